@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// backupVersion is bumped when the backup file layout changes
+const backupVersion = 1
+
+// backupTables are the application tables saved by backup, parents before
+// children. The logs table is left out; it is diagnostic and can be large.
+var backupTables = []string{
+	"users",
+	"session_metadata",
+	"messages",
+	"contact_groups",
+	"contacts",
+	"message_templates",
+	"campaigns",
+	"campaign_messages",
+	"auto_replies",
+	"auto_reply_logs",
+	"webhook_deliveries",
+	"chat_labels",
+	"chat_label_assignments",
+	"contact_first_seen",
+	"chat_state",
+}
+
+// droppedBackupTables are tables older backups may contain that no longer
+// exist; they are skipped on restore
+var droppedBackupTables = map[string]bool{
+	"webhook_events": true,
+}
+
+// storeSuffix names the copy of the WhatsApp device store next to a backup file
+const storeSuffix = ".store.db"
+
+type backupFile struct {
+	Version   int           `json:"version"`
+	CreatedAt string        `json:"created_at"`
+	Tables    []backupTable `json:"tables"`
+}
+
+type backupTable struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+func (c *cli) dbMigrate(args []string) error {
+	fs := c.flags("db migrate")
+	fs.Parse(args)
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	applied, err := c.db.Migrate()
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+	if applied == nil {
+		applied = []repository.Migration{}
+	}
+
+	return c.print(map[string]any{"applied": applied}, func(w io.Writer) {
+		for _, migration := range applied {
+			fmt.Fprintf(w, "Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		fmt.Fprintf(w, "Database %s is up to date\n", c.db.Name())
+	})
+}
+
+func (c *cli) dbRollback(args []string) error {
+	fs := c.flags("db rollback")
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	fs.Parse(args)
+
+	if *steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	release, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	rolledBack, err := c.db.Rollback(*steps)
+	if err != nil {
+		// Migrations rolled back before the failure stay rolled back
+		return fmt.Errorf("failed to roll back database after %d migration(s): %v", len(rolledBack), err)
+	}
+	if rolledBack == nil {
+		rolledBack = []repository.Migration{}
+	}
+
+	return c.print(map[string]any{"rolled_back": rolledBack}, func(w io.Writer) {
+		for _, migration := range rolledBack {
+			fmt.Fprintf(w, "Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	})
+}
+
+func (c *cli) dbStatus(args []string) error {
+	fs := c.flags("db status")
+	fs.Parse(args)
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	statuses, err := c.db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	return c.print(statuses, func(w io.Writer) {
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tREVERSIBLE")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = models.FormatTimestamp(*status.AppliedAt)
+			}
+			reversible := "no"
+			if status.Reversible {
+				reversible = "yes"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, appliedAt, reversible)
+		}
+		if floor := repository.RollbackFloor(statuses); floor != nil {
+			fmt.Fprintf(w, "\ndb rollback cannot go past %04d_%s, which has no down migration\n", floor.Version, floor.Name)
+		}
+	})
+}
+
+func (c *cli) backup(args []string) error {
+	fs := c.flags("backup")
+	out := fs.String("out", "", "backup file to write (required)")
+	withStore := fs.Bool("with-store", false, "also copy the WhatsApp device store (requires the server to be stopped)")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	if *withStore {
+		// Copying the SQLite store while the server writes to it can tear it
+		release, err := c.lock()
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	backup := backupFile{Version: backupVersion, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	rows := 0
+	for _, table := range backupTables {
+		dump, err := c.dumpTable(table)
+		if err != nil {
+			return err
+		}
+		rows += len(dump.Rows)
+		backup.Tables = append(backup.Tables, dump)
+	}
+
+	data, err := json.Marshal(backup)
+	if err != nil {
+		return fmt.Errorf("failed to encode backup: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup: %v", err)
+	}
+
+	result := map[string]any{"file": *out, "tables": len(backup.Tables), "rows": rows}
+	if *withStore {
+		if err := copyFile(c.cfg.WhatsAppDBPath, *out+storeSuffix); err != nil {
+			return fmt.Errorf("failed to copy WhatsApp store: %v", err)
+		}
+		result["store_file"] = *out + storeSuffix
+	}
+
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Backed up %d rows from %d tables to %s\n", rows, len(backup.Tables), *out)
+		if *withStore {
+			fmt.Fprintf(w, "WhatsApp store copied to %s\n", *out+storeSuffix)
+		}
+	})
+}
+
+// dumpTable reads every row of a table
+func (c *cli) dumpTable(table string) (backupTable, error) {
+	dump := backupTable{Name: table, Rows: [][]any{}}
+
+	rows, err := c.db.DB().Query("SELECT * FROM `" + table + "`")
+	if err != nil {
+		return dump, fmt.Errorf("failed to read %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	if dump.Columns, err = rows.Columns(); err != nil {
+		return dump, fmt.Errorf("failed to read %s columns: %v", table, err)
+	}
+
+	for rows.Next() {
+		values := make([]any, len(dump.Columns))
+		pointers := make([]any, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return dump, fmt.Errorf("failed to read %s row: %v", table, err)
+		}
+
+		for i, value := range values {
+			switch v := value.(type) {
+			case []byte:
+				values[i] = string(v)
+			case time.Time:
+				values[i] = v.UTC().Format("2006-01-02 15:04:05")
+			}
+		}
+		dump.Rows = append(dump.Rows, values)
+	}
+
+	return dump, rows.Err()
+}
+
+func (c *cli) restore(args []string) error {
+	fs := c.flags("restore")
+	in := fs.String("in", "", "backup file to restore (required)")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("--in is required")
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer file.Close()
+
+	var backup backupFile
+	decoder := json.NewDecoder(file)
+	// Keep integers exact instead of decoding them as float64
+	decoder.UseNumber()
+	if err := decoder.Decode(&backup); err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	if backup.Version != backupVersion {
+		return fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	if err := validateBackup(&backup); err != nil {
+		return err
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	release, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Make sure tables and columns added since the backup exist
+	if err := c.db.InitTables(); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	rows, err := c.restoreTables(&backup)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{"file": *in, "tables": len(backup.Tables), "rows": rows}
+	storeFile := *in + storeSuffix
+	if _, err := os.Stat(storeFile); err == nil {
+		if err := copyFile(storeFile, c.cfg.WhatsAppDBPath); err != nil {
+			return fmt.Errorf("failed to restore WhatsApp store: %v", err)
+		}
+		result["store_file"] = storeFile
+	}
+
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Restored %d rows into %d tables from %s (backup taken %s)\n", rows, len(backup.Tables), *in, backup.CreatedAt)
+		if _, ok := result["store_file"]; ok {
+			fmt.Fprintf(w, "WhatsApp store restored to %s\n", c.cfg.WhatsAppDBPath)
+		}
+	})
+}
+
+// restoreTables replaces the contents of every table in the backup in one transaction
+func (c *cli) restoreTables(backup *backupFile) (int, error) {
+	ctx := context.Background()
+	conn, err := c.db.DB().Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// Rows are inserted table by table, so relations are checked only once all are back
+	dialect := c.db.Dialect()
+	if _, err := conn.ExecContext(ctx, dialect.ForeignKeyChecks(false)); err != nil {
+		return 0, fmt.Errorf("failed to disable foreign key checks: %v", err)
+	}
+	defer conn.ExecContext(ctx, dialect.ForeignKeyChecks(true))
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	restored := 0
+	for _, table := range backup.Tables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM `"+table.Name+"`"); err != nil {
+			return 0, fmt.Errorf("failed to clear %s: %v", table.Name, err)
+		}
+		if len(table.Rows) == 0 {
+			continue
+		}
+
+		query := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES (%s)", table.Name,
+			strings.Join(table.Columns, "`, `"),
+			strings.TrimSuffix(strings.Repeat("?, ", len(table.Columns)), ", "))
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("failed to prepare %s insert: %v", table.Name, err)
+		}
+		for _, row := range table.Rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				stmt.Close()
+				return 0, fmt.Errorf("failed to restore %s row: %v", table.Name, err)
+			}
+		}
+		stmt.Close()
+		restored += len(table.Rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit restore: %v", err)
+	}
+	return restored, nil
+}
+
+// validateBackup rejects tables and columns that could not have come from
+// backup, since their names are spliced into SQL, and leaves out dropped tables
+func validateBackup(backup *backupFile) error {
+	known := make(map[string]bool, len(backupTables))
+	for _, table := range backupTables {
+		known[table] = true
+	}
+
+	tables := backup.Tables[:0]
+	for _, table := range backup.Tables {
+		if !droppedBackupTables[table.Name] {
+			tables = append(tables, table)
+		}
+	}
+	backup.Tables = tables
+
+	for _, table := range backup.Tables {
+		if !known[table.Name] {
+			return fmt.Errorf("backup contains unknown table %q", table.Name)
+		}
+		for _, column := range table.Columns {
+			if column == "" || strings.ContainsAny(column, "`\\") {
+				return fmt.Errorf("backup contains invalid column %q in %s", column, table.Name)
+			}
+		}
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				return fmt.Errorf("backup row in %s has %d values for %d columns", table.Name, len(row), len(table.Columns))
+			}
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst, replacing dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,213 @@
+// Command wamsctl administers a WhatsApp Multi-Session deployment without the
+// web UI or HTTP API. It reads the same environment configuration as the
+// server (.env is not loaded automatically) and talks to the database directly.
+//
+// Destructive commands take the instance lock held by a running server and
+// refuse to run while it is up, unless --force is given.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"whatsapp-multi-session/internal/config"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+const usage = `Usage: wamsctl [--json] [--force] <command> [flags]
+
+Commands:
+  user list                                   List users
+  user create --username U --password P       Create a user (--role, --session-limit, --region)
+  user reset-password --username U            Set a new password (--password, or generated)
+  session list                                List sessions (--user to filter by owner)
+  session disable <session-id>                Disable a session so it is not auto-connected
+  session delete <session-id>                 Delete a session's metadata
+  apikey revoke --username U                  Revoke a user's API key
+  db migrate                                  Apply pending schema migrations
+  db status                                   List schema migrations and when they were applied
+  db rollback                                 Undo the last migration, for development (--steps);
+                                              stops at migrations without a down file, see db status
+  backup --out FILE                           Write the application tables to FILE (--with-store)
+  restore --in FILE                           Replace the application tables from FILE
+
+Global flags:
+  --json    Print results as JSON
+  --force   Run destructive commands even if a server instance holds the lock
+`
+
+// lockWait is how long destructive commands wait for the instance lock
+const lockWait = 2 * time.Second
+
+type cli struct {
+	cfg   *config.Config
+	log   *logger.Logger
+	db    *repository.Database
+	json  bool
+	force bool
+	out   io.Writer
+}
+
+func main() {
+	c := &cli{out: os.Stdout}
+
+	args := c.parseGlobal(os.Args[1:])
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := c.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "wamsctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseGlobal parses the global flags given before the command and returns
+// the command with its arguments
+func (c *cli) parseGlobal(args []string) []string {
+	global := flag.NewFlagSet("wamsctl", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	c.bindGlobal(global)
+	global.Parse(args)
+	return global.Args()
+}
+
+// bindGlobal registers the flags accepted before and after every command
+func (c *cli) bindGlobal(fs *flag.FlagSet) {
+	fs.BoolVar(&c.json, "json", c.json, "print results as JSON")
+	fs.BoolVar(&c.force, "force", c.force, "run destructive commands even if a server holds the instance lock")
+}
+
+// flags returns a flag set for a command that also accepts the global flags
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("wamsctl "+name, flag.ExitOnError)
+	c.bindGlobal(fs)
+	return fs
+}
+
+func (c *cli) run(args []string) error {
+	command, args := args[0], args[1:]
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch command {
+	case "user":
+		switch sub {
+		case "list":
+			return c.userList(args[1:])
+		case "create":
+			return c.userCreate(args[1:])
+		case "reset-password":
+			return c.userResetPassword(args[1:])
+		}
+	case "session":
+		switch sub {
+		case "list":
+			return c.sessionList(args[1:])
+		case "disable":
+			return c.sessionDisable(args[1:])
+		case "delete":
+			return c.sessionDelete(args[1:])
+		}
+	case "apikey":
+		if sub == "revoke" {
+			return c.apiKeyRevoke(args[1:])
+		}
+	case "db":
+		switch sub {
+		case "migrate":
+			return c.dbMigrate(args[1:])
+		case "status":
+			return c.dbStatus(args[1:])
+		case "rollback":
+			return c.dbRollback(args[1:])
+		}
+	case "backup":
+		return c.backup(args)
+	case "restore":
+		return c.restore(args)
+	case "help", "-h", "--help":
+		fmt.Fprint(c.out, usage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command: %s %s", command, sub)
+}
+
+// connect loads the configuration and opens the application database
+func (c *cli) connect() error {
+	if c.db != nil {
+		return nil
+	}
+
+	c.cfg = config.Load()
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	// Keep stdout for command output; errors are returned instead
+	c.log = logger.New(false, c.cfg.LogLevel, c.cfg.LogFormat)
+
+	db, err := repository.NewDatabase(repository.DatabaseConfig{
+		Type:     c.cfg.DatabaseType,
+		Path:     c.cfg.DatabasePath,
+		Host:     c.cfg.MySQLHost,
+		Port:     c.cfg.MySQLPort,
+		User:     c.cfg.MySQLUser,
+		Password: c.cfg.MySQLPassword,
+		Database: c.cfg.MySQLDatabase,
+	})
+	if err != nil {
+		return err
+	}
+	c.db = db
+	return nil
+}
+
+// lock takes the instance lock for a destructive command. The returned
+// function releases it.
+func (c *cli) lock() (func(), error) {
+	lock, err := c.db.AcquireInstanceLock(lockWait)
+	if errors.Is(err, repository.ErrInstanceLocked) {
+		if c.force {
+			fmt.Fprintln(os.Stderr, "wamsctl: warning: a server instance appears to be running, continuing because of --force")
+			return func() {}, nil
+		}
+		return nil, fmt.Errorf("a server instance appears to be running against this database; stop it first or pass --force")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Release() }, nil
+}
+
+// print writes v as JSON, or calls text to write it for humans
+func (c *cli) print(v any, text func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// requireArg returns the single positional argument of a command
+func requireArg(fs *flag.FlagSet, name string) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s requires exactly one %s argument", fs.Name(), name)
+	}
+	return fs.Arg(0), nil
+}
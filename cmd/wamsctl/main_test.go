@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// newTestCLI returns a CLI configured through the environment, as the
+// server is, for a migrated SQLite database removed when the test ends
+func newTestCLI(t *testing.T) *cli {
+	t.Helper()
+	t.Setenv("DB_TYPE", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "app.db"))
+	t.Setenv("LOG_LEVEL", "error")
+
+	c := &cli{out: &bytes.Buffer{}}
+	t.Cleanup(func() {
+		if c.db != nil {
+			c.db.Close()
+		}
+	})
+	if _, err := runCLI(c, "db", "migrate"); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return c
+}
+
+// runCLI runs a command line as a fresh invocation would and returns its output
+func runCLI(c *cli, args ...string) (string, error) {
+	c.json, c.force = false, false
+	out := c.out.(*bytes.Buffer)
+	out.Reset()
+	err := c.run(c.parseGlobal(args))
+	return out.String(), err
+}
+
+func TestParseGlobal(t *testing.T) {
+	tests := []struct {
+		args  []string
+		json  bool
+		force bool
+		rest  string
+	}{
+		{[]string{"user", "list"}, false, false, "user list"},
+		{[]string{"--json", "user", "list"}, true, false, "user list"},
+		{[]string{"--force", "--json", "session", "delete", "s1"}, true, true, "session delete s1"},
+		// Flags after the command are left to the command
+		{[]string{"session", "delete", "--force", "s1"}, false, false, "session delete --force s1"},
+		{[]string{"--json"}, true, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			c := &cli{}
+			rest := c.parseGlobal(tt.args)
+			if c.json != tt.json || c.force != tt.force || strings.Join(rest, " ") != tt.rest {
+				t.Errorf("parsed json=%v force=%v command %q, want json=%v force=%v command %q",
+					c.json, c.force, strings.Join(rest, " "), tt.json, tt.force, tt.rest)
+			}
+		})
+	}
+}
+
+// Invalid command lines are rejected before the database is touched
+func TestRunRejectsArguments(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"frobnicate"}, "unknown command: frobnicate"},
+		{[]string{"user"}, "unknown command: user"},
+		{[]string{"user", "remove"}, "unknown command: user remove"},
+		{[]string{"apikey", "create"}, "unknown command: apikey create"},
+		{[]string{"user", "create"}, "--username is required"},
+		{[]string{"user", "create", "--username", "bob", "--role", "owner"}, "--role must be"},
+		{[]string{"user", "create", "--username", "bob", "--session-limit", "-1"}, "--session-limit must be"},
+		{[]string{"user", "create", "--username", "bob", "--password", "short"}, "at least 6 characters"},
+		{[]string{"user", "reset-password"}, "--username is required"},
+		{[]string{"user", "reset-password", "--username", "bob", "--password", "12345"}, "at least 6 characters"},
+		{[]string{"session", "disable"}, "requires exactly one session-id argument"},
+		{[]string{"session", "delete", "s1", "s2"}, "requires exactly one session-id argument"},
+		{[]string{"apikey", "revoke"}, "--username is required"},
+		{[]string{"db", "rollback", "--steps", "0"}, "--steps must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			c := &cli{out: &bytes.Buffer{}}
+			_, err := runCLI(c, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+			if c.db != nil {
+				c.db.Close()
+				t.Error("database opened for an invalid command line")
+			}
+		})
+	}
+}
+
+func TestUserResetPassword(t *testing.T) {
+	c := newTestCLI(t)
+	if _, err := runCLI(c, "user", "create", "--username", "alice", "--password", "first-pass"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	login := func(password string) error {
+		_, err := c.userService().Login(&models.LoginRequest{Username: "alice", Password: password})
+		return err
+	}
+
+	// Without --password a new one is generated and shown once
+	out, err := runCLI(c, "user", "reset-password", "--username", "alice", "--json")
+	if err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	var result struct {
+		User     models.User `json:"user"`
+		Password string      `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if result.User.Username != "alice" || len(result.Password) < minPasswordLength {
+		t.Fatalf("reset reported user %q with password %q", result.User.Username, result.Password)
+	}
+	if err := login(result.Password); err != nil {
+		t.Errorf("login with the generated password failed: %v", err)
+	}
+	if err := login("first-pass"); err == nil {
+		t.Error("login with the old password still works")
+	}
+
+	// A given password is set and not echoed
+	out, err = runCLI(c, "user", "reset-password", "--username", "alice", "--password", "chosen-pass")
+	if err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if !strings.Contains(out, "User alice (ID ") || !strings.Contains(out, "password reset") || strings.Contains(out, "chosen-pass") {
+		t.Errorf("output = %q, want the reset reported without the password", out)
+	}
+	if err := login("chosen-pass"); err != nil {
+		t.Errorf("login with the chosen password failed: %v", err)
+	}
+	if err := login(result.Password); err == nil {
+		t.Error("login with the generated password still works")
+	}
+
+	if _, err := runCLI(c, "user", "reset-password", "--username", "nobody", "--password", "chosen-pass"); err == nil || !strings.Contains(err.Error(), "user not found") {
+		t.Errorf("resetting an unknown user = %v, want user not found", err)
+	}
+}
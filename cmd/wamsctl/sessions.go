@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+func (c *cli) sessionList(args []string) error {
+	fs := c.flags("session list")
+	userID := fs.Int("user", 0, "only list sessions owned by this user ID")
+	fs.Parse(args)
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	repo := repository.NewSessionRepository(c.db.DB())
+	var sessions []*models.SessionMetadata
+	var err error
+	if *userID > 0 {
+		sessions, err = repo.GetByUserID(*userID)
+	} else {
+		sessions, err = repo.GetAll()
+	}
+	if err != nil {
+		return err
+	}
+	if sessions == nil {
+		sessions = []*models.SessionMetadata{}
+	}
+
+	return c.print(sessions, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tPHONE\tUSER\tENABLED\tBANNED UNTIL")
+		for _, session := range sessions {
+			bannedUntil := "-"
+			if session.BannedUntil != nil {
+				bannedUntil = models.FormatTimestamp(*session.BannedUntil)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%t\t%s\n", session.ID, session.Name, session.ActualPhone,
+				session.UserID, session.Enabled, bannedUntil)
+		}
+	})
+}
+
+func (c *cli) sessionDisable(args []string) error {
+	fs := c.flags("session disable")
+	fs.Parse(args)
+
+	sessionID, err := requireArg(fs, "session-id")
+	if err != nil {
+		return err
+	}
+
+	return c.withSession(sessionID, "disabled", func(repo *repository.SessionRepository) error {
+		return repo.UpdateSessionEnabled(sessionID, false)
+	})
+}
+
+func (c *cli) sessionDelete(args []string) error {
+	fs := c.flags("session delete")
+	fs.Parse(args)
+
+	sessionID, err := requireArg(fs, "session-id")
+	if err != nil {
+		return err
+	}
+
+	// Like the API, this removes the session's metadata only; the linked
+	// device stays in the WhatsApp store until it is logged out on the phone
+	return c.withSession(sessionID, "deleted", func(repo *repository.SessionRepository) error {
+		return repo.Delete(sessionID)
+	})
+}
+
+// withSession runs a destructive change on an existing session under the instance lock
+func (c *cli) withSession(sessionID, action string, change func(repo *repository.SessionRepository) error) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	release, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo := repository.NewSessionRepository(c.db.DB())
+	session, err := repo.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+
+	if err := change(repo); err != nil {
+		return err
+	}
+
+	result := map[string]any{"session_id": sessionID, action: true}
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Session %s (%s) %s\n", sessionID, session.Name, action)
+	})
+}
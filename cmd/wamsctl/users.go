@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/services"
+)
+
+// minPasswordLength matches the admin API's password rule
+const minPasswordLength = 6
+
+func (c *cli) userService() *services.UserService {
+	return services.NewUserService(repository.NewUserRepository(c.db.DB()),
+		repository.NewAPIKeyRepository(c.db.DB()), c.cfg.JWTSecret, c.log)
+}
+
+func (c *cli) userList(args []string) error {
+	fs := c.flags("user list")
+	fs.Parse(args)
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	users, err := c.userService().GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	return c.print(users, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tUSERNAME\tROLE\tSESSION LIMIT\tACTIVE\tCREATED")
+		for _, user := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%t\t%s\n", user.ID, user.Username, user.Role,
+				user.SessionLimit, user.IsActive, models.FormatTimestamp(user.CreatedAt))
+		}
+	})
+}
+
+func (c *cli) userCreate(args []string) error {
+	fs := c.flags("user create")
+	req := &models.CreateUserRequest{}
+	fs.StringVar(&req.Username, "username", "", "username (required)")
+	fs.StringVar(&req.Password, "password", "", "password (generated when empty)")
+	fs.StringVar(&req.Role, "role", models.RoleUser, "role: admin or user")
+	sessionLimit := fs.Int("session-limit", models.DefaultSessionLimit, "maximum number of sessions, 0 for unlimited")
+	fs.StringVar(&req.DefaultRegion, "region", "", "default phone region, e.g. ID")
+	fs.Parse(args)
+
+	if req.Username == "" {
+		return fmt.Errorf("--username is required")
+	}
+	if req.Role != models.RoleAdmin && req.Role != models.RoleUser {
+		return fmt.Errorf("--role must be 'admin' or 'user'")
+	}
+	if *sessionLimit < 0 {
+		return fmt.Errorf("--session-limit must be 0 (unlimited) or more")
+	}
+	req.SessionLimit = sessionLimit
+	generated, err := ensurePassword(&req.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	user, err := c.userService().CreateUser(req)
+	if err != nil {
+		return err
+	}
+
+	return c.printCredentials("created", user, req.Password, generated)
+}
+
+func (c *cli) userResetPassword(args []string) error {
+	fs := c.flags("user reset-password")
+	username := fs.String("username", "", "username (required)")
+	password := fs.String("password", "", "new password (generated when empty)")
+	fs.Parse(args)
+
+	if *username == "" {
+		return fmt.Errorf("--username is required")
+	}
+	generated, err := ensurePassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	user, err := c.userService().ResetPassword(*username, *password)
+	if err != nil {
+		return err
+	}
+
+	return c.printCredentials("password reset", user, *password, generated)
+}
+
+func (c *cli) apiKeyRevoke(args []string) error {
+	fs := c.flags("apikey revoke")
+	username := fs.String("username", "", "username (required)")
+	fs.Parse(args)
+
+	if *username == "" {
+		return fmt.Errorf("--username is required")
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	user, err := repository.NewUserRepository(c.db.DB()).GetByUsername(*username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", *username)
+	}
+
+	if err := c.userService().RevokeAPIKey(user.ID); err != nil {
+		return err
+	}
+
+	result := map[string]any{"user_id": user.ID, "username": user.Username, "api_key_revoked": true}
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "API key of user %s revoked\n", user.Username)
+	})
+}
+
+// printCredentials reports a created or reset account. A generated password
+// is shown once, since it cannot be recovered later.
+func (c *cli) printCredentials(action string, user *models.User, password string, generated bool) error {
+	result := map[string]any{"user": user}
+	if generated {
+		result["password"] = password
+	}
+
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "User %s (ID %d) %s\n", user.Username, user.ID, action)
+		if generated {
+			fmt.Fprintf(w, "Generated password: %s\n", password)
+		}
+	})
+}
+
+// ensurePassword generates a password when none was given and checks its length
+func ensurePassword(password *string) (generated bool, err error) {
+	if *password == "" {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return false, fmt.Errorf("failed to generate password: %v", err)
+		}
+		*password = base64.RawURLEncoding.EncodeToString(buf)
+		return true, nil
+	}
+
+	if len(*password) < minPasswordLength {
+		return false, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return false, nil
+}
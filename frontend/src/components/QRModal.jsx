@@ -76,6 +76,14 @@ const QRModal = ({ session, onClose, onSuccess }) => {
           console.log('⏰ Setting countdown to:', timeoutSeconds, 'seconds');
           setCountdown(timeoutSeconds);
           startCountdown();
+        } else if (data.type === 'pairing_finalizing') {
+          console.log('🔄 Pairing successful, waiting for WhatsApp to finish login');
+          setLoading(true);
+          setError('');
+          if (countdownIntervalRef.current) {
+            clearInterval(countdownIntervalRef.current);
+            countdownIntervalRef.current = null;
+          }
         } else if (data.type === 'success') {
           console.log('✅ Success message received');
           setLoading(false);
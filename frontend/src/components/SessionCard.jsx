@@ -217,6 +217,16 @@ const SessionCard = ({
                 <span>Not connected</span>
               </div>
             )}
+            {session.banned && (
+              <div
+                className="flex items-center text-xs font-semibold text-red-700 bg-red-50 border border-red-200 rounded-lg px-2 py-1 mb-1"
+                title={session.ban_reason || "Temporarily banned by WhatsApp"}
+              >
+                <span className="truncate">
+                  Banned until {new Date(session.banned_until).toLocaleString()}
+                </span>
+              </div>
+            )}
             <div className="flex items-center justify-between text-xs text-gray-500 group/id">
               <div className="flex items-center flex-1 min-w-0">
                 <svg className="w-3 h-3 mr-1.5 text-primary-500 flex-shrink-0" fill="currentColor" viewBox="0 0 20 20">
@@ -230,7 +230,7 @@ const UserManagement = () => {
       username: user.username, 
       password: '', 
       role: user.role || 'user',
-      session_limit: user.session_limit || 5
+      session_limit: user.session_limit ?? 5
     });
     setShowEditModal(true);
   };
@@ -656,9 +656,9 @@ const UserManagement = () => {
                           <td className="px-6 py-4 whitespace-nowrap">
                             <div className="flex items-center">
                               <span className={`text-sm font-medium ${
-                                user.session_limit === -1 ? 'text-primary-600' : 'text-gray-900'
+                                user.session_limit <= 0 ? 'text-primary-600' : 'text-gray-900'
                               }`}>
-                                {user.session_limit === -1 ? (
+                                {user.session_limit <= 0 ? (
                                   <span className="flex items-center">
                                     <svg className="w-4 h-4 mr-1 text-primary-500" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                                       <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M8 12h.01M12 12h.01M16 12h.01M21 12c0 4.418-4.03 8-9 8a9.863 9.863 0 01-4.255-.949L3 20l1.395-3.72C3.512 15.042 3 13.574 3 12c0-4.418 4.03-8 9-8s9 3.582 9 8z" />
@@ -812,16 +812,19 @@ const UserManagement = () => {
                   <input
                     type="number"
                     value={formData.session_limit}
-                    onChange={(e) => setFormData({ ...formData, session_limit: parseInt(e.target.value) || 5 })}
+                    onChange={(e) => {
+                      const limit = parseInt(e.target.value);
+                      setFormData({ ...formData, session_limit: Number.isNaN(limit) ? 5 : limit });
+                    }}
                     className="w-full px-4 py-3 border border-gray-300 rounded-xl focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all duration-200 text-sm"
-                    min="-1"
+                    min="0"
                     required
                   />
                   <p className="text-xs text-gray-500 mt-2 flex items-center">
                     <svg className="w-3 h-3 mr-1" fill="currentColor" viewBox="0 0 20 20">
                       <path fillRule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7-4a1 1 0 11-2 0 1 1 0 012 0zM9 9a1 1 0 000 2v3a1 1 0 001 1h1a1 1 0 100-2v-3a1 1 0 00-1-1H9z" clipRule="evenodd" />
                     </svg>
-                    Use -1 for unlimited sessions
+                    Use 0 for unlimited sessions
                   </p>
                 </div>
 
@@ -938,15 +941,18 @@ const UserManagement = () => {
                   <input
                     type="number"
                     value={formData.session_limit}
-                    onChange={(e) => setFormData({ ...formData, session_limit: parseInt(e.target.value) || 5 })}
+                    onChange={(e) => {
+                      const limit = parseInt(e.target.value);
+                      setFormData({ ...formData, session_limit: Number.isNaN(limit) ? 5 : limit });
+                    }}
                     className="w-full px-4 py-3 border border-gray-300 rounded-xl focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all duration-200 text-sm"
-                    min="-1"
+                    min="0"
                   />
                   <p className="text-xs text-gray-500 mt-2 flex items-center">
                     <svg className="w-3 h-3 mr-1" fill="currentColor" viewBox="0 0 20 20">
                       <path fillRule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7-4a1 1 0 11-2 0 1 1 0 012 0zM9 9a1 1 0 000 2v3a1 1 0 001 1h1a1 1 0 100-2v-3a1 1 0 00-1-1H9z" clipRule="evenodd" />
                     </svg>
-                    Use -1 for unlimited sessions
+                    Use 0 for unlimited sessions
                   </p>
                 </div>
 
@@ -26,6 +26,7 @@ export const AuthProvider = ({ children }) => {
         } catch (error) {
           // Invalid user data, clear everything
           localStorage.removeItem('auth_token');
+          localStorage.removeItem('refresh_token');
           localStorage.removeItem('user_data');
           setToken(null);
           setUser(null);
@@ -43,13 +44,58 @@ export const AuthProvider = ({ children }) => {
     setLoading(false);
   }, [token]);
 
+  // Stores the tokens of a login, refresh or password change
+  const storeTokens = (newToken, refreshToken) => {
+    localStorage.setItem('auth_token', newToken);
+    if (refreshToken) {
+      localStorage.setItem('refresh_token', refreshToken);
+    }
+    axios.defaults.headers.common['Authorization'] = `Bearer ${newToken}`;
+    setToken(newToken);
+  };
+
   useEffect(() => {
-    // Add response interceptor to handle 401 errors
+    // One refresh at a time: a rotated refresh token can only be used once
+    let refreshing = null;
+
+    const refresh = () => {
+      if (!refreshing) {
+        const refreshToken = localStorage.getItem('refresh_token');
+        if (!refreshToken) {
+          return Promise.reject(new Error('No refresh token'));
+        }
+        refreshing = axios
+          .post('/api/auth/refresh', { refresh_token: refreshToken }, { skipAuthRefresh: true })
+          .then((response) => {
+            const { token: newToken, refresh_token: newRefreshToken } = response.data.data;
+            storeTokens(newToken, newRefreshToken);
+            return newToken;
+          })
+          .finally(() => {
+            refreshing = null;
+          });
+      }
+      return refreshing;
+    };
+
+    // Renew expired access tokens with the refresh token and retry once;
+    // log out when that fails
     const interceptor = axios.interceptors.response.use(
       (response) => response,
-      (error) => {
-        if (error.response && error.response.status === 401) {
-          logout();
+      async (error) => {
+        const config = error.config;
+        if (error.response && error.response.status === 401 && config && !config.skipAuthRefresh) {
+          if (!config.retriedAfterRefresh) {
+            try {
+              const newToken = await refresh();
+              config.retriedAfterRefresh = true;
+              config.headers = { ...config.headers, Authorization: `Bearer ${newToken}` };
+              return axios(config);
+            } catch (refreshError) {
+              // Fall through to logout
+            }
+          }
+          clearSession();
         }
         return Promise.reject(error);
       }
@@ -68,16 +114,12 @@ export const AuthProvider = ({ children }) => {
       });
 
       if (response.data.success) {
-        const { token: newToken, user: userData } = response.data.data;
+        const { token: newToken, refresh_token: refreshToken, user: userData } = response.data.data;
         
-        localStorage.setItem('auth_token', newToken);
         localStorage.setItem('user_data', JSON.stringify(userData));
-        
-        setToken(newToken);
+        storeTokens(newToken, refreshToken);
         setUser(userData);
         
-        axios.defaults.headers.common['Authorization'] = `Bearer ${newToken}`;
-        
         return { success: true };
       }
     } catch (error) {
@@ -110,8 +152,9 @@ export const AuthProvider = ({ children }) => {
     }
   };
 
-  const logout = () => {
+  const clearSession = () => {
     localStorage.removeItem('auth_token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user_data');
     delete axios.defaults.headers.common['Authorization'];
     
@@ -119,12 +162,24 @@ export const AuthProvider = ({ children }) => {
     setUser(null);
   };
 
+  const logout = () => {
+    // Revoke the refresh token; the short-lived access token just expires
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (refreshToken) {
+      axios
+        .post('/api/auth/logout', { refresh_token: refreshToken }, { skipAuthRefresh: true })
+        .catch(() => {});
+    }
+    clearSession();
+  };
+
   const value = {
     user,
     token,
     loading,
     login,
     logout,
+    storeTokens,
     isAuthenticated: !!user && !!token,
   };
 
@@ -4,7 +4,7 @@ import { useNotification } from '../contexts/NotificationContext';
 import axios from 'axios';
 
 const Settings = () => {
-  const { user } = useAuth();
+  const { user, storeTokens } = useAuth();
   const { showNotification } = useNotification();
   const [activeTab, setActiveTab] = useState('profile');
   const [loading, setLoading] = useState(false);
@@ -98,10 +98,15 @@ const Settings = () => {
 
     try {
       setLoadingPassword(true);
-      await axios.post('/api/auth/change-password', {
+      const response = await axios.post('/api/auth/change-password', {
         old_password: passwordForm.oldPassword,
         new_password: passwordForm.newPassword
       });
+      // Changing the password revokes every other token; keep this session
+      const tokens = response.data.data;
+      if (tokens?.token) {
+        storeTokens(tokens.token, tokens.refresh_token);
+      }
       
       setPasswordForm({ oldPassword: '', newPassword: '', confirmPassword: '' });
       showNotification('Password changed successfully!', 'success');
@@ -186,7 +191,7 @@ const Settings = () => {
               <label className="block text-sm font-medium text-gray-700">Session Limit</label>
               <input
                 type="text"
-                value={user?.session_limit || 'N/A'}
+                value={user?.session_limit === undefined ? 'N/A' : user.session_limit <= 0 ? 'Unlimited' : user.session_limit}
                 disabled
                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm bg-gray-50 text-gray-500"
               />
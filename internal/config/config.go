@@ -1,20 +1,46 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/joho/godotenv"
+
+	"whatsapp-multi-session/internal/utils"
+	"whatsapp-multi-session/pkg/httpclient"
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/phone"
 )
 
 // Config holds all application configuration
 type Config struct {
 	// Server configuration
 	Port string
+	// How long shutdown waits for requests, bulk jobs and webhooks in flight
+	ShutdownTimeout time.Duration
+
+	// Public URL settings (used to build absolute URLs in webhooks)
+	PublicBaseURL     string
+	TrustProxyHeaders bool
+
+	// Outbound HTTP proxy for webhook delivery and URL downloads
+	OutboundHTTPProxy    string
+	OutboundNoProxy      string
+	OutboundProxyTestURL string
+
+	// Let webhooks and URL downloads reach loopback, link-local and private addresses
+	AllowPrivateWebhooks bool
+
+	// Default region (ISO 3166-1 alpha-2) for formatting phone numbers when a user has none set
+	DefaultPhoneRegion string
 
 	// Database configuration
 	WhatsAppDBPath string
+	DatabaseType   string // mysql or sqlite
+	DatabasePath   string // Application database file when DatabaseType is sqlite
 	MySQLHost      string
 	MySQLPort      string
 	MySQLUser      string
@@ -22,8 +48,9 @@ type Config struct {
 	MySQLDatabase  string
 
 	// JWT configuration
-	JWTSecret     string
-	JWTExpiration time.Duration
+	JWTSecret       string
+	JWTExpiration   time.Duration // Lifetime of access tokens
+	RefreshTokenTTL time.Duration
 
 	// Admin credentials
 	AdminUsername string
@@ -33,14 +60,33 @@ type Config struct {
 	EnableLogging       bool
 	EnableDatabaseLog   bool
 	EnableFrontend      bool
+	EnableMetrics       bool
 	LogLevel            string
+	LogFormat           string // text or json
 	MaxSessions         int
+	MaxMediaSizeMB      int
+	MaxDownloadSize     int64         // Largest file downloaded from a URL, in bytes
+	DownloadTimeout     time.Duration // How long a URL download may take
 	SessionTimeout      time.Duration
 
+	// Hour of day (0-23, server local time) when contact engagement scores are recomputed
+	ContactScoringHour int
+
+	// How long a sent message may stay undelivered before it is classified as probably blocked
+	BlockSuspectAfter time.Duration
+
 	// WhatsApp settings
 	AutoConnect bool
 	QRTimeout   time.Duration
 
+	// Create every new session as a sandbox session that simulates WhatsApp
+	SandboxMode bool
+	// How long a sandbox send waits before its delivered receipt
+	SandboxReceiptDelay time.Duration
+
+	// ffmpeg binary used to convert voice notes to OGG/Opus, by path or name in PATH
+	FFmpegPath string
+
 	// Security settings
 	CORSAllowedOrigins []string
 	RateLimit          int
@@ -48,6 +94,50 @@ type Config struct {
 	// Webhook settings
 	WebhookTimeout    time.Duration
 	WebhookMaxRetries int
+	// Suspend a session's webhook after it has failed continuously this long (0 disables)
+	WebhookSuspendAfter time.Duration
+	// Give up on a queued webhook delivery this long after the event
+	WebhookMaxAge time.Duration
+	// Prune delivered webhook deliveries after this long (0 keeps them)
+	WebhookDeliveryRetention time.Duration
+	// Optional URL that receives a JSON notice when a webhook is suspended or a session is banned
+	OperatorNotifyURL string
+
+	// Reconnecting dropped sessions: failed reconnects before a session needs
+	// attention (0 never gives up), and the bounds of the backoff between them
+	ReconnectMaxAttempts int
+	ReconnectBaseDelay   time.Duration
+	ReconnectMaxDelay    time.Duration
+
+	// How long media uploads are reused for identical URLs and content (0 disables)
+	UploadCacheTTL time.Duration
+	// Delete received media after this long (0 keeps it)
+	MediaRetention time.Duration
+
+	// Replace message content with "[redacted]" in every message export
+	ExportRedactContent bool
+
+	// Open WebSocket connections per user: warn above the soft limit, reject at the hard limit (0 disables)
+	WebSocketSoftLimit int
+	WebSocketHardLimit int
+
+	// API requests taking at least this long are logged at info level (0 logs all at debug)
+	SlowRequestThreshold time.Duration
+
+	// Basic auth credentials of /metrics, served when EnableMetrics is set (no username disables auth)
+	MetricsUsername string
+	MetricsPassword string
+
+	// Storage settings (media, downloads)
+	StorageDriver    string
+	StorageLocalPath string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3Prefix         string
+	S3ForcePathStyle bool
 }
 
 // Load loads configuration from environment variables
@@ -59,9 +149,27 @@ func Load() *Config {
 	return &Config{
 		// Server
 		Port: getEnv("PORT", "8080"),
+		ShutdownTimeout: getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		// Public URL
+		PublicBaseURL:     getEnv("PUBLIC_BASE_URL", ""),
+		TrustProxyHeaders: getBoolEnv("TRUST_PROXY_HEADERS", false),
+
+		// Outbound proxy
+		OutboundHTTPProxy:    getEnv("OUTBOUND_HTTP_PROXY", ""),
+		OutboundNoProxy:      getEnv("OUTBOUND_NO_PROXY", ""),
+		OutboundProxyTestURL: getEnv("OUTBOUND_PROXY_TEST_URL", "https://web.whatsapp.com"),
+		AllowPrivateWebhooks: getBoolEnv("ALLOW_PRIVATE_WEBHOOKS", false),
+
+		DefaultPhoneRegion: strings.ToUpper(getEnv("DEFAULT_PHONE_REGION", phone.FallbackRegion)),
+
+		ContactScoringHour: getIntEnv("CONTACT_SCORING_HOUR", 2),
+		BlockSuspectAfter:  getDurationEnv("BLOCK_SUSPECT_AFTER", 24*time.Hour),
 
 		// Database
 		WhatsAppDBPath: getEnv("WHATSAPP_DB_PATH", "./database/sessions.db"),
+		DatabaseType:   strings.ToLower(getEnv("DB_TYPE", "mysql")),
+		DatabasePath:   getEnv("DB_PATH", "./database/app.db"),
 		MySQLHost:      getEnv("MYSQL_HOST", "localhost"),
 		MySQLPort:      getEnv("MYSQL_PORT", "3306"),
 		MySQLUser:      getEnv("MYSQL_USER", "root"),
@@ -69,8 +177,9 @@ func Load() *Config {
 		MySQLDatabase:  getEnv("MYSQL_DATABASE", "waGo"),
 
 		// JWT
-		JWTSecret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
-		JWTExpiration: getDurationEnv("SESSION_TIMEOUT", 24*time.Hour),
+		JWTSecret:       getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
+		JWTExpiration:   getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
 
 		// Admin
 		AdminUsername: getEnv("ADMIN_USERNAME", "admin"),
@@ -80,14 +189,24 @@ func Load() *Config {
 		EnableLogging:     getBoolEnv("ENABLE_LOGGING", true),
 		EnableDatabaseLog: getBoolEnv("ENABLE_DATABASE_LOG", true),
 		EnableFrontend:    getBoolEnv("ENABLE_FRONTEND", true),
+		EnableMetrics:     getBoolEnv("ENABLE_METRICS", false),
 		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		LogFormat:         getEnv("LOG_FORMAT", logger.FormatText),
 		MaxSessions:       getIntEnv("MAX_SESSIONS", 10),
+		MaxMediaSizeMB:    getIntEnv("MAX_MEDIA_SIZE_MB", 64),
+		MaxDownloadSize:   getByteSizeEnv("MAX_DOWNLOAD_SIZE", 64<<20),
+		DownloadTimeout:   getDurationEnv("DOWNLOAD_TIMEOUT", 2*time.Minute),
 		SessionTimeout:    getDurationEnv("SESSION_TIMEOUT", 24*time.Hour),
 
 		// WhatsApp
 		AutoConnect: getBoolEnv("AUTO_CONNECT", true),
 		QRTimeout:   getDurationEnv("QR_TIMEOUT", 30*time.Second),
 
+		SandboxMode:         getBoolEnv("SANDBOX_MODE", false),
+		SandboxReceiptDelay: getDurationEnv("SANDBOX_RECEIPT_DELAY", time.Second),
+
+		FFmpegPath: getEnv("FFMPEG_PATH", "ffmpeg"),
+
 		// Security
 		CORSAllowedOrigins: getStringSliceEnv("CORS_ALLOWED_ORIGINS", []string{"*"}),
 		RateLimit:          getIntEnv("RATE_LIMIT", 100),
@@ -95,7 +214,78 @@ func Load() *Config {
 		// Webhook
 		WebhookTimeout:    getDurationEnv("WEBHOOK_TIMEOUT", 30*time.Second),
 		WebhookMaxRetries: getIntEnv("WEBHOOK_MAX_RETRIES", 3),
+		WebhookSuspendAfter: getDurationEnv("WEBHOOK_SUSPEND_AFTER", 6*time.Hour),
+		WebhookMaxAge:       getDurationEnv("WEBHOOK_MAX_AGE", 24*time.Hour),
+		WebhookDeliveryRetention: time.Duration(getIntEnv("WEBHOOK_DELIVERY_RETENTION_DAYS", 7)) * 24 * time.Hour,
+		OperatorNotifyURL:   getEnv("OPERATOR_NOTIFY_URL", ""),
+
+		ReconnectMaxAttempts: getIntEnv("RECONNECT_MAX_ATTEMPTS", 10),
+		ReconnectBaseDelay:   getDurationEnv("RECONNECT_BASE_DELAY", 2*time.Second),
+		ReconnectMaxDelay:    getDurationEnv("RECONNECT_MAX_DELAY", 5*time.Minute),
+
+		UploadCacheTTL: getDurationEnv("UPLOAD_CACHE_TTL", 6*time.Hour),
+		MediaRetention: time.Duration(getIntEnv("MEDIA_RETENTION_DAYS", 30)) * 24 * time.Hour,
+
+		SlowRequestThreshold: getDurationEnv("SLOW_REQUEST_THRESHOLD", time.Second),
+
+		MetricsUsername: getEnv("METRICS_USERNAME", ""),
+		MetricsPassword: getEnv("METRICS_PASSWORD", ""),
+
+		ExportRedactContent: getBoolEnv("EXPORT_REDACT_CONTENT", false),
+
+		WebSocketSoftLimit: getIntEnv("WS_SOFT_LIMIT_PER_USER", 10),
+		WebSocketHardLimit: getIntEnv("WS_HARD_LIMIT_PER_USER", 50),
+
+		// Storage
+		StorageDriver:    getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalPath: getEnv("STORAGE_LOCAL_PATH", "."),
+		S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+		S3Region:         getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:         getEnv("S3_BUCKET", ""),
+		S3AccessKey:      getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:      getEnv("S3_SECRET_KEY", ""),
+		S3Prefix:         getEnv("S3_PREFIX", ""),
+		S3ForcePathStyle: getBoolEnv("S3_FORCE_PATH_STYLE", false),
+	}
+}
+
+// Validate checks configuration values that cannot be fixed by falling back to defaults
+func (c *Config) Validate() error {
+	if c.PublicBaseURL != "" {
+		normalized, err := utils.NormalizeBaseURL(c.PublicBaseURL)
+		if err != nil {
+			return fmt.Errorf("invalid PUBLIC_BASE_URL %q: %v", c.PublicBaseURL, err)
+		}
+		c.PublicBaseURL = normalized
+	}
+
+	if c.OutboundHTTPProxy != "" {
+		if err := httpclient.ValidateProxyURL(c.OutboundHTTPProxy); err != nil {
+			return fmt.Errorf("invalid OUTBOUND_HTTP_PROXY: %v", err)
+		}
+	}
+
+	if !phone.IsSupportedRegion(c.DefaultPhoneRegion) {
+		return fmt.Errorf("unsupported DEFAULT_PHONE_REGION %q", c.DefaultPhoneRegion)
+	}
+
+	if c.ContactScoringHour < 0 || c.ContactScoringHour > 23 {
+		return fmt.Errorf("CONTACT_SCORING_HOUR must be between 0 and 23, got %d", c.ContactScoringHour)
 	}
+
+	if c.DatabaseType != "mysql" && c.DatabaseType != "sqlite" {
+		return fmt.Errorf("DB_TYPE must be mysql or sqlite, got %q", c.DatabaseType)
+	}
+
+	if c.LogFormat != logger.FormatText && c.LogFormat != logger.FormatJSON {
+		return fmt.Errorf("LOG_FORMAT must be text or json, got %q", c.LogFormat)
+	}
+
+	if c.UploadCacheTTL > 7*24*time.Hour {
+		return fmt.Errorf("UPLOAD_CACHE_TTL must not exceed 168h (WhatsApp media expiry), got %s", c.UploadCacheTTL)
+	}
+
+	return nil
 }
 
 // Helper functions
@@ -126,6 +316,29 @@ func getIntEnv(key string, fallback int) int {
 	return fallback
 }
 
+// getByteSizeEnv reads a size in bytes, optionally with a KB, MB or GB suffix
+func getByteSizeEnv(key string, fallback int64) int64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if value == "" {
+		return fallback
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return fallback
+	}
+	return size * multiplier
+}
+
 func getDurationEnv(key string, fallback time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		d, err := time.ParseDuration(value)
@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
 	"whatsapp-multi-session/internal/services"
 	"whatsapp-multi-session/pkg/logger"
 )
@@ -16,6 +17,8 @@ import (
 type AdminHandler struct {
 	userService *services.UserService
 	logger      *logger.Logger
+	audit       *services.AuditService
+	db          *repository.Database
 }
 
 // NewAdminHandler creates a new admin handler
@@ -29,6 +32,50 @@ func NewAdminHandler(
 	}
 }
 
+// SetAuditService records user changes in the audit log
+func (h *AdminHandler) SetAuditService(audit *services.AuditService) {
+	h.audit = audit
+}
+
+// SetDatabase enables the schema migration status endpoint
+func (h *AdminHandler) SetDatabase(db *repository.Database) {
+	h.db = db
+}
+
+// GetMigrations lists the schema migrations of this build and when each was applied
+func (h *AdminHandler) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		HandleError(w, models.NewServiceUnavailableError("migration status is not available"))
+		return
+	}
+
+	statuses, err := h.db.MigrationStatus()
+	if err != nil {
+		h.logger.Error("Failed to read migration status: %v", err)
+		HandleErrorWithMessage(w, http.StatusInternalServerError, "Failed to read migration status", models.ErrCodeInternalServer)
+		return
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	// Rollbacks cannot go past the newest applied migration without a down file
+	rollbackFloor := 0
+	if floor := repository.RollbackFloor(statuses); floor != nil {
+		rollbackFloor = floor.Version
+	}
+
+	WriteSuccessResponse(w, "Migration status retrieved", map[string]interface{}{
+		"dialect":        h.db.Dialect(),
+		"migrations":     statuses,
+		"pending":        pending,
+		"rollback_floor": rollbackFloor,
+	})
+}
+
 // GetUsers handles getting all users
 func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := h.userService.GetAllUsers()
@@ -104,9 +151,6 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if req.Role == "" {
 		req.Role = models.RoleUser
 	}
-	if req.SessionLimit == 0 {
-		req.SessionLimit = 5
-	}
 
 	// Validate role
 	if req.Role != models.RoleAdmin && req.Role != models.RoleUser {
@@ -114,6 +158,11 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.SessionLimit != nil && *req.SessionLimit < 0 {
+		http.Error(w, "Session limit must be 0 (unlimited) or more", http.StatusBadRequest)
+		return
+	}
+
 	user, err := h.userService.CreateUser(&req)
 	if err != nil {
 		h.logger.Error("Failed to create user: %v", err)
@@ -121,6 +170,12 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, models.AuditUserCreated, models.AuditTargetUser, strconv.Itoa(user.ID), map[string]interface{}{
+		"username":      user.Username,
+		"role":          user.Role,
+		"session_limit": user.SessionLimit,
+	})
+
 	// Remove password from response
 	user.Password = ""
 
@@ -163,6 +218,11 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.SessionLimit != nil && *req.SessionLimit < 0 {
+		http.Error(w, "Session limit must be 0 (unlimited) or more", http.StatusBadRequest)
+		return
+	}
+
 	user, err := h.userService.UpdateUser(userID, &req)
 	if err != nil {
 		h.logger.Error("Failed to update user %d: %v", userID, err)
@@ -170,6 +230,8 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, models.AuditUserUpdated, models.AuditTargetUser, vars["id"], userChanges(&req))
+
 	// Remove password from response
 	user.Password = ""
 
@@ -196,9 +258,36 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, models.AuditUserDeleted, models.AuditTargetUser, vars["id"], nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": "User deleted successfully",
 	})
-}
\ No newline at end of file
+}
+
+// userChanges lists the fields an update sets, for the audit log. A new
+// password is recorded as changed, never its value.
+func userChanges(req *models.UpdateUserRequest) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if req.Username != "" {
+		changes["username"] = req.Username
+	}
+	if req.Password != "" {
+		changes["password_changed"] = true
+	}
+	if req.Role != "" {
+		changes["role"] = req.Role
+	}
+	if req.SessionLimit != nil {
+		changes["session_limit"] = *req.SessionLimit
+	}
+	if req.IsActive != nil {
+		changes["is_active"] = *req.IsActive
+	}
+	if req.DefaultRegion != nil {
+		changes["default_region"] = *req.DefaultRegion
+	}
+	return changes
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// adminSessionStates are the connection states GET /api/admin/sessions filters by
+var adminSessionStates = map[string]bool{
+	models.ConnectionStatusConnected:      true,
+	models.ConnectionStatusConnecting:     true,
+	models.ConnectionStatusReconnecting:   true,
+	models.ConnectionStatusNeedsAttention: true,
+	models.ConnectionStatusDisconnected:   true,
+}
+
+// AdminGetSessions handles GET /api/admin/sessions
+func (h *SessionHandler) AdminGetSessions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID := 0
+	if raw := query.Get("user_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+	state := query.Get("state")
+	if state != "" && !adminSessionStates[state] {
+		HandleError(w, models.NewBadRequestError("state must be connected, connecting, reconnecting, needs_attention or disconnected"))
+		return
+	}
+
+	owners, err := h.whatsappService.GetSessionOwners(userID)
+	if err != nil {
+		h.logger.Error("Failed to list sessions: %v", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	region := phoneRegion(r)
+	responses := make([]*models.AdminSessionResponse, 0, len(owners))
+	for _, owner := range owners {
+		session, exists := h.whatsappService.GetSession(owner.SessionID)
+		if !exists {
+			continue
+		}
+		response := toSessionResponse(session, region)
+		if state != "" && response.ConnectionStatus != state {
+			continue
+		}
+		responses = append(responses, &models.AdminSessionResponse{
+			SessionResponse: response,
+			UserID:          owner.UserID,
+			OwnerUsername:   owner.Username,
+		})
+	}
+
+	WriteSuccessResponse(w, "Sessions retrieved successfully", responses)
+}
+
+// ReassignSessionOwner handles PUT /api/admin/sessions/{sessionId}/owner
+func (h *SessionHandler) ReassignSessionOwner(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	var req models.ReassignSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	session, owner, err := h.whatsappService.ReassignSession(sessionID, req.UserID)
+	if err != nil {
+		h.logger.Error("Failed to reassign session %s to user %d: %v", sessionID, req.UserID, err)
+		HandleError(w, err)
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditSessionReassigned, models.AuditTargetSession, sessionID, map[string]interface{}{
+		"user_id":  owner.ID,
+		"username": owner.Username,
+	})
+
+	WriteSuccessResponse(w, "Session reassigned successfully", &models.AdminSessionResponse{
+		SessionResponse: toSessionResponse(session, phoneRegion(r)),
+		UserID:          owner.ID,
+		OwnerUsername:   owner.Username,
+	})
+}
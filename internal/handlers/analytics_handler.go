@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	
 	"whatsapp-multi-session/internal/middleware"
 	"whatsapp-multi-session/internal/services"
@@ -54,13 +55,15 @@ func (h *AnalyticsHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 	isAdmin := userClaims.Role == "admin"
 	
 	// Get analytics data
-	analytics, err := h.analyticsService.GetAnalytics(int64(userClaims.UserID), isAdmin, timeRange)
+	analytics, err := h.analyticsService.GetAnalytics(int64(userClaims.UserID), isAdmin, timeRange, includeSandbox(r))
 	if err != nil {
 		h.log.Error("Failed to get analytics: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve analytics")
 		return
 	}
 	
+	formatTopContacts(analytics.TopContacts, phoneRegion(r))
+	
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"data":    analytics,
@@ -87,7 +90,7 @@ func (h *AnalyticsHandler) GetMessageStats(w http.ResponseWriter, r *http.Reques
 	isAdmin := userClaims.Role == "admin"
 	
 	// Get message stats
-	stats, err := h.analyticsService.GetMessageStats(int64(userClaims.UserID), isAdmin, timeRange)
+	stats, err := h.analyticsService.GetMessageStats(int64(userClaims.UserID), isAdmin, timeRange, includeSandbox(r))
 	if err != nil {
 		h.log.Error("Failed to get message stats: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve message statistics")
@@ -114,7 +117,7 @@ func (h *AnalyticsHandler) GetSessionStats(w http.ResponseWriter, r *http.Reques
 	isAdmin := userClaims.Role == "admin"
 	
 	// Get session stats
-	stats, err := h.analyticsService.GetSessionStats(int64(userClaims.UserID), isAdmin)
+	stats, err := h.analyticsService.GetSessionStats(int64(userClaims.UserID), isAdmin, includeSandbox(r))
 	if err != nil {
 		h.log.Error("Failed to get session stats: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve session statistics")
@@ -140,4 +143,11 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 		"success": false,
 		"error":   message,
 	})
-}
\ No newline at end of file
+}
+
+// includeSandbox reports whether ?include_sandbox=true asks for sandbox
+// sessions, which analytics leave out by default
+func includeSandbox(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_sandbox"))
+	return include
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"whatsapp-multi-session/internal/middleware"
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/services"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// Page sizes of the audit log
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// recordAudit records an action the caller of r performed on a target. A nil
+// audit service records nothing.
+func recordAudit(audit *services.AuditService, r *http.Request, action, targetType, targetID string, details map[string]interface{}) {
+	if audit == nil {
+		return
+	}
+
+	entry := &models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         getClientIP(r),
+		Details:    details,
+	}
+	if claims, ok := middleware.GetUserClaims(r); ok {
+		actorID := claims.UserID
+		entry.ActorUserID = &actorID
+		entry.ActorUsername = claims.Username
+	}
+	if info, ok := middleware.GetAuthInfo(r); ok {
+		entry.AuthMethod = info.Method
+		if info.Method == middleware.AuthMethodAPIKey {
+			if entry.Details == nil {
+				entry.Details = map[string]interface{}{}
+			}
+			entry.Details["api_key"] = info.KeyHint
+		}
+	}
+	audit.Record(entry)
+}
+
+// auditMessageSent records a message sent from a session
+func (h *SessionHandler) auditMessageSent(r *http.Request, sessionID, to, messageID, messageType string) {
+	recordAudit(h.audit, r, models.AuditMessageSent, models.AuditTargetSession, sessionID, map[string]interface{}{
+		"to":         to,
+		"message_id": messageID,
+		"type":       messageType,
+	})
+}
+
+// AuditHandler serves the audit log
+type AuditHandler struct {
+	audit  *services.AuditService
+	logger *logger.Logger
+}
+
+// NewAuditHandler creates a new audit log handler
+func NewAuditHandler(audit *services.AuditService, log *logger.Logger) *AuditHandler {
+	return &AuditHandler{audit: audit, logger: log}
+}
+
+// GetAuditLogs handles GET /api/admin/audit-logs, filtered by actor_id,
+// action, target_type, target_id and a from/to range, newest first
+func (h *AuditHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditLogFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	var err error
+	if value := query.Get("actor_id"); value != "" {
+		if filter.ActorUserID, err = strconv.Atoi(value); err != nil || filter.ActorUserID <= 0 {
+			HandleError(w, models.NewBadRequestError("actor_id must be a user ID"))
+			return
+		}
+	}
+	if filter.From, err = parseExportTime(query.Get("from"), false); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid from: %v", err))
+		return
+	}
+	if filter.To, err = parseExportTime(query.Get("to"), true); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid to: %v", err))
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		HandleError(w, models.NewBadRequestError("from must be before to"))
+		return
+	}
+
+	page := 1
+	if value := query.Get("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page <= 0 {
+			HandleError(w, models.NewBadRequestError("page must be 1 or more"))
+			return
+		}
+	}
+	pageSize := defaultAuditPageSize
+	if value := query.Get("page_size"); value != "" {
+		if pageSize, err = strconv.Atoi(value); err != nil || pageSize <= 0 || pageSize > maxAuditPageSize {
+			HandleError(w, models.NewBadRequestError("page_size must be between 1 and %d", maxAuditPageSize))
+			return
+		}
+	}
+
+	result, err := h.audit.List(filter, page, pageSize)
+	if err != nil {
+		h.logger.Error("Failed to get audit logs: %v", err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Audit logs retrieved successfully", result)
+}
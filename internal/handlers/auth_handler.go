@@ -18,6 +18,7 @@ type AuthHandler struct {
 	userService *services.UserService
 	rateLimiter *ratelimiter.LoginRateLimiter
 	logger      *logger.Logger
+	audit       *services.AuditService
 }
 
 // NewAuthHandler creates a new auth handler
@@ -33,6 +34,11 @@ func NewAuthHandler(
 	}
 }
 
+// SetAuditService records account and API key changes in the audit log
+func (h *AuthHandler) SetAuditService(audit *services.AuditService) {
+	h.audit = audit
+}
+
 // Login handles user login
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Get client IP
@@ -111,6 +117,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info("User %s registered successfully", req.Username)
+	recordAudit(h.audit, r, models.AuditUserCreated, models.AuditTargetUser, strconv.Itoa(response.User.ID), map[string]interface{}{
+		"username": response.User.Username,
+		"role":     response.User.Role,
+	})
 
 	// Return response
 	WriteSuccessResponse(w, "Registration successful", response)
@@ -143,15 +153,56 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Change password
-	if err := h.userService.ChangePassword(claims.UserID, &req); err != nil {
+	// Change password; other tokens of the user are revoked and new ones returned
+	response, err := h.userService.ChangePassword(claims.UserID, &req)
+	if err != nil {
 		h.logger.Warn("Failed password change for user %d: %v", claims.UserID, err)
 		HandleErrorWithMessage(w, http.StatusBadRequest, err.Error(), models.ErrCodeInvalidInput)
 		return
 	}
 
 	h.logger.Info("Password changed successfully for user %d", claims.UserID)
-	WriteSuccessResponse(w, "Password changed successfully", nil)
+	recordAudit(h.audit, r, models.AuditPasswordChanged, models.AuditTargetUser, strconv.Itoa(claims.UserID), nil)
+	WriteSuccessResponse(w, "Password changed successfully", response)
+}
+
+// Refresh exchanges a refresh token for new access and refresh tokens
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		HandleErrorWithMessage(w, http.StatusBadRequest, "refresh_token is required", models.ErrCodeInvalidInput)
+		return
+	}
+
+	response, err := h.userService.Refresh(req.RefreshToken)
+	if err != nil {
+		if _, ok := err.(models.UnauthorizedError); ok {
+			h.logger.Warn("Refused token refresh from %s: %v", getClientIP(r), err)
+		} else {
+			h.logger.Error("Failed to refresh token: %v", err)
+		}
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Token refreshed successfully", response)
+}
+
+// Logout revokes a refresh token, or every token of its user with "all"
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		HandleErrorWithMessage(w, http.StatusBadRequest, "refresh_token is required", models.ErrCodeInvalidInput)
+		return
+	}
+
+	if err := h.userService.Logout(&req); err != nil {
+		h.logger.Error("Failed to log out: %v", err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Logged out successfully", nil)
 }
 
 // GenerateAPIKey generates a new API key for the authenticated user
@@ -172,6 +223,9 @@ func (h *AuthHandler) GenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info("Generated API key for user %d", claims.UserID)
+	recordAudit(h.audit, r, models.AuditAPIKeyCreated, models.AuditTargetUser, strconv.Itoa(claims.UserID), map[string]interface{}{
+		"legacy": true,
+	})
 	WriteSuccessResponse(w, "API key generated successfully", response)
 }
 
@@ -192,6 +246,9 @@ func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info("Revoked API key for user %d", claims.UserID)
+	recordAudit(h.audit, r, models.AuditAPIKeyRevoked, models.AuditTargetUser, strconv.Itoa(claims.UserID), map[string]interface{}{
+		"all": true,
+	})
 	WriteSuccessResponse(w, "API key revoked successfully", nil)
 }
 
@@ -215,6 +272,83 @@ func (h *AuthHandler) GetAPIKeyInfo(w http.ResponseWriter, r *http.Request) {
 	WriteSuccessResponse(w, "API key info retrieved successfully", info)
 }
 
+// ListAPIKeys lists the named API keys of the authenticated user
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserClaims(r)
+	if !ok {
+		HandleErrorWithMessage(w, http.StatusUnauthorized, "Unauthorized", models.ErrCodeUnauthorized)
+		return
+	}
+
+	keys, err := h.userService.ListAPIKeys(claims.UserID)
+	if err != nil {
+		h.logger.Error("Failed to list API keys of user %d: %v", claims.UserID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "API keys retrieved successfully", keys)
+}
+
+// CreateAPIKey creates a named API key for the authenticated user. The key
+// is only ever returned in this response.
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserClaims(r)
+	if !ok {
+		HandleErrorWithMessage(w, http.StatusUnauthorized, "Unauthorized", models.ErrCodeUnauthorized)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		HandleErrorWithMessage(w, http.StatusBadRequest, "Invalid request body", models.ErrCodeInvalidInput)
+		return
+	}
+
+	created, err := h.userService.CreateAPIKey(claims.UserID, &req)
+	if err != nil {
+		if _, ok := err.(models.BadRequestError); !ok {
+			h.logger.Error("Failed to create API key for user %d: %v", claims.UserID, err)
+		}
+		HandleError(w, err)
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditAPIKeyCreated, models.AuditTargetAPIKey, strconv.FormatInt(created.ID, 10), map[string]interface{}{
+		"name":       created.Name,
+		"hint":       created.Hint,
+		"scopes":     created.Scopes,
+		"sessions":   created.Sessions,
+		"rate_limit": created.RateLimit,
+	})
+
+	WriteSuccessResponse(w, "API key created; store it now, it will not be shown again", created)
+}
+
+// DeleteAPIKey revokes one named API key of the authenticated user
+func (h *AuthHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserClaims(r)
+	if !ok {
+		HandleErrorWithMessage(w, http.StatusUnauthorized, "Unauthorized", models.ErrCodeUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		HandleErrorWithMessage(w, http.StatusBadRequest, "Invalid API key ID", models.ErrCodeInvalidInput)
+		return
+	}
+
+	if err := h.userService.DeleteAPIKey(claims.UserID, keyID); err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditAPIKeyRevoked, models.AuditTargetAPIKey, strconv.FormatInt(keyID, 10), nil)
+
+	WriteSuccessResponse(w, "API key revoked successfully", nil)
+}
+
 // AdminGenerateAPIKey generates API key for any user (admin only)
 func (h *AuthHandler) AdminGenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from URL params
@@ -235,6 +369,9 @@ func (h *AuthHandler) AdminGenerateAPIKey(w http.ResponseWriter, r *http.Request
 	}
 
 	h.logger.Info("Admin generated API key for user %d", userID)
+	recordAudit(h.audit, r, models.AuditAPIKeyCreated, models.AuditTargetUser, userIDStr, map[string]interface{}{
+		"legacy": true,
+	})
 	WriteSuccessResponse(w, "API key generated successfully", response)
 }
 
@@ -257,6 +394,9 @@ func (h *AuthHandler) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request)
 	}
 
 	h.logger.Info("Admin revoked API key for user %d", userID)
+	recordAudit(h.audit, r, models.AuditAPIKeyRevoked, models.AuditTargetUser, userIDStr, map[string]interface{}{
+		"all": true,
+	})
 	WriteSuccessResponse(w, "API key revoked successfully", nil)
 }
 
@@ -2,30 +2,94 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"whatsapp-multi-session/internal/models"
 	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/services"
 	"whatsapp-multi-session/pkg/logger"
 )
 
 type AutoReplyHandler struct {
-	autoReplyRepo *repository.AutoReplyRepository
-	logger        *logger.Logger
+	autoReplyRepo    *repository.AutoReplyRepository
+	autoReplyService *services.AutoReplyService
+	whatsappService  *services.WhatsAppService
+	logger           *logger.Logger
 }
 
 func NewAutoReplyHandler(
 	autoReplyRepo *repository.AutoReplyRepository,
+	autoReplyService *services.AutoReplyService,
+	whatsappService *services.WhatsAppService,
 	logger *logger.Logger,
 ) *AutoReplyHandler {
 	return &AutoReplyHandler{
-		autoReplyRepo: autoReplyRepo,
-		logger:        logger,
+		autoReplyRepo:    autoReplyRepo,
+		autoReplyService: autoReplyService,
+		whatsappService:  whatsappService,
+		logger:           logger,
 	}
 }
 
+// checkSessionAccess verifies that the requesting user may manage the
+// auto-replies of a session: admins may manage every session's, users those
+// of their own sessions
+func (h *AutoReplyHandler) checkSessionAccess(r *http.Request, sessionID string) error {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		return models.NewUnauthorizedError("user authentication required")
+	}
+	if role, _ := r.Context().Value("role").(string); role == models.RoleAdmin {
+		return nil
+	}
+	
+	owned, err := h.whatsappService.IsSessionOwnedByUser(sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return models.NewForbiddenError("access denied: session not owned by user")
+	}
+	return nil
+}
+
+// ruleForRequest returns the auto-reply rule of the request's {id}, if the
+// requesting user may manage it
+func (h *AutoReplyHandler) ruleForRequest(r *http.Request) (*models.AutoReply, error) {
+	autoReplyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid auto reply ID")
+	}
+	
+	autoReply, err := h.autoReplyRepo.GetAutoReply(autoReplyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkSessionAccess(r, autoReply.SessionID); err != nil {
+		return nil, err
+	}
+	return autoReply, nil
+}
+
+// handleAccessError writes the response to a failed access check or rule
+// lookup, reporting whether there was an error
+func (h *AutoReplyHandler) handleAccessError(w http.ResponseWriter, err error) bool {
+	switch err.(type) {
+	case nil:
+		return false
+	case models.NotFoundError, models.BadRequestError, models.UnauthorizedError, models.ForbiddenError:
+		HandleError(w, err)
+	default:
+		h.logger.Error("Failed to check auto reply access: %v", err)
+		http.Error(w, "Failed to check auto reply access", http.StatusInternalServerError)
+	}
+	return true
+}
+
 // GetAutoReplies handles GET /api/auto-replies
 func (h *AutoReplyHandler) GetAutoReplies(w http.ResponseWriter, r *http.Request) {
 	// Get session ID from query parameter
@@ -35,6 +99,10 @@ func (h *AutoReplyHandler) GetAutoReplies(w http.ResponseWriter, r *http.Request
 		return
 	}
 	
+	if h.handleAccessError(w, h.checkSessionAccess(r, sessionID)) {
+		return
+	}
+	
 	autoReplies, err := h.autoReplyRepo.GetAutoRepliesBySession(sessionID)
 	if err != nil {
 		h.logger.Error("Failed to get auto replies: %v", err)
@@ -54,6 +122,25 @@ func (h *AutoReplyHandler) CreateAutoReply(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
+	if err := autoReply.SendOptions.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if err := models.ValidateTimeWindow(autoReply.TimeStart, autoReply.TimeEnd, autoReply.TimeZone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if err := models.ValidateAutoReplyContent(autoReply.Trigger, autoReply.Keywords, autoReply.MediaType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if h.handleAccessError(w, h.checkSessionAccess(r, autoReply.SessionID)) {
+		return
+	}
+	
 	if err := h.autoReplyRepo.CreateAutoReply(&autoReply); err != nil {
 		h.logger.Error("Failed to create auto reply: %v", err)
 		http.Error(w, "Failed to create auto reply", http.StatusInternalServerError)
@@ -67,12 +154,11 @@ func (h *AutoReplyHandler) CreateAutoReply(w http.ResponseWriter, r *http.Reques
 
 // UpdateAutoReply handles PUT /api/auto-replies/{id}
 func (h *AutoReplyHandler) UpdateAutoReply(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	autoReplyID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid auto reply ID", http.StatusBadRequest)
+	current, err := h.ruleForRequest(r)
+	if h.handleAccessError(w, err) {
 		return
 	}
+	autoReplyID := current.ID
 	
 	var updateReq models.UpdateAutoReplyRequest
 	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
@@ -80,7 +166,40 @@ func (h *AutoReplyHandler) UpdateAutoReply(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
+	if err := updateReq.SendOptions.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	timeZone := ""
+	if updateReq.TimeZone != nil {
+		timeZone = *updateReq.TimeZone
+	}
+	if err := models.ValidateTimeWindow(updateReq.TimeStart, updateReq.TimeEnd, timeZone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if err := validateUpdatedContent(current, updateReq); err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if updateReq.ExpectedVersion, err = expectedVersion(r, updateReq.ExpectedVersion); err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	if err := h.autoReplyRepo.UpdateAutoReply(autoReplyID, updateReq); err != nil {
+		switch err.(type) {
+		case models.NotFoundError, models.BadRequestError:
+			HandleError(w, err)
+			return
+		}
+		if err == repository.ErrVersionConflict {
+			h.writeAutoReplyConflict(w, autoReplyID, *updateReq.ExpectedVersion)
+			return
+		}
 		h.logger.Error("Failed to update auto reply: %v", err)
 		http.Error(w, "Failed to update auto reply", http.StatusInternalServerError)
 		return
@@ -94,24 +213,202 @@ func (h *AutoReplyHandler) UpdateAutoReply(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
+	setVersionHeader(w, autoReply.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(autoReply)
 }
 
+// writeAutoReplyConflict answers an auto-reply update based on an outdated
+// version with 409 and the rule's current state
+func (h *AutoReplyHandler) writeAutoReplyConflict(w http.ResponseWriter, autoReplyID int, expected int64) {
+	autoReply, err := h.autoReplyRepo.GetAutoReply(autoReplyID)
+	if err != nil {
+		h.logger.Error("Failed to get auto reply after version conflict: %v", err)
+		http.Error(w, "Failed to update auto reply", http.StatusInternalServerError)
+		return
+	}
+	
+	HandleError(w, models.ConflictError{
+		Message:        fmt.Sprintf("auto-reply %d was modified since version %d; current version is %d", autoReplyID, expected, autoReply.Version),
+		CurrentVersion: autoReply.Version,
+		Current:        autoReply,
+	})
+}
+
+// validateUpdatedContent checks the trigger, patterns and media type a rule
+// will have after an update, since an update may change only some of them
+func validateUpdatedContent(current *models.AutoReply, updateReq models.UpdateAutoReplyRequest) error {
+	trigger, keywords := current.Trigger, current.Keywords
+	if updateReq.Trigger != "" {
+		trigger = updateReq.Trigger
+	}
+	if updateReq.Keywords != nil {
+		keywords = updateReq.Keywords
+	}
+	if err := models.ValidateAutoReplyContent(trigger, keywords, updateReq.MediaType); err != nil {
+		return models.NewBadRequestError("%v", err)
+	}
+	return nil
+}
+
 // DeleteAutoReply handles DELETE /api/auto-replies/{id}
 func (h *AutoReplyHandler) DeleteAutoReply(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	autoReplyID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid auto reply ID", http.StatusBadRequest)
+	autoReply, err := h.ruleForRequest(r)
+	if h.handleAccessError(w, err) {
 		return
 	}
 	
-	if err := h.autoReplyRepo.DeleteAutoReply(autoReplyID); err != nil {
+	if err := h.autoReplyRepo.DeleteAutoReply(autoReply.ID); err != nil {
+		if _, ok := err.(models.NotFoundError); ok {
+			HandleError(w, err)
+			return
+		}
 		h.logger.Error("Failed to delete auto reply: %v", err)
 		http.Error(w, "Failed to delete auto reply", http.StatusInternalServerError)
 		return
 	}
 	
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
+// maxAutoReplyLogLimit is the largest page of auto-reply logs
+const maxAutoReplyLogLimit = 100
+
+// GetAutoReplyLogs handles GET /api/auto-replies/{id}/logs. from and to
+// accept RFC3339 timestamps or dates; a date in to includes the whole day.
+func (h *AutoReplyHandler) GetAutoReplyLogs(w http.ResponseWriter, r *http.Request) {
+	autoReply, err := h.ruleForRequest(r)
+	if h.handleAccessError(w, err) {
+		return
+	}
+	
+	query := r.URL.Query()
+	page := 1
+	if value := query.Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			HandleError(w, models.NewBadRequestError("page must be a positive number"))
+			return
+		}
+	}
+	limit := 20
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAutoReplyLogLimit {
+			HandleError(w, models.NewBadRequestError("limit must be between 1 and %d", maxAutoReplyLogLimit))
+			return
+		}
+	}
+	
+	var from, to *time.Time
+	if t, err := parseExportTime(query.Get("from"), false); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid from: %v", err))
+		return
+	} else if !t.IsZero() {
+		from = &t
+	}
+	if t, err := parseExportTime(query.Get("to"), true); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid to: %v", err))
+		return
+	} else if !t.IsZero() {
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		HandleError(w, models.NewBadRequestError("from must be before to"))
+		return
+	}
+	
+	logs, total, err := h.autoReplyRepo.GetAutoReplyLogsPage(autoReply.ID, from, to, (page-1)*limit, limit)
+	if err != nil {
+		h.logger.Error("Failed to get logs of auto reply %d: %v", autoReply.ID, err)
+		http.Error(w, "Failed to get auto reply logs", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AutoReplyLogList{
+		AutoReplyID: autoReply.ID,
+		Logs:        logs,
+		Total:       total,
+		Page:        page,
+		Limit:       limit,
+		Pages:       (total + limit - 1) / limit,
+	})
+}
+
+// GetSessionAutoReplyStats handles GET /api/sessions/{sessionId}/auto-replies/stats
+func (h *AutoReplyHandler) GetSessionAutoReplyStats(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+	if h.handleAccessError(w, h.checkSessionAccess(r, sessionID)) {
+		return
+	}
+	
+	stats, err := h.autoReplyRepo.GetAutoReplyStatsBySession(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get auto reply stats for session %s: %v", sessionID, err)
+		http.Error(w, "Failed to get auto reply stats", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// TestAutoReply handles POST /api/auto-replies/{id}/test. Nothing is sent.
+func (h *AutoReplyHandler) TestAutoReply(w http.ResponseWriter, r *http.Request) {
+	autoReply, err := h.ruleForRequest(r)
+	if h.handleAccessError(w, err) {
+		return
+	}
+	
+	var testReq models.AutoReplyTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&testReq); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if testReq.TestMessage == "" {
+		http.Error(w, "test_message is required", http.StatusBadRequest)
+		return
+	}
+	testReq.AutoReplyID = autoReply.ID
+	
+	response, err := h.autoReplyService.TestAutoReply(testReq)
+	if err != nil {
+		h.logger.Error("Failed to test auto reply %d: %v", autoReply.ID, err)
+		http.Error(w, "Failed to test auto reply", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateAutoReplyFromTemplate handles POST /api/auto-replies/templates/{type}
+func (h *AutoReplyHandler) CreateAutoReplyFromTemplate(w http.ResponseWriter, r *http.Request) {
+	var templateReq models.AutoReplyTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&templateReq); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if templateReq.SessionID == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+	if h.handleAccessError(w, h.checkSessionAccess(r, templateReq.SessionID)) {
+		return
+	}
+	
+	autoReply, err := h.autoReplyService.CreateAutoReplyFromTemplate(templateReq.SessionID, mux.Vars(r)["type"])
+	if err != nil {
+		if _, ok := err.(models.BadRequestError); ok {
+			HandleError(w, err)
+			return
+		}
+		h.logger.Error("Failed to create auto reply from template: %v", err)
+		http.Error(w, "Failed to create auto reply", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(autoReply)
+}
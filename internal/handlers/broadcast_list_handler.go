@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetBroadcastLists handles GET /api/sessions/{sessionId}/broadcast-lists
+func (h *SessionHandler) GetBroadcastLists(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	refresh := r.URL.Query().Get("refresh") == "true"
+	lists, err := h.whatsappService.GetBroadcastLists(sessionID, refresh)
+	if err != nil {
+		h.logger.Error("Failed to get broadcast lists for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Broadcast lists retrieved successfully", map[string]interface{}{
+		"broadcast_lists": lists,
+		"count":           len(lists),
+	})
+}
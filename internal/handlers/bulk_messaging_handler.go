@@ -2,29 +2,44 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
 	"whatsapp-multi-session/internal/services"
 	"whatsapp-multi-session/pkg/logger"
 )
 
 type BulkMessagingHandler struct {
 	bulkService *services.BulkMessagingService
+	contactRepo *repository.ContactRepository
+	userRepo    *repository.UserRepository
 	logger      *logger.Logger
+	audit       *services.AuditService
 }
 
 func NewBulkMessagingHandler(
 	bulkService *services.BulkMessagingService,
+	contactRepo *repository.ContactRepository,
+	userRepo *repository.UserRepository,
 	logger *logger.Logger,
 ) *BulkMessagingHandler {
 	return &BulkMessagingHandler{
 		bulkService: bulkService,
+		contactRepo: contactRepo,
+		userRepo:    userRepo,
 		logger:      logger,
 	}
 }
 
+// SetAuditService records started bulk jobs in the audit log
+func (h *BulkMessagingHandler) SetAuditService(audit *services.AuditService) {
+	h.audit = audit
+}
+
 // StartBulkMessaging handles POST /api/bulk-messages
 func (h *BulkMessagingHandler) StartBulkMessaging(w http.ResponseWriter, r *http.Request) {
 	var bulkReq models.BulkMessageRequest
@@ -33,30 +48,172 @@ func (h *BulkMessagingHandler) StartBulkMessaging(w http.ResponseWriter, r *http
 		return
 	}
 	
-	// For now, we'll use the direct bulk message service
-	// TODO: Get template and contacts from database based on request
+	if bulkReq.Segment != "" && !models.IsValidSegment(bulkReq.Segment) {
+		http.Error(w, "Invalid segment, must be one of: engaged, dormant, never_replied", http.StatusBadRequest)
+		return
+	}
+	
+	if err := bulkReq.SendOptions.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	hasSelection := len(bulkReq.ContactIDs) > 0 || bulkReq.GroupID != nil || bulkReq.Segment != ""
+	switch {
+	case bulkReq.TemplateID != 0 && bulkReq.Message != "":
+		HandleError(w, models.NewBadRequestError("template_id and message cannot be combined"))
+		return
+	case len(bulkReq.Recipients) > 0 && hasSelection:
+		HandleError(w, models.NewBadRequestError("recipients cannot be combined with contact_ids, group_id or segment"))
+		return
+	}
+	
+	// TODO: Get template from database once templates are re-enabled
 	var template *models.MessageTemplate
+	if bulkReq.TemplateID != 0 {
+		HandleError(w, models.NewServiceUnavailableError("message templates are not available, send message instead"))
+		return
+	}
+	
 	var contacts []models.Contact
+	var invalid []models.InvalidRecipient
+	if len(bulkReq.Recipients) > 0 {
+		contacts, invalid = services.BulkRecipientContacts(bulkReq.Recipients, phoneRegion(r))
+		if len(invalid) > 0 && !bulkReq.SkipInvalid {
+			writeInvalidRecipients(w, invalid)
+			return
+		}
+	} else {
+		var err error
+		contacts, err = h.resolveContacts(r, bulkReq)
+		switch err.(type) {
+		case models.NotFoundError, models.ForbiddenError, models.BadRequestError, models.UnauthorizedError:
+			HandleError(w, err)
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to resolve bulk messaging contacts: %v", err)
+			http.Error(w, "Failed to resolve contacts", http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	// Jobs send from sessions of the same user whose contacts they message
+	owner, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if bulkReq.CheckNumbers {
+		contacts, invalid, err = h.bulkService.LeaveOutUnregistered(bulkReq, contacts, invalid, phoneRegion(r), owner)
+		if err != nil {
+			h.logger.Error("Failed to check bulk messaging recipients: %v", err)
+			HandleError(w, err)
+			return
+		}
+	}
 	
 	// Start bulk messaging
-	job, err := h.bulkService.StartBulkMessage(bulkReq, template, contacts)
+	job, err := h.bulkService.StartBulkMessage(bulkReq, template, contacts, invalid, owner)
+	switch err.(type) {
+	case models.BadRequestError, models.ForbiddenError:
+		HandleError(w, err)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to start bulk messaging: %v", err)
 		http.Error(w, "Failed to start bulk messaging", http.StatusInternalServerError)
 		return
 	}
+
+	recordAudit(h.audit, r, models.AuditBulkJobStarted, models.AuditTargetBulkJob, job.ID, map[string]interface{}{
+		"session_id":  job.SessionID,
+		"session_ids": job.SessionIDs,
+		"recipients":  len(job.Contacts),
+	})
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(job)
 }
 
+// writeInvalidRecipients rejects a bulk request whose inline recipients
+// include numbers that cannot be messaged, listing them
+func writeInvalidRecipients(w http.ResponseWriter, invalid []models.InvalidRecipient) {
+	response := models.ErrorResponse(
+		fmt.Sprintf("%d recipient(s) have an invalid phone number; fix them or set skip_invalid", len(invalid)),
+		models.ErrCodeBadRequest,
+	)
+	response.Data = map[string]interface{}{"invalid_recipients": invalid}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response)
+}
+
+// resolveContacts returns the contacts targeted by a bulk request. A segment is
+// combined with group_id and contact_ids so that only contacts matching all of them are used.
+// Only the caller's contacts are used, and contact_ids must all be the caller's.
+func (h *BulkMessagingHandler) resolveContacts(r *http.Request, req models.BulkMessageRequest) ([]models.Contact, error) {
+	scope, err := contactScope(r)
+	if err != nil {
+		return nil, err
+	}
+	if scope != 0 {
+		if err := checkContactOwner(h.contactRepo, req.ContactIDs, scope); err != nil {
+			return nil, err
+		}
+	}
+	
+	if req.Segment == "" {
+		if len(req.ContactIDs) > 0 {
+			return h.contactRepo.GetContactsByIDs(req.ContactIDs, scope)
+		}
+		if req.GroupID != nil {
+			return h.contactRepo.GetContactsByGroupID(*req.GroupID, scope)
+		}
+		return nil, nil
+	}
+	
+	thresholds, err := segmentThresholds(h.userRepo, r)
+	if err != nil {
+		return nil, err
+	}
+	
+	contacts, err := h.contactRepo.GetContactsBySegment(req.Segment, thresholds, req.GroupID, scope)
+	if err != nil || len(req.ContactIDs) == 0 {
+		return contacts, err
+	}
+	
+	wanted := make(map[int]bool, len(req.ContactIDs))
+	for _, id := range req.ContactIDs {
+		wanted[id] = true
+	}
+	filtered := contacts[:0]
+	for _, contact := range contacts {
+		if wanted[contact.ID] {
+			filtered = append(filtered, contact)
+		}
+	}
+	return filtered, nil
+}
+
 // GetBulkMessagingJob handles GET /api/bulk-messages/{jobId}
 func (h *BulkMessagingHandler) GetBulkMessagingJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobID := vars["jobId"]
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
 	
-	job, err := h.bulkService.GetJob(jobID)
+	job, err := h.bulkService.GetJob(jobID, scope)
+	if _, ok := err.(models.NotFoundError); ok {
+		HandleError(w, err)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to get bulk messaging job: %v", err)
 		http.Error(w, "Failed to get bulk messaging job", http.StatusInternalServerError)
@@ -74,7 +231,18 @@ func (h *BulkMessagingHandler) GetBulkMessagingJob(w http.ResponseWriter, r *htt
 
 // GetBulkMessagingJobs handles GET /api/bulk-messages
 func (h *BulkMessagingHandler) GetBulkMessagingJobs(w http.ResponseWriter, r *http.Request) {
-	jobs := h.bulkService.GetJobs()
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	jobs, err := h.bulkService.GetJobs(scope)
+	if err != nil {
+		h.logger.Error("Failed to get bulk messaging jobs: %v", err)
+		http.Error(w, "Failed to get bulk messaging jobs", http.StatusInternalServerError)
+		return
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jobs)
@@ -84,12 +252,123 @@ func (h *BulkMessagingHandler) GetBulkMessagingJobs(w http.ResponseWriter, r *ht
 func (h *BulkMessagingHandler) CancelBulkMessagingJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobID := vars["jobId"]
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
 	
-	if err := h.bulkService.CancelJob(jobID); err != nil {
+	if err := h.bulkService.CancelJob(jobID, scope); err != nil {
+		switch err.(type) {
+		case models.NotFoundError, models.BadRequestError:
+			HandleError(w, err)
+			return
+		}
 		h.logger.Error("Failed to cancel bulk messaging job: %v", err)
 		http.Error(w, "Failed to cancel bulk messaging job", http.StatusInternalServerError)
 		return
 	}
 	
 	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBulkMessagingJobResults handles GET /api/bulk-messages/{jobId}/results
+func (h *BulkMessagingHandler) GetBulkMessagingJobResults(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	page := 1
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	
+	results, err := h.bulkService.GetJobResults(jobID, r.URL.Query().Get("status"), page, limit, scope)
+	switch err.(type) {
+	case models.NotFoundError, models.BadRequestError, models.ServiceUnavailableError:
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get results of bulk messaging job %s: %v", jobID, err)
+		http.Error(w, "Failed to get bulk messaging job results", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// RetryFailedBulkMessagingJob handles POST /api/bulk-messages/{jobId}/retry-failed
+func (h *BulkMessagingHandler) RetryFailedBulkMessagingJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	job, err := h.bulkService.RetryFailedJob(jobID, scope)
+	switch err.(type) {
+	case models.NotFoundError, models.BadRequestError, models.ServiceUnavailableError:
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to retry bulk messaging job %s: %v", jobID, err)
+		http.Error(w, "Failed to retry bulk messaging job", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// PauseBulkMessagingJob handles POST /api/bulk-messages/{jobId}/pause
+func (h *BulkMessagingHandler) PauseBulkMessagingJob(w http.ResponseWriter, r *http.Request) {
+	h.changeJobState(w, r, h.bulkService.PauseJob, "pause")
+}
+
+// ResumeBulkMessagingJob handles POST /api/bulk-messages/{jobId}/resume
+func (h *BulkMessagingHandler) ResumeBulkMessagingJob(w http.ResponseWriter, r *http.Request) {
+	h.changeJobState(w, r, h.bulkService.ResumeJob, "resume")
+}
+
+// changeJobState applies a pause or resume to a job of the caller, or any
+// job for admins, and answers with the job
+func (h *BulkMessagingHandler) changeJobState(w http.ResponseWriter, r *http.Request, change func(string, int) error, action string) {
+	jobID := mux.Vars(r)["jobId"]
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if err := change(jobID, scope); err != nil {
+		switch err.(type) {
+		case models.NotFoundError, models.BadRequestError:
+			HandleError(w, err)
+			return
+		}
+		h.logger.Error("Failed to %s bulk messaging job %s: %v", action, jobID, err)
+		http.Error(w, fmt.Sprintf("Failed to %s bulk messaging job", action), http.StatusInternalServerError)
+		return
+	}
+	
+	job, err := h.bulkService.GetJob(jobID, scope)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
 }
\ No newline at end of file
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/services"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+	campaignRepo    *repository.CampaignRepository
+	contactRepo     *repository.ContactRepository
+	groupRepo       *repository.ContactGroupRepository
+	logger          *logger.Logger
+}
+
+func NewCampaignHandler(
+	campaignService *services.CampaignService,
+	campaignRepo *repository.CampaignRepository,
+	contactRepo *repository.ContactRepository,
+	groupRepo *repository.ContactGroupRepository,
+	logger *logger.Logger,
+) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		campaignRepo:    campaignRepo,
+		contactRepo:     contactRepo,
+		groupRepo:       groupRepo,
+		logger:          logger,
+	}
+}
+
+// decodeCampaignRequest decodes a campaign request, explaining malformed times
+func decodeCampaignRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err == nil {
+		return true
+	}
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		HandleError(w, models.NewBadRequestError("scheduled_at must be an RFC 3339 time such as 2024-06-01T09:00:00+07:00"))
+		return false
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+	return false
+}
+
+// CreateCampaign handles POST /api/campaigns
+func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	owner, err := contactOwner(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	var req models.CreateCampaignRequest
+	if !decodeCampaignRequest(w, r, &req) {
+		return
+	}
+	if req.TemplateID != 0 {
+		HandleError(w, models.NewServiceUnavailableError("message templates are not available, send message instead"))
+		return
+	}
+
+	// Campaigns message the contacts of their owner only
+	if err := checkGroupOwner(h.groupRepo, req.GroupID, owner); err != nil {
+		HandleError(w, err)
+		return
+	}
+	if err := checkContactOwner(h.contactRepo, req.ContactIDs, owner); err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(req, owner)
+	if err != nil {
+		switch err.(type) {
+		case models.BadRequestError, models.ForbiddenError:
+			HandleError(w, err)
+			return
+		}
+		h.logger.Error("Failed to create campaign: %v", err)
+		http.Error(w, "Failed to create campaign", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(campaign)
+}
+
+// GetCampaigns handles GET /api/campaigns
+func (h *CampaignHandler) GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	campaigns, err := h.campaignRepo.GetCampaigns(scope)
+	if err != nil {
+		h.logger.Error("Failed to get campaigns: %v", err)
+		http.Error(w, "Failed to get campaigns", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaigns)
+}
+
+// GetCampaign handles GET /api/campaigns/{id}
+func (h *CampaignHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	campaignID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid campaign ID", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	campaign, err := h.campaignRepo.GetCampaign(campaignID, scope)
+	if err != nil {
+		if _, ok := err.(models.NotFoundError); ok {
+			HandleError(w, err)
+			return
+		}
+		h.logger.Error("Failed to get campaign: %v", err)
+		http.Error(w, "Failed to get campaign", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaign)
+}
+
+// UpdateCampaign handles PUT /api/campaigns/{id}, rescheduling, editing or
+// cancelling a campaign before it starts
+func (h *CampaignHandler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	campaignID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid campaign ID", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	var req models.UpdateCampaignRequest
+	if !decodeCampaignRequest(w, r, &req) {
+		return
+	}
+
+	campaign, err := h.campaignService.UpdateCampaign(campaignID, scope, req)
+	if err != nil {
+		switch err.(type) {
+		case models.NotFoundError, models.BadRequestError:
+			HandleError(w, err)
+			return
+		}
+		h.logger.Error("Failed to update campaign: %v", err)
+		http.Error(w, "Failed to update campaign", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaign)
+}
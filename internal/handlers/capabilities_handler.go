@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"whatsapp-multi-session/internal/config"
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// whatsmeowModule is the module path used to look up the library version in build info
+const whatsmeowModule = "go.mau.fi/whatsmeow"
+
+// CapabilitiesHandler reports server version, enabled features and limits
+type CapabilitiesHandler struct {
+	cfg           *config.Config
+	version       string
+	storageDriver string
+}
+
+// NewCapabilitiesHandler creates a new capabilities handler
+func NewCapabilitiesHandler(cfg *config.Config, version string, storageDriver string) *CapabilitiesHandler {
+	return &CapabilitiesHandler{
+		cfg:           cfg,
+		version:       version,
+		storageDriver: storageDriver,
+	}
+}
+
+// GetCapabilities handles GET /api/capabilities
+func (h *CapabilitiesHandler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	whatsmeowVersion, goVersion := buildVersions()
+
+	capabilities := &models.Capabilities{
+		Version:          h.version,
+		WhatsmeowVersion: whatsmeowVersion,
+		GoVersion:        goVersion,
+		Features: map[string]bool{
+			"frontend":         h.cfg.EnableFrontend,
+			"database_logging": h.cfg.EnableDatabaseLog,
+			"metrics":          h.cfg.EnableMetrics,
+			"s3_storage":       h.storageDriver == storage.DriverS3,
+			"sandbox_mode":     h.cfg.SandboxMode,
+		},
+		Limits: map[string]int{
+			"max_media_size_bytes":      h.cfg.MaxMediaSizeMB * 1024 * 1024,
+			"max_sessions":              h.cfg.MaxSessions,
+			"default_sessions_per_user": models.DefaultSessionLimit,
+		},
+		Endpoints: compiledEndpoints(),
+	}
+
+	WriteSuccessResponse(w, "Capabilities retrieved successfully", capabilities)
+}
+
+// compiledEndpoints lists optional endpoints and whether this build serves them.
+// Keep in sync with setupRoutes when adding or removing optional features.
+func compiledEndpoints() map[string]bool {
+	return map[string]bool{
+		"send_location":         true,
+		"send_attachment":       true,
+		"send_file_url":         true,
+		"forward":               true,
+		"reply":                 true,
+		"typing":                true,
+		"mark_read":             true,
+		"reactions":             true,
+		"delete_message":        true,
+		"message_history":       true,
+		"presence":              true,
+		"profile":               true,
+		"groups":                true,
+		"group_management":      true,
+		"conversations":         true,
+		"labels":                true,
+		"send_defaults":         true,
+		"new_contact_detection": true,
+		"status":                true,
+		"check_number":          true,
+		"contacts":              true,
+		"contact_profiles":      true,
+		"contact_sync":          true,
+		"bulk_messages":         true,
+		"bulk_recipients":       true,
+		"bulk_throttle":         true,
+		"bulk_session_rotation": true,
+		"scheduled_campaigns":   true,
+		"auto_replies":          true,
+		"webhook_events":        true,
+		"analytics":             true,
+		"templates":             false,
+		"polls":                 true,
+		"voice_notes":           true,
+		"newsletters":           false,
+		"edit_message":          true,
+		"pairing_code":          true,
+		"sandbox":               true,
+	}
+}
+
+// buildVersions reads the whatsmeow module version and Go version from the binary's build info
+func buildVersions() (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown", "unknown"
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == whatsmeowModule {
+			if dep.Replace != nil {
+				return dep.Replace.Version, info.GoVersion
+			}
+			return dep.Version, info.GoVersion
+		}
+	}
+
+	return "unknown", info.GoVersion
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/services"
+)
+
+// GetCatalog handles GET /api/sessions/{sessionId}/catalog
+func (h *SessionHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > services.MaxCatalogLimit {
+			HandleError(w, models.NewBadRequestError("limit must be between 1 and %d", services.MaxCatalogLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	catalog, err := h.whatsappService.GetCatalog(sessionID, limit, r.URL.Query().Get("after"))
+	if err != nil {
+		h.logger.Error("Failed to get catalog for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Catalog retrieved successfully", catalog)
+}
+
+// SendProduct handles POST /api/sessions/{sessionId}/send-product
+func (h *SessionHandler) SendProduct(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.SendProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.To == "" {
+		HandleError(w, models.NewBadRequestError("to is required"))
+		return
+	}
+
+	messageID, err := h.whatsappService.SendProduct(sessionID, &req)
+	if err != nil {
+		h.logger.Error("Failed to send product from session %s: %v", sessionID, err)
+		h.logMessage(sessionID, messageID, "", req.To, "product", req.Body, "", "sent", "failed", err.Error())
+		HandleError(w, err)
+		return
+	}
+	h.logMessage(sessionID, messageID, "", req.To, "product", req.Body, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "product")
+
+	WriteSuccessResponse(w, "Product sent successfully", map[string]interface{}{
+		"message_id": messageID,
+		"product_id": req.ProductID,
+	})
+}
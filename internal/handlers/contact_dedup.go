@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// DeduplicateContacts handles POST /api/contacts/deduplicate. Contacts are
+// grouped by their number in E.164, reading national numbers in the user's
+// region. Without merge the clusters are only reported; with merge each
+// cluster is merged into its oldest contact and the numbers of the other
+// contacts are rewritten in E.164. Only contacts of the same user are
+// duplicates; admins deduplicate every user's contacts unless they pass
+// ?user_id=.
+func (h *ContactHandler) DeduplicateContacts(w http.ResponseWriter, r *http.Request) {
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	var req models.ContactDedupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	region := phoneRegion(r)
+	clusters, normalize, unparsable, err := h.contactRepo.FindDuplicateContacts(region, scope)
+	if err != nil {
+		h.logger.Error("Failed to find duplicate contacts: %v", err)
+		http.Error(w, "Failed to find duplicate contacts", http.StatusInternalServerError)
+		return
+	}
+
+	result := &models.ContactDedupResult{
+		Clusters:   clusters,
+		Normalized: len(normalize),
+		Unparsable: unparsable,
+		Merged:     req.Merge,
+	}
+	for _, cluster := range clusters {
+		result.Duplicates += len(cluster.MergeIDs)
+	}
+
+	if req.Merge {
+		merged := 0
+		for _, cluster := range clusters {
+			if err := h.contactRepo.MergeContactCluster(cluster); err != nil {
+				h.logger.Error("Failed to merge contacts %v into %d: %v", cluster.MergeIDs, cluster.KeepID, err)
+				result.Errors = append(result.Errors, fmt.Sprintf("Contacts %v into %d: %v", cluster.MergeIDs, cluster.KeepID, err))
+				result.Duplicates -= len(cluster.MergeIDs)
+				continue
+			}
+			merged++
+		}
+		if err := h.contactRepo.NormalizeContactPhones(normalize); err != nil {
+			h.logger.Error("Failed to normalize contact phones: %v", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("Normalizing phones: %v", err))
+			result.Normalized = 0
+		}
+		h.logger.Info("Merged %d duplicate contact(s) in %d cluster(s) and normalized %d phone(s)",
+			result.Duplicates, merged, result.Normalized)
+	}
+
+	for i := range result.Clusters {
+		cluster := &result.Clusters[i]
+		for j := range cluster.Contacts {
+			formatContactPhone(&cluster.Contacts[j], region)
+		}
+		formatContactPhone(cluster.Merged, region)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
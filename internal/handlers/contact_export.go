@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// contactExportPageSize is how many contacts are read from the database at a time
+const contactExportPageSize = 500
+
+// contactCSVHeader names the columns of a CSV contact export, in the form
+// contact detection reads back on import
+var contactCSVHeader = []string{"name", "phone", "email", "company", "position", "tags", "notes"}
+
+// ExportContacts handles GET /api/contacts/export. format is csv (default) or
+// json; query, group_id, tags (comma-separated), is_active and segment filter
+// the contacts like GET /api/contacts, which also decides whose contacts are
+// exported. Contacts are streamed page by page, so
+// an export never holds more than a page in memory.
+func (h *ContactHandler) ExportContacts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		HandleError(w, models.NewBadRequestError("format must be csv or json"))
+		return
+	}
+
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	req := models.ContactSearchRequest{
+		Query:   query.Get("query"),
+		Segment: query.Get("segment"),
+		UserID:  scope,
+	}
+	if value := query.Get("group_id"); value != "" {
+		groupID, err := strconv.Atoi(value)
+		if err != nil {
+			HandleError(w, models.NewBadRequestError("group_id must be a number"))
+			return
+		}
+		req.GroupID = &groupID
+	}
+	if value := query.Get("is_active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			HandleError(w, models.NewBadRequestError("is_active must be true or false"))
+			return
+		}
+		req.IsActive = &active
+	}
+	for _, tag := range strings.Split(query.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			req.Tags = append(req.Tags, tag)
+		}
+	}
+	if req.Segment != "" {
+		if !models.IsValidSegment(req.Segment) {
+			HandleError(w, models.NewBadRequestError("segment must be one of: engaged, dormant, never_replied"))
+			return
+		}
+		thresholds, err := segmentThresholds(h.userRepo, r)
+		if err != nil {
+			h.logger.Error("Failed to get segment thresholds: %v", err)
+			http.Error(w, "Failed to export contacts", http.StatusInternalServerError)
+			return
+		}
+		req.Thresholds = thresholds
+	}
+
+	// Fail with a proper error if the first page cannot be read, before streaming starts
+	page, err := h.contactRepo.ExportContacts(req, 0, contactExportPageSize)
+	if err != nil {
+		h.logger.Error("Failed to export contacts: %v", err)
+		http.Error(w, "Failed to export contacts", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := "contacts-" + time.Now().UTC().Format("20060102")
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, fileName, format))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+
+	var encoder contactEncoder = &jsonContactEncoder{out: out}
+	if format == "csv" {
+		encoder = &csvContactEncoder{writer: csv.NewWriter(out)}
+	}
+
+	rows := 0
+	err = encoder.begin()
+	for err == nil && len(page) > 0 {
+		for _, contact := range page {
+			if err = encoder.encode(contact); err != nil {
+				break
+			}
+			rows++
+		}
+		if err == nil {
+			err = encoder.flush()
+		}
+		if err != nil {
+			break
+		}
+		// Push each page to the client instead of buffering the whole export
+		if gz != nil {
+			gz.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(page) < contactExportPageSize {
+			break
+		}
+		page, err = h.contactRepo.ExportContacts(req, page[len(page)-1].ID, contactExportPageSize)
+	}
+	if err == nil {
+		err = encoder.end()
+	}
+	if err != nil {
+		// Headers are already sent; the export ends truncated
+		h.logger.Error("Contact export failed after %d row(s): %v", rows, err)
+		return
+	}
+
+	h.logger.Info("Exported %d contact(s) as %s", rows, format)
+}
+
+// contactEncoder writes the contacts of an export one at a time
+type contactEncoder interface {
+	begin() error
+	encode(contact *models.Contact) error
+	flush() error // Called after each page
+	end() error
+}
+
+// csvContactEncoder writes contacts as CSV rows under contactCSVHeader, with
+// tags separated by semicolons
+type csvContactEncoder struct {
+	writer *csv.Writer
+}
+
+func (e *csvContactEncoder) begin() error {
+	return e.writer.Write(contactCSVHeader)
+}
+
+func (e *csvContactEncoder) encode(contact *models.Contact) error {
+	return e.writer.Write([]string{
+		contact.Name, contact.Phone, contact.Email, contact.Company, contact.Position,
+		strings.Join(contact.Tags, ";"), contact.Notes,
+	})
+}
+
+func (e *csvContactEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvContactEncoder) end() error {
+	return e.flush()
+}
+
+// jsonContactEncoder writes contacts as a JSON array, element by element
+// instead of encoding one slice
+type jsonContactEncoder struct {
+	out   io.Writer
+	count int
+}
+
+func (e *jsonContactEncoder) begin() error {
+	_, err := io.WriteString(e.out, "[")
+	return err
+}
+
+func (e *jsonContactEncoder) encode(contact *models.Contact) error {
+	data, err := json.Marshal(contact)
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		if _, err := io.WriteString(e.out, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	_, err = e.out.Write(data)
+	return err
+}
+
+func (e *jsonContactEncoder) flush() error {
+	return nil
+}
+
+func (e *jsonContactEncoder) end() error {
+	_, err := io.WriteString(e.out, "]")
+	return err
+}
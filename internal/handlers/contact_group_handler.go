@@ -28,7 +28,13 @@ func NewContactGroupHandler(
 
 // GetContactGroups handles GET /api/contact-groups
 func (h *ContactGroupHandler) GetContactGroups(w http.ResponseWriter, r *http.Request) {
-	groups, err := h.groupRepo.GetContactGroups()
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	groups, err := h.groupRepo.GetContactGroups(scope)
 	if err != nil {
 		h.logger.Error("Failed to get contact groups: %v", err)
 		http.Error(w, "Failed to get contact groups", http.StatusInternalServerError)
@@ -41,6 +47,12 @@ func (h *ContactGroupHandler) GetContactGroups(w http.ResponseWriter, r *http.Re
 
 // CreateContactGroup handles POST /api/contact-groups
 func (h *ContactGroupHandler) CreateContactGroup(w http.ResponseWriter, r *http.Request) {
+	owner, err := contactOwner(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var req models.CreateContactGroupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -49,6 +61,7 @@ func (h *ContactGroupHandler) CreateContactGroup(w http.ResponseWriter, r *http.
 	
 	// Convert request to ContactGroup model
 	group := &models.ContactGroup{
+		UserID:      owner,
 		Name:        req.Name,
 		Description: req.Description,
 		Color:       req.Color,
@@ -75,20 +88,30 @@ func (h *ContactGroupHandler) UpdateContactGroup(w http.ResponseWriter, r *http.
 		return
 	}
 	
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var req models.UpdateContactGroupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	
-	if err := h.groupRepo.UpdateContactGroup(groupID, req); err != nil {
+	if err := h.groupRepo.UpdateContactGroup(groupID, scope, req); err != nil {
+		if _, ok := err.(models.NotFoundError); ok {
+			HandleError(w, err)
+			return
+		}
 		h.logger.Error("Failed to update contact group: %v", err)
 		http.Error(w, "Failed to update contact group", http.StatusInternalServerError)
 		return
 	}
 	
 	// Get updated group
-	group, err := h.groupRepo.GetContactGroup(groupID)
+	group, err := h.groupRepo.GetContactGroup(groupID, scope)
 	if err != nil {
 		h.logger.Error("Failed to get updated contact group: %v", err)
 		http.Error(w, "Failed to get updated contact group", http.StatusInternalServerError)
@@ -108,7 +131,17 @@ func (h *ContactGroupHandler) DeleteContactGroup(w http.ResponseWriter, r *http.
 		return
 	}
 	
-	if err := h.groupRepo.DeleteContactGroup(groupID); err != nil {
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if err := h.groupRepo.DeleteContactGroup(groupID, scope); err != nil {
+		if _, ok := err.(models.NotFoundError); ok {
+			HandleError(w, err)
+			return
+		}
 		h.logger.Error("Failed to delete contact group: %v", err)
 		http.Error(w, "Failed to delete contact group", http.StatusInternalServerError)
 		return
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -15,6 +16,7 @@ import (
 type ContactHandler struct {
 	contactRepo    *repository.ContactRepository
 	groupRepo      *repository.ContactGroupRepository
+	userRepo       *repository.UserRepository
 	detectionSvc   *services.ContactDetectionService
 	logger         *logger.Logger
 }
@@ -22,12 +24,14 @@ type ContactHandler struct {
 func NewContactHandler(
 	contactRepo *repository.ContactRepository,
 	groupRepo *repository.ContactGroupRepository,
+	userRepo *repository.UserRepository,
 	detectionSvc *services.ContactDetectionService,
 	logger *logger.Logger,
 ) *ContactHandler {
 	return &ContactHandler{
 		contactRepo:  contactRepo,
 		groupRepo:    groupRepo,
+		userRepo:     userRepo,
 		detectionSvc: detectionSvc,
 		logger:       logger,
 	}
@@ -40,6 +44,18 @@ func (h *ContactHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
 	groupIDStr := r.URL.Query().Get("group_id")
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
+	segment := r.URL.Query().Get("segment")
+	
+	if segment != "" && !models.IsValidSegment(segment) {
+		http.Error(w, "Invalid segment, must be one of: engaged, dormant, never_replied", http.StatusBadRequest)
+		return
+	}
+	
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
 	
 	var groupID *int
 	if groupIDStr != "" {
@@ -65,10 +81,22 @@ func (h *ContactHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
 	searchReq := models.ContactSearchRequest{
 		Query:   query,
 		GroupID: groupID,
+		Segment: segment,
+		UserID:  scope,
 		Page:    page,
 		Limit:   limit,
 	}
 	
+	if segment != "" {
+		thresholds, err := segmentThresholds(h.userRepo, r)
+		if err != nil {
+			h.logger.Error("Failed to get segment thresholds: %v", err)
+			http.Error(w, "Failed to get contacts", http.StatusInternalServerError)
+			return
+		}
+		searchReq.Thresholds = thresholds
+	}
+	
 	response, err := h.contactRepo.GetContacts(searchReq)
 	if err != nil {
 		h.logger.Error("Failed to get contacts: %v", err)
@@ -76,17 +104,147 @@ func (h *ContactHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	region := phoneRegion(r)
+	for i := range response.Contacts {
+		formatContactPhone(&response.Contacts[i], region)
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetSegmentSettings handles GET /api/contacts/segment-settings
+func (h *ContactHandler) GetSegmentSettings(w http.ResponseWriter, r *http.Request) {
+	thresholds, err := segmentThresholds(h.userRepo, r)
+	if err != nil {
+		h.logger.Error("Failed to get segment thresholds: %v", err)
+		http.Error(w, "Failed to get segment settings", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thresholds)
+}
+
+// UpdateSegmentSettings handles PUT /api/contacts/segment-settings
+func (h *ContactHandler) UpdateSegmentSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	var req models.UpdateSegmentThresholdsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	if req.EngagedMinScore != nil && (*req.EngagedMinScore < 0 || *req.EngagedMinScore > 100) {
+		http.Error(w, "engaged_min_score must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	if req.DormantAfterDays != nil && *req.DormantAfterDays < 1 {
+		http.Error(w, "dormant_after_days must be at least 1", http.StatusBadRequest)
+		return
+	}
+	
+	if err := h.userRepo.UpdateSegmentThresholds(userID, req.EngagedMinScore, req.DormantAfterDays); err != nil {
+		h.logger.Error("Failed to update segment thresholds: %v", err)
+		http.Error(w, "Failed to update segment settings", http.StatusInternalServerError)
+		return
+	}
+	
+	h.GetSegmentSettings(w, r)
+}
+
+// segmentThresholds returns the segment thresholds of the requesting user
+func segmentThresholds(userRepo *repository.UserRepository, r *http.Request) (models.SegmentThresholds, error) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		return models.DefaultSegmentThresholds(), nil
+	}
+	return userRepo.GetSegmentThresholds(userID)
+}
+
+// contactScope returns the user whose contacts and contact groups a request
+// works on. Users only ever see their own; admins see every user's (0) unless
+// they pass ?user_id= to look at one tenant.
+func contactScope(r *http.Request) (int, error) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		return 0, models.NewUnauthorizedError("unauthorized")
+	}
+	
+	value := r.URL.Query().Get("user_id")
+	if role, _ := r.Context().Value("role").(string); role != models.RoleAdmin {
+		if value != "" && value != strconv.Itoa(userID) {
+			return 0, models.NewForbiddenError("only admins can access the contacts of other users")
+		}
+		return userID, nil
+	}
+	if value == "" {
+		return 0, nil
+	}
+	scope, err := strconv.Atoi(value)
+	if err != nil || scope <= 0 {
+		return 0, models.NewBadRequestError("user_id must be a positive user ID")
+	}
+	return scope, nil
+}
+
+// contactOwner returns the user that contacts and contact groups created by a
+// request belong to: the caller, or the user an admin passed as ?user_id=
+func contactOwner(r *http.Request) (int, error) {
+	scope, err := contactScope(r)
+	if err != nil || scope != 0 {
+		return scope, err
+	}
+	userID, _ := r.Context().Value("user_id").(int)
+	return userID, nil
+}
+
+// checkGroupOwner verifies that a contact group assigned to contacts of owner
+// is one of owner's groups
+func checkGroupOwner(groupRepo *repository.ContactGroupRepository, groupID *int, owner int) error {
+	if groupID == nil {
+		return nil
+	}
+	_, err := groupRepo.GetContactGroup(*groupID, owner)
+	return err
+}
+
+// checkContactOwner verifies that every contact in ids belongs to owner
+func checkContactOwner(contactRepo *repository.ContactRepository, ids []int, owner int) error {
+	foreign, err := contactRepo.ForeignContactIDs(ids, owner)
+	if err != nil {
+		return err
+	}
+	if len(foreign) > 0 {
+		return models.NewNotFoundError("contacts not found: %v", foreign)
+	}
+	return nil
+}
+
 // CreateContact handles POST /api/contacts
 func (h *ContactHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
+	owner, err := contactOwner(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var contact models.Contact
 	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	contact.UserID = owner
+	
+	if err := checkGroupOwner(h.groupRepo, contact.GroupID, owner); err != nil {
+		HandleError(w, err)
+		return
+	}
 	
 	if err := h.contactRepo.CreateContact(&contact); err != nil {
 		h.logger.Error("Failed to create contact: %v", err)
@@ -94,6 +252,8 @@ func (h *ContactHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	formatContactPhone(&contact, phoneRegion(r))
+	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(contact)
@@ -108,30 +268,84 @@ func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var updateReq models.UpdateContactRequest
 	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	
-	if err := h.contactRepo.UpdateContact(contactID, updateReq); err != nil {
+	if updateReq.GroupID != nil {
+		// The group must belong to the contact's owner, whoever is editing it
+		current, err := h.contactRepo.GetContact(contactID, scope)
+		if err != nil {
+			HandleError(w, err)
+			return
+		}
+		if err := checkGroupOwner(h.groupRepo, updateReq.GroupID, current.UserID); err != nil {
+			HandleError(w, err)
+			return
+		}
+	}
+	
+	if updateReq.ExpectedVersion, err = expectedVersion(r, updateReq.ExpectedVersion); err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if err := h.contactRepo.UpdateContact(contactID, scope, updateReq); err != nil {
+		switch err.(type) {
+		case models.NotFoundError, models.BadRequestError:
+			HandleError(w, err)
+			return
+		}
+		if err == repository.ErrVersionConflict {
+			h.writeContactConflict(w, r, contactID, scope, *updateReq.ExpectedVersion)
+			return
+		}
 		h.logger.Error("Failed to update contact: %v", err)
 		http.Error(w, "Failed to update contact", http.StatusInternalServerError)
 		return
 	}
 	
 	// Return updated contact
-	contact, err := h.contactRepo.GetContact(contactID)
+	contact, err := h.contactRepo.GetContact(contactID, scope)
 	if err != nil {
 		h.logger.Error("Failed to get updated contact: %v", err)
 		http.Error(w, "Failed to get updated contact", http.StatusInternalServerError)
 		return
 	}
 	
+	formatContactPhone(contact, phoneRegion(r))
+	
+	setVersionHeader(w, contact.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(contact)
 }
 
+// writeContactConflict answers a contact update based on an outdated version
+// with 409 and the contact's current state
+func (h *ContactHandler) writeContactConflict(w http.ResponseWriter, r *http.Request, contactID, scope int, expected int64) {
+	contact, err := h.contactRepo.GetContact(contactID, scope)
+	if err != nil {
+		h.logger.Error("Failed to get contact after version conflict: %v", err)
+		http.Error(w, "Failed to update contact", http.StatusInternalServerError)
+		return
+	}
+	formatContactPhone(contact, phoneRegion(r))
+	
+	HandleError(w, models.ConflictError{
+		Message:        fmt.Sprintf("contact %d was modified since version %d; current version is %d", contactID, expected, contact.Version),
+		CurrentVersion: contact.Version,
+		Current:        contact,
+	})
+}
+
 // DeleteContact handles DELETE /api/contacts/{id}
 func (h *ContactHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -141,7 +355,17 @@ func (h *ContactHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	if err := h.contactRepo.DeleteContact(contactID); err != nil {
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
+	if err := h.contactRepo.DeleteContact(contactID, scope); err != nil {
+		if _, ok := err.(models.NotFoundError); ok {
+			HandleError(w, err)
+			return
+		}
 		h.logger.Error("Failed to delete contact: %v", err)
 		http.Error(w, "Failed to delete contact", http.StatusInternalServerError)
 		return
@@ -152,13 +376,33 @@ func (h *ContactHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 
 // BulkActions handles POST /api/contacts/bulk
 func (h *ContactHandler) BulkActions(w http.ResponseWriter, r *http.Request) {
+	scope, err := contactScope(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var request models.BulkContactRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	
-	if err := h.contactRepo.BulkUpdateContacts(request); err != nil {
+	// Nothing is changed unless every contact belongs to the caller
+	if scope != 0 {
+		if err := checkContactOwner(h.contactRepo, request.ContactIDs, scope); err != nil {
+			HandleError(w, err)
+			return
+		}
+		if request.Action == "move_to_group" {
+			if err := checkGroupOwner(h.groupRepo, request.GroupID, scope); err != nil {
+				HandleError(w, err)
+				return
+			}
+		}
+	}
+	
+	if err := h.contactRepo.BulkUpdateContacts(request, scope); err != nil {
 		h.logger.Error("Failed to perform bulk action: %v", err)
 		http.Error(w, "Failed to perform bulk action", http.StatusInternalServerError)
 		return
@@ -226,6 +470,12 @@ func (h *ContactHandler) DetectContacts(w http.ResponseWriter, r *http.Request)
 
 // ImportContacts handles POST /api/contacts/import
 func (h *ContactHandler) ImportContacts(w http.ResponseWriter, r *http.Request) {
+	owner, err := contactOwner(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	
 	var request struct {
 		Contacts []models.Contact `json:"contacts"`
 	}
@@ -235,8 +485,20 @@ func (h *ContactHandler) ImportContacts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
+	checked := make(map[int]bool)
+	for _, contact := range request.Contacts {
+		if contact.GroupID == nil || checked[*contact.GroupID] {
+			continue
+		}
+		if err := checkGroupOwner(h.groupRepo, contact.GroupID, owner); err != nil {
+			HandleError(w, err)
+			return
+		}
+		checked[*contact.GroupID] = true
+	}
+	
 	// Use bulk create to import contacts
-	result, err := h.contactRepo.BulkCreateContacts(request.Contacts)
+	result, err := h.contactRepo.BulkCreateContacts(request.Contacts, owner)
 	if err != nil {
 		h.logger.Error("Failed to import contacts: %v", err)
 		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
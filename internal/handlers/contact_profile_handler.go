@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetContactAvatar handles GET /api/sessions/{sessionId}/contacts/{jid}/avatar.
+// ?size=preview returns the thumbnail instead of the full picture, and
+// ?format=url returns the picture's WhatsApp URL instead of the image.
+func (h *SessionHandler) GetContactAvatar(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var preview bool
+	switch query.Get("size") {
+	case "", "full":
+	case "preview":
+		preview = true
+	default:
+		HandleError(w, models.NewBadRequestError("size must be full or preview"))
+		return
+	}
+
+	switch query.Get("format") {
+	case "url":
+		avatar, err := h.whatsappService.GetContactAvatar(sessionID, vars["jid"], preview)
+		if err != nil {
+			h.logger.Debug("Failed to get avatar of %s for session %s: %v", vars["jid"], sessionID, err)
+			HandleError(w, err)
+			return
+		}
+		WriteSuccessResponse(w, "Profile picture retrieved successfully", avatar)
+	case "", "image":
+		image, contentType, err := h.whatsappService.DownloadContactAvatar(sessionID, vars["jid"], preview)
+		if err != nil {
+			h.logger.Debug("Failed to download avatar of %s for session %s: %v", vars["jid"], sessionID, err)
+			HandleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		w.Write(image)
+	default:
+		HandleError(w, models.NewBadRequestError("format must be image or url"))
+	}
+}
+
+// GetBusinessProfile handles GET /api/sessions/{sessionId}/contacts/{jid}/business-profile
+func (h *SessionHandler) GetBusinessProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	profile, err := h.whatsappService.GetBusinessProfile(sessionID, vars["jid"])
+	if err != nil {
+		h.logger.Debug("Failed to get business profile of %s for session %s: %v", vars["jid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Business profile retrieved successfully", profile)
+}
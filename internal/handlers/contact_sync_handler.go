@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SyncContacts handles POST /api/sessions/{sessionId}/sync-contacts, copying
+// the session's WhatsApp contacts into the contacts table. dry_run is read
+// from the query string or, if present, a JSON body.
+func (h *SessionHandler) SyncContacts(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	req := models.SyncContactsRequest{DryRun: r.URL.Query().Get("dry_run") == "true"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.whatsappService.SyncContacts(sessionID, req.DryRun)
+	if err != nil {
+		h.logger.Error("Failed to sync contacts of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	message := "Contacts synced successfully"
+	if req.DryRun {
+		message = "Contact sync previewed"
+	}
+	WriteSuccessResponse(w, message, result)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// EditMessage handles PUT /api/sessions/{sessionId}/messages/{messageId},
+// replacing the text of a sent text message
+func (h *SessionHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.whatsappService.EditMessage(sessionID, req.Chat, vars["messageId"], req.Text)
+	if err != nil {
+		h.logger.Error("Failed to edit message %s of session %s: %v", vars["messageId"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Message edited successfully", resp)
+}
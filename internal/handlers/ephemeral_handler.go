@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SetChatEphemeral handles PUT /api/sessions/{sessionId}/chats/{jid}/ephemeral
+func (h *SessionHandler) SetChatEphemeral(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.ChatEphemeralRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	seconds, err := h.whatsappService.SetChatEphemeral(sessionID, vars["jid"], req.Timer)
+	if err != nil {
+		h.logger.Error("Failed to set disappearing messages in %s for session %s: %v", vars["jid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Disappearing messages updated successfully", map[string]interface{}{
+		"chat":                 vars["jid"],
+		"timer":                req.Timer,
+		"ephemeral_expiration": seconds,
+	})
+}
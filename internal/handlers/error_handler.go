@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 	
 	"whatsapp-multi-session/internal/models"
 )
@@ -27,6 +29,51 @@ func HandleError(w http.ResponseWriter, err error) {
 	case models.ServiceUnavailableError:
 		w.WriteHeader(http.StatusServiceUnavailable)
 		response = models.ErrorResponse(err.Error(), models.ErrCodeServiceUnavailable)
+	case models.ForbiddenError:
+		w.WriteHeader(http.StatusForbidden)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeForbidden)
+	case models.SessionBannedError:
+		banned := err.(models.SessionBannedError)
+		if wait := time.Until(banned.BannedUntil); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		w.WriteHeader(http.StatusForbidden)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeSessionBanned)
+		response.Data = map[string]string{"banned_until": models.FormatTimestamp(banned.BannedUntil)}
+	case models.PayloadTooLargeError:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		response = models.ErrorResponse(err.Error(), models.ErrCodePayloadTooLarge)
+	case models.RateLimitedError:
+		w.WriteHeader(http.StatusTooManyRequests)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeRateLimited)
+	case models.EditWindowExpiredError:
+		expired := err.(models.EditWindowExpiredError)
+		w.WriteHeader(http.StatusBadRequest)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeEditWindowExpired)
+		response.Data = map[string]interface{}{
+			"sent_at":             models.FormatTimestamp(expired.SentAt),
+			"edit_window_seconds": int(models.EditWindow.Seconds()),
+		}
+	case models.NotReadyError:
+		if retryAfter := err.(models.NotReadyError).RetryAfter; retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+		}
+		w.WriteHeader(http.StatusTooEarly)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeNotReady)
+	case models.ConflictError:
+		conflict := err.(models.ConflictError)
+		setVersionHeader(w, conflict.CurrentVersion)
+		w.WriteHeader(http.StatusConflict)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeConflict)
+		response.Data = map[string]interface{}{
+			"current_version": conflict.CurrentVersion,
+			"current":         conflict.Current,
+		}
+	case models.SessionLimitError:
+		limit := err.(models.SessionLimitError)
+		w.WriteHeader(http.StatusForbidden)
+		response = models.ErrorResponse(err.Error(), models.ErrCodeSessionLimitReached)
+		response.Data = map[string]int{"count": limit.Count, "limit": limit.Limit}
 	default:
 		// For any other errors, return 500
 		w.WriteHeader(http.StatusInternalServerError)
@@ -36,6 +83,20 @@ func HandleError(w http.ResponseWriter, err error) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// writeSendError reports a failed send. Ban and rate-limit rejections keep
+// their typed status so clients can back off, invalid send options and
+// unusable media URLs are a 400, oversized downloads a 413 and unknown
+// broadcast lists a 404; other failures stay a plain 500.
+func writeSendError(w http.ResponseWriter, err error) {
+	switch err.(type) {
+	case models.SessionBannedError, models.RateLimitedError, models.BadRequestError, models.PayloadTooLargeError,
+		models.NotFoundError, models.ServiceUnavailableError:
+		HandleError(w, err)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // HandleErrorWithMessage writes error response with custom message and code
 func HandleErrorWithMessage(w http.ResponseWriter, statusCode int, message string, code string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// CreateGroup handles POST /api/sessions/{sessionId}/groups
+func (h *SessionHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.whatsappService.CreateGroup(sessionID, req.Name, req.Participants)
+	if err != nil {
+		h.logger.Error("Failed to create group for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group created successfully", group)
+}
+
+// AddGroupParticipants handles POST /api/sessions/{sessionId}/groups/{groupJid}/participants
+func (h *SessionHandler) AddGroupParticipants(w http.ResponseWriter, r *http.Request) {
+	h.updateGroupParticipants(w, r, h.whatsappService.AddGroupParticipants, "Participants added")
+}
+
+// RemoveGroupParticipants handles DELETE /api/sessions/{sessionId}/groups/{groupJid}/participants
+func (h *SessionHandler) RemoveGroupParticipants(w http.ResponseWriter, r *http.Request) {
+	h.updateGroupParticipants(w, r, h.whatsappService.RemoveGroupParticipants, "Participants removed")
+}
+
+// PromoteGroupParticipants handles POST /api/sessions/{sessionId}/groups/{groupJid}/admins
+func (h *SessionHandler) PromoteGroupParticipants(w http.ResponseWriter, r *http.Request) {
+	h.updateGroupParticipants(w, r, h.whatsappService.PromoteParticipants, "Participants promoted")
+}
+
+// DemoteGroupParticipants handles DELETE /api/sessions/{sessionId}/groups/{groupJid}/admins
+func (h *SessionHandler) DemoteGroupParticipants(w http.ResponseWriter, r *http.Request) {
+	h.updateGroupParticipants(w, r, h.whatsappService.DemoteParticipants, "Participants demoted")
+}
+
+// updateGroupParticipants applies a participant change and returns WhatsApp's
+// result for each participant, since some may fail while others succeed
+func (h *SessionHandler) updateGroupParticipants(w http.ResponseWriter, r *http.Request,
+	change func(sessionID, groupJID string, participants []string) ([]*models.GroupParticipantResult, error), message string) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.GroupParticipantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := change(sessionID, vars["groupJid"], req.Participants)
+	if err != nil {
+		h.logger.Error("Failed to update participants of group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, message, map[string]interface{}{
+		"group":        vars["groupJid"],
+		"participants": results,
+	})
+}
+
+// LeaveGroup handles POST /api/sessions/{sessionId}/groups/{groupJid}/leave
+func (h *SessionHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := h.whatsappService.LeaveGroup(sessionID, vars["groupJid"]); err != nil {
+		h.logger.Error("Failed to leave group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Left group successfully", map[string]interface{}{
+		"group": vars["groupJid"],
+	})
+}
+
+// SetGroupName handles PUT /api/sessions/{sessionId}/groups/{groupJid}/name
+func (h *SessionHandler) SetGroupName(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.GroupNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.whatsappService.SetGroupName(sessionID, vars["groupJid"], req.Name); err != nil {
+		h.logger.Error("Failed to set name of group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group name updated successfully", map[string]interface{}{
+		"group": vars["groupJid"],
+		"name":  strings.TrimSpace(req.Name),
+	})
+}
+
+// SetGroupTopic handles PUT /api/sessions/{sessionId}/groups/{groupJid}/topic
+func (h *SessionHandler) SetGroupTopic(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.GroupTopicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.whatsappService.SetGroupTopic(sessionID, vars["groupJid"], req.Topic); err != nil {
+		h.logger.Error("Failed to set topic of group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group topic updated successfully", map[string]interface{}{
+		"group": vars["groupJid"],
+		"topic": strings.TrimSpace(req.Topic),
+	})
+}
+
+// SetGroupPhoto handles PUT /api/sessions/{sessionId}/groups/{groupJid}/photo
+func (h *SessionHandler) SetGroupPhoto(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.GroupPhotoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	image, err := base64.StdEncoding.DecodeString(req.Image)
+	if err != nil {
+		HandleError(w, models.NewBadRequestError("invalid base64 image data: %v", err))
+		return
+	}
+
+	pictureID, err := h.whatsappService.SetGroupPhoto(sessionID, vars["groupJid"], image)
+	if err != nil {
+		h.logger.Error("Failed to set photo of group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group photo updated successfully", map[string]interface{}{
+		"group":      vars["groupJid"],
+		"picture_id": pictureID,
+	})
+}
+
+// GetGroupInviteLink handles GET /api/sessions/{sessionId}/groups/{groupJid}/invite-link.
+// With ?reset=true the current link is revoked and a new one returned.
+func (h *SessionHandler) GetGroupInviteLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	reset := r.URL.Query().Get("reset") == "true"
+	link, err := h.whatsappService.GetGroupInviteLink(sessionID, vars["groupJid"], reset)
+	if err != nil {
+		h.logger.Error("Failed to get invite link of group %s for session %s: %v", vars["groupJid"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group invite link retrieved successfully", map[string]interface{}{
+		"group":       vars["groupJid"],
+		"invite_link": link,
+		"reset":       reset,
+	})
+}
+
+// JoinGroup handles POST /api/sessions/{sessionId}/groups/join
+func (h *SessionHandler) JoinGroup(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.JoinGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.whatsappService.JoinGroup(sessionID, req.Link)
+	if err != nil {
+		h.logger.Error("Failed to join group for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	message := "Joined group successfully"
+	if group.PendingApproval {
+		message = "Requested to join the group, an admin has to approve"
+	}
+	WriteSuccessResponse(w, message, group)
+}
+
+// PreviewGroupInvite handles GET /api/sessions/{sessionId}/groups/join?link=,
+// describing the group behind an invite link without joining it
+func (h *SessionHandler) PreviewGroupInvite(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	preview, err := h.whatsappService.PreviewGroupInvite(sessionID, r.URL.Query().Get("link"))
+	if err != nil {
+		h.logger.Error("Failed to preview group invite for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Group invite retrieved successfully", preview)
+}
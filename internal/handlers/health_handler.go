@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/services"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// proxyCheckInterval limits how often the outbound proxy self-test runs
+const proxyCheckInterval = time.Minute
+
+// databasePingTimeout bounds the database check of the health and readiness endpoints
+const databasePingTimeout = 2 * time.Second
+
+// Health statuses, of the whole service and of each component
+const (
+	healthOK        = "ok"
+	healthDegraded  = "degraded"  // Working, but with a problem worth a look
+	healthUnhealthy = "unhealthy" // A critical dependency failed; served with 503
+)
+
+// HealthHandler serves the health check endpoint
+type HealthHandler struct {
+	outboundClient  *http.Client
+	proxyConfigured bool
+	proxyTestURL    string
+	db              *sql.DB
+	whatsapp        *services.WhatsAppService
+	logger          *logger.Logger
+
+	mu         sync.Mutex
+	proxyCheck *ProxyCheckResult
+}
+
+// ProxyCheckResult is the outcome of the outbound proxy connectivity self-test
+type ProxyCheckResult struct {
+	Configured bool      `json:"configured"`
+	Reachable  bool      `json:"reachable"`
+	LatencyMS  int64     `json:"latency_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at,omitempty"`
+}
+
+// ComponentHealth is the health of one dependency
+type ComponentHealth struct {
+	Status    string                `json:"status"`
+	LatencyMS int64                 `json:"latency_ms,omitempty"`
+	Error     string                `json:"error,omitempty"`
+	Sessions  *models.SessionCounts `json:"sessions,omitempty"`
+}
+
+// NewHealthHandler creates a new health handler. When an outbound proxy is
+// configured the health response includes a connectivity test through it.
+func NewHealthHandler(outboundClient *http.Client, proxyConfigured bool, proxyTestURL string, log *logger.Logger) *HealthHandler {
+	return &HealthHandler{
+		outboundClient:  outboundClient,
+		proxyConfigured: proxyConfigured,
+		proxyTestURL:    proxyTestURL,
+		logger:          log,
+	}
+}
+
+// SetDatabase makes the health and readiness checks ping the database
+func (h *HealthHandler) SetDatabase(db *sql.DB) {
+	h.db = db
+}
+
+// SetWhatsAppService makes the health check report sessions and the
+// WhatsApp store, and readiness wait for stored sessions to be restored
+func (h *HealthHandler) SetWhatsAppService(whatsapp *services.WhatsAppService) {
+	h.whatsapp = whatsapp
+}
+
+// Health handles GET /api/health. It answers 503 when the database or the
+// WhatsApp store fails, and reports a degraded status when no session is
+// connected or the outbound proxy is unreachable.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	components := make(map[string]ComponentHealth)
+	response := map[string]interface{}{
+		"status":     healthOK,
+		"service":    "whatsapp-multi-session",
+		"components": components,
+	}
+
+	if h.db != nil {
+		components["database"] = h.checkDatabase(r.Context())
+	}
+	if h.whatsapp != nil {
+		store := ComponentHealth{Status: healthOK}
+		if err := h.whatsapp.CheckStoreWritable(); err != nil {
+			store = ComponentHealth{Status: healthUnhealthy, Error: err.Error()}
+			h.logger.Error("Health check: %v", err)
+		}
+		components["whatsapp_store"] = store
+		components["sessions"] = sessionsHealth(h.whatsapp.SessionCounts())
+	}
+
+	status := healthOK
+	for _, component := range components {
+		if component.Status == healthUnhealthy {
+			status = healthUnhealthy
+			break
+		}
+		if component.Status == healthDegraded {
+			status = healthDegraded
+		}
+	}
+
+	if h.proxyConfigured {
+		result := h.checkOutboundProxy()
+		response["outbound_proxy"] = result
+		if !result.Reachable && status == healthOK {
+			status = healthDegraded
+		}
+	}
+	response["status"] = status
+
+	code := http.StatusOK
+	if status == healthUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Ready handles GET /api/ready: 503 until stored sessions are restored and
+// the database answers, for load balancers and Kubernetes readiness probes
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ready, reason := true, ""
+	if h.whatsapp != nil {
+		ready, reason = h.whatsapp.Ready()
+	}
+	if ready && h.db != nil {
+		if database := h.checkDatabase(r.Context()); database.Status != healthOK {
+			ready, reason = false, "database unavailable: "+database.Error
+		}
+	}
+
+	response := map[string]interface{}{"ready": ready}
+	code := http.StatusOK
+	if !ready {
+		response["reason"] = reason
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkDatabase pings the database with a short timeout
+func (h *HealthHandler) checkDatabase(ctx context.Context) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, databasePingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := h.db.PingContext(ctx); err != nil {
+		h.logger.Error("Health check: database ping failed: %v", err)
+		return ComponentHealth{Status: healthUnhealthy, Error: err.Error()}
+	}
+	return ComponentHealth{Status: healthOK, LatencyMS: time.Since(start).Milliseconds()}
+}
+
+// sessionsHealth reports the session counts, degraded when there are
+// enabled sessions but none is connected. Sessions alone never make the
+// service unhealthy: restarting it would not bring WhatsApp back.
+func sessionsHealth(counts models.SessionCounts) ComponentHealth {
+	health := ComponentHealth{Status: healthOK, Sessions: &counts}
+	if counts.Enabled > 0 && counts.Connected == 0 {
+		health.Status = healthDegraded
+		health.Error = "no session is connected"
+	}
+	return health
+}
+
+// checkOutboundProxy runs the proxy self-test, reusing a recent result
+func (h *HealthHandler) checkOutboundProxy() ProxyCheckResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.proxyCheck != nil && time.Since(h.proxyCheck.CheckedAt) < proxyCheckInterval {
+		return *h.proxyCheck
+	}
+
+	result := &ProxyCheckResult{Configured: true, CheckedAt: time.Now()}
+	start := time.Now()
+
+	req, err := http.NewRequest(http.MethodHead, h.proxyTestURL, nil)
+	if err == nil {
+		var resp *http.Response
+		resp, err = h.outboundClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		h.logger.Warn("Outbound proxy self-test to %s failed: %v", h.proxyTestURL, err)
+	} else {
+		result.Reachable = true
+		result.LatencyMS = time.Since(start).Milliseconds()
+	}
+
+	h.proxyCheck = result
+	return *result
+}
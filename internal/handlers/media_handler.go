@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -10,21 +10,25 @@ import (
 
 	"github.com/gorilla/mux"
 	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/storage"
 )
 
 // MediaHandler handles media file serving
 type MediaHandler struct {
-	logger *logger.Logger
+	storage storage.Storage
+	logger  *logger.Logger
 }
 
 // NewMediaHandler creates a new media handler
-func NewMediaHandler(log *logger.Logger) *MediaHandler {
+func NewMediaHandler(mediaStorage storage.Storage, log *logger.Logger) *MediaHandler {
 	return &MediaHandler{
-		logger: log,
+		storage: mediaStorage,
+		logger:  log,
 	}
 }
 
-// ServeTempMedia serves temporary media files with expiration and authentication check
+// ServeTempMedia serves temporary media files with expiration and authentication check.
+// It handles GET and HEAD, including range and conditional requests.
 func (h *MediaHandler) ServeTempMedia(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	fileName := vars["filename"]
@@ -61,78 +65,106 @@ func (h *MediaHandler) ServeTempMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Construct file path
-	filePath := filepath.Join("./media/received", fileName)
-	
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		http.Error(w, "Media file not found", http.StatusNotFound)
+	key := storage.JoinKey(storage.PrefixReceivedMedia, fileName)
+
+	// Object storage can hand out a direct signed URL instead of proxying bytes
+	remaining := time.Until(time.Unix(expires, 0))
+	if signedURL, err := h.storage.PresignGet(key, remaining); err == nil {
+		http.Redirect(w, r, signedURL, http.StatusFound)
 		return
 	}
-	
-	// Determine content type based on file extension
-	ext := strings.ToLower(filepath.Ext(fileName))
-	var contentType string
-	switch ext {
-	case ".jpg", ".jpeg":
-		contentType = "image/jpeg"
-	case ".png":
-		contentType = "image/png"
-	case ".gif":
-		contentType = "image/gif"
-	case ".pdf":
-		contentType = "application/pdf"
-	case ".mp4":
-		contentType = "video/mp4"
-	case ".ogg":
-		contentType = "audio/ogg"
-	case ".mp3":
-		contentType = "audio/mpeg"
-	default:
-		contentType = "application/octet-stream"
+
+	if h.serveObject(w, r, key, fileName) {
+		h.logger.Debug("Served temporary media file: %s", fileName)
+	}
+}
+
+// serveObject writes a stored object to the response, see writeObject. It
+// returns false when the object could not be served (an error response has
+// been written).
+func (h *MediaHandler) serveObject(w http.ResponseWriter, r *http.Request, key, fileName string) bool {
+	reader, object, err := h.storage.Get(r.Context(), key)
+	if err == storage.ErrNotFound {
+		http.Error(w, "Media file not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		h.logger.Error("Failed to open media file %s: %v", key, err)
+		http.Error(w, "Failed to read media file", http.StatusInternalServerError)
+		return false
+	}
+	defer reader.Close()
+
+	writeObject(w, r, reader, object, fileName)
+	return true
+}
+
+// writeObject writes an opened stored object to the response. It supports
+// HEAD, Range and If-None-Match/If-Modified-Since requests and takes the
+// content type and strong ETag from the stored metadata.
+func writeObject(w http.ResponseWriter, r *http.Request, reader io.Reader, object *storage.Object, fileName string) {
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = contentTypeForFile(fileName)
 	}
 	
 	// Set appropriate headers
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "private, max-age=3600") // Cache for 1 hour
 	w.Header().Set("Content-Disposition", "inline; filename=\""+fileName+"\"")
+	if object.ETag != "" {
+		w.Header().Set("ETag", `"`+object.ETag+`"`)
+	}
 	
-	// Serve the file
-	http.ServeFile(w, r, filePath)
-	
-	h.logger.Debug("Served temporary media file: %s", fileName)
-}
-
-// CleanupExpiredMedia removes expired media files (should be called periodically)
-func (h *MediaHandler) CleanupExpiredMedia() {
-	mediaDir := "./media/received"
-	
-	// Clean up files older than 24 hours
-	cutoff := time.Now().Add(-24 * time.Hour)
+	// Local files are seekable; ServeContent handles HEAD, Range and the
+	// conditional headers using the ETag and modification time set above
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, fileName, object.ModTime, seeker)
+		return
+	}
 	
-	files, err := os.ReadDir(mediaDir)
-	if err != nil {
-		h.logger.Error("Failed to read media directory: %v", err)
+	if object.ETag != "" && etagMatches(r.Header.Get("If-None-Match"), object.ETag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		
-		info, err := file.Info()
-		if err != nil {
-			continue
-		}
-		
-		if info.ModTime().Before(cutoff) {
-			filePath := filepath.Join(mediaDir, file.Name())
-			if err := os.Remove(filePath); err != nil {
-				h.logger.Error("Failed to remove expired media file %s: %v", filePath, err)
-			} else {
-				h.logger.Info("Removed expired media file: %s", filePath)
-			}
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, reader)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag (unquoted)
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == `"`+etag+`"` {
+			return true
 		}
 	}
-}
\ No newline at end of file
+	return false
+}
+
+// contentTypeForFile determines the content type from the file extension
+func contentTypeForFile(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".pdf":
+		return "application/pdf"
+	case ".mp4":
+		return "video/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	case ".mp3":
+		return "audio/mpeg"
+	default:
+		return "application/octet-stream"
+	}
+}
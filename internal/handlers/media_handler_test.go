@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// testMediaContent is stored as media/received/clip.bin by newTestMediaHandler
+const testMediaContent = "0123456789abcdefghij"
+
+// newTestMediaHandler returns a media handler on local storage holding one
+// video, stored under a name whose extension says nothing about its type,
+// along with the video's ETag
+func newTestMediaHandler(t *testing.T) (*MediaHandler, string) {
+	t.Helper()
+	mediaStorage, err := storage.New(storage.Config{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if err := mediaStorage.Put(context.Background(), storage.JoinKey(storage.PrefixReceivedMedia, "clip.bin"), []byte(testMediaContent), "video/mp4"); err != nil {
+		t.Fatalf("failed to store media: %v", err)
+	}
+	sum := sha256.Sum256([]byte(testMediaContent))
+	return NewMediaHandler(mediaStorage, logger.New(false, "error", "text")), `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// serveMedia requests a temporary media file, as a signed-in user unless
+// anonymous is set
+func serveMedia(h *MediaHandler, method, target string, header http.Header, anonymous bool) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/media/temp/{filename}", h.ServeTempMedia).Methods("GET", "HEAD")
+
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if !anonymous {
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", 1))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeTempMedia(t *testing.T) {
+	h, etag := newTestMediaHandler(t)
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	target := "/api/media/temp/clip.bin?expires=" + expires
+
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		status       int
+		body         string
+		contentRange string
+	}{
+		{name: "whole file", method: "GET", status: http.StatusOK, body: testMediaContent},
+		{name: "head", method: "HEAD", status: http.StatusOK},
+		{name: "range", method: "GET", header: http.Header{"Range": {"bytes=2-5"}}, status: http.StatusPartialContent, body: "2345", contentRange: "bytes 2-5/20"},
+		{name: "open range", method: "GET", header: http.Header{"Range": {"bytes=15-"}}, status: http.StatusPartialContent, body: "fghij", contentRange: "bytes 15-19/20"},
+		{name: "suffix range", method: "GET", header: http.Header{"Range": {"bytes=-3"}}, status: http.StatusPartialContent, body: "hij", contentRange: "bytes 17-19/20"},
+		{name: "unsatisfiable range", method: "GET", header: http.Header{"Range": {"bytes=50-60"}}, status: http.StatusRequestedRangeNotSatisfiable},
+		{name: "matching etag", method: "GET", header: http.Header{"If-None-Match": {etag}}, status: http.StatusNotModified},
+		{name: "matching etag on head", method: "HEAD", header: http.Header{"If-None-Match": {etag}}, status: http.StatusNotModified},
+		{name: "one of several etags", method: "GET", header: http.Header{"If-None-Match": {`"other", ` + etag}}, status: http.StatusNotModified},
+		{name: "stale etag", method: "GET", header: http.Header{"If-None-Match": {`"other"`}}, status: http.StatusOK, body: testMediaContent},
+		{name: "range with current etag", method: "GET", header: http.Header{"Range": {"bytes=0-1"}, "If-Range": {etag}}, status: http.StatusPartialContent, body: "01", contentRange: "bytes 0-1/20"},
+		{name: "range with stale etag", method: "GET", header: http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"other"`}}, status: http.StatusOK, body: testMediaContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveMedia(h, tt.method, target, tt.header, false)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if rec.Code == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %s, want %s", got, etag)
+			}
+			if tt.status == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 response has a body: %q", rec.Body.String())
+				}
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "video/mp4" {
+				t.Errorf("Content-Type = %s, want the stored video/mp4", got)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+				t.Errorf("Accept-Ranges = %q, want bytes", got)
+			}
+			wantLength := len(tt.body)
+			if tt.method == "HEAD" {
+				wantLength = len(testMediaContent)
+			}
+			if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(wantLength) {
+				t.Errorf("Content-Length = %s, want %d", got, wantLength)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+		})
+	}
+}
+
+func TestServeTempMediaRejects(t *testing.T) {
+	h, _ := newTestMediaHandler(t)
+	valid := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		target    string
+		anonymous bool
+		status    int
+	}{
+		{"anonymous", "/api/media/temp/clip.bin?expires=" + valid, true, http.StatusUnauthorized},
+		{"no expiry", "/api/media/temp/clip.bin", false, http.StatusBadRequest},
+		{"bad expiry", "/api/media/temp/clip.bin?expires=soon", false, http.StatusBadRequest},
+		{"expired", "/api/media/temp/clip.bin?expires=" + expired, false, http.StatusGone},
+		{"traversal", "/api/media/temp/..clip.bin?expires=" + valid, false, http.StatusBadRequest},
+		{"missing file", "/api/media/temp/other.bin?expires=" + valid, false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveMedia(h, "GET", tt.target, nil, tt.anonymous)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+// Objects streamed from object storage cannot seek, so ranges are refused
+// but HEAD and If-None-Match still work
+func TestWriteObjectUnseekable(t *testing.T) {
+	object := &storage.Object{Size: int64(len(testMediaContent)), ContentType: "video/mp4", ETag: "abc123"}
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		status int
+		body   string
+	}{
+		{"whole file", "GET", nil, http.StatusOK, testMediaContent},
+		{"range ignored", "GET", http.Header{"Range": {"bytes=2-5"}}, http.StatusOK, testMediaContent},
+		{"head", "HEAD", nil, http.StatusOK, ""},
+		{"matching etag", "GET", http.Header{"If-None-Match": {`"abc123"`}}, http.StatusNotModified, ""},
+		{"weak matching etag", "GET", http.Header{"If-None-Match": {`W/"abc123"`}}, http.StatusNotModified, ""},
+		{"any etag", "GET", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/media", nil)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			rec := httptest.NewRecorder()
+			// MultiReader hides the Seek method of the underlying reader
+			writeObject(rec, req, io.MultiReader(strings.NewReader(testMediaContent)), object, "clip.bin")
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+			if got := rec.Header().Get("ETag"); got != `"abc123"` {
+				t.Errorf("ETag = %s, want \"abc123\"", got)
+			}
+			if tt.status == http.StatusOK {
+				if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(testMediaContent)) {
+					t.Errorf("Content-Length = %s, want %d", got, len(testMediaContent))
+				}
+				if got := rec.Header().Get("Accept-Ranges"); got != "none" {
+					t.Errorf("Accept-Ranges = %q, want none", got)
+				}
+			}
+		})
+	}
+}
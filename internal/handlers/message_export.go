@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// exportPageSize is how many messages are read from the database at a time
+const exportPageSize = 500
+
+// redactedContent replaces message content when exports are redacted
+const redactedContent = "[redacted]"
+
+// exportedMessage is one line of a JSON Lines message export
+type exportedMessage struct {
+	Timestamp string `json:"timestamp"` // RFC3339 UTC
+	SessionID string `json:"session_id"`
+	Direction string `json:"direction"`
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"`
+	Type      string `json:"type"`
+	Content   string `json:"content"`
+	MediaURL  string `json:"media_url,omitempty"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SetExportRedaction makes every message export replace content with
+// "[redacted]", regardless of what the request asks for
+func (h *SessionHandler) SetExportRedaction(redact bool) {
+	h.redactExports = redact
+}
+
+// ExportMessages handles GET /api/sessions/{sessionId}/messages/export
+func (h *SessionHandler) ExportMessages(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	userID, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	filter, redact, err := h.parseExportQuery(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	filter.SessionID = sessionID
+
+	h.streamExport(w, r, filter, redact, userID, fmt.Sprintf("message-export-%s", sessionID))
+}
+
+// AdminExportUserMessages handles GET /api/admin/users/{userId}/messages/export,
+// exporting the messages of every session the user owns
+func (h *SessionHandler) AdminExportUserMessages(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	adminID, _ := r.Context().Value("user_id").(int)
+
+	filter, redact, err := h.parseExportQuery(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	filter.UserID = ownerID
+
+	h.streamExport(w, r, filter, redact, adminID, fmt.Sprintf("message-export-user-%d", ownerID))
+}
+
+// parseExportQuery reads direction, from, to, format and redact from the query string.
+// from and to accept RFC3339 timestamps or dates; a date in to includes the whole day.
+func (h *SessionHandler) parseExportQuery(r *http.Request) (repository.MessageExportFilter, bool, error) {
+	query := r.URL.Query()
+	var filter repository.MessageExportFilter
+
+	if format := query.Get("format"); format != "" && format != "jsonl" {
+		return filter, false, models.NewBadRequestError("unsupported format %q, only jsonl is supported", format)
+	}
+
+	filter.Direction = query.Get("direction")
+	if filter.Direction != "" && filter.Direction != "sent" && filter.Direction != "received" {
+		return filter, false, models.NewBadRequestError("direction must be sent or received")
+	}
+
+	var err error
+	if filter.From, err = parseExportTime(query.Get("from"), false); err != nil {
+		return filter, false, models.NewBadRequestError("invalid from: %v", err)
+	}
+	if filter.To, err = parseExportTime(query.Get("to"), true); err != nil {
+		return filter, false, models.NewBadRequestError("invalid to: %v", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, false, models.NewBadRequestError("from must be before to")
+	}
+
+	redact := h.redactExports || query.Get("redact") == "true"
+	return filter, redact, nil
+}
+
+// parseExportTime parses an RFC3339 timestamp or a YYYY-MM-DD date (UTC).
+// With endOfDay a date is moved to the start of the following day.
+func parseExportTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 timestamp or YYYY-MM-DD date, got %q", value)
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// streamExport writes the matching messages as JSON Lines, page by page,
+// gzip-compressed when the client accepts it, and audit-logs the export
+func (h *SessionHandler) streamExport(w http.ResponseWriter, r *http.Request, filter repository.MessageExportFilter, redact bool, actorID int, fileName string) {
+	if h.messageRepo == nil {
+		HandleError(w, models.NewServiceUnavailableError("message history is not available"))
+		return
+	}
+
+	// Fail with a proper error if the first page cannot be read, before streaming starts
+	page, err := h.messageRepo.ExportMessages(filter, 0, exportPageSize)
+	if err != nil {
+		h.logger.Error("Failed to export messages: %v", err)
+		http.Error(w, "Failed to export messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl"`, fileName))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+
+	encoder := json.NewEncoder(out)
+	rows := 0
+	for len(page) > 0 {
+		for _, msg := range page {
+			line := exportedMessage{
+				Timestamp: models.FormatTimestamp(msg.CreatedAt),
+				SessionID: msg.SessionID,
+				Direction: msg.Direction,
+				Sender:    msg.SenderJID,
+				Recipient: msg.RecipientJID,
+				Type:      msg.MessageType,
+				Content:   msg.Content,
+				MediaURL:  msg.MediaURL,
+				MessageID: msg.MessageID,
+				Status:    msg.Status,
+				Error:     msg.ErrorMessage,
+			}
+			if redact && line.Content != "" {
+				line.Content = redactedContent
+			}
+			if err := encoder.Encode(line); err != nil {
+				h.auditExport(r, filter, redact, actorID, rows, err)
+				return
+			}
+			rows++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(page) < exportPageSize {
+			break
+		}
+		if page, err = h.messageRepo.ExportMessages(filter, page[len(page)-1].ID, exportPageSize); err != nil {
+			// Headers are already sent; the truncated export is recorded in the audit log
+			h.auditExport(r, filter, redact, actorID, rows, err)
+			return
+		}
+	}
+
+	h.auditExport(r, filter, redact, actorID, rows, nil)
+}
+
+// auditExport records who exported which messages
+func (h *SessionHandler) auditExport(r *http.Request, filter repository.MessageExportFilter, redact bool, actorID, rows int, err error) {
+	actor := int64(actorID)
+	audit := h.logger.WithContext("message_export", filter.SessionID, &actor)
+
+	scope := "session " + filter.SessionID
+	if filter.SessionID == "" {
+		scope = fmt.Sprintf("all sessions of user %d", filter.UserID)
+	}
+	params := fmt.Sprintf("direction=%q from=%q to=%q redact=%v", filter.Direction, exportTimeString(filter.From), exportTimeString(filter.To), redact)
+
+	details := map[string]interface{}{
+		"direction": filter.Direction,
+		"from":      exportTimeString(filter.From),
+		"to":        exportTimeString(filter.To),
+		"redact":    redact,
+		"rows":      rows,
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	if filter.SessionID == "" {
+		recordAudit(h.audit, r, models.AuditMessagesExported, models.AuditTargetUser, strconv.Itoa(filter.UserID), details)
+	} else {
+		recordAudit(h.audit, r, models.AuditMessagesExported, models.AuditTargetSession, filter.SessionID, details)
+	}
+
+	if err != nil {
+		audit.Error("Message export of %s by user %d failed after %d row(s) (%s): %v", scope, actorID, rows, params, err)
+		return
+	}
+	audit.Info("Message export of %s by user %d: %d row(s) (%s)", scope, actorID, rows, params)
+}
+
+func exportTimeString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return models.FormatTimestamp(t)
+}
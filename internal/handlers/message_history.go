@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// Page sizes of the message history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// GetMessageHistory handles GET /api/sessions/{sessionId}/messages, returning
+// the session's logged messages newest first, a page at a time
+func (h *SessionHandler) GetMessageHistory(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+	if h.messageRepo == nil {
+		HandleError(w, models.NewServiceUnavailableError("message history is not available"))
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.MessageHistoryFilter{
+		SessionID:   sessionID,
+		Chats:       chatSpellings(query.Get("chat")),
+		Direction:   query.Get("direction"),
+		MessageType: query.Get("message_type"),
+	}
+	if filter.Direction != "" && filter.Direction != "sent" && filter.Direction != "received" {
+		HandleError(w, models.NewBadRequestError("direction must be sent or received"))
+		return
+	}
+
+	var err error
+	if filter.From, err = parseExportTime(query.Get("from"), false); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid from: %v", err))
+		return
+	}
+	if filter.To, err = parseExportTime(query.Get("to"), true); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid to: %v", err))
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		HandleError(w, models.NewBadRequestError("from must be before to"))
+		return
+	}
+
+	var beforeID int64
+	if value := query.Get("before_id"); value != "" {
+		if beforeID, err = strconv.ParseInt(value, 10, 64); err != nil || beforeID <= 0 {
+			HandleError(w, models.NewBadRequestError("before_id must be a positive message ID"))
+			return
+		}
+	}
+	limit := defaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxHistoryLimit {
+			HandleError(w, models.NewBadRequestError("limit must be between 1 and %d", maxHistoryLimit))
+			return
+		}
+	}
+
+	// One extra row tells whether another page follows
+	messages, total, err := h.messageRepo.GetMessages(filter, beforeID, limit+1)
+	if err != nil {
+		h.logger.Error("Failed to get message history of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	resp := &models.MessageHistoryResponse{
+		Messages: make([]models.HistoryMessage, 0, len(messages)),
+		Total:    total,
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		resp.NextCursor = messages[limit-1].ID
+	}
+	for _, msg := range messages {
+		item := models.HistoryMessage{
+			ID:        msg.ID,
+			MessageID: msg.MessageID,
+			Chat:      msg.RecipientJID,
+			Sender:    msg.SenderJID,
+			Direction: msg.Direction,
+			Type:      msg.MessageType,
+			Content:   msg.Content,
+			MediaURL:  msg.MediaURL,
+			Status:    msg.Status,
+			Error:     msg.ErrorMessage,
+			Timestamp: models.FormatTimestamp(msg.CreatedAt),
+		}
+		if msg.EditedAt != nil {
+			item.EditedAt = models.FormatTimestamp(*msg.EditedAt)
+		}
+		resp.Messages = append(resp.Messages, item)
+	}
+
+	WriteSuccessResponse(w, "Messages retrieved successfully", resp)
+}
+
+// chatSpellings returns the forms a chat may be logged under. Messages sent
+// through the API used to be logged with the recipient as the client gave it,
+// so a phone number is matched with and without "+" as well as a user JID.
+func chatSpellings(chat string) []string {
+	chat = strings.TrimSpace(chat)
+	if chat == "" {
+		return nil
+	}
+
+	number := strings.TrimPrefix(chat, "+")
+	if strings.Contains(chat, "@") {
+		e164 := phone.FromJID(chat)
+		if e164 == "" {
+			return []string{chat}
+		}
+		number = strings.TrimPrefix(e164, "+")
+	}
+	return []string{number, "+" + number, number + "@s.whatsapp.net"}
+}
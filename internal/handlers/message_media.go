@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetMessageMedia handles GET /api/sessions/{sessionId}/media/{messageId}
+func (h *SessionHandler) GetMessageMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+	messageID := vars["messageId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	reader, object, fileName, err := h.whatsappService.OpenMessageMedia(r.Context(), sessionID, messageID)
+	switch err.(type) {
+	case models.NotFoundError, models.ServiceUnavailableError:
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to open media of message %s of session %s: %v", messageID, sessionID, err)
+		http.Error(w, "Failed to read media file", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	writeObject(w, r, reader, object, fileName)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetNewsletters handles GET /api/sessions/{sessionId}/newsletters
+func (h *SessionHandler) GetNewsletters(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	newsletters, err := h.whatsappService.GetNewsletters(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get newsletters for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Newsletters retrieved successfully", map[string]interface{}{
+		"newsletters": newsletters,
+		"total":       len(newsletters),
+	})
+}
+
+// FollowNewsletter handles POST /api/sessions/{sessionId}/newsletters/follow
+func (h *SessionHandler) FollowNewsletter(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.FollowNewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	newsletter, err := h.whatsappService.FollowNewsletter(sessionID, req.Newsletter)
+	if err != nil {
+		h.logger.Error("Failed to follow newsletter for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Newsletter followed successfully", newsletter)
+}
+
+// UnfollowNewsletter handles POST /api/sessions/{sessionId}/newsletters/unfollow
+func (h *SessionHandler) UnfollowNewsletter(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.FollowNewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	jid, err := h.whatsappService.UnfollowNewsletter(sessionID, req.Newsletter)
+	if err != nil {
+		h.logger.Error("Failed to unfollow newsletter for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Newsletter unfollowed successfully", map[string]interface{}{
+		"newsletter": jid,
+	})
+}
+
+// SendNewsletterMessage handles POST /api/sessions/{sessionId}/newsletters/{newsletterJid}/send
+func (h *SessionHandler) SendNewsletterMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+	newsletterJID := vars["newsletterJid"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.SendNewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	messageType, content := "text", req.Message
+	if req.File != "" {
+		messageType, content = "media", req.Caption
+		if req.Type != "" {
+			messageType = req.Type
+		}
+	}
+
+	messageID, err := h.whatsappService.SendNewsletterMessage(sessionID, newsletterJID, &req)
+	if err != nil {
+		h.logger.Error("Failed to send message to newsletter %s from session %s: %v", newsletterJID, sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	h.logMessage(sessionID, messageID, "", newsletterJID, messageType, content, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, newsletterJID, messageID, messageType)
+
+	WriteSuccessResponse(w, "Message sent to newsletter successfully", map[string]interface{}{
+		"message_id": messageID,
+		"newsletter": newsletterJID,
+	})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"whatsapp-multi-session/internal/middleware"
+)
+
+// PerfHandler serves the rolling API latency snapshot
+type PerfHandler struct {
+	metrics *middleware.RequestMetrics
+}
+
+// NewPerfHandler creates a new performance handler
+func NewPerfHandler(metrics *middleware.RequestMetrics) *PerfHandler {
+	return &PerfHandler{metrics: metrics}
+}
+
+// GetPerf handles GET /api/admin/perf
+func (h *PerfHandler) GetPerf(w http.ResponseWriter, r *http.Request) {
+	WriteSuccessResponse(w, "Performance snapshot retrieved successfully", h.metrics.Snapshot())
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"whatsapp-multi-session/internal/middleware"
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// phoneRegion returns the region used to format phone numbers for the
+// requesting user, falling back to the server default
+func phoneRegion(r *http.Request) string {
+	if claims, ok := middleware.GetUserClaims(r); ok && claims.Region != "" {
+		return claims.Region
+	}
+	return phone.DefaultRegion()
+}
+
+// formatContactPhone fills the E.164 and display forms of a contact's phone number
+func formatContactPhone(contact *models.Contact, region string) {
+	if contact == nil {
+		return
+	}
+	contact.PhoneE164, contact.PhoneDisplay = phone.Format(contact.Phone, region)
+}
+
+// formatTopContacts adds E.164 and display forms to analytics top-contact rows
+func formatTopContacts(contacts []map[string]interface{}, region string) {
+	for _, contact := range contacts {
+		jid, _ := contact["contact"].(string)
+		phoneE164, phoneDisplay := phone.Format(jid, region)
+		contact["phone_e164"] = phoneE164
+		contact["phone_display"] = phoneDisplay
+	}
+}
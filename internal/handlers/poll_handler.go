@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SendPoll handles POST /api/sessions/{sessionId}/send-poll
+func (h *SessionHandler) SendPoll(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.SendPollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.To == "" {
+		HandleError(w, models.NewBadRequestError("to is required"))
+		return
+	}
+
+	messageID, err := h.whatsappService.SendPoll(sessionID, &req)
+
+	// The question and options are logged so incoming votes can be matched to option names
+	content, _ := json.Marshal(models.PollContent{Question: req.Question, Options: req.Options})
+	if err != nil {
+		h.logger.Error("Failed to send poll from session %s: %v", sessionID, err)
+		h.logMessage(sessionID, messageID, "", req.To, "poll", string(content), "", "sent", "failed", err.Error())
+		HandleError(w, err)
+		return
+	}
+	h.logMessage(sessionID, messageID, "", req.To, "poll", string(content), "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "poll")
+
+	WriteSuccessResponse(w, "Poll sent successfully", map[string]interface{}{
+		"message_id": messageID,
+	})
+}
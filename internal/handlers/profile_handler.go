@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetProfile handles GET /api/sessions/{sessionId}/profile
+func (h *SessionHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	profile, err := h.whatsappService.GetProfile(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get profile of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Profile retrieved successfully", profile)
+}
+
+// SetProfileName handles PUT /api/sessions/{sessionId}/profile/name
+func (h *SessionHandler) SetProfileName(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.ProfileNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.whatsappService.SetProfileName(sessionID, req.Name); err != nil {
+		h.logger.Error("Failed to set profile name of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Profile name updated successfully", nil)
+}
+
+// SetProfileAbout handles PUT /api/sessions/{sessionId}/profile/about
+func (h *SessionHandler) SetProfileAbout(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.ProfileAboutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.whatsappService.SetProfileAbout(sessionID, req.About); err != nil {
+		h.logger.Error("Failed to set profile about of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Profile about updated successfully", nil)
+}
+
+// SetProfilePicture handles PUT /api/sessions/{sessionId}/profile/picture
+func (h *SessionHandler) SetProfilePicture(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.ProfilePictureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	image, err := base64.StdEncoding.DecodeString(req.Image)
+	if err != nil {
+		HandleError(w, models.NewBadRequestError("invalid base64 image data: %v", err))
+		return
+	}
+
+	pictureID, err := h.whatsappService.SetProfilePicture(sessionID, image)
+	if err != nil {
+		h.logger.Error("Failed to set profile picture of session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Profile picture updated successfully", map[string]interface{}{
+		"picture_id": pictureID,
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// Bounds of the size query parameter of QR images, in pixels
+const (
+	defaultQRImageSize = 256
+	minQRImageSize     = 64
+	maxQRImageSize     = 1024
+)
+
+// GetQRCodePNG handles GET /api/sessions/{sessionId}/qr.png
+func (h *SessionHandler) GetQRCodePNG(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	h.writeQRImage(w, r, sessionID, "png")
+}
+
+// writeQRImage writes the QR code a session is showing as a PNG image, or
+// with format base64 as JSON carrying it as a data URL. Unlike the plain QR
+// endpoint it does not start a login.
+func (h *SessionHandler) writeQRImage(w http.ResponseWriter, r *http.Request, sessionID, format string) {
+	size := defaultQRImageSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size < minQRImageSize || size > maxQRImageSize {
+			HandleError(w, models.NewBadRequestError("size must be between %d and %d pixels", minQRImageSize, maxQRImageSize))
+			return
+		}
+	}
+
+	response, err := h.whatsappService.ActiveQRCode(sessionID)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	png, err := qrcode.Encode(response.QRCode, qrcode.Medium, size)
+	if err != nil {
+		h.logger.Error("Failed to render QR code for session %s: %v", sessionID, err)
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	// Codes are replaced every 20 seconds, so no copy may be reused
+	w.Header().Set("Cache-Control", "no-store")
+	if format == "base64" {
+		response.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Write(png)
+}
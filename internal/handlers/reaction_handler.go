@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// React handles POST /api/sessions/{sessionId}/react
+func (h *SessionHandler) React(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.ReactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Chat == "" {
+		HandleError(w, models.NewBadRequestError("chat is required"))
+		return
+	}
+
+	messageID, err := h.whatsappService.SendReaction(sessionID, req.Chat, req.MessageID, req.Sender, req.Emoji)
+	if err != nil {
+		h.logger.Error("Failed to send reaction from session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	message := "Reaction sent successfully"
+	if req.Emoji == "" {
+		message = "Reaction removed successfully"
+	}
+	WriteSuccessResponse(w, message, map[string]interface{}{
+		"message_id": messageID,
+	})
+}
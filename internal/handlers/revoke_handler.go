@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// DeleteMessage handles DELETE /api/sessions/{sessionId}/messages/{messageId}.
+// chat and for are read from the query string or, if present, a JSON body.
+func (h *SessionHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	req := models.DeleteMessageRequest{
+		Chat: r.URL.Query().Get("chat"),
+		For:  r.URL.Query().Get("for"),
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.whatsappService.RevokeMessage(sessionID, vars["messageId"], &req)
+	if err != nil {
+		h.logger.Error("Failed to delete message %s of session %s: %v", vars["messageId"], sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Message deleted successfully", resp)
+}
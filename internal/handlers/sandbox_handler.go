@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// InjectSandboxMessage handles POST /api/sessions/{sessionId}/sandbox/incoming,
+// delivering a synthetic incoming message to a sandbox session so webhooks and
+// auto-replies can be exercised without WhatsApp
+func (h *SessionHandler) InjectSandboxMessage(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.SandboxIncomingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid request body: %v", err))
+		return
+	}
+	if req.From == "" {
+		HandleError(w, models.NewBadRequestError("from is required"))
+		return
+	}
+
+	resp, err := h.whatsappService.InjectSandboxMessage(sessionID, &req)
+	if err != nil {
+		h.logger.Error("Failed to inject sandbox message into session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Sandbox message delivered", resp)
+}
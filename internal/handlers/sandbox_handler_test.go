@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+func TestInjectSandboxMessage(t *testing.T) {
+	h, _, sessionID := newTestSessionHandler(t)
+	target := "/api/sessions/" + sessionID + "/sandbox/incoming"
+
+	rec := serveAs(h, testOwnerID, "POST", target, `{"from":"628123456789","text":"hello","message_id":"INJECTED1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Success bool                            `json:"success"`
+		Data    *models.SandboxIncomingResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Data == nil || resp.Data.MessageID != "INJECTED1" || resp.Data.From != "628123456789@s.whatsapp.net" {
+		t.Errorf("response = %+v", resp.Data)
+	}
+}
+
+func TestInjectSandboxMessageRejects(t *testing.T) {
+	h, _, sessionID := newTestSessionHandler(t)
+	target := "/api/sessions/" + sessionID + "/sandbox/incoming"
+
+	tests := []struct {
+		name   string
+		userID int
+		body   string
+		status int
+	}{
+		{"invalid body", testOwnerID, `{"from":`, http.StatusBadRequest},
+		{"without sender", testOwnerID, `{"text":"hello"}`, http.StatusBadRequest},
+		{"without text", testOwnerID, `{"from":"628123456789"}`, http.StatusBadRequest},
+		{"other user's session", testOtherID, `{"from":"628123456789","text":"hello"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveAs(h, tt.userID, "POST", target, tt.body); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
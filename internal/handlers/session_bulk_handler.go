@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// BulkSessions handles POST /api/sessions/bulk
+func (h *SessionHandler) BulkSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		http.Error(w, "User authentication required", http.StatusUnauthorized)
+		return
+	}
+	role, ok := r.Context().Value("role").(string)
+	if !ok {
+		http.Error(w, "User role required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.SessionBulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.whatsappService.StartSessionBulk(userID, role == "admin", &req)
+	if err != nil {
+		h.logger.Error("Failed to run bulk %s for user %d: %v", req.Action, userID, err)
+		HandleError(w, err)
+		return
+	}
+
+	if req.Action == models.SessionBulkDelete {
+		for _, result := range job.Results {
+			if result.OK {
+				recordAudit(h.audit, r, models.AuditSessionDeleted, models.AuditTargetSession, result.SessionID, map[string]interface{}{
+					"purge_data": req.PurgeData,
+					"bulk_job":   job.ID,
+				})
+			}
+		}
+	}
+
+	message := "Bulk action completed"
+	if job.Status == models.SessionBulkRunning {
+		message = "Bulk action started"
+	}
+	WriteSuccessResponse(w, message, job)
+}
+
+// GetSessionBulkJob handles GET /api/sessions/bulk/{jobId}
+func (h *SessionHandler) GetSessionBulkJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		http.Error(w, "User authentication required", http.StatusUnauthorized)
+		return
+	}
+	role, ok := r.Context().Value("role").(string)
+	if !ok {
+		http.Error(w, "User role required", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.whatsappService.GetSessionBulkJob(mux.Vars(r)["jobId"], userID, role == "admin")
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Bulk job retrieved successfully", job)
+}
@@ -5,21 +5,25 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"go.mau.fi/whatsmeow"
-	"golang.org/x/net/proxy"
 
+	"whatsapp-multi-session/internal/middleware"
 	"whatsapp-multi-session/internal/models"
 	"whatsapp-multi-session/internal/repository"
 	"whatsapp-multi-session/internal/services"
 	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/phone"
 )
 
 // SessionHandler handles session-related endpoints
@@ -29,6 +33,11 @@ type SessionHandler struct {
 	logger          *logger.Logger
 	jwtSecret       string
 	upgrader        websocket.Upgrader
+	websockets      *webSocketTracker
+	redactExports   bool
+	autoReplies     *services.AutoReplyService
+	userService     *services.UserService
+	audit           *services.AuditService
 }
 
 // NewSessionHandler creates a new session handler
@@ -69,9 +78,25 @@ func NewSessionHandler(
 		logger:          log,
 		jwtSecret:       jwtSecret,
 		upgrader:        upgrader,
+		websockets:      newWebSocketTracker(),
 	}
 }
 
+// SetWebSocketLimits sets how many WebSocket connections one user may hold
+// open: above soft a warning is logged and returned in the X-WebSocket-Warning
+// header, at hard further upgrades are rejected with 429. 0 disables a limit.
+func (h *SessionHandler) SetWebSocketLimits(soft, hard int) {
+	h.websockets.mu.Lock()
+	defer h.websockets.mu.Unlock()
+	h.websockets.softLimit = soft
+	h.websockets.hardLimit = hard
+}
+
+// GetWebSocketStats handles GET /api/admin/websockets
+func (h *SessionHandler) GetWebSocketStats(w http.ResponseWriter, r *http.Request) {
+	WriteSuccessResponse(w, "WebSocket stats retrieved successfully", h.websockets.stats())
+}
+
 // checkSessionOwnership verifies if user has access to the session
 func (h *SessionHandler) checkSessionOwnership(sessionID string, userID int, role string) error {
 	if role == "admin" {
@@ -145,26 +170,95 @@ func (h *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 	session, err := h.whatsappService.CreateSession(&req, userID, role)
 	if err != nil {
 		h.logger.Error("Failed to create session: %v", err)
+		switch err.(type) {
+		case models.BadRequestError, models.ForbiddenError, models.SessionLimitError:
+			HandleError(w, err)
+			return
+		}
 		HandleErrorWithMessage(w, http.StatusInternalServerError, err.Error(), models.ErrCodeInternalServer)
 		return
 	}
 
+	recordAudit(h.audit, r, models.AuditSessionCreated, models.AuditTargetSession, session.ID, map[string]interface{}{
+		"name": session.Name,
+	})
+
 	// Convert to response
+	response := toSessionResponse(session, phoneRegion(r))
+
+	WriteSuccessResponse(w, "Session created successfully", response)
+}
+
+// toSessionResponse converts a session into its API representation, adding
+// E.164 and display forms of the connected phone number
+func toSessionResponse(session *models.Session, region string) *models.SessionResponse {
+	phoneE164, phoneDisplay := phone.Format(session.ActualPhone, region)
+
+	webhookProxyURL := session.WebhookProxyURL
+	if parsed, err := url.Parse(webhookProxyURL); err == nil && webhookProxyURL != "" {
+		webhookProxyURL = parsed.Redacted()
+	}
+
+	state := session.State()
 	response := &models.SessionResponse{
 		ID:            session.ID,
 		Phone:         session.Phone,
 		ActualPhone:   session.ActualPhone,
+		PhoneE164:     phoneE164,
+		PhoneDisplay:  phoneDisplay,
 		Name:          session.Name,
 		Position:      session.Position,
+		Tags:          session.Tags,
 		WebhookURL:    session.WebhookURL,
-		AutoReplyText: session.AutoReplyText,
+		WebhookProxyURL: webhookProxyURL,
+		WebhookLegacyFormat: session.WebhookLegacyFormat,
+		HasWebhookSecret: session.WebhookSecret != "",
+		ReceiveReceipts: session.ReceiveReceipts,
+		ReceiveStatus: session.ReceiveStatus,
+		AllowViewOnceCapture: session.AllowViewOnceCapture,
+		PersistMedia:  session.PersistMedia,
+		WebhookEvents: session.WebhookEvents,
+		WebhookSuspended: session.WebhookSuspendedAt != nil,
+		Banned:        session.BannedUntil != nil,
+		BanReason:     session.BanReason,
+		SendDefaults:  models.ResolveSendOptions(nil, session.SendDefaults),
+		NewContactDetection: session.NewContactSince != nil,
+		NewContactCreateContact: session.NewContactCreateContact,
+		AutoRepliesEnabled: session.AutoRepliesEnabled,
+		AutoReplyText: session.AutoReply(),
 		ProxyConfig:   session.ProxyConfig,
+		ProxyStatus:   session.ProxyStatus,
 		Enabled:       session.Enabled,
-		Connected:     session.Connected,
-		LoggedIn:      session.LoggedIn,
+		Sandbox:       session.Sandbox,
+		Connected:     state.Connected,
+		LoggedIn:      state.LoggedIn,
+		State:         session.AuthState(),
+		ConnectionStatus: state.ConnectionStatus(),
+		LastDisconnectReason: state.LastDisconnectReason,
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	if !state.LastConnectedAt.IsZero() {
+		response.LastConnectedAt = models.FormatTimestamp(state.LastConnectedAt)
+	}
+	if !session.CreatedAt.IsZero() {
+		response.CreatedAt = models.FormatTimestamp(session.CreatedAt)
+	}
+	if session.WebhookSecret != "" {
+		response.WebhookSignature = models.WebhookSignatureScheme
+	}
+	if session.WebhookSuspendedAt != nil {
+		response.WebhookSuspendedAt = models.FormatTimestamp(*session.WebhookSuspendedAt)
+	}
+	if session.BannedUntil != nil {
+		response.BannedUntil = models.FormatTimestamp(*session.BannedUntil)
+	}
+	if session.NewContactSince != nil {
+		response.NewContactSince = models.FormatTimestamp(*session.NewContactSince)
 	}
 
-	WriteSuccessResponse(w, "Session created successfully", response)
+	return response
 }
 
 // GetSessions handles getting all sessions
@@ -197,27 +291,76 @@ func (h *SessionHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Convert to response format
-	responses := make([]*models.SessionResponse, len(sessions))
-	for i, session := range sessions {
-		responses[i] = &models.SessionResponse{
-			ID:            session.ID,
-			Phone:         session.Phone,
-			ActualPhone:   session.ActualPhone,
-			Name:          session.Name,
-			Position:      session.Position,
-			WebhookURL:    session.WebhookURL,
-			AutoReplyText: session.AutoReplyText,
-			ProxyConfig:   session.ProxyConfig,
-			Enabled:       session.Enabled,
-			Connected:     session.Connected,
-			LoggedIn:      session.LoggedIn,
+	query, err := sessionQuery(r)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	// API keys limited to some sessions only see those
+	info, _ := middleware.GetAuthInfo(r)
+	visible := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if info != nil && !info.SessionAllowed(session.ID) {
+			continue
 		}
+		visible = append(visible, session)
+	}
+
+	page, total, err := services.QuerySessions(visible, query)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	// Convert to response format
+	responses := make([]*models.SessionResponse, 0, len(page))
+	for _, session := range page {
+		responses = append(responses, toSessionResponse(session, phoneRegion(r)))
 	}
 
+	// The list stays the response data; the count of every page is a header
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	WriteSuccessResponse(w, "Sessions retrieved successfully", responses)
 }
 
+// maxSessionLimit is the largest page of sessions
+const maxSessionLimit = 500
+
+// sessionQuery reads the filters, order and page of GET /sessions
+func sessionQuery(r *http.Request) (*models.SessionQuery, error) {
+	values := r.URL.Query()
+	query := &models.SessionQuery{
+		Tags:   values["tag"],
+		State:  values.Get("state"),
+		Search: values.Get("search"),
+		Sort:   values.Get("sort"),
+		Page:   1,
+	}
+	if value := values.Get("enabled"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, models.NewBadRequestError("enabled must be true or false")
+		}
+		query.Enabled = &enabled
+	}
+	if value := values.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return nil, models.NewBadRequestError("page must be a positive number")
+		}
+		query.Page = page
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSessionLimit {
+			return nil, models.NewBadRequestError("limit must be between 1 and %d", maxSessionLimit)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
 // GetSession handles getting a specific session
 func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -250,18 +393,10 @@ func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := &models.SessionResponse{
-		ID:            session.ID,
-		Phone:         session.Phone,
-		ActualPhone:   session.ActualPhone,
-		Name:          session.Name,
-		Position:      session.Position,
-		WebhookURL:    session.WebhookURL,
-		AutoReplyText: session.AutoReplyText,
-		ProxyConfig:   session.ProxyConfig,
-		Enabled:       session.Enabled,
-		Connected:     session.Connected,
-		LoggedIn:      session.LoggedIn,
+	response := toSessionResponse(session, phoneRegion(r))
+	if version, err := h.whatsappService.GetSessionVersion(sessionID); err == nil {
+		response.Version = version
+		setVersionHeader(w, version)
 	}
 
 	WriteSuccessResponse(w, "Session retrieved successfully", response)
@@ -351,12 +486,17 @@ func (h *SessionHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.whatsappService.DeleteSession(sessionID); err != nil {
+	purgeData := r.URL.Query().Get("purge_data") == "true"
+	if err := h.whatsappService.DeleteSession(sessionID, purgeData); err != nil {
 		h.logger.Error("Failed to delete session %s: %v", sessionID, err)
 		HandleError(w, err)
 		return
 	}
 
+	recordAudit(h.audit, r, models.AuditSessionDeleted, models.AuditTargetSession, sessionID, map[string]interface{}{
+		"purge_data": purgeData,
+	})
+
 	WriteSuccessResponse(w, "Session deleted successfully", nil)
 }
 
@@ -370,21 +510,69 @@ func (h *SessionHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	qrCode, err := h.whatsappService.GetQRCode(sessionID)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "png", "base64":
+		h.writeQRImage(w, r, sessionID, format)
+		return
+	default:
+		HandleError(w, models.NewBadRequestError("format must be json, png or base64"))
+		return
+	}
+
+	response, err := h.whatsappService.GetQRCode(sessionID)
+	switch err.(type) {
+	case models.NotReadyError, models.BadRequestError, models.NotFoundError:
+		HandleError(w, err)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to get QR code for session %s: %v", sessionID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	response := &models.QRResponse{
-		QRCode: qrCode,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// RequestPairCode handles POST /api/sessions/{sessionId}/pair-code, an
+// alternative to scanning the QR code
+func (h *SessionHandler) RequestPairCode(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.PairCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		HandleError(w, models.NewBadRequestError("invalid request body: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Phone) == "" {
+		HandleError(w, models.NewBadRequestError("phone is required"))
+		return
+	}
+
+	// Numbers without a country code are read in the user's region
+	number, err := phone.Parse(req.Phone, phoneRegion(r))
+	if err != nil {
+		HandleError(w, models.NewBadRequestError("invalid phone number %q: %v", req.Phone, err))
+		return
+	}
+
+	code, err := h.whatsappService.PairPhone(sessionID, number.E164)
+	if err != nil {
+		h.logger.Error("Failed to request pairing code for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Pairing code generated, enter it on the phone under Linked devices", code)
+}
+
 // UpdateSession handles session updates
 func (h *SessionHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -401,14 +589,40 @@ func (h *SessionHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.whatsappService.UpdateSession(sessionID, &req); err != nil {
-		h.logger.Error("Failed to update session %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	expected, err := expectedVersion(r, req.ExpectedVersion)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	req.ExpectedVersion = expected
+
+	version, err := h.whatsappService.UpdateSession(sessionID, &req)
+	if err != nil {
+		h.writeSessionUpdateError(w, r, sessionID, err)
 		return
 	}
 
+	setVersionHeader(w, version)
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Session updated"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Session updated", "version": version})
+}
+
+// writeSessionUpdateError reports a failed session update. A version
+// conflict carries the session's current settings so the client can merge.
+func (h *SessionHandler) writeSessionUpdateError(w http.ResponseWriter, r *http.Request, sessionID string, err error) {
+	conflict, ok := err.(models.ConflictError)
+	if !ok {
+		h.logger.Error("Failed to update session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	if session, exists := h.whatsappService.GetSession(sessionID); exists {
+		current := toSessionResponse(session, phoneRegion(r))
+		current.Version = conflict.CurrentVersion
+		conflict.Current = current
+	}
+	HandleError(w, conflict)
 }
 
 // SendMessage handles sending text messages
@@ -451,6 +665,7 @@ func (h *SessionHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Log successful message
 	h.logMessage(sessionID, messageID, "", req.To, "text", req.Message, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "text")
 
 	WriteSuccessResponse(w, "Message sent successfully", map[string]interface{}{
 		"message_id": messageID,
@@ -504,6 +719,7 @@ func (h *SessionHandler) SendLocation(w http.ResponseWriter, r *http.Request) {
 	// Log successful location message
 	locationContent := fmt.Sprintf("Location: %f, %f", req.Latitude, req.Longitude)
 	h.logMessage(sessionID, messageID, "", req.To, "location", locationContent, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "location")
 
 	WriteSuccessResponse(w, "Location sent successfully", map[string]interface{}{
 		"message_id": messageID,
@@ -544,12 +760,13 @@ func (h *SessionHandler) SendAttachment(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to send attachment from session %s: %v", sessionID, err)
 		// Log failed attachment
 		h.logMessage(sessionID, messageID, "", req.To, "document", req.Caption, req.File, "sent", "failed", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSendError(w, err)
 		return
 	}
 
 	// Log successful attachment
 	h.logMessage(sessionID, messageID, "", req.To, "document", req.Caption, req.File, "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "document")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -587,12 +804,13 @@ func (h *SessionHandler) SendFileFromURL(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to send file from URL for session %s: %v", sessionID, err)
 		// Log failed file URL
 		h.logMessage(sessionID, messageID, "", req.To, "document", req.Caption, req.URL, "sent", "failed", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSendError(w, err)
 		return
 	}
 
 	// Log successful file URL
 	h.logMessage(sessionID, messageID, "", req.To, "document", req.Caption, req.URL, "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "document")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -630,12 +848,13 @@ func (h *SessionHandler) SendImage(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to send image from session %s: %v", sessionID, err)
 		// Log failed image
 		h.logMessage(sessionID, messageID, "", req.To, "image", req.Caption, req.Image, "sent", "failed", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSendError(w, err)
 		return
 	}
 
 	// Log successful image
 	h.logMessage(sessionID, messageID, "", req.To, "image", req.Caption, req.Image, "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "image")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -677,10 +896,14 @@ func (h *SessionHandler) ForwardMessage(w http.ResponseWriter, r *http.Request)
 	messageID, err := h.whatsappService.ForwardMessage(sessionID, &req)
 	if err != nil {
 		h.logger.Error("Failed to forward message from session %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.logMessage(sessionID, messageID, "", req.To, "text", req.Text, "", "sent", "failed", err.Error())
+		writeSendError(w, err)
 		return
 	}
 
+	h.logMessage(sessionID, messageID, "", req.To, "text", req.Text, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "text")
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -723,12 +946,13 @@ func (h *SessionHandler) ReplyMessage(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to reply to message from session %s: %v", sessionID, err)
 		// Log failed reply
 		h.logMessage(sessionID, messageID, "", req.To, "text", req.Message, "", "sent", "failed", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSendError(w, err)
 		return
 	}
 
 	// Log successful reply
 	h.logMessage(sessionID, messageID, "", req.To, "text", req.Message, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "text")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -781,6 +1005,41 @@ func (h *SessionHandler) CheckNumber(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// CheckNumbers handles POST /api/sessions/{sessionId}/check-numbers
+func (h *SessionHandler) CheckNumbers(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.CheckNumbersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.whatsappService.CheckNumbers(sessionID, req.Numbers, phoneRegion(r))
+	if err != nil {
+		h.logger.Error("Failed to check numbers from session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	registered := 0
+	for _, result := range results {
+		if result.Exists {
+			registered++
+		}
+	}
+	WriteSuccessResponse(w, "Numbers checked", map[string]interface{}{
+		"results":    results,
+		"total":      len(results),
+		"registered": registered,
+	})
+}
+
 // SendTyping handles sending typing indicator
 func (h *SessionHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -792,7 +1051,8 @@ func (h *SessionHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		To string `json:"to"`
+		To              string `json:"to"`
+		DurationSeconds int    `json:"duration_seconds,omitempty"` // Stop typing by itself after this long
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -804,9 +1064,10 @@ func (h *SessionHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.whatsappService.SendTyping(sessionID, req.To, true); err != nil {
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if err := h.whatsappService.SendTyping(sessionID, req.To, true, duration); err != nil {
 		h.logger.Error("Failed to send typing indicator from session %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		HandleError(w, err)
 		return
 	}
 
@@ -837,7 +1098,7 @@ func (h *SessionHandler) StopTyping(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.whatsappService.SendTyping(sessionID, req.To, false); err != nil {
+	if err := h.whatsappService.SendTyping(sessionID, req.To, false, 0); err != nil {
 		h.logger.Error("Failed to stop typing indicator from session %s: %v", sessionID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -925,6 +1186,218 @@ func (h *SessionHandler) GetGroups(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetBlocklist handles GET /api/sessions/{sessionId}/blocklist
+func (h *SessionHandler) GetBlocklist(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	jids, err := h.whatsappService.GetBlocklist(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get blocklist for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Blocklist retrieved successfully", map[string]interface{}{
+		"blocked": jids,
+		"count":   len(jids),
+	})
+}
+
+// BlockContact handles POST /api/sessions/{sessionId}/blocklist
+func (h *SessionHandler) BlockContact(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req struct {
+		JID string `json:"jid"` // JID or phone number
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.JID == "" {
+		http.Error(w, "jid is required", http.StatusBadRequest)
+		return
+	}
+
+	h.updateBlocklist(w, sessionID, req.JID, true)
+}
+
+// UnblockContact handles DELETE /api/sessions/{sessionId}/blocklist/{jid}
+func (h *SessionHandler) UnblockContact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	h.updateBlocklist(w, sessionID, vars["jid"], false)
+}
+
+// updateBlocklist blocks or unblocks a contact and writes the updated blocklist
+func (h *SessionHandler) updateBlocklist(w http.ResponseWriter, sessionID, target string, block bool) {
+	jids, err := h.whatsappService.UpdateBlocklist(sessionID, target, block)
+	if err != nil {
+		h.logger.Error("Failed to update blocklist for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	message := "Contact unblocked successfully"
+	if block {
+		message = "Contact blocked successfully"
+	}
+
+	WriteSuccessResponse(w, message, map[string]interface{}{
+		"blocked": jids,
+		"count":   len(jids),
+	})
+}
+
+// GetBlockedSuspects handles GET /api/sessions/{sessionId}/blocked-suspects
+func (h *SessionHandler) GetBlockedSuspects(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	if h.messageRepo == nil {
+		HandleError(w, models.NewServiceUnavailableError("message logging is not available"))
+		return
+	}
+
+	suspects, err := h.messageRepo.GetBlockedSuspects(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get blocked suspects for session %s: %v", sessionID, err)
+		http.Error(w, "Failed to get blocked suspects", http.StatusInternalServerError)
+		return
+	}
+
+	WriteSuccessResponse(w, "Blocked suspects retrieved successfully", map[string]interface{}{
+		"suspects": suspects,
+		"count":    len(suspects),
+	})
+}
+
+// GetLabels handles GET /api/sessions/{sessionId}/labels
+func (h *SessionHandler) GetLabels(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	refresh := r.URL.Query().Get("refresh") == "true"
+	labels, business, err := h.whatsappService.GetLabels(sessionID, refresh)
+	if err != nil {
+		h.logger.Error("Failed to get labels for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"labels":   labels,
+		"count":    len(labels),
+		"business": business,
+	}
+	if !business {
+		response["note"] = "Labels are only available on WhatsApp Business accounts"
+	}
+
+	WriteSuccessResponse(w, "Labels retrieved successfully", response)
+}
+
+// GetChatLabels handles GET /api/sessions/{sessionId}/chats/{jid}/labels
+func (h *SessionHandler) GetChatLabels(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	labels, err := h.whatsappService.GetChatLabels(sessionID, vars["jid"])
+	if err != nil {
+		h.logger.Error("Failed to get chat labels for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Chat labels retrieved successfully", map[string]interface{}{
+		"labels": labels,
+		"count":  len(labels),
+	})
+}
+
+// AddChatLabel handles POST /api/sessions/{sessionId}/chats/{jid}/labels
+func (h *SessionHandler) AddChatLabel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.ChatLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.setChatLabel(w, sessionID, vars["jid"], &req, true)
+}
+
+// RemoveChatLabel handles DELETE /api/sessions/{sessionId}/chats/{jid}/labels/{labelId}
+func (h *SessionHandler) RemoveChatLabel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	h.setChatLabel(w, sessionID, vars["jid"], &models.ChatLabelRequest{LabelID: vars["labelId"]}, false)
+}
+
+// setChatLabel assigns or removes a chat label and writes the result
+func (h *SessionHandler) setChatLabel(w http.ResponseWriter, sessionID, chat string, req *models.ChatLabelRequest, labeled bool) {
+	label, err := h.whatsappService.SetChatLabel(sessionID, chat, req, labeled)
+	if err != nil {
+		h.logger.Error("Failed to update chat label for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	message := "Label removed successfully"
+	if labeled {
+		message = "Label assigned successfully"
+	}
+
+	WriteSuccessResponse(w, message, map[string]interface{}{
+		"chat":    chat,
+		"label":   label,
+		"labeled": labeled,
+	})
+}
+
 // SendMessageGeneral handles sending messages via API with phone selection (for compatibility)
 func (h *SessionHandler) SendMessageGeneral(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -932,6 +1405,7 @@ func (h *SessionHandler) SendMessageGeneral(w http.ResponseWriter, r *http.Reque
 		SessionID string `json:"session_id"` // Legacy field for backward compatibility
 		To        string `json:"to"`         // Recipient
 		Message   string `json:"message"`    // Message content
+		models.SendOptions
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -959,8 +1433,9 @@ func (h *SessionHandler) SendMessageGeneral(w http.ResponseWriter, r *http.Reque
 
 	// Create message request
 	msgReq := &models.SendMessageRequest{
-		To:      req.To,
-		Message: req.Message,
+		To:          req.To,
+		Message:     req.Message,
+		SendOptions: req.SendOptions,
 	}
 
 	// Send message
@@ -976,10 +1451,13 @@ func (h *SessionHandler) SendMessageGeneral(w http.ResponseWriter, r *http.Reque
 	h.logger.Info("API message sent successfully with ID: %s", messageID)
 	// Log successful message
 	h.logMessage(sessionID, messageID, "", req.To, "text", req.Message, "", "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "text")
 
+	now := time.Now()
 	WriteSuccessResponse(w, "Message sent successfully", map[string]interface{}{
 		"message_id": messageID,
-		"timestamp":  time.Now().Unix(),
+		"timestamp":  now.Unix(),
+		"sent_at":    models.FormatTimestamp(now),
 		"session":    sessionID,
 	})
 }
@@ -1016,6 +1494,18 @@ func (h *SessionHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if h.userService != nil {
+		issuedAt, _ := claims.GetIssuedAt()
+		var issued time.Time
+		if issuedAt != nil {
+			issued = issuedAt.Time
+		}
+		if err := h.userService.CheckAccessToken(int(userID), issued); err != nil {
+			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Check session ownership
 	if err := h.checkSessionOwnership(sessionID, int(userID), role); err != nil {
 		h.logger.Error("WebSocket session ownership check failed: %v", err)
@@ -1030,60 +1520,79 @@ func (h *SessionHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Limit open connections per user; released on every exit, including panics
+	count, ok := h.websockets.acquire(int(userID), sessionID)
+	if !ok {
+		h.logger.Warn("Rejecting WebSocket for session %s: user %d already has %d open connections", sessionID, int(userID), count)
+		http.Error(w, fmt.Sprintf("Too many open WebSocket connections (%d)", count), http.StatusTooManyRequests)
+		return
+	}
+	defer h.websockets.release(int(userID), sessionID)
+
+	var responseHeader http.Header
+	if h.websockets.overSoftLimit(count) {
+		h.logger.Warn("User %d has %d open WebSocket connections (soft limit %d); a client may be leaking connections", int(userID), count, h.websockets.softLimit)
+		responseHeader = http.Header{}
+		responseHeader.Set("X-WebSocket-Warning", fmt.Sprintf("%d open connections exceed the soft limit of %d", count, h.websockets.softLimit))
+	}
+
 	// Upgrade connection to WebSocket
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		h.logger.Error("Failed to upgrade WebSocket connection: %v", err)
 		return
 	}
 	defer conn.Close()
 
+	ws := &wsWriter{conn: conn}
+
 	h.logger.Info("WebSocket connection established for session %s", sessionID)
 
-	// Start QR code streaming if not logged in
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	if !session.LoggedIn {
-		h.logger.Info("Starting QR code generation for unauthenticated session %s", sessionID)
-		
-		// Disconnect if already connected but not logged in (like original implementation)
-		if session.Connected && session.Client.IsConnected() && !session.Client.IsLoggedIn() {
-			h.logger.Info("Disconnecting stale connection for session %s", sessionID)
-			session.Client.Disconnect()
-			// Give it a moment to disconnect cleanly
-			time.Sleep(1 * time.Second)
-		}
+	// Lifecycle events of the session are streamed for as long as the socket is open
+	statuses, unsubscribeStatus := h.whatsappService.SubscribeSessionStatus(sessionID)
+	defer unsubscribeStatus()
+	go h.streamSessionStatus(ctx, ws, statuses, sessionID)
 
-		// Get QR channel before connecting (like original implementation)
-		h.logger.Debug("Getting QR channel for session %s", sessionID)
-		qrChan, err := session.Client.GetQRChannel(ctx)
-		if err != nil {
-			h.logger.Error("Failed to get QR channel for session %s: %v", sessionID, err)
-			conn.WriteJSON(models.WebSocketMessage{
+	// Start QR code streaming if not logged in
+
+	if !session.IsLoggedIn() && session.Sandbox {
+		// Sandbox sessions log in without a QR code
+		if err := h.whatsappService.ConnectSession(sessionID); err != nil {
+			h.logger.Error("Failed to connect sandbox session %s: %v", sessionID, err)
+			ws.WriteJSON(models.WebSocketMessage{
 				Type: "error",
-				Data: map[string]string{"error": "Failed to get QR channel: " + err.Error()},
+				Data: map[string]string{"error": "Failed to connect: " + err.Error()},
 			})
 			return
 		}
+		if err := ws.WriteJSON(models.WebSocketMessage{
+			Type: "success",
+			Data: map[string]string{"message": "Login successful"},
+		}); err != nil {
+			return
+		}
+	} else if !session.IsLoggedIn() {
+		// Every socket of the session follows the same QR login, started by
+		// whichever comes first
+		qrEvents, unsubscribeQR := h.whatsappService.SubscribeQR(sessionID)
+		defer unsubscribeQR()
 
-		// Start QR code streaming
-		h.logger.Debug("Starting QR code streaming for session %s", sessionID)
-		go h.streamQRUpdatesFromChannel(ctx, conn, qrChan, sessionID)
+		// Pairing codes requested over REST or this socket are shown alongside the QR
+		pairCodes, unsubscribe := h.whatsappService.SubscribePairCodes(sessionID)
+		defer unsubscribe()
 
-		// Now connect after getting QR channel (only if not already connected)
-		if !session.Connected {
-			h.logger.Info("Connecting session %s for QR generation", sessionID)
-			if err := h.whatsappService.ConnectSession(sessionID); err != nil {
-				h.logger.Error("Failed to connect session %s: %v", sessionID, err)
-				conn.WriteJSON(models.WebSocketMessage{
-					Type: "error",
-					Data: map[string]string{"error": "Failed to connect: " + err.Error()},
-				})
-				return
-			}
-		} else {
-			h.logger.Info("Session %s already connected, starting QR generation", sessionID)
+		go h.streamQRUpdates(ctx, ws, qrEvents, pairCodes, sessionID)
+
+		if err := h.whatsappService.StartQRLogin(sessionID); err != nil {
+			h.logger.Error("Failed to start QR login for session %s: %v", sessionID, err)
+			ws.WriteJSON(models.WebSocketMessage{
+				Type: "error",
+				Data: map[string]string{"error": "Failed to connect: " + err.Error()},
+			})
+			return
 		}
 	} else {
 		h.logger.Info("Session %s already logged in, no QR needed", sessionID)
@@ -1104,10 +1613,23 @@ func (h *SessionHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request
 		// Handle different message types
 		switch msg.Type {
 		case "ping":
-			if err := conn.WriteJSON(models.WebSocketMessage{Type: "pong"}); err != nil {
+			if err := ws.WriteJSON(models.WebSocketMessage{Type: "pong"}); err != nil {
 				h.logger.Error("WebSocket pong error for session %s: %v", sessionID, err)
 				return
 			}
+		case "pair_code":
+			// {"type": "pair_code", "data": {"phone": "..."}}; the code arrives as a "pair_code" message
+			data, _ := msg.Data.(map[string]interface{})
+			phoneNumber, _ := data["phone"].(string)
+			if _, err := h.whatsappService.PairPhone(sessionID, phoneNumber); err != nil {
+				h.logger.Warn("WebSocket pairing code request failed for session %s: %v", sessionID, err)
+				if err := ws.WriteJSON(models.WebSocketMessage{
+					Type: "error",
+					Data: map[string]string{"error": "Failed to get pairing code: " + err.Error()},
+				}); err != nil {
+					return
+				}
+			}
 		default:
 			h.logger.Debug("Received WebSocket message type %s for session %s", msg.Type, sessionID)
 		}
@@ -1118,12 +1640,14 @@ func (h *SessionHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request
 	// Run this in a goroutine with delay to avoid interfering with immediate reconnections
 	go func() {
 		if session, exists := h.whatsappService.GetSession(sessionID); exists {
-			if !session.LoggedIn && session.Connected {
+			if !session.IsLoggedIn() && session.IsConnected() {
 				// Give a delay to allow for immediate reconnection attempts
 				time.Sleep(5 * time.Second)
 				
-				// Check again after delay - if still not logged in, disconnect
-				if session, exists := h.whatsappService.GetSession(sessionID); exists && !session.LoggedIn && session.Connected {
+				// Check again after delay - if still not logged in and no other socket
+				// follows the QR login, disconnect
+				if session, exists := h.whatsappService.GetSession(sessionID); exists && !session.IsLoggedIn() && session.IsConnected() &&
+					!h.whatsappService.HasQRSubscribers(sessionID) {
 					h.logger.Info("WebSocket closed for unauthenticated session %s, disconnecting after delay", sessionID)
 					if err := h.whatsappService.DisconnectSession(sessionID); err != nil {
 						h.logger.Error("Failed to disconnect unauthenticated session %s: %v", sessionID, err)
@@ -1167,7 +1691,7 @@ func (h *SessionHandler) logMessage(sessionID, messageID, senderJID, recipientJI
 		SessionID:    sessionID,
 		MessageID:    messageID,
 		SenderJID:    senderJID,
-		RecipientJID: recipientJID,
+		RecipientJID: services.ChatJID(recipientJID),
 		MessageType:  messageType,
 		Content:      content,
 		MediaURL:     mediaURL,
@@ -1260,23 +1784,167 @@ func (h *SessionHandler) UpdateSessionWebhook(w http.ResponseWriter, r *http.Req
 	}
 
 	var req struct {
-		WebhookURL string `json:"webhook_url"`
+		WebhookURL    string  `json:"webhook_url"`
+		WebhookSecret *string `json:"webhook_secret,omitempty"` // Omit to keep the current secret, empty to stop signing
+		WebhookEvents *[]string `json:"webhook_events,omitempty"` // Omit to keep the current events, empty to send all
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.whatsappService.UpdateSessionWebhook(sessionID, req.WebhookURL); err != nil {
+	if err := h.whatsappService.UpdateSessionWebhook(sessionID, req.WebhookURL, req.WebhookSecret, req.WebhookEvents); err != nil {
 		h.logger.Error("Failed to update session webhook %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		HandleError(w, err)
 		return
 	}
 
+	// The secret itself stays out of the audit log
+	details := map[string]interface{}{
+		"webhook_url":    req.WebhookURL,
+		"secret_changed": req.WebhookSecret != nil,
+	}
+	if req.WebhookEvents != nil {
+		details["webhook_events"] = *req.WebhookEvents
+	}
+	recordAudit(h.audit, r, models.AuditWebhookChanged, models.AuditTargetSession, sessionID, details)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook updated successfully"})
 }
 
+// ResumeWebhook handles POST /api/sessions/{sessionId}/webhook/resume
+func (h *SessionHandler) ResumeWebhook(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	userID, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	// The body is optional; an empty body resumes without replay
+	var req struct {
+		Replay bool `json:"replay"` // Re-send events stored while the webhook was failing
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.whatsappService.ResumeWebhook(sessionID, userID, req.Replay); err != nil {
+		h.logger.Error("Failed to resume webhook for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditWebhookResumed, models.AuditTargetSession, sessionID, map[string]interface{}{
+		"replay": req.Replay,
+	})
+
+	WriteSuccessResponse(w, "Webhook resumed successfully", map[string]interface{}{
+		"session_id": sessionID,
+		"replaying":  req.Replay,
+	})
+}
+
+// UpdateSendDefaults handles replacing a session's default send options
+func (h *SessionHandler) UpdateSendDefaults(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Unknown keys are rejected so a typo is not silently ignored
+	defaults, err := models.ParseSendOptions(body)
+	if err != nil {
+		HandleError(w, models.NewBadRequestError("%v", err))
+		return
+	}
+
+	if err := h.whatsappService.UpdateSendDefaults(sessionID, defaults); err != nil {
+		h.logger.Error("Failed to update send defaults for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Send defaults updated successfully", map[string]interface{}{
+		"session_id":    sessionID,
+		"send_defaults": models.ResolveSendOptions(nil, defaults),
+	})
+}
+
+// UpdateSessionTags handles replacing the tags of a session
+func (h *SessionHandler) UpdateSessionTags(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.UpdateSessionTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.whatsappService.UpdateSessionTags(sessionID, req.Tags)
+	if err != nil {
+		h.logger.Error("Failed to update tags for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Session tags updated successfully", map[string]interface{}{
+		"session_id": sessionID,
+		"tags":       tags,
+	})
+}
+
+// UpdateNewContactDetection handles enabling or disabling first-contact detection
+func (h *SessionHandler) UpdateNewContactDetection(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req struct {
+		Enabled       bool `json:"enabled"`
+		CreateContact bool `json:"create_contact"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	backfilled, err := h.whatsappService.ConfigureNewContactDetection(sessionID, req.Enabled, req.CreateContact)
+	if err != nil {
+		h.logger.Error("Failed to update new contact detection for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "New contact detection updated successfully", map[string]interface{}{
+		"session_id":              sessionID,
+		"enabled":                 req.Enabled,
+		"create_contact":          req.CreateContact,
+		"known_contacts_recorded": backfilled,
+	})
+}
+
 // UpdateSessionName handles updating session name
 func (h *SessionHandler) UpdateSessionName(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -1288,7 +1956,8 @@ func (h *SessionHandler) UpdateSessionName(w http.ResponseWriter, r *http.Reques
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name            string `json:"name"`
+		ExpectedVersion *int64 `json:"expected_version,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -1300,18 +1969,26 @@ func (h *SessionHandler) UpdateSessionName(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	expected, err := expectedVersion(r, req.ExpectedVersion)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
 	updateReq := &models.UpdateSessionRequest{
-		Name: req.Name,
+		Name:            req.Name,
+		ExpectedVersion: expected,
 	}
 
-	if err := h.whatsappService.UpdateSession(sessionID, updateReq); err != nil {
-		h.logger.Error("Failed to update session name %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	version, err := h.whatsappService.UpdateSession(sessionID, updateReq)
+	if err != nil {
+		h.writeSessionUpdateError(w, r, sessionID, err)
 		return
 	}
 
+	setVersionHeader(w, version)
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Session name updated successfully"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Session name updated successfully", "version": version})
 }
 
 // UpdateSessionAutoReply updates the auto reply text for a session
@@ -1342,6 +2019,67 @@ func (h *SessionHandler) UpdateSessionAutoReply(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(map[string]string{"message": "Session auto reply updated successfully"})
 }
 
+// SetUserService makes WebSocket connections reject revoked tokens
+func (h *SessionHandler) SetUserService(userService *services.UserService) {
+	h.userService = userService
+}
+
+// SetAuditService records session changes and sent messages in the audit log
+func (h *SessionHandler) SetAuditService(audit *services.AuditService) {
+	h.audit = audit
+}
+
+// SetAutoReplyService enables the auto-reply rule toggle of sessions
+func (h *SessionHandler) SetAutoReplyService(autoReplies *services.AutoReplyService) {
+	h.autoReplies = autoReplies
+}
+
+// UpdateSessionAutoRepliesEnabled turns the auto-reply rules of a session on or off
+func (h *SessionHandler) UpdateSessionAutoRepliesEnabled(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	if h.autoReplies == nil {
+		HandleError(w, models.NewServiceUnavailableError("auto-reply rules are not available"))
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if req.Enabled {
+		err = h.autoReplies.EnableAutoRepliesForSession(sessionID)
+	} else {
+		err = h.autoReplies.DisableAutoRepliesForSession(sessionID)
+	}
+	if _, ok := err.(models.NotFoundError); ok {
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to update auto replies of session %s: %v", sessionID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id":           sessionID,
+		"auto_replies_enabled": req.Enabled,
+	})
+}
+
 // checkSessionEnabled checks if a session is enabled before allowing operations
 func (h *SessionHandler) checkSessionEnabled(sessionID string) error {
 	session, exists := h.whatsappService.GetSession(sessionID)
@@ -1404,27 +2142,58 @@ func (h *SessionHandler) UpdateSessionProxy(w http.ResponseWriter, r *http.Reque
 
 	var req struct {
 		ProxyConfig *models.ProxyConfig `json:"proxy_config"`
+		Verify      bool                `json:"verify"` // Test the proxy live before saving it
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.ProxyConfig == nil {
+		http.Error(w, "proxy_config is required", http.StatusBadRequest)
+		return
+	}
 
-	updateReq := &models.UpdateSessionRequest{
-		ProxyConfig: req.ProxyConfig,
+	// Nothing is saved unless the proxy passes validation (and the live test, if asked for)
+	if err := h.whatsappService.ValidateProxy(r.Context(), req.ProxyConfig); err != nil {
+		HandleError(w, err)
+		return
 	}
 
-	if err := h.whatsappService.UpdateSession(sessionID, updateReq); err != nil {
+	var verification *models.ProxyVerification
+	if req.Verify && req.ProxyConfig.Enabled {
+		ctx, cancel := context.WithTimeout(r.Context(), proxyVerifyTimeout)
+		defer cancel()
+
+		var err error
+		verification, err = h.whatsappService.VerifyProxy(ctx, req.ProxyConfig)
+		if err != nil {
+			HandleError(w, models.NewBadRequestError("proxy verification failed: %v", err))
+			return
+		}
+	}
+
+	reconnecting, err := h.whatsappService.UpdateSessionProxy(sessionID, req.ProxyConfig)
+	if err != nil {
 		h.logger.Error("Failed to update session proxy %s: %v", sessionID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		HandleError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Session proxy configuration updated successfully"})
+	data := map[string]interface{}{
+		"session_id":    sessionID,
+		"proxy_enabled": req.ProxyConfig.Enabled,
+		"reconnecting":  reconnecting,
+	}
+	if verification != nil {
+		data["verification"] = verification
+	}
+	WriteSuccessResponse(w, "Session proxy configuration updated successfully", data)
 }
 
-// TestProxy handles testing proxy connectivity
+// proxyVerifyTimeout bounds the live proxy test run by UpdateSessionProxy
+const proxyVerifyTimeout = 10 * time.Second
+
+// TestProxy handles testing that WhatsApp's servers are reachable through a proxy
 func (h *SessionHandler) TestProxy(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ProxyConfig *models.ProxyConfig `json:"proxy_config"`
@@ -1438,19 +2207,30 @@ func (h *SessionHandler) TestProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Proxy configuration is required", http.StatusBadRequest)
 		return
 	}
+	if err := h.whatsappService.ValidateProxy(r.Context(), req.ProxyConfig); err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), proxyVerifyTimeout)
+	defer cancel()
 
-	// Test proxy connectivity
-	success, message := h.testProxyConnection(req.ProxyConfig)
-	
 	response := map[string]interface{}{
-		"success": success,
-		"message": message,
 		"proxy_info": map[string]interface{}{
 			"type": req.ProxyConfig.Type,
 			"host": req.ProxyConfig.Host,
 			"port": req.ProxyConfig.Port,
 		},
 	}
+	latency, err := h.whatsappService.TestProxyWhatsApp(ctx, req.ProxyConfig)
+	if err != nil {
+		response["success"] = false
+		response["message"] = fmt.Sprintf("WhatsApp is not reachable through the proxy: %v", err)
+	} else {
+		response["success"] = true
+		response["message"] = "WhatsApp is reachable through the proxy"
+		response["latency_ms"] = latency.Milliseconds()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -1463,76 +2243,6 @@ func (h *SessionHandler) TestProxy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// testProxyConnection tests if the proxy is reachable and working
-func (h *SessionHandler) testProxyConnection(config *models.ProxyConfig) (bool, string) {
-	timeout := 5 * time.Second
-	
-	switch config.Type {
-	case "http", "https":
-		// Test HTTP/HTTPS proxy
-		proxyURL := fmt.Sprintf("%s://%s:%d", config.Type, config.Host, config.Port)
-		if config.Username != "" && config.Password != "" {
-			proxyURL = fmt.Sprintf("%s://%s:%s@%s:%d", config.Type, config.Username, config.Password, config.Host, config.Port)
-		}
-		
-		parsed, err := url.Parse(proxyURL)
-		if err != nil {
-			return false, fmt.Sprintf("Invalid proxy URL: %v", err)
-		}
-		
-		client := &http.Client{
-			Transport: &http.Transport{
-				Proxy: http.ProxyURL(parsed),
-			},
-			Timeout: timeout,
-		}
-		
-		// Test by making a simple HTTP request to a reliable endpoint
-		testURL := "http://httpbin.org/get"
-		resp, err := client.Get(testURL)
-		if err != nil {
-			// If httpbin fails, try a simpler test
-			resp, err = client.Get("http://www.google.com")
-			if err != nil {
-				return false, fmt.Sprintf("Proxy connection failed: %v", err)
-			}
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode == 200 {
-			return true, fmt.Sprintf("HTTP/HTTPS proxy connection successful (Status: %d)", resp.StatusCode)
-		}
-		return false, fmt.Sprintf("Proxy returned status code: %d", resp.StatusCode)
-		
-	case "socks5":
-		// Test SOCKS5 proxy
-		var auth *proxy.Auth
-		if config.Username != "" && config.Password != "" {
-			auth = &proxy.Auth{
-				User:     config.Username,
-				Password: config.Password,
-			}
-		}
-		
-		dialer, err := proxy.SOCKS5("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port), auth, proxy.Direct)
-		if err != nil {
-			return false, fmt.Sprintf("Failed to create SOCKS5 dialer: %v", err)
-		}
-		
-		// Test connection by dialing through proxy
-		conn, err := dialer.Dial("tcp", "google.com:80")
-		if err != nil {
-			return false, fmt.Sprintf("SOCKS5 proxy connection failed: %v", err)
-		}
-		conn.Close()
-		
-		return true, "SOCKS5 proxy connection successful"
-		
-	default:
-		return false, "Unsupported proxy type"
-	}
-}
-
 // GetConversations handles getting all conversations/chats for a session
 func (h *SessionHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -1543,20 +2253,42 @@ func (h *SessionHandler) GetConversations(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get conversations from the WhatsApp service
-	conversations, err := h.whatsappService.GetConversations(sessionID)
+	query := &models.ConversationQuery{
+		Label: r.URL.Query().Get("label"),
+		Sort:  r.URL.Query().Get("sort"),
+		Page:  1,
+	}
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			HandleError(w, models.NewBadRequestError("page must be a positive number"))
+			return
+		}
+		query.Page = page
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxConversationLimit {
+			HandleError(w, models.NewBadRequestError("limit must be between 1 and %d", maxConversationLimit))
+			return
+		}
+		query.Limit = limit
+	}
+
+	// Get conversations from the WhatsApp service, optionally filtered by label
+	conversations, err := h.whatsappService.GetConversations(sessionID, query)
 	if err != nil {
 		h.logger.Error("Failed to get conversations for session %s: %v", sessionID, err)
 		HandleError(w, err)
 		return
 	}
 
-	WriteSuccessResponse(w, "Conversations retrieved successfully", map[string]interface{}{
-		"conversations": conversations,
-		"count":         len(conversations),
-	})
+	WriteSuccessResponse(w, "Conversations retrieved successfully", conversations)
 }
 
+// maxConversationLimit is the largest page of conversations
+const maxConversationLimit = 500
+
 // generateSessionID generates a random 10-digit session ID
 func generateSessionID() string {
 	// Generate a random number between 1000000000 and 9999999999 (10 digits)
@@ -1572,59 +2304,66 @@ func generateSessionID() string {
 	return fmt.Sprintf("%d", n.Int64()+min)
 }
 
-// streamQRUpdatesFromChannel streams QR code updates from a QR channel directly
-func (h *SessionHandler) streamQRUpdatesFromChannel(ctx context.Context, conn *websocket.Conn, qrChan <-chan whatsmeow.QRChannelItem, sessionID string) {
+// streamQRUpdates streams the session's QR login to a WebSocket connection,
+// along with pairing codes generated while the QR is shown
+func (h *SessionHandler) streamQRUpdates(ctx context.Context, ws *wsWriter, qrEvents <-chan *models.QREvent, pairCodes <-chan *models.PairCodeResponse, sessionID string) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case evt, ok := <-qrChan:
-			if !ok {
-				h.logger.Info("QR channel closed for session %s", sessionID)
+		case code := <-pairCodes:
+			if err := ws.WriteJSON(models.WebSocketMessage{Type: "pair_code", Data: code}); err != nil {
+				h.logger.Error("Failed to send pairing code for session %s: %v", sessionID, err)
 				return
 			}
-
+			h.logger.Debug("Sent pairing code for session %s", sessionID)
+		case evt := <-qrEvents:
 			h.logger.Debug("QR event for session %s: %s", sessionID, evt.Event)
 
 			var msgType string
 			var data interface{}
 
 			switch evt.Event {
-			case "code":
+			case models.QREventCode:
 				msgType = "qr"
 				data = map[string]interface{}{
-					"qr":      evt.Code,
-					"timeout": evt.Timeout,
+					"qr":         evt.Code,
+					"timeout":    evt.Timeout,
+					"expires_at": models.FormatTimestamp(evt.ExpiresAt),
+				}
+			case models.QREventSuccess:
+				// WhatsApp restarts the connection right after pairing; report progress
+				// and only announce success once the session is back online
+				if err := ws.WriteJSON(models.WebSocketMessage{
+					Type: "pairing_finalizing",
+					Data: map[string]string{"message": "Pairing successful, finalizing login"},
+				}); err != nil {
+					h.logger.Error("Failed to send pairing update for session %s: %v", sessionID, err)
+					return
+				}
+
+				if err := h.whatsappService.WaitForPairingComplete(sessionID, 60*time.Second); err != nil {
+					h.logger.Error("Pairing did not complete for session %s: %v", sessionID, err)
+					msgType = "error"
+					data = map[string]string{"error": "Pairing could not be finalized: " + err.Error()}
+				} else {
+					msgType = "success"
+					data = map[string]string{"message": "Login successful"}
 				}
-			case "success":
-				msgType = "success"
-				data = map[string]string{"message": "Login successful"}
-			case "timeout":
+			case models.QREventTimeout:
 				msgType = "qr_timeout"
 				data = map[string]string{"message": "QR code timeout"}
 			default:
-				// Handle error events (events starting with "err-")
-				if len(evt.Event) > 4 && evt.Event[:4] == "err-" {
-					msgType = "error"
-					errorMessage := fmt.Sprintf("QR generation failed: %s", evt.Event)
-					// Provide user-friendly messages for common errors
-					if evt.Event == "err-client-outdated" {
-						errorMessage = "WhatsApp client version is outdated. Please update the whatmeow library or restart the container to fetch the latest version."
-					}
-					data = map[string]string{"error": errorMessage}
-					h.logger.Error("QR error event for session %s: %s", sessionID, evt.Event)
-				} else {
-					h.logger.Debug("Unknown QR event: %s", evt.Event)
-					continue
+				msgType = "error"
+				errorMessage := fmt.Sprintf("QR generation failed: %s", evt.Error)
+				// Provide user-friendly messages for common errors
+				if evt.Error == whatsmeow.QRChannelClientOutdated.Event {
+					errorMessage = "WhatsApp client version is outdated. Please update the whatmeow library or restart the container to fetch the latest version."
 				}
+				data = map[string]string{"error": errorMessage}
 			}
 
-			wsMsg := models.WebSocketMessage{
-				Type: msgType,
-				Data: data,
-			}
-
-			if err := conn.WriteJSON(wsMsg); err != nil {
+			if err := ws.WriteJSON(models.WebSocketMessage{Type: msgType, Data: data}); err != nil {
 				h.logger.Error("Failed to send QR update for session %s: %v", sessionID, err)
 				return
 			}
@@ -1632,9 +2371,14 @@ func (h *SessionHandler) streamQRUpdatesFromChannel(ctx context.Context, conn *w
 			h.logger.Debug("Sent QR update (%s) for session %s", msgType, sessionID)
 
 			// Stop streaming on success or timeout
-			if evt.Event == "success" || evt.Event == "timeout" {
+			if evt.Event == models.QREventSuccess || evt.Event == models.QREventTimeout {
 				return
 			}
 		}
 	}
 }
+
+// GetUploadCacheStats handles GET /api/admin/upload-cache
+func (h *SessionHandler) GetUploadCacheStats(w http.ResponseWriter, r *http.Request) {
+	WriteSuccessResponse(w, "Upload cache stats retrieved successfully", h.whatsappService.UploadCacheStats())
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/services"
+	"whatsapp-multi-session/pkg/httpclient"
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// Users created by newTestSessionHandler
+const (
+	testOwnerID = 1
+	testOtherID = 2
+)
+
+// newTestSessionHandler returns a session handler backed by an in-memory
+// SQLite database with two users, along with a connected sandbox session
+// owned by testOwnerID
+func newTestSessionHandler(t *testing.T) (*SessionHandler, *repository.MessageRepository, string) {
+	t.Helper()
+
+	db, err := repository.NewDatabase(repository.DatabaseConfig{Type: "sqlite", Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to :memory: opens a database of its own
+	db.DB().SetMaxOpenConns(1)
+	if err := db.InitTables(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	for _, id := range []int{testOwnerID, testOtherID} {
+		if _, err := db.DB().Exec(`INSERT INTO users (id, username, password_hash, role, session_limit, is_active, created_at) VALUES (?, ?, 'x', 'user', 5, 1, 0)`,
+			id, fmt.Sprintf("user%d", id)); err != nil {
+			t.Fatalf("failed to create user %d: %v", id, err)
+		}
+	}
+
+	log := logger.New(false, "error", "text")
+	mediaStorage, err := storage.New(storage.Config{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	messageRepo := repository.NewMessageRepository(db.DB())
+	whatsappService, err := services.NewWhatsAppService(filepath.Join(t.TempDir(), "whatsapp.db"), repository.NewSessionRepository(db.DB()),
+		messageRepo, mediaStorage, httpclient.NewPool(&http.Client{}), log)
+	if err != nil {
+		t.Fatalf("failed to create WhatsApp service: %v", err)
+	}
+	t.Cleanup(func() { whatsappService.Close() })
+
+	session, err := whatsappService.CreateSession(&models.CreateSessionRequest{Name: "Sandbox", Sandbox: true}, testOwnerID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := whatsappService.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect session: %v", err)
+	}
+
+	return NewSessionHandler(whatsappService, messageRepo, "test-secret", log, nil), messageRepo, session.ID
+}
+
+// serveAs sends a request through the session routes as the given user
+func serveAs(h *SessionHandler, userID int, method, target, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/sessions/{sessionId}/send", h.SendMessage).Methods("POST")
+	router.HandleFunc("/api/sessions/{sessionId}/reply", h.ReplyMessage).Methods("POST")
+	router.HandleFunc("/api/sessions/{sessionId}/forward", h.ForwardMessage).Methods("POST")
+	router.HandleFunc("/api/sessions/{sessionId}/messages", h.GetMessageHistory).Methods("GET")
+	router.HandleFunc("/api/sessions/{sessionId}/sandbox/incoming", h.InjectSandboxMessage).Methods("POST")
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "user_id", userID)
+	ctx = context.WithValue(ctx, "role", models.RoleUser)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+// decodeHistory decodes a message history response
+func decodeHistory(t *testing.T, rec *httptest.ResponseRecorder) *models.MessageHistoryResponse {
+	t.Helper()
+	var resp struct {
+		Success bool                           `json:"success"`
+		Data    *models.MessageHistoryResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success || resp.Data == nil {
+		t.Fatalf("unsuccessful response: %+v", resp)
+	}
+	return resp.Data
+}
+
+func TestSendMessageIsLoggedToHistory(t *testing.T) {
+	h, messageRepo, sessionID := newTestSessionHandler(t)
+
+	for _, text := range []string{"first", "second", "third"} {
+		rec := serveAs(h, testOwnerID, "POST", "/api/sessions/"+sessionID+"/send", `{"to":"628123456789","message":"`+text+`"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("send returned %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	messages, total, err := messageRepo.GetMessages(repository.MessageHistoryFilter{SessionID: sessionID}, 0, 10)
+	if err != nil {
+		t.Fatalf("failed to read logged messages: %v", err)
+	}
+	if total != 3 || len(messages) != 3 {
+		t.Fatalf("logged %d messages (total %d), want 3", len(messages), total)
+	}
+	if messages[0].Content != "third" || messages[0].Direction != "sent" || messages[0].Status != "sent" || messages[0].MessageID == "" {
+		t.Errorf("newest logged message = %+v, want the third sent message", messages[0])
+	}
+
+	rec := serveAs(h, testOwnerID, "GET", "/api/sessions/"+sessionID+"/messages?limit=2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history returned %d: %s", rec.Code, rec.Body.String())
+	}
+	page := decodeHistory(t, rec)
+	if page.Total != 3 || len(page.Messages) != 2 || page.NextCursor == 0 {
+		t.Fatalf("first page = %d messages of %d, cursor %d; want 2 of 3 with a cursor", len(page.Messages), page.Total, page.NextCursor)
+	}
+	if page.Messages[0].Content != "third" || page.Messages[1].Content != "second" {
+		t.Errorf("first page = %q, %q; want third, second", page.Messages[0].Content, page.Messages[1].Content)
+	}
+
+	rec = serveAs(h, testOwnerID, "GET", "/api/sessions/"+sessionID+"/messages?limit=2&before_id="+strconv.FormatInt(page.NextCursor, 10), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second page returned %d: %s", rec.Code, rec.Body.String())
+	}
+	page = decodeHistory(t, rec)
+	if len(page.Messages) != 1 || page.Messages[0].Content != "first" || page.NextCursor != 0 {
+		t.Errorf("second page = %+v, want only the first message and no cursor", page.Messages)
+	}
+}
+
+func TestMessageHistoryFilters(t *testing.T) {
+	h, messageRepo, sessionID := newTestSessionHandler(t)
+
+	for _, msg := range []*repository.Message{
+		{SessionID: sessionID, MessageID: "in-1", SenderJID: "628123456789@s.whatsapp.net", RecipientJID: "628123456789@s.whatsapp.net", MessageType: "text", Content: "hi", Direction: "received", Status: "received"},
+		{SessionID: sessionID, MessageID: "out-1", RecipientJID: "628123456789@s.whatsapp.net", MessageType: "image", Content: "photo", Direction: "sent", Status: "sent"},
+		{SessionID: sessionID, MessageID: "out-2", RecipientJID: "628987654321@s.whatsapp.net", MessageType: "text", Content: "other chat", Direction: "sent", Status: "sent"},
+	} {
+		if err := messageRepo.LogMessage(msg); err != nil {
+			t.Fatalf("failed to log message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"out-2", "out-1", "in-1"}},
+		{"?direction=received", []string{"in-1"}},
+		{"?message_type=image", []string{"out-1"}},
+		{"?chat=%2B628123456789", []string{"out-1", "in-1"}},
+		{"?chat=628987654321@s.whatsapp.net", []string{"out-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := serveAs(h, testOwnerID, "GET", "/api/sessions/"+sessionID+"/messages"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("history returned %d: %s", rec.Code, rec.Body.String())
+			}
+			var got []string
+			for _, msg := range decodeHistory(t, rec).Messages {
+				got = append(got, msg.MessageID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageHistoryRejects(t *testing.T) {
+	h, _, sessionID := newTestSessionHandler(t)
+
+	tests := []struct {
+		name   string
+		userID int
+		query  string
+		status int
+	}{
+		{"other user's session", testOtherID, "", http.StatusForbidden},
+		{"unknown direction", testOwnerID, "?direction=sideways", http.StatusBadRequest},
+		{"limit too large", testOwnerID, "?limit=1000", http.StatusBadRequest},
+		{"bad cursor", testOwnerID, "?before_id=-1", http.StatusBadRequest},
+		{"from after to", testOwnerID, "?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAs(h, tt.userID, "GET", "/api/sessions/"+sessionID+"/messages"+tt.query, "")
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSendMessageToOtherUsersSessionIsNotLogged(t *testing.T) {
+	h, messageRepo, sessionID := newTestSessionHandler(t)
+
+	rec := serveAs(h, testOtherID, "POST", "/api/sessions/"+sessionID+"/send", `{"to":"628123456789","message":"hi"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if _, total, err := messageRepo.GetMessages(repository.MessageHistoryFilter{SessionID: sessionID}, 0, 10); err != nil || total != 0 {
+		t.Errorf("logged %d messages (err %v), want none", total, err)
+	}
+}
+
+func TestMessageHistoryWithoutRepository(t *testing.T) {
+	h, _, sessionID := newTestSessionHandler(t)
+	h.messageRepo = nil
+
+	rec := serveAs(h, testOwnerID, "GET", "/api/sessions/"+sessionID+"/messages", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestReplyAndForwardAreLogged(t *testing.T) {
+	h, messageRepo, sessionID := newTestSessionHandler(t)
+
+	tests := []struct {
+		endpoint string
+		body     string
+		content  string
+	}{
+		{"reply", `{"to":"628123456789","message":"thanks!","quoted_message_id":"QUOTED1"}`, "thanks!"},
+		{"forward", `{"to":"628987654321","message_id":"ORIGINAL1","text":"have a look"}`, "have a look"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			rec := serveAs(h, testOwnerID, "POST", "/api/sessions/"+sessionID+"/"+tt.endpoint, tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s returned %d: %s", tt.endpoint, rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Success bool   `json:"success"`
+				ID      string `json:"id"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if !resp.Success || resp.ID == "" {
+				t.Fatalf("response = %+v, want success with a message ID", resp)
+			}
+
+			logged, err := messageRepo.GetMessageByID(sessionID, resp.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged == nil || logged.Content != tt.content || logged.Direction != "sent" || logged.Status != "sent" {
+				t.Errorf("logged message = %+v, want %q sent", logged, tt.content)
+			}
+		})
+	}
+}
+
+func TestReplyAndForwardReject(t *testing.T) {
+	h, messageRepo, sessionID := newTestSessionHandler(t)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		userID   int
+		body     string
+		status   int
+	}{
+		{"reply without recipient", "reply", testOwnerID, `{"message":"hi","quoted_message_id":"Q1"}`, http.StatusBadRequest},
+		{"reply without message", "reply", testOwnerID, `{"to":"628123456789","quoted_message_id":"Q1"}`, http.StatusBadRequest},
+		{"reply without quoted message", "reply", testOwnerID, `{"to":"628123456789","message":"hi"}`, http.StatusBadRequest},
+		{"reply with invalid body", "reply", testOwnerID, `{"to":`, http.StatusBadRequest},
+		{"reply in a group without quoted sender", "reply", testOwnerID, `{"to":"120363012345678901@g.us","message":"hi","quoted_message_id":"Q1"}`, http.StatusBadRequest},
+		{"reply to other user's session", "reply", testOtherID, `{"to":"628123456789","message":"hi","quoted_message_id":"Q1"}`, http.StatusForbidden},
+		{"forward without recipient", "forward", testOwnerID, `{"message_id":"M1","text":"hi"}`, http.StatusBadRequest},
+		{"forward without message ID", "forward", testOwnerID, `{"to":"628123456789","text":"hi"}`, http.StatusBadRequest},
+		{"forward without text", "forward", testOwnerID, `{"to":"628123456789","message_id":"M1"}`, http.StatusBadRequest},
+		{"forward from other user's session", "forward", testOtherID, `{"to":"628123456789","message_id":"M1","text":"hi"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAs(h, tt.userID, "POST", "/api/sessions/"+sessionID+"/"+tt.endpoint, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+
+	// Nothing rejected is logged as sent
+	messages, _, err := messageRepo.GetMessages(repository.MessageHistoryFilter{SessionID: sessionID}, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if message.Status == "sent" {
+			t.Errorf("rejected message logged as sent: %+v", message)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSessionHealth handles GET /api/sessions/{sessionId}/health
+func (h *SessionHandler) GetSessionHealth(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	health, err := h.whatsappService.GetSessionHealth(sessionID)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Session health retrieved successfully", health)
+}
+
+// ReconnectSession handles POST /api/sessions/{sessionId}/reconnect
+func (h *SessionHandler) ReconnectSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	health, err := h.whatsappService.ForceReconnect(sessionID)
+	if err != nil {
+		h.logger.Error("Failed to reconnect session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Session reconnecting", health)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// PostStatus handles POST /api/sessions/{sessionId}/status
+func (h *SessionHandler) PostStatus(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	var req models.PostStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.whatsappService.PostStatus(sessionID, &req)
+	if err != nil {
+		h.logger.Error("Failed to post status for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+	h.auditMessageSent(r, sessionID, "status@broadcast", status.MessageID, "status")
+
+	WriteSuccessResponse(w, "Status posted successfully", status)
+}
+
+// RevokeStatus handles DELETE /api/sessions/{sessionId}/status/{messageId}
+func (h *SessionHandler) RevokeStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	if err := h.whatsappService.RevokeStatus(sessionID, vars["messageId"]); err != nil {
+		h.logger.Error("Failed to delete status for session %s: %v", sessionID, err)
+		HandleError(w, err)
+		return
+	}
+
+	WriteSuccessResponse(w, "Status deleted successfully", map[string]string{
+		"session_id": sessionID,
+		"message_id": vars["messageId"],
+	})
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// expectedVersion returns the version a conditional update is based on,
+// taken from the If-Match header or the expected_version body field, or nil
+// for an unconditional update. The two must agree when both are given.
+func expectedVersion(r *http.Request, fromBody *int64) (*int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return fromBody, nil
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid If-Match header %q, expected a version", header)
+	}
+	if fromBody != nil && *fromBody != version {
+		return nil, models.NewBadRequestError("If-Match version %d does not match expected_version %d", version, *fromBody)
+	}
+	return &version, nil
+}
+
+// setVersionHeader exposes a resource version as its ETag, for use in If-Match
+func setVersionHeader(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SendVoice handles POST /api/sessions/{sessionId}/send-voice
+func (h *SessionHandler) SendVoice(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionId"]
+
+	// Check user authentication and session ownership
+	if _, _, ok := h.getUserInfoAndCheckOwnership(w, r, sessionID); !ok {
+		return
+	}
+
+	// Check if session is enabled
+	if err := h.checkSessionEnabled(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req models.SendVoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.To == "" {
+		HandleError(w, models.NewBadRequestError("to is required"))
+		return
+	}
+
+	messageID, err := h.whatsappService.SendVoice(sessionID, &req)
+	if err != nil {
+		h.logger.Error("Failed to send voice note from session %s: %v", sessionID, err)
+		h.logMessage(sessionID, messageID, "", req.To, "audio", "", req.URL, "sent", "failed", err.Error())
+		writeSendError(w, err)
+		return
+	}
+	h.logMessage(sessionID, messageID, "", req.To, "audio", "", req.URL, "sent", "sent", "")
+	h.auditMessageSent(r, sessionID, req.To, messageID, "audio")
+
+	WriteSuccessResponse(w, "Voice note sent successfully", map[string]interface{}{
+		"message_id": messageID,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetWebhookDeliveries handles GET /api/admin/webhook-deliveries
+func (h *SessionHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := 1
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	limit := 20
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	deliveries, err := h.whatsappService.ListWebhookDeliveries(query.Get("status"), query.Get("session_id"), page, limit)
+	switch err.(type) {
+	case models.BadRequestError, models.ServiceUnavailableError:
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list webhook deliveries: %v", err)
+		http.Error(w, "Failed to list webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	WriteSuccessResponse(w, "Webhook deliveries retrieved successfully", deliveries)
+}
+
+// RetryWebhookDelivery handles POST /api/admin/webhook-deliveries/{id}/retry
+func (h *SessionHandler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.whatsappService.RetryWebhookDelivery(id)
+	switch err.(type) {
+	case models.NotFoundError, models.BadRequestError, models.ServiceUnavailableError:
+		HandleError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to retry webhook delivery %d: %v", id, err)
+		http.Error(w, "Failed to retry webhook delivery", http.StatusInternalServerError)
+		return
+	}
+
+	WriteSuccessResponse(w, "Webhook delivery queued for retry", delivery)
+}
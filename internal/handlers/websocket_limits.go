@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+)
+
+// webSocketTracker counts open WebSocket connections per user and per
+// session, so a leaking client cannot exhaust the server's file descriptors
+type webSocketTracker struct {
+	mu         sync.Mutex
+	softLimit  int // Connections per user above which a warning is logged and returned
+	hardLimit  int // Connections per user above which upgrades are rejected
+	perUser    map[int]int
+	perSession map[string]int
+}
+
+// WebSocketStats reports open WebSocket connections
+type WebSocketStats struct {
+	Total      int            `json:"total"`
+	SoftLimit  int            `json:"soft_limit"` // Per user, 0 means no warning
+	HardLimit  int            `json:"hard_limit"` // Per user, 0 means unlimited
+	PerUser    map[string]int `json:"per_user"`   // Keyed by user ID
+	PerSession map[string]int `json:"per_session"`
+}
+
+func newWebSocketTracker() *webSocketTracker {
+	return &webSocketTracker{
+		perUser:    make(map[int]int),
+		perSession: make(map[string]int),
+	}
+}
+
+// acquire registers a new connection and returns the user's connection count
+// including it. ok is false, and nothing is registered, when the user is
+// already at the hard limit.
+func (t *webSocketTracker) acquire(userID int, sessionID string) (count int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.hardLimit > 0 && t.perUser[userID] >= t.hardLimit {
+		return t.perUser[userID], false
+	}
+	t.perUser[userID]++
+	t.perSession[sessionID]++
+	return t.perUser[userID], true
+}
+
+// release unregisters a connection registered by acquire
+func (t *webSocketTracker) release(userID int, sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.perUser[userID]--; t.perUser[userID] <= 0 {
+		delete(t.perUser, userID)
+	}
+	if t.perSession[sessionID]--; t.perSession[sessionID] <= 0 {
+		delete(t.perSession, sessionID)
+	}
+}
+
+// overSoftLimit reports whether count exceeds the soft limit
+func (t *webSocketTracker) overSoftLimit(count int) bool {
+	return t.softLimit > 0 && count > t.softLimit
+}
+
+func (t *webSocketTracker) stats() WebSocketStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := WebSocketStats{
+		SoftLimit:  t.softLimit,
+		HardLimit:  t.hardLimit,
+		PerUser:    make(map[string]int, len(t.perUser)),
+		PerSession: make(map[string]int, len(t.perSession)),
+	}
+	for userID, count := range t.perUser {
+		stats.PerUser[strconv.Itoa(userID)] = count
+		stats.Total += count
+	}
+	for sessionID, count := range t.perSession {
+		stats.PerSession[sessionID] = count
+	}
+	return stats
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// dialSession opens a WebSocket to the session as testOwnerID
+func dialSession(t *testing.T, server *httptest.Server, sessionID string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": testOwnerID, "role": "user"}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/" + sessionID + "?token=" + token
+	return websocket.DefaultDialer.Dial(url, nil)
+}
+
+// waitForWebSockets waits until the handler counts total open connections
+func waitForWebSockets(t *testing.T, h *SessionHandler, total int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.websockets.stats().Total != total {
+		if time.Now().After(deadline) {
+			t.Fatalf("open connections = %+v, want %d in total", h.websockets.stats(), total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	const soft, hard = 2, 4
+	h, _, sessionID := newTestSessionHandler(t)
+	h.SetWebSocketLimits(soft, hard)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/{sessionId}", h.WebSocketHandler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	var conns []*websocket.Conn
+	t.Cleanup(func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	for i := 1; i <= hard; i++ {
+		conn, resp, err := dialSession(t, server, sessionID)
+		if err != nil {
+			t.Fatalf("connection %d failed: %v", i, err)
+		}
+		conns = append(conns, conn)
+		if warning := resp.Header.Get("X-WebSocket-Warning"); (warning != "") != (i > soft) {
+			t.Errorf("connection %d warning = %q, want one only above the soft limit of %d", i, warning, soft)
+		}
+	}
+	waitForWebSockets(t, h, hard)
+
+	// At the hard limit further upgrades are rejected without counting them
+	_, resp, err := dialSession(t, server, sessionID)
+	if !errors.Is(err, websocket.ErrBadHandshake) || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("connection above the hard limit = %v with status %d, want 429", err, status)
+	}
+	stats := h.websockets.stats()
+	if stats.Total != hard || stats.PerUser["1"] != hard || stats.PerSession[sessionID] != hard {
+		t.Errorf("stats after a rejected upgrade = %+v, want %d connections of user 1 to %s", stats, hard, sessionID)
+	}
+
+	// The admin endpoint reports the same counts
+	rec := httptest.NewRecorder()
+	h.GetWebSocketStats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/websockets", nil))
+	var body struct {
+		Data WebSocketStats `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Total != hard || body.Data.SoftLimit != soft || body.Data.HardLimit != hard || body.Data.PerSession[sessionID] != hard {
+		t.Errorf("admin stats = %+v", body.Data)
+	}
+
+	// Closed connections are released, freeing room for new ones
+	for _, conn := range conns[:2] {
+		conn.Close()
+	}
+	conns = conns[2:]
+	waitForWebSockets(t, h, hard-2)
+	conn, resp, err := dialSession(t, server, sessionID)
+	if err != nil {
+		t.Fatalf("connection after releasing failed: %v", err)
+	}
+	conns = append(conns, conn)
+	if warning := resp.Header.Get("X-WebSocket-Warning"); warning == "" {
+		t.Errorf("connection %d has no warning above the soft limit of %d", hard-1, soft)
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	conns = nil
+	waitForWebSockets(t, h, 0)
+	if stats := h.websockets.stats(); len(stats.PerUser) != 0 || len(stats.PerSession) != 0 {
+		t.Errorf("stats after closing every connection = %+v, want none left", stats)
+	}
+}
+
+func TestWebSocketTracker(t *testing.T) {
+	tracker := newWebSocketTracker()
+	tracker.softLimit, tracker.hardLimit = 1, 2
+
+	for _, tt := range []struct {
+		userID    int
+		sessionID string
+		count     int
+		ok        bool
+	}{
+		{1, "a", 1, true},
+		{1, "b", 2, true},
+		{1, "a", 2, false},
+		// Limits apply per user
+		{2, "a", 1, true},
+	} {
+		count, ok := tracker.acquire(tt.userID, tt.sessionID)
+		if count != tt.count || ok != tt.ok {
+			t.Errorf("acquire(%d, %q) = %d, %v; want %d, %v", tt.userID, tt.sessionID, count, ok, tt.count, tt.ok)
+		}
+	}
+	if tracker.overSoftLimit(1) || !tracker.overSoftLimit(2) {
+		t.Error("soft limit of 1 is not exceeded by 2 connections only")
+	}
+	if stats := tracker.stats(); stats.Total != 3 || stats.PerUser["1"] != 2 || stats.PerSession["a"] != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	tracker.release(1, "a")
+	tracker.release(1, "b")
+	tracker.release(2, "a")
+	if stats := tracker.stats(); stats.Total != 0 || len(stats.PerUser) != 0 || len(stats.PerSession) != 0 {
+		t.Errorf("stats after releasing = %+v, want empty", stats)
+	}
+
+	// Unlimited without a hard limit
+	tracker.hardLimit = 0
+	for i := 1; i <= 5; i++ {
+		if _, ok := tracker.acquire(1, "a"); !ok {
+			t.Fatalf("connection %d rejected without a hard limit", i)
+		}
+	}
+}
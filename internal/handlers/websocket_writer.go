@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// wsWriter serializes the messages written to a WebSocket connection, which
+// supports only one writer at a time, and stamps each with the time it is sent
+type wsWriter struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+// WriteJSON sends msg, stamped with the current time unless it has a timestamp
+func (w *wsWriter) WriteJSON(msg models.WebSocketMessage) error {
+	if msg.Timestamp == "" {
+		msg.Timestamp = models.FormatTimestamp(time.Now())
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteJSON(msg)
+}
+
+// streamSessionStatus forwards the status events of a session to a WebSocket
+// connection until ctx is done
+func (h *SessionHandler) streamSessionStatus(ctx context.Context, ws *wsWriter, statuses <-chan *models.SessionStatusEvent, sessionID string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-statuses:
+			if err := ws.WriteJSON(models.WebSocketMessage{
+				Type:      "session_status",
+				Data:      event,
+				Timestamp: event.Timestamp,
+			}); err != nil {
+				h.logger.Debug("Failed to send status event for session %s: %v", sessionID, err)
+				return
+			}
+		}
+	}
+}
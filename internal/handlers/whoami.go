@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"whatsapp-multi-session/internal/middleware"
+	"whatsapp-multi-session/internal/models"
+)
+
+// Whoami handles GET /api/auth/whoami, reporting how the request was
+// authenticated and, with ?session_id=, whether that session may be accessed.
+// The token or API key itself is never echoed back.
+func (h *SessionHandler) Whoami(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserClaims(r)
+	if !ok {
+		http.Error(w, "User authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	resp := &models.WhoamiResponse{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Role:     claims.Role,
+		IsAdmin:  claims.Role == models.RoleAdmin,
+	}
+
+	if info, ok := middleware.GetAuthInfo(r); ok {
+		resp.AuthMethod = info.Method
+		if info.IssuedAt != nil {
+			resp.IssuedAt = models.FormatTimestamp(*info.IssuedAt)
+		}
+		if info.ExpiresAt != nil {
+			resp.ExpiresAt = models.FormatTimestamp(*info.ExpiresAt)
+		}
+		if info.Method == middleware.AuthMethodAPIKey {
+			scopes := info.Scopes
+			if scopes == nil {
+				scopes = []string{"*"}
+			}
+			resp.APIKey = &models.WhoamiAPIKey{
+				Name:     info.KeyName,
+				Hint:     info.KeyHint,
+				Scopes:   scopes,
+				Sessions: info.Sessions,
+			}
+		}
+	}
+
+	if sessionID := r.URL.Query().Get("session_id"); sessionID != "" {
+		resp.SessionAccess = h.explainSessionAccess(sessionID, claims.UserID, claims.Role)
+		if info, ok := middleware.GetAuthInfo(r); ok && resp.SessionAccess.Allowed && !info.SessionAllowed(sessionID) {
+			resp.SessionAccess.Allowed = false
+			resp.SessionAccess.Reason = "API key is not allowed to access this session"
+		}
+	}
+
+	WriteSuccessResponse(w, "Authentication details retrieved successfully", resp)
+}
+
+// explainSessionAccess follows checkSessionOwnership and says why access is
+// granted or denied. Sessions of other users are not distinguished from
+// missing ones.
+func (h *SessionHandler) explainSessionAccess(sessionID string, userID int, role string) *models.WhoamiSessionAccess {
+	access := &models.WhoamiSessionAccess{SessionID: sessionID}
+
+	if role == models.RoleAdmin {
+		access.Allowed = true
+		access.Reason = "admins may access every session"
+		if _, exists := h.whatsappService.GetSession(sessionID); !exists {
+			access.Reason += ", but the session does not exist"
+		}
+		return access
+	}
+
+	owned, err := h.whatsappService.IsSessionOwnedByUser(sessionID, userID)
+	switch {
+	case err != nil:
+		access.Reason = err.Error()
+	case !owned:
+		access.Reason = "session does not exist or belongs to another user"
+	default:
+		access.Allowed = true
+		access.Reason = "session is owned by this user"
+	}
+	return access
+}
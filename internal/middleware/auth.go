@@ -3,7 +3,9 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	
@@ -15,6 +17,7 @@ type Claims struct {
 	Username string `json:"username"`
 	UserID   int    `json:"user_id"`
 	Role     string `json:"role"`
+	Region   string `json:"region,omitempty"` // Default region for phone number formatting
 	jwt.RegisteredClaims
 }
 
@@ -24,8 +27,28 @@ type ContextKey string
 const (
 	// UserContextKey is the key for user claims in context
 	UserContextKey ContextKey = "user"
+	// AuthInfoContextKey is the key for how the request was authenticated
+	AuthInfoContextKey ContextKey = "auth_info"
 )
 
+// Authentication methods recorded in AuthInfo
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// AuthInfo describes how a request was authenticated. It never holds the
+// token or key itself.
+type AuthInfo struct {
+	Method    string     // AuthMethodJWT or AuthMethodAPIKey
+	IssuedAt  *time.Time // JWT only
+	ExpiresAt *time.Time // JWT only; API keys do not expire
+	KeyHint   string     // API key only: prefix and last four characters
+	KeyName   string     // API key only
+	Scopes    []string   // API key only: nil or "*" grants everything the user may do
+	Sessions  []string   // API key only: nil allows every session the user may access
+}
+
 // AuthMiddleware creates JWT authentication middleware
 func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -62,6 +85,7 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 			ctx = context.WithValue(ctx, "username", claims.Username)
 			ctx = context.WithValue(ctx, "role", claims.Role)
 			ctx = context.WithValue(ctx, UserContextKey, claims)
+			ctx = context.WithValue(ctx, AuthInfoContextKey, jwtAuthInfo(claims))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -89,11 +113,16 @@ func FlexibleAuthMiddleware(jwtSecret string, userService *services.UserService)
 
 			// Try API key authentication first (if it looks like an API key)
 			if strings.HasPrefix(tokenString, "wams_") {
-				user, err := userService.AuthenticateAPIKey(tokenString)
+				user, key, err := userService.AuthenticateAPIKey(tokenString)
 				if err != nil {
 					http.Error(w, "Invalid API key", http.StatusUnauthorized)
 					return
 				}
+				if allowed, retryAfter := userService.AllowAPIKeyRequest(key); !allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+					http.Error(w, "API key rate limit exceeded", http.StatusTooManyRequests)
+					return
+				}
 
 				// Create claims-like context for compatibility
 				ctx := context.WithValue(r.Context(), "user_id", user.ID)
@@ -105,8 +134,16 @@ func FlexibleAuthMiddleware(jwtSecret string, userService *services.UserService)
 					Username: user.Username,
 					UserID:   user.ID,
 					Role:     user.Role,
+					Region:   user.DefaultRegion,
 				}
 				ctx = context.WithValue(ctx, UserContextKey, claims)
+				ctx = context.WithValue(ctx, AuthInfoContextKey, &AuthInfo{
+					Method:   AuthMethodAPIKey,
+					KeyHint:  key.Hint,
+					KeyName:  key.Name,
+					Scopes:   key.Scopes,
+					Sessions: key.Sessions,
+				})
 				
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
@@ -123,16 +160,41 @@ func FlexibleAuthMiddleware(jwtSecret string, userService *services.UserService)
 				return
 			}
 
+			// Reject tokens issued before a password change or account lock
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			if err := userService.CheckAccessToken(claims.UserID, issuedAt); err != nil {
+				http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+
 			// Add claims to context (individual keys for compatibility)
 			ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 			ctx = context.WithValue(ctx, "username", claims.Username)
 			ctx = context.WithValue(ctx, "role", claims.Role)
 			ctx = context.WithValue(ctx, UserContextKey, claims)
+			ctx = context.WithValue(ctx, AuthInfoContextKey, jwtAuthInfo(claims))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// jwtAuthInfo records the issue and expiry times of a validated token
+func jwtAuthInfo(claims *Claims) *AuthInfo {
+	info := &AuthInfo{Method: AuthMethodJWT}
+	if claims.IssuedAt != nil {
+		issuedAt := claims.IssuedAt.Time
+		info.IssuedAt = &issuedAt
+	}
+	if claims.ExpiresAt != nil {
+		expiresAt := claims.ExpiresAt.Time
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
+
 // RequireRole creates middleware that requires specific role
 func RequireRole(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -157,4 +219,10 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 func GetUserClaims(r *http.Request) (*Claims, bool) {
 	claims, ok := r.Context().Value(UserContextKey).(*Claims)
 	return claims, ok
+}
+
+// GetAuthInfo extracts how the request was authenticated from context
+func GetAuthInfo(r *http.Request) (*AuthInfo, bool) {
+	info, ok := r.Context().Value(AuthInfoContextKey).(*AuthInfo)
+	return info, ok
 }
\ No newline at end of file
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"whatsapp-multi-session/internal/utils"
+)
+
+// RecordBaseURL hands the public base URL of every request, taken from its
+// Host or, with trustProxyHeaders, its X-Forwarded-Proto and X-Forwarded-Host
+// headers, to record. It belongs after authentication so anonymous callers
+// cannot choose the address put into webhooks.
+func RecordBaseURL(trustProxyHeaders bool, record func(baseURL string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			record(utils.RequestBaseURL(r, trustProxyHeaders))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
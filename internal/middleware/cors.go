@@ -21,10 +21,13 @@ func NewCORS(allowedOrigins []string) *cors.Cors {
 			"Authorization",
 			"Content-Type",
 			"X-Requested-With",
+			"If-Match",
 		},
 		ExposedHeaders: []string{
 			"Content-Length",
 			"Content-Type",
+			"ETag",
+			"X-Total-Count",
 		},
 		AllowCredentials: true,
 		MaxAge:           300,
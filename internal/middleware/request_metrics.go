@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/metrics"
+)
+
+const (
+	// perfWindow is how far back the performance snapshot looks
+	perfWindow = 15 * time.Minute
+	// maxSamplesPerRoute bounds the memory kept for a busy route within the window
+	maxSamplesPerRoute = 2000
+	// perfTopRoutes is how many of the slowest routes the snapshot reports
+	perfTopRoutes = 10
+)
+
+// fileRoutes are the route templates that stream media files; their bodies
+// are passed through untouched instead of being counted
+var fileRoutes = map[string]bool{
+	"/api/media/temp/{filename}":                  true,
+	"/api/sessions/{sessionId}/media/{messageId}": true,
+}
+
+// RequestMetrics logs every API call with its route, status, latency and
+// sizes, and keeps recent latencies per route for the performance snapshot.
+// Routes are recorded by their mux template (e.g. /api/sessions/{sessionId})
+// rather than the raw path, so IDs do not create a route per session.
+type RequestMetrics struct {
+	logger        *logger.Logger
+	slowThreshold time.Duration
+
+	mu     sync.Mutex
+	routes map[string][]requestSample
+}
+
+type requestSample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// RouteLatency summarizes a route's latencies within the snapshot window
+type RouteLatency struct {
+	Route string  `json:"route"` // Method and route template, e.g. "GET /api/sessions/{sessionId}"
+	Count int     `json:"count"`
+	P50MS float64 `json:"p50_ms"`
+	P95MS float64 `json:"p95_ms"`
+	MaxMS float64 `json:"max_ms"`
+}
+
+// PerfSnapshot is the rolling performance summary served to admins
+type PerfSnapshot struct {
+	WindowMinutes   int            `json:"window_minutes"`
+	SlowThresholdMS int64          `json:"slow_threshold_ms"`
+	Routes          []RouteLatency `json:"routes"` // Slowest first by p95
+}
+
+// NewRequestMetrics creates request metrics that log calls taking at least
+// slowThreshold at info level and all others at debug level
+func NewRequestMetrics(log *logger.Logger, slowThreshold time.Duration) *RequestMetrics {
+	return &RequestMetrics{
+		logger:        log,
+		slowThreshold: slowThreshold,
+		routes:        make(map[string][]requestSample),
+	}
+}
+
+// Middleware records each request matched by the router
+func (m *RequestMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		metricRoute := "unmatched" // Raw paths would create a series per URL
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+				metricRoute = template
+			}
+		}
+
+		// WebSocket connections live for minutes; their duration says nothing about latency
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			m.logger.Debug("%s %s upgraded to WebSocket", r.Method, route)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		countBody := !fileRoutes[route]
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK, countBody: countBody}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+		duration := time.Since(start)
+
+		m.record(r.Method+" "+route, start, duration)
+		metrics.HTTPRequestDuration.Observe(duration.Seconds(), r.Method, metricRoute, strconv.Itoa(recorder.status))
+
+		responseSize := "-"
+		if countBody {
+			responseSize = formatSize(recorder.size)
+		}
+		requestSize := "-"
+		if r.ContentLength >= 0 {
+			requestSize = formatSize(r.ContentLength)
+		}
+
+		if m.slowThreshold > 0 && duration >= m.slowThreshold {
+			m.logger.Info("Slow request: %s %s -> %d in %s (request %s, response %s)",
+				r.Method, route, recorder.status, duration.Round(time.Millisecond), requestSize, responseSize)
+		} else {
+			m.logger.Debug("%s %s -> %d in %s (request %s, response %s)",
+				r.Method, route, recorder.status, duration.Round(time.Millisecond), requestSize, responseSize)
+		}
+	})
+}
+
+// record adds a latency sample for a route, dropping samples outside the window
+func (m *RequestMetrics) record(route string, at time.Time, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	samples := append(pruneSamples(m.routes[route], at.Add(-perfWindow)), requestSample{at: at, duration: duration})
+	if len(samples) > maxSamplesPerRoute {
+		samples = samples[len(samples)-maxSamplesPerRoute:]
+	}
+	m.routes[route] = samples
+}
+
+// Snapshot returns the slowest routes of the last 15 minutes with their p50 and p95 latencies
+func (m *RequestMetrics) Snapshot() PerfSnapshot {
+	cutoff := time.Now().Add(-perfWindow)
+
+	m.mu.Lock()
+	routes := make([]RouteLatency, 0, len(m.routes))
+	for route, samples := range m.routes {
+		samples = pruneSamples(samples, cutoff)
+		if len(samples) == 0 {
+			delete(m.routes, route)
+			continue
+		}
+		m.routes[route] = samples
+
+		durations := make([]time.Duration, len(samples))
+		for i, sample := range samples {
+			durations[i] = sample.duration
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+		routes = append(routes, RouteLatency{
+			Route: route,
+			Count: len(durations),
+			P50MS: milliseconds(percentile(durations, 50)),
+			P95MS: milliseconds(percentile(durations, 95)),
+			MaxMS: milliseconds(durations[len(durations)-1]),
+		})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].P95MS != routes[j].P95MS {
+			return routes[i].P95MS > routes[j].P95MS
+		}
+		return routes[i].Route < routes[j].Route
+	})
+	if len(routes) > perfTopRoutes {
+		routes = routes[:perfTopRoutes]
+	}
+
+	return PerfSnapshot{
+		WindowMinutes:   int(perfWindow / time.Minute),
+		SlowThresholdMS: m.slowThreshold.Milliseconds(),
+		Routes:          routes,
+	}
+}
+
+// pruneSamples drops samples taken before cutoff; samples are in time order
+func pruneSamples(samples []requestSample, cutoff time.Time) []requestSample {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].at.Before(cutoff) })
+	return samples[i:]
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
+
+// responseRecorder captures the status code and, unless disabled, the
+// number of body bytes written
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	countBody   bool
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	if r.countBody {
+		r.size += int64(n)
+	}
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets handlers take over the connection through the recorder
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
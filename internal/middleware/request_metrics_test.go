@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// Requests are recorded by route template, so IDs in the path do not create
+// a route each, and media downloads are not counted into response sizes
+func TestRequestMetricsRecordsRouteTemplates(t *testing.T) {
+	m := NewRequestMetrics(logger.New(false, "error", "text"), time.Second)
+
+	body := []byte("0123456789")
+	counted := make(map[string]int64)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+		if recorder, ok := w.(*responseRecorder); ok {
+			counted[r.Method+" "+r.URL.Path] = recorder.size
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/media/temp/{filename}", handler).Methods("GET")
+	sessions := api.PathPrefix("/sessions").Subrouter()
+	sessions.HandleFunc("/{sessionId}/messages", handler).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/messages/{messageId}", handler).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/media/{messageId}", handler).Methods("GET")
+
+	requests := []struct {
+		method string
+		path   string
+		size   int64
+	}{
+		{"GET", "/api/sessions/abc/messages", int64(len(body))},
+		{"GET", "/api/sessions/def/messages", int64(len(body))},
+		{"GET", "/api/sessions/abc/messages", int64(len(body))},
+		{"DELETE", "/api/sessions/abc/messages/3EB0C767D71D", int64(len(body))},
+		{"GET", "/api/sessions/abc/media/3EB0C767D71D", 0},
+		{"GET", "/api/media/temp/photo.jpg", 0},
+	}
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != string(body) {
+			t.Fatalf("%s %s = %d %q", req.method, req.path, rec.Code, rec.Body)
+		}
+		if size, ok := counted[req.method+" "+req.path]; !ok || size != req.size {
+			t.Errorf("%s %s counted %d response bytes, want %d", req.method, req.path, size, req.size)
+		}
+	}
+
+	want := map[string]int{
+		"GET /api/sessions/{sessionId}/messages":                3,
+		"DELETE /api/sessions/{sessionId}/messages/{messageId}": 1,
+		"GET /api/sessions/{sessionId}/media/{messageId}":       1,
+		"GET /api/media/temp/{filename}":                        1,
+	}
+	snapshot := m.Snapshot()
+	got := make(map[string]int)
+	for _, route := range snapshot.Routes {
+		got[route.Route] = route.Count
+	}
+	if len(got) != len(want) {
+		t.Errorf("snapshot routes = %v, want %v", got, want)
+	}
+	for route, count := range want {
+		if got[route] != count {
+			t.Errorf("route %q recorded %d times, want %d", route, got[route], count)
+		}
+	}
+	if snapshot.WindowMinutes != 15 || snapshot.SlowThresholdMS != 1000 {
+		t.Errorf("snapshot window %d min, threshold %d ms", snapshot.WindowMinutes, snapshot.SlowThresholdMS)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 20)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		p    int
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 10 * time.Millisecond},
+		{95, 19 * time.Millisecond},
+		{100, 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%d) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(sorted[:1], 95); got != time.Millisecond {
+		t.Errorf("percentile of one sample = %v, want it", got)
+	}
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+const sessionRoutePrefix = "/api/sessions/{sessionId}"
+
+// sendRoutes are the session routes needing send:messages, by path below
+// the session
+var sendRoutes = map[string]bool{
+	"/send":               true,
+	"/send-location":      true,
+	"/send-poll":          true,
+	"/send-product":       true,
+	"/send-voice":         true,
+	"/send-attachment":    true,
+	"/send-image":         true,
+	"/send-file-url":      true,
+	"/forward":            true,
+	"/reply":              true,
+	"/react":              true,
+	"/mark-read":          true,
+	"/typing":             true,
+	"/stop-typing":        true,
+	"/set-online":         true,
+	"/presence":           true,
+	"/status":             true,
+	"/status/{messageId}": true,
+
+	"/newsletters/{newsletterJid}/send": true,
+}
+
+// readMessageRoutes are the session routes needing read:messages
+var readMessageRoutes = map[string]bool{
+	"/messages":          true,
+	"/messages/export":   true,
+	"/media/{messageId}": true,
+	"/conversations":     true,
+}
+
+// unscopedRoutes may be used with any key
+var unscopedRoutes = map[string]bool{
+	"/api/auth/whoami":  true,
+	"/api/capabilities": true,
+}
+
+// routeScope returns the scope an API key needs for a route, "" when any key
+// may use it. Routes no scope covers need the full-access scope.
+func routeScope(method, template string) string {
+	if unscopedRoutes[template] {
+		return ""
+	}
+
+	switch {
+	case template == "/api/sessions", strings.HasPrefix(template, "/api/sessions/bulk"):
+		if method == http.MethodGet {
+			return models.ScopeReadSessions
+		}
+		return models.ScopeManageSessions
+	case strings.HasPrefix(template, sessionRoutePrefix):
+		route := strings.TrimPrefix(template, sessionRoutePrefix)
+		switch {
+		case sendRoutes[route], route == "/messages/{messageId}" && method != http.MethodGet:
+			return models.ScopeSendMessages
+		case readMessageRoutes[route]:
+			return models.ScopeReadMessages
+		case route == "/sync-contacts":
+			return models.ScopeManageContacts
+		case method == http.MethodGet || method == http.MethodHead || route == "/check-number" || route == "/check-numbers":
+			return models.ScopeReadSessions
+		}
+		return models.ScopeManageSessions
+	case template == "/api/send":
+		return models.ScopeSendMessages
+	case strings.HasPrefix(template, "/api/media/"):
+		return models.ScopeReadMessages
+	case strings.HasPrefix(template, "/api/contacts"), strings.HasPrefix(template, "/api/contact-groups"):
+		return models.ScopeManageContacts
+	case strings.HasPrefix(template, "/api/bulk-messages"), strings.HasPrefix(template, "/api/campaigns"):
+		return models.ScopeManageBulk
+	case strings.HasPrefix(template, "/api/auto-replies"):
+		return models.ScopeManageAutoReplies
+	case strings.HasPrefix(template, "/api/analytics"):
+		return models.ScopeReadAnalytics
+	}
+	return models.ScopeAll
+}
+
+// RequireAPIKeyScopes rejects requests made with an API key that lacks the
+// scope of the route, or that is limited to some sessions and names another
+// one. Keys limited to sessions may only use routes naming a session, besides
+// listing sessions (filtered by the handler) and the unscoped routes. Requests
+// authenticated with a JWT pass through.
+func RequireAPIKeyScopes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := GetAuthInfo(r)
+		if !ok || info.Method != AuthMethodAPIKey {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var template string
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+
+		if scope := routeScope(r.Method, template); scope != "" && !info.HasScope(scope) {
+			http.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+
+		if info.Sessions != nil && !unscopedRoutes[template] && template != "/api/sessions" {
+			sessionID, named := mux.Vars(r)["sessionId"]
+			if !named {
+				http.Error(w, "API key is limited to some sessions and can only use routes naming a session", http.StatusForbidden)
+				return
+			}
+			if !info.SessionAllowed(sessionID) {
+				http.Error(w, "API key is not allowed to access session "+sessionID, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HasScope reports whether the credentials grant scope. JWTs and keys
+// without scopes grant everything.
+func (info *AuthInfo) HasScope(scope string) bool {
+	if info.Scopes == nil {
+		return true
+	}
+	for _, held := range info.Scopes {
+		if held == models.ScopeAll || held == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionAllowed reports whether the credentials may access a session, as
+// far as the API key's session list goes; ownership is checked separately
+func (info *AuthInfo) SessionAllowed(sessionID string) bool {
+	if info.Sessions == nil {
+		return true
+	}
+	for _, allowed := range info.Sessions {
+		if allowed == sessionID {
+			return true
+		}
+	}
+	return false
+}
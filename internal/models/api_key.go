@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// API key scopes. A key holds one or more; ScopeAll grants everything its
+// user may do, including the routes no other scope covers.
+const (
+	ScopeAll               = "*"
+	ScopeReadSessions      = "read:sessions"   // List and inspect sessions, QR codes and groups
+	ScopeManageSessions    = "manage:sessions" // Create, change, connect and delete sessions
+	ScopeSendMessages      = "send:messages"   // Send, edit, delete and react to messages
+	ScopeReadMessages      = "read:messages"   // Message history, exports, media and conversations
+	ScopeManageContacts    = "manage:contacts" // Contacts and contact groups
+	ScopeManageBulk        = "manage:bulk"     // Bulk messaging jobs and campaigns
+	ScopeManageAutoReplies = "manage:auto_replies"
+	ScopeReadAnalytics     = "read:analytics"
+)
+
+// APIKeyScopes lists the scopes a key may be created with
+var APIKeyScopes = []string{
+	ScopeAll,
+	ScopeReadSessions,
+	ScopeManageSessions,
+	ScopeSendMessages,
+	ScopeReadMessages,
+	ScopeManageContacts,
+	ScopeManageBulk,
+	ScopeManageAutoReplies,
+	ScopeReadAnalytics,
+}
+
+// IsAPIKeyScope reports whether scope is one a key may hold
+func IsAPIKeyScope(scope string) bool {
+	for _, known := range APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a named API key of a user. The key itself is only known when it
+// is created; afterwards only its hash and hint are stored.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"` // e.g. wams_...3f9a
+	Scopes     []string   `json:"scopes"`
+	Sessions   []string   `json:"sessions,omitempty"` // Omitted when every session of the user is allowed
+	RateLimit  int        `json:"rate_limit"`         // Requests per minute, 0 for unlimited
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	KeyHash    string     `json:"-"`
+}
+
+// CreateAPIKeyRequest creates a named API key
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`               // Defaults to ["*"]
+	Sessions  []string `json:"sessions,omitempty"`   // Limit the key to these sessions
+	RateLimit int      `json:"rate_limit,omitempty"` // Requests per minute, 0 for unlimited
+}
+
+// CreatedAPIKey is a new API key, the only time the key itself is returned
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Audited actions
+const (
+	AuditSessionCreated    = "session.created"
+	AuditSessionDeleted    = "session.deleted"
+	AuditSessionReassigned = "session.reassigned"
+	AuditWebhookChanged    = "session.webhook_changed"
+	AuditWebhookResumed    = "session.webhook_resumed"
+	AuditMessageSent       = "message.sent"
+	AuditMessagesExported  = "message.exported"
+	AuditUserCreated       = "user.created"
+	AuditUserUpdated       = "user.updated"
+	AuditUserDeleted       = "user.deleted"
+	AuditPasswordChanged   = "user.password_changed"
+	AuditAPIKeyCreated     = "api_key.created"
+	AuditAPIKeyRevoked     = "api_key.revoked"
+	AuditBulkJobStarted    = "bulk_job.started"
+)
+
+// Kinds of audit log targets
+const (
+	AuditTargetSession = "session"
+	AuditTargetUser    = "user"
+	AuditTargetAPIKey  = "api_key"
+	AuditTargetBulkJob = "bulk_job"
+)
+
+// AuditLog records who performed a sensitive action on what
+type AuditLog struct {
+	ID            int64                  `json:"id"`
+	ActorUserID   *int                   `json:"actor_user_id,omitempty"`
+	ActorUsername string                 `json:"actor_username,omitempty"`
+	AuthMethod    string                 `json:"auth_method,omitempty"` // jwt or api_key
+	Action        string                 `json:"action"`
+	TargetType    string                 `json:"target_type,omitempty"`
+	TargetID      string                 `json:"target_id,omitempty"`
+	IP            string                 `json:"ip,omitempty"`
+	Details       map[string]interface{} `json:"details,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+// AuditLogFilter selects audit log entries; zero fields match everything
+type AuditLogFilter struct {
+	ActorUserID int
+	Action      string // An action, or a prefix ending in "." such as "session."
+	TargetType  string
+	TargetID    string
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// AuditLogPage is a page of audit log entries, newest first
+type AuditLogPage struct {
+	Logs       []*AuditLog `json:"logs"`
+	Total      int64       `json:"total"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	TotalPages int         `json:"total_pages"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 )
 
@@ -9,11 +11,11 @@ type AutoReply struct {
 	ID          int       `json:"id"`
 	SessionID   string    `json:"session_id"`
 	Name        string    `json:"name"`
-	Trigger     string    `json:"trigger"`     // "keyword", "all", "new_contact", "time_based"
-	Keywords    []string  `json:"keywords,omitempty"`
-	Response    string    `json:"response"`
-	MediaURL    string    `json:"media_url,omitempty"`
-	MediaType   string    `json:"media_type,omitempty"`
+	Trigger     string    `json:"trigger"`     // "keyword", "regex", "all", "new_contact", "time_based"
+	Keywords    []string  `json:"keywords,omitempty"` // Regular expressions for the regex trigger
+	Response    string    `json:"response"`           // May contain variables such as {{sender_name}}
+	MediaURL    string    `json:"media_url,omitempty"` // Media sent with the response as its caption
+	MediaType   string    `json:"media_type,omitempty"` // image, video, audio or document; guessed from the download if empty
 	IsActive    bool      `json:"is_active"`
 	Priority    int       `json:"priority"`    // Higher number = higher priority
 	DelayMin    int       `json:"delay_min"`   // Minimum delay in seconds
@@ -21,10 +23,97 @@ type AutoReply struct {
 	MaxReplies  int       `json:"max_replies"` // Max replies per contact per day (0 = unlimited)
 	TimeStart   string    `json:"time_start,omitempty"` // HH:MM format
 	TimeEnd     string    `json:"time_end,omitempty"`   // HH:MM format
+	TimeZone    string    `json:"time_zone,omitempty"`  // IANA name the window is read in, server local time if empty
 	Conditions  []AutoReplyCondition `json:"conditions,omitempty"`
+	SendOptions *SendOptions `json:"send_options,omitempty"` // Overrides the session's send defaults for this rule
 	UsageCount  int       `json:"usage_count"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Version     int64      `json:"version"` // Changes with every update, see expected_version
+}
+
+// InTimeWindow reports whether t is within the rule's daily time window, read
+// in the rule's time zone. Both ends are included to the minute and the window
+// may span midnight, as 18:00 to 09:00 does; a window starting when it ends
+// covers the whole day. Rules without a window are always within it.
+func (a *AutoReply) InTimeWindow(t time.Time) bool {
+	if a.TimeStart == "" || a.TimeEnd == "" {
+		return true
+	}
+	start, err := parseClock(a.TimeStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(a.TimeEnd)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(a.Location())
+	minute := local.Hour()*60 + local.Minute()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return minute >= start && minute <= end
+	default:
+		// Spans midnight
+		return minute >= start || minute <= end
+	}
+}
+
+// Location returns the time zone the rule's time window is read in
+func (a *AutoReply) Location() *time.Location {
+	if a.TimeZone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(a.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// ValidateTimeWindow checks the parts of an auto-reply time window that are
+// set: times of day as HH:MM and an IANA time zone
+func ValidateTimeWindow(start, end, timeZone string) error {
+	if start != "" {
+		if _, err := parseClock(start); err != nil {
+			return fmt.Errorf("time_start must be a time of day as HH:MM")
+		}
+	}
+	if end != "" {
+		if _, err := parseClock(end); err != nil {
+			return fmt.Errorf("time_end must be a time of day as HH:MM")
+		}
+	}
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return fmt.Errorf("unknown time_zone %q", timeZone)
+		}
+	}
+	return nil
+}
+
+// ValidateAutoReplyContent checks what a rule matches and replies with: the
+// patterns of a regex trigger must compile, and media needs a known type
+func ValidateAutoReplyContent(trigger string, keywords []string, mediaType string) error {
+	if trigger == "regex" {
+		if len(keywords) == 0 {
+			return fmt.Errorf("keywords are required for regex trigger")
+		}
+		for _, pattern := range keywords {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid regular expression %q: %v", pattern, err)
+			}
+		}
+	}
+	switch mediaType {
+	case "", "image", "video", "audio", "document":
+	default:
+		return fmt.Errorf("media_type must be image, video, audio or document")
+	}
+	return nil
 }
 
 // AutoReplyCondition represents conditions for auto-reply triggers
@@ -36,15 +125,17 @@ type AutoReplyCondition struct {
 
 // AutoReplyLog represents a log of auto-reply actions
 type AutoReplyLog struct {
-	ID           int       `json:"id"`
-	AutoReplyID  int       `json:"auto_reply_id"`
-	SessionID    string    `json:"session_id"`
-	ContactPhone string    `json:"contact_phone"`
-	TriggerMsg   string    `json:"trigger_msg"`
-	Response     string    `json:"response"`
-	Success      bool      `json:"success"`
-	ErrorMsg     string    `json:"error_msg,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID                 int       `json:"id"`
+	AutoReplyID        int       `json:"auto_reply_id"`
+	SessionID          string    `json:"session_id"`
+	ContactPhone       string    `json:"contact_phone"`
+	TriggerMsg         string    `json:"trigger_msg"`
+	Response           string    `json:"response"`
+	Success            bool      `json:"success"`
+	ErrorMsg           string    `json:"error_msg,omitempty"`
+	MediaIncluded      bool      `json:"media_included"`
+	SubstitutionErrors []string  `json:"substitution_errors,omitempty"` // Variables of the response that could not be filled in
+	CreatedAt          time.Time `json:"created_at"`
 }
 
 // CreateAutoReplyRequest represents auto-reply creation request
@@ -62,7 +153,9 @@ type CreateAutoReplyRequest struct {
 	MaxReplies int                  `json:"max_replies,omitempty"`
 	TimeStart  string               `json:"time_start,omitempty"`
 	TimeEnd    string               `json:"time_end,omitempty"`
+	TimeZone   string               `json:"time_zone,omitempty"`
 	Conditions []AutoReplyCondition `json:"conditions,omitempty"`
+	SendOptions *SendOptions        `json:"send_options,omitempty"`
 }
 
 // UpdateAutoReplyRequest represents auto-reply update request
@@ -80,7 +173,25 @@ type UpdateAutoReplyRequest struct {
 	MaxReplies int                  `json:"max_replies,omitempty"`
 	TimeStart  string               `json:"time_start,omitempty"`
 	TimeEnd    string               `json:"time_end,omitempty"`
+	TimeZone   *string              `json:"time_zone,omitempty"` // Empty string goes back to server local time
 	Conditions []AutoReplyCondition `json:"conditions,omitempty"`
+	SendOptions *SendOptions        `json:"send_options,omitempty"`
+	ExpectedVersion *int64          `json:"expected_version,omitempty"` // Reject the update if the rule changed since this version
+}
+
+// AutoReplyLogList is a page of the logs of an auto-reply rule, newest first
+type AutoReplyLogList struct {
+	AutoReplyID int            `json:"auto_reply_id"`
+	Logs        []AutoReplyLog `json:"logs"`
+	Total       int            `json:"total"`
+	Page        int            `json:"page"`
+	Limit       int            `json:"limit"`
+	Pages       int            `json:"pages"`
+}
+
+// AutoReplyTemplateRequest names the session a built-in template is created for
+type AutoReplyTemplateRequest struct {
+	SessionID string `json:"session_id"`
 }
 
 // AutoReplyStats represents auto-reply statistics
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoReplyInTimeWindow(t *testing.T) {
+	at := func(clock string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2024, 3, 15, parsed.Hour(), parsed.Minute(), 30, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		timeZone string
+		at       string
+		want     bool
+	}{
+		{"no window", "", "", "UTC", "03:00", true},
+		{"only a start", "09:00", "", "UTC", "03:00", true},
+
+		{"daytime inside", "09:00", "17:00", "UTC", "12:00", true},
+		{"daytime at the start", "09:00", "17:00", "UTC", "09:00", true},
+		{"daytime at the end", "09:00", "17:00", "UTC", "17:00", true},
+		{"daytime before the start", "09:00", "17:00", "UTC", "08:59", false},
+		{"daytime after the end", "09:00", "17:00", "UTC", "17:01", false},
+
+		// Spans midnight
+		{"overnight in the evening", "18:00", "09:00", "UTC", "23:00", true},
+		{"overnight at midnight", "18:00", "09:00", "UTC", "00:00", true},
+		{"overnight early in the morning", "18:00", "09:00", "UTC", "02:00", true},
+		{"overnight at the start", "18:00", "09:00", "UTC", "18:00", true},
+		{"overnight at the end", "18:00", "09:00", "UTC", "09:00", true},
+		{"overnight before the start", "18:00", "09:00", "UTC", "17:59", false},
+		{"overnight after the end", "18:00", "09:00", "UTC", "09:01", false},
+		{"overnight at midday", "18:00", "09:00", "UTC", "12:00", false},
+
+		// A window starting when it ends covers the whole day
+		{"whole day at midnight", "00:00", "00:00", "UTC", "00:00", true},
+		{"whole day at midday", "00:00", "00:00", "UTC", "12:00", true},
+		{"whole day before midnight", "00:00", "00:00", "UTC", "23:59", true},
+		{"whole day from the evening", "18:00", "18:00", "UTC", "17:59", true},
+
+		// The window is read in the rule's time zone, 7 hours ahead of UTC
+		{"zone moves into the window", "09:00", "17:00", "Asia/Jakarta", "05:00", true},
+		{"zone moves out of the window", "09:00", "17:00", "Asia/Jakarta", "12:00", false},
+		{"zone at the start", "09:00", "17:00", "Asia/Jakarta", "02:00", true},
+		{"zone before the start", "09:00", "17:00", "Asia/Jakarta", "01:59", false},
+		{"zone overnight", "18:00", "09:00", "Asia/Jakarta", "15:00", true},
+		{"zone overnight after the end", "18:00", "09:00", "Asia/Jakarta", "02:01", false},
+
+		{"invalid start", "9am", "17:00", "UTC", "12:00", false},
+		{"invalid end", "09:00", "25:00", "UTC", "12:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &AutoReply{TimeStart: tt.start, TimeEnd: tt.end, TimeZone: tt.timeZone}
+			if got := rule.InTimeWindow(at(tt.at)); got != tt.want {
+				t.Errorf("InTimeWindow(%s UTC) for %s-%s in %s = %v, want %v", tt.at, tt.start, tt.end, tt.timeZone, got, tt.want)
+			}
+		})
+	}
+}
+
+// Rules without a time zone, or with one that no longer loads, use the
+// server's local time
+func TestAutoReplyLocation(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		timeZone string
+		want     *time.Location
+	}{
+		{"", time.Local},
+		{"Mars/Olympus_Mons", time.Local},
+		{"Asia/Jakarta", jakarta},
+	}
+	for _, tt := range tests {
+		rule := &AutoReply{TimeZone: tt.timeZone}
+		if got := rule.Location(); got.String() != tt.want.String() {
+			t.Errorf("Location() for %q = %s, want %s", tt.timeZone, got, tt.want)
+		}
+	}
+
+	// A window without a zone follows the server's clock
+	rule := &AutoReply{TimeStart: "09:00", TimeEnd: "17:00"}
+	if !rule.InTimeWindow(time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)) {
+		t.Error("12:00 local time is outside 09:00-17:00 without a time zone")
+	}
+	if rule.InTimeWindow(time.Date(2024, 3, 15, 20, 0, 0, 0, time.Local)) {
+		t.Error("20:00 local time is inside 09:00-17:00 without a time zone")
+	}
+}
+
+func TestValidateTimeWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		timeZone string
+		wantErr  bool
+	}{
+		{"empty", "", "", "", false},
+		{"overnight window", "18:00", "09:00", "", false},
+		{"whole day in a zone", "00:00", "00:00", "Asia/Jakarta", false},
+		{"only a zone", "", "", "UTC", false},
+		{"start without a colon", "0900", "17:00", "", true},
+		{"hour out of range", "09:00", "24:00", "", true},
+		{"minute out of range", "09:60", "17:00", "", true},
+		{"unknown zone", "09:00", "17:00", "Mars/Olympus_Mons", true},
+		{"offset instead of a zone", "09:00", "17:00", "+07:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTimeWindow(tt.start, tt.end, tt.timeZone)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTimeWindow(%q, %q, %q) = %v, want error %v", tt.start, tt.end, tt.timeZone, err, tt.wantErr)
+			}
+		})
+	}
+}
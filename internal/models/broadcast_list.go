@@ -0,0 +1,9 @@
+package models
+
+// BroadcastList is a broadcast list mirrored from the phone
+type BroadcastList struct {
+	JID        string   `json:"jid"` // …@broadcast, accepted as a send recipient
+	Name       string   `json:"name"`
+	Recipients []string `json:"recipients"`           // Member JIDs, by phone number when known
+	UpdatedAt  string   `json:"updated_at,omitempty"` // When the mirror last changed, RFC3339 UTC
+}
@@ -1,24 +1,28 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // Campaign represents a messaging campaign
 type Campaign struct {
 	ID              int                `json:"id"`
+	UserID          int                `json:"user_id,omitempty"` // Owner of the contacts messaged
 	Name            string             `json:"name"`
 	Description     string             `json:"description,omitempty"`
-	TemplateID      int                `json:"template_id"`
+	TemplateID      int                `json:"template_id,omitempty"`
 	Template        *MessageTemplate   `json:"template,omitempty"`
+	Message         string             `json:"message,omitempty"` // Plain text sent instead of a template
 	GroupID         *int               `json:"group_id,omitempty"`
 	Group           *ContactGroup      `json:"group,omitempty"`
 	ContactIDs      []int              `json:"contact_ids,omitempty"`
 	SessionID       string             `json:"session_id"`
-	Status          string             `json:"status"` // "draft", "scheduled", "running", "paused", "completed", "failed"
+	Status          string             `json:"status"` // "scheduled", "starting" or "cancelled" before the start, then the status of its bulk job
+	JobID           string             `json:"job_id,omitempty"` // Bulk messaging job sending the campaign, once started
 	DelayBetween    int                `json:"delay_between"` // seconds between messages
 	RandomDelay     bool               `json:"random_delay"`  // add random delay variation
-	ScheduledAt     *time.Time         `json:"scheduled_at,omitempty"`
+	ScheduledAt     *time.Time         `json:"scheduled_at,omitempty"` // UTC
 	StartedAt       *time.Time         `json:"started_at,omitempty"`
 	CompletedAt     *time.Time         `json:"completed_at,omitempty"`
 	TotalContacts   int                `json:"total_contacts"`
@@ -26,6 +30,7 @@ type Campaign struct {
 	FailedCount     int                `json:"failed_count"`
 	PendingCount    int                `json:"pending_count"`
 	Variables       map[string]string  `json:"variables,omitempty"`
+	Throttle        *BulkThrottle      `json:"throttle,omitempty"`
 	CreatedAt       time.Time          `json:"created_at"`
 	UpdatedAt       *time.Time         `json:"updated_at,omitempty"`
 }
@@ -48,29 +53,40 @@ type CampaignMessage struct {
 type CreateCampaignRequest struct {
 	Name         string            `json:"name" validate:"required"`
 	Description  string            `json:"description,omitempty"`
-	TemplateID   int               `json:"template_id" validate:"required"`
+	TemplateID   int               `json:"template_id,omitempty"`
+	Message      string            `json:"message,omitempty"` // Plain text, with the same {{variables}} as templates
 	GroupID      *int              `json:"group_id,omitempty"`
 	ContactIDs   []int             `json:"contact_ids,omitempty"`
 	SessionID    string            `json:"session_id" validate:"required"`
 	DelayBetween int               `json:"delay_between,omitempty"`
 	RandomDelay  bool              `json:"random_delay,omitempty"`
-	ScheduledAt  *time.Time        `json:"scheduled_at,omitempty"`
+	ScheduledAt  *time.Time        `json:"scheduled_at" validate:"required"` // RFC 3339, with a time zone offset
 	Variables    map[string]string `json:"variables,omitempty"`
+	Throttle     *BulkThrottle     `json:"throttle,omitempty"`
 }
 
+// Statuses of a campaign before its bulk messaging job starts
+const (
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusStarting  = "starting" // Claimed by the scheduler of one instance
+	CampaignStatusCancelled = "cancelled"
+	CampaignStatusFailed    = "failed"
+)
+
 // UpdateCampaignRequest represents campaign update request
 type UpdateCampaignRequest struct {
 	Name         string            `json:"name,omitempty"`
 	Description  string            `json:"description,omitempty"`
 	TemplateID   int               `json:"template_id,omitempty"`
+	Message      string            `json:"message,omitempty"`
 	GroupID      *int              `json:"group_id,omitempty"`
 	ContactIDs   []int             `json:"contact_ids,omitempty"`
 	SessionID    string            `json:"session_id,omitempty"`
-	DelayBetween int               `json:"delay_between,omitempty"`
-	RandomDelay  bool              `json:"random_delay,omitempty"`
+	DelayBetween *int              `json:"delay_between,omitempty"`
+	RandomDelay  *bool             `json:"random_delay,omitempty"`
 	ScheduledAt  *time.Time        `json:"scheduled_at,omitempty"`
 	Variables    map[string]string `json:"variables,omitempty"`
-	Status       string            `json:"status,omitempty"`
+	Status       string            `json:"status,omitempty"` // Only CampaignStatusCancelled, to cancel before the start
 }
 
 // CampaignStats represents campaign statistics
@@ -92,15 +108,86 @@ type CampaignListResponse struct {
 	Pages     int        `json:"pages"`
 }
 
-// BulkMessageRequest represents a direct bulk message request (without campaigns)
+// BulkMessageRequest represents a direct bulk message request (without campaigns).
+// The text is either a template or message; the recipients are either stored
+// contacts (contact_ids, group_id, segment) or given inline as recipients.
 type BulkMessageRequest struct {
-	SessionID    string            `json:"session_id" validate:"required"`
-	TemplateID   int               `json:"template_id" validate:"required"`
-	ContactIDs   []int             `json:"contact_ids,omitempty"`
-	GroupID      *int              `json:"group_id,omitempty"`
-	DelayBetween int               `json:"delay_between,omitempty"`
-	RandomDelay  bool              `json:"random_delay,omitempty"`
-	Variables    map[string]string `json:"variables,omitempty"`
+	SessionID      string            `json:"session_id,omitempty"`
+	SessionIDs     []string          `json:"session_ids,omitempty"`     // Sessions to rotate between instead of session_id
+	SessionWeights map[string]int    `json:"session_weights,omitempty"` // Relative share of messages per session of session_ids, equal when unset
+	TemplateID     int               `json:"template_id,omitempty"`
+	Message        string            `json:"message,omitempty"`      // Plain text, with the same {{variables}} as templates
+	Recipients     []BulkRecipient   `json:"recipients,omitempty"`   // Numbers to message without stored contacts
+	SkipInvalid    bool              `json:"skip_invalid,omitempty"` // Start with the valid recipients instead of rejecting the job
+	CheckNumbers   bool              `json:"check_numbers,omitempty"` // Leave out recipients not on WhatsApp, checked before the job starts
+	ContactIDs     []int             `json:"contact_ids,omitempty"`
+	GroupID        *int              `json:"group_id,omitempty"`
+	Segment        string            `json:"segment,omitempty"` // "engaged", "dormant" or "never_replied"
+	DelayBetween   int               `json:"delay_between,omitempty"`
+	RandomDelay    bool              `json:"random_delay,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty"`
+	SendOptions    *SendOptions      `json:"send_options,omitempty"` // Overrides the session's send defaults for this job
+	Throttle       *BulkThrottle     `json:"throttle,omitempty"`     // Throughput limits and quiet hours, defaults when unset
+}
+
+// BulkRecipient is a recipient of a bulk message given inline. It is written
+// as a phone number, or as an object to also give a name for {{name}}.
+type BulkRecipient struct {
+	Phone string `json:"phone"`
+	Name  string `json:"name,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare phone number as well as a recipient object
+func (r *BulkRecipient) UnmarshalJSON(data []byte) error {
+	var number string
+	if err := json.Unmarshal(data, &number); err == nil {
+		*r = BulkRecipient{Phone: number}
+		return nil
+	}
+	type recipient BulkRecipient
+	return json.Unmarshal(data, (*recipient)(r))
+}
+
+// InvalidRecipient is a recipient left out of a bulk job before it started:
+// an inline recipient whose number cannot be messaged, or one not on WhatsApp
+type InvalidRecipient struct {
+	Index     int    `json:"index"` // Position in recipients, or among the selected contacts, from 0
+	Phone     string `json:"phone"`
+	ContactID int    `json:"contact_id,omitempty"` // Stored contact left out by check_numbers
+	Reason    string `json:"reason"`
+}
+
+// InvalidReasonNotOnWhatsApp is the reason of recipients left out by check_numbers
+const InvalidReasonNotOnWhatsApp = "not on WhatsApp"
+
+// Outcomes of a bulk message to one recipient
+const (
+	BulkResultPending = "pending"
+	BulkResultSent    = "sent"
+	BulkResultFailed  = "failed"
+)
+
+// BulkMessageResult is the outcome of a bulk messaging job for one recipient
+type BulkMessageResult struct {
+	Index     int        `json:"index"` // Position of the recipient in the job, from 0
+	Phone     string     `json:"phone"`
+	ContactID int        `json:"contact_id,omitempty"` // 0 for inline recipients
+	Name      string     `json:"name,omitempty"`
+	Status    string     `json:"status"`               // BulkResultPending, BulkResultSent or BulkResultFailed
+	SessionID string     `json:"session_id,omitempty"` // Session that handled the recipient
+	MessageID string     `json:"message_id,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
+
+// BulkMessageResultList is a page of the per-recipient results of a bulk messaging job
+type BulkMessageResultList struct {
+	JobID   string              `json:"job_id"`
+	Results []BulkMessageResult `json:"results"`
+	Total   int                 `json:"total"`
+	Page    int                 `json:"page"`
+	Limit   int                 `json:"limit"`
+	Pages   int                 `json:"pages"`
 }
 
 // BulkMessageResponse represents bulk message operation response
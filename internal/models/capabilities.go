@@ -0,0 +1,16 @@
+package models
+
+// DefaultSessionLimit is the number of sessions a non-admin user may create
+// unless a different limit is configured for them. A limit of 0 is unlimited.
+const DefaultSessionLimit = 5
+
+// Capabilities describes what this server build supports so clients can do
+// feature detection instead of probing endpoints
+type Capabilities struct {
+	Version          string          `json:"version"`
+	WhatsmeowVersion string          `json:"whatsmeow_version"`
+	GoVersion        string          `json:"go_version"`
+	Features         map[string]bool `json:"features"`
+	Limits           map[string]int  `json:"limits"`
+	Endpoints        map[string]bool `json:"endpoints"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// Product is an item of a WhatsApp Business catalog
+type Product struct {
+	ID          string `json:"id"`
+	RetailerID  string `json:"retailer_id,omitempty"` // The business's own SKU
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	Price1000   int64  `json:"price_amount_1000"` // Price times 1000, e.g. 12500 for 12.50
+	Currency    string `json:"currency,omitempty"`
+	Hidden      bool   `json:"hidden"`
+	Status      string `json:"status,omitempty"` // Review status, e.g. APPROVED or REJECTED
+}
+
+// Catalog is one page of a business account's catalog
+type Catalog struct {
+	Products []*Product `json:"products"`
+	Next     string     `json:"next,omitempty"` // Cursor of the next page, empty on the last page
+}
+
+// SendProductRequest sends a product of the session's own catalog
+type SendProductRequest struct {
+	To        string `json:"to"`
+	ProductID string `json:"product_id"`
+	Body      string `json:"body,omitempty"` // Text shown with the product
+	Footer    string `json:"footer,omitempty"`
+	SendOptions
+}
+
+// WebhookOrder describes a cart order, carried in the webhook of an order message
+type WebhookOrder struct {
+	OrderID   string              `json:"order_id"`
+	Title     string              `json:"title,omitempty"`
+	Message   string              `json:"message,omitempty"` // Note the customer added to the order
+	Seller    string              `json:"seller,omitempty"`
+	Status    string              `json:"status,omitempty"` // inquiry, accepted or declined
+	ItemCount int                 `json:"item_count"`
+	Total1000 int64               `json:"total_amount_1000"` // Total times 1000
+	Currency  string              `json:"currency,omitempty"`
+	Items     []*WebhookOrderItem `json:"items"` // Empty when the order details could not be fetched
+}
+
+// WebhookOrderItem is a product line of an order
+type WebhookOrderItem struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	ImageURL  string `json:"image_url,omitempty"`
+	Price1000 int64  `json:"price_amount_1000"`
+	Currency  string `json:"currency,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
@@ -7,8 +7,11 @@ import (
 // Contact represents a contact in the CRM system
 type Contact struct {
 	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"` // Owner of the contact; set from the request, not the body
 	Name        string    `json:"name"`
 	Phone       string    `json:"phone"`
+	PhoneE164   string    `json:"phone_e164,omitempty"`    // Computed, not stored
+	PhoneDisplay string   `json:"phone_display,omitempty"` // Computed, not stored
 	Email       string    `json:"email,omitempty"`
 	Company     string    `json:"company,omitempty"`
 	Position    string    `json:"position,omitempty"`
@@ -18,13 +21,91 @@ type Contact struct {
 	Notes       string    `json:"notes,omitempty"`
 	IsActive    bool      `json:"is_active"`
 	LastContact *time.Time `json:"last_contact,omitempty"`
+	InboundCount  int      `json:"inbound_count"`  // Messages received from the contact
+	OutboundCount int      `json:"outbound_count"` // Messages sent to the contact
+	EngagementScore   int        `json:"engagement_score"`
+	LastInboundAt     *time.Time `json:"last_inbound_at,omitempty"`
+	RepliedToCampaign bool       `json:"replied_to_campaign"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Version     int64      `json:"version"` // Changes with every update, see expected_version
+}
+
+// Contact segments derived from the engagement score
+const (
+	SegmentEngaged      = "engaged"       // score at or above the engaged threshold
+	SegmentDormant      = "dormant"       // replied before, but not within the dormant window
+	SegmentNeverReplied = "never_replied" // no inbound message on record
+)
+
+// Default segment thresholds, used when a user has not configured their own
+const (
+	DefaultEngagedMinScore  = 50
+	DefaultDormantAfterDays = 60
+)
+
+// IsValidSegment reports whether segment is a known contact segment
+func IsValidSegment(segment string) bool {
+	switch segment {
+	case SegmentEngaged, SegmentDormant, SegmentNeverReplied:
+		return true
+	}
+	return false
+}
+
+// SegmentThresholds holds the score/date thresholds that define the contact segments
+type SegmentThresholds struct {
+	EngagedMinScore  int `json:"engaged_min_score"`
+	DormantAfterDays int `json:"dormant_after_days"`
+}
+
+// DefaultSegmentThresholds returns the built-in segment thresholds
+func DefaultSegmentThresholds() SegmentThresholds {
+	return SegmentThresholds{
+		EngagedMinScore:  DefaultEngagedMinScore,
+		DormantAfterDays: DefaultDormantAfterDays,
+	}
+}
+
+// UpdateSegmentThresholdsRequest represents a segment settings update request.
+// A nil field resets that threshold to its default.
+type UpdateSegmentThresholdsRequest struct {
+	EngagedMinScore  *int `json:"engaged_min_score"`
+	DormantAfterDays *int `json:"dormant_after_days"`
+}
+
+// ContactEngagement is the computed engagement data for a single contact
+type ContactEngagement struct {
+	ContactID         int
+	Score             int
+	LastInboundAt     *time.Time
+	RepliedToCampaign bool
+}
+
+// ContactPhoneKey identifies the contacts of one user with one E.164 number
+type ContactPhoneKey struct {
+	UserID int
+	Phone  string
+}
+
+// ContactActivity is message traffic with a contact, accumulated since the last write
+type ContactActivity struct {
+	ContactID   int
+	LastContact time.Time
+	Inbound     int
+	Outbound    int
+}
+
+// InboundActivity summarizes received messages from one phone number
+type InboundActivity struct {
+	LastAt      time.Time
+	RecentCount int
 }
 
 // ContactGroup represents a contact group for marketing campaigns
 type ContactGroup struct {
 	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description,omitempty"`
 	Color       string    `json:"color,omitempty"`
@@ -57,6 +138,7 @@ type UpdateContactRequest struct {
 	Tags     []string `json:"tags,omitempty"`
 	Notes    string   `json:"notes,omitempty"`
 	IsActive *bool    `json:"is_active,omitempty"`
+	ExpectedVersion *int64 `json:"expected_version,omitempty"` // Reject the update if the contact changed since this version
 }
 
 // CreateContactGroupRequest represents contact group creation request
@@ -79,10 +161,42 @@ type ContactImportResult struct {
 	Success     int      `json:"success"`
 	Failed      int      `json:"failed"`
 	Duplicates  int      `json:"duplicates"`
+	Updated     int      `json:"updated,omitempty"` // Existing contacts changed by a sync
 	Total       int      `json:"total"`
+	DryRun      bool     `json:"dry_run,omitempty"` // Nothing was written, the counts are what a sync would do
 	Errors      []string `json:"errors,omitempty"`
 }
 
+// SyncContactsRequest copies a session's WhatsApp contacts into the contacts table
+type SyncContactsRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// ContactDedupRequest finds, and with Merge merges, contacts sharing a phone number
+type ContactDedupRequest struct {
+	Merge bool `json:"merge"` // Without it nothing is changed and the result is a preview
+}
+
+// ContactDuplicateCluster is a set of contacts whose numbers normalize to the same E.164 number
+type ContactDuplicateCluster struct {
+	UserID   int       `json:"user_id"`   // Owner of the contacts
+	Phone    string    `json:"phone"`     // E.164
+	KeepID   int       `json:"keep_id"`   // The oldest contact, which the others are merged into
+	MergeIDs []int     `json:"merge_ids"` // Contacts removed by a merge
+	Contacts []Contact `json:"contacts"`  // Oldest first
+	Merged   *Contact  `json:"merged"`    // The kept contact as it is, or would be, after the merge
+}
+
+// ContactDedupResult reports the duplicate clusters found and what was changed
+type ContactDedupResult struct {
+	Clusters   []ContactDuplicateCluster `json:"clusters"`
+	Duplicates int                       `json:"duplicates"` // Contacts merged, or to be merged, into another
+	Normalized int                       `json:"normalized"` // Other contacts whose number is, or would be, rewritten in E.164
+	Unparsable []int                     `json:"unparsable,omitempty"` // Contacts whose number could not be normalized
+	Merged     bool                      `json:"merged"`
+	Errors     []string                  `json:"errors,omitempty"` // Clusters that failed to merge; the others are merged regardless
+}
+
 // BulkContactRequest represents a bulk contact operation request
 type BulkContactRequest struct {
 	ContactIDs []int  `json:"contact_ids" validate:"required"`
@@ -96,6 +210,9 @@ type ContactSearchRequest struct {
 	GroupID *int   `json:"group_id,omitempty"`
 	Tags    []string `json:"tags,omitempty"`
 	IsActive *bool  `json:"is_active,omitempty"`
+	Segment string `json:"segment,omitempty"`
+	Thresholds SegmentThresholds `json:"-"` // Applied when Segment is set
+	UserID  int    `json:"-"` // Owner of the contacts; 0 searches every user's
 	Page    int    `json:"page,omitempty"`
 	Limit   int    `json:"limit,omitempty"`
 }
@@ -116,6 +233,8 @@ type SmartContactDetection struct {
 	Email       string   `json:"email,omitempty"`
 	Company     string   `json:"company,omitempty"`
 	Position    string   `json:"position,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
 	Confidence  float64  `json:"confidence"`
 	Source      string   `json:"source"`
 	RawData     string   `json:"raw_data"`
@@ -1,6 +1,9 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Custom error types for proper HTTP status code handling
 
@@ -40,6 +43,88 @@ func (e ServiceUnavailableError) Error() string {
 	return e.Message
 }
 
+// ForbiddenError represents a 403 error for an action the session's account may not perform
+type ForbiddenError struct {
+	Message string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.Message
+}
+
+// SessionBannedError represents a 403 error for a session WhatsApp has temporarily banned
+type SessionBannedError struct {
+	Message     string
+	BannedUntil time.Time
+}
+
+func (e SessionBannedError) Error() string {
+	return e.Message
+}
+
+// PayloadTooLargeError represents a 413 error for content above a size limit
+type PayloadTooLargeError struct {
+	Message string
+}
+
+func (e PayloadTooLargeError) Error() string {
+	return e.Message
+}
+
+// RateLimitedError represents a 429 error returned when WhatsApp rate-limits a session
+type RateLimitedError struct {
+	Message string
+}
+
+func (e RateLimitedError) Error() string {
+	return e.Message
+}
+
+// EditWindowExpiredError represents a 400 error for editing a message WhatsApp
+// no longer accepts edits of
+type EditWindowExpiredError struct {
+	Message string
+	SentAt  time.Time
+}
+
+func (e EditWindowExpiredError) Error() string {
+	return e.Message
+}
+
+// ConflictError represents a 409 error for an update based on an outdated version
+type ConflictError struct {
+	Message        string
+	CurrentVersion int64
+	Current        interface{} // Current state of the resource, returned to the client when set
+}
+
+func (e ConflictError) Error() string {
+	return e.Message
+}
+
+// NotReadyError represents a 425 error for something that is being prepared
+// and should be asked for again shortly
+type NotReadyError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e NotReadyError) Error() string {
+	return e.Message
+}
+
+// SessionLimitError represents a 403 error for a user who already has as many
+// sessions as their session limit allows
+type SessionLimitError struct {
+	Message string
+	Count   int
+	Limit   int
+}
+
+func (e SessionLimitError) Error() string {
+	return e.Message
+}
+
 // Helper functions to create errors
 
 func NewNotFoundError(format string, args ...interface{}) error {
@@ -58,6 +143,48 @@ func NewServiceUnavailableError(format string, args ...interface{}) error {
 	return ServiceUnavailableError{Message: fmt.Sprintf(format, args...)}
 }
 
+func NewForbiddenError(format string, args ...interface{}) error {
+	return ForbiddenError{Message: fmt.Sprintf(format, args...)}
+}
+
+func NewSessionBannedError(sessionID string, until time.Time, reason string) error {
+	return SessionBannedError{
+		Message:     fmt.Sprintf("session %s is temporarily banned by WhatsApp until %s (%s)", sessionID, FormatTimestamp(until), reason),
+		BannedUntil: until,
+	}
+}
+
+func NewPayloadTooLargeError(format string, args ...interface{}) error {
+	return PayloadTooLargeError{Message: fmt.Sprintf(format, args...)}
+}
+
+func NewRateLimitedError(format string, args ...interface{}) error {
+	return RateLimitedError{Message: fmt.Sprintf(format, args...)}
+}
+
+func NewEditWindowExpiredError(messageID string, sentAt time.Time) error {
+	return EditWindowExpiredError{
+		Message: fmt.Sprintf("message %s was sent %s ago, WhatsApp only accepts edits within %s", messageID, time.Since(sentAt).Round(time.Minute), EditWindow),
+		SentAt:  sentAt,
+	}
+}
+
+func NewNotReadyError(retryAfter time.Duration, format string, args ...interface{}) error {
+	return NotReadyError{Message: fmt.Sprintf(format, args...), RetryAfter: retryAfter}
+}
+
+func NewConflictError(currentVersion int64, format string, args ...interface{}) error {
+	return ConflictError{Message: fmt.Sprintf(format, args...), CurrentVersion: currentVersion}
+}
+
+func NewSessionLimitError(count, limit int) error {
+	return SessionLimitError{
+		Message: fmt.Sprintf("session limit reached: %d/%d sessions used", count, limit),
+		Count:   count,
+		Limit:   limit,
+	}
+}
+
 // Common errors
 var (
 	ErrSessionNotFound         = NewNotFoundError("session not found")
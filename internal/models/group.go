@@ -0,0 +1,85 @@
+package models
+
+// Longest group name and description WhatsApp accepts
+const (
+	GroupNameMaxLength  = 25
+	GroupTopicMaxLength = 2048
+)
+
+// CreateGroupRequest represents a request to create a group
+type CreateGroupRequest struct {
+	Name         string   `json:"name"`
+	Participants []string `json:"participants"` // Phone numbers or JIDs; the session's account is added implicitly
+}
+
+// GroupParticipantsRequest lists the participants of a group to change
+type GroupParticipantsRequest struct {
+	Participants []string `json:"participants"` // Phone numbers or JIDs
+}
+
+// Outcomes of a participant change, for GroupParticipantResult
+const (
+	GroupParticipantOK             = "ok"
+	GroupParticipantInviteRequired = "invite_required" // Privacy settings prevent adding; WhatsApp sent an invite instead
+	GroupParticipantNotAuthorized  = "not_authorized"
+	GroupParticipantNotFound       = "not_found"
+	GroupParticipantRecentlyLeft   = "recently_left"
+	GroupParticipantAlreadyMember  = "already_member"
+	GroupParticipantFailed         = "failed"
+)
+
+// GroupParticipantResult is WhatsApp's answer for one participant of a change
+type GroupParticipantResult struct {
+	JID              string `json:"jid"`
+	Phone            string `json:"phone,omitempty"` // E.164, when WhatsApp reveals it
+	Status           string `json:"status"`
+	ErrorCode        int    `json:"error_code,omitempty"`
+	InviteCode       string `json:"invite_code,omitempty"`       // For invite_required
+	InviteExpiration string `json:"invite_expiration,omitempty"` // RFC3339 UTC
+}
+
+// GroupResponse describes a group after it was created
+type GroupResponse struct {
+	JID          string                    `json:"jid"`
+	Name         string                    `json:"name"`
+	Owner        string                    `json:"owner,omitempty"`
+	Created      string                    `json:"created"`
+	Participants []*GroupParticipantResult `json:"participants"`
+}
+
+// GroupNameRequest sets the name (subject) of a group
+type GroupNameRequest struct {
+	Name string `json:"name"`
+}
+
+// GroupTopicRequest sets the description of a group; empty removes it
+type GroupTopicRequest struct {
+	Topic string `json:"topic"`
+}
+
+// GroupPhotoRequest sets the picture of a group
+type GroupPhotoRequest struct {
+	Image string `json:"image"` // Base64 JPEG, ideally 640x640
+}
+
+// JoinGroupRequest joins a group from an invite link
+type JoinGroupRequest struct {
+	Link string `json:"link"` // https://chat.whatsapp.com/ link or just its invite code
+}
+
+// GroupInvitePreview describes the group behind an invite link
+type GroupInvitePreview struct {
+	JID              string `json:"jid"`
+	Name             string `json:"name"`
+	Topic            string `json:"topic,omitempty"`
+	Size             int    `json:"size"`
+	Created          string `json:"created,omitempty"`
+	ApprovalRequired bool   `json:"approval_required"` // Joining needs an admin's approval
+}
+
+// JoinGroupResponse reports a joined group
+type JoinGroupResponse struct {
+	JID             string `json:"jid"`
+	Name            string `json:"name"`
+	PendingApproval bool   `json:"pending_approval"` // Requested to join, an admin has to approve
+}
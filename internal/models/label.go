@@ -0,0 +1,14 @@
+package models
+
+// ChatLabel is a WhatsApp Business chat label mirrored from the phone
+type ChatLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color int    `json:"color"` // Index into WhatsApp's label color palette
+}
+
+// ChatLabelRequest assigns a label to a chat, by ID or by name
+type ChatLabelRequest struct {
+	LabelID string `json:"label_id,omitempty"`
+	Label   string `json:"label,omitempty"` // Label name, used when label_id is empty
+}
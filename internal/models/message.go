@@ -6,6 +6,7 @@ import "time"
 type SendMessageRequest struct {
 	To      string `json:"to"`
 	Message string `json:"message"`
+	SendOptions
 }
 
 // SendImageRequest represents an image send request
@@ -13,6 +14,8 @@ type SendImageRequest struct {
 	To      string `json:"to"`
 	Image   string `json:"image"`   // Base64 encoded image
 	Caption string `json:"caption"`
+	ViewOnce bool  `json:"view_once,omitempty"` // The recipient can open the image once
+	SendOptions
 }
 
 // SendFileRequest represents a file send request
@@ -21,6 +24,7 @@ type SendFileRequest struct {
 	File     string `json:"file"`     // Base64 encoded file
 	FileName string `json:"filename"`
 	Caption  string `json:"caption"`
+	SendOptions
 }
 
 // SendFileURLRequest represents a file send request from URL
@@ -30,6 +34,18 @@ type SendFileURLRequest struct {
 	FileName string `json:"filename,omitempty"`
 	Caption  string `json:"caption,omitempty"`
 	Type     string `json:"type,omitempty"` // image, video, audio, document
+	NoCache  bool   `json:"no_cache,omitempty"` // Re-download and re-upload even if the URL was sent recently
+	KeepLocal bool  `json:"keep_local,omitempty"` // Keep a copy of the download in storage under downloads/
+	SendOptions
+}
+
+// SendVoiceRequest represents a voice note send request. Audio that is not
+// OGG/Opus is converted when ffmpeg is available.
+type SendVoiceRequest struct {
+	To    string `json:"to"`
+	Audio string `json:"audio,omitempty"` // Base64 encoded audio
+	URL   string `json:"url,omitempty"`   // Audio URL to download, used when audio is empty
+	SendOptions
 }
 
 // SendLocationRequest represents a location send request
@@ -37,6 +53,44 @@ type SendLocationRequest struct {
 	To        string  `json:"to"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+	SendOptions
+}
+
+// Poll limits enforced by WhatsApp
+const (
+	PollMinOptions = 2
+	PollMaxOptions = 12
+)
+
+// SendPollRequest represents a poll message request
+type SendPollRequest struct {
+	To              string   `json:"to"`
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`          // 2 to 12 distinct options
+	SelectableCount int      `json:"selectable_count"` // How many options a voter may pick; 0 allows any number
+	SendOptions
+}
+
+// PollContent is stored as the content of logged poll messages, so votes can
+// be matched to option names
+type PollContent struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+// WebhookPollVote describes a vote, carried in the webhook of a poll_vote message
+type WebhookPollVote struct {
+	PollID               string   `json:"poll_id"`                // Message ID of the poll
+	SelectedOptions      []string `json:"selected_options"`       // Option names, known for polls sent through this API
+	SelectedOptionHashes []string `json:"selected_option_hashes"` // Hex SHA-256 of each option name; empty when the vote was withdrawn
+}
+
+// WebhookInteractiveReply describes a tapped list row or button, carried in the
+// webhook of a list_reply or button_reply message
+type WebhookInteractiveReply struct {
+	ID          string `json:"id"`                    // Row or button ID given by whoever sent the list or buttons
+	Title       string `json:"title"`                 // Text of the selected row or button
+	Description string `json:"description,omitempty"` // Description of the selected list row
 }
 
 // MessageResponse represents a message response
@@ -50,28 +104,94 @@ type MessageResponse struct {
 // WebhookMessage represents a message for webhook delivery
 type WebhookMessage struct {
 	SessionID   string    `json:"session_id"`
-	From        string    `json:"from"`
+	From        string    `json:"from"`                  // Sender JID without its device
+	FromDevice  string    `json:"from_device,omitempty"` // Sender JID as received, with the device, e.g. 628987654321:12@s.whatsapp.net
 	FromName    string    `json:"from_name"`
-	To          string    `json:"to"`
+	FromPhone   string    `json:"from_phone,omitempty"` // Sender number in E.164, empty for LID-only senders
+	To          string    `json:"to"`                   // The session's own JID, empty when it logged out meanwhile
+	Chat        string    `json:"chat"`                 // The sender for direct messages, the group or channel otherwise
 	Message     string    `json:"message"`
 	MessageType string    `json:"message_type"`
-	Timestamp   time.Time `json:"timestamp"`
+	Timestamp   time.Time `json:"timestamp"` // Deprecated: use message_timestamp
+	MessageTimestamp string `json:"message_timestamp,omitempty"` // WhatsApp message time, RFC3339 UTC
+	ReceivedAt  string    `json:"received_at,omitempty"`         // When this server processed the message, RFC3339 UTC
+	ServerLatencyMS int64 `json:"server_latency_ms,omitempty"`   // received_at minus message_timestamp
 	ID          string    `json:"id"`
 	IsGroup     bool      `json:"is_group"`
 	GroupID     string    `json:"group_id,omitempty"`
+	IsStatus    bool      `json:"is_status,omitempty"` // A contact's status update, sent with receive_status
+	IsNewsletter  bool    `json:"is_newsletter,omitempty"`  // Posted to a channel the session follows
+	NewsletterJID string  `json:"newsletter_jid,omitempty"` // The channel, …@newsletter
 	MediaURL    string    `json:"media_url,omitempty"`
+	IsViewOnce  bool      `json:"is_view_once,omitempty"` // View-once media, only downloaded with allow_view_once_capture
+	FirstContact bool     `json:"first_contact,omitempty"` // First message ever received from this number, see new contact detection
+	PollVote    *WebhookPollVote `json:"poll_vote,omitempty"` // Set for message_type poll_vote
+	Order       *WebhookOrder    `json:"order,omitempty"`     // Set for message_type order
+	InteractiveReply *WebhookInteractiveReply `json:"interactive_reply,omitempty"` // Set for message_type list_reply and button_reply
+	TargetMessageID string   `json:"target_message_id,omitempty"` // Message reacted to, for message_type reaction
+	IsPTT       bool      `json:"is_ptt,omitempty"`   // Audio recorded as a voice note rather than sent as a file
+	Duration    int       `json:"duration,omitempty"` // Length of audio in seconds
+	EphemeralExpiration int `json:"ephemeral_expiration,omitempty"` // Disappearing message timer in seconds; the message vanishes that long after it was sent
+
+	// Set when the message replies to another message
+	QuotedMessageID   string `json:"quoted_message_id,omitempty"`
+	QuotedMessageText string `json:"quoted_message_text,omitempty"` // Text or caption of the quoted message
+	QuotedParticipant string `json:"quoted_participant,omitempty"`  // JID of the quoted message's sender
+}
+
+// WebhookNewContact is sent when a number writes to a session for the first time
+type WebhookNewContact struct {
+	Type        string `json:"type"` // Always "new_contact"
+	SessionID   string `json:"session_id"`
+	From        string `json:"from"`                  // Sender JID without its device
+	FromDevice  string `json:"from_device,omitempty"` // Sender JID as received
+	FromName    string `json:"from_name"`
+	FromPhone   string `json:"from_phone,omitempty"`
+	MessageID   string `json:"message_id"`
+	FirstSeenAt string `json:"first_seen_at"`        // RFC3339 UTC
+	ContactID   int    `json:"contact_id,omitempty"` // CRM contact created for the number, if enabled
 }
 
 // WebhookReceipt represents a read/delivery receipt for webhook delivery
 type WebhookReceipt struct {
+	EventType     string    `json:"event_type"` // Always "receipt"
 	SessionID     string    `json:"session_id"`
 	MessageIDs    []string  `json:"message_ids"`
 	Sender        string    `json:"sender"`
 	Chat          string    `json:"chat"`
 	MessageSender string    `json:"message_sender,omitempty"`
-	Timestamp     time.Time `json:"timestamp"`
+	Timestamp     time.Time `json:"timestamp"` // Deprecated: use receipt_timestamp
+	ReceiptTimestamp string `json:"receipt_timestamp,omitempty"` // RFC3339 UTC
+	ReceivedAt    string    `json:"received_at,omitempty"`       // RFC3339 UTC
 	Type          string    `json:"type"`
 	Status        string    `json:"status"`
+	BroadcastList string    `json:"broadcast_list,omitempty"` // The list the message was sent to; sender is the recipient this receipt is from
+}
+
+// SetTimes fills the RFC3339 timestamp fields. Sessions that opted into the
+// legacy webhook format only receive the deprecated timestamp field.
+func (m *WebhookMessage) SetTimes(messageTime, receivedAt time.Time, legacy bool) {
+	m.Timestamp = messageTime
+	if legacy {
+		return
+	}
+
+	m.MessageTimestamp = FormatTimestamp(messageTime)
+	m.ReceivedAt = FormatTimestamp(receivedAt)
+	if latency := receivedAt.Sub(messageTime).Milliseconds(); latency > 0 {
+		m.ServerLatencyMS = latency
+	}
+}
+
+// SetTimes fills the RFC3339 timestamp fields, see WebhookMessage.SetTimes
+func (r *WebhookReceipt) SetTimes(receiptTime, receivedAt time.Time, legacy bool) {
+	r.Timestamp = receiptTime
+	if legacy {
+		return
+	}
+
+	r.ReceiptTimestamp = FormatTimestamp(receiptTime)
+	r.ReceivedAt = FormatTimestamp(receivedAt)
 }
 
 // ContactInfo represents WhatsApp contact information
@@ -88,6 +208,7 @@ type ForwardMessageRequest struct {
 	To        string `json:"to"`         // Recipient JID
 	MessageID string `json:"message_id"` // Message ID to forward
 	Text      string `json:"text"`       // Message text content to forward
+	SendOptions
 }
 
 // ReplyMessageRequest represents a reply message request
@@ -95,6 +216,97 @@ type ReplyMessageRequest struct {
 	To              string `json:"to"`               // Recipient JID
 	Message         string `json:"message"`          // Reply message content
 	QuotedMessageID string `json:"quoted_message_id"` // ID of message being replied to
+	QuotedSender    string `json:"quoted_sender,omitempty"` // Sender of the quoted message; "me" for the session's own, defaults to the logged sender or, in one-to-one chats, the chat
+	SendOptions
+}
+
+// Receipt types accepted by MarkReadRequest
+const (
+	MarkReadTypeRead   = "read"   // Blue ticks for the messages
+	MarkReadTypePlayed = "played" // Voice messages were listened to
+	MarkReadTypeSeen   = "seen"   // Read receipts, and the whole chat marked read on every device
+)
+
+// ReactRequest represents a request to react to a message with an emoji
+type ReactRequest struct {
+	Chat      string `json:"chat"`             // Chat JID or phone number the message is in
+	MessageID string `json:"message_id"`       // Message to react to
+	Sender    string `json:"sender,omitempty"` // Sender of the message; "me" for the session's own messages, defaults to the chat in one-to-one chats
+	Emoji     string `json:"emoji"`            // A single emoji; empty removes the session's reaction
+}
+
+// Ways to delete a message, for DeleteMessageRequest
+const (
+	DeleteForEveryone = "everyone" // Revoke the message for every participant
+	DeleteForMe       = "me"       // Remove it only from the session's own devices
+)
+
+// RevokeWindow is how long after sending WhatsApp accepts deleting a message
+// for everyone
+const RevokeWindow = 60 * time.Hour
+
+// DeleteMessageRequest represents a request to delete a message
+type DeleteMessageRequest struct {
+	Chat string `json:"chat,omitempty"` // Chat JID or phone number; optional when the message was logged
+	For  string `json:"for,omitempty"`  // everyone (default) or me
+}
+
+// DeleteMessageResponse reports a deleted message
+type DeleteMessageResponse struct {
+	MessageID       string `json:"message_id"`
+	Chat            string `json:"chat"`
+	For             string `json:"for"`
+	RevokeMessageID string `json:"revoke_message_id,omitempty"` // ID of the revoke sent, for everyone
+}
+
+// EditWindow is how long after sending WhatsApp accepts edits of a message
+const EditWindow = 15 * time.Minute
+
+// EditMessageRequest represents a request to edit a sent text message
+type EditMessageRequest struct {
+	Chat string `json:"chat,omitempty"` // Chat JID or phone number; defaults to the chat the message was logged in
+	Text string `json:"text"`           // New text of the message
+}
+
+// EditMessageResponse reports an edited message
+type EditMessageResponse struct {
+	MessageID     string `json:"message_id"`
+	Chat          string `json:"chat"`
+	Text          string `json:"text"`
+	EditMessageID string `json:"edit_message_id"` // ID of the edit sent
+	EditedAt      string `json:"edited_at"`
+}
+
+// MarkReadRequest represents a request to mark received messages as read
+type MarkReadRequest struct {
+	Chat       string   `json:"chat"`                  // Chat JID or phone number
+	MessageIDs []string `json:"message_ids,omitempty"` // Omit for every message received in the chat since it was last marked read
+	Sender     string   `json:"sender,omitempty"`      // Sender of message_ids, required in groups
+	Type       string   `json:"type,omitempty"`        // read (default), played or seen
+}
+
+// MarkReadResponse reports what was marked read
+type MarkReadResponse struct {
+	Chat       string   `json:"chat"`
+	MessageIDs []string `json:"message_ids"`
+	ChatMarked bool     `json:"chat_marked"` // The whole chat was marked read (type seen)
+}
+
+// MaxNumberChecks bounds the numbers of one CheckNumbersRequest
+const MaxNumberChecks = 500
+
+// CheckNumbersRequest represents a request to check many numbers at once
+type CheckNumbersRequest struct {
+	Numbers []string `json:"numbers"` // Phone numbers, at most MaxNumberChecks
+}
+
+// NumberCheckResult tells whether a number is on WhatsApp
+type NumberCheckResult struct {
+	Number string `json:"number"`          // As given
+	Phone  string `json:"phone,omitempty"` // E.164, absent for an invalid number
+	Exists bool   `json:"exists"`
+	JID    string `json:"jid,omitempty"`   // Canonical JID of a registered number
+	Error  string `json:"error,omitempty"` // Why the number is invalid
 }
 
 // Conversation represents a chat/conversation in WhatsApp
@@ -103,11 +315,61 @@ type Conversation struct {
 	Name          string     `json:"name"`
 	IsGroup       bool       `json:"is_group"`
 	LastMessageID string     `json:"last_message_id,omitempty"`
-	LastMessage   string     `json:"last_message,omitempty"`
+	LastMessageText string   `json:"last_message_text,omitempty"`
+	LastMessageType string   `json:"last_message_type,omitempty"`
 	LastMessageTime *time.Time `json:"last_message_time,omitempty"`
+	LastMessageFromMe bool   `json:"last_message_from_me,omitempty"`
+	LastMessageSender string `json:"last_message_sender,omitempty"`      // Group chats: JID of the last message's sender
+	LastMessageSenderName string `json:"last_message_sender_name,omitempty"` // Group chats: contact name of the last message's sender
 	UnreadCount   int        `json:"unread_count"`
 	IsPinned      bool       `json:"is_pinned"`
 	IsMuted       bool       `json:"is_muted"`
 	IsArchived    bool       `json:"is_archived"`
 	Avatar        string     `json:"avatar,omitempty"`
-}
\ No newline at end of file
+	Labels        []string   `json:"labels,omitempty"` // WhatsApp Business label names
+}
+// HistoryMessage is a logged message in a session's message history
+type HistoryMessage struct {
+	ID        int64  `json:"id"` // Position in the history, pass as before_id to page past it
+	MessageID string `json:"message_id"`
+	Chat      string `json:"chat"`             // Chat JID; older messages sent through the API keep the recipient as given
+	Sender    string `json:"sender,omitempty"` // Sender JID of received messages
+	Direction string `json:"direction"`        // sent or received
+	Type      string `json:"type"`
+	Content   string `json:"content,omitempty"`
+	MediaURL  string `json:"media_url,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"`
+	EditedAt  string `json:"edited_at,omitempty"` // When the text was last edited
+}
+
+// MessageHistoryResponse is a page of a session's message history, newest first
+type MessageHistoryResponse struct {
+	Messages   []HistoryMessage `json:"messages"`
+	Total      int64            `json:"total"`                 // Messages matching the filters, on every page
+	NextCursor int64            `json:"next_cursor,omitempty"` // before_id of the next page; absent on the last page
+}
+
+// Orders of the conversation list, for ConversationQuery
+const (
+	ConversationSortLastActivity = "last_activity" // Most recent message first; chats without messages last
+	ConversationSortName         = "name"
+)
+
+// ConversationQuery selects and pages the conversations of a session
+type ConversationQuery struct {
+	Label string // Only chats with this label
+	Sort  string // last_activity (default) or name
+	Page  int    // 1-based
+	Limit int    // Conversations per page; 0 returns every conversation
+}
+
+// ConversationList is a page of a session's conversations
+type ConversationList struct {
+	Conversations []*Conversation `json:"conversations"`
+	Count         int             `json:"count"` // Conversations on this page
+	Total         int             `json:"total"` // Conversations on every page
+	Page          int             `json:"page"`
+	Limit         int             `json:"limit,omitempty"`
+}
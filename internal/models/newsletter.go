@@ -0,0 +1,31 @@
+package models
+
+// Newsletter describes a WhatsApp channel
+type Newsletter struct {
+	JID         string `json:"jid"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InviteCode  string `json:"invite_code,omitempty"`
+	InviteLink  string `json:"invite_link,omitempty"`
+	Subscribers int    `json:"subscribers"`
+	Verified    bool   `json:"verified"`
+	State       string `json:"state,omitempty"` // active, suspended or geosuspended
+	Role        string `json:"role,omitempty"`  // owner, admin, subscriber or guest; empty when looked up by invite
+	Muted       bool   `json:"muted"`
+	Created     string `json:"created,omitempty"` // RFC3339 UTC
+}
+
+// FollowNewsletterRequest names a channel to follow or unfollow
+type FollowNewsletterRequest struct {
+	Newsletter string `json:"newsletter"` // Invite link, invite code or channel JID (…@newsletter)
+}
+
+// SendNewsletterRequest is a post to a channel: a text, or a media file
+// with an optional caption
+type SendNewsletterRequest struct {
+	Message  string `json:"message,omitempty"`
+	File     string `json:"file,omitempty"` // Base64 encoded file
+	FileName string `json:"filename,omitempty"`
+	Type     string `json:"type,omitempty"` // image, video, audio or document; detected from the file when empty
+	Caption  string `json:"caption,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+// Longest push name and about text WhatsApp accepts
+const (
+	ProfileNameMaxLength  = 25
+	ProfileAboutMaxLength = 139
+)
+
+// Profile is the WhatsApp profile of a session's own account
+type Profile struct {
+	JID        string `json:"jid"`
+	PushName   string `json:"push_name"`
+	About      string `json:"about"`
+	PictureID  string `json:"picture_id,omitempty"`
+	PictureURL string `json:"picture_url,omitempty"` // Temporary download URL; absent when no picture is set
+}
+
+// ProfileNameRequest sets the push name of a session's account
+type ProfileNameRequest struct {
+	Name string `json:"name"`
+}
+
+// ProfileAboutRequest sets the about text of a session's account
+type ProfileAboutRequest struct {
+	About string `json:"about"`
+}
+
+// ProfilePictureRequest sets the profile picture of a session's account
+type ProfilePictureRequest struct {
+	Image string `json:"image"` // Base64 JPEG, ideally 640x640
+}
+
+// ContactAvatar is the profile picture of a contact or group
+type ContactAvatar struct {
+	JID        string `json:"jid"`
+	PictureID  string `json:"picture_id"`
+	PictureURL string `json:"picture_url"` // Temporary WhatsApp download URL
+	Type       string `json:"type"`        // image (full size) or preview (thumbnail)
+}
+
+// BusinessCategory is a category a WhatsApp business lists itself under
+type BusinessCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// BusinessHours are the opening hours of a WhatsApp business on one day
+type BusinessHours struct {
+	DayOfWeek string `json:"day_of_week"`
+	Mode      string `json:"mode"` // specific_hours, open_24h or appointment_only
+	OpenTime  string `json:"open_time,omitempty"`
+	CloseTime string `json:"close_time,omitempty"`
+}
+
+// BusinessProfile is the public profile of a WhatsApp business account
+type BusinessProfile struct {
+	JID           string             `json:"jid"`
+	VerifiedName  string             `json:"verified_name,omitempty"`
+	About         string             `json:"about,omitempty"`
+	Email         string             `json:"email,omitempty"`
+	Address       string             `json:"address,omitempty"`
+	Categories    []BusinessCategory `json:"categories"`
+	HoursTimeZone string             `json:"business_hours_timezone,omitempty"`
+	Hours         []BusinessHours    `json:"business_hours"`
+	Options       map[string]string  `json:"profile_options"` // Such as commerce_experience (catalog or shop) and cart_enabled
+}
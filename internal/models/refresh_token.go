@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RefreshToken is a long-lived token exchanged for new access tokens. Only
+// its hash is stored; each use revokes it and issues a new one.
+type RefreshToken struct {
+	ID        int64
+	UserID    int
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time // Exchanged for a new token
+	RevokedAt *time.Time // Logged out or invalidated
+}
+
+// RefreshRequest exchanges a refresh token for a new access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutRequest revokes a refresh token, or with All every token of its user
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all,omitempty"`
+}
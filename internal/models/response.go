@@ -1,5 +1,16 @@
 package models
 
+import "time"
+
+// FormatTimestamp renders t as an RFC3339 UTC string, the format used for
+// timestamps the API emits. Zero times render as an empty string.
+func FormatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
 // StandardResponse represents the standard API response structure
 type StandardResponse struct {
 	Success bool        `json:"success"`
@@ -40,4 +51,10 @@ const (
 	ErrCodeAlreadyExists       = "ALREADY_EXISTS"
 	ErrCodeRateLimited         = "RATE_LIMITED"
 	ErrCodeForbidden           = "FORBIDDEN"
+	ErrCodeSessionBanned       = "SESSION_BANNED"
+	ErrCodeConflict            = "CONFLICT"
+	ErrCodePayloadTooLarge     = "PAYLOAD_TOO_LARGE"
+	ErrCodeEditWindowExpired   = "EDIT_WINDOW_EXPIRED"
+	ErrCodeNotReady            = "NOT_READY"
+	ErrCodeSessionLimitReached = "SESSION_LIMIT_REACHED"
 )
\ No newline at end of file
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Disappearing message timers accepted by WhatsApp, in seconds
+const (
+	EphemeralOff     = 0
+	Ephemeral24Hours = 24 * 60 * 60
+	Ephemeral7Days   = 7 * 24 * 60 * 60
+	Ephemeral90Days  = 90 * 24 * 60 * 60
+)
+
+// EphemeralTimers names the disappearing message timers for chat settings
+var EphemeralTimers = map[string]int{
+	"off": EphemeralOff,
+	"24h": Ephemeral24Hours,
+	"7d":  Ephemeral7Days,
+	"90d": Ephemeral90Days,
+}
+
+// ChatEphemeralRequest sets the disappearing message timer of a chat
+type ChatEphemeralRequest struct {
+	Timer string `json:"timer"` // off, 24h, 7d or 90d
+}
+
+// SendOptions controls how a message is sent. Used both as a session's send
+// defaults and on individual send requests; unset fields fall back to the
+// session defaults, then to the built-in defaults (all off).
+type SendOptions struct {
+	LinkPreview         *bool `json:"link_preview,omitempty"`         // Attach a preview of the first link in text messages
+	SimulateTyping      *bool `json:"simulate_typing,omitempty"`      // Show "typing..." before sending
+	MarkRead            *bool `json:"mark_read,omitempty"`            // Mark the chat's received messages read after sending
+	EphemeralExpiration *int  `json:"ephemeral_expiration,omitempty"` // Disappearing message timer in seconds
+}
+
+// EffectiveSendOptions are send options with every default resolved
+type EffectiveSendOptions struct {
+	LinkPreview         bool `json:"link_preview"`
+	SimulateTyping      bool `json:"simulate_typing"`
+	MarkRead            bool `json:"mark_read"`
+	EphemeralExpiration int  `json:"ephemeral_expiration"`
+}
+
+// ResolveSendOptions merges request options over session defaults; the request wins
+func ResolveSendOptions(request, defaults *SendOptions) EffectiveSendOptions {
+	var effective EffectiveSendOptions
+	for _, opts := range []*SendOptions{defaults, request} {
+		if opts == nil {
+			continue
+		}
+		if opts.LinkPreview != nil {
+			effective.LinkPreview = *opts.LinkPreview
+		}
+		if opts.SimulateTyping != nil {
+			effective.SimulateTyping = *opts.SimulateTyping
+		}
+		if opts.MarkRead != nil {
+			effective.MarkRead = *opts.MarkRead
+		}
+		if opts.EphemeralExpiration != nil {
+			effective.EphemeralExpiration = *opts.EphemeralExpiration
+		}
+	}
+	return effective
+}
+
+// Validate checks that the options hold values WhatsApp accepts
+func (o *SendOptions) Validate() error {
+	if o == nil || o.EphemeralExpiration == nil {
+		return nil
+	}
+
+	switch *o.EphemeralExpiration {
+	case EphemeralOff, Ephemeral24Hours, Ephemeral7Days, Ephemeral90Days:
+		return nil
+	}
+	return fmt.Errorf("ephemeral_expiration must be one of %d, %d, %d or %d seconds",
+		EphemeralOff, Ephemeral24Hours, Ephemeral7Days, Ephemeral90Days)
+}
+
+// ParseSendOptions decodes send options, rejecting unknown keys so a typo
+// is reported instead of silently having no effect
+func ParseSendOptions(data []byte) (*SendOptions, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	opts := &SendOptions{}
+	if err := decoder.Decode(opts); err != nil {
+		return nil, fmt.Errorf("invalid send options: %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
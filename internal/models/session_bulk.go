@@ -0,0 +1,51 @@
+package models
+
+// Actions of POST /sessions/bulk
+const (
+	SessionBulkConnect    = "connect"
+	SessionBulkDisconnect = "disconnect"
+	SessionBulkEnable     = "enable"
+	SessionBulkDisable    = "disable"
+	SessionBulkDelete     = "delete"
+)
+
+// MaxSessionBulkTargets bounds how many sessions one bulk action may name
+const MaxSessionBulkTargets = 500
+
+// Statuses of a SessionBulkJob
+const (
+	SessionBulkRunning   = "running"
+	SessionBulkCompleted = "completed"
+)
+
+// SessionBulkRequest applies one action to many sessions, named either by
+// ID or by tag
+type SessionBulkRequest struct {
+	Action     string   `json:"action"` // connect, disconnect, enable, disable or delete
+	SessionIDs []string `json:"session_ids,omitempty"`
+	Tag        string   `json:"tag,omitempty"`        // Every session of the caller with this tag
+	PurgeData  bool     `json:"purge_data,omitempty"` // delete only: also delete the sessions' stored media
+}
+
+// SessionBulkResult is the outcome of a bulk action for one session
+type SessionBulkResult struct {
+	SessionID string `json:"session_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SessionBulkJob is a bulk action and its progress. Connects run in the
+// background and are polled with GET /sessions/bulk/{jobId}; other actions
+// are answered once done.
+type SessionBulkJob struct {
+	ID          string               `json:"id"`
+	Action      string               `json:"action"`
+	Status      string               `json:"status"` // running or completed
+	Total       int                  `json:"total"`
+	Done        int                  `json:"done"`
+	Succeeded   int                  `json:"succeeded"`
+	Failed      int                  `json:"failed"`
+	Results     []*SessionBulkResult `json:"results"`                // Sessions done so far, in completion order
+	CreatedAt   string               `json:"created_at"`             // RFC3339
+	CompletedAt string               `json:"completed_at,omitempty"` // RFC3339
+}
@@ -0,0 +1,192 @@
+package models
+
+import "time"
+
+// The connection state and auto reply text of a session are written by
+// whatsmeow event handlers, connection goroutines and API requests at the same
+// time, so they are only accessed through these methods, which hold the
+// session's own lock. Other session fields are guarded by the lock of
+// the service holding the session.
+
+// SessionState is a snapshot of a session's connection state
+type SessionState struct {
+	Connected  bool // The connection to WhatsApp is open
+	LoggedIn   bool // The device is linked and authenticated
+	Connecting bool // A connection attempt started by ConnectSession is in progress
+
+	ReconnectAttempts    int       // Reconnects scheduled since the connection was last open
+	NextReconnectAt      time.Time // When the next reconnect is due, zero if none is scheduled
+	NeedsAttention       bool      // Reconnecting was given up and waits for a manual reconnect
+	LastDisconnectReason string
+	LastDisconnectedAt   time.Time
+	LastConnectedAt      time.Time
+}
+
+// Connection statuses reported by SessionState.ConnectionStatus
+const (
+	ConnectionStatusConnected      = "connected"
+	ConnectionStatusConnecting     = "connecting"
+	ConnectionStatusReconnecting   = "reconnecting"
+	ConnectionStatusNeedsAttention = "needs_attention"
+	ConnectionStatusDisconnected   = "disconnected"
+)
+
+// ConnectionStatus summarizes the state as one of the ConnectionStatus constants
+func (state SessionState) ConnectionStatus() string {
+	switch {
+	case state.Connected:
+		return ConnectionStatusConnected
+	case state.Connecting:
+		return ConnectionStatusConnecting
+	case state.NeedsAttention:
+		return ConnectionStatusNeedsAttention
+	case !state.NextReconnectAt.IsZero():
+		return ConnectionStatusReconnecting
+	default:
+		return ConnectionStatusDisconnected
+	}
+}
+
+// Session states reported by Session.AuthState
+const (
+	AuthStateConnected = "connected"  // Linked and connected
+	AuthStateLoggedIn  = "logged_in"  // Linked, but the connection is not open
+	AuthStateNeedsAuth = "needs_auth" // No linked device; waiting for a QR scan or pairing code
+)
+
+// SessionHealth is the connection health of a session
+type SessionHealth struct {
+	SessionID            string `json:"session_id"`
+	Status               string `json:"status"`
+	Connected            bool   `json:"connected"`
+	LoggedIn             bool   `json:"logged_in"`
+	ClientConnected      bool   `json:"client_connected"`
+	ClientLoggedIn       bool   `json:"client_logged_in"`
+	ReconnectAttempts    int    `json:"reconnect_attempts"`
+	MaxReconnectAttempts int    `json:"max_reconnect_attempts"`
+	NextReconnectAt      string `json:"next_reconnect_at,omitempty"`
+	LastDisconnectReason string `json:"last_disconnect_reason,omitempty"`
+	LastDisconnectedAt   string `json:"last_disconnected_at,omitempty"`
+	LastConnectedAt      string `json:"last_connected_at,omitempty"`
+}
+
+// SessionCounts counts the sessions of an instance by state
+type SessionCounts struct {
+	Total          int `json:"total"`
+	Enabled        int `json:"enabled"`
+	Connected      int `json:"connected"`
+	LoggedIn       int `json:"logged_in"`
+	NeedsAuth      int `json:"needs_auth"`      // No linked device; waiting for a QR scan or pairing code
+	NeedsAttention int `json:"needs_attention"` // Reconnecting was given up
+}
+
+// State returns the session's connection state
+func (s *Session) State() SessionState {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state
+}
+
+// AuthState summarizes whether the session is linked and connected as one of
+// the AuthState constants
+func (s *Session) AuthState() string {
+	state := s.State()
+	switch {
+	case s.Client == nil || s.Client.Store.ID == nil:
+		return AuthStateNeedsAuth
+	case state.Connected && state.LoggedIn:
+		return AuthStateConnected
+	default:
+		return AuthStateLoggedIn
+	}
+}
+
+// IsConnected reports whether the session's connection to WhatsApp is open
+func (s *Session) IsConnected() bool {
+	return s.State().Connected
+}
+
+// IsLoggedIn reports whether the session's device is logged in
+func (s *Session) IsLoggedIn() bool {
+	return s.State().LoggedIn
+}
+
+// IsConnecting reports whether a connection attempt is in progress
+func (s *Session) IsConnecting() bool {
+	return s.State().Connecting
+}
+
+// UpdateState changes the connection state with update, atomically, and
+// returns the state as it was before
+func (s *Session) UpdateState(update func(state *SessionState)) SessionState {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	previous := s.state
+	update(&s.state)
+	return previous
+}
+
+// SetConnected records whether the connection is open and logged in. Once it
+// is open, any connection attempt ends and reconnecting starts over.
+func (s *Session) SetConnected(connected, loggedIn bool) {
+	s.UpdateState(func(state *SessionState) {
+		state.Connected = connected
+		state.LoggedIn = loggedIn
+		if connected {
+			state.Connecting = false
+			state.ReconnectAttempts = 0
+			state.NextReconnectAt = time.Time{}
+			state.NeedsAttention = false
+			state.LastConnectedAt = time.Now()
+		}
+	})
+}
+
+// SetLoggedIn records whether the device is logged in
+func (s *Session) SetLoggedIn(loggedIn bool) {
+	s.UpdateState(func(state *SessionState) {
+		state.LoggedIn = loggedIn
+	})
+}
+
+// SetConnecting records whether a connection attempt is in progress
+func (s *Session) SetConnecting(connecting bool) {
+	s.UpdateState(func(state *SessionState) {
+		state.Connecting = connecting
+	})
+}
+
+// StartConnecting marks a connection attempt as in progress unless one
+// already is or the session is connected, and returns the state before
+func (s *Session) StartConnecting() (previous SessionState, started bool) {
+	previous = s.UpdateState(func(state *SessionState) {
+		if !state.Connecting && !state.Connected {
+			state.Connecting = true
+		}
+	})
+	return previous, !previous.Connecting && !previous.Connected
+}
+
+// SetDisconnected records that the connection is closed and returns the
+// state before
+func (s *Session) SetDisconnected() SessionState {
+	return s.UpdateState(func(state *SessionState) {
+		state.Connected = false
+		state.LoggedIn = false
+		state.Connecting = false
+	})
+}
+
+// AutoReply returns the text sent in reply to incoming messages, nil if none
+func (s *Session) AutoReply() *string {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.autoReplyText
+}
+
+// SetAutoReply sets the text sent in reply to incoming messages, nil for none
+func (s *Session) SetAutoReply(text *string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.autoReplyText = text
+}
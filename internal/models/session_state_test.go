@@ -0,0 +1,156 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Concurrent connects must start only one connection attempt
+func TestStartConnectingOnlyOnce(t *testing.T) {
+	session := &Session{ID: "race"}
+
+	const callers = 50
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, ok := session.StartConnecting(); ok {
+				started.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := started.Load(); n != 1 {
+		t.Fatalf("%d connection attempts started, want 1", n)
+	}
+	if state := session.State(); !state.Connecting || state.Connected {
+		t.Errorf("state = %+v, want connecting", state)
+	}
+}
+
+// Connects, disconnects and readers racing on one session; run with -race.
+// A session is never seen connecting and connected at once.
+func TestSessionStateConcurrentConnectDisconnect(t *testing.T) {
+	session := &Session{ID: "race"}
+
+	const rounds = 500
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	fail := func(msg string) {
+		select {
+		case errs <- msg:
+		default:
+		}
+	}
+
+	// Connectors: start an attempt and finish it by connecting or failing
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				if _, ok := session.StartConnecting(); !ok {
+					continue
+				}
+				if (i+n)%2 == 0 {
+					session.SetConnected(true, true)
+				} else {
+					session.SetConnecting(false)
+				}
+			}
+		}(i)
+	}
+
+	// Disconnectors: close the connection as a disconnect event would
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				session.SetDisconnected()
+				session.UpdateState(func(state *SessionState) {
+					state.LastDisconnectReason = "test"
+					state.ReconnectAttempts++
+				})
+			}
+		}()
+	}
+
+	// Readers: check every snapshot
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				state := session.State()
+				if state.Connecting && state.Connected {
+					fail("session seen connecting and connected at once")
+				}
+				if state.Connected && !state.LoggedIn {
+					fail("session seen connected without being logged in")
+				}
+				_ = state.ConnectionStatus()
+				_ = session.IsConnected()
+				_ = session.AuthState()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+
+	// Settle in a known state: once disconnected, one attempt starts again
+	session.SetDisconnected()
+	if _, ok := session.StartConnecting(); !ok {
+		t.Error("no connection attempt started after disconnecting")
+	}
+	if _, ok := session.StartConnecting(); ok {
+		t.Error("second connection attempt started while one is in progress")
+	}
+	session.SetConnected(true, true)
+	if state := session.State(); state.Connecting || !state.Connected || state.ReconnectAttempts != 0 {
+		t.Errorf("state after connecting = %+v, want connected with reconnects reset", state)
+	}
+	if _, ok := session.StartConnecting(); ok {
+		t.Error("connection attempt started on a connected session")
+	}
+}
+
+func TestAutoReplyConcurrent(t *testing.T) {
+	session := &Session{ID: "race"}
+	texts := []string{"away", "back soon"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				if n%3 == 0 {
+					session.SetAutoReply(nil)
+				} else {
+					session.SetAutoReply(&texts[(i+n)%2])
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				if text := session.AutoReply(); text != nil && *text != texts[0] && *text != texts[1] {
+					t.Errorf("auto reply = %q", *text)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
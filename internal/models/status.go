@@ -0,0 +1,45 @@
+package models
+
+// Status (story) posting limits, enforced before anything is uploaded
+const (
+	StatusMaxTextLength   = 700              // Characters in a text status
+	StatusMaxImageBytes   = 5 * 1024 * 1024  // JPEG or PNG
+	StatusMaxVideoBytes   = 16 * 1024 * 1024 // MP4
+	StatusMaxVideoSeconds = 60
+)
+
+// PostStatusRequest represents a WhatsApp Status post. Text statuses use
+// text with optional colors and font; image and video statuses take the
+// media as base64 in media or from media_url, with an optional caption.
+type PostStatusRequest struct {
+	Type            string `json:"type"` // text, image or video
+	Text            string `json:"text,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"` // #RRGGBB or #AARRGGBB
+	TextColor       string `json:"text_color,omitempty"`       // #RRGGBB or #AARRGGBB
+	Font            string `json:"font,omitempty"`             // e.g. system, system_bold, fb_script, courierprime_bold
+	Media           string `json:"media,omitempty"`            // Base64 encoded image or video
+	MediaURL        string `json:"media_url,omitempty"`
+	Caption         string `json:"caption,omitempty"`
+}
+
+// StatusResponse describes a posted status
+type StatusResponse struct {
+	MessageID string `json:"message_id"`
+	Type      string `json:"type"`
+	PostedAt  string `json:"posted_at"`  // RFC3339 UTC
+	ExpiresAt string `json:"expires_at"` // Statuses disappear 24 hours after posting
+}
+
+// WebhookStatusUpdate is sent when a contact views or reacts to one of the
+// session's own statuses
+type WebhookStatusUpdate struct {
+	Type      string   `json:"type"`  // Always "status_update"
+	Event     string   `json:"event"` // viewed, played or reaction
+	SessionID string   `json:"session_id"`
+	StatusIDs []string `json:"status_ids"`
+	From      string   `json:"from"`
+	FromName  string   `json:"from_name,omitempty"`
+	FromPhone string   `json:"from_phone,omitempty"`
+	Reaction  string   `json:"reaction,omitempty"` // Emoji of a reaction; empty when a reaction was removed
+	Timestamp string   `json:"timestamp"`          // RFC3339 UTC
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // Quiet hours name IANA time zones, which the host may not have
+)
+
+// Default throughput of a bulk messaging job, well below the rates that get
+// numbers banned
+const (
+	DefaultBulkMaxPerMinute = 12
+	DefaultBulkMaxPerHour   = 300
+	DefaultBulkBreakEvery   = 50
+	DefaultBulkBreakSeconds = 600
+)
+
+// BulkThrottle limits how fast a bulk messaging job sends. The per-minute and
+// per-hour limits count every bulk message of the session, so jobs sharing a
+// session share them. Unset limits take the defaults.
+type BulkThrottle struct {
+	MaxPerMinute int         `json:"max_per_minute,omitempty"`
+	MaxPerHour   int         `json:"max_per_hour,omitempty"`
+	BreakEvery   int         `json:"break_every,omitempty"`   // Take a break after this many messages
+	BreakSeconds int         `json:"break_seconds,omitempty"` // Length of the break
+	QuietHours   *QuietHours `json:"quiet_hours,omitempty"`   // Daily period without sending
+}
+
+// QuietHours is a daily period without sending, such as 22:00 to 07:00,
+// which may span midnight
+type QuietHours struct {
+	Start    string `json:"start"`               // "HH:MM"
+	End      string `json:"end"`                 // "HH:MM"
+	TimeZone string `json:"time_zone,omitempty"` // IANA name such as "Asia/Jakarta", UTC if empty
+}
+
+// WithDefaults returns the throttle with its unset limits filled in
+func (t *BulkThrottle) WithDefaults() *BulkThrottle {
+	resolved := BulkThrottle{}
+	if t != nil {
+		resolved = *t
+	}
+	if resolved.MaxPerMinute == 0 {
+		resolved.MaxPerMinute = DefaultBulkMaxPerMinute
+	}
+	if resolved.MaxPerHour == 0 {
+		resolved.MaxPerHour = DefaultBulkMaxPerHour
+	}
+	if resolved.BreakEvery == 0 {
+		resolved.BreakEvery = DefaultBulkBreakEvery
+	}
+	if resolved.BreakSeconds == 0 {
+		resolved.BreakSeconds = DefaultBulkBreakSeconds
+	}
+	return &resolved
+}
+
+// Validate checks that the limits are not negative and the quiet hours are
+// well formed
+func (t *BulkThrottle) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.MaxPerMinute < 0 || t.MaxPerHour < 0 || t.BreakEvery < 0 || t.BreakSeconds < 0 {
+		return fmt.Errorf("throttle limits cannot be negative")
+	}
+	if t.QuietHours != nil {
+		return t.QuietHours.Validate()
+	}
+	return nil
+}
+
+// Validate checks that the quiet hours are two different times of day in a
+// known time zone
+func (q *QuietHours) Validate() error {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return fmt.Errorf("quiet_hours start must be a time of day as HH:MM")
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return fmt.Errorf("quiet_hours end must be a time of day as HH:MM")
+	}
+	if start == end {
+		return fmt.Errorf("quiet_hours start and end must differ")
+	}
+	if _, err := time.LoadLocation(q.TimeZone); err != nil {
+		return fmt.Errorf("unknown quiet_hours time_zone %q", q.TimeZone)
+	}
+	return nil
+}
+
+// Until returns when the quiet hours around t end, or the zero time if t is
+// outside them
+func (q *QuietHours) Until(t time.Time) time.Time {
+	location, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return time.Time{}
+	}
+	start, _ := parseClock(q.Start)
+	end, _ := parseClock(q.End)
+
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	quiet := minute >= start && minute < end
+	if start > end {
+		// Spans midnight
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, location)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// parseClock returns the minute of the day of a time given as HH:MM
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
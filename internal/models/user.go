@@ -7,12 +7,13 @@ type User struct {
 	ID           int        `json:"id"`
 	Username     string     `json:"username"`
 	Password     string     `json:"-"` // Don't include in JSON responses
-	APIKey       string     `json:"-"` // Don't include in JSON responses for security
 	Role         string     `json:"role"`
-	SessionLimit int        `json:"session_limit"`
+	SessionLimit int        `json:"session_limit"` // 0 is unlimited
 	IsActive     bool       `json:"is_active"`
+	DefaultRegion string    `json:"default_region,omitempty"` // ISO region used to format phone numbers, e.g. "ID"
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	TokensInvalidatedAt *time.Time `json:"-"` // Tokens issued before this are rejected
 }
 
 // UserRole constants
@@ -29,9 +30,12 @@ type LoginRequest struct {
 
 // LoginResponse represents the response after successful login
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token"`
-	User    *User  `json:"user"`
+	Success          bool   `json:"success"`
+	Token            string `json:"token"`
+	ExpiresAt        string `json:"expires_at"`                   // Access token expiry, RFC3339 UTC
+	RefreshToken     string `json:"refresh_token,omitempty"`      // Exchange at /api/auth/refresh before ExpiresAt
+	RefreshExpiresAt string `json:"refresh_expires_at,omitempty"` // RFC3339 UTC
+	User             *User  `json:"user"`
 }
 
 // RegisterRequest represents user registration request
@@ -58,7 +62,8 @@ type CreateUserRequest struct {
 	Username     string `json:"username"`
 	Password     string `json:"password"`
 	Role         string `json:"role"`
-	SessionLimit int    `json:"session_limit"`
+	SessionLimit *int   `json:"session_limit"` // Defaults to DefaultSessionLimit, 0 is unlimited
+	DefaultRegion string `json:"default_region,omitempty"`
 }
 
 // UpdateUserRequest represents user update request
@@ -66,8 +71,9 @@ type UpdateUserRequest struct {
 	Username     string `json:"username,omitempty"`
 	Password     string `json:"password,omitempty"`
 	Role         string `json:"role,omitempty"`
-	SessionLimit int    `json:"session_limit,omitempty"`
+	SessionLimit *int   `json:"session_limit,omitempty"` // 0 is unlimited
 	IsActive     *bool  `json:"is_active,omitempty"`
+	DefaultRegion *string `json:"default_region,omitempty"`
 }
 
 // APIKeyResponse represents API key generation response
@@ -80,6 +86,34 @@ type APIKeyResponse struct {
 // APIKeyInfo represents API key information (without the actual key)
 type APIKeyInfo struct {
 	HasKey    bool      `json:"has_key"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	LastUsed  *time.Time `json:"last_used,omitempty"`
-}
\ No newline at end of file
+	Keys      int       `json:"keys"`                 // Number of keys, see GET /api/auth/api-keys
+	CreatedAt time.Time `json:"created_at,omitempty"` // Of the newest key
+	LastUsed  *time.Time `json:"last_used,omitempty"` // Last use of any key
+}
+// WhoamiResponse describes how a request was authenticated and what it may access
+type WhoamiResponse struct {
+	AuthMethod    string               `json:"auth_method"` // jwt or api_key
+	UserID        int                  `json:"user_id"`
+	Username      string               `json:"username"`
+	Role          string               `json:"role"`
+	IsAdmin       bool                 `json:"is_admin"`             // Admins may access every session
+	IssuedAt      string               `json:"issued_at,omitempty"`  // JWT only, RFC3339 UTC
+	ExpiresAt     string               `json:"expires_at,omitempty"` // JWT only, RFC3339 UTC
+	APIKey        *WhoamiAPIKey        `json:"api_key,omitempty"`
+	SessionAccess *WhoamiSessionAccess `json:"session_access,omitempty"` // Present when session_id is given
+}
+
+// WhoamiAPIKey describes the API key used, without the key itself
+type WhoamiAPIKey struct {
+	Name     string   `json:"name"`
+	Hint     string   `json:"hint"`               // e.g. wams_...3f9a
+	Scopes   []string `json:"scopes"`             // "*" grants everything the user may do
+	Sessions []string `json:"sessions,omitempty"` // Omitted when every session the user may access is allowed
+}
+
+// WhoamiSessionAccess is the result of the session access check for one session
+type WhoamiSessionAccess struct {
+	SessionID string `json:"session_id"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a queued webhook delivery
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is a webhook event queued for delivery to a session's webhook.
+// A pending delivery is retried with backoff until it is delivered or expires,
+// when it is failed and only sent again by an explicit retry.
+type WebhookDelivery struct {
+	ID          int64           `json:"id"`
+	SessionID   string          `json:"session_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextRetryAt time.Time       `json:"next_retry_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryList is a page of queued webhook deliveries
+type WebhookDeliveryList struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Total      int                `json:"total"`
+	Page       int                `json:"page"`
+	Limit      int                `json:"limit"`
+	Pages      int                `json:"pages"`
+}
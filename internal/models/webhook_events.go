@@ -0,0 +1,51 @@
+package models
+
+import "strings"
+
+// Webhook events a session can choose to receive, see Session.WebhookEvents.
+// Messages are named by their webhook message_type; group is not an event of
+// its own but lets the listed message types through for group chats too.
+var WebhookEventNames = []string{
+	"text", "image", "video", "audio", "document", "poll_vote", "reaction", "order", "list_reply", "button_reply", "unknown",
+	"undecryptable", "receipt", "status_update", "new_contact", "session_status", "group",
+}
+
+// optInWebhookEvents are only sent to webhooks whose list names them
+var optInWebhookEvents = map[string]bool{
+	"session_status": true,
+}
+
+// ValidateWebhookEvents rejects event names a session cannot filter on
+func ValidateWebhookEvents(events []string) error {
+	for _, event := range events {
+		known := false
+		for _, name := range WebhookEventNames {
+			if event == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return NewBadRequestError("unknown webhook event %q, allowed: %s", event, strings.Join(WebhookEventNames, ", "))
+		}
+	}
+	return nil
+}
+
+// WebhookEventEnabled reports whether the session's webhook receives event,
+// for a group chat if group is set. Sessions without a list receive every
+// event that is not opt-in.
+func (s *Session) WebhookEventEnabled(event string, group bool) bool {
+	if len(s.WebhookEvents) == 0 {
+		return !optInWebhookEvents[event]
+	}
+	listed := func(name string) bool {
+		for _, e := range s.WebhookEvents {
+			if e == name {
+				return true
+			}
+		}
+		return false
+	}
+	return listed(event) && (!group || listed("group"))
+}
@@ -3,14 +3,17 @@ package repository
 import (
 	"database/sql"
 	"time"
+
+	"whatsapp-multi-session/internal/models"
 )
 
 type AnalyticsRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 func NewAnalyticsRepository(db *sql.DB) *AnalyticsRepository {
-	return &AnalyticsRepository{db: db}
+	return &AnalyticsRepository{db: db, dialect: dialectOf(db)}
 }
 
 // MessageStats represents message statistics
@@ -21,6 +24,8 @@ type MessageStats struct {
 	FailedMessages    int64 `json:"failed_messages"`
 	MediaMessages     int64 `json:"media_messages"`
 	TextMessages      int64 `json:"text_messages"`
+	UndecryptableMessages int64 `json:"undecryptable_messages"`
+	RevokedMessages int64 `json:"revoked_messages"`
 }
 
 // SessionStats represents session statistics
@@ -38,6 +43,10 @@ type UserStats struct {
 	RegularUsers  int64 `json:"regular_users"`
 }
 
+// excludeSandbox filters out sandbox sessions, aliased s, which only carry
+// simulated traffic
+const excludeSandbox = " AND s.sandbox = FALSE"
+
 // TimeSeriesData represents time series data point
 type TimeSeriesData struct {
 	Time  time.Time `json:"time"`
@@ -45,19 +54,16 @@ type TimeSeriesData struct {
 }
 
 // GetMessageStats retrieves message statistics
-func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string) (*MessageStats, error) {
+func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string, includeSandbox bool) (*MessageStats, error) {
 	stats := &MessageStats{}
 	
 	// Check if messages table exists
-	var tableExists int
-	// Check if messages table exists in MySQL
-	checkTableQuery := `SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'messages'`
-	err := r.db.QueryRow(checkTableQuery).Scan(&tableExists)
+	tableExists, err := r.dialect.tableExists(r.db, "messages")
 	if err != nil {
 		return stats, nil // Return empty stats if can't check table
 	}
 	
-	if tableExists == 0 {
+	if !tableExists {
 		// Messages table doesn't exist, return empty stats
 		return stats, nil
 	}
@@ -70,7 +76,9 @@ func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string) (*
 			SUM(CASE WHEN direction = 'received' THEN 1 ELSE 0 END) as received,
 			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed,
 			SUM(CASE WHEN message_type IN ('image', 'video', 'audio', 'document') THEN 1 ELSE 0 END) as media,
-			SUM(CASE WHEN message_type = 'text' THEN 1 ELSE 0 END) as text
+			SUM(CASE WHEN message_type = 'text' THEN 1 ELSE 0 END) as text,
+			SUM(CASE WHEN message_type = 'undecryptable' THEN 1 ELSE 0 END) as undecryptable,
+			SUM(CASE WHEN status = 'revoked' THEN 1 ELSE 0 END) as revoked
 		FROM messages m
 		JOIN session_metadata s ON m.session_id = s.id
 		WHERE 1=1
@@ -83,19 +91,12 @@ func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string) (*
 		baseQuery += " AND s.user_id = ?"
 		args = append(args, userId)
 	}
-	
-	// Add time range filter (MySQL syntax)
-	switch timeRange {
-	case "today":
-		baseQuery += " AND m.created_at >= CURDATE()"
-	case "week":
-		baseQuery += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 7 DAY)"
-	case "month":
-		baseQuery += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 30 DAY)"
-	case "year":
-		baseQuery += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 1 YEAR)"
+	if !includeSandbox {
+		baseQuery += excludeSandbox
 	}
 	
+	baseQuery += r.timeRangeFilter(timeRange)
+	
 	row := r.db.QueryRow(baseQuery, args...)
 	err = row.Scan(
 		&stats.TotalMessages,
@@ -104,6 +105,8 @@ func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string) (*
 		&stats.FailedMessages,
 		&stats.MediaMessages,
 		&stats.TextMessages,
+		&stats.UndecryptableMessages,
+		&stats.RevokedMessages,
 	)
 	
 	if err != nil && err != sql.ErrNoRows {
@@ -114,7 +117,7 @@ func (r *AnalyticsRepository) GetMessageStats(userId int64, timeRange string) (*
 }
 
 // GetSessionStats retrieves session statistics
-func (r *AnalyticsRepository) GetSessionStats(userId int64) (*SessionStats, error) {
+func (r *AnalyticsRepository) GetSessionStats(userId int64, includeSandbox bool) (*SessionStats, error) {
 	stats := &SessionStats{}
 	
 	// Since we don't have is_connected in session_metadata, we'll count all sessions as total
@@ -132,6 +135,9 @@ func (r *AnalyticsRepository) GetSessionStats(userId int64) (*SessionStats, erro
 		baseQuery += " AND user_id = ?"
 		args = append(args, userId)
 	}
+	if !includeSandbox {
+		baseQuery += " AND sandbox = FALSE"
+	}
 	
 	row := r.db.QueryRow(baseQuery, args...)
 	err := row.Scan(&stats.TotalSessions)
@@ -176,34 +182,18 @@ func (r *AnalyticsRepository) GetUserStats() (*UserStats, error) {
 }
 
 // GetMessageTimeSeries retrieves message count time series data
-func (r *AnalyticsRepository) GetMessageTimeSeries(userId int64, timeRange string, interval string) ([]TimeSeriesData, error) {
+func (r *AnalyticsRepository) GetMessageTimeSeries(userId int64, timeRange string, interval string, includeSandbox bool) ([]TimeSeriesData, error) {
 	// Initialize with empty slice to avoid null response
 	data := make([]TimeSeriesData, 0)
 	
 	// Check if messages table exists
-	var tableExists int
-	// Check if messages table exists in MySQL
-	checkTableQuery := `SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'messages'`
-	err := r.db.QueryRow(checkTableQuery).Scan(&tableExists)
-	if err != nil || tableExists == 0 {
+	tableExists, err := r.dialect.tableExists(r.db, "messages")
+	if err != nil || !tableExists {
 		// Return empty data if table doesn't exist
 		return data, nil
 	}
 	
-	// Determine groupBy based on interval (MySQL syntax)
-	var groupBy string
-	switch interval {
-	case "hour":
-		groupBy = "DATE_FORMAT(m.created_at, '%Y-%m-%d %H:00:00')"
-	case "day":
-		groupBy = "DATE(m.created_at)"
-	case "week":
-		groupBy = "DATE_FORMAT(m.created_at, '%Y-%u')"
-	case "month":
-		groupBy = "DATE_FORMAT(m.created_at, '%Y-%m')"
-	default:
-		groupBy = "DATE(m.created_at)"
-	}
+	groupBy := r.timePeriod(interval)
 	
 	query := `
 		SELECT 
@@ -220,19 +210,12 @@ func (r *AnalyticsRepository) GetMessageTimeSeries(userId int64, timeRange strin
 		query += " AND s.user_id = ?"
 		args = append(args, userId)
 	}
-	
-	// Add time range filter (MySQL syntax)
-	switch timeRange {
-	case "today":
-		query += " AND m.created_at >= CURDATE()"
-	case "week":
-		query += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 7 DAY)"
-	case "month":
-		query += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 30 DAY)"
-	case "year":
-		query += " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 1 YEAR)"
+	if !includeSandbox {
+		query += excludeSandbox
 	}
 	
+	query += r.timeRangeFilter(timeRange)
+	
 	query += " GROUP BY time_period ORDER BY time_period"
 	
 	rows, err := r.db.Query(query, args...)
@@ -261,7 +244,7 @@ func (r *AnalyticsRepository) GetMessageTimeSeries(userId int64, timeRange strin
 		}
 		
 		data = append(data, TimeSeriesData{
-			Time:  t,
+			Time:  t.UTC(),
 			Value: count,
 		})
 	}
@@ -270,16 +253,13 @@ func (r *AnalyticsRepository) GetMessageTimeSeries(userId int64, timeRange strin
 }
 
 // GetTopContacts retrieves top contacts by message count
-func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int) ([]map[string]interface{}, error) {
+func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int, includeSandbox bool) ([]map[string]interface{}, error) {
 	// Initialize with empty slice to avoid null response
 	contacts := make([]map[string]interface{}, 0)
 	
 	// Check if messages table exists
-	var tableExists int
-	// Check if messages table exists in MySQL
-	checkTableQuery := `SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'messages'`
-	err := r.db.QueryRow(checkTableQuery).Scan(&tableExists)
-	if err != nil || tableExists == 0 {
+	tableExists, err := r.dialect.tableExists(r.db, "messages")
+	if err != nil || !tableExists {
 		// Return empty data if table doesn't exist
 		return contacts, nil
 	}
@@ -300,6 +280,9 @@ func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int) ([]map[str
 		query += " AND s.user_id = ?"
 		args = append(args, userId)
 	}
+	if !includeSandbox {
+		query += excludeSandbox
+	}
 	
 	query += ` 
 		GROUP BY m.recipient_jid 
@@ -317,7 +300,7 @@ func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int) ([]map[str
 	for rows.Next() {
 		var contact string
 		var messageCount int64
-		var lastMessage time.Time
+		var lastMessage scanTime
 		
 		if err := rows.Scan(&contact, &messageCount, &lastMessage); err != nil {
 			continue // Skip invalid rows
@@ -326,7 +309,7 @@ func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int) ([]map[str
 		contacts = append(contacts, map[string]interface{}{
 			"contact":       contact,
 			"message_count": messageCount,
-			"last_message":  lastMessage,
+			"last_message":  models.FormatTimestamp(lastMessage.Time),
 		})
 	}
 	
@@ -334,20 +317,17 @@ func (r *AnalyticsRepository) GetTopContacts(userId int64, limit int) ([]map[str
 }
 
 // GetSessionActivity retrieves session activity data
-func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map[string]interface{}, error) {
+func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int, includeSandbox bool) ([]map[string]interface{}, error) {
 	// Initialize with empty slice to avoid null response
 	sessions := make([]map[string]interface{}, 0)
 	
 	// First check if messages table exists to determine query strategy
-	var tableExists int
-	// Check if messages table exists in MySQL
-	checkTableQuery := `SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'messages'`
-	err := r.db.QueryRow(checkTableQuery).Scan(&tableExists)
+	tableExists, err := r.dialect.tableExists(r.db, "messages")
 	
 	var query string
 	args := []interface{}{}
 	
-	if err != nil || tableExists == 0 {
+	if err != nil || !tableExists {
 		// Messages table doesn't exist, just return session info without message counts
 		query = `
 			SELECT 
@@ -363,6 +343,9 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 			query += " AND s.user_id = ?"
 			args = append(args, userId)
 		}
+		if !includeSandbox {
+			query += excludeSandbox
+		}
 		
 		query += ` ORDER BY s.created_at DESC LIMIT ?`
 		args = append(args, limit)
@@ -374,7 +357,8 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 				s.phone,
 				s.name,
 				COUNT(m.id) as message_count,
-				MAX(m.created_at) as last_activity
+				MAX(m.created_at) as last_activity,
+				SUM(CASE WHEN m.message_type = 'undecryptable' THEN 1 ELSE 0 END) as undecryptable_count
 			FROM session_metadata s
 			LEFT JOIN messages m ON s.id = m.session_id
 			WHERE 1=1
@@ -384,6 +368,9 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 			query += " AND s.user_id = ?"
 			args = append(args, userId)
 		}
+		if !includeSandbox {
+			query += excludeSandbox
+		}
 		
 		query += ` 
 			GROUP BY s.id, s.phone, s.name
@@ -399,7 +386,7 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 	}
 	defer rows.Close()
 	for rows.Next() {
-		if tableExists == 0 {
+		if !tableExists {
 			// Simple scan without message data
 			var id, messageCount int64
 			var phoneNumber, name sql.NullString
@@ -421,9 +408,10 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 			// Full scan with message data
 			var id, messageCount int64
 			var phoneNumber, name sql.NullString
-			var lastActivity sql.NullTime
+			var lastActivity scanTime
+			var undecryptableCount sql.NullInt64
 			
-			if err := rows.Scan(&id, &phoneNumber, &name, &messageCount, &lastActivity); err != nil {
+			if err := rows.Scan(&id, &phoneNumber, &name, &messageCount, &lastActivity, &undecryptableCount); err != nil {
 				continue // Skip invalid rows
 			}
 			
@@ -433,10 +421,11 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 				"name":          name.String,
 				"is_connected":  true, // Assume connected since we don't have this field
 				"message_count": messageCount,
+				"undecryptable_count": undecryptableCount.Int64,
 			}
 			
 			if lastActivity.Valid {
-				session["last_activity"] = lastActivity.Time
+				session["last_activity"] = models.FormatTimestamp(lastActivity.Time)
 			}
 			
 			sessions = append(sessions, session)
@@ -445,3 +434,61 @@ func (r *AnalyticsRepository) GetSessionActivity(userId int64, limit int) ([]map
 	
 	return sessions, nil
 }
+
+// timeRangeFilter restricts messages, aliased m, to a time range: today,
+// week, month or year. Any other range leaves them unrestricted.
+func (r *AnalyticsRepository) timeRangeFilter(timeRange string) string {
+	if r.dialect == DialectSQLite {
+		createdAt := r.dialect.timestamp("m.created_at")
+		switch timeRange {
+		case "today":
+			return " AND " + createdAt + " >= datetime('now', 'start of day')"
+		case "week":
+			return " AND " + createdAt + " >= datetime('now', '-7 days')"
+		case "month":
+			return " AND " + createdAt + " >= datetime('now', '-30 days')"
+		case "year":
+			return " AND " + createdAt + " >= datetime('now', '-1 year')"
+		}
+		return ""
+	}
+
+	switch timeRange {
+	case "today":
+		return " AND m.created_at >= CURDATE()"
+	case "week":
+		return " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 7 DAY)"
+	case "month":
+		return " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 30 DAY)"
+	case "year":
+		return " AND m.created_at >= DATE_SUB(NOW(), INTERVAL 1 YEAR)"
+	}
+	return ""
+}
+
+// timePeriod is the expression grouping messages, aliased m, by interval:
+// hour, day, week or month, day when unknown
+func (r *AnalyticsRepository) timePeriod(interval string) string {
+	if r.dialect == DialectSQLite {
+		createdAt := r.dialect.timestamp("m.created_at")
+		switch interval {
+		case "hour":
+			return "strftime('%Y-%m-%d %H:00:00', " + createdAt + ")"
+		case "week":
+			return "strftime('%Y-%W', " + createdAt + ")"
+		case "month":
+			return "strftime('%Y-%m', " + createdAt + ")"
+		}
+		return "date(" + createdAt + ")"
+	}
+
+	switch interval {
+	case "hour":
+		return "DATE_FORMAT(m.created_at, '%Y-%m-%d %H:00:00')"
+	case "week":
+		return "DATE_FORMAT(m.created_at, '%Y-%u')"
+	case "month":
+		return "DATE_FORMAT(m.created_at, '%Y-%m')"
+	}
+	return "DATE(m.created_at)"
+}
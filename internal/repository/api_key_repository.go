@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+const apiKeyColumns = `id, user_id, name, key_hash, key_hint, scopes, sessions, rate_limit, created_at, last_used_at`
+
+// APIKeyRepository stores the API keys of users
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create stores a new key and sets its ID
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, key_hash, key_hint, scopes, sessions, rate_limit, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode API key scopes: %v", err)
+	}
+	var sessions interface{}
+	if key.Sessions != nil {
+		encoded, err := json.Marshal(key.Sessions)
+		if err != nil {
+			return fmt.Errorf("failed to encode API key sessions: %v", err)
+		}
+		sessions = string(encoded)
+	}
+
+	result, err := r.db.Exec(query, key.UserID, key.Name, key.KeyHash, key.Hint, string(scopes), sessions,
+		key.RateLimit, key.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %v", err)
+	}
+
+	key.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get API key ID: %v", err)
+	}
+	return nil
+}
+
+// GetByHash returns the key with the given hash, or nil if there is none
+func (r *APIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ?`
+
+	key, err := scanAPIKey(r.db.QueryRow(query, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %v", err)
+	}
+	return key, nil
+}
+
+// GetByUserID returns the keys of a user, oldest first
+func (r *APIKeyRepository) GetByUserID(userID int) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = ? ORDER BY id`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys of user %d: %v", userID, err)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Delete deletes a key of a user, reporting whether it existed
+func (r *APIKeyRepository) Delete(userID int, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM api_keys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API key: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// DeleteByUserID deletes every key of a user
+func (r *APIKeyRepository) DeleteByUserID(userID int) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM api_keys WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete API keys of user %d: %v", userID, err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteByName deletes the keys of a user with the given name
+func (r *APIKeyRepository) DeleteByName(userID int, name string) error {
+	if _, err := r.db.Exec(`DELETE FROM api_keys WHERE user_id = ? AND name = ?`, userID, name); err != nil {
+		return fmt.Errorf("failed to delete API key %s of user %d: %v", name, userID, err)
+	}
+	return nil
+}
+
+// TouchLastUsed records that a key was used at usedAt
+func (r *APIKeyRepository) TouchLastUsed(id int64, usedAt time.Time) error {
+	if _, err := r.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt.Unix(), id); err != nil {
+		return fmt.Errorf("failed to record API key use: %v", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var sessions sql.NullString
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.KeyHash, &key.Hint, &scopes, &sessions,
+		&key.RateLimit, &createdAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("invalid scopes of API key %d: %v", key.ID, err)
+	}
+	if sessions.Valid {
+		if err := json.Unmarshal([]byte(sessions.String), &key.Sessions); err != nil {
+			return nil, fmt.Errorf("invalid sessions of API key %d: %v", key.ID, err)
+		}
+	}
+	key.CreatedAt = time.Unix(createdAt, 0)
+	if lastUsedAt.Valid {
+		usedAt := time.Unix(lastUsedAt.Int64, 0)
+		key.LastUsedAt = &usedAt
+	}
+	return key, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// AuditLogRepository stores the audit log
+type AuditLogRepository struct {
+	db *sql.DB
+}
+
+// NewAuditLogRepository creates a new audit log repository
+func NewAuditLogRepository(db *sql.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+// Create stores an audit log entry and sets its ID
+func (r *AuditLogRepository) Create(entry *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (actor_user_id, actor_username, auth_method, action, target_type, target_id, ip, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	var details interface{}
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit log details: %v", err)
+		}
+		details = string(encoded)
+	}
+
+	result, err := r.db.Exec(query, entry.ActorUserID, nullableString(entry.ActorUsername), nullableString(entry.AuthMethod),
+		entry.Action, nullableString(entry.TargetType), nullableString(entry.TargetID), nullableString(entry.IP),
+		details, entry.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create audit log entry: %v", err)
+	}
+
+	entry.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get audit log entry ID: %v", err)
+	}
+	return nil
+}
+
+// List returns a page of the entries matching filter, newest first, and how
+// many match in total
+func (r *AuditLogRepository) List(filter models.AuditLogFilter) ([]*models.AuditLog, int64, error) {
+	where, args := auditLogConditions(filter)
+
+	var total int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_logs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit log entries: %v", err)
+	}
+
+	query := `
+		SELECT id, actor_user_id, actor_username, auth_method, action, target_type, target_id, ip, details, created_at
+		FROM audit_logs` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit log entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditLog{}
+	for rows.Next() {
+		entry := &models.AuditLog{}
+		var actorUserID sql.NullInt64
+		var actorUsername, authMethod, targetType, targetID, ip, details sql.NullString
+		var createdAt int64
+		err := rows.Scan(&entry.ID, &actorUserID, &actorUsername, &authMethod, &entry.Action, &targetType, &targetID,
+			&ip, &details, &createdAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit log entry: %v", err)
+		}
+
+		if actorUserID.Valid {
+			id := int(actorUserID.Int64)
+			entry.ActorUserID = &id
+		}
+		entry.ActorUsername = actorUsername.String
+		entry.AuthMethod = authMethod.String
+		entry.TargetType = targetType.String
+		entry.TargetID = targetID.String
+		entry.IP = ip.String
+		if details.Valid && details.String != "" {
+			if err := json.Unmarshal([]byte(details.String), &entry.Details); err != nil {
+				return nil, 0, fmt.Errorf("invalid details of audit log entry %d: %v", entry.ID, err)
+			}
+		}
+		entry.CreatedAt = time.Unix(createdAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// auditLogConditions builds the WHERE clause of a filter
+func auditLogConditions(filter models.AuditLogFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.ActorUserID != 0 {
+		conditions = append(conditions, "actor_user_id = ?")
+		args = append(args, filter.ActorUserID)
+	}
+	if strings.HasSuffix(filter.Action, ".") {
+		conditions = append(conditions, "action LIKE ?")
+		args = append(args, filter.Action+"%")
+	} else if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.Unix())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.Unix())
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
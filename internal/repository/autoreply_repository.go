@@ -18,6 +18,15 @@ func NewAutoReplyRepository(db *sql.DB) *AutoReplyRepository {
 	return &AutoReplyRepository{db: db}
 }
 
+// sendOptionsValue encodes send options for a nullable JSON column
+func sendOptionsValue(opts *models.SendOptions) interface{} {
+	if opts == nil {
+		return nil
+	}
+	data, _ := json.Marshal(opts)
+	return string(data)
+}
+
 // CreateAutoReply creates a new auto-reply rule
 func (r *AutoReplyRepository) CreateAutoReply(autoReply *models.AutoReply) error {
 	keywordsJSON, _ := json.Marshal(autoReply.Keywords)
@@ -26,8 +35,8 @@ func (r *AutoReplyRepository) CreateAutoReply(autoReply *models.AutoReply) error
 	query := `
 		INSERT INTO auto_replies (session_id, name, trigger_type, keywords, response, media_url, media_type, 
 		                         is_active, priority, delay_min, delay_max, max_replies, time_start, time_end, 
-		                         conditions, usage_count, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		                         time_zone, conditions, send_options, usage_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	result, err := r.db.Exec(query,
 		autoReply.SessionID,
@@ -44,7 +53,9 @@ func (r *AutoReplyRepository) CreateAutoReply(autoReply *models.AutoReply) error
 		autoReply.MaxReplies,
 		autoReply.TimeStart,
 		autoReply.TimeEnd,
+		nullString(autoReply.TimeZone),
 		string(conditionsJSON),
+		sendOptionsValue(autoReply.SendOptions),
 		0, // initial usage count
 		time.Now().Unix(),
 	)
@@ -68,14 +79,14 @@ func (r *AutoReplyRepository) CreateAutoReply(autoReply *models.AutoReply) error
 // GetAutoReply retrieves an auto-reply rule by ID
 func (r *AutoReplyRepository) GetAutoReply(id int) (*models.AutoReply, error) {
 	autoReply := &models.AutoReply{}
-	var keywordsJSON, conditionsJSON sql.NullString
+	var keywordsJSON, timeZone, conditionsJSON, sendOptionsJSON sql.NullString
 	var updatedAt sql.NullInt64
 	var createdAt int64
 	
 	query := `
 		SELECT id, session_id, name, trigger_type, keywords, response, media_url, media_type,
 		       is_active, priority, delay_min, delay_max, max_replies, time_start, time_end,
-		       conditions, usage_count, created_at, updated_at
+		       time_zone, conditions, send_options, usage_count, created_at, updated_at
 		FROM auto_replies
 		WHERE id = ?`
 	
@@ -95,7 +106,9 @@ func (r *AutoReplyRepository) GetAutoReply(id int) (*models.AutoReply, error) {
 		&autoReply.MaxReplies,
 		&autoReply.TimeStart,
 		&autoReply.TimeEnd,
+		&timeZone,
 		&conditionsJSON,
+		&sendOptionsJSON,
 		&autoReply.UsageCount,
 		&createdAt,
 		&updatedAt,
@@ -103,16 +116,20 @@ func (r *AutoReplyRepository) GetAutoReply(id int) (*models.AutoReply, error) {
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("auto-reply not found")
+			return nil, models.NewNotFoundError("auto-reply %d not found", id)
 		}
 		return nil, fmt.Errorf("failed to get auto-reply: %v", err)
 	}
 	
+	autoReply.TimeZone = timeZone.String
+	
 	// Parse timestamps
 	autoReply.CreatedAt = time.Unix(createdAt, 0)
+	autoReply.Version = createdAt
 	if updatedAt.Valid {
 		t := time.Unix(updatedAt.Int64, 0)
 		autoReply.UpdatedAt = &t
+		autoReply.Version = updatedAt.Int64
 	}
 	
 	// Parse JSON fields
@@ -124,6 +141,11 @@ func (r *AutoReplyRepository) GetAutoReply(id int) (*models.AutoReply, error) {
 		json.Unmarshal([]byte(conditionsJSON.String), &autoReply.Conditions)
 	}
 	
+	if sendOptionsJSON.Valid && sendOptionsJSON.String != "" {
+		autoReply.SendOptions = &models.SendOptions{}
+		json.Unmarshal([]byte(sendOptionsJSON.String), autoReply.SendOptions)
+	}
+	
 	return autoReply, nil
 }
 
@@ -142,7 +164,7 @@ func (r *AutoReplyRepository) getAutoRepliesWithFilter(whereClause string, args
 	query := `
 		SELECT id, session_id, name, trigger_type, keywords, response, media_url, media_type,
 		       is_active, priority, delay_min, delay_max, max_replies, time_start, time_end,
-		       conditions, usage_count, created_at, updated_at
+		       time_zone, conditions, send_options, usage_count, created_at, updated_at
 		FROM auto_replies
 		WHERE ` + whereClause
 	
@@ -162,7 +184,7 @@ func (r *AutoReplyRepository) getAutoRepliesWithFilter(whereClause string, args
 	
 	for rows.Next() {
 		autoReply := models.AutoReply{}
-		var keywordsJSON, conditionsJSON sql.NullString
+		var keywordsJSON, timeZone, conditionsJSON, sendOptionsJSON sql.NullString
 		var updatedAt sql.NullInt64
 		var createdAt int64
 		
@@ -182,7 +204,9 @@ func (r *AutoReplyRepository) getAutoRepliesWithFilter(whereClause string, args
 			&autoReply.MaxReplies,
 			&autoReply.TimeStart,
 			&autoReply.TimeEnd,
+			&timeZone,
 			&conditionsJSON,
+			&sendOptionsJSON,
 			&autoReply.UsageCount,
 			&createdAt,
 			&updatedAt,
@@ -192,11 +216,15 @@ func (r *AutoReplyRepository) getAutoRepliesWithFilter(whereClause string, args
 			return nil, fmt.Errorf("failed to scan auto-reply: %v", err)
 		}
 		
+		autoReply.TimeZone = timeZone.String
+		
 		// Parse timestamps
 		autoReply.CreatedAt = time.Unix(createdAt, 0)
+		autoReply.Version = createdAt
 		if updatedAt.Valid {
 			t := time.Unix(updatedAt.Int64, 0)
 			autoReply.UpdatedAt = &t
+			autoReply.Version = updatedAt.Int64
 		}
 		
 		// Parse JSON fields
@@ -208,6 +236,11 @@ func (r *AutoReplyRepository) getAutoRepliesWithFilter(whereClause string, args
 			json.Unmarshal([]byte(conditionsJSON.String), &autoReply.Conditions)
 		}
 		
+		if sendOptionsJSON.Valid && sendOptionsJSON.String != "" {
+			autoReply.SendOptions = &models.SendOptions{}
+			json.Unmarshal([]byte(sendOptionsJSON.String), autoReply.SendOptions)
+		}
+		
 		autoReplies = append(autoReplies, autoReply)
 	}
 	
@@ -285,21 +318,35 @@ func (r *AutoReplyRepository) UpdateAutoReply(id int, req models.UpdateAutoReply
 		args = append(args, req.TimeEnd)
 	}
 	
+	if req.TimeZone != nil {
+		setParts = append(setParts, "time_zone = ?")
+		args = append(args, nullString(*req.TimeZone))
+	}
+	
 	if req.Conditions != nil {
 		conditionsJSON, _ := json.Marshal(req.Conditions)
 		setParts = append(setParts, "conditions = ?")
 		args = append(args, string(conditionsJSON))
 	}
 	
+	if req.SendOptions != nil {
+		setParts = append(setParts, "send_options = ?")
+		args = append(args, sendOptionsValue(req.SendOptions))
+	}
+	
 	if len(setParts) == 0 {
-		return fmt.Errorf("no fields to update")
+		return models.NewBadRequestError("no fields to update")
 	}
 	
-	setParts = append(setParts, "updated_at = ?")
+	setParts = append(setParts, bumpVersion)
 	args = append(args, time.Now().Unix())
 	args = append(args, id)
 	
 	query := fmt.Sprintf("UPDATE auto_replies SET %s WHERE id = ?", strings.Join(setParts, ", "))
+	if req.ExpectedVersion != nil {
+		query += " AND " + versionColumn + " = ?"
+		args = append(args, *req.ExpectedVersion)
+	}
 	
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
@@ -312,12 +359,31 @@ func (r *AutoReplyRepository) UpdateAutoReply(id int, req models.UpdateAutoReply
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("auto-reply not found")
+		if _, err := r.GetAutoReplyVersion(id); err != nil {
+			return err
+		}
+		return ErrVersionConflict
 	}
 	
 	return nil
 }
 
+// GetAutoReplyVersion returns the current version of an auto-reply rule
+func (r *AutoReplyRepository) GetAutoReplyVersion(id int) (int64, error) {
+	query := "SELECT " + versionColumn + " FROM auto_replies WHERE id = ?"
+	
+	var version int64
+	err := r.db.QueryRow(query, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, models.NewNotFoundError("auto-reply %d not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get auto-reply version: %v", err)
+	}
+	
+	return version, nil
+}
+
 // DeleteAutoReply deletes an auto-reply rule
 func (r *AutoReplyRepository) DeleteAutoReply(id int) error {
 	query := "DELETE FROM auto_replies WHERE id = ?"
@@ -333,7 +399,7 @@ func (r *AutoReplyRepository) DeleteAutoReply(id int) error {
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("auto-reply not found")
+		return models.NewNotFoundError("auto-reply %d not found", id)
 	}
 	
 	return nil
@@ -362,9 +428,16 @@ func (r *AutoReplyRepository) IncrementUsageCount(id int) error {
 
 // CreateAutoReplyLog creates a new auto-reply log entry
 func (r *AutoReplyRepository) CreateAutoReplyLog(log *models.AutoReplyLog) error {
+	var substitutionErrors interface{}
+	if len(log.SubstitutionErrors) > 0 {
+		errorsJSON, _ := json.Marshal(log.SubstitutionErrors)
+		substitutionErrors = string(errorsJSON)
+	}
+	
 	query := `
-		INSERT INTO auto_reply_logs (auto_reply_id, session_id, contact_phone, trigger_msg, response, success, error_msg, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO auto_reply_logs (auto_reply_id, session_id, contact_phone, trigger_msg, response, success, error_msg,
+		                             media_included, substitution_errors, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	result, err := r.db.Exec(query,
 		log.AutoReplyID,
@@ -374,6 +447,8 @@ func (r *AutoReplyRepository) CreateAutoReplyLog(log *models.AutoReplyLog) error
 		log.Response,
 		log.Success,
 		log.ErrorMsg,
+		log.MediaIncluded,
+		substitutionErrors,
 		time.Now().Unix(),
 	)
 	
@@ -396,7 +471,7 @@ func (r *AutoReplyRepository) CreateAutoReplyLog(log *models.AutoReplyLog) error
 func (r *AutoReplyRepository) GetAutoReplyLogs(autoReplyID *int, sessionID string, startDate, endDate *time.Time, limit int) ([]models.AutoReplyLog, error) {
 	query := `
 		SELECT arl.id, arl.auto_reply_id, arl.session_id, arl.contact_phone, arl.trigger_msg, 
-		       arl.response, arl.success, arl.error_msg, arl.created_at
+		       arl.response, arl.success, arl.error_msg, arl.media_included, arl.substitution_errors, arl.created_at
 		FROM auto_reply_logs arl
 		WHERE 1=1`
 	
@@ -439,6 +514,7 @@ func (r *AutoReplyRepository) GetAutoReplyLogs(autoReplyID *int, sessionID strin
 	
 	for rows.Next() {
 		log := models.AutoReplyLog{}
+		var substitutionErrors sql.NullString
 		var createdAt int64
 		
 		err := rows.Scan(
@@ -450,6 +526,8 @@ func (r *AutoReplyRepository) GetAutoReplyLogs(autoReplyID *int, sessionID strin
 			&log.Response,
 			&log.Success,
 			&log.ErrorMsg,
+			&log.MediaIncluded,
+			&substitutionErrors,
 			&createdAt,
 		)
 		
@@ -457,6 +535,10 @@ func (r *AutoReplyRepository) GetAutoReplyLogs(autoReplyID *int, sessionID strin
 			return nil, fmt.Errorf("failed to scan auto-reply log: %v", err)
 		}
 		
+		if substitutionErrors.Valid && substitutionErrors.String != "" {
+			json.Unmarshal([]byte(substitutionErrors.String), &log.SubstitutionErrors)
+		}
+		
 		log.CreatedAt = time.Unix(createdAt, 0)
 		logs = append(logs, log)
 	}
@@ -464,6 +546,76 @@ func (r *AutoReplyRepository) GetAutoReplyLogs(autoReplyID *int, sessionID strin
 	return logs, nil
 }
 
+// GetAutoReplyLogsPage returns a page of the logs of an auto-reply rule,
+// newest first, within the optional date range, and how many there are in all
+func (r *AutoReplyRepository) GetAutoReplyLogsPage(autoReplyID int, startDate, endDate *time.Time, offset, limit int) ([]models.AutoReplyLog, int, error) {
+	where := " WHERE auto_reply_id = ?"
+	args := []interface{}{autoReplyID}
+	
+	if startDate != nil {
+		where += " AND created_at >= ?"
+		args = append(args, startDate.Unix())
+	}
+	
+	if endDate != nil {
+		where += " AND created_at < ?"
+		args = append(args, endDate.Unix())
+	}
+	
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM auto_reply_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count auto-reply logs: %v", err)
+	}
+	
+	query := `
+		SELECT id, auto_reply_id, session_id, contact_phone, trigger_msg, response, success, error_msg,
+		       media_included, substitution_errors, created_at
+		FROM auto_reply_logs` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?`
+	
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query auto-reply logs: %v", err)
+	}
+	defer rows.Close()
+	
+	logs := []models.AutoReplyLog{}
+	for rows.Next() {
+		log := models.AutoReplyLog{}
+		var errorMsg, substitutionErrors sql.NullString
+		var createdAt int64
+		
+		err := rows.Scan(
+			&log.ID,
+			&log.AutoReplyID,
+			&log.SessionID,
+			&log.ContactPhone,
+			&log.TriggerMsg,
+			&log.Response,
+			&log.Success,
+			&errorMsg,
+			&log.MediaIncluded,
+			&substitutionErrors,
+			&createdAt,
+		)
+		
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan auto-reply log: %v", err)
+		}
+		
+		log.ErrorMsg = errorMsg.String
+		if substitutionErrors.Valid && substitutionErrors.String != "" {
+			json.Unmarshal([]byte(substitutionErrors.String), &log.SubstitutionErrors)
+		}
+		
+		log.CreatedAt = time.Unix(createdAt, 0)
+		logs = append(logs, log)
+	}
+	
+	return logs, total, nil
+}
+
 // GetAutoReplyLogsBySession retrieves auto-reply logs for a specific session
 func (r *AutoReplyRepository) GetAutoReplyLogsBySession(sessionID string, startDate, endDate *time.Time) ([]models.AutoReplyLog, error) {
 	return r.GetAutoReplyLogs(nil, sessionID, startDate, endDate, 0)
@@ -494,7 +646,7 @@ func (r *AutoReplyRepository) GetAutoReplyStatsBySession(sessionID string) (map[
 	
 	// Total rules
 	var totalRules, activeRules int
-	err := r.db.QueryRow("SELECT COUNT(*), SUM(CASE WHEN is_active THEN 1 ELSE 0 END) FROM auto_replies WHERE session_id = ?", sessionID).Scan(&totalRules, &activeRules)
+	err := r.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_active THEN 1 ELSE 0 END), 0) FROM auto_replies WHERE session_id = ?", sessionID).Scan(&totalRules, &activeRules)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rule counts: %v", err)
 	}
@@ -507,7 +659,7 @@ func (r *AutoReplyRepository) GetAutoReplyStatsBySession(sessionID string) (map[
 	
 	var totalTriggers, successfulTriggers int
 	err = r.db.QueryRow(`
-		SELECT COUNT(*), SUM(CASE WHEN success THEN 1 ELSE 0 END) 
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) 
 		FROM auto_reply_logs 
 		WHERE session_id = ? AND created_at >= ?`, sessionID, thirtyDaysAgo).Scan(&totalTriggers, &successfulTriggers)
 	if err != nil {
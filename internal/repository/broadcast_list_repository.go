@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// BroadcastListRepository mirrors the broadcast lists of the phone
+type BroadcastListRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewBroadcastListRepository creates a new broadcast list repository
+func NewBroadcastListRepository(db *sql.DB) *BroadcastListRepository {
+	return &BroadcastListRepository{db: db, dialect: dialectOf(db)}
+}
+
+// UpsertList stores a list's current name and recipients
+func (r *BroadcastListRepository) UpsertList(sessionID string, list *models.BroadcastList) error {
+	recipients, err := json.Marshal(list.Recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encode broadcast list recipients: %v", err)
+	}
+
+	query := `
+		INSERT INTO broadcast_lists (session_id, list_jid, name, recipients, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		` + r.dialect.onDuplicateKey("session_id", "list_jid") + `
+			name = ` + r.dialect.inserted("name") + `, recipients = ` + r.dialect.inserted("recipients") + `,
+			updated_at = ` + r.dialect.inserted("updated_at") + `
+	`
+
+	_, err = r.db.Exec(query, sessionID, list.JID, list.Name, string(recipients), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save broadcast list: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteList removes a list
+func (r *BroadcastListRepository) DeleteList(sessionID, listJID string) error {
+	if _, err := r.db.Exec(`DELETE FROM broadcast_lists WHERE session_id = ? AND list_jid = ?`, sessionID, listJID); err != nil {
+		return fmt.Errorf("failed to delete broadcast list: %v", err)
+	}
+	return nil
+}
+
+// GetLists returns all broadcast lists of a session ordered by name
+func (r *BroadcastListRepository) GetLists(sessionID string) ([]*models.BroadcastList, error) {
+	query := `SELECT list_jid, name, recipients, updated_at FROM broadcast_lists WHERE session_id = ? ORDER BY name`
+
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query broadcast lists: %v", err)
+	}
+	defer rows.Close()
+
+	lists := []*models.BroadcastList{}
+	for rows.Next() {
+		list, err := scanBroadcastList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// GetList returns a broadcast list, or nil if the session has no such list
+func (r *BroadcastListRepository) GetList(sessionID, listJID string) (*models.BroadcastList, error) {
+	query := `SELECT list_jid, name, recipients, updated_at FROM broadcast_lists WHERE session_id = ? AND list_jid = ?`
+
+	list, err := scanBroadcastList(r.db.QueryRow(query, sessionID, listJID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return list, err
+}
+
+func scanBroadcastList(row rowScanner) (*models.BroadcastList, error) {
+	var (
+		list       models.BroadcastList
+		recipients string
+		updatedAt  int64
+	)
+	if err := row.Scan(&list.JID, &list.Name, &recipients, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan broadcast list: %v", err)
+	}
+	if err := json.Unmarshal([]byte(recipients), &list.Recipients); err != nil || list.Recipients == nil {
+		list.Recipients = []string{}
+	}
+	list.UpdatedAt = models.FormatTimestamp(time.Unix(updatedAt, 0))
+	return &list, nil
+}
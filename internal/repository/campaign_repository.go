@@ -0,0 +1,513 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// bulkJobInsertBatch is how many campaign messages are inserted per statement
+const bulkJobInsertBatch = 500
+
+type CampaignRepository struct {
+	db *sql.DB
+}
+
+func NewCampaignRepository(db *sql.DB) *CampaignRepository {
+	return &CampaignRepository{db: db}
+}
+
+// BulkJob is the stored state of a bulk messaging job, kept in the campaigns table
+type BulkJob struct {
+	CampaignID        int
+	UserID            int // User who started the job, 0 for jobs an admin started for any user
+	JobID             string
+	RetryOf           string // Job whose failed recipients this job retries
+	SessionID         string
+	SessionIDs        []string       // Sessions a rotating job sends from, empty otherwise
+	SessionWeights    map[string]int // Relative share of messages of each rotating session
+	Message           string
+	DelayBetween      int
+	RandomDelay       bool
+	Variables         map[string]string
+	SendOptions       *models.SendOptions
+	Throttle          *models.BulkThrottle
+	InvalidRecipients []models.InvalidRecipient
+	Status            string
+	PausedReason      string
+	NextIndex         int
+	Total             int
+	Sent              int
+	Failed            int
+	CreatedAt         time.Time
+	StartedAt         *time.Time
+	CompletedAt       *time.Time
+}
+
+// BulkJobMessage is the message of a bulk messaging job to one recipient,
+// kept in the campaign_messages table
+type BulkJobMessage struct {
+	Position  int // Index of the recipient in the job
+	ContactID int // 0 for inline recipients
+	Phone     string
+	Name      string
+	Content   string
+	Status    string // models.BulkResultPending, BulkResultSent or BulkResultFailed
+	SessionID string // Session that handled the message
+	ErrorMsg  string
+	MessageID string
+	SentAt    *time.Time
+}
+
+const bulkJobColumns = `id, user_id, job_id, retry_of, session_id, session_ids, session_weights, message, delay_between, random_delay, variables, send_options, throttle,
+	invalid_recipients, status, paused_reason, next_index, total_contacts, sent_count, failed_count,
+	created_at, started_at, completed_at`
+
+// CreateBulkJob stores a new bulk messaging job with the messages to its
+// recipients, in one transaction. A job sending a campaign is stored in the
+// campaign's row; any other job gets a row of its own and job.CampaignID is set.
+func (r *CampaignRepository) CreateBulkJob(job *BulkJob, messages []BulkJobMessage) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	variablesJSON, _ := json.Marshal(job.Variables)
+	sendOptionsJSON, _ := json.Marshal(job.SendOptions)
+	throttleJSON, _ := json.Marshal(job.Throttle)
+	invalidJSON, _ := json.Marshal(job.InvalidRecipients)
+	sessionIDsJSON, _ := json.Marshal(job.SessionIDs)
+	sessionWeightsJSON, _ := json.Marshal(job.SessionWeights)
+	id := int64(job.CampaignID)
+	if id != 0 {
+		_, err = tx.Exec(`
+			UPDATE campaigns
+			SET job_id = ?, message = ?, status = ?, send_options = ?, throttle = ?, next_index = ?, total_contacts = ?,
+			    sent_count = 0, failed_count = 0, pending_count = ?, updated_at = ?
+			WHERE id = ?`,
+			job.JobID, job.Message, job.Status, string(sendOptionsJSON), string(throttleJSON), job.NextIndex, job.Total,
+			job.Total, time.Now().Unix(), id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to attach bulk job %s to campaign %d: %v", job.JobID, id, err)
+		}
+	} else {
+		result, err := tx.Exec(`
+			INSERT INTO campaigns (user_id, name, job_id, retry_of, session_id, session_ids, session_weights, message, status, delay_between,
+				random_delay, variables, send_options, throttle, invalid_recipients, next_index, paused_reason, total_contacts,
+				sent_count, failed_count, pending_count, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ownerOrNull(job.UserID), job.JobID, job.JobID, nullString(job.RetryOf), job.SessionID, string(sessionIDsJSON), string(sessionWeightsJSON), job.Message, job.Status, job.DelayBetween, job.RandomDelay, string(variablesJSON),
+			string(sendOptionsJSON), string(throttleJSON), string(invalidJSON), job.NextIndex, nullString(job.PausedReason), job.Total, job.Sent, job.Failed,
+			job.Total-job.Sent-job.Failed, job.CreatedAt.Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create bulk job %s: %v", job.JobID, err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get campaign ID of bulk job %s: %v", job.JobID, err)
+		}
+	}
+
+	now := job.CreatedAt.Unix()
+	for start := 0; start < len(messages); start += bulkJobInsertBatch {
+		end := start + bulkJobInsertBatch
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		placeholders := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*8)
+		for _, message := range messages[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, id, message.Position, idOrNull(message.ContactID), message.Phone, message.Name,
+				message.Content, models.BulkResultPending, now)
+		}
+		query := `
+			INSERT INTO campaign_messages (campaign_id, position, contact_id, phone, name, content, status, created_at)
+			VALUES ` + strings.Join(placeholders, ", ")
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to create messages of bulk job %s: %v", job.JobID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	job.CampaignID = int(id)
+	return nil
+}
+
+// UpdateBulkJob stores the status and progress of a bulk messaging job
+func (r *CampaignRepository) UpdateBulkJob(job *BulkJob) error {
+	_, err := r.db.Exec(`
+		UPDATE campaigns
+		SET status = ?, paused_reason = ?, next_index = ?, sent_count = ?, failed_count = ?, pending_count = ?,
+		    started_at = ?, completed_at = ?, updated_at = ?
+		WHERE id = ?`,
+		job.Status, nullString(job.PausedReason), job.NextIndex, job.Sent, job.Failed, job.Total-job.Sent-job.Failed,
+		unixOrNull(job.StartedAt), unixOrNull(job.CompletedAt), time.Now().Unix(),
+		job.CampaignID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update bulk job %s: %v", job.JobID, err)
+	}
+	return nil
+}
+
+// UpdateBulkJobMessage records the outcome of the message to the recipient
+// at position in a bulk messaging job, and the session that handled it
+func (r *CampaignRepository) UpdateBulkJobMessage(campaignID, position int, sessionID, status, messageID, errorMsg string, sentAt *time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_messages SET session_id = ?, status = ?, message_id = ?, error_msg = ?, sent_at = ?
+		WHERE campaign_id = ? AND position = ?`,
+		nullString(sessionID), status, nullString(messageID), nullString(errorMsg), unixOrNull(sentAt), campaignID, position,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update message %d of campaign %d: %v", position, campaignID, err)
+	}
+	return nil
+}
+
+// GetBulkJob returns a stored bulk messaging job by its job ID, only if
+// userID started it unless userID is 0
+func (r *CampaignRepository) GetBulkJob(jobID string, userID int) (*BulkJob, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	jobs, err := r.queryBulkJobs("WHERE job_id = ?"+owner, append([]interface{}{jobID}, ownerArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, models.NewNotFoundError("job %s not found", jobID)
+	}
+	return jobs[0], nil
+}
+
+// GetBulkJobs returns the stored bulk messaging jobs userID started, or all
+// of them if userID is 0, newest first
+func (r *CampaignRepository) GetBulkJobs(userID int) ([]*BulkJob, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	return r.queryBulkJobs("WHERE job_id IS NOT NULL"+owner+" ORDER BY created_at DESC, id DESC", ownerArgs...)
+}
+
+// GetIncompleteBulkJobs returns the stored bulk messaging jobs that had not
+// finished, oldest first
+func (r *CampaignRepository) GetIncompleteBulkJobs() ([]*BulkJob, error) {
+	return r.queryBulkJobs("WHERE job_id IS NOT NULL AND status IN ('pending', 'running', 'paused', 'throttled', 'sleeping_quiet_hours') ORDER BY id")
+}
+
+func (r *CampaignRepository) queryBulkJobs(where string, args ...interface{}) ([]*BulkJob, error) {
+	rows, err := r.db.Query("SELECT "+bulkJobColumns+" FROM campaigns "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bulk jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []*BulkJob{}
+	for rows.Next() {
+		job := &BulkJob{}
+		var retryOf, sessionIDsJSON, sessionWeightsJSON, message, variablesJSON, sendOptionsJSON, throttleJSON, invalidJSON, pausedReason sql.NullString
+		var createdAt int64
+		var userID, startedAt, completedAt sql.NullInt64
+		err := rows.Scan(
+			&job.CampaignID, &userID, &job.JobID, &retryOf, &job.SessionID, &sessionIDsJSON, &sessionWeightsJSON, &message, &job.DelayBetween, &job.RandomDelay,
+			&variablesJSON, &sendOptionsJSON, &throttleJSON, &invalidJSON, &job.Status, &pausedReason, &job.NextIndex,
+			&job.Total, &job.Sent, &job.Failed, &createdAt, &startedAt, &completedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bulk job: %v", err)
+		}
+
+		job.UserID = int(userID.Int64)
+		job.RetryOf = retryOf.String
+		job.Message = message.String
+		job.PausedReason = pausedReason.String
+		job.CreatedAt = time.Unix(createdAt, 0)
+		if startedAt.Valid {
+			t := time.Unix(startedAt.Int64, 0)
+			job.StartedAt = &t
+		}
+		if completedAt.Valid {
+			t := time.Unix(completedAt.Int64, 0)
+			job.CompletedAt = &t
+		}
+		if sessionIDsJSON.Valid {
+			json.Unmarshal([]byte(sessionIDsJSON.String), &job.SessionIDs)
+		}
+		if sessionWeightsJSON.Valid {
+			json.Unmarshal([]byte(sessionWeightsJSON.String), &job.SessionWeights)
+		}
+		if variablesJSON.Valid {
+			json.Unmarshal([]byte(variablesJSON.String), &job.Variables)
+		}
+		if sendOptionsJSON.Valid {
+			json.Unmarshal([]byte(sendOptionsJSON.String), &job.SendOptions)
+		}
+		if throttleJSON.Valid {
+			json.Unmarshal([]byte(throttleJSON.String), &job.Throttle)
+		}
+		if invalidJSON.Valid {
+			json.Unmarshal([]byte(invalidJSON.String), &job.InvalidRecipients)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+const bulkJobMessageColumns = "position, contact_id, phone, name, content, status, session_id, error_msg, message_id, sent_at"
+
+// GetBulkJobMessages returns the messages of a stored bulk messaging job by
+// position. Messages to contacts deleted since the job was created are gone.
+func (r *CampaignRepository) GetBulkJobMessages(campaignID int) ([]BulkJobMessage, error) {
+	rows, err := r.db.Query("SELECT "+bulkJobMessageColumns+" FROM campaign_messages WHERE campaign_id = ? ORDER BY position", campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages of campaign %d: %v", campaignID, err)
+	}
+	defer rows.Close()
+
+	return scanBulkJobMessages(rows)
+}
+
+// GetBulkJobResults returns a page of the messages of a stored bulk messaging
+// job by position, only those with status unless it is empty, and how many
+// match in all
+func (r *CampaignRepository) GetBulkJobResults(campaignID int, status string, offset, limit int) ([]BulkJobMessage, int, error) {
+	where := "WHERE campaign_id = ?"
+	args := []interface{}{campaignID}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM campaign_messages "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count messages of campaign %d: %v", campaignID, err)
+	}
+
+	rows, err := r.db.Query("SELECT "+bulkJobMessageColumns+" FROM campaign_messages "+where+" ORDER BY position LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get messages of campaign %d: %v", campaignID, err)
+	}
+	defer rows.Close()
+
+	messages, err := scanBulkJobMessages(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+func scanBulkJobMessages(rows *sql.Rows) ([]BulkJobMessage, error) {
+	messages := []BulkJobMessage{}
+	for rows.Next() {
+		var message BulkJobMessage
+		var contactID, sentAt sql.NullInt64
+		var phone, name, sessionID, errorMsg, messageID sql.NullString
+		err := rows.Scan(&message.Position, &contactID, &phone, &name, &message.Content, &message.Status,
+			&sessionID, &errorMsg, &messageID, &sentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign message: %v", err)
+		}
+
+		message.ContactID = int(contactID.Int64)
+		message.Phone = phone.String
+		message.Name = name.String
+		message.SessionID = sessionID.String
+		message.ErrorMsg = errorMsg.String
+		message.MessageID = messageID.String
+		if sentAt.Valid {
+			t := time.Unix(sentAt.Int64, 0)
+			message.SentAt = &t
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// idOrNull converts a zero ID to NULL
+func idOrNull(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullString converts an empty string to NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+const campaignColumns = `id, user_id, name, description, group_id, contact_ids, session_id, status, message, job_id,
+	delay_between, random_delay, scheduled_at, started_at, completed_at, total_contacts, sent_count, failed_count,
+	pending_count, variables, throttle, created_at, updated_at`
+
+// CreateCampaign stores a new scheduled campaign and sets its ID
+func (r *CampaignRepository) CreateCampaign(campaign *models.Campaign) error {
+	contactIDsJSON, _ := json.Marshal(campaign.ContactIDs)
+	variablesJSON, _ := json.Marshal(campaign.Variables)
+	throttleJSON, _ := json.Marshal(campaign.Throttle)
+	campaign.CreatedAt = time.Now().UTC()
+
+	result, err := r.db.Exec(`
+		INSERT INTO campaigns (user_id, name, description, group_id, contact_ids, session_id, status, message,
+			delay_between, random_delay, scheduled_at, variables, throttle, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		idOrNull(campaign.UserID), campaign.Name, campaign.Description, campaign.GroupID, string(contactIDsJSON),
+		campaign.SessionID, campaign.Status, campaign.Message, campaign.DelayBetween, campaign.RandomDelay,
+		unixOrNull(campaign.ScheduledAt), string(variablesJSON), string(throttleJSON), campaign.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create campaign: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get campaign ID: %v", err)
+	}
+	campaign.ID = int(id)
+	return nil
+}
+
+// GetCampaign returns a scheduled campaign by ID; unless userID is 0 it must
+// belong to userID
+func (r *CampaignRepository) GetCampaign(id, userID int) (*models.Campaign, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	campaigns, err := r.queryCampaigns("WHERE id = ? AND scheduled_at IS NOT NULL"+owner, append([]interface{}{id}, ownerArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaigns) == 0 {
+		return nil, models.NewNotFoundError("campaign %d not found", id)
+	}
+	return &campaigns[0], nil
+}
+
+// GetCampaigns returns the scheduled campaigns of userID, or of every user if
+// it is 0, by scheduled time, latest first
+func (r *CampaignRepository) GetCampaigns(userID int) ([]models.Campaign, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	return r.queryCampaigns("WHERE scheduled_at IS NOT NULL"+owner+" ORDER BY scheduled_at DESC, id DESC", ownerArgs...)
+}
+
+// UpdateScheduledCampaign applies changes to a campaign that has not started
+// yet. The status is compared in the update itself, so a campaign the
+// scheduler claims meanwhile is not changed.
+func (r *CampaignRepository) UpdateScheduledCampaign(campaign *models.Campaign) error {
+	variablesJSON, _ := json.Marshal(campaign.Variables)
+	result, err := r.db.Exec(`
+		UPDATE campaigns
+		SET name = ?, description = ?, message = ?, delay_between = ?, random_delay = ?, scheduled_at = ?,
+		    variables = ?, status = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		campaign.Name, campaign.Description, campaign.Message, campaign.DelayBetween, campaign.RandomDelay,
+		unixOrNull(campaign.ScheduledAt), string(variablesJSON), campaign.Status, time.Now().Unix(),
+		campaign.ID, models.CampaignStatusScheduled,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign %d: %v", campaign.ID, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.NewBadRequestError("campaign %d has already started", campaign.ID)
+	}
+	return nil
+}
+
+// ClaimDueCampaigns marks the scheduled campaigns due at now as starting and
+// returns them. Each campaign is claimed by comparing its status, so when
+// several instances poll at once every campaign is claimed by only one.
+func (r *CampaignRepository) ClaimDueCampaigns(now time.Time) ([]models.Campaign, error) {
+	due, err := r.queryCampaigns("WHERE status = ? AND scheduled_at <= ? ORDER BY scheduled_at, id",
+		models.CampaignStatusScheduled, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]models.Campaign, 0, len(due))
+	for _, campaign := range due {
+		result, err := r.db.Exec("UPDATE campaigns SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+			models.CampaignStatusStarting, now.Unix(), campaign.ID, models.CampaignStatusScheduled)
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim campaign %d: %v", campaign.ID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			campaign.Status = models.CampaignStatusStarting
+			claimed = append(claimed, campaign)
+		}
+	}
+	return claimed, nil
+}
+
+// SetCampaignStatus sets the status of a campaign
+func (r *CampaignRepository) SetCampaignStatus(id int, status string) error {
+	if _, err := r.db.Exec("UPDATE campaigns SET status = ?, updated_at = ? WHERE id = ?", status, time.Now().Unix(), id); err != nil {
+		return fmt.Errorf("failed to set status of campaign %d: %v", id, err)
+	}
+	return nil
+}
+
+func (r *CampaignRepository) queryCampaigns(where string, args ...interface{}) ([]models.Campaign, error) {
+	rows, err := r.db.Query("SELECT "+campaignColumns+" FROM campaigns "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %v", err)
+	}
+	defer rows.Close()
+
+	campaigns := []models.Campaign{}
+	for rows.Next() {
+		var campaign models.Campaign
+		var userID, groupID, scheduledAt, startedAt, completedAt, updatedAt sql.NullInt64
+		var description, contactIDsJSON, message, jobID, variablesJSON, throttleJSON sql.NullString
+		var createdAt int64
+		err := rows.Scan(
+			&campaign.ID, &userID, &campaign.Name, &description, &groupID, &contactIDsJSON, &campaign.SessionID,
+			&campaign.Status, &message, &jobID, &campaign.DelayBetween, &campaign.RandomDelay, &scheduledAt,
+			&startedAt, &completedAt, &campaign.TotalContacts, &campaign.SentCount, &campaign.FailedCount,
+			&campaign.PendingCount, &variablesJSON, &throttleJSON, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %v", err)
+		}
+
+		campaign.UserID = int(userID.Int64)
+		campaign.Description = description.String
+		campaign.Message = message.String
+		campaign.JobID = jobID.String
+		if groupID.Valid {
+			id := int(groupID.Int64)
+			campaign.GroupID = &id
+		}
+		if contactIDsJSON.Valid {
+			json.Unmarshal([]byte(contactIDsJSON.String), &campaign.ContactIDs)
+		}
+		if variablesJSON.Valid {
+			json.Unmarshal([]byte(variablesJSON.String), &campaign.Variables)
+		}
+		if throttleJSON.Valid {
+			json.Unmarshal([]byte(throttleJSON.String), &campaign.Throttle)
+		}
+		campaign.ScheduledAt = utcOrNil(scheduledAt)
+		campaign.StartedAt = utcOrNil(startedAt)
+		campaign.CompletedAt = utcOrNil(completedAt)
+		campaign.UpdatedAt = utcOrNil(updatedAt)
+		campaign.CreatedAt = time.Unix(createdAt, 0).UTC()
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// utcOrNil converts a nullable unix timestamp to a UTC time
+func utcOrNil(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := time.Unix(value.Int64, 0).UTC()
+	return &t
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// dedupPageSize is how many contact numbers are read at a time when looking
+// for duplicates
+const dedupPageSize = 1000
+
+// normalizeContactPhone returns raw in E.164, interpreting national numbers in
+// the server's default region, or raw unchanged when it cannot be parsed
+func normalizeContactPhone(raw string) string {
+	number, err := phone.Parse(raw, "")
+	if err != nil {
+		return raw
+	}
+	return number.E164
+}
+
+// FindDuplicateContacts groups the contacts of each user by their number
+// normalized to E.164, interpreting national numbers in region; unless userID
+// is 0 only the contacts of userID are looked at. It returns the clusters of
+// contacts sharing a number, each with the merged contact a merge would keep,
+// the numbers of the remaining contacts that are not stored in E.164 (by
+// contact ID), and the IDs of contacts whose number cannot be parsed.
+func (r *ContactRepository) FindDuplicateContacts(region string, userID int) ([]models.ContactDuplicateCluster, map[int]string, []int, error) {
+	byPhone := make(map[models.ContactPhoneKey][]int)
+	stored := make(map[int]string)
+	unparsable := []int{}
+
+	afterID := 0
+	for {
+		page, err := r.GetContactPhonesAfter(afterID, dedupPageSize, userID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, contact := range page {
+			number, err := phone.Parse(contact.Phone, region)
+			if err != nil {
+				unparsable = append(unparsable, contact.ID)
+				continue
+			}
+			// Contacts of different users are never duplicates of each other
+			key := models.ContactPhoneKey{UserID: contact.UserID, Phone: number.E164}
+			byPhone[key] = append(byPhone[key], contact.ID)
+			stored[contact.ID] = contact.Phone
+		}
+		if len(page) < dedupPageSize {
+			break
+		}
+		afterID = page[len(page)-1].ID
+	}
+
+	clusters := []models.ContactDuplicateCluster{}
+	normalize := make(map[int]string)
+	for key, ids := range byPhone {
+		e164 := key.Phone
+		if len(ids) == 1 {
+			if stored[ids[0]] != e164 {
+				normalize[ids[0]] = e164
+			}
+			continue
+		}
+
+		contacts, err := r.GetContactsByIDs(ids, 0)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// IDs grow with insertion, so the lowest is the oldest contact
+		sort.Slice(contacts, func(i, j int) bool { return contacts[i].ID < contacts[j].ID })
+
+		cluster := models.ContactDuplicateCluster{
+			UserID:   key.UserID,
+			Phone:    e164,
+			KeepID:   contacts[0].ID,
+			MergeIDs: make([]int, 0, len(contacts)-1),
+			Contacts: contacts,
+			Merged:   mergeContacts(contacts, e164),
+		}
+		for _, contact := range contacts[1:] {
+			cluster.MergeIDs = append(cluster.MergeIDs, contact.ID)
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].KeepID < clusters[j].KeepID })
+	sort.Ints(unparsable)
+
+	return clusters, normalize, unparsable, nil
+}
+
+// mergeContacts combines contacts, oldest first, into the first one: tags are
+// united, empty fields are filled from the newer contacts, message counts are
+// added up and activity times take the latest value
+func mergeContacts(contacts []models.Contact, e164 string) *models.Contact {
+	merged := contacts[0]
+	merged.Phone = e164
+	merged.Tags = append([]string(nil), contacts[0].Tags...)
+
+	for _, other := range contacts[1:] {
+		for _, tag := range other.Tags {
+			if !containsString(merged.Tags, tag) {
+				merged.Tags = append(merged.Tags, tag)
+			}
+		}
+		if merged.Name == "" || merged.Name == contacts[0].Phone {
+			merged.Name = other.Name
+		}
+		if merged.Email == "" {
+			merged.Email = other.Email
+		}
+		if merged.Company == "" {
+			merged.Company = other.Company
+		}
+		if merged.Position == "" {
+			merged.Position = other.Position
+		}
+		if merged.Notes == "" {
+			merged.Notes = other.Notes
+		}
+		if merged.GroupID == nil && other.GroupID != nil {
+			merged.GroupID = other.GroupID
+			merged.Group = other.Group
+		}
+		merged.IsActive = merged.IsActive || other.IsActive
+		merged.InboundCount += other.InboundCount
+		merged.OutboundCount += other.OutboundCount
+		if other.EngagementScore > merged.EngagementScore {
+			merged.EngagementScore = other.EngagementScore
+		}
+		merged.RepliedToCampaign = merged.RepliedToCampaign || other.RepliedToCampaign
+		merged.LastContact = laterTime(merged.LastContact, other.LastContact)
+		merged.LastInboundAt = laterTime(merged.LastInboundAt, other.LastInboundAt)
+	}
+	return &merged
+}
+
+// MergeContactCluster writes the merged contact of a duplicate cluster, moves
+// the campaign messages and campaign targets of the other contacts to it and
+// deletes them, in one transaction
+func (r *ContactRepository) MergeContactCluster(cluster models.ContactDuplicateCluster) error {
+	if len(cluster.MergeIDs) == 0 {
+		return nil
+	}
+	merged := cluster.Merged
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	tagsJSON, _ := json.Marshal(merged.Tags)
+	_, err = tx.Exec(`
+		UPDATE contacts
+		SET name = ?, phone = ?, email = ?, company = ?, position = ?, group_id = ?, tags = ?, notes = ?,
+		    is_active = ?, last_contact = ?, inbound_count = ?, outbound_count = ?,
+		    engagement_score = ?, last_inbound_at = ?, replied_to_campaign = ?, updated_at = ?
+		WHERE id = ?`,
+		merged.Name, merged.Phone, merged.Email, merged.Company, merged.Position, merged.GroupID, string(tagsJSON), merged.Notes,
+		merged.IsActive, unixOrNull(merged.LastContact), merged.InboundCount, merged.OutboundCount,
+		merged.EngagementScore, unixOrNull(merged.LastInboundAt), merged.RepliedToCampaign, time.Now().Unix(),
+		cluster.KeepID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update contact %d: %v", cluster.KeepID, err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(cluster.MergeIDs)), ",")
+	args := make([]interface{}, 0, len(cluster.MergeIDs)+1)
+	args = append(args, cluster.KeepID)
+	for _, id := range cluster.MergeIDs {
+		args = append(args, id)
+	}
+	if _, err := tx.Exec("UPDATE campaign_messages SET contact_id = ? WHERE contact_id IN ("+placeholders+")", args...); err != nil {
+		return fmt.Errorf("failed to move campaign messages: %v", err)
+	}
+	if err := r.repointCampaignTargets(tx, cluster.KeepID, cluster.MergeIDs); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM contacts WHERE id IN ("+placeholders+")", args[1:]...); err != nil {
+		return fmt.Errorf("failed to delete merged contacts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// repointCampaignTargets replaces merged contact IDs with the kept one in the
+// contact_ids of campaigns
+func (r *ContactRepository) repointCampaignTargets(tx *sql.Tx, keepID int, mergeIDs []int) error {
+	conditions := make([]string, 0, len(mergeIDs))
+	args := make([]interface{}, 0, len(mergeIDs))
+	for _, id := range mergeIDs {
+		conditions = append(conditions, r.dialect.jsonArrayContains("contact_ids"))
+		args = append(args, fmt.Sprint(id))
+	}
+
+	rows, err := tx.Query("SELECT id, contact_ids FROM campaigns WHERE "+strings.Join(conditions, " OR "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query campaign targets: %v", err)
+	}
+	updates := make(map[int][]int)
+	for rows.Next() {
+		var campaignID int
+		var idsJSON string
+		if err := rows.Scan(&campaignID, &idsJSON); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan campaign targets: %v", err)
+		}
+		var ids []int
+		if err := json.Unmarshal([]byte(idsJSON), &ids); err != nil {
+			continue
+		}
+		repointed := make([]int, 0, len(ids))
+		for _, id := range ids {
+			for _, merged := range mergeIDs {
+				if id == merged {
+					id = keepID
+					break
+				}
+			}
+			if !containsInt(repointed, id) {
+				repointed = append(repointed, id)
+			}
+		}
+		updates[campaignID] = repointed
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read campaign targets: %v", err)
+	}
+
+	for campaignID, ids := range updates {
+		idsJSON, _ := json.Marshal(ids)
+		if _, err := tx.Exec("UPDATE campaigns SET contact_ids = ? WHERE id = ?", string(idsJSON), campaignID); err != nil {
+			return fmt.Errorf("failed to update targets of campaign %d: %v", campaignID, err)
+		}
+	}
+	return nil
+}
+
+// NormalizeContactPhones rewrites the numbers of contacts, by contact ID, in
+// one transaction
+func (r *ContactRepository) NormalizeContactPhones(phones map[int]string) error {
+	if len(phones) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE contacts SET phone = ?, updated_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	for id, e164 := range phones {
+		if _, err := stmt.Exec(e164, now, id); err != nil {
+			return fmt.Errorf("failed to normalize phone of contact %d: %v", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// laterTime returns the later of two optional times
+func laterTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// unixOrNull converts an optional time to a nullable unix timestamp
+func unixOrNull(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -10,20 +10,22 @@ import (
 )
 
 type ContactGroupRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 func NewContactGroupRepository(db *sql.DB) *ContactGroupRepository {
-	return &ContactGroupRepository{db: db}
+	return &ContactGroupRepository{db: db, dialect: dialectOf(db)}
 }
 
 // CreateContactGroup creates a new contact group
 func (r *ContactGroupRepository) CreateContactGroup(group *models.ContactGroup) error {
 	query := `
-		INSERT INTO contact_groups (name, description, color, is_active, created_at)
-		VALUES (?, ?, ?, ?, ?)`
+		INSERT INTO contact_groups (user_id, name, description, color, is_active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
 	
 	result, err := r.db.Exec(query,
+		ownerOrNull(group.UserID),
 		group.Name,
 		group.Description,
 		group.Color,
@@ -46,22 +48,25 @@ func (r *ContactGroupRepository) CreateContactGroup(group *models.ContactGroup)
 	return nil
 }
 
-// GetContactGroup retrieves a contact group by ID
-func (r *ContactGroupRepository) GetContactGroup(id int) (*models.ContactGroup, error) {
+// GetContactGroup retrieves a contact group by ID. Unless userID is 0, groups
+// of other users are not found.
+func (r *ContactGroupRepository) GetContactGroup(id, userID int) (*models.ContactGroup, error) {
 	group := &models.ContactGroup{}
-	var updatedAt sql.NullInt64
+	var owner, updatedAt sql.NullInt64
 	var createdAt int64
 	
+	ownerCond, ownerArgs := ownerCondition("cg.user_id", userID)
 	query := `
-		SELECT cg.id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
+		SELECT cg.id, cg.user_id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
 		       COUNT(c.id) as contact_count
 		FROM contact_groups cg
 		LEFT JOIN contacts c ON cg.id = c.group_id AND c.is_active = true
-		WHERE cg.id = ?
+		WHERE cg.id = ?` + ownerCond + `
 		GROUP BY cg.id`
 	
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, append([]interface{}{id}, ownerArgs...)...).Scan(
 		&group.ID,
+		&owner,
 		&group.Name,
 		&group.Description,
 		&group.Color,
@@ -73,10 +78,11 @@ func (r *ContactGroupRepository) GetContactGroup(id int) (*models.ContactGroup,
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("contact group not found")
+			return nil, models.NewNotFoundError("contact group %d not found", id)
 		}
 		return nil, fmt.Errorf("failed to get contact group: %v", err)
 	}
+	group.UserID = int(owner.Int64)
 	
 	// Parse timestamps
 	group.CreatedAt = time.Unix(createdAt, 0)
@@ -88,17 +94,20 @@ func (r *ContactGroupRepository) GetContactGroup(id int) (*models.ContactGroup,
 	return group, nil
 }
 
-// GetContactGroups retrieves all contact groups
-func (r *ContactGroupRepository) GetContactGroups() ([]models.ContactGroup, error) {
+// GetContactGroups retrieves all contact groups of userID, or of every user
+// when userID is 0
+func (r *ContactGroupRepository) GetContactGroups(userID int) ([]models.ContactGroup, error) {
+	owner, ownerArgs := ownerCondition("cg.user_id", userID)
 	query := `
-		SELECT cg.id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
+		SELECT cg.id, cg.user_id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
 		       COUNT(c.id) as contact_count
 		FROM contact_groups cg
 		LEFT JOIN contacts c ON cg.id = c.group_id AND c.is_active = true
+		WHERE 1=1` + owner + `
 		GROUP BY cg.id
 		ORDER BY cg.name`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, ownerArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query contact groups: %v", err)
 	}
@@ -108,11 +117,12 @@ func (r *ContactGroupRepository) GetContactGroups() ([]models.ContactGroup, erro
 	
 	for rows.Next() {
 		group := models.ContactGroup{}
-		var updatedAt sql.NullInt64
+		var owner, updatedAt sql.NullInt64
 		var createdAt int64
 		
 		err := rows.Scan(
 			&group.ID,
+			&owner,
 			&group.Name,
 			&group.Description,
 			&group.Color,
@@ -125,6 +135,7 @@ func (r *ContactGroupRepository) GetContactGroups() ([]models.ContactGroup, erro
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact group: %v", err)
 		}
+		group.UserID = int(owner.Int64)
 		
 		// Parse timestamps
 		group.CreatedAt = time.Unix(createdAt, 0)
@@ -139,18 +150,20 @@ func (r *ContactGroupRepository) GetContactGroups() ([]models.ContactGroup, erro
 	return groups, nil
 }
 
-// GetActiveContactGroups retrieves only active contact groups
-func (r *ContactGroupRepository) GetActiveContactGroups() ([]models.ContactGroup, error) {
+// GetActiveContactGroups retrieves only active contact groups of userID, or of
+// every user when userID is 0
+func (r *ContactGroupRepository) GetActiveContactGroups(userID int) ([]models.ContactGroup, error) {
+	owner, ownerArgs := ownerCondition("cg.user_id", userID)
 	query := `
-		SELECT cg.id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
+		SELECT cg.id, cg.user_id, cg.name, cg.description, cg.color, cg.is_active, cg.created_at, cg.updated_at,
 		       COUNT(c.id) as contact_count
 		FROM contact_groups cg
 		LEFT JOIN contacts c ON cg.id = c.group_id AND c.is_active = true
-		WHERE cg.is_active = true
+		WHERE cg.is_active = true` + owner + `
 		GROUP BY cg.id
 		ORDER BY cg.name`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, ownerArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active contact groups: %v", err)
 	}
@@ -160,11 +173,12 @@ func (r *ContactGroupRepository) GetActiveContactGroups() ([]models.ContactGroup
 	
 	for rows.Next() {
 		group := models.ContactGroup{}
-		var updatedAt sql.NullInt64
+		var owner, updatedAt sql.NullInt64
 		var createdAt int64
 		
 		err := rows.Scan(
 			&group.ID,
+			&owner,
 			&group.Name,
 			&group.Description,
 			&group.Color,
@@ -177,6 +191,7 @@ func (r *ContactGroupRepository) GetActiveContactGroups() ([]models.ContactGroup
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact group: %v", err)
 		}
+		group.UserID = int(owner.Int64)
 		
 		// Parse timestamps
 		group.CreatedAt = time.Unix(createdAt, 0)
@@ -191,8 +206,9 @@ func (r *ContactGroupRepository) GetActiveContactGroups() ([]models.ContactGroup
 	return groups, nil
 }
 
-// UpdateContactGroup updates an existing contact group
-func (r *ContactGroupRepository) UpdateContactGroup(id int, req models.UpdateContactGroupRequest) error {
+// UpdateContactGroup updates an existing contact group. Unless userID is 0,
+// groups of other users are not found.
+func (r *ContactGroupRepository) UpdateContactGroup(id, userID int, req models.UpdateContactGroupRequest) error {
 	setParts := []string{}
 	args := []interface{}{}
 	
@@ -224,7 +240,9 @@ func (r *ContactGroupRepository) UpdateContactGroup(id int, req models.UpdateCon
 	args = append(args, time.Now().Unix())
 	args = append(args, id)
 	
-	query := fmt.Sprintf("UPDATE contact_groups SET %s WHERE id = ?", strings.Join(setParts, ", "))
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	query := fmt.Sprintf("UPDATE contact_groups SET %s WHERE id = ?", strings.Join(setParts, ", ")) + owner
+	args = append(args, ownerArgs...)
 	
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
@@ -237,14 +255,19 @@ func (r *ContactGroupRepository) UpdateContactGroup(id int, req models.UpdateCon
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("contact group not found")
+		return models.NewNotFoundError("contact group %d not found", id)
 	}
 	
 	return nil
 }
 
-// DeleteContactGroup deletes a contact group
-func (r *ContactGroupRepository) DeleteContactGroup(id int) error {
+// DeleteContactGroup deletes a contact group. Unless userID is 0, groups of
+// other users are not found.
+func (r *ContactGroupRepository) DeleteContactGroup(id, userID int) error {
+	if _, err := r.GetContactGroup(id, userID); err != nil {
+		return err
+	}
+	
 	// Check if group has contacts
 	var contactCount int
 	err := r.db.QueryRow("SELECT COUNT(*) FROM contacts WHERE group_id = ?", id).Scan(&contactCount)
@@ -269,16 +292,16 @@ func (r *ContactGroupRepository) DeleteContactGroup(id int) error {
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("contact group not found")
+		return models.NewNotFoundError("contact group %d not found", id)
 	}
 	
 	return nil
 }
 
-// CheckGroupNameExists checks if a group name already exists
-func (r *ContactGroupRepository) CheckGroupNameExists(name string, excludeID *int) (bool, error) {
-	query := "SELECT COUNT(*) FROM contact_groups WHERE name = ?"
-	args := []interface{}{name}
+// CheckGroupNameExists checks if userID already has a group with the name
+func (r *ContactGroupRepository) CheckGroupNameExists(name string, userID int, excludeID *int) (bool, error) {
+	query := "SELECT COUNT(*) FROM contact_groups WHERE name = ? AND user_id " + r.dialect.nullSafeEqual() + " ?"
+	args := []interface{}{name, ownerOrNull(userID)}
 	
 	if excludeID != nil {
 		query += " AND id != ?"
@@ -294,13 +317,15 @@ func (r *ContactGroupRepository) CheckGroupNameExists(name string, excludeID *in
 	return count > 0, nil
 }
 
-// GetGroupStats returns statistics for contact groups
-func (r *ContactGroupRepository) GetGroupStats() (map[string]interface{}, error) {
+// GetGroupStats returns statistics for the contact groups of userID, or of
+// every user when userID is 0
+func (r *ContactGroupRepository) GetGroupStats(userID int) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
+	owner, ownerArgs := ownerCondition("user_id", userID)
 	
 	// Total groups
 	var totalGroups, activeGroups int
-	err := r.db.QueryRow("SELECT COUNT(*), SUM(CASE WHEN is_active THEN 1 ELSE 0 END) FROM contact_groups").Scan(&totalGroups, &activeGroups)
+	err := r.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_active THEN 1 ELSE 0 END), 0) FROM contact_groups WHERE 1=1"+owner, ownerArgs...).Scan(&totalGroups, &activeGroups)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get group counts: %v", err)
 	}
@@ -310,7 +335,7 @@ func (r *ContactGroupRepository) GetGroupStats() (map[string]interface{}, error)
 	
 	// Total contacts in groups
 	var contactsInGroups int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM contacts WHERE group_id IS NOT NULL AND is_active = true").Scan(&contactsInGroups)
+	err = r.db.QueryRow("SELECT COUNT(*) FROM contacts WHERE group_id IS NOT NULL AND is_active = true"+owner, ownerArgs...).Scan(&contactsInGroups)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get contacts in groups count: %v", err)
 	}
@@ -319,7 +344,7 @@ func (r *ContactGroupRepository) GetGroupStats() (map[string]interface{}, error)
 	
 	// Contacts without groups
 	var contactsWithoutGroup int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM contacts WHERE group_id IS NULL AND is_active = true").Scan(&contactsWithoutGroup)
+	err = r.db.QueryRow("SELECT COUNT(*) FROM contacts WHERE group_id IS NULL AND is_active = true"+owner, ownerArgs...).Scan(&contactsWithoutGroup)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get contacts without group count: %v", err)
 	}
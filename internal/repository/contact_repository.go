@@ -11,67 +11,33 @@ import (
 )
 
 type ContactRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 func NewContactRepository(db *sql.DB) *ContactRepository {
-	return &ContactRepository{db: db}
+	return &ContactRepository{db: db, dialect: dialectOf(db)}
 }
 
-// CreateContact creates a new contact
-func (r *ContactRepository) CreateContact(contact *models.Contact) error {
-	tagsJSON, _ := json.Marshal(contact.Tags)
-	
-	query := `
-		INSERT INTO contacts (name, phone, email, company, position, group_id, tags, notes, is_active, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	
-	result, err := r.db.Exec(query,
-		contact.Name,
-		contact.Phone,
-		contact.Email,
-		contact.Company,
-		contact.Position,
-		contact.GroupID,
-		string(tagsJSON),
-		contact.Notes,
-		contact.IsActive,
-		time.Now().Unix(),
-	)
-	
-	if err != nil {
-		return fmt.Errorf("failed to create contact: %v", err)
-	}
-	
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get contact ID: %v", err)
-	}
-	
-	contact.ID = int(id)
-	contact.CreatedAt = time.Now()
-	
-	return nil
-}
+// contactColumns lists the columns read by scanContact, in scan order.
+// Queries must join contact_groups as cg.
+const contactColumns = `c.id, c.user_id, c.name, c.phone, c.email, c.company, c.position, c.group_id, c.tags,
+		       c.notes, c.is_active, c.last_contact, c.created_at, c.updated_at,
+		       c.engagement_score, c.last_inbound_at, c.replied_to_campaign,
+		       c.inbound_count, c.outbound_count,
+		       cg.name as group_name, cg.color as group_color`
 
-// GetContact retrieves a contact by ID
-func (r *ContactRepository) GetContact(id int) (*models.Contact, error) {
+// scanContact scans a row selected with contactColumns
+func scanContact(row rowScanner) (*models.Contact, error) {
 	contact := &models.Contact{}
 	var tagsJSON, groupName, groupColor sql.NullString
-	var lastContact sql.NullInt64
+	var userID, lastContact, lastInboundAt sql.NullInt64
 	var updatedAt sql.NullInt64
 	var createdAt int64
 	
-	query := `
-		SELECT c.id, c.name, c.phone, c.email, c.company, c.position, c.group_id, c.tags, 
-		       c.notes, c.is_active, c.last_contact, c.created_at, c.updated_at,
-		       cg.name as group_name, cg.color as group_color
-		FROM contacts c
-		LEFT JOIN contact_groups cg ON c.group_id = cg.id
-		WHERE c.id = ?`
-	
-	err := r.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&contact.ID,
+		&userID,
 		&contact.Name,
 		&contact.Phone,
 		&contact.Email,
@@ -84,27 +50,36 @@ func (r *ContactRepository) GetContact(id int) (*models.Contact, error) {
 		&lastContact,
 		&createdAt,
 		&updatedAt,
+		&contact.EngagementScore,
+		&lastInboundAt,
+		&contact.RepliedToCampaign,
+		&contact.InboundCount,
+		&contact.OutboundCount,
 		&groupName,
 		&groupColor,
 	)
-	
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("contact not found")
-		}
-		return nil, fmt.Errorf("failed to get contact: %v", err)
+		return nil, err
 	}
 	
+	contact.UserID = int(userID.Int64)
+	
 	// Parse timestamps
 	contact.CreatedAt = time.Unix(createdAt, 0)
+	contact.Version = createdAt
 	if updatedAt.Valid {
 		t := time.Unix(updatedAt.Int64, 0)
 		contact.UpdatedAt = &t
+		contact.Version = updatedAt.Int64
 	}
 	if lastContact.Valid {
 		t := time.Unix(lastContact.Int64, 0)
 		contact.LastContact = &t
 	}
+	if lastInboundAt.Valid {
+		t := time.Unix(lastInboundAt.Int64, 0)
+		contact.LastInboundAt = &t
+	}
 	
 	// Parse tags
 	if tagsJSON.Valid && tagsJSON.String != "" {
@@ -123,50 +98,145 @@ func (r *ContactRepository) GetContact(id int) (*models.Contact, error) {
 	return contact, nil
 }
 
-// GetContacts retrieves contacts with filtering and pagination
-func (r *ContactRepository) GetContacts(req models.ContactSearchRequest) (*models.ContactListResponse, error) {
-	// Build base query
+// ownerCondition returns the condition limiting a query to the rows of userID
+// in the given owner column, or nothing when userID is 0 (every user's rows)
+func ownerCondition(column string, userID int) (string, []interface{}) {
+	if userID == 0 {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []interface{}{userID}
+}
+
+// ownerOrNull stores a contact or group without an owner as NULL
+func ownerOrNull(userID int) interface{} {
+	if userID == 0 {
+		return nil
+	}
+	return userID
+}
+
+// CreateContact creates a new contact, storing its number in E.164 when it can be parsed
+func (r *ContactRepository) CreateContact(contact *models.Contact) error {
+	contact.Phone = normalizeContactPhone(contact.Phone)
+	tagsJSON, _ := json.Marshal(contact.Tags)
+	
+	query := `
+		INSERT INTO contacts (user_id, name, phone, email, company, position, group_id, tags, notes, is_active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	
+	result, err := r.db.Exec(query,
+		ownerOrNull(contact.UserID),
+		contact.Name,
+		contact.Phone,
+		contact.Email,
+		contact.Company,
+		contact.Position,
+		contact.GroupID,
+		string(tagsJSON),
+		contact.Notes,
+		contact.IsActive,
+		time.Now().Unix(),
+	)
+	
+	if err != nil {
+		return fmt.Errorf("failed to create contact: %v", err)
+	}
+	
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get contact ID: %v", err)
+	}
+	
+	contact.ID = int(id)
+	contact.CreatedAt = time.Now()
+	
+	return nil
+}
+
+// GetContact retrieves a contact by ID. Unless userID is 0, contacts of other
+// users are not found.
+func (r *ContactRepository) GetContact(id, userID int) (*models.Contact, error) {
+	owner, ownerArgs := ownerCondition("c.user_id", userID)
+	query := `
+		SELECT `+contactColumns+`
+		FROM contacts c
+		LEFT JOIN contact_groups cg ON c.group_id = cg.id
+		WHERE c.id = ?` + owner
+	
+	contact, err := scanContact(r.db.QueryRow(query, append([]interface{}{id}, ownerArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.NewNotFoundError("contact %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get contact: %v", err)
+	}
+	
+	return contact, nil
+}
+
+// contactFilter builds the FROM and WHERE clauses selecting the contacts
+// matching the filters of req, joining contact_groups as cg
+func contactFilter(req models.ContactSearchRequest) (string, []interface{}, error) {
 	baseQuery := `
 		FROM contacts c
 		LEFT JOIN contact_groups cg ON c.group_id = cg.id
 		WHERE 1=1`
 	
 	args := []interface{}{}
-	argIndex := 1
 	
 	// Add filters
+	if req.UserID != 0 {
+		baseQuery += " AND c.user_id = ?"
+		args = append(args, req.UserID)
+	}
+	
 	if req.Query != "" {
-		baseQuery += fmt.Sprintf(" AND (c.name LIKE ? OR c.phone LIKE ? OR c.email LIKE ? OR c.company LIKE ?)")
+		baseQuery += " AND (c.name LIKE ? OR c.phone LIKE ? OR c.email LIKE ? OR c.company LIKE ?)"
 		likeQuery := "%" + req.Query + "%"
 		args = append(args, likeQuery, likeQuery, likeQuery, likeQuery)
-		argIndex += 4
 	}
 	
 	if req.GroupID != nil {
-		baseQuery += fmt.Sprintf(" AND c.group_id = ?")
+		baseQuery += " AND c.group_id = ?"
 		args = append(args, *req.GroupID)
-		argIndex++
 	}
 	
 	if req.IsActive != nil {
-		baseQuery += fmt.Sprintf(" AND c.is_active = ?")
+		baseQuery += " AND c.is_active = ?"
 		args = append(args, *req.IsActive)
-		argIndex++
+	}
+	
+	if req.Segment != "" {
+		condition, segmentArgs, err := segmentCondition(req.Segment, req.Thresholds)
+		if err != nil {
+			return "", nil, err
+		}
+		baseQuery += " AND " + condition
+		args = append(args, segmentArgs...)
 	}
 	
 	if len(req.Tags) > 0 {
 		// For SQLite/MySQL JSON search - simplified approach
 		for _, tag := range req.Tags {
-			baseQuery += fmt.Sprintf(" AND c.tags LIKE ?")
+			baseQuery += " AND c.tags LIKE ?"
 			args = append(args, "%"+tag+"%")
-			argIndex++
 		}
 	}
 	
+	return baseQuery, args, nil
+}
+
+// GetContacts retrieves contacts with filtering and pagination
+func (r *ContactRepository) GetContacts(req models.ContactSearchRequest) (*models.ContactListResponse, error) {
+	baseQuery, args, err := contactFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Count total records
 	countQuery := "SELECT COUNT(*) " + baseQuery
 	var total int
-	err := r.db.QueryRow(countQuery, args...).Scan(&total)
+	err = r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count contacts: %v", err)
 	}
@@ -188,9 +258,7 @@ func (r *ContactRepository) GetContacts(req models.ContactSearchRequest) (*model
 	
 	// Build main query with pagination
 	selectQuery := `
-		SELECT c.id, c.name, c.phone, c.email, c.company, c.position, c.group_id, c.tags,
-		       c.notes, c.is_active, c.last_contact, c.created_at, c.updated_at,
-		       cg.name as group_name, cg.color as group_color
+		SELECT `+contactColumns+`
 		` + baseQuery + `
 		ORDER BY c.created_at DESC
 		LIMIT ? OFFSET ?`
@@ -206,60 +274,12 @@ func (r *ContactRepository) GetContacts(req models.ContactSearchRequest) (*model
 	var contacts []models.Contact
 	
 	for rows.Next() {
-		contact := models.Contact{}
-		var tagsJSON, groupName, groupColor sql.NullString
-		var lastContact sql.NullInt64
-		var updatedAt sql.NullInt64
-		var createdAt int64
-		
-		err := rows.Scan(
-			&contact.ID,
-			&contact.Name,
-			&contact.Phone,
-			&contact.Email,
-			&contact.Company,
-			&contact.Position,
-			&contact.GroupID,
-			&tagsJSON,
-			&contact.Notes,
-			&contact.IsActive,
-			&lastContact,
-			&createdAt,
-			&updatedAt,
-			&groupName,
-			&groupColor,
-		)
-		
+		contact, err := scanContact(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact: %v", err)
 		}
 		
-		// Parse timestamps
-		contact.CreatedAt = time.Unix(createdAt, 0)
-		if updatedAt.Valid {
-			t := time.Unix(updatedAt.Int64, 0)
-			contact.UpdatedAt = &t
-		}
-		if lastContact.Valid {
-			t := time.Unix(lastContact.Int64, 0)
-			contact.LastContact = &t
-		}
-		
-		// Parse tags
-		if tagsJSON.Valid && tagsJSON.String != "" {
-			json.Unmarshal([]byte(tagsJSON.String), &contact.Tags)
-		}
-		
-		// Set group info if exists
-		if contact.GroupID != nil && groupName.Valid {
-			contact.Group = &models.ContactGroup{
-				ID:    *contact.GroupID,
-				Name:  groupName.String,
-				Color: groupColor.String,
-			}
-		}
-		
-		contacts = append(contacts, contact)
+		contacts = append(contacts, *contact)
 	}
 	
 	pages := (total + limit - 1) / limit
@@ -273,8 +293,43 @@ func (r *ContactRepository) GetContacts(req models.ContactSearchRequest) (*model
 	}, nil
 }
 
-// UpdateContact updates an existing contact
-func (r *ContactRepository) UpdateContact(id int, req models.UpdateContactRequest) error {
+// ExportContacts returns up to limit contacts matching the filters of req
+// (ignoring its pagination) with an ID greater than afterID, in ID order.
+// Callers page through a large export by passing the last ID returned.
+func (r *ContactRepository) ExportContacts(req models.ContactSearchRequest, afterID, limit int) ([]*models.Contact, error) {
+	baseQuery, args, err := contactFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	
+	query := `
+		SELECT `+contactColumns+`
+		` + baseQuery + ` AND c.id > ?
+		ORDER BY c.id
+		LIMIT ?`
+	args = append(args, afterID, limit)
+	
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %v", err)
+	}
+	defer rows.Close()
+	
+	contacts := []*models.Contact{}
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+		contacts = append(contacts, contact)
+	}
+	
+	return contacts, rows.Err()
+}
+
+// UpdateContact updates an existing contact. Unless userID is 0, contacts of
+// other users are not found.
+func (r *ContactRepository) UpdateContact(id, userID int, req models.UpdateContactRequest) error {
 	setParts := []string{}
 	args := []interface{}{}
 	
@@ -285,7 +340,7 @@ func (r *ContactRepository) UpdateContact(id int, req models.UpdateContactReques
 	
 	if req.Phone != "" {
 		setParts = append(setParts, "phone = ?")
-		args = append(args, req.Phone)
+		args = append(args, normalizeContactPhone(req.Phone))
 	}
 	
 	if req.Email != "" {
@@ -325,14 +380,20 @@ func (r *ContactRepository) UpdateContact(id int, req models.UpdateContactReques
 	}
 	
 	if len(setParts) == 0 {
-		return fmt.Errorf("no fields to update")
+		return models.NewBadRequestError("no fields to update")
 	}
 	
-	setParts = append(setParts, "updated_at = ?")
+	setParts = append(setParts, bumpVersion)
 	args = append(args, time.Now().Unix())
 	args = append(args, id)
 	
-	query := fmt.Sprintf("UPDATE contacts SET %s WHERE id = ?", strings.Join(setParts, ", "))
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	query := fmt.Sprintf("UPDATE contacts SET %s WHERE id = ?", strings.Join(setParts, ", ")) + owner
+	args = append(args, ownerArgs...)
+	if req.ExpectedVersion != nil {
+		query += " AND " + versionColumn + " = ?"
+		args = append(args, *req.ExpectedVersion)
+	}
 	
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
@@ -345,17 +406,40 @@ func (r *ContactRepository) UpdateContact(id int, req models.UpdateContactReques
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("contact not found")
+		if _, err := r.GetContactVersion(id, userID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
 	}
 	
 	return nil
 }
 
-// DeleteContact deletes a contact
-func (r *ContactRepository) DeleteContact(id int) error {
-	query := "DELETE FROM contacts WHERE id = ?"
+// GetContactVersion returns the current version of a contact. Unless userID is
+// 0, contacts of other users are not found.
+func (r *ContactRepository) GetContactVersion(id, userID int) (int64, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	query := "SELECT " + versionColumn + " FROM contacts WHERE id = ?" + owner
+	
+	var version int64
+	err := r.db.QueryRow(query, append([]interface{}{id}, ownerArgs...)...).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, models.NewNotFoundError("contact %d not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get contact version: %v", err)
+	}
+	
+	return version, nil
+}
+
+// DeleteContact deletes a contact. Unless userID is 0, contacts of other users
+// are not found.
+func (r *ContactRepository) DeleteContact(id, userID int) error {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	query := "DELETE FROM contacts WHERE id = ?" + owner
 	
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, append([]interface{}{id}, ownerArgs...)...)
 	if err != nil {
 		return fmt.Errorf("failed to delete contact: %v", err)
 	}
@@ -366,14 +450,16 @@ func (r *ContactRepository) DeleteContact(id int) error {
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("contact not found")
+		return models.NewNotFoundError("contact %d not found", id)
 	}
 	
 	return nil
 }
 
-// BulkCreateContacts creates multiple contacts in a transaction
-func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact) (*models.ContactImportResult, error) {
+// BulkCreateContacts creates multiple contacts of userID in a transaction,
+// storing numbers in E.164 when they can be parsed and skipping numbers the
+// user already has a contact for
+func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact, userID int) (*models.ContactImportResult, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %v", err)
@@ -386,8 +472,8 @@ func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact) (*mode
 	}
 	
 	query := `
-		INSERT INTO contacts (name, phone, email, company, position, group_id, tags, notes, is_active, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO contacts (user_id, name, phone, email, company, position, group_id, tags, notes, is_active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	stmt, err := tx.Prepare(query)
 	if err != nil {
@@ -396,10 +482,12 @@ func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact) (*mode
 	defer stmt.Close()
 	
 	for i, contact := range contacts {
-		// Check for duplicate phone number
+		contact.Phone = normalizeContactPhone(contact.Phone)
+		
+		// Check for duplicate phone number, stored in E.164 or as bare digits
 		var existingID int
-		checkQuery := "SELECT id FROM contacts WHERE phone = ?"
-		err := tx.QueryRow(checkQuery, contact.Phone).Scan(&existingID)
+		checkQuery := "SELECT id FROM contacts WHERE user_id " + r.dialect.nullSafeEqual() + " ? AND phone IN (?, ?) LIMIT 1"
+		err := tx.QueryRow(checkQuery, ownerOrNull(userID), contact.Phone, strings.TrimPrefix(contact.Phone, "+")).Scan(&existingID)
 		if err == nil {
 			result.Duplicates++
 			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Phone %s already exists", i+1, contact.Phone))
@@ -413,6 +501,7 @@ func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact) (*mode
 		tagsJSON, _ := json.Marshal(contact.Tags)
 		
 		_, err = stmt.Exec(
+			ownerOrNull(userID),
 			contact.Name,
 			contact.Phone,
 			contact.Email,
@@ -440,8 +529,9 @@ func (r *ContactRepository) BulkCreateContacts(contacts []models.Contact) (*mode
 	return result, nil
 }
 
-// BulkUpdateContacts performs bulk operations on contacts
-func (r *ContactRepository) BulkUpdateContacts(req models.BulkContactRequest) error {
+// BulkUpdateContacts performs bulk operations on contacts. Unless userID is 0,
+// contacts of other users are left alone.
+func (r *ContactRepository) BulkUpdateContacts(req models.BulkContactRequest, userID int) error {
 	if len(req.ContactIDs) == 0 {
 		return fmt.Errorf("no contact IDs provided")
 	}
@@ -479,6 +569,10 @@ func (r *ContactRepository) BulkUpdateContacts(req models.BulkContactRequest) er
 		return fmt.Errorf("invalid action: %s", req.Action)
 	}
 	
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	query += owner
+	args = append(args, ownerArgs...)
+	
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to execute bulk operation: %v", err)
@@ -496,8 +590,51 @@ func (r *ContactRepository) BulkUpdateContacts(req models.BulkContactRequest) er
 	return nil
 }
 
-// GetContactsByIDs retrieves multiple contacts by their IDs
-func (r *ContactRepository) GetContactsByIDs(ids []int) ([]models.Contact, error) {
+// ForeignContactIDs returns those of ids that are not contacts of userID,
+// either because they belong to another user or because they do not exist
+func (r *ContactRepository) ForeignContactIDs(ids []int, userID int) ([]int, error) {
+	foreign := []int{}
+	if len(ids) == 0 {
+		return foreign, nil
+	}
+	
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	args = append(args, ownerArgs...)
+	
+	rows, err := r.db.Query("SELECT id FROM contacts WHERE id IN ("+placeholders+")"+owner, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check contact owners: %v", err)
+	}
+	defer rows.Close()
+	
+	owned := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan contact ID: %v", err)
+		}
+		owned[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check contact owners: %v", err)
+	}
+	
+	for _, id := range ids {
+		if !owned[id] && !containsInt(foreign, id) {
+			foreign = append(foreign, id)
+		}
+	}
+	return foreign, nil
+}
+
+// GetContactsByIDs retrieves multiple contacts by their IDs. Unless userID is
+// 0, contacts of other users are left out.
+func (r *ContactRepository) GetContactsByIDs(ids []int, userID int) ([]models.Contact, error) {
 	if len(ids) == 0 {
 		return []models.Contact{}, nil
 	}
@@ -510,13 +647,13 @@ func (r *ContactRepository) GetContactsByIDs(ids []int) ([]models.Contact, error
 		args[i] = id
 	}
 	
+	owner, ownerArgs := ownerCondition("c.user_id", userID)
 	query := fmt.Sprintf(`
-		SELECT c.id, c.name, c.phone, c.email, c.company, c.position, c.group_id, c.tags,
-		       c.notes, c.is_active, c.last_contact, c.created_at, c.updated_at,
-		       cg.name as group_name, cg.color as group_color
+		SELECT `+contactColumns+`
 		FROM contacts c
 		LEFT JOIN contact_groups cg ON c.group_id = cg.id
-		WHERE c.id IN (%s)`, placeholders)
+		WHERE c.id IN (%s)`, placeholders) + owner
+	args = append(args, ownerArgs...)
 	
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
@@ -527,77 +664,29 @@ func (r *ContactRepository) GetContactsByIDs(ids []int) ([]models.Contact, error
 	var contacts []models.Contact
 	
 	for rows.Next() {
-		contact := models.Contact{}
-		var tagsJSON, groupName, groupColor sql.NullString
-		var lastContact sql.NullInt64
-		var updatedAt sql.NullInt64
-		var createdAt int64
-		
-		err := rows.Scan(
-			&contact.ID,
-			&contact.Name,
-			&contact.Phone,
-			&contact.Email,
-			&contact.Company,
-			&contact.Position,
-			&contact.GroupID,
-			&tagsJSON,
-			&contact.Notes,
-			&contact.IsActive,
-			&lastContact,
-			&createdAt,
-			&updatedAt,
-			&groupName,
-			&groupColor,
-		)
-		
+		contact, err := scanContact(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact: %v", err)
 		}
 		
-		// Parse timestamps
-		contact.CreatedAt = time.Unix(createdAt, 0)
-		if updatedAt.Valid {
-			t := time.Unix(updatedAt.Int64, 0)
-			contact.UpdatedAt = &t
-		}
-		if lastContact.Valid {
-			t := time.Unix(lastContact.Int64, 0)
-			contact.LastContact = &t
-		}
-		
-		// Parse tags
-		if tagsJSON.Valid && tagsJSON.String != "" {
-			json.Unmarshal([]byte(tagsJSON.String), &contact.Tags)
-		}
-		
-		// Set group info if exists
-		if contact.GroupID != nil && groupName.Valid {
-			contact.Group = &models.ContactGroup{
-				ID:    *contact.GroupID,
-				Name:  groupName.String,
-				Color: groupColor.String,
-			}
-		}
-		
-		contacts = append(contacts, contact)
+		contacts = append(contacts, *contact)
 	}
 	
 	return contacts, nil
 }
 
-// GetContactsByGroupID retrieves all contacts in a specific group
-func (r *ContactRepository) GetContactsByGroupID(groupID int) ([]models.Contact, error) {
+// GetContactsByGroupID retrieves all active contacts in a specific group.
+// Unless userID is 0, contacts of other users are left out.
+func (r *ContactRepository) GetContactsByGroupID(groupID, userID int) ([]models.Contact, error) {
+	owner, ownerArgs := ownerCondition("c.user_id", userID)
 	query := `
-		SELECT c.id, c.name, c.phone, c.email, c.company, c.position, c.group_id, c.tags,
-		       c.notes, c.is_active, c.last_contact, c.created_at, c.updated_at,
-		       cg.name as group_name, cg.color as group_color
+		SELECT `+contactColumns+`
 		FROM contacts c
 		LEFT JOIN contact_groups cg ON c.group_id = cg.id
-		WHERE c.group_id = ? AND c.is_active = true
+		WHERE c.group_id = ? AND c.is_active = true` + owner + `
 		ORDER BY c.name`
 	
-	rows, err := r.db.Query(query, groupID)
+	rows, err := r.db.Query(query, append([]interface{}{groupID}, ownerArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query contacts by group: %v", err)
 	}
@@ -606,60 +695,12 @@ func (r *ContactRepository) GetContactsByGroupID(groupID int) ([]models.Contact,
 	var contacts []models.Contact
 	
 	for rows.Next() {
-		contact := models.Contact{}
-		var tagsJSON, groupName, groupColor sql.NullString
-		var lastContact sql.NullInt64
-		var updatedAt sql.NullInt64
-		var createdAt int64
-		
-		err := rows.Scan(
-			&contact.ID,
-			&contact.Name,
-			&contact.Phone,
-			&contact.Email,
-			&contact.Company,
-			&contact.Position,
-			&contact.GroupID,
-			&tagsJSON,
-			&contact.Notes,
-			&contact.IsActive,
-			&lastContact,
-			&createdAt,
-			&updatedAt,
-			&groupName,
-			&groupColor,
-		)
-		
+		contact, err := scanContact(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact: %v", err)
 		}
 		
-		// Parse timestamps
-		contact.CreatedAt = time.Unix(createdAt, 0)
-		if updatedAt.Valid {
-			t := time.Unix(updatedAt.Int64, 0)
-			contact.UpdatedAt = &t
-		}
-		if lastContact.Valid {
-			t := time.Unix(lastContact.Int64, 0)
-			contact.LastContact = &t
-		}
-		
-		// Parse tags
-		if tagsJSON.Valid && tagsJSON.String != "" {
-			json.Unmarshal([]byte(tagsJSON.String), &contact.Tags)
-		}
-		
-		// Set group info if exists
-		if contact.GroupID != nil && groupName.Valid {
-			contact.Group = &models.ContactGroup{
-				ID:    *contact.GroupID,
-				Name:  groupName.String,
-				Color: groupColor.String,
-			}
-		}
-		
-		contacts = append(contacts, contact)
+		contacts = append(contacts, *contact)
 	}
 	
 	return contacts, nil
@@ -685,4 +726,428 @@ func (r *ContactRepository) UpdateLastContact(id int) error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+// segmentCondition returns the WHERE condition selecting contacts in a segment
+func segmentCondition(segment string, thresholds models.SegmentThresholds) (string, []interface{}, error) {
+	switch segment {
+	case models.SegmentEngaged:
+		return "c.engagement_score >= ?", []interface{}{thresholds.EngagedMinScore}, nil
+	case models.SegmentDormant:
+		cutoff := time.Now().AddDate(0, 0, -thresholds.DormantAfterDays).Unix()
+		return "(c.last_inbound_at IS NOT NULL AND c.last_inbound_at < ?)", []interface{}{cutoff}, nil
+	case models.SegmentNeverReplied:
+		return "c.last_inbound_at IS NULL", nil, nil
+	default:
+		return "", nil, fmt.Errorf("invalid segment: %s", segment)
+	}
+}
+
+// GetContactsBySegment retrieves all active contacts in a segment, optionally
+// limited to a group. Unless userID is 0, contacts of other users are left out.
+func (r *ContactRepository) GetContactsBySegment(segment string, thresholds models.SegmentThresholds, groupID *int, userID int) ([]models.Contact, error) {
+	condition, args, err := segmentCondition(segment, thresholds)
+	if err != nil {
+		return nil, err
+	}
+	
+	query := `
+		SELECT `+contactColumns+`
+		FROM contacts c
+		LEFT JOIN contact_groups cg ON c.group_id = cg.id
+		WHERE c.is_active = true AND ` + condition
+	
+	if groupID != nil {
+		query += " AND c.group_id = ?"
+		args = append(args, *groupID)
+	}
+	owner, ownerArgs := ownerCondition("c.user_id", userID)
+	query += owner + " ORDER BY c.name"
+	args = append(args, ownerArgs...)
+	
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts by segment: %v", err)
+	}
+	defer rows.Close()
+	
+	var contacts []models.Contact
+	
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+		
+		contacts = append(contacts, *contact)
+	}
+	
+	return contacts, nil
+}
+
+// GetContactByPhone returns the contact of userID with the given number,
+// stored either in E.164 or as bare digits, or nil if there is none
+func (r *ContactRepository) GetContactByPhone(e164 string, userID int) (*models.Contact, error) {
+	query := `
+		SELECT `+contactColumns+`
+		FROM contacts c
+		LEFT JOIN contact_groups cg ON c.group_id = cg.id
+		WHERE c.user_id `+r.dialect.nullSafeEqual()+` ? AND c.phone IN (?, ?)
+		ORDER BY c.id
+		LIMIT 1`
+	
+	contact, err := scanContact(r.db.QueryRow(query, ownerOrNull(userID), e164, strings.TrimPrefix(e164, "+")))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact by phone: %v", err)
+	}
+	
+	return contact, nil
+}
+
+// GetContactPhonesAfter returns up to limit contacts (ID, owner and phone
+// only) with an ID greater than afterID. Unless userID is 0, contacts of other
+// users are left out.
+func (r *ContactRepository) GetContactPhonesAfter(afterID, limit, userID int) ([]models.Contact, error) {
+	owner, ownerArgs := ownerCondition("user_id", userID)
+	args := append(append([]interface{}{afterID}, ownerArgs...), limit)
+	rows, err := r.db.Query("SELECT id, user_id, phone FROM contacts WHERE id > ?"+owner+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact phones: %v", err)
+	}
+	defer rows.Close()
+	
+	var contacts []models.Contact
+	for rows.Next() {
+		var contact models.Contact
+		var owner sql.NullInt64
+		if err := rows.Scan(&contact.ID, &owner, &contact.Phone); err != nil {
+			return nil, fmt.Errorf("failed to scan contact phone: %v", err)
+		}
+		contact.UserID = int(owner.Int64)
+		contacts = append(contacts, contact)
+	}
+	
+	return contacts, nil
+}
+
+// GetInboundActivity aggregates received messages per sender phone number (digits only).
+// RecentCount counts messages received at or after since.
+func (r *ContactRepository) GetInboundActivity(since time.Time) (map[string]models.InboundActivity, error) {
+	query := `
+		SELECT SUBSTRING_INDEX(SUBSTRING_INDEX(sender_jid, '@', 1), ':', 1) AS sender,
+		       `+r.dialect.unixTime("MAX("+r.dialect.timestamp("created_at")+")")+`,
+		       SUM(CASE WHEN `+r.dialect.timestamp("created_at")+` >= `+r.dialect.fromUnixTime("?")+` THEN 1 ELSE 0 END)
+		FROM messages
+		WHERE direction = 'received' AND sender_jid LIKE '%@s.whatsapp.net'
+		GROUP BY sender`
+	
+	rows, err := r.db.Query(query, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inbound activity: %v", err)
+	}
+	defer rows.Close()
+	
+	activity := make(map[string]models.InboundActivity)
+	for rows.Next() {
+		var sender string
+		var lastAt int64
+		var recent int
+		if err := rows.Scan(&sender, &lastAt, &recent); err != nil {
+			return nil, fmt.Errorf("failed to scan inbound activity: %v", err)
+		}
+		activity[sender] = models.InboundActivity{
+			LastAt:      time.Unix(lastAt, 0).UTC(),
+			RecentCount: recent,
+		}
+	}
+	
+	return activity, nil
+}
+
+// GetCampaignRepliedContacts returns which of the given contacts sent a message within window
+// after receiving a campaign message. senders maps contact IDs to their phone digits.
+func (r *ContactRepository) GetCampaignRepliedContacts(senders map[int]string, window time.Duration) (map[int]bool, error) {
+	replied := make(map[int]bool)
+	if len(senders) == 0 {
+		return replied, nil
+	}
+	
+	placeholders := strings.Repeat("?,", len(senders))
+	placeholders = placeholders[:len(placeholders)-1]
+	
+	args := make([]interface{}, 0, len(senders))
+	for id := range senders {
+		args = append(args, id)
+	}
+	
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT contact_id, sent_at
+		FROM campaign_messages
+		WHERE sent_at IS NOT NULL AND contact_id IN (%s)
+		ORDER BY contact_id, sent_at DESC`, placeholders), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign messages: %v", err)
+	}
+	
+	sentAt := make(map[int][]int64)
+	for rows.Next() {
+		var contactID int
+		var sent int64
+		if err := rows.Scan(&contactID, &sent); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan campaign message: %v", err)
+		}
+		sentAt[contactID] = append(sentAt[contactID], sent)
+	}
+	rows.Close()
+	
+	query := `
+		SELECT 1 FROM messages
+		WHERE direction = 'received'
+		  AND (sender_jid LIKE ? OR sender_jid LIKE ?)
+		  AND `+r.dialect.timestamp("created_at")+` BETWEEN `+r.dialect.fromUnixTime("?")+` AND `+r.dialect.fromUnixTime("?")+`
+		LIMIT 1`
+	
+	for contactID, times := range sentAt {
+		digits := senders[contactID]
+		for _, sent := range times {
+			var found int
+			err := r.db.QueryRow(query, digits+"@%", digits+":%", sent, sent+int64(window.Seconds())).Scan(&found)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to check campaign reply: %v", err)
+			}
+			replied[contactID] = true
+			break
+		}
+	}
+	
+	return replied, nil
+}
+
+// UpdateEngagementBatch stores computed engagement data for a batch of contacts in one transaction
+func (r *ContactRepository) UpdateEngagementBatch(batch []models.ContactEngagement) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	
+	stmt, err := tx.Prepare(`
+		UPDATE contacts
+		SET engagement_score = ?, last_inbound_at = ?, replied_to_campaign = ?, score_updated_at = ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare engagement update: %v", err)
+	}
+	defer stmt.Close()
+	
+	now := time.Now().Unix()
+	for _, e := range batch {
+		var lastInbound interface{}
+		if e.LastInboundAt != nil {
+			lastInbound = e.LastInboundAt.Unix()
+		}
+		if _, err := stmt.Exec(e.Score, lastInbound, e.RepliedToCampaign, now, e.ContactID); err != nil {
+			return fmt.Errorf("failed to update engagement for contact %d: %v", e.ContactID, err)
+		}
+	}
+	
+	return tx.Commit()
+}
+
+// GetContactIDsByPhones maps E.164 numbers, per owner, to the ID of the
+// owner's contact with that number, stored either in E.164 or as bare digits.
+// Like GetContactByPhone, the oldest contact wins when a user has several with
+// a number; numbers without a contact are left out.
+func (r *ContactRepository) GetContactIDsByPhones(e164s []string) (map[models.ContactPhoneKey]int, error) {
+	ids := make(map[models.ContactPhoneKey]int)
+	if len(e164s) == 0 {
+		return ids, nil
+	}
+	
+	placeholders := make([]string, 0, len(e164s)*2)
+	args := make([]interface{}, 0, len(e164s)*2)
+	for _, e164 := range e164s {
+		placeholders = append(placeholders, "?", "?")
+		args = append(args, e164, strings.TrimPrefix(e164, "+"))
+	}
+	
+	query := "SELECT id, user_id, phone FROM contacts WHERE phone IN (" + strings.Join(placeholders, ", ") + ") ORDER BY id"
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up contacts by phone: %v", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var id int
+		var owner sql.NullInt64
+		var stored string
+		if err := rows.Scan(&id, &owner, &stored); err != nil {
+			return nil, fmt.Errorf("failed to scan contact phone: %v", err)
+		}
+		key := models.ContactPhoneKey{UserID: int(owner.Int64), Phone: "+" + strings.TrimPrefix(stored, "+")}
+		if _, exists := ids[key]; !exists {
+			ids[key] = id
+		}
+	}
+	
+	return ids, rows.Err()
+}
+
+// GetContactsByPhones maps E.164 numbers to the contact of userID with that
+// number, stored either in E.164 or as bare digits. Like GetContactIDsByPhones,
+// the oldest contact wins when the user has several with a number.
+func (r *ContactRepository) GetContactsByPhones(e164s []string, userID int) (map[string]*models.Contact, error) {
+	contacts := make(map[string]*models.Contact)
+	if len(e164s) == 0 {
+		return contacts, nil
+	}
+	
+	placeholders := make([]string, 0, len(e164s)*2)
+	args := make([]interface{}, 0, len(e164s)*2+1)
+	args = append(args, ownerOrNull(userID))
+	for _, e164 := range e164s {
+		placeholders = append(placeholders, "?", "?")
+		args = append(args, e164, strings.TrimPrefix(e164, "+"))
+	}
+	
+	query := `
+		SELECT `+contactColumns+`
+		FROM contacts c
+		LEFT JOIN contact_groups cg ON c.group_id = cg.id
+		WHERE c.user_id ` + r.dialect.nullSafeEqual() + ` ? AND c.phone IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY c.id`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up contacts by phone: %v", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+		e164 := "+" + strings.TrimPrefix(contact.Phone, "+")
+		if _, exists := contacts[e164]; !exists {
+			contacts[e164] = contact
+		}
+	}
+	
+	return contacts, rows.Err()
+}
+
+// CreateContactsBatch inserts a batch of contacts with one statement. Unlike
+// BulkCreateContacts it does not check for duplicates; callers filter them out
+// first.
+func (r *ContactRepository) CreateContactsBatch(contacts []models.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+	
+	now := time.Now().Unix()
+	placeholders := make([]string, 0, len(contacts))
+	args := make([]interface{}, 0, len(contacts)*11)
+	for _, contact := range contacts {
+		tagsJSON, _ := json.Marshal(contact.Tags)
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args,
+			ownerOrNull(contact.UserID),
+			contact.Name,
+			contact.Phone,
+			contact.Email,
+			contact.Company,
+			contact.Position,
+			contact.GroupID,
+			string(tagsJSON),
+			contact.Notes,
+			contact.IsActive,
+			now,
+		)
+	}
+	
+	query := `
+		INSERT INTO contacts (user_id, name, phone, email, company, position, group_id, tags, notes, is_active, created_at)
+		VALUES ` + strings.Join(placeholders, ", ")
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to create contacts: %v", err)
+	}
+	return nil
+}
+
+// UpdateNameAndTagsBatch sets the name and tags of a batch of contacts in one
+// transaction
+func (r *ContactRepository) UpdateNameAndTagsBatch(contacts []models.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+	
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	
+	stmt, err := tx.Prepare("UPDATE contacts SET name = ?, tags = ?, updated_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+	
+	now := time.Now().Unix()
+	for _, contact := range contacts {
+		tagsJSON, _ := json.Marshal(contact.Tags)
+		if _, err := stmt.Exec(contact.Name, string(tagsJSON), now, contact.ID); err != nil {
+			return fmt.Errorf("failed to update contact %d: %v", contact.ID, err)
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// RecordActivityBatch moves last_contact forward and adds to the message
+// counts of a batch of contacts in one transaction. It does not change
+// updated_at, so message traffic never conflicts with edits to the contact.
+func (r *ContactRepository) RecordActivityBatch(batch []models.ContactActivity) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	
+	stmt, err := tx.Prepare(`
+		UPDATE contacts
+		SET last_contact = GREATEST(COALESCE(last_contact, 0), ?),
+		    inbound_count = inbound_count + ?,
+		    outbound_count = outbound_count + ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare activity update: %v", err)
+	}
+	defer stmt.Close()
+	
+	for _, a := range batch {
+		if _, err := stmt.Exec(a.LastContact.Unix(), a.Inbound, a.Outbound, a.ContactID); err != nil {
+			return fmt.Errorf("failed to record activity for contact %d: %v", a.ContactID, err)
+		}
+	}
+	
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// contactSeenBackfillBatch is how many contacts are inserted per backfill statement
+const contactSeenBackfillBatch = 500
+
+// ContactSeenRepository records which contacts have ever written to a session,
+// so the first message of a new contact can be told apart from later ones.
+// Contacts are keyed by E.164 number, or by JID when the number is unknown.
+type ContactSeenRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewContactSeenRepository creates a new contact seen repository
+func NewContactSeenRepository(db *sql.DB) *ContactSeenRepository {
+	return &ContactSeenRepository{db: db, dialect: dialectOf(db)}
+}
+
+// RecordInbound counts an incoming message from a contact. first reports
+// whether the contact had never been seen before.
+func (r *ContactSeenRepository) RecordInbound(sessionID, contact, messageID string, at time.Time) (first bool, err error) {
+	if r.dialect == DialectSQLite {
+		return r.recordInboundSQLite(sessionID, contact, messageID, at)
+	}
+
+	query := `
+		INSERT INTO contact_first_seen (session_id, contact, first_seen_at, first_message_id, message_count)
+		VALUES (?, ?, ?, ?, 1)
+		ON DUPLICATE KEY UPDATE message_count = message_count + 1
+	`
+
+	result, err := r.db.Exec(query, sessionID, contact, at.Unix(), messageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record contact: %v", err)
+	}
+
+	// MySQL reports 1 affected row for an insert and 2 for an update
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record contact: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// recordInboundSQLite is RecordInbound for SQLite, which reports one
+// affected row for both sides of an upsert: the contact is inserted if new,
+// and counted otherwise
+func (r *ContactSeenRepository) recordInboundSQLite(sessionID, contact, messageID string, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO contact_first_seen (session_id, contact, first_seen_at, first_message_id, message_count)
+		VALUES (?, ?, ?, ?, 1)
+	`, sessionID, contact, at.Unix(), messageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record contact: %v", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 1 {
+		return true, nil
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE contact_first_seen SET message_count = message_count + 1 WHERE session_id = ? AND contact = ?
+	`, sessionID, contact)
+	if err != nil {
+		return false, fmt.Errorf("failed to record contact: %v", err)
+	}
+	return false, nil
+}
+
+// IsFirstMessage reports whether the contact has written exactly one message
+// since detection was enabled and was not known before
+func (r *ContactSeenRepository) IsFirstMessage(sessionID, contact string) (bool, error) {
+	query := `
+		SELECT message_count, backfilled
+		FROM contact_first_seen
+		WHERE session_id = ? AND contact = ?
+	`
+
+	var count int
+	var backfilled bool
+	err := r.db.QueryRow(query, sessionID, contact).Scan(&count, &backfilled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check contact: %v", err)
+	}
+
+	return !backfilled && count == 1, nil
+}
+
+// Backfill marks contacts as already known, without counting a message, so
+// enabling detection does not report existing contacts as new. It returns
+// how many contacts were newly recorded.
+func (r *ContactSeenRepository) Backfill(sessionID string, contacts []string) (int, error) {
+	now := time.Now().Unix()
+	recorded := 0
+
+	for start := 0; start < len(contacts); start += contactSeenBackfillBatch {
+		end := start + contactSeenBackfillBatch
+		if end > len(contacts) {
+			end = len(contacts)
+		}
+		batch := contacts[start:end]
+
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*3)
+		for i, contact := range batch {
+			placeholders[i] = "(?, ?, ?, 0, TRUE)"
+			args = append(args, sessionID, contact, now)
+		}
+
+		query := `
+			` + r.dialect.insertIgnore() + ` INTO contact_first_seen (session_id, contact, first_seen_at, message_count, backfilled)
+			VALUES ` + strings.Join(placeholders, ", ")
+
+		result, err := r.db.Exec(query, args...)
+		if err != nil {
+			return recorded, fmt.Errorf("failed to backfill contacts: %v", err)
+		}
+		affected, _ := result.RowsAffected()
+		recorded += int(affected)
+	}
+
+	return recorded, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect identifies the SQL flavour of the application database. Queries
+// are written for MySQL; the few constructs SQLite spells differently go
+// through the helpers below.
+type Dialect string
+
+const (
+	DialectMySQL  Dialect = "mysql"
+	DialectSQLite Dialect = "sqlite3"
+)
+
+// dialectOf tells the dialect of a connection pool from its driver, so
+// repositories built on a *sql.DB need not be told
+func dialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*sqlite3.SQLiteDriver); ok {
+		return DialectSQLite
+	}
+	return DialectMySQL
+}
+
+// ForeignKeyChecks is the statement turning foreign key checks of the
+// current connection on or off
+func (d Dialect) ForeignKeyChecks(enabled bool) string {
+	if d == DialectSQLite {
+		if enabled {
+			return "PRAGMA foreign_keys = ON"
+		}
+		return "PRAGMA foreign_keys = OFF"
+	}
+	if enabled {
+		return "SET FOREIGN_KEY_CHECKS = 1"
+	}
+	return "SET FOREIGN_KEY_CHECKS = 0"
+}
+
+// tableExists reports whether the database has a table of that name
+func (d Dialect) tableExists(db *sql.DB, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	if d == DialectSQLite {
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	}
+
+	var count int
+	if err := db.QueryRow(query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// insertIgnore starts an INSERT that skips rows conflicting with a unique key
+func (d Dialect) insertIgnore() string {
+	if d == DialectSQLite {
+		return "INSERT OR IGNORE"
+	}
+	return "INSERT IGNORE"
+}
+
+// onDuplicateKey starts the update clause of an upsert. SQLite needs the
+// columns of the conflicting unique key; MySQL works them out itself.
+func (d Dialect) onDuplicateKey(keyColumns ...string) string {
+	if d == DialectSQLite {
+		return "ON CONFLICT (" + strings.Join(keyColumns, ", ") + ") DO UPDATE SET"
+	}
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+// inserted refers, in the update clause of an upsert, to the value the
+// insert proposed for column
+func (d Dialect) inserted(column string) string {
+	if d == DialectSQLite {
+		return "excluded." + column
+	}
+	return "VALUES(" + column + ")"
+}
+
+// nullSafeEqual is the comparison operator treating two NULLs as equal
+func (d Dialect) nullSafeEqual() string {
+	if d == DialectSQLite {
+		return "IS"
+	}
+	return "<=>"
+}
+
+// jsonArrayContains tests whether the JSON array in column holds the value
+// given, JSON-encoded, as the query argument
+func (d Dialect) jsonArrayContains(column string) string {
+	if d == DialectSQLite {
+		return "EXISTS (SELECT 1 FROM json_each(" + column + ") WHERE json_each.value = json_extract(?, '$'))"
+	}
+	return "JSON_CONTAINS(" + column + ", ?)"
+}
+
+// timestamp normalizes a TIMESTAMP column for comparison and grouping. SQLite
+// keeps timestamps as text in the time zone they were written in, so they
+// are brought to UTC first.
+func (d Dialect) timestamp(column string) string {
+	if d == DialectSQLite {
+		return "datetime(" + column + ")"
+	}
+	return column
+}
+
+// fromUnixTime converts unix seconds to a timestamp comparable with timestamp()
+func (d Dialect) fromUnixTime(expr string) string {
+	if d == DialectSQLite {
+		return "datetime(" + expr + ", 'unixepoch')"
+	}
+	return "FROM_UNIXTIME(" + expr + ")"
+}
+
+// unixTime converts a timestamp to unix seconds
+func (d Dialect) unixTime(expr string) string {
+	if d == DialectSQLite {
+		return "CAST(strftime('%s', " + expr + ") AS INTEGER)"
+	}
+	return "UNIX_TIMESTAMP(" + expr + ")"
+}
+
+// sqliteTimestampLayouts are the text forms of timestamps in SQLite: as the
+// driver writes them, and as CURRENT_TIMESTAMP and datetime() return them
+var sqliteTimestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// scanTime scans a timestamp that may arrive as text. SQLite drops the
+// column type of expressions such as MAX(created_at), so the driver returns
+// their value unparsed.
+type scanTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner
+func (t *scanTime) Scan(value any) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		*t = scanTime{}
+		return nil
+	case time.Time:
+		*t = scanTime{Time: v, Valid: true}
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into a timestamp", value)
+	}
+
+	for _, layout := range sqliteTimestampLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			*t = scanTime{Time: parsed, Valid: true}
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", text)
+}
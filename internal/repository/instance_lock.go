@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// InstanceLockName is the MySQL named lock held by a running server. Admin
+// tools take the same lock before destructive operations so they never race
+// a live instance. With SQLite it is a file lock on <database>.lock instead.
+const InstanceLockName = "whatsapp-multi-session.instance"
+
+// ErrInstanceLocked is returned when another process holds the instance lock
+var ErrInstanceLocked = fmt.Errorf("instance lock %q is held by another process", InstanceLockName)
+
+// instanceLockPoll is how often a busy SQLite instance lock is retried
+const instanceLockPoll = 100 * time.Millisecond
+
+// InstanceLock is a held MySQL named lock. Named locks belong to a
+// connection, so the lock pins one connection until it is released. For
+// SQLite it is a held file lock instead.
+type InstanceLock struct {
+	conn *sql.Conn
+	file *os.File
+}
+
+// AcquireInstanceLock takes the instance lock, waiting up to timeout for it
+func (d *Database) AcquireInstanceLock(timeout time.Duration) (*InstanceLock, error) {
+	if d.dialect == DialectSQLite {
+		return acquireFileLock(d.name+".lock", timeout)
+	}
+
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection for instance lock: %v", err)
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", InstanceLockName, int(timeout/time.Second)).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire instance lock: %v", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, ErrInstanceLocked
+	}
+
+	return &InstanceLock{conn: conn}, nil
+}
+
+// acquireFileLock takes an exclusive lock on the file at path, creating it
+// if needed. The lock goes away with the process holding it.
+func acquireFileLock(path string, timeout time.Duration) (*InstanceLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open instance lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to acquire instance lock: %v", err)
+		}
+		if locked {
+			return &InstanceLock{file: file}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrInstanceLocked
+		}
+		time.Sleep(instanceLockPoll)
+	}
+}
+
+// Release gives up the instance lock and returns its connection to the pool
+func (l *InstanceLock) Release() error {
+	if l.file != nil {
+		// Closing the file drops its lock
+		return l.file.Close()
+	}
+
+	defer l.conn.Close()
+
+	if _, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", InstanceLockName); err != nil {
+		return fmt.Errorf("failed to release instance lock: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package repository
+
+import "os"
+
+// tryLockFile always succeeds: file locks are only taken on Unix, so
+// elsewhere the SQLite instance lock does not keep processes apart
+func tryLockFile(file *os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// LabelRepository mirrors WhatsApp Business chat labels and their chat assignments
+type LabelRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewLabelRepository creates a new label repository
+func NewLabelRepository(db *sql.DB) *LabelRepository {
+	return &LabelRepository{db: db, dialect: dialectOf(db)}
+}
+
+// UpsertLabel stores a label's current name and color
+func (r *LabelRepository) UpsertLabel(sessionID string, label *models.ChatLabel) error {
+	query := `
+		INSERT INTO chat_labels (session_id, label_id, name, color, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		` + r.dialect.onDuplicateKey("session_id", "label_id") + `
+			name = ` + r.dialect.inserted("name") + `, color = ` + r.dialect.inserted("color") + `,
+			updated_at = ` + r.dialect.inserted("updated_at") + `
+	`
+
+	_, err := r.db.Exec(query, sessionID, label.ID, label.Name, label.Color, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save label: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteLabel removes a label and its chat assignments
+func (r *LabelRepository) DeleteLabel(sessionID, labelID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chat_label_assignments WHERE session_id = ? AND label_id = ?`, sessionID, labelID); err != nil {
+		return fmt.Errorf("failed to delete label assignments: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_labels WHERE session_id = ? AND label_id = ?`, sessionID, labelID); err != nil {
+		return fmt.Errorf("failed to delete label: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetLabels returns all labels of a session ordered by name
+func (r *LabelRepository) GetLabels(sessionID string) ([]*models.ChatLabel, error) {
+	query := `SELECT label_id, name, color FROM chat_labels WHERE session_id = ? ORDER BY name`
+
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query labels: %v", err)
+	}
+	defer rows.Close()
+
+	return scanLabels(rows)
+}
+
+// GetChatLabels returns the labels assigned to a chat
+func (r *LabelRepository) GetChatLabels(sessionID, chatJID string) ([]*models.ChatLabel, error) {
+	query := `
+		SELECT l.label_id, l.name, l.color
+		FROM chat_label_assignments a
+		JOIN chat_labels l ON l.session_id = a.session_id AND l.label_id = a.label_id
+		WHERE a.session_id = ? AND a.chat_jid = ?
+		ORDER BY l.name
+	`
+
+	rows, err := r.db.Query(query, sessionID, chatJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat labels: %v", err)
+	}
+	defer rows.Close()
+
+	return scanLabels(rows)
+}
+
+// GetAssignments returns the label names of every labeled chat of a session, keyed by chat JID
+func (r *LabelRepository) GetAssignments(sessionID string) (map[string][]string, error) {
+	query := `
+		SELECT a.chat_jid, l.name
+		FROM chat_label_assignments a
+		JOIN chat_labels l ON l.session_id = a.session_id AND l.label_id = a.label_id
+		WHERE a.session_id = ?
+		ORDER BY l.name
+	`
+
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query label assignments: %v", err)
+	}
+	defer rows.Close()
+
+	assignments := make(map[string][]string)
+	for rows.Next() {
+		var chatJID, name string
+		if err := rows.Scan(&chatJID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan label assignment: %v", err)
+		}
+		assignments[chatJID] = append(assignments[chatJID], name)
+	}
+
+	return assignments, nil
+}
+
+// SetChatLabel assigns a label to a chat or removes it
+func (r *LabelRepository) SetChatLabel(sessionID, chatJID, labelID string, labeled bool) error {
+	var err error
+	if labeled {
+		_, err = r.db.Exec(`
+			`+r.dialect.insertIgnore()+` INTO chat_label_assignments (session_id, chat_jid, label_id, created_at)
+			VALUES (?, ?, ?, ?)
+		`, sessionID, chatJID, labelID, time.Now().Unix())
+	} else {
+		_, err = r.db.Exec(`
+			DELETE FROM chat_label_assignments WHERE session_id = ? AND chat_jid = ? AND label_id = ?
+		`, sessionID, chatJID, labelID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update chat label: %v", err)
+	}
+
+	return nil
+}
+
+func scanLabels(rows *sql.Rows) ([]*models.ChatLabel, error) {
+	labels := []*models.ChatLabel{}
+	for rows.Next() {
+		label := &models.ChatLabel{}
+		if err := rows.Scan(&label.ID, &label.Name, &label.Color); err != nil {
+			return nil, fmt.Errorf("failed to scan label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
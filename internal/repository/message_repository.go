@@ -2,32 +2,36 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
 type MessageRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 func NewMessageRepository(db *sql.DB) *MessageRepository {
-	return &MessageRepository{db: db}
+	return &MessageRepository{db: db, dialect: dialectOf(db)}
 }
 
 // Message represents a WhatsApp message
 type Message struct {
-	ID           int64     `json:"id"`
-	SessionID    string    `json:"session_id"`
-	MessageID    string    `json:"message_id"`
-	SenderJID    string    `json:"sender_jid"`
-	RecipientJID string    `json:"recipient_jid"`
-	MessageType  string    `json:"message_type"`
-	Content      string    `json:"content"`
-	MediaURL     string    `json:"media_url"`
-	Direction    string    `json:"direction"` // 'sent' or 'received'
-	Status       string    `json:"status"`    // 'pending', 'sent', 'delivered', 'read', 'failed'
-	ErrorMessage string    `json:"error_message"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int64      `json:"id"`
+	SessionID    string     `json:"session_id"`
+	MessageID    string     `json:"message_id"`
+	SenderJID    string     `json:"sender_jid"`
+	RecipientJID string     `json:"recipient_jid"`
+	MessageType  string     `json:"message_type"`
+	Content      string     `json:"content"`
+	MediaURL     string     `json:"media_url"`
+	Direction    string     `json:"direction"` // 'sent' or 'received'
+	Status       string     `json:"status"`    // 'pending', 'sent', 'delivered', 'read', 'played', 'failed', 'probably_blocked', 'revoked', 'deleted'
+	ErrorMessage string     `json:"error_message"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	EditedAt     *time.Time `json:"edited_at,omitempty"` // Set by GetMessageByID and GetMessages once the text was edited
 }
 
 // LogMessage logs a message to the database
@@ -63,18 +67,116 @@ func (r *MessageRepository) LogMessage(message *Message) error {
 	return err
 }
 
-// UpdateMessageStatus updates the status of a message
+// UpdateMessageStatus updates the status of a message. Deleted messages keep
+// their status, so receipts arriving late do not bring them back.
 func (r *MessageRepository) UpdateMessageStatus(messageID, status, errorMessage string) error {
 	query := `
 		UPDATE messages 
 		SET status = ?, error_message = ?, updated_at = ?
-		WHERE message_id = ?
+		WHERE message_id = ? AND (status IS NULL OR status NOT IN ('revoked', 'deleted'))
 	`
 
 	_, err := r.db.Exec(query, status, errorMessage, time.Now(), messageID)
 	return err
 }
 
+// receiptStatuses are the statuses receipts move a sent message through, in order
+var receiptStatuses = []string{"delivered", "read", "played"}
+
+// UpdateMessageReceiptStatus sets the status of a session's message from a
+// receipt. Statuses only move forward, so a delivery receipt arriving after
+// the read receipt does not undo it, and deleted messages keep their status.
+// It reports whether the message changed.
+func (r *MessageRepository) UpdateMessageReceiptStatus(sessionID, messageID, status string) (bool, error) {
+	rank := -1
+	for i, receiptStatus := range receiptStatuses {
+		if receiptStatus == status {
+			rank = i
+		}
+	}
+	if rank < 0 {
+		return false, fmt.Errorf("%q is not a receipt status", status)
+	}
+
+	// The message keeps a status at or past this one
+	kept := append([]string{"revoked", "deleted"}, receiptStatuses[rank:]...)
+	query := `
+		UPDATE messages
+		SET status = ?, updated_at = ?
+		WHERE session_id = ? AND message_id = ? AND (status IS NULL OR status NOT IN (?` + strings.Repeat(", ?", len(kept)-1) + `))
+	`
+	args := []interface{}{status, time.Now(), sessionID, messageID}
+	for _, keptStatus := range kept {
+		args = append(args, keptStatus)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to update status of message %s: %v", messageID, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MarkMessageDeleted sets the status of a session's message to 'revoked'
+// (deleted for everyone) or 'deleted' (deleted for the session only)
+func (r *MessageRepository) MarkMessageDeleted(sessionID, messageID, status string) error {
+	query := `
+		UPDATE messages
+		SET status = ?, updated_at = ?
+		WHERE session_id = ? AND message_id = ?
+	`
+
+	if _, err := r.db.Exec(query, status, time.Now(), sessionID, messageID); err != nil {
+		return fmt.Errorf("failed to mark message %s %s: %v", messageID, status, err)
+	}
+	return nil
+}
+
+// UpdateEditedMessage replaces the text of a session's message after it was
+// edited and records when
+func (r *MessageRepository) UpdateEditedMessage(sessionID, messageID, content string, editedAt time.Time) error {
+	query := `
+		UPDATE messages
+		SET content = ?, edited_at = ?, updated_at = ?
+		WHERE session_id = ? AND message_id = ?
+	`
+
+	if _, err := r.db.Exec(query, content, editedAt, time.Now(), sessionID, messageID); err != nil {
+		return fmt.Errorf("failed to update edited message %s: %v", messageID, err)
+	}
+	return nil
+}
+
+// SetMediaKey records the storage key of the media of a session's message
+func (r *MessageRepository) SetMediaKey(sessionID, messageID, key string) error {
+	query := `UPDATE messages SET media_key = ? WHERE session_id = ? AND message_id = ?`
+
+	if _, err := r.db.Exec(query, key, sessionID, messageID); err != nil {
+		return fmt.Errorf("failed to record media of message %s: %v", messageID, err)
+	}
+	return nil
+}
+
+// GetMediaKey returns the storage key of the media of a session's message.
+// found is false when the message was not logged; the key is empty when it
+// has no stored media.
+func (r *MessageRepository) GetMediaKey(sessionID, messageID string) (key string, found bool, err error) {
+	query := `SELECT COALESCE(media_key, '') FROM messages WHERE session_id = ? AND message_id = ?`
+
+	err = r.db.QueryRow(query, sessionID, messageID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get media of message %s: %v", messageID, err)
+	}
+	return key, true, nil
+}
+
 // GetMessagesBySession gets messages for a specific session
 func (r *MessageRepository) GetMessagesBySession(sessionID string, limit int) ([]*Message, error) {
 	query := `
@@ -105,28 +207,490 @@ func (r *MessageRepository) GetMessagesBySession(sessionID string, limit int) ([
 		if err != nil {
 			continue
 		}
+		msg.CreatedAt = msg.CreatedAt.UTC()
+		msg.UpdatedAt = msg.UpdatedAt.UTC()
 		messages = append(messages, msg)
 	}
 
 	return messages, nil
 }
 
+// GetMessageByID returns a message of the session by its WhatsApp message ID, or nil if it was not logged
+func (r *MessageRepository) GetMessageByID(sessionID, messageID string) (*Message, error) {
+	query := `
+		SELECT id, session_id, COALESCE(message_id, ''), COALESCE(sender_jid, ''), COALESCE(recipient_jid, ''),
+		       message_type, COALESCE(content, ''), COALESCE(media_url, ''), direction, COALESCE(status, ''),
+		       COALESCE(error_message, ''), created_at, updated_at, edited_at
+		FROM messages
+		WHERE session_id = ? AND message_id = ?
+	`
+
+	msg := &Message{}
+	var editedAt sql.NullTime
+	err := r.db.QueryRow(query, sessionID, messageID).Scan(
+		&msg.ID, &msg.SessionID, &msg.MessageID,
+		&msg.SenderJID, &msg.RecipientJID, &msg.MessageType,
+		&msg.Content, &msg.MediaURL, &msg.Direction,
+		&msg.Status, &msg.ErrorMessage, &msg.CreatedAt, &msg.UpdatedAt, &editedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message %s: %v", messageID, err)
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC()
+	msg.UpdatedAt = msg.UpdatedAt.UTC()
+	msg.EditedAt = nullTimeUTC(editedAt)
+	return msg, nil
+}
+
+// MessageExportFilter selects the messages to export; zero fields match everything
+type MessageExportFilter struct {
+	SessionID string    // Messages of this session
+	UserID    int       // Messages of every session owned by the user, used when SessionID is empty
+	Direction string    // "sent" or "received"
+	From      time.Time // Inclusive
+	To        time.Time // Exclusive
+}
+
+// ExportMessages returns up to limit messages matching filter with an ID
+// greater than afterID, in ID order. Callers page through a large export by
+// passing the last ID returned.
+func (r *MessageRepository) ExportMessages(filter MessageExportFilter, afterID int64, limit int) ([]*Message, error) {
+	conditions := []string{"m.id > ?"}
+	args := []interface{}{afterID}
+
+	if filter.SessionID != "" {
+		conditions = append(conditions, "m.session_id = ?")
+		args = append(args, filter.SessionID)
+	} else {
+		conditions = append(conditions, "m.session_id IN (SELECT id FROM session_metadata WHERE user_id = ?)")
+		args = append(args, filter.UserID)
+	}
+	if filter.Direction != "" {
+		conditions = append(conditions, "m.direction = ?")
+		args = append(args, filter.Direction)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "m.created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "m.created_at < ?")
+		args = append(args, filter.To)
+	}
+
+	query := `
+		SELECT m.id, m.session_id, COALESCE(m.message_id, ''), COALESCE(m.sender_jid, ''), COALESCE(m.recipient_jid, ''),
+		       m.message_type, COALESCE(m.content, ''), COALESCE(m.media_url, ''), m.direction, COALESCE(m.status, ''),
+		       COALESCE(m.error_message, ''), m.created_at, m.updated_at
+		FROM messages m
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY m.id
+		LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages for export: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		msg := &Message{}
+		if err := rows.Scan(
+			&msg.ID, &msg.SessionID, &msg.MessageID,
+			&msg.SenderJID, &msg.RecipientJID, &msg.MessageType,
+			&msg.Content, &msg.MediaURL, &msg.Direction,
+			&msg.Status, &msg.ErrorMessage, &msg.CreatedAt, &msg.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan exported message: %v", err)
+		}
+		msg.CreatedAt = msg.CreatedAt.UTC()
+		msg.UpdatedAt = msg.UpdatedAt.UTC()
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// MessageHistoryFilter selects a session's messages; zero fields match everything
+type MessageHistoryFilter struct {
+	SessionID   string
+	Chats       []string  // Accepted spellings of the chat JID, matched against recipient_jid
+	Direction   string    // "sent" or "received"
+	MessageType string
+	From        time.Time // Inclusive
+	To          time.Time // Exclusive
+}
+
+// GetMessages returns up to limit messages matching filter with an ID below
+// beforeID (no bound when 0), newest first, and the number of messages
+// matching filter regardless of beforeID. Paging by ID keeps every page an
+// index range scan, however deep the client pages.
+func (r *MessageRepository) GetMessages(filter MessageHistoryFilter, beforeID int64, limit int) ([]*Message, int64, error) {
+	conditions := []string{"session_id = ?"}
+	args := []interface{}{filter.SessionID}
+
+	if len(filter.Chats) > 0 {
+		conditions = append(conditions, "recipient_jid IN (?"+strings.Repeat(", ?", len(filter.Chats)-1)+")")
+		for _, chat := range filter.Chats {
+			args = append(args, chat)
+		}
+	}
+	if filter.Direction != "" {
+		conditions = append(conditions, "direction = ?")
+		args = append(args, filter.Direction)
+	}
+	if filter.MessageType != "" {
+		conditions = append(conditions, "message_type = ?")
+		args = append(args, filter.MessageType)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM messages WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count messages: %v", err)
+	}
+
+	if beforeID > 0 {
+		where += " AND id < ?"
+		args = append(args, beforeID)
+	}
+	query := `
+		SELECT id, session_id, COALESCE(message_id, ''), COALESCE(sender_jid, ''), COALESCE(recipient_jid, ''),
+		       message_type, COALESCE(content, ''), COALESCE(media_url, ''), direction, COALESCE(status, ''),
+		       COALESCE(error_message, ''), created_at, updated_at, edited_at
+		FROM messages
+		WHERE ` + where + `
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query messages: %v", err)
+	}
+	defer rows.Close()
+
+	messages := []*Message{}
+	for rows.Next() {
+		msg := &Message{}
+		var editedAt sql.NullTime
+		if err := rows.Scan(
+			&msg.ID, &msg.SessionID, &msg.MessageID,
+			&msg.SenderJID, &msg.RecipientJID, &msg.MessageType,
+			&msg.Content, &msg.MediaURL, &msg.Direction,
+			&msg.Status, &msg.ErrorMessage, &msg.CreatedAt, &msg.UpdatedAt, &editedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan message: %v", err)
+		}
+		msg.CreatedAt = msg.CreatedAt.UTC()
+		msg.UpdatedAt = msg.UpdatedAt.UTC()
+		msg.EditedAt = nullTimeUTC(editedAt)
+		messages = append(messages, msg)
+	}
+
+	return messages, total, rows.Err()
+}
+
+// ChatSummary is the latest message of one chat of a session and how many
+// messages were received in it since it was last read
+type ChatSummary struct {
+	ChatJID     string
+	LastMessage *Message
+	UnreadCount int
+}
+
+// GetChatSummaries returns a summary of every chat of the session with logged
+// messages. Unread messages are the received ones logged after the chat was
+// last marked read, or all received ones when it never was.
+func (r *MessageRepository) GetChatSummaries(sessionID string) ([]*ChatSummary, error) {
+	rows, err := r.db.Query(`
+		SELECT m.id, m.session_id, COALESCE(m.message_id, ''), COALESCE(m.sender_jid, ''), COALESCE(m.recipient_jid, ''),
+		       m.message_type, COALESCE(m.content, ''), COALESCE(m.media_url, ''), m.direction, COALESCE(m.status, ''),
+		       COALESCE(m.error_message, ''), m.created_at, m.updated_at
+		FROM (
+			SELECT MAX(id) AS last_id
+			FROM messages
+			WHERE session_id = ? AND recipient_jid IS NOT NULL AND recipient_jid <> ''
+			GROUP BY recipient_jid
+		) latest
+		JOIN messages m ON m.id = latest.last_id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest chat messages: %v", err)
+	}
+	defer rows.Close()
+
+	var summaries []*ChatSummary
+	byChat := make(map[string]*ChatSummary)
+	for rows.Next() {
+		msg := &Message{}
+		if err := rows.Scan(
+			&msg.ID, &msg.SessionID, &msg.MessageID,
+			&msg.SenderJID, &msg.RecipientJID, &msg.MessageType,
+			&msg.Content, &msg.MediaURL, &msg.Direction,
+			&msg.Status, &msg.ErrorMessage, &msg.CreatedAt, &msg.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan latest chat message: %v", err)
+		}
+		msg.CreatedAt = msg.CreatedAt.UTC()
+		msg.UpdatedAt = msg.UpdatedAt.UTC()
+		summary := &ChatSummary{ChatJID: msg.RecipientJID, LastMessage: msg}
+		summaries = append(summaries, summary)
+		byChat[summary.ChatJID] = summary
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	unread, err := r.db.Query(`
+		SELECT m.recipient_jid, COUNT(*)
+		FROM messages m
+		LEFT JOIN chat_state cs ON cs.session_id = m.session_id AND cs.chat_jid = m.recipient_jid
+		WHERE m.session_id = ? AND m.direction = 'received' AND m.id > COALESCE(cs.last_read_id, 0)
+		GROUP BY m.recipient_jid
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread messages: %v", err)
+	}
+	defer unread.Close()
+
+	for unread.Next() {
+		var chatJID string
+		var count int
+		if err := unread.Scan(&chatJID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %v", err)
+		}
+		if summary, ok := byChat[chatJID]; ok {
+			summary.UnreadCount = count
+		}
+	}
+
+	return summaries, unread.Err()
+}
+
+// MarkChatRead records that the chat was read up to its latest logged message
+func (r *MessageRepository) MarkChatRead(sessionID, chatJID string) error {
+	query := `
+		INSERT INTO chat_state (session_id, chat_jid, last_read_id, last_read_at)
+		SELECT ?, ?, COALESCE(MAX(id), 0), ?
+		FROM messages
+		WHERE session_id = ? AND recipient_jid = ?
+		` + r.dialect.onDuplicateKey("session_id", "chat_jid") + `
+			last_read_id = GREATEST(last_read_id, ` + r.dialect.inserted("last_read_id") + `),
+			last_read_at = ` + r.dialect.inserted("last_read_at") + `
+	`
+
+	if _, err := r.db.Exec(query, sessionID, chatJID, time.Now().Unix(), sessionID, chatJID); err != nil {
+		return fmt.Errorf("failed to mark chat %s read: %v", chatJID, err)
+	}
+	return nil
+}
+
+// StatusProbablyBlocked marks a sent message that was acknowledged by the server
+// but never delivered to a recipient who used to receive messages normally
+const StatusProbablyBlocked = "probably_blocked"
+
+// BlockedSuspect is a recipient whose recent messages were classified as probably blocked
+type BlockedSuspect struct {
+	RecipientJID     string    `json:"recipient_jid"`
+	UndeliveredCount int       `json:"undelivered_count"`
+	LastAttemptAt    time.Time `json:"last_attempt_at"`
+}
+
+// MarkProbablyBlocked classifies sent messages as probably blocked when they were
+// only acknowledged by the server before sentBefore, while an earlier message to
+// the same recipient from the same session was delivered. A later delivery
+// receipt overwrites the classification. It returns the number of messages marked.
+func (r *MessageRepository) MarkProbablyBlocked(sentBefore time.Time) (int64, error) {
+	query := `
+		UPDATE messages m
+		JOIN (
+			SELECT session_id, recipient_jid, MIN(created_at) AS first_delivered
+			FROM messages
+			WHERE direction = 'sent' AND status IN ('delivered', 'read', 'played')
+			GROUP BY session_id, recipient_jid
+		) d ON d.session_id = m.session_id AND d.recipient_jid = m.recipient_jid
+		SET m.status = ?, m.updated_at = ?
+		WHERE m.direction = 'sent'
+		  AND m.status = 'sent'
+		  AND m.created_at < ?
+		  AND m.created_at > d.first_delivered
+	`
+	if r.dialect == DialectSQLite {
+		// SQLite joins the table to update with UPDATE ... FROM
+		query = `
+			UPDATE messages AS m
+			SET status = ?, updated_at = ?
+			FROM (
+				SELECT session_id, recipient_jid, MIN(created_at) AS first_delivered
+				FROM messages
+				WHERE direction = 'sent' AND status IN ('delivered', 'read', 'played')
+				GROUP BY session_id, recipient_jid
+			) AS d
+			WHERE d.session_id = m.session_id AND d.recipient_jid = m.recipient_jid
+			  AND m.direction = 'sent'
+			  AND m.status = 'sent'
+			  AND m.created_at < ?
+			  AND m.created_at > d.first_delivered
+		`
+	}
+
+	result, err := r.db.Exec(query, StatusProbablyBlocked, time.Now(), sentBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to classify undelivered messages: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetBlockedSuspects lists the recipients of a session with messages classified as probably blocked
+func (r *MessageRepository) GetBlockedSuspects(sessionID string) ([]BlockedSuspect, error) {
+	query := `
+		SELECT recipient_jid, COUNT(*), MAX(created_at)
+		FROM messages
+		WHERE session_id = ? AND direction = 'sent' AND status = ?
+		GROUP BY recipient_jid
+		ORDER BY MAX(created_at) DESC
+	`
+
+	rows, err := r.db.Query(query, sessionID, StatusProbablyBlocked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocked suspects: %v", err)
+	}
+	defer rows.Close()
+
+	suspects := []BlockedSuspect{}
+	for rows.Next() {
+		var suspect BlockedSuspect
+		var lastAttemptAt scanTime
+		if err := rows.Scan(&suspect.RecipientJID, &suspect.UndeliveredCount, &lastAttemptAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocked suspect: %v", err)
+		}
+		suspect.LastAttemptAt = lastAttemptAt.Time
+		suspect.LastAttemptAt = suspect.LastAttemptAt.UTC()
+		suspects = append(suspects, suspect)
+	}
+
+	return suspects, nil
+}
+
+// GetCorrespondents returns the distinct one-to-one chat partners of a
+// session: senders of messages received outside groups and recipients of
+// sent ones
+func (r *MessageRepository) GetCorrespondents(sessionID string) ([]string, error) {
+	query := `
+		SELECT sender_jid FROM messages
+		WHERE session_id = ? AND direction = 'received' AND recipient_jid NOT LIKE '%@g.us'
+		UNION
+		SELECT recipient_jid FROM messages
+		WHERE session_id = ? AND direction = 'sent' AND recipient_jid NOT LIKE '%@g.us'
+	`
+
+	rows, err := r.db.Query(query, sessionID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query correspondents: %v", err)
+	}
+	defer rows.Close()
+
+	var jids []string
+	for rows.Next() {
+		var jid string
+		if err := rows.Scan(&jid); err != nil {
+			return nil, fmt.Errorf("failed to scan correspondent: %v", err)
+		}
+		jids = append(jids, jid)
+	}
+
+	return jids, rows.Err()
+}
+
+// GetMediaKeys returns the storage keys of the media stored for a session's messages
+func (r *MessageRepository) GetMediaKeys(sessionID string) ([]string, error) {
+	query := `SELECT media_key FROM messages WHERE session_id = ? AND media_key IS NOT NULL AND media_key <> ''`
+
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan media key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteBySession deletes the logged messages of a session
+func (r *MessageRepository) DeleteBySession(sessionID string) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM messages WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages of session %s: %v", sessionID, err)
+	}
+	return result.RowsAffected()
+}
+
+// GetMessageStatuses returns the current status of each of the given message IDs
+func (r *MessageRepository) GetMessageStatuses(messageIDs []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return statuses, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(messageIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+	}
+
+	rows, err := r.db.Query(fmt.Sprintf("SELECT message_id, status FROM messages WHERE message_id IN (%s)", placeholders), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message statuses: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var status sql.NullString
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, fmt.Errorf("failed to scan message status: %v", err)
+		}
+		statuses[id] = status.String
+	}
+
+	return statuses, nil
+}
+
 // ensureMessagesTable creates the messages table if it doesn't exist
 func (r *MessageRepository) ensureMessagesTable() error {
-	// Check if table exists using MySQL INFORMATION_SCHEMA
-	var count int
-	checkQuery := `
-		SELECT COUNT(*) 
-		FROM INFORMATION_SCHEMA.TABLES 
-		WHERE TABLE_SCHEMA = DATABASE() 
-		AND TABLE_NAME = 'messages'
-	`
-	err := r.db.QueryRow(checkQuery).Scan(&count)
+	exists, err := r.dialect.tableExists(r.db, "messages")
 	if err != nil {
 		return err
 	}
 	
-	if count > 0 {
+	// SQLite databases always get the table from their first migration
+	if exists || r.dialect != DialectMySQL {
 		return nil // Table already exists
 	}
 	
@@ -146,16 +710,28 @@ func (r *MessageRepository) ensureMessagesTable() error {
 			error_message TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			edited_at TIMESTAMP NULL DEFAULT NULL,
+			media_key VARCHAR(512) NULL,
 			INDEX idx_session_id (session_id),
 			INDEX idx_sender_jid (sender_jid),
 			INDEX idx_recipient_jid (recipient_jid),
 			INDEX idx_direction (direction),
 			INDEX idx_status (status),
 			INDEX idx_created_at (created_at),
+			INDEX idx_session_history (session_id, id),
+			INDEX idx_session_chat_history (session_id, recipient_jid, id),
 			FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
 	`
 	
 	_, err = r.db.Exec(createQuery)
 	return err
-}
\ No newline at end of file
+}
+// nullTimeUTC returns a nullable timestamp column as a UTC time, or nil when NULL
+func nullTimeUTC(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Schema changes ship as numbered SQL files in migrations/<dialect>, named
+// NNNN_description.sql. Each is applied once, in order, and recorded in the
+// schema_migrations table. SQLite applies a migration and its record in one
+// transaction.
+//
+// A migration may come with NNNN_description.down.sql undoing it, which
+// Rollback runs to step back during development. Rolling back a migration
+// without one fails. The MySQL migrations before 21 and migration 23, which
+// moves webhook_events into the delivery queue, have none, so a MySQL
+// database cannot be rolled back past them.
+//
+// MySQL cannot roll back DDL, so a migration interrupted halfway is run again
+// from the start. Statements that find their change already in place
+// (duplicate column, index or table) are therefore skipped, which also lets
+// databases created before versioned migrations adopt them. Keep migrations
+// safe to re-run: prefer ADD COLUMN and CREATE INDEX, and make data updates
+// idempotent.
+//
+// Every migration after 27 ships for both dialects under the same version.
+// SQLite databases start at version 27, which creates the schema of that
+// version in one go.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockName is the MySQL named lock held while migrating, so
+// replicas starting together do not apply the same migration twice
+const migrationLockName = "whatsapp-multi-session.migrations"
+
+// migrationLockWait is how long to wait for another process to finish migrating
+const migrationLockWait = 2 * time.Minute
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	sql     string
+	down    string // Script undoing the migration; empty if it has none
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	Reversible bool       `json:"reversible"` // Whether it has a down migration
+}
+
+// downSuffix ends the file name of a down migration
+const downSuffix = ".down.sql"
+
+// loadMigrations reads the embedded migrations of a dialect, ordered by version
+func loadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %s", dialect)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	downs := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		if base, ok := strings.CutSuffix(entry.Name(), downSuffix); ok {
+			content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			downs[base] = string(content)
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_description.sql", entry.Name())
+		}
+		if other, duplicate := seen[version]; duplicate {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, sql: string(content)})
+	}
+
+	for i, migration := range migrations {
+		base := fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+		if down, ok := downs[base]; ok {
+			migrations[i].down = down
+			delete(downs, base)
+		}
+	}
+	for base := range downs {
+		return nil, fmt.Errorf("down migration %s%s has no migration %s.sql", base, downSuffix, base)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies all pending migrations and returns the ones it applied
+func (d *Database) Migrate() ([]Migration, error) {
+	migrations, err := loadMigrations(d.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	unlock, err := d.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := d.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		if err := d.applyMigration(ctx, migration); err != nil {
+			return ran, fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// MigrationStatus lists every known migration and when it was applied
+func (d *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(d.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name, Reversible: migration.down != ""}
+		if at, ok := applied[migration.Version]; ok {
+			appliedAt := at
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// RollbackFloor returns the newest applied migration of statuses without a
+// down migration, which Rollback cannot go past, or nil if there is none
+func RollbackFloor(statuses []MigrationStatus) *MigrationStatus {
+	var floor *MigrationStatus
+	for i, status := range statuses {
+		if status.AppliedAt != nil && !status.Reversible && (floor == nil || status.Version > floor.Version) {
+			floor = &statuses[i]
+		}
+	}
+	return floor
+}
+
+func (d *Database) createMigrationsTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at BIGINT NOT NULL
+		)`
+	if d.dialect == DialectMySQL {
+		query += " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+	}
+
+	_, err := d.db.Exec(query)
+	return err
+}
+
+// appliedMigrations returns the applied migration versions with their time of application
+func (d *Database) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := d.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+		}
+		applied[version] = time.Unix(appliedAt, 0)
+	}
+	return applied, rows.Err()
+}
+
+// Rollback undoes the last steps applied migrations, newest first, and
+// returns the ones it rolled back. It is meant for development: down
+// migrations drop what their migration added, data included. If one of them
+// has no down migration, nothing is rolled back and the error tells how many
+// steps are possible.
+func (d *Database) Rollback(steps int) ([]Migration, error) {
+	migrations, err := loadMigrations(d.dialect)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	ctx := context.Background()
+	unlock, err := d.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := d.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	for i, version := range versions {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %04d is unknown to this build", version)
+		}
+		if migration.down == "" {
+			return nil, fmt.Errorf("cannot roll back %d migration(s): migration %04d_%s has no down migration, so at most %d can be rolled back",
+				len(versions), migration.Version, migration.Name, i)
+		}
+	}
+
+	var rolledBack []Migration
+	for _, version := range versions {
+		migration := byVersion[version]
+		err := d.runMigration(ctx, migration.down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return rolledBack, fmt.Errorf("rolling back migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		rolledBack = append(rolledBack, migration)
+	}
+	return rolledBack, nil
+}
+
+// applyMigration runs the statements of a migration and records it
+func (d *Database) applyMigration(ctx context.Context, migration Migration) error {
+	return d.runMigration(ctx, migration.sql, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now().Unix())
+}
+
+// runMigration runs the statements of a script, then the record statement
+// updating schema_migrations. SQLite runs them in one transaction; MySQL
+// commits DDL as it goes, so there a failed script is left partly applied.
+func (d *Database) runMigration(ctx context.Context, script, record string, args ...any) error {
+	type execer interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}
+	var exec execer = d.db
+	var tx *sql.Tx
+	if d.dialect == DialectSQLite {
+		var err error
+		if tx, err = d.db.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		exec = tx
+	}
+
+	for _, statement := range splitStatements(script) {
+		if _, err := exec.ExecContext(ctx, statement); err != nil {
+			if d.alreadyApplied(err) {
+				continue
+			}
+			return err
+		}
+	}
+	if _, err := exec.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// alreadyApplied reports whether a statement failed only because its change is already in place
+func (d *Database) alreadyApplied(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1050, // ER_TABLE_EXISTS_ERROR
+			1060, // ER_DUP_FIELDNAME
+			1061, // ER_DUP_KEYNAME
+			1091: // ER_CANT_DROP_FIELD_OR_KEY, rerunning an interrupted down migration
+			return true
+		}
+		return false
+	}
+
+	message := err.Error()
+	return strings.Contains(message, "duplicate column name") || strings.Contains(message, "already exists")
+}
+
+// lockMigrations makes concurrent migration runs wait for each other
+func (d *Database) lockMigrations(ctx context.Context) (func(), error) {
+	if d.dialect != DialectMySQL {
+		// SQLite serializes writers on the database file itself
+		return func() {}, nil
+	}
+
+	// Named locks belong to a connection, so pin one until the lock is released
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection for migration lock: %v", err)
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(migrationLockWait/time.Second)).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("timed out after %s waiting for another process to finish migrating", migrationLockWait)
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLockName)
+		conn.Close()
+	}, nil
+}
+
+// splitStatements splits a migration into statements at semicolons ending a
+// line, dropping comment lines
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, statement)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
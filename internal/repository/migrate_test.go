@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqliteBaselineVersion is the migration SQLite databases start from
+const sqliteBaselineVersion = 27
+
+// newTestSQLite opens an empty SQLite database removed when the test ends
+func newTestSQLite(t *testing.T) *Database {
+	t.Helper()
+	d, err := NewDatabase(DatabaseConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// migrateTo applies the migrations up to and including version
+func migrateTo(t *testing.T, d *Database, version int) {
+	t.Helper()
+	migrations, err := loadMigrations(d.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.createMigrationsTable(); err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+		if err := d.applyMigration(context.Background(), migration); err != nil {
+			t.Fatalf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+	}
+}
+
+// sqliteSchema describes every table of a SQLite database, its columns,
+// indexes and foreign keys, one line each, keyed by table
+func sqliteSchema(t *testing.T, d *Database) map[string][]string {
+	t.Helper()
+	tables, err := queryStrings(d, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		var lines []string
+		columns, err := queryRows(d, fmt.Sprintf("PRAGMA table_info(%q)", table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range columns {
+			// cid, name, type, notnull, dflt_value, pk
+			lines = append(lines, "column "+strings.Join(column[1:], " "))
+		}
+
+		indexes, err := queryRows(d, fmt.Sprintf("PRAGMA index_list(%q)", table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, index := range indexes {
+			// seq, name, unique, origin, partial
+			indexColumns, err := queryStrings(d, fmt.Sprintf("SELECT name FROM pragma_index_info(%q) ORDER BY seqno", index[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, fmt.Sprintf("index %s unique=%s origin=%s (%s)", index[1], index[2], index[3], strings.Join(indexColumns, ", ")))
+		}
+
+		foreignKeys, err := queryRows(d, fmt.Sprintf("PRAGMA foreign_key_list(%q)", table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, foreignKey := range foreignKeys {
+			// id, seq, table, from, to, on_update, on_delete, match
+			lines = append(lines, "foreign key "+strings.Join(foreignKey[2:], " "))
+		}
+
+		sort.Strings(lines)
+		schema[table] = lines
+	}
+	return schema
+}
+
+// queryRows returns every row of a query with its values as strings
+func queryRows(d *Database, query string) ([][]string, error) {
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result [][]string
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(columns))
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				row[i] = "NULL"
+			case []byte:
+				row[i] = string(v)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// queryStrings returns the first column of every row of a query
+func queryStrings(d *Database, query string) ([]string, error) {
+	rows, err := queryRows(d, query)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = row[0]
+	}
+	return values, nil
+}
+
+// compareSchemas fails the test for every difference between two schemas
+func compareSchemas(t *testing.T, wantName string, want map[string][]string, gotName string, got map[string][]string) {
+	t.Helper()
+	for table, wantLines := range want {
+		gotLines, ok := got[table]
+		if !ok {
+			t.Errorf("table %s is missing from the %s database", table, gotName)
+			continue
+		}
+		if strings.Join(wantLines, "\n") != strings.Join(gotLines, "\n") {
+			t.Errorf("table %s differs:\n%s:\n  %s\n%s:\n  %s", table,
+				wantName, strings.Join(wantLines, "\n  "), gotName, strings.Join(gotLines, "\n  "))
+		}
+	}
+	for table := range got {
+		if _, ok := want[table]; !ok {
+			t.Errorf("table %s is only in the %s database", table, gotName)
+		}
+	}
+}
+
+// A database created at the baseline and holding data ends up with the
+// schema of a fresh one once upgraded, its data intact
+func TestMigrateUpgradedMatchesFresh(t *testing.T) {
+	fresh := newTestSQLite(t)
+	if err := fresh.InitTables(); err != nil {
+		t.Fatalf("migrating a fresh database failed: %v", err)
+	}
+
+	upgraded := newTestSQLite(t)
+	migrateTo(t, upgraded, sqliteBaselineVersion)
+	if _, err := upgraded.db.Exec(`INSERT INTO users (id, username, password_hash, role, session_limit, is_active, created_at, tokens_invalidated_at)
+		VALUES (1, 'alice', 'x', 'user', 5, 1, 1700000000, 1700000100)`); err != nil {
+		t.Fatalf("failed to add baseline user: %v", err)
+	}
+	if _, err := upgraded.db.Exec(`INSERT INTO session_metadata (id, phone, actual_phone, name, webhook_url, user_id, created_at, enabled)
+		VALUES ('s1', '628123456789', '', 'Shop', 'https://example.com/hook', 1, 1700000000, 1)`); err != nil {
+		t.Fatalf("failed to add baseline session: %v", err)
+	}
+	ran, err := upgraded.Migrate()
+	if err != nil {
+		t.Fatalf("upgrading the baseline database failed: %v", err)
+	}
+	if len(ran) == 0 || ran[0].Version != sqliteBaselineVersion+1 {
+		t.Errorf("upgrade applied %d migrations starting at %v, want the ones after %d", len(ran), ran, sqliteBaselineVersion)
+	}
+
+	compareSchemas(t, "fresh", sqliteSchema(t, fresh), "upgraded", sqliteSchema(t, upgraded))
+
+	var name, webhookURL string
+	if err := upgraded.db.QueryRow(`SELECT name, webhook_url FROM session_metadata WHERE id = 's1'`).Scan(&name, &webhookURL); err != nil {
+		t.Fatalf("baseline session is gone: %v", err)
+	}
+	if name != "Shop" || webhookURL != "https://example.com/hook" {
+		t.Errorf("baseline session = %q, %q after upgrading", name, webhookURL)
+	}
+	var invalidatedAt int64
+	if err := upgraded.db.QueryRow(`SELECT tokens_invalidated_at FROM users WHERE id = 1`).Scan(&invalidatedAt); err != nil {
+		t.Fatalf("baseline user is gone: %v", err)
+	}
+	if invalidatedAt != 1700000100*1000000 {
+		t.Errorf("tokens_invalidated_at = %d after upgrading, want it in microseconds", invalidatedAt)
+	}
+}
+
+// Rolling a database back to the baseline and migrating it again gives the
+// fresh schema, so every down migration undoes its migration
+func TestMigrateRollbackRoundTrip(t *testing.T) {
+	fresh := newTestSQLite(t)
+	if err := fresh.InitTables(); err != nil {
+		t.Fatalf("migrating a fresh database failed: %v", err)
+	}
+	want := sqliteSchema(t, fresh)
+
+	migrations, err := loadMigrations(DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for _, migration := range migrations {
+		if migration.Version > sqliteBaselineVersion {
+			steps++
+		}
+	}
+
+	roundTrip := newTestSQLite(t)
+	if err := roundTrip.InitTables(); err != nil {
+		t.Fatalf("migrating the database failed: %v", err)
+	}
+	rolledBack, err := roundTrip.Rollback(steps)
+	if err != nil {
+		t.Fatalf("rolling back failed: %v", err)
+	}
+	if len(rolledBack) != steps {
+		t.Fatalf("rolled back %d migrations, want %d", len(rolledBack), steps)
+	}
+
+	baseline := newTestSQLite(t)
+	migrateTo(t, baseline, sqliteBaselineVersion)
+	compareSchemas(t, "baseline", sqliteSchema(t, baseline), "rolled back", sqliteSchema(t, roundTrip))
+
+	if _, err := roundTrip.Migrate(); err != nil {
+		t.Fatalf("migrating again failed: %v", err)
+	}
+	compareSchemas(t, "fresh", want, "migrated again", sqliteSchema(t, roundTrip))
+}
+
+// A MySQL rollback reaching a migration without a down file fails before
+// undoing anything, and status reports where rollbacks stop
+func TestMigrateRollbackStopsAtIrreversible(t *testing.T) {
+	d := newTestMySQL(t)
+	before, err := d.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	floor := RollbackFloor(before)
+	if floor == nil || floor.Version != 23 {
+		t.Fatalf("rollback floor = %+v, want migration 23", floor)
+	}
+
+	latest := before[len(before)-1].Version
+	rolledBack, err := d.Rollback(latest)
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("at most %d can be rolled back", latest-floor.Version)) {
+		t.Errorf("rolling back every migration = %v, want an error naming %d possible steps", err, latest-floor.Version)
+	}
+	if len(rolledBack) != 0 {
+		t.Errorf("rolled back %d migrations before failing", len(rolledBack))
+	}
+	after, err := d.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range after {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %04d_%s is no longer applied", status.Version, status.Name)
+		}
+		if status.Reversible != before[i].Reversible {
+			t.Errorf("migration %04d_%s reversible changed", status.Version, status.Name)
+		}
+	}
+}
+
+func TestRollbackFloor(t *testing.T) {
+	applied := new(time.Time)
+	statuses := []MigrationStatus{
+		{Version: 1, AppliedAt: applied},
+		{Version: 2, AppliedAt: applied, Reversible: true},
+		{Version: 3, AppliedAt: applied},
+		{Version: 4, AppliedAt: applied, Reversible: true},
+		{Version: 5},
+	}
+	if floor := RollbackFloor(statuses); floor == nil || floor.Version != 3 {
+		t.Errorf("RollbackFloor() = %+v, want migration 3", floor)
+	}
+	if floor := RollbackFloor(statuses[3:]); floor != nil {
+		t.Errorf("RollbackFloor() of reversible and pending migrations = %+v, want none", floor)
+	}
+
+	// Every MySQL migration from 24 on has a down file
+	migrations, err := loadMigrations(DialectMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysql := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		mysql[i] = MigrationStatus{Version: migration.Version, AppliedAt: applied, Reversible: migration.down != ""}
+	}
+	if floor := RollbackFloor(mysql); floor == nil || floor.Version != 23 {
+		t.Errorf("MySQL rollback floor = %+v, want migration 23", floor)
+	}
+}
+
+// Migrating an up to date database changes nothing
+func TestMigrateIsIdempotent(t *testing.T) {
+	d := newTestSQLite(t)
+	if err := d.InitTables(); err != nil {
+		t.Fatalf("migrating failed: %v", err)
+	}
+	before := sqliteSchema(t, d)
+
+	ran, err := d.Migrate()
+	if err != nil {
+		t.Fatalf("migrating again failed: %v", err)
+	}
+	if len(ran) != 0 {
+		t.Errorf("migrating again applied %d migrations", len(ran))
+	}
+	compareSchemas(t, "first run", before, "second run", sqliteSchema(t, d))
+
+	statuses, err := d.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %04d_%s is not recorded as applied", status.Version, status.Name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []Dialect{DialectMySQL, DialectSQLite} {
+		migrations, err := loadMigrations(dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", dialect, err)
+		}
+		for i := 1; i < len(migrations); i++ {
+			if migrations[i].Version != migrations[i-1].Version+1 {
+				t.Errorf("%s: migration %d follows %d", dialect, migrations[i].Version, migrations[i-1].Version)
+			}
+		}
+	}
+
+	// Every migration after the SQLite baseline ships for both dialects
+	mysqlMigrations, _ := loadMigrations(DialectMySQL)
+	sqliteMigrations, _ := loadMigrations(DialectSQLite)
+	names := make(map[int]string)
+	for _, migration := range mysqlMigrations {
+		names[migration.Version] = migration.Name
+	}
+	for _, migration := range sqliteMigrations {
+		if migration.Version == sqliteBaselineVersion {
+			continue
+		}
+		if names[migration.Version] != migration.Name {
+			t.Errorf("SQLite migration %04d_%s has no MySQL counterpart", migration.Version, migration.Name)
+		}
+	}
+	if last := sqliteMigrations[len(sqliteMigrations)-1].Version; last != mysqlMigrations[len(mysqlMigrations)-1].Version {
+		t.Errorf("latest SQLite migration is %d, latest MySQL migration %d", last, mysqlMigrations[len(mysqlMigrations)-1].Version)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+	id INT -- trailing
+);
+
+-- another
+ALTER TABLE a ADD COLUMN b INT;
+UPDATE a SET b = 1`
+	got := splitStatements(script)
+	want := []string{"CREATE TABLE a (\n\tid INT -- trailing\n)", "ALTER TABLE a ADD COLUMN b INT", "UPDATE a SET b = 1"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+-- Schema as it was before versioned migrations. Tables are created only if
+-- missing, so databases set up by earlier releases are left as they are.
+-- Columns added since then follow in later migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(255) UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	role VARCHAR(50) NOT NULL DEFAULT 'user',
+	session_limit INT NOT NULL DEFAULT 5,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS session_metadata (
+	id VARCHAR(255) PRIMARY KEY,
+	phone VARCHAR(50) NOT NULL,
+	actual_phone VARCHAR(50),
+	name VARCHAR(255),
+	position INT DEFAULT 0,
+	webhook_url TEXT,
+	auto_reply_text TEXT,
+	proxy_enabled BOOLEAN DEFAULT FALSE,
+	proxy_type VARCHAR(10) DEFAULT '',
+	proxy_host VARCHAR(255) DEFAULT '',
+	proxy_port INT DEFAULT 0,
+	proxy_username VARCHAR(255) DEFAULT '',
+	proxy_password VARCHAR(255) DEFAULT '',
+	user_id INT NOT NULL DEFAULT 1,
+	created_at BIGINT NOT NULL,
+	INDEX idx_phone (phone),
+	INDEX idx_user_id (user_id),
+	INDEX idx_created_at (created_at),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS messages (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	session_id VARCHAR(255) NOT NULL,
+	message_id VARCHAR(255) UNIQUE,
+	sender_jid VARCHAR(100),
+	recipient_jid VARCHAR(100),
+	message_type VARCHAR(50) NOT NULL DEFAULT 'text',
+	content TEXT,
+	media_url TEXT,
+	direction VARCHAR(20) NOT NULL,
+	status VARCHAR(50),
+	error_message TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX idx_session_id (session_id),
+	INDEX idx_sender_jid (sender_jid),
+	INDEX idx_recipient_jid (recipient_jid),
+	INDEX idx_direction (direction),
+	INDEX idx_status (status),
+	INDEX idx_created_at (created_at),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS logs (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	level VARCHAR(10) NOT NULL,
+	message TEXT NOT NULL,
+	component VARCHAR(100),
+	session_id VARCHAR(255),
+	user_id INT,
+	metadata JSON,
+	created_at BIGINT NOT NULL,
+	INDEX idx_level (level),
+	INDEX idx_component (component),
+	INDEX idx_session_id (session_id),
+	INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS contact_groups (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	color VARCHAR(7),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	INDEX idx_name (name),
+	INDEX idx_is_active (is_active)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS contacts (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	phone VARCHAR(50) NOT NULL,
+	email VARCHAR(255),
+	company VARCHAR(255),
+	position VARCHAR(255),
+	group_id INT,
+	tags JSON,
+	notes TEXT,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	last_contact BIGINT,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	FOREIGN KEY (group_id) REFERENCES contact_groups(id) ON DELETE SET NULL,
+	INDEX idx_phone (phone),
+	INDEX idx_name (name),
+	INDEX idx_group_id (group_id),
+	INDEX idx_is_active (is_active),
+	INDEX idx_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS message_templates (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	content TEXT NOT NULL,
+	type VARCHAR(50) NOT NULL DEFAULT 'text',
+	variables JSON,
+	media_url TEXT,
+	media_type VARCHAR(50),
+	category VARCHAR(100),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	usage_count INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	INDEX idx_name (name),
+	INDEX idx_type (type),
+	INDEX idx_category (category),
+	INDEX idx_is_active (is_active)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS campaigns (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	template_id INT NOT NULL,
+	group_id INT,
+	contact_ids JSON,
+	session_id VARCHAR(255) NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'draft',
+	delay_between INT NOT NULL DEFAULT 1,
+	random_delay BOOLEAN NOT NULL DEFAULT FALSE,
+	scheduled_at BIGINT,
+	started_at BIGINT,
+	completed_at BIGINT,
+	total_contacts INT NOT NULL DEFAULT 0,
+	sent_count INT NOT NULL DEFAULT 0,
+	failed_count INT NOT NULL DEFAULT 0,
+	pending_count INT NOT NULL DEFAULT 0,
+	variables JSON,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	FOREIGN KEY (template_id) REFERENCES message_templates(id) ON DELETE CASCADE,
+	FOREIGN KEY (group_id) REFERENCES contact_groups(id) ON DELETE SET NULL,
+	INDEX idx_name (name),
+	INDEX idx_status (status),
+	INDEX idx_session_id (session_id),
+	INDEX idx_template_id (template_id),
+	INDEX idx_group_id (group_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS campaign_messages (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	campaign_id INT NOT NULL,
+	contact_id INT NOT NULL,
+	content TEXT NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'pending',
+	error_msg TEXT,
+	message_id VARCHAR(255),
+	sent_at BIGINT,
+	created_at BIGINT NOT NULL,
+	FOREIGN KEY (campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+	FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE CASCADE,
+	INDEX idx_campaign_id (campaign_id),
+	INDEX idx_contact_id (contact_id),
+	INDEX idx_status (status),
+	INDEX idx_sent_at (sent_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS auto_replies (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	session_id VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	trigger_type VARCHAR(50) NOT NULL,
+	keywords JSON,
+	response TEXT NOT NULL,
+	media_url TEXT,
+	media_type VARCHAR(50),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	priority INT NOT NULL DEFAULT 0,
+	delay_min INT NOT NULL DEFAULT 0,
+	delay_max INT NOT NULL DEFAULT 0,
+	max_replies INT NOT NULL DEFAULT 0,
+	time_start VARCHAR(5),
+	time_end VARCHAR(5),
+	conditions JSON,
+	usage_count INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	INDEX idx_session_id (session_id),
+	INDEX idx_trigger_type (trigger_type),
+	INDEX idx_is_active (is_active),
+	INDEX idx_priority (priority)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS auto_reply_logs (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	auto_reply_id INT NOT NULL,
+	session_id VARCHAR(255) NOT NULL,
+	contact_phone VARCHAR(50) NOT NULL,
+	trigger_msg TEXT NOT NULL,
+	response TEXT NOT NULL,
+	success BOOLEAN NOT NULL DEFAULT TRUE,
+	error_msg TEXT,
+	created_at BIGINT NOT NULL,
+	FOREIGN KEY (auto_reply_id) REFERENCES auto_replies(id) ON DELETE CASCADE,
+	INDEX idx_auto_reply_id (auto_reply_id),
+	INDEX idx_session_id (session_id),
+	INDEX idx_contact_phone (contact_phone),
+	INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	session_id VARCHAR(255) NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	payload JSON NOT NULL,
+	created_at BIGINT NOT NULL,
+	delivered_at BIGINT NULL,
+	INDEX idx_session_pending (session_id, delivered_at, id),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS chat_labels (
+	session_id VARCHAR(255) NOT NULL,
+	label_id VARCHAR(64) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	color INT NOT NULL DEFAULT 0,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, label_id),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS chat_label_assignments (
+	session_id VARCHAR(255) NOT NULL,
+	chat_jid VARCHAR(255) NOT NULL,
+	label_id VARCHAR(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, chat_jid, label_id),
+	INDEX idx_session_label (session_id, label_id),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS contact_first_seen (
+	session_id VARCHAR(255) NOT NULL,
+	contact VARCHAR(255) NOT NULL,
+	first_seen_at BIGINT NOT NULL,
+	first_message_id VARCHAR(255) NULL,
+	message_count INT NOT NULL DEFAULT 0,
+	backfilled BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (session_id, contact),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
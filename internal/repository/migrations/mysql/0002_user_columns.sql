@@ -0,0 +1,6 @@
+-- User columns previously added by migrateUsersTable
+
+ALTER TABLE users ADD COLUMN api_key VARCHAR(64) UNIQUE NULL;
+ALTER TABLE users ADD COLUMN default_region VARCHAR(2) NULL;
+ALTER TABLE users ADD COLUMN segment_engaged_min_score INT NULL;
+ALTER TABLE users ADD COLUMN segment_dormant_days INT NULL;
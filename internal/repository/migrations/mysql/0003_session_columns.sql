@@ -0,0 +1,13 @@
+-- Session columns previously added by migrateSessionsTable
+
+ALTER TABLE session_metadata ADD COLUMN enabled BOOLEAN DEFAULT TRUE;
+UPDATE session_metadata SET enabled = TRUE WHERE enabled IS NULL;
+ALTER TABLE session_metadata ADD COLUMN webhook_proxy_url TEXT;
+ALTER TABLE session_metadata ADD COLUMN webhook_legacy_format BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE session_metadata ADD COLUMN webhook_suspended_at BIGINT NULL;
+ALTER TABLE session_metadata ADD COLUMN banned_until BIGINT NULL;
+ALTER TABLE session_metadata ADD COLUMN ban_reason VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE session_metadata ADD COLUMN send_defaults JSON NULL;
+ALTER TABLE session_metadata ADD COLUMN new_contact_since BIGINT NULL;
+ALTER TABLE session_metadata ADD COLUMN new_contact_create_contact BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE session_metadata ADD COLUMN updated_at BIGINT NULL;
@@ -0,0 +1,10 @@
+-- Contact engagement scoring, previously added by migrateContactsTable. The
+-- indexes were only created for new tables before, so older databases get
+-- them here too.
+
+ALTER TABLE contacts ADD COLUMN engagement_score INT NOT NULL DEFAULT 0;
+ALTER TABLE contacts ADD COLUMN last_inbound_at BIGINT NULL;
+ALTER TABLE contacts ADD COLUMN replied_to_campaign BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE contacts ADD COLUMN score_updated_at BIGINT NULL;
+CREATE INDEX idx_engagement_score ON contacts (engagement_score);
+CREATE INDEX idx_last_inbound_at ON contacts (last_inbound_at);
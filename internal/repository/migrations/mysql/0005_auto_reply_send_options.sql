@@ -0,0 +1,3 @@
+-- Per-rule send options for auto-replies
+
+ALTER TABLE auto_replies ADD COLUMN send_options JSON NULL;
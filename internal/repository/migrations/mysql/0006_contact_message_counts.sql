@@ -0,0 +1,6 @@
+-- Messages exchanged with each CRM contact, maintained from the message
+-- pipeline together with last_contact.
+
+ALTER TABLE contacts ADD COLUMN inbound_count INT NOT NULL DEFAULT 0;
+ALTER TABLE contacts ADD COLUMN outbound_count INT NOT NULL DEFAULT 0;
+CREATE INDEX idx_last_contact ON contacts (last_contact);
@@ -0,0 +1,4 @@
+-- Sandbox sessions simulate WhatsApp for development and are left out of
+-- session limits and analytics.
+
+ALTER TABLE session_metadata ADD COLUMN sandbox BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Secret used to sign a session's webhook requests; empty sends them unsigned.
+
+ALTER TABLE session_metadata ADD COLUMN webhook_secret VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Message history pages a session's messages, optionally of one chat, by
+-- descending ID; these indexes keep every page a range scan.
+
+CREATE INDEX idx_session_history ON messages (session_id, id);
+CREATE INDEX idx_session_chat_history ON messages (session_id, recipient_jid, id);
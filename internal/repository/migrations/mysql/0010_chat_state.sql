@@ -0,0 +1,11 @@
+-- How far each chat of a session was read; received messages logged after
+-- last_read_id are unread.
+
+CREATE TABLE IF NOT EXISTS chat_state (
+	session_id VARCHAR(255) NOT NULL,
+	chat_jid VARCHAR(100) NOT NULL,
+	last_read_id INT NOT NULL DEFAULT 0,
+	last_read_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, chat_jid),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+-- Sent text messages can be edited for a short while; the log keeps the
+-- latest text and when it was edited.
+
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMP NULL DEFAULT NULL;
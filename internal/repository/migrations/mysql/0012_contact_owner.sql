@@ -0,0 +1,10 @@
+-- Contacts and contact groups belong to the user who created them. Rows
+-- created before they had an owner are given to the first admin, who can
+-- still see every user's contacts.
+
+ALTER TABLE contact_groups ADD COLUMN user_id INT NULL;
+ALTER TABLE contacts ADD COLUMN user_id INT NULL;
+CREATE INDEX idx_user_id ON contact_groups (user_id);
+CREATE INDEX idx_user_id ON contacts (user_id);
+UPDATE contact_groups SET user_id = (SELECT id FROM users WHERE role = 'admin' ORDER BY id LIMIT 1) WHERE user_id IS NULL;
+UPDATE contacts SET user_id = (SELECT id FROM users WHERE role = 'admin' ORDER BY id LIMIT 1) WHERE user_id IS NULL;
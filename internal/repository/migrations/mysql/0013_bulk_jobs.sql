@@ -0,0 +1,18 @@
+-- Bulk messaging jobs are stored as campaigns so they survive restarts. A job
+-- sending a plain message has no template, and its inline recipients are no
+-- stored contacts, so campaign messages keep the number and name they were
+-- sent to and their position in the job.
+
+ALTER TABLE campaigns MODIFY COLUMN template_id INT NULL;
+ALTER TABLE campaigns ADD COLUMN job_id VARCHAR(64) NULL;
+ALTER TABLE campaigns ADD COLUMN message TEXT NULL;
+ALTER TABLE campaigns ADD COLUMN send_options JSON NULL;
+ALTER TABLE campaigns ADD COLUMN invalid_recipients JSON NULL;
+ALTER TABLE campaigns ADD COLUMN next_index INT NOT NULL DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN paused_reason VARCHAR(50) NULL;
+CREATE UNIQUE INDEX idx_job_id ON campaigns (job_id);
+ALTER TABLE campaign_messages MODIFY COLUMN contact_id INT NULL;
+ALTER TABLE campaign_messages ADD COLUMN position INT NOT NULL DEFAULT 0;
+ALTER TABLE campaign_messages ADD COLUMN phone VARCHAR(50) NULL;
+ALTER TABLE campaign_messages ADD COLUMN name VARCHAR(255) NULL;
+CREATE INDEX idx_campaign_position ON campaign_messages (campaign_id, position);
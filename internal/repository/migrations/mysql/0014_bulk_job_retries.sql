@@ -0,0 +1,4 @@
+-- A bulk messaging job retrying the failed recipients of another job refers
+-- to it by job ID.
+
+ALTER TABLE campaigns ADD COLUMN retry_of VARCHAR(64) NULL;
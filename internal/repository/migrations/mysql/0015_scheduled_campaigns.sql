@@ -0,0 +1,6 @@
+-- Scheduled campaigns belong to the user whose contacts they message, and the
+-- scheduler looks them up by status and due time.
+
+ALTER TABLE campaigns ADD COLUMN user_id INT NULL;
+CREATE INDEX idx_user_id ON campaigns (user_id);
+CREATE INDEX idx_status_scheduled_at ON campaigns (status, scheduled_at);
@@ -0,0 +1,4 @@
+-- Bulk messaging jobs and scheduled campaigns keep their throughput limits and
+-- quiet hours, so a job reloaded after a restart is throttled the same way.
+
+ALTER TABLE campaigns ADD COLUMN throttle JSON NULL;
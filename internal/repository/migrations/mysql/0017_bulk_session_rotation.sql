@@ -0,0 +1,7 @@
+-- A bulk messaging job may rotate between several sessions. The job keeps
+-- its sessions and their weights, and each campaign message the session that
+-- handled it.
+
+ALTER TABLE campaigns ADD COLUMN session_ids JSON NULL;
+ALTER TABLE campaigns ADD COLUMN session_weights JSON NULL;
+ALTER TABLE campaign_messages ADD COLUMN session_id VARCHAR(255) NULL;
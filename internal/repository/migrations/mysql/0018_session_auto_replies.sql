@@ -0,0 +1,4 @@
+-- Auto-reply rules answer incoming messages of sessions that have them
+-- enabled, which existing sessions do so their active rules take effect.
+
+ALTER TABLE session_metadata ADD COLUMN auto_replies_enabled BOOLEAN NOT NULL DEFAULT TRUE;
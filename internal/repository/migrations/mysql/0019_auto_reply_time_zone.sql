@@ -0,0 +1,4 @@
+-- Auto-reply time windows are read in the rule's time zone, the server's
+-- local time when it has none.
+
+ALTER TABLE auto_replies ADD COLUMN time_zone VARCHAR(64) NULL;
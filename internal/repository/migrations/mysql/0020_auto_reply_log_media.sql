@@ -0,0 +1,6 @@
+-- Auto-reply logs record whether the reply carried media and the template
+-- variables that could not be filled in.
+
+ALTER TABLE auto_reply_logs
+	ADD COLUMN media_included BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN substitution_errors JSON NULL;
@@ -0,0 +1,3 @@
+-- Sessions can opt out of receipt webhooks; existing sessions keep them.
+
+ALTER TABLE session_metadata ADD COLUMN receive_receipts BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,3 @@
+-- The webhook events a session receives; NULL sends every event.
+
+ALTER TABLE session_metadata ADD COLUMN webhook_events JSON NULL;
@@ -0,0 +1,33 @@
+-- Webhook events are queued for delivery by a background worker, which retries
+-- failed deliveries with backoff until they expire. Events still undelivered in
+-- webhook_events are moved over, which is dropped: held for sessions whose
+-- webhook is suspended, failed otherwise, since nothing would have replayed them.
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	session_id VARCHAR(255) NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	payload JSON NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_retry_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	last_error TEXT NULL,
+	created_at BIGINT NOT NULL,
+	delivered_at BIGINT NULL,
+	INDEX idx_status_next_retry (status, next_retry_at),
+	INDEX idx_session_status (session_id, status, id),
+	INDEX idx_status_delivered (status, delivered_at),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO webhook_deliveries (session_id, event_type, payload, status, attempts, next_retry_at, expires_at, last_error, created_at)
+SELECT e.session_id, e.event_type, e.payload,
+	IF(s.webhook_suspended_at IS NULL, 'failed', 'pending'), 1, e.created_at, e.created_at + 86400,
+	'undelivered before the delivery queue', e.created_at
+FROM webhook_events e
+JOIN session_metadata s ON s.id = e.session_id
+WHERE e.delivered_at IS NULL
+ORDER BY e.id;
+
+DROP TABLE webhook_events;
@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN media_key;
+ALTER TABLE session_metadata DROP COLUMN persist_media;
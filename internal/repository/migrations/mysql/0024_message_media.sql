@@ -0,0 +1,6 @@
+-- Received media is recorded on its message so it can be downloaded after the
+-- webhook's temporary URL expires; sessions can opt out of keeping it.
+
+ALTER TABLE messages ADD COLUMN media_key VARCHAR(512) NULL;
+
+ALTER TABLE session_metadata ADD COLUMN persist_media BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,4 @@
+-- The plaintext keys moved over by the migration cannot be restored from
+-- their hashes, so users are left without API keys.
+
+DROP TABLE IF EXISTS api_keys;
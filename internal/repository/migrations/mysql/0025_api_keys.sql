@@ -0,0 +1,26 @@
+-- Users may hold several named API keys, each limited to scopes, optionally
+-- to some sessions and to a request rate. Keys are stored as SHA-256 hashes;
+-- the single plaintext key of each user is moved over as a full-access key.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id INT NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	key_hint VARCHAR(20) NOT NULL,
+	scopes JSON NOT NULL,
+	sessions JSON NULL,
+	rate_limit INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	last_used_at BIGINT NULL,
+	UNIQUE INDEX idx_key_hash (key_hash),
+	INDEX idx_user (user_id, id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO api_keys (user_id, name, key_hash, key_hint, scopes, created_at)
+SELECT id, 'default', SHA2(api_key, 256), CONCAT('wams_...', RIGHT(api_key, 4)), '["*"]', COALESCE(updated_at, created_at)
+FROM users
+WHERE api_key IS NOT NULL AND api_key <> '';
+
+UPDATE users SET api_key = NULL;
@@ -0,0 +1,18 @@
+-- Access tokens are short-lived and renewed with refresh tokens, which are
+-- stored as SHA-256 hashes and rotated on every use (used_at). Tokens issued
+-- before a user's tokens_invalidated_at are rejected.
+
+ALTER TABLE users ADD COLUMN tokens_invalidated_at BIGINT NULL;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id INT NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	used_at BIGINT NULL,
+	revoked_at BIGINT NULL,
+	UNIQUE INDEX idx_token_hash (token_hash),
+	INDEX idx_user_expires (user_id, expires_at),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,19 @@
+-- Record of sensitive actions, kept apart from the application logs. Entries
+-- outlive the users and sessions they name, so there are no foreign keys.
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	actor_user_id INT NULL,
+	actor_username VARCHAR(255) NULL,
+	auth_method VARCHAR(20) NULL,
+	action VARCHAR(64) NOT NULL,
+	target_type VARCHAR(32) NULL,
+	target_id VARCHAR(255) NULL,
+	ip VARCHAR(64) NULL,
+	details JSON NULL,
+	created_at BIGINT NOT NULL,
+	INDEX idx_created (created_at),
+	INDEX idx_actor_created (actor_user_id, created_at),
+	INDEX idx_action_created (action, created_at),
+	INDEX idx_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1 @@
+ALTER TABLE session_metadata DROP COLUMN receive_status;
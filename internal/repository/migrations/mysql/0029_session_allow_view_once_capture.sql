@@ -0,0 +1,3 @@
+-- Received view-once media is only downloaded for sessions allowing it.
+
+ALTER TABLE session_metadata ADD COLUMN allow_view_once_capture BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+-- Broadcast lists are mirrored from the phone's app state, like chat labels.
+-- recipients holds the JIDs of the list's members as a JSON array.
+
+CREATE TABLE IF NOT EXISTS broadcast_lists (
+	session_id VARCHAR(255) NOT NULL,
+	list_jid VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	recipients JSON NOT NULL,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, list_jid),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Tags group sessions for filtering the session list, as a JSON array; NULL for none.
+
+ALTER TABLE session_metadata ADD COLUMN tags JSON NULL;
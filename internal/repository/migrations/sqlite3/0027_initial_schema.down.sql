@@ -0,0 +1,21 @@
+-- Drops the whole application schema, children before parents.
+
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS chat_state;
+DROP TABLE IF EXISTS contact_first_seen;
+DROP TABLE IF EXISTS chat_label_assignments;
+DROP TABLE IF EXISTS chat_labels;
+DROP TABLE IF EXISTS auto_reply_logs;
+DROP TABLE IF EXISTS auto_replies;
+DROP TABLE IF EXISTS campaign_messages;
+DROP TABLE IF EXISTS campaigns;
+DROP TABLE IF EXISTS message_templates;
+DROP TABLE IF EXISTS contacts;
+DROP TABLE IF EXISTS contact_groups;
+DROP TABLE IF EXISTS logs;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS session_metadata;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,388 @@
+-- SQLite databases start out at the schema MySQL databases reach with
+-- migration 27, so this migration carries that version and later migrations
+-- share their version with the MySQL one.
+--
+-- Differences from the MySQL schema: integer keys are INTEGER PRIMARY KEY
+-- AUTOINCREMENT, JSON columns are TEXT, index names are prefixed with their
+-- table since SQLite scopes them to the database, and messages.updated_at is
+-- not touched on update (every update of a message sets it anyway).
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username VARCHAR(255) UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	role VARCHAR(50) NOT NULL DEFAULT 'user',
+	session_limit INT NOT NULL DEFAULT 5,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	api_key VARCHAR(64) UNIQUE NULL,
+	default_region VARCHAR(2) NULL,
+	segment_engaged_min_score INT NULL,
+	segment_dormant_days INT NULL,
+	tokens_invalidated_at BIGINT NULL
+);
+
+CREATE TABLE IF NOT EXISTS session_metadata (
+	id VARCHAR(255) PRIMARY KEY,
+	phone VARCHAR(50) NOT NULL,
+	actual_phone VARCHAR(50),
+	name VARCHAR(255),
+	position INT DEFAULT 0,
+	webhook_url TEXT,
+	auto_reply_text TEXT,
+	proxy_enabled BOOLEAN DEFAULT FALSE,
+	proxy_type VARCHAR(10) DEFAULT '',
+	proxy_host VARCHAR(255) DEFAULT '',
+	proxy_port INT DEFAULT 0,
+	proxy_username VARCHAR(255) DEFAULT '',
+	proxy_password VARCHAR(255) DEFAULT '',
+	user_id INT NOT NULL DEFAULT 1,
+	created_at BIGINT NOT NULL,
+	enabled BOOLEAN DEFAULT TRUE,
+	webhook_proxy_url TEXT,
+	webhook_legacy_format BOOLEAN NOT NULL DEFAULT FALSE,
+	webhook_suspended_at BIGINT NULL,
+	banned_until BIGINT NULL,
+	ban_reason VARCHAR(255) NOT NULL DEFAULT '',
+	send_defaults TEXT NULL,
+	new_contact_since BIGINT NULL,
+	new_contact_create_contact BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at BIGINT NULL,
+	sandbox BOOLEAN NOT NULL DEFAULT FALSE,
+	webhook_secret VARCHAR(255) NOT NULL DEFAULT '',
+	auto_replies_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	receive_receipts BOOLEAN NOT NULL DEFAULT TRUE,
+	webhook_events TEXT NULL,
+	persist_media BOOLEAN NOT NULL DEFAULT TRUE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_session_metadata_phone ON session_metadata (phone);
+CREATE INDEX IF NOT EXISTS idx_session_metadata_user_id ON session_metadata (user_id);
+CREATE INDEX IF NOT EXISTS idx_session_metadata_created_at ON session_metadata (created_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id VARCHAR(255) NOT NULL,
+	message_id VARCHAR(255) UNIQUE,
+	sender_jid VARCHAR(100),
+	recipient_jid VARCHAR(100),
+	message_type VARCHAR(50) NOT NULL DEFAULT 'text',
+	content TEXT,
+	media_url TEXT,
+	direction VARCHAR(20) NOT NULL,
+	status VARCHAR(50),
+	error_message TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	edited_at TIMESTAMP NULL DEFAULT NULL,
+	media_key VARCHAR(512) NULL,
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages (session_id);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_jid ON messages (sender_jid);
+CREATE INDEX IF NOT EXISTS idx_messages_recipient_jid ON messages (recipient_jid);
+CREATE INDEX IF NOT EXISTS idx_messages_direction ON messages (direction);
+CREATE INDEX IF NOT EXISTS idx_messages_status ON messages (status);
+CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages (created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_session_history ON messages (session_id, id);
+CREATE INDEX IF NOT EXISTS idx_messages_session_chat_history ON messages (session_id, recipient_jid, id);
+
+CREATE TABLE IF NOT EXISTS logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	level VARCHAR(10) NOT NULL,
+	message TEXT NOT NULL,
+	component VARCHAR(100),
+	session_id VARCHAR(255),
+	user_id INT,
+	metadata TEXT,
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_logs_level ON logs (level);
+CREATE INDEX IF NOT EXISTS idx_logs_component ON logs (component);
+CREATE INDEX IF NOT EXISTS idx_logs_session_id ON logs (session_id);
+CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs (created_at);
+
+CREATE TABLE IF NOT EXISTS contact_groups (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	color VARCHAR(7),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	user_id INT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_contact_groups_name ON contact_groups (name);
+CREATE INDEX IF NOT EXISTS idx_contact_groups_is_active ON contact_groups (is_active);
+CREATE INDEX IF NOT EXISTS idx_contact_groups_user_id ON contact_groups (user_id);
+
+CREATE TABLE IF NOT EXISTS contacts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	phone VARCHAR(50) NOT NULL,
+	email VARCHAR(255),
+	company VARCHAR(255),
+	position VARCHAR(255),
+	group_id INT,
+	tags TEXT,
+	notes TEXT,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	last_contact BIGINT,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	engagement_score INT NOT NULL DEFAULT 0,
+	last_inbound_at BIGINT NULL,
+	replied_to_campaign BOOLEAN NOT NULL DEFAULT FALSE,
+	score_updated_at BIGINT NULL,
+	inbound_count INT NOT NULL DEFAULT 0,
+	outbound_count INT NOT NULL DEFAULT 0,
+	user_id INT NULL,
+	FOREIGN KEY (group_id) REFERENCES contact_groups(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_contacts_phone ON contacts (phone);
+CREATE INDEX IF NOT EXISTS idx_contacts_name ON contacts (name);
+CREATE INDEX IF NOT EXISTS idx_contacts_group_id ON contacts (group_id);
+CREATE INDEX IF NOT EXISTS idx_contacts_is_active ON contacts (is_active);
+CREATE INDEX IF NOT EXISTS idx_contacts_email ON contacts (email);
+CREATE INDEX IF NOT EXISTS idx_contacts_engagement_score ON contacts (engagement_score);
+CREATE INDEX IF NOT EXISTS idx_contacts_last_inbound_at ON contacts (last_inbound_at);
+CREATE INDEX IF NOT EXISTS idx_contacts_last_contact ON contacts (last_contact);
+CREATE INDEX IF NOT EXISTS idx_contacts_user_id ON contacts (user_id);
+
+CREATE TABLE IF NOT EXISTS message_templates (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	content TEXT NOT NULL,
+	type VARCHAR(50) NOT NULL DEFAULT 'text',
+	variables TEXT,
+	media_url TEXT,
+	media_type VARCHAR(50),
+	category VARCHAR(100),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	usage_count INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_message_templates_name ON message_templates (name);
+CREATE INDEX IF NOT EXISTS idx_message_templates_type ON message_templates (type);
+CREATE INDEX IF NOT EXISTS idx_message_templates_category ON message_templates (category);
+CREATE INDEX IF NOT EXISTS idx_message_templates_is_active ON message_templates (is_active);
+
+CREATE TABLE IF NOT EXISTS campaigns (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	template_id INT NULL,
+	group_id INT,
+	contact_ids TEXT,
+	session_id VARCHAR(255) NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'draft',
+	delay_between INT NOT NULL DEFAULT 1,
+	random_delay BOOLEAN NOT NULL DEFAULT FALSE,
+	scheduled_at BIGINT,
+	started_at BIGINT,
+	completed_at BIGINT,
+	total_contacts INT NOT NULL DEFAULT 0,
+	sent_count INT NOT NULL DEFAULT 0,
+	failed_count INT NOT NULL DEFAULT 0,
+	pending_count INT NOT NULL DEFAULT 0,
+	variables TEXT,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	job_id VARCHAR(64) NULL,
+	message TEXT NULL,
+	send_options TEXT NULL,
+	invalid_recipients TEXT NULL,
+	next_index INT NOT NULL DEFAULT 0,
+	paused_reason VARCHAR(50) NULL,
+	retry_of VARCHAR(64) NULL,
+	user_id INT NULL,
+	throttle TEXT NULL,
+	session_ids TEXT NULL,
+	session_weights TEXT NULL,
+	FOREIGN KEY (template_id) REFERENCES message_templates(id) ON DELETE CASCADE,
+	FOREIGN KEY (group_id) REFERENCES contact_groups(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_campaigns_name ON campaigns (name);
+CREATE INDEX IF NOT EXISTS idx_campaigns_status ON campaigns (status);
+CREATE INDEX IF NOT EXISTS idx_campaigns_session_id ON campaigns (session_id);
+CREATE INDEX IF NOT EXISTS idx_campaigns_template_id ON campaigns (template_id);
+CREATE INDEX IF NOT EXISTS idx_campaigns_group_id ON campaigns (group_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_campaigns_job_id ON campaigns (job_id);
+CREATE INDEX IF NOT EXISTS idx_campaigns_user_id ON campaigns (user_id);
+CREATE INDEX IF NOT EXISTS idx_campaigns_status_scheduled_at ON campaigns (status, scheduled_at);
+
+CREATE TABLE IF NOT EXISTS campaign_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	campaign_id INT NOT NULL,
+	contact_id INT NULL,
+	content TEXT NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'pending',
+	error_msg TEXT,
+	message_id VARCHAR(255),
+	sent_at BIGINT,
+	created_at BIGINT NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+	phone VARCHAR(50) NULL,
+	name VARCHAR(255) NULL,
+	session_id VARCHAR(255) NULL,
+	FOREIGN KEY (campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+	FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_campaign_messages_campaign_id ON campaign_messages (campaign_id);
+CREATE INDEX IF NOT EXISTS idx_campaign_messages_contact_id ON campaign_messages (contact_id);
+CREATE INDEX IF NOT EXISTS idx_campaign_messages_status ON campaign_messages (status);
+CREATE INDEX IF NOT EXISTS idx_campaign_messages_sent_at ON campaign_messages (sent_at);
+CREATE INDEX IF NOT EXISTS idx_campaign_messages_position ON campaign_messages (campaign_id, position);
+
+CREATE TABLE IF NOT EXISTS auto_replies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	trigger_type VARCHAR(50) NOT NULL,
+	keywords TEXT,
+	response TEXT NOT NULL,
+	media_url TEXT,
+	media_type VARCHAR(50),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	priority INT NOT NULL DEFAULT 0,
+	delay_min INT NOT NULL DEFAULT 0,
+	delay_max INT NOT NULL DEFAULT 0,
+	max_replies INT NOT NULL DEFAULT 0,
+	time_start VARCHAR(5),
+	time_end VARCHAR(5),
+	conditions TEXT,
+	usage_count INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT,
+	send_options TEXT NULL,
+	time_zone VARCHAR(64) NULL
+);
+CREATE INDEX IF NOT EXISTS idx_auto_replies_session_id ON auto_replies (session_id);
+CREATE INDEX IF NOT EXISTS idx_auto_replies_trigger_type ON auto_replies (trigger_type);
+CREATE INDEX IF NOT EXISTS idx_auto_replies_is_active ON auto_replies (is_active);
+CREATE INDEX IF NOT EXISTS idx_auto_replies_priority ON auto_replies (priority);
+
+CREATE TABLE IF NOT EXISTS auto_reply_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	auto_reply_id INT NOT NULL,
+	session_id VARCHAR(255) NOT NULL,
+	contact_phone VARCHAR(50) NOT NULL,
+	trigger_msg TEXT NOT NULL,
+	response TEXT NOT NULL,
+	success BOOLEAN NOT NULL DEFAULT TRUE,
+	error_msg TEXT,
+	created_at BIGINT NOT NULL,
+	media_included BOOLEAN NOT NULL DEFAULT FALSE,
+	substitution_errors TEXT NULL,
+	FOREIGN KEY (auto_reply_id) REFERENCES auto_replies(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_auto_reply_logs_auto_reply_id ON auto_reply_logs (auto_reply_id);
+CREATE INDEX IF NOT EXISTS idx_auto_reply_logs_session_id ON auto_reply_logs (session_id);
+CREATE INDEX IF NOT EXISTS idx_auto_reply_logs_contact_phone ON auto_reply_logs (contact_phone);
+CREATE INDEX IF NOT EXISTS idx_auto_reply_logs_created_at ON auto_reply_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS chat_labels (
+	session_id VARCHAR(255) NOT NULL,
+	label_id VARCHAR(64) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	color INT NOT NULL DEFAULT 0,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, label_id),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chat_label_assignments (
+	session_id VARCHAR(255) NOT NULL,
+	chat_jid VARCHAR(255) NOT NULL,
+	label_id VARCHAR(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, chat_jid, label_id),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_chat_label_assignments_label ON chat_label_assignments (session_id, label_id);
+
+CREATE TABLE IF NOT EXISTS contact_first_seen (
+	session_id VARCHAR(255) NOT NULL,
+	contact VARCHAR(255) NOT NULL,
+	first_seen_at BIGINT NOT NULL,
+	first_message_id VARCHAR(255) NULL,
+	message_count INT NOT NULL DEFAULT 0,
+	backfilled BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (session_id, contact),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chat_state (
+	session_id VARCHAR(255) NOT NULL,
+	chat_jid VARCHAR(100) NOT NULL,
+	last_read_id INT NOT NULL DEFAULT 0,
+	last_read_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, chat_jid),
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id VARCHAR(255) NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_retry_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	last_error TEXT NULL,
+	created_at BIGINT NOT NULL,
+	delivered_at BIGINT NULL,
+	FOREIGN KEY (session_id) REFERENCES session_metadata(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_retry ON webhook_deliveries (status, next_retry_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_session_status ON webhook_deliveries (session_id, status, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_delivered ON webhook_deliveries (status, delivered_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INT NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	key_hint VARCHAR(20) NOT NULL,
+	scopes TEXT NOT NULL,
+	sessions TEXT NULL,
+	rate_limit INT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	last_used_at BIGINT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id, id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INT NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	used_at BIGINT NULL,
+	revoked_at BIGINT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_expires ON refresh_tokens (user_id, expires_at);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_user_id INT NULL,
+	actor_username VARCHAR(255) NULL,
+	auth_method VARCHAR(20) NULL,
+	action VARCHAR(64) NOT NULL,
+	target_type VARCHAR(32) NULL,
+	target_id VARCHAR(255) NULL,
+	ip VARCHAR(64) NULL,
+	details TEXT NULL,
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_created ON audit_logs (actor_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action_created ON audit_logs (action, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_type, target_id);
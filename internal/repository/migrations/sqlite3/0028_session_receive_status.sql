@@ -0,0 +1,3 @@
+-- Contacts' status updates reach the webhook only for sessions asking for them.
+
+ALTER TABLE session_metadata ADD COLUMN receive_status BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+UPDATE users SET tokens_invalidated_at = tokens_invalidated_at / 1000000 WHERE tokens_invalidated_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- tokens_invalidated_at is kept in microseconds, as access tokens carry
+-- sub-second issue times, so a token issued in the same second as a logout
+-- or password change is told apart from the ones issued after it.
+
+UPDATE users SET tokens_invalidated_at = tokens_invalidated_at * 1000000 WHERE tokens_invalidated_at IS NOT NULL;
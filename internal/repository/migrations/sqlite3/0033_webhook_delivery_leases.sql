@@ -0,0 +1,5 @@
+-- A worker claims the due deliveries it sends by leasing them until
+-- lease_until, so when several instances share a database each delivery is
+-- sent by one of them. NULL when no worker holds the delivery.
+
+ALTER TABLE webhook_deliveries ADD COLUMN lease_until BIGINT NULL;
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// RefreshTokenRepository stores the refresh tokens of users
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create stores a new refresh token and sets its ID
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, token.UserID, token.TokenHash, token.CreatedAt.Unix(), token.ExpiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %v", err)
+	}
+
+	token.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token ID: %v", err)
+	}
+	return nil
+}
+
+// GetByHash returns the refresh token with the given hash, or nil if there is none
+func (r *RefreshTokenRepository) GetByHash(tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = ?
+	`
+
+	token := &models.RefreshToken{}
+	var createdAt, expiresAt int64
+	var usedAt, revokedAt sql.NullInt64
+	err := r.db.QueryRow(query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &createdAt, &expiresAt, &usedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+
+	token.CreatedAt = time.Unix(createdAt, 0)
+	token.ExpiresAt = time.Unix(expiresAt, 0)
+	if usedAt.Valid {
+		used := time.Unix(usedAt.Int64, 0)
+		token.UsedAt = &used
+	}
+	if revokedAt.Valid {
+		revoked := time.Unix(revokedAt.Int64, 0)
+		token.RevokedAt = &revoked
+	}
+	return token, nil
+}
+
+// MarkUsed records that a refresh token was exchanged, reporting false when
+// it was already used or revoked, so that only one of two concurrent uses wins
+func (r *RefreshTokenRepository) MarkUsed(id int64, at time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL`
+	return r.updateOne(query, at.Unix(), id)
+}
+
+// Revoke revokes a refresh token that has not been used
+func (r *RefreshTokenRepository) Revoke(id int64, at time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL`
+	return r.updateOne(query, at.Unix(), id)
+}
+
+func (r *RefreshTokenRepository) updateOne(query string, args ...interface{}) (bool, error) {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to update refresh token: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// RevokeByUserID revokes every unused refresh token of a user
+func (r *RefreshTokenRepository) RevokeByUserID(userID int, at time.Time) error {
+	query := `UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND used_at IS NULL AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, at.Unix(), userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user %d: %v", userID, err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes the refresh tokens of a user that expired before
+// now. Used tokens are kept until then to recognize their reuse.
+func (r *RefreshTokenRepository) DeleteExpired(userID int, now time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE user_id = ? AND expires_at < ?`, userID, now.Unix()); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,676 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// forEachBackend runs a test against a freshly migrated database of every
+// dialect: SQLite always, and MySQL when TEST_MYSQL_HOST names a server.
+// The MySQL database given by TEST_MYSQL_DATABASE is emptied first, so it
+// must be one set aside for tests.
+func forEachBackend(t *testing.T, test func(t *testing.T, d *Database)) {
+	t.Run("sqlite", func(t *testing.T) {
+		d := newTestSQLite(t)
+		if err := d.InitTables(); err != nil {
+			t.Fatalf("failed to migrate database: %v", err)
+		}
+		test(t, d)
+	})
+	t.Run("mysql", func(t *testing.T) {
+		test(t, newTestMySQL(t))
+	})
+}
+
+// newTestMySQL opens the MySQL test database with every table dropped and
+// the migrations applied again
+func newTestMySQL(t *testing.T) *Database {
+	t.Helper()
+	host := os.Getenv("TEST_MYSQL_HOST")
+	if host == "" {
+		t.Skip("TEST_MYSQL_HOST is not set")
+	}
+	port := os.Getenv("TEST_MYSQL_PORT")
+	if port == "" {
+		port = "3306"
+	}
+
+	d, err := NewDatabase(DatabaseConfig{
+		Type:     "mysql",
+		Host:     host,
+		Port:     port,
+		User:     os.Getenv("TEST_MYSQL_USER"),
+		Password: os.Getenv("TEST_MYSQL_PASSWORD"),
+		Database: os.Getenv("TEST_MYSQL_DATABASE"),
+	})
+	if err != nil {
+		t.Fatalf("failed to open MySQL database: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	// Foreign key checks are per connection, so the drops share one
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, d.dialect.ForeignKeyChecks(false)); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := queryStrings(d, `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if _, err := conn.ExecContext(ctx, "DROP TABLE `"+table+"`"); err != nil {
+			t.Fatalf("failed to drop table %s: %v", table, err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, d.dialect.ForeignKeyChecks(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.InitTables(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return d
+}
+
+// seedUser creates a user and returns its ID
+func seedUser(t *testing.T, d *Database, username string) int {
+	t.Helper()
+	user := &models.User{Username: username, Password: "x", Role: models.RoleUser, SessionLimit: 5, IsActive: true, CreatedAt: time.Now()}
+	if err := NewUserRepository(d.db).Create(user); err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return user.ID
+}
+
+// seedSession creates a session of userID
+func seedSession(t *testing.T, d *Database, id string, userID int, sandbox bool) *models.SessionMetadata {
+	t.Helper()
+	session := &models.SessionMetadata{ID: id, Name: id, Enabled: true, UserID: userID, Sandbox: sandbox, CreatedAt: time.Now()}
+	if err := NewSessionRepository(d.db).Create(session); err != nil {
+		t.Fatalf("failed to create session %s: %v", id, err)
+	}
+	return session
+}
+
+// logMessages logs messages, failing the test on the first error
+func logMessages(t *testing.T, d *Database, messages ...*Message) {
+	t.Helper()
+	repo := NewMessageRepository(d.db)
+	for _, msg := range messages {
+		if msg.UpdatedAt.IsZero() {
+			msg.UpdatedAt = msg.CreatedAt
+		}
+		if err := repo.LogMessage(msg); err != nil {
+			t.Fatalf("failed to log message %s: %v", msg.MessageID, err)
+		}
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		if got := dialectOf(d.db); got != d.dialect {
+			t.Errorf("dialectOf() = %s, want %s", got, d.dialect)
+		}
+	})
+}
+
+// Each dialect helper evaluated on its own by the database
+func TestDialectHelpers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		tests := []struct {
+			name  string
+			query string
+			args  []interface{}
+			want  int64
+		}{
+			{"NULL equals NULL", "SELECT CASE WHEN NULL " + d.dialect.nullSafeEqual() + " NULL THEN 1 ELSE 0 END", nil, 1},
+			{"value differs from NULL", "SELECT CASE WHEN 1 " + d.dialect.nullSafeEqual() + " NULL THEN 1 ELSE 0 END", nil, 0},
+			{"value equals value", "SELECT CASE WHEN 2 " + d.dialect.nullSafeEqual() + " ? THEN 1 ELSE 0 END", []interface{}{2}, 1},
+			{"array holds value", "SELECT CASE WHEN " + d.dialect.jsonArrayContains("'[1,12,3]'") + " THEN 1 ELSE 0 END", []interface{}{"12"}, 1},
+			{"array lacks value", "SELECT CASE WHEN " + d.dialect.jsonArrayContains("'[1,12,3]'") + " THEN 1 ELSE 0 END", []interface{}{"2"}, 0},
+			{"unix time round trip", "SELECT " + d.dialect.unixTime(d.dialect.fromUnixTime("?")), []interface{}{1700000000}, 1700000000},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got int64
+				if err := d.db.QueryRow(tt.query, tt.args...).Scan(&got); err != nil {
+					t.Fatalf("%s: %v", tt.query, err)
+				}
+				if got != tt.want {
+					t.Errorf("%s = %d, want %d", tt.query, got, tt.want)
+				}
+			})
+		}
+
+		for table, want := range map[string]bool{"messages": true, "no_such_table": false} {
+			if got, err := d.dialect.tableExists(d.db, table); err != nil || got != want {
+				t.Errorf("tableExists(%s) = %v, %v; want %v", table, got, err, want)
+			}
+		}
+	})
+}
+
+// Upserts through onDuplicateKey and inserted, assignments through insertIgnore
+func TestLabelRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewLabelRepository(d.db)
+
+		if err := repo.UpsertLabel("s1", &models.ChatLabel{ID: "1", Name: "Customer", Color: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UpsertLabel("s1", &models.ChatLabel{ID: "1", Name: "VIP", Color: 5}); err != nil {
+			t.Fatalf("updating the label failed: %v", err)
+		}
+		if err := repo.UpsertLabel("s1", &models.ChatLabel{ID: "2", Name: "Lead", Color: 1}); err != nil {
+			t.Fatal(err)
+		}
+		labels, err := repo.GetLabels("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*models.ChatLabel{{ID: "2", Name: "Lead", Color: 1}, {ID: "1", Name: "VIP", Color: 5}}
+		if !reflect.DeepEqual(labels, want) {
+			t.Errorf("labels = %+v, want %+v", labels, want)
+		}
+
+		chat := "628123456789@s.whatsapp.net"
+		for i := 0; i < 2; i++ {
+			if err := repo.SetChatLabel("s1", chat, "1", true); err != nil {
+				t.Fatalf("assigning the label (%d) failed: %v", i+1, err)
+			}
+		}
+		if err := repo.SetChatLabel("s1", chat, "2", true); err != nil {
+			t.Fatal(err)
+		}
+		assignments, err := repo.GetAssignments("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := assignments[chat]; !reflect.DeepEqual(got, []string{"Lead", "VIP"}) {
+			t.Errorf("chat labels = %v, want [Lead VIP] once each", got)
+		}
+
+		if err := repo.SetChatLabel("s1", chat, "1", false); err != nil {
+			t.Fatal(err)
+		}
+		assignments, err = repo.GetAssignments("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := assignments[chat]; !reflect.DeepEqual(got, []string{"Lead"}) {
+			t.Errorf("chat labels after removing VIP = %v, want [Lead]", got)
+		}
+	})
+}
+
+func TestBroadcastListRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewBroadcastListRepository(d.db)
+
+		list := &models.BroadcastList{JID: "123@broadcast", Name: "Customers", Recipients: []string{"628111@s.whatsapp.net"}}
+		if err := repo.UpsertList("s1", list); err != nil {
+			t.Fatal(err)
+		}
+		list = &models.BroadcastList{JID: "123@broadcast", Name: "Regulars", Recipients: []string{"628111@s.whatsapp.net", "628222@s.whatsapp.net"}}
+		if err := repo.UpsertList("s1", list); err != nil {
+			t.Fatalf("updating the list failed: %v", err)
+		}
+
+		lists, err := repo.GetLists("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lists) != 1 || lists[0].Name != "Regulars" || !reflect.DeepEqual(lists[0].Recipients, list.Recipients) {
+			t.Errorf("lists = %+v, want the updated list only", lists)
+		}
+		if missing, err := repo.GetList("s1", "456@broadcast"); err != nil || missing != nil {
+			t.Errorf("GetList(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+}
+
+// MarkChatRead upserts with GREATEST, so the read position never moves back
+func TestMarkChatRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewMessageRepository(d.db)
+		chat := "628111@s.whatsapp.net"
+		received := func(id string) *Message {
+			return &Message{SessionID: "s1", MessageID: id, SenderJID: chat, RecipientJID: chat, MessageType: "text",
+				Content: id, Direction: "received", Status: "received", CreatedAt: time.Now().UTC()}
+		}
+		unread := func() int {
+			t.Helper()
+			summaries, err := repo.GetChatSummaries("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(summaries) != 1 {
+				t.Fatalf("%d chat summaries, want 1", len(summaries))
+			}
+			return summaries[0].UnreadCount
+		}
+
+		logMessages(t, d, received("in-1"), received("in-2"))
+		if n := unread(); n != 2 {
+			t.Errorf("unread = %d before reading, want 2", n)
+		}
+		if err := repo.MarkChatRead("s1", chat); err != nil {
+			t.Fatal(err)
+		}
+		if n := unread(); n != 0 {
+			t.Errorf("unread = %d after reading, want 0", n)
+		}
+
+		logMessages(t, d, received("in-3"))
+		if n := unread(); n != 1 {
+			t.Errorf("unread = %d after a new message, want 1", n)
+		}
+		if err := repo.MarkChatRead("s1", chat); err != nil {
+			t.Fatalf("marking the chat read again failed: %v", err)
+		}
+		if n := unread(); n != 0 {
+			t.Errorf("unread = %d after reading again, want 0", n)
+		}
+	})
+}
+
+func TestContactSeenRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewContactSeenRepository(d.db)
+		now := time.Now()
+
+		steps := []struct {
+			contact string
+			first   bool
+		}{
+			{"628111", true},
+			{"628111", false},
+			{"628222", true},
+		}
+		for _, step := range steps {
+			first, err := repo.RecordInbound("s1", step.contact, "msg", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first != step.first {
+				t.Errorf("RecordInbound(%s) first = %v, want %v", step.contact, first, step.first)
+			}
+		}
+
+		recorded, err := repo.Backfill("s1", []string{"628111", "628333"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded != 1 {
+			t.Errorf("Backfill recorded %d contacts, want only the unknown one", recorded)
+		}
+		if first, err := repo.RecordInbound("s1", "628333", "msg", now); err != nil || first {
+			t.Errorf("RecordInbound(backfilled) = %v, %v; want not first", first, err)
+		}
+
+		for contact, want := range map[string]bool{"628111": false, "628222": true, "628333": false, "628444": false} {
+			if got, err := repo.IsFirstMessage("s1", contact); err != nil || got != want {
+				t.Errorf("IsFirstMessage(%s) = %v, %v; want %v", contact, got, err, want)
+			}
+		}
+	})
+}
+
+// Groups without an owner match each other through nullSafeEqual
+func TestCheckGroupNameExists(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		alice := seedUser(t, d, "alice")
+		bob := seedUser(t, d, "bob")
+		repo := NewContactGroupRepository(d.db)
+
+		shared := &models.ContactGroup{Name: "Team", IsActive: true}
+		owned := &models.ContactGroup{Name: "Team", UserID: alice, IsActive: true}
+		for _, group := range []*models.ContactGroup{shared, owned} {
+			if err := repo.CreateContactGroup(group); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			name      string
+			userID    int
+			excludeID *int
+			want      bool
+		}{
+			{"unowned", 0, nil, true},
+			{"unowned excluding itself", 0, &shared.ID, false},
+			{"owner", alice, nil, true},
+			{"owner excluding the group", alice, &owned.ID, false},
+			{"other user", bob, nil, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.CheckGroupNameExists("Team", tt.userID, tt.excludeID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("CheckGroupNameExists() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+// Message times written in another zone compare by the instant they name
+func TestGetInboundActivity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		jakarta := time.FixedZone("WIB", 7*60*60)
+
+		logMessages(t, d,
+			&Message{SessionID: "s1", MessageID: "a1", SenderJID: "628111@s.whatsapp.net", RecipientJID: "628111@s.whatsapp.net",
+				MessageType: "text", Direction: "received", Status: "received", CreatedAt: base},
+			&Message{SessionID: "s1", MessageID: "a2", SenderJID: "628111@s.whatsapp.net", RecipientJID: "628111@s.whatsapp.net",
+				MessageType: "text", Direction: "received", Status: "received", CreatedAt: base.Add(time.Hour).In(jakarta)},
+			&Message{SessionID: "s1", MessageID: "b1", SenderJID: "628222:3@s.whatsapp.net", RecipientJID: "628222@s.whatsapp.net",
+				MessageType: "text", Direction: "received", Status: "received", CreatedAt: base.Add(10 * time.Minute).In(jakarta)},
+			&Message{SessionID: "s1", MessageID: "c1", RecipientJID: "628333@s.whatsapp.net",
+				MessageType: "text", Direction: "sent", Status: "sent", CreatedAt: base},
+		)
+
+		activity, err := NewContactRepository(d.db).GetInboundActivity(base.Add(30 * time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]models.InboundActivity{
+			"628111": {LastAt: base.Add(time.Hour), RecentCount: 1},
+			"628222": {LastAt: base.Add(10 * time.Minute), RecentCount: 0},
+		}
+		if !reflect.DeepEqual(activity, want) {
+			t.Errorf("activity = %+v, want %+v", activity, want)
+		}
+	})
+}
+
+func TestUpdateSettingsVersions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		session := seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewSessionRepository(d.db)
+
+		start, err := repo.GetVersion("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session.Name = "Shop"
+		session.WebhookURL = "https://example.com/hook"
+		version, err := repo.UpdateSettings(session, &start)
+		if err != nil {
+			t.Fatalf("update at the current version failed: %v", err)
+		}
+		if version != start+1 {
+			t.Errorf("version = %d after updating, want %d", version, start+1)
+		}
+
+		session.Name = "Stale"
+		if got, err := repo.UpdateSettings(session, &start); !errors.Is(err, ErrVersionConflict) || got != start+1 {
+			t.Errorf("update at a stale version = %d, %v; want %d, ErrVersionConflict", got, err, start+1)
+		}
+
+		stored, err := repo.GetByID("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Name != "Shop" || stored.WebhookURL != "https://example.com/hook" {
+			t.Errorf("stored session = %q, %q; want the first update only", stored.Name, stored.WebhookURL)
+		}
+
+		if version, err := repo.UpdateSettings(session, nil); err != nil || version != start+2 {
+			t.Errorf("unconditional update = %d, %v; want %d", version, err, start+2)
+		}
+	})
+}
+
+// Sandbox sessions and messages outside the time range are left out of the
+// stats, and the daily series groups by the normalized timestamp
+func TestAnalyticsMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		alice := seedUser(t, d, "alice")
+		seedSession(t, d, "live", alice, false)
+		seedSession(t, d, "sandbox", alice, true)
+		repo := NewAnalyticsRepository(d.db)
+
+		now := time.Now().UTC()
+		old := now.AddDate(0, 0, -40)
+		message := func(id, sessionID, direction, messageType string, at time.Time) *Message {
+			return &Message{SessionID: sessionID, MessageID: id, RecipientJID: "628111@s.whatsapp.net", MessageType: messageType,
+				Direction: direction, Status: direction, CreatedAt: at}
+		}
+		logMessages(t, d,
+			message("m1", "live", "sent", "text", now),
+			message("m2", "live", "received", "image", now),
+			message("m3", "live", "sent", "text", old),
+			message("m4", "sandbox", "sent", "text", now),
+		)
+
+		tests := []struct {
+			name           string
+			timeRange      string
+			includeSandbox bool
+			total, sent    int64
+		}{
+			{"all time", "all", false, 3, 2},
+			{"last month", "month", false, 2, 1},
+			{"with sandbox", "all", true, 4, 3},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				stats, err := repo.GetMessageStats(int64(alice), tt.timeRange, tt.includeSandbox)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats.TotalMessages != tt.total || stats.SentMessages != tt.sent {
+					t.Errorf("stats = %d total, %d sent; want %d, %d", stats.TotalMessages, stats.SentMessages, tt.total, tt.sent)
+				}
+			})
+		}
+
+		series, err := repo.GetMessageTimeSeries(int64(alice), "all", "day", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts []int64
+		for _, point := range series {
+			counts = append(counts, point.Value)
+		}
+		if !reflect.DeepEqual(counts, []int64{1, 2}) {
+			t.Errorf("daily counts = %v, want [1 2]", counts)
+		}
+	})
+}
+
+// Connecting and logging out record the actual phone without touching the
+// settings, whether read back one by one or when sessions are restored
+func TestUpdateActualPhoneKeepsSettings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		repo := NewSessionRepository(d.db)
+		session := &models.SessionMetadata{
+			ID:          "s1",
+			Name:        "Shop",
+			WebhookURL:  "https://example.com/hook",
+			ProxyConfig: &models.ProxyConfig{Enabled: true, Type: "socks5", Host: "proxy.example.com", Port: 1080, Username: "user", Password: "secret"},
+			Enabled:     false,
+			UserID:      seedUser(t, d, "alice"),
+			CreatedAt:   time.Now(),
+		}
+		if err := repo.Create(session); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, actualPhone := range []string{"628123456789", ""} {
+			if err := repo.UpdateActualPhone("s1", actualPhone); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := repo.GetByID("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			all, err := repo.GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != 1 {
+				t.Fatalf("GetAll returned %d sessions, want 1", len(all))
+			}
+			for source, got := range map[string]*models.SessionMetadata{"GetByID": stored, "GetAll": all[0]} {
+				if got.ActualPhone != actualPhone {
+					t.Errorf("%s: actual phone = %q, want %q", source, got.ActualPhone, actualPhone)
+				}
+				if got.Enabled || got.WebhookURL != session.WebhookURL || !reflect.DeepEqual(got.ProxyConfig, session.ProxyConfig) {
+					t.Errorf("%s: settings = enabled %v, webhook %q, proxy %+v; want them unchanged", source, got.Enabled, got.WebhookURL, got.ProxyConfig)
+				}
+			}
+		}
+	})
+}
+
+// Due deliveries are claimed by one worker at a time, per session, until their
+// lease ends or they are given back
+func TestClaimDueWebhookDeliveries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		userID := seedUser(t, d, "alice")
+		repo := NewWebhookDeliveryRepository(d.db)
+		expires := time.Now().Add(time.Hour)
+		for i := 0; i < 10; i++ {
+			for _, sessionID := range []string{"s1", "s2"} {
+				if i == 0 {
+					seedSession(t, d, sessionID, userID, false)
+				}
+				if err := repo.Enqueue(sessionID, "message", []byte(`{"n":1}`), expires); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		// Workers polling at once claim every delivery once
+		now := time.Now()
+		lease := now.Add(time.Minute)
+		claims := make(chan []*models.WebhookDelivery, 4)
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			go func() {
+				claimed, err := repo.ClaimDue(now, lease, 100)
+				claims <- claimed
+				errs <- err
+			}()
+		}
+		claimedBy := make(map[int64]int)
+		for i := 0; i < 4; i++ {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+			for _, delivery := range <-claims {
+				claimedBy[delivery.ID]++
+			}
+		}
+		if len(claimedBy) != 20 {
+			t.Errorf("%d deliveries claimed, want 20", len(claimedBy))
+		}
+		for id, count := range claimedBy {
+			if count != 1 {
+				t.Errorf("delivery %d claimed %d times", id, count)
+			}
+		}
+
+		// Given back, a session's deliveries are claimed again; held, they are
+		// not until the lease ends
+		s1, _, err := repo.List("", "s1", 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int64, len(s1))
+		for i, delivery := range s1 {
+			ids[i] = delivery.ID
+		}
+		if err := repo.Unclaim(ids, lease); err != nil {
+			t.Fatal(err)
+		}
+		claimed, err := repo.ClaimDue(now, lease, 100)
+		if err != nil || len(claimed) != 10 || claimed[0].SessionID != "s1" {
+			t.Errorf("claimed after giving back s1 = %d deliveries, %v; want the 10 of s1", len(claimed), err)
+		}
+		if claimed, err := repo.ClaimDue(lease.Add(time.Second), lease.Add(time.Minute), 100); err != nil || len(claimed) != 20 {
+			t.Errorf("claimed after the lease = %d deliveries, %v; want 20", len(claimed), err)
+		}
+	})
+}
+
+// Deliveries queued while a session's deliveries are postponed behind a retry
+// wait for it too
+func TestEnqueueBehindPostponedWebhookDeliveries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d *Database) {
+		seedSession(t, d, "s1", seedUser(t, d, "alice"), false)
+		repo := NewWebhookDeliveryRepository(d.db)
+		expires := time.Now().Add(time.Hour)
+		for i := 0; i < 2; i++ {
+			if err := repo.Enqueue("s1", "message", []byte(`{}`), expires); err != nil {
+				t.Fatal(err)
+			}
+		}
+		now := time.Now()
+		head, err := repo.ClaimDue(now, now.Add(time.Minute), 100)
+		if err != nil || len(head) != 2 {
+			t.Fatalf("claimed %d deliveries, %v; want 2", len(head), err)
+		}
+
+		retryAt := now.Add(10 * time.Minute)
+		if err := repo.MarkRetry(head[0].ID, 1, retryAt, "connection refused"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.PostponeSession("s1", retryAt); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Enqueue("s1", "message", []byte(`{}`), expires); err != nil {
+			t.Fatal(err)
+		}
+
+		deliveries, _, err := repo.List("", "s1", 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, delivery := range deliveries {
+			if delivery.NextRetryAt.Unix() != retryAt.Unix() {
+				t.Errorf("delivery %d is due at %v, want %v", delivery.ID, delivery.NextRetryAt, retryAt)
+			}
+		}
+		if claimed, err := repo.ClaimDue(time.Now(), time.Now().Add(time.Minute), 100); err != nil || len(claimed) != 0 {
+			t.Errorf("claimed %d deliveries before the retry, %v; want none", len(claimed), err)
+		}
+		claimed, err := repo.ClaimDue(retryAt, retryAt.Add(time.Minute), 100)
+		if err != nil || len(claimed) != 3 || claimed[0].ID != head[0].ID {
+			t.Errorf("claimed at the retry = %d deliveries, %v; want 3 from the retried one", len(claimed), err)
+		}
+
+		// Without a postponed head, new deliveries are due at once
+		seedSession(t, d, "s2", seedUser(t, d, "bob"), false)
+		if err := repo.Enqueue("s2", "message", []byte(`{}`), expires); err != nil {
+			t.Fatal(err)
+		}
+		if fresh, _, err := repo.List("", "s2", 0, 1); err != nil || len(fresh) != 1 || fresh[0].NextRetryAt.After(time.Now()) {
+			t.Errorf("new delivery of s2 = %+v, %v; want it due", fresh, err)
+		}
+	})
+}
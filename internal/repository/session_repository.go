@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -41,13 +42,113 @@ func dbFieldsToProxyConfig(enabled bool, proxyType, host string, port int, usern
 	}
 }
 
+// sessionColumns lists the session_metadata columns read by scanSessionMetadata, in scan order
+const sessionColumns = `id, phone, actual_phone, name, position, webhook_url, auto_reply_text,
+		       proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
+		       enabled, user_id, created_at, webhook_proxy_url, webhook_legacy_format, webhook_secret,
+		       receive_receipts, receive_status, allow_view_once_capture, persist_media, webhook_events, webhook_suspended_at, banned_until, ban_reason, send_defaults,
+		       new_contact_since, new_contact_create_contact, auto_replies_enabled, sandbox, tags`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSessionMetadata scans a row selected with sessionColumns
+func scanSessionMetadata(row rowScanner) (*models.SessionMetadata, error) {
+	session := &models.SessionMetadata{}
+	
+	var createdAtUnix int64
+	var webhookSuspendedAt, bannedUntil, newContactSince sql.NullInt64
+	var autoReplyText, webhookProxyURL, webhookEvents, banReason, sendDefaults, tags sql.NullString
+	var proxyEnabled bool
+	var proxyType, proxyHost, proxyUsername, proxyPassword string
+	var proxyPort int
+	
+	err := row.Scan(
+		&session.ID,
+		&session.Phone,
+		&session.ActualPhone,
+		&session.Name,
+		&session.Position,
+		&session.WebhookURL,
+		&autoReplyText,
+		&proxyEnabled,
+		&proxyType,
+		&proxyHost,
+		&proxyPort,
+		&proxyUsername,
+		&proxyPassword,
+		&session.Enabled,
+		&session.UserID,
+		&createdAtUnix,
+		&webhookProxyURL,
+		&session.WebhookLegacyFormat,
+		&session.WebhookSecret,
+		&session.ReceiveReceipts,
+		&session.ReceiveStatus,
+		&session.AllowViewOnceCapture,
+		&session.PersistMedia,
+		&webhookEvents,
+		&webhookSuspendedAt,
+		&bannedUntil,
+		&banReason,
+		&sendDefaults,
+		&newContactSince,
+		&session.NewContactCreateContact,
+		&session.AutoRepliesEnabled,
+		&session.Sandbox,
+		&tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	session.CreatedAt = time.Unix(createdAtUnix, 0)
+	session.WebhookProxyURL = webhookProxyURL.String
+	if webhookEvents.Valid && webhookEvents.String != "" {
+		json.Unmarshal([]byte(webhookEvents.String), &session.WebhookEvents)
+	}
+	if webhookSuspendedAt.Valid {
+		suspendedAt := time.Unix(webhookSuspendedAt.Int64, 0)
+		session.WebhookSuspendedAt = &suspendedAt
+	}
+	if bannedUntil.Valid {
+		until := time.Unix(bannedUntil.Int64, 0)
+		session.BannedUntil = &until
+	}
+	session.BanReason = banReason.String
+	if sendDefaults.Valid && sendDefaults.String != "" {
+		session.SendDefaults = &models.SendOptions{}
+		json.Unmarshal([]byte(sendDefaults.String), session.SendDefaults)
+	}
+	if newContactSince.Valid {
+		since := time.Unix(newContactSince.Int64, 0)
+		session.NewContactSince = &since
+	}
+	if tags.Valid && tags.String != "" {
+		json.Unmarshal([]byte(tags.String), &session.Tags)
+	}
+	
+	// Handle nullable auto_reply_text
+	if autoReplyText.Valid {
+		session.AutoReplyText = &autoReplyText.String
+	}
+	
+	// Convert proxy fields to ProxyConfig
+	session.ProxyConfig = dbFieldsToProxyConfig(proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword)
+	
+	return session, nil
+}
+
 // Create creates a new session
 func (r *SessionRepository) Create(session *models.SessionMetadata) error {
 	query := `
 		INSERT INTO session_metadata (id, phone, actual_phone, name, position, webhook_url, auto_reply_text, 
 		                             proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
-		                             enabled, user_id, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                             enabled, user_id, created_at, webhook_proxy_url, webhook_legacy_format, webhook_secret,
+		                             receive_receipts, receive_status, allow_view_once_capture, persist_media, sandbox)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	// Convert proxy config to database fields
@@ -70,6 +171,14 @@ func (r *SessionRepository) Create(session *models.SessionMetadata) error {
 		session.Enabled,
 		session.UserID,
 		session.CreatedAt.Unix(),
+		session.WebhookProxyURL,
+		session.WebhookLegacyFormat,
+		session.WebhookSecret,
+		session.ReceiveReceipts,
+		session.ReceiveStatus,
+		session.AllowViewOnceCapture,
+		session.PersistMedia,
+		session.Sandbox,
 	)
 	
 	if err != nil {
@@ -81,40 +190,13 @@ func (r *SessionRepository) Create(session *models.SessionMetadata) error {
 
 // GetByID retrieves a session by ID
 func (r *SessionRepository) GetByID(id string) (*models.SessionMetadata, error) {
-	session := &models.SessionMetadata{}
 	query := `
-		SELECT id, phone, actual_phone, name, position, webhook_url, auto_reply_text,
-		       proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
-		       enabled, user_id, created_at
+		SELECT `+sessionColumns+`
 		FROM session_metadata
 		WHERE id = ?
 	`
 	
-	var createdAtUnix int64
-	var autoReplyText sql.NullString
-	var proxyEnabled bool
-	var proxyType, proxyHost, proxyUsername, proxyPassword string
-	var proxyPort int
-	
-	err := r.db.QueryRow(query, id).Scan(
-		&session.ID,
-		&session.Phone,
-		&session.ActualPhone,
-		&session.Name,
-		&session.Position,
-		&session.WebhookURL,
-		&autoReplyText,
-		&proxyEnabled,
-		&proxyType,
-		&proxyHost,
-		&proxyPort,
-		&proxyUsername,
-		&proxyPassword,
-		&session.Enabled,
-		&session.UserID,
-		&createdAtUnix,
-	)
-	
+	session, err := scanSessionMetadata(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -123,25 +205,13 @@ func (r *SessionRepository) GetByID(id string) (*models.SessionMetadata, error)
 		return nil, fmt.Errorf("failed to get session: %v", err)
 	}
 	
-	session.CreatedAt = time.Unix(createdAtUnix, 0)
-	
-	// Handle nullable auto_reply_text
-	if autoReplyText.Valid {
-		session.AutoReplyText = &autoReplyText.String
-	}
-	
-	// Convert proxy fields to ProxyConfig
-	session.ProxyConfig = dbFieldsToProxyConfig(proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword)
-	
 	return session, nil
 }
 
 // GetAll retrieves all sessions
 func (r *SessionRepository) GetAll() ([]*models.SessionMetadata, error) {
 	query := `
-		SELECT id, phone, actual_phone, name, position, webhook_url, auto_reply_text,
-		       proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
-		       enabled, user_id, created_at
+		SELECT `+sessionColumns+`
 		FROM session_metadata
 		ORDER BY position ASC, created_at DESC
 	`
@@ -154,47 +224,11 @@ func (r *SessionRepository) GetAll() ([]*models.SessionMetadata, error) {
 	
 	var sessions []*models.SessionMetadata
 	for rows.Next() {
-		session := &models.SessionMetadata{}
-		
-		var createdAtUnix int64
-		var autoReplyText sql.NullString
-		var proxyEnabled bool
-		var proxyType, proxyHost, proxyUsername, proxyPassword string
-		var proxyPort int
-		
-		err := rows.Scan(
-			&session.ID,
-			&session.Phone,
-			&session.ActualPhone,
-			&session.Name,
-			&session.Position,
-			&session.WebhookURL,
-			&autoReplyText,
-			&proxyEnabled,
-			&proxyType,
-			&proxyHost,
-			&proxyPort,
-			&proxyUsername,
-			&proxyPassword,
-			&session.Enabled,
-			&session.UserID,
-			&createdAtUnix,
-		)
-		
+		session, err := scanSessionMetadata(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %v", err)
 		}
 		
-		session.CreatedAt = time.Unix(createdAtUnix, 0)
-		
-		// Handle nullable auto_reply_text
-		if autoReplyText.Valid {
-			session.AutoReplyText = &autoReplyText.String
-		}
-		
-		// Convert proxy fields to ProxyConfig
-		session.ProxyConfig = dbFieldsToProxyConfig(proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword)
-		
 		sessions = append(sessions, session)
 	}
 	
@@ -207,7 +241,8 @@ func (r *SessionRepository) Update(session *models.SessionMetadata) error {
 		UPDATE session_metadata
 		SET phone = ?, actual_phone = ?, name = ?, position = ?, webhook_url = ?, auto_reply_text = ?,
 		    proxy_enabled = ?, proxy_type = ?, proxy_host = ?, proxy_port = ?, proxy_username = ?, proxy_password = ?,
-		    enabled = ?
+		    enabled = ?, webhook_proxy_url = ?, webhook_legacy_format = ?, webhook_secret = ?, receive_receipts = ?,
+		    receive_status = ?, allow_view_once_capture = ?, persist_media = ?
 		WHERE id = ? AND user_id = ?
 	`
 	
@@ -228,6 +263,13 @@ func (r *SessionRepository) Update(session *models.SessionMetadata) error {
 		proxyUsername,
 		proxyPassword,
 		session.Enabled,
+		session.WebhookProxyURL,
+		session.WebhookLegacyFormat,
+		session.WebhookSecret,
+		session.ReceiveReceipts,
+		session.ReceiveStatus,
+		session.AllowViewOnceCapture,
+		session.PersistMedia,
 		session.ID,
 		session.UserID,
 	)
@@ -239,6 +281,85 @@ func (r *SessionRepository) Update(session *models.SessionMetadata) error {
 	return nil
 }
 
+// UpdateSettings saves the user-editable settings of a session and returns
+// its new version. With expectedVersion set, the update only applies if the
+// session is still at that version; otherwise ErrVersionConflict is returned.
+func (r *SessionRepository) UpdateSettings(session *models.SessionMetadata, expectedVersion *int64) (int64, error) {
+	query := `
+		UPDATE session_metadata
+		SET name = ?, position = ?, webhook_url = ?, auto_reply_text = ?,
+		    proxy_enabled = ?, proxy_type = ?, proxy_host = ?, proxy_port = ?, proxy_username = ?, proxy_password = ?,
+		    enabled = ?, webhook_proxy_url = ?, webhook_legacy_format = ?, webhook_secret = ?, receive_receipts = ?,
+		    receive_status = ?, allow_view_once_capture = ?, persist_media = ?, ` + bumpVersion + `
+		WHERE id = ?
+	`
+
+	proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword := proxyConfigToDBFields(session.ProxyConfig)
+	args := []interface{}{
+		session.Name,
+		session.Position,
+		session.WebhookURL,
+		session.AutoReplyText,
+		proxyEnabled,
+		proxyType,
+		proxyHost,
+		proxyPort,
+		proxyUsername,
+		proxyPassword,
+		session.Enabled,
+		session.WebhookProxyURL,
+		session.WebhookLegacyFormat,
+		session.WebhookSecret,
+		session.ReceiveReceipts,
+		session.ReceiveStatus,
+		session.AllowViewOnceCapture,
+		session.PersistMedia,
+		time.Now().Unix(),
+		session.ID,
+	}
+	if expectedVersion != nil {
+		query += " AND " + versionColumn + " = ?"
+		args = append(args, *expectedVersion)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update session: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	// Every update advances the version, so no affected row means a missing session or a conflict
+	version, err := r.GetVersion(session.ID)
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return version, ErrVersionConflict
+	}
+
+	return version, nil
+}
+
+// GetVersion returns the current version of a session
+func (r *SessionRepository) GetVersion(id string) (int64, error) {
+	query := `SELECT ` + versionColumn + ` FROM session_metadata WHERE id = ?`
+
+	var version int64
+	err := r.db.QueryRow(query, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, models.NewNotFoundError("session %s not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get session version: %v", err)
+	}
+
+	return version, nil
+}
+
 // UpdateActualPhone updates the actual phone number after login
 func (r *SessionRepository) UpdateActualPhone(id, actualPhone string) error {
 	query := `UPDATE session_metadata SET actual_phone = ? WHERE id = ?`
@@ -253,9 +374,9 @@ func (r *SessionRepository) UpdateActualPhone(id, actualPhone string) error {
 
 // UpdateWebhook updates the webhook URL
 func (r *SessionRepository) UpdateWebhook(id, webhookURL string) error {
-	query := `UPDATE session_metadata SET webhook_url = ? WHERE id = ?`
+	query := `UPDATE session_metadata SET webhook_url = ?, ` + bumpVersion + ` WHERE id = ?`
 	
-	_, err := r.db.Exec(query, webhookURL, id)
+	_, err := r.db.Exec(query, webhookURL, time.Now().Unix(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update webhook: %v", err)
 	}
@@ -265,9 +386,9 @@ func (r *SessionRepository) UpdateWebhook(id, webhookURL string) error {
 
 // UpdateAutoReplyText updates the auto reply text
 func (r *SessionRepository) UpdateAutoReplyText(id string, autoReplyText *string) error {
-	query := `UPDATE session_metadata SET auto_reply_text = ? WHERE id = ?`
+	query := `UPDATE session_metadata SET auto_reply_text = ?, ` + bumpVersion + ` WHERE id = ?`
 	
-	_, err := r.db.Exec(query, autoReplyText, id)
+	_, err := r.db.Exec(query, autoReplyText, time.Now().Unix(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update auto reply text: %v", err)
 	}
@@ -275,11 +396,17 @@ func (r *SessionRepository) UpdateAutoReplyText(id string, autoReplyText *string
 	return nil
 }
 
-// UpdateSessionWebhook updates only the webhook URL for a session
-func (r *SessionRepository) UpdateSessionWebhook(id string, webhookURL string) error {
-	query := `UPDATE session_metadata SET webhook_url = ? WHERE id = ?`
+// UpdateSessionWebhook updates the webhook URL and secret of a session
+func (r *SessionRepository) UpdateSessionWebhook(id string, webhookURL, webhookSecret string, webhookEvents []string) error {
+	query := `UPDATE session_metadata SET webhook_url = ?, webhook_secret = ?, webhook_events = ?, ` + bumpVersion + ` WHERE id = ?`
 	
-	_, err := r.db.Exec(query, webhookURL, id)
+	var events interface{}
+	if len(webhookEvents) > 0 {
+		eventsJSON, _ := json.Marshal(webhookEvents)
+		events = string(eventsJSON)
+	}
+	
+	_, err := r.db.Exec(query, webhookURL, webhookSecret, events, time.Now().Unix(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update webhook URL: %v", err)
 	}
@@ -287,11 +414,134 @@ func (r *SessionRepository) UpdateSessionWebhook(id string, webhookURL string) e
 	return nil
 }
 
+// UpdateWebhookSuspended records when webhook delivery was suspended, or clears it when suspendedAt is nil
+func (r *SessionRepository) UpdateWebhookSuspended(id string, suspendedAt *time.Time) error {
+	query := `UPDATE session_metadata SET webhook_suspended_at = ? WHERE id = ?`
+	
+	var value interface{}
+	if suspendedAt != nil {
+		value = suspendedAt.Unix()
+	}
+	
+	_, err := r.db.Exec(query, value, id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook suspension: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateBan records or, with a nil until, clears a temporary WhatsApp ban
+func (r *SessionRepository) UpdateBan(id string, until *time.Time, reason string) error {
+	query := `UPDATE session_metadata SET banned_until = ?, ban_reason = ? WHERE id = ?`
+	
+	var value interface{}
+	if until != nil {
+		value = until.Unix()
+	}
+	
+	_, err := r.db.Exec(query, value, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to update session ban: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateSendDefaults stores the session's default send options; nil clears them
+func (r *SessionRepository) UpdateSendDefaults(id string, defaults *models.SendOptions) error {
+	query := `UPDATE session_metadata SET send_defaults = ?, ` + bumpVersion + ` WHERE id = ?`
+	
+	var value interface{}
+	if defaults != nil {
+		data, err := json.Marshal(defaults)
+		if err != nil {
+			return fmt.Errorf("failed to encode send defaults: %v", err)
+		}
+		value = string(data)
+	}
+	
+	_, err := r.db.Exec(query, value, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update send defaults: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateNewContactDetection stores the session's first-contact detection
+// settings; a nil since disables detection
+func (r *SessionRepository) UpdateNewContactDetection(id string, since *time.Time, createContact bool) error {
+	query := `UPDATE session_metadata SET new_contact_since = ?, new_contact_create_contact = ?, ` + bumpVersion + ` WHERE id = ?`
+	
+	var value interface{}
+	if since != nil {
+		value = since.Unix()
+	}
+	
+	_, err := r.db.Exec(query, value, createContact, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update new contact detection: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateAutoRepliesEnabled stores whether the session's auto-reply rules
+// answer incoming messages
+func (r *SessionRepository) UpdateAutoRepliesEnabled(id string, enabled bool) error {
+	query := `UPDATE session_metadata SET auto_replies_enabled = ?, ` + bumpVersion + ` WHERE id = ?`
+	
+	_, err := r.db.Exec(query, enabled, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update auto replies enabled: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateTags stores the session's tags; an empty list clears them
+func (r *SessionRepository) UpdateTags(id string, tags []string) error {
+	query := `UPDATE session_metadata SET tags = ?, ` + bumpVersion + ` WHERE id = ?`
+	
+	var value interface{}
+	if len(tags) > 0 {
+		data, _ := json.Marshal(tags)
+		value = string(data)
+	}
+	
+	_, err := r.db.Exec(query, value, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update session tags: %v", err)
+	}
+	
+	return nil
+}
+
+// UpdateProxyConfig stores the session's proxy; nil clears it
+func (r *SessionRepository) UpdateProxyConfig(id string, config *models.ProxyConfig) error {
+	query := `
+		UPDATE session_metadata
+		SET proxy_enabled = ?, proxy_type = ?, proxy_host = ?, proxy_port = ?, proxy_username = ?, proxy_password = ?,
+		    ` + bumpVersion + `
+		WHERE id = ?
+	`
+	
+	proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword := proxyConfigToDBFields(config)
+	
+	_, err := r.db.Exec(query, proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update session proxy: %v", err)
+	}
+	
+	return nil
+}
+
 // UpdateSessionEnabled updates the enabled status of a session
 func (r *SessionRepository) UpdateSessionEnabled(id string, enabled bool) error {
-	query := `UPDATE session_metadata SET enabled = ? WHERE id = ?`
+	query := `UPDATE session_metadata SET enabled = ?, ` + bumpVersion + ` WHERE id = ?`
 	
-	_, err := r.db.Exec(query, enabled, id)
+	_, err := r.db.Exec(query, enabled, time.Now().Unix(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update session enabled status: %v", err)
 	}
@@ -299,6 +549,53 @@ func (r *SessionRepository) UpdateSessionEnabled(id string, enabled bool) error
 	return nil
 }
 
+// UpdateUserID moves a session to another owner
+func (r *SessionRepository) UpdateUserID(id string, userID int) error {
+	query := `UPDATE session_metadata SET user_id = ?, ` + bumpVersion + ` WHERE id = ?`
+	
+	_, err := r.db.Exec(query, userID, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update session owner: %v", err)
+	}
+	
+	return nil
+}
+
+// GetOwners returns the owner and creation time of every session, newest
+// first, or only of the sessions of userID when it is not 0
+func (r *SessionRepository) GetOwners(userID int) ([]*models.SessionOwner, error) {
+	query := `
+		SELECT s.id, s.user_id, COALESCE(u.username, ''), s.created_at
+		FROM session_metadata s
+		LEFT JOIN users u ON u.id = s.user_id
+	`
+	var args []interface{}
+	if userID != 0 {
+		query += ` WHERE s.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY s.created_at DESC, s.id ASC`
+	
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session owners: %v", err)
+	}
+	defer rows.Close()
+	
+	var owners []*models.SessionOwner
+	for rows.Next() {
+		owner := &models.SessionOwner{}
+		var createdAtUnix int64
+		if err := rows.Scan(&owner.SessionID, &owner.UserID, &owner.Username, &createdAtUnix); err != nil {
+			return nil, fmt.Errorf("failed to scan session owner: %v", err)
+		}
+		owner.CreatedAt = time.Unix(createdAtUnix, 0)
+		owners = append(owners, owner)
+	}
+	
+	return owners, rows.Err()
+}
+
 // Delete deletes a session
 func (r *SessionRepository) Delete(id string) error {
 	query := `DELETE FROM session_metadata WHERE id = ?`
@@ -344,9 +641,7 @@ func (r *SessionRepository) GetNextPosition() (int, error) {
 // GetByUserID retrieves all sessions for a specific user
 func (r *SessionRepository) GetByUserID(userID int) ([]*models.SessionMetadata, error) {
 	query := `
-		SELECT id, phone, actual_phone, name, position, webhook_url, auto_reply_text,
-		       proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
-		       enabled, user_id, created_at
+		SELECT `+sessionColumns+`
 		FROM session_metadata
 		WHERE user_id = ?
 		ORDER BY position ASC, created_at DESC
@@ -360,47 +655,11 @@ func (r *SessionRepository) GetByUserID(userID int) ([]*models.SessionMetadata,
 	
 	var sessions []*models.SessionMetadata
 	for rows.Next() {
-		session := &models.SessionMetadata{}
-		
-		var createdAtUnix int64
-		var autoReplyText sql.NullString
-		var proxyEnabled bool
-		var proxyType, proxyHost, proxyUsername, proxyPassword string
-		var proxyPort int
-		
-		err := rows.Scan(
-			&session.ID,
-			&session.Phone,
-			&session.ActualPhone,
-			&session.Name,
-			&session.Position,
-			&session.WebhookURL,
-			&autoReplyText,
-			&proxyEnabled,
-			&proxyType,
-			&proxyHost,
-			&proxyPort,
-			&proxyUsername,
-			&proxyPassword,
-			&session.Enabled,
-			&session.UserID,
-			&createdAtUnix,
-		)
-		
+		session, err := scanSessionMetadata(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %v", err)
 		}
 		
-		session.CreatedAt = time.Unix(createdAtUnix, 0)
-		
-		// Handle nullable auto_reply_text
-		if autoReplyText.Valid {
-			session.AutoReplyText = &autoReplyText.String
-		}
-		
-		// Convert proxy fields to ProxyConfig
-		session.ProxyConfig = dbFieldsToProxyConfig(proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword)
-		
 		sessions = append(sessions, session)
 	}
 	
@@ -409,40 +668,13 @@ func (r *SessionRepository) GetByUserID(userID int) ([]*models.SessionMetadata,
 
 // GetByIDAndUserID retrieves a session by ID and user ID
 func (r *SessionRepository) GetByIDAndUserID(id string, userID int) (*models.SessionMetadata, error) {
-	session := &models.SessionMetadata{}
 	query := `
-		SELECT id, phone, actual_phone, name, position, webhook_url, auto_reply_text,
-		       proxy_enabled, proxy_type, proxy_host, proxy_port, proxy_username, proxy_password,
-		       enabled, user_id, created_at
+		SELECT `+sessionColumns+`
 		FROM session_metadata
 		WHERE id = ? AND user_id = ?
 	`
 	
-	var createdAtUnix int64
-	var autoReplyText sql.NullString
-	var proxyEnabled bool
-	var proxyType, proxyHost, proxyUsername, proxyPassword string
-	var proxyPort int
-	
-	err := r.db.QueryRow(query, id, userID).Scan(
-		&session.ID,
-		&session.Phone,
-		&session.ActualPhone,
-		&session.Name,
-		&session.Position,
-		&session.WebhookURL,
-		&autoReplyText,
-		&proxyEnabled,
-		&proxyType,
-		&proxyHost,
-		&proxyPort,
-		&proxyUsername,
-		&proxyPassword,
-		&session.Enabled,
-		&session.UserID,
-		&createdAtUnix,
-	)
-	
+	session, err := scanSessionMetadata(r.db.QueryRow(query, id, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -451,16 +683,6 @@ func (r *SessionRepository) GetByIDAndUserID(id string, userID int) (*models.Ses
 		return nil, fmt.Errorf("failed to get session: %v", err)
 	}
 	
-	session.CreatedAt = time.Unix(createdAtUnix, 0)
-	
-	// Handle nullable auto_reply_text
-	if autoReplyText.Valid {
-		session.AutoReplyText = &autoReplyText.String
-	}
-	
-	// Convert proxy fields to ProxyConfig
-	session.ProxyConfig = dbFieldsToProxyConfig(proxyEnabled, proxyType, proxyHost, proxyPort, proxyUsername, proxyPassword)
-	
 	return session, nil
 }
 
@@ -485,10 +707,11 @@ func (r *SessionRepository) DeleteByIDAndUserID(id string, userID int) error {
 	return nil
 }
 
-// CountByUserID returns the total number of sessions for a user
+// CountByUserID returns the number of sessions a user has against the session
+// limit; sandbox sessions are not counted
 func (r *SessionRepository) CountByUserID(userID int) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM session_metadata WHERE user_id = ?`
+	query := `SELECT COUNT(*) FROM session_metadata WHERE user_id = ? AND sandbox = FALSE`
 	
 	err := r.db.QueryRow(query, userID).Scan(&count)
 	if err != nil {
@@ -21,25 +21,17 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 // Create creates a new user
 func (r *UserRepository) Create(user *models.User) error {
 	query := `
-		INSERT INTO users (username, password_hash, api_key, role, session_limit, is_active, created_at)
+		INSERT INTO users (username, password_hash, role, session_limit, is_active, default_region, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	
-	// Handle NULL API key - pass nil if API key is empty
-	var apiKeyValue interface{}
-	if user.APIKey == "" {
-		apiKeyValue = nil
-	} else {
-		apiKeyValue = user.APIKey
-	}
-	
 	result, err := r.db.Exec(query, 
 		user.Username, 
 		user.Password,
-		apiKeyValue,
 		user.Role, 
 		user.SessionLimit, 
 		user.IsActive,
+		nullableString(user.DefaultRegion),
 		user.CreatedAt.Unix(),
 	)
 	
@@ -60,24 +52,26 @@ func (r *UserRepository) Create(user *models.User) error {
 func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, password_hash, api_key, role, session_limit, is_active, created_at, updated_at
+		SELECT id, username, password_hash, role, session_limit, is_active, default_region, created_at, updated_at, tokens_invalidated_at
 		FROM users
 		WHERE username = ?
 	`
 	
 	var createdAtUnix int64
 	var updatedAtUnix sql.NullInt64
-	var apiKey sql.NullString
+	var defaultRegion sql.NullString
+	var tokensInvalidatedUnix sql.NullInt64
 	err := r.db.QueryRow(query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
-		&apiKey,
 		&user.Role,
 		&user.SessionLimit,
 		&user.IsActive,
+		&defaultRegion,
 		&createdAtUnix,
 		&updatedAtUnix,
+		&tokensInvalidatedUnix,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -89,12 +83,14 @@ func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 	}
 	
 	user.CreatedAt = time.Unix(createdAtUnix, 0)
+	user.DefaultRegion = defaultRegion.String
 	if updatedAtUnix.Valid {
 		updatedTime := time.Unix(updatedAtUnix.Int64, 0)
 		user.UpdatedAt = &updatedTime
 	}
-	if apiKey.Valid {
-		user.APIKey = apiKey.String
+	if tokensInvalidatedUnix.Valid {
+		invalidatedAt := time.UnixMicro(tokensInvalidatedUnix.Int64)
+		user.TokensInvalidatedAt = &invalidatedAt
 	}
 	
 	return user, nil
@@ -104,24 +100,26 @@ func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 func (r *UserRepository) GetByID(id int) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, password_hash, api_key, role, session_limit, is_active, created_at, updated_at
+		SELECT id, username, password_hash, role, session_limit, is_active, default_region, created_at, updated_at, tokens_invalidated_at
 		FROM users
 		WHERE id = ?
 	`
 	
 	var createdAtUnix int64
 	var updatedAtUnix sql.NullInt64
-	var apiKey sql.NullString
+	var defaultRegion sql.NullString
+	var tokensInvalidatedUnix sql.NullInt64
 	err := r.db.QueryRow(query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
-		&apiKey,
 		&user.Role,
 		&user.SessionLimit,
 		&user.IsActive,
+		&defaultRegion,
 		&createdAtUnix,
 		&updatedAtUnix,
+		&tokensInvalidatedUnix,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -133,56 +131,14 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 	}
 	
 	user.CreatedAt = time.Unix(createdAtUnix, 0)
+	user.DefaultRegion = defaultRegion.String
 	if updatedAtUnix.Valid {
 		updatedTime := time.Unix(updatedAtUnix.Int64, 0)
 		user.UpdatedAt = &updatedTime
 	}
-	if apiKey.Valid {
-		user.APIKey = apiKey.String
-	}
-	
-	return user, nil
-}
-
-// GetByAPIKey retrieves a user by API key
-func (r *UserRepository) GetByAPIKey(apiKey string) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, username, password_hash, api_key, role, session_limit, is_active, created_at, updated_at
-		FROM users
-		WHERE api_key = ?
-	`
-	
-	var createdAtUnix int64
-	var updatedAtUnix sql.NullInt64
-	var userAPIKey sql.NullString
-	err := r.db.QueryRow(query, apiKey).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Password,
-		&userAPIKey,
-		&user.Role,
-		&user.SessionLimit,
-		&user.IsActive,
-		&createdAtUnix,
-		&updatedAtUnix,
-	)
-	
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user by API key: %v", err)
-	}
-	
-	user.CreatedAt = time.Unix(createdAtUnix, 0)
-	if updatedAtUnix.Valid {
-		updatedTime := time.Unix(updatedAtUnix.Int64, 0)
-		user.UpdatedAt = &updatedTime
-	}
-	if userAPIKey.Valid {
-		user.APIKey = userAPIKey.String
+	if tokensInvalidatedUnix.Valid {
+		invalidatedAt := time.UnixMicro(tokensInvalidatedUnix.Int64)
+		user.TokensInvalidatedAt = &invalidatedAt
 	}
 	
 	return user, nil
@@ -195,7 +151,7 @@ func (r *UserRepository) Update(user *models.User) error {
 	
 	query := `
 		UPDATE users
-		SET username = ?, password_hash = ?, role = ?, session_limit = ?, is_active = ?, updated_at = ?
+		SET username = ?, password_hash = ?, role = ?, session_limit = ?, is_active = ?, default_region = ?, updated_at = ?
 		WHERE id = ?
 	`
 	
@@ -205,6 +161,7 @@ func (r *UserRepository) Update(user *models.User) error {
 		user.Role,
 		user.SessionLimit,
 		user.IsActive,
+		nullableString(user.DefaultRegion),
 		user.UpdatedAt.Unix(),
 		user.ID,
 	)
@@ -216,33 +173,58 @@ func (r *UserRepository) Update(user *models.User) error {
 	return nil
 }
 
-// UpdateAPIKey updates a user's API key
-func (r *UserRepository) UpdateAPIKey(userID int, apiKey string) error {
+// GetSegmentThresholds returns a user's contact segment thresholds, falling back to the defaults
+func (r *UserRepository) GetSegmentThresholds(userID int) (models.SegmentThresholds, error) {
+	thresholds := models.DefaultSegmentThresholds()
+	
 	query := `
-		UPDATE users
-		SET api_key = ?, updated_at = ?
+		SELECT segment_engaged_min_score, segment_dormant_days
+		FROM users
 		WHERE id = ?
 	`
 	
-	_, err := r.db.Exec(query, apiKey, time.Now().Unix(), userID)
+	var engagedMinScore, dormantDays sql.NullInt64
+	err := r.db.QueryRow(query, userID).Scan(&engagedMinScore, &dormantDays)
+	if err == sql.ErrNoRows {
+		return thresholds, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update API key: %v", err)
+		return thresholds, fmt.Errorf("failed to get segment thresholds: %v", err)
 	}
 	
-	return nil
+	if engagedMinScore.Valid {
+		thresholds.EngagedMinScore = int(engagedMinScore.Int64)
+	}
+	if dormantDays.Valid {
+		thresholds.DormantAfterDays = int(dormantDays.Int64)
+	}
+	
+	return thresholds, nil
 }
 
-// RemoveAPIKey removes a user's API key
-func (r *UserRepository) RemoveAPIKey(userID int) error {
+// UpdateSegmentThresholds stores a user's contact segment thresholds; nil values reset to the defaults
+func (r *UserRepository) UpdateSegmentThresholds(userID int, engagedMinScore, dormantDays *int) error {
 	query := `
 		UPDATE users
-		SET api_key = NULL, updated_at = ?
+		SET segment_engaged_min_score = ?, segment_dormant_days = ?, updated_at = ?
 		WHERE id = ?
 	`
 	
-	_, err := r.db.Exec(query, time.Now().Unix(), userID)
+	_, err := r.db.Exec(query, nullableInt(engagedMinScore), nullableInt(dormantDays), time.Now().Unix(), userID)
 	if err != nil {
-		return fmt.Errorf("failed to remove API key: %v", err)
+		return fmt.Errorf("failed to update segment thresholds: %v", err)
+	}
+	
+	return nil
+}
+
+// InvalidateTokens makes the access and refresh tokens issued to a user
+// before at invalid. The time is kept in microseconds, unlike the others.
+func (r *UserRepository) InvalidateTokens(userID int, at time.Time) error {
+	query := `UPDATE users SET tokens_invalidated_at = ? WHERE id = ?`
+	
+	if _, err := r.db.Exec(query, at.UnixMicro(), userID); err != nil {
+		return fmt.Errorf("failed to invalidate tokens: %v", err)
 	}
 	
 	return nil
@@ -263,7 +245,7 @@ func (r *UserRepository) Delete(id int) error {
 // GetAll retrieves all users
 func (r *UserRepository) GetAll() ([]*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, role, session_limit, is_active, created_at, updated_at
+		SELECT id, username, password_hash, role, session_limit, is_active, default_region, created_at, updated_at, tokens_invalidated_at
 		FROM users
 		ORDER BY created_at DESC
 	`
@@ -279,6 +261,8 @@ func (r *UserRepository) GetAll() ([]*models.User, error) {
 		user := &models.User{}
 		var createdAtUnix int64
 		var updatedAtUnix sql.NullInt64
+		var defaultRegion sql.NullString
+		var tokensInvalidatedUnix sql.NullInt64
 		
 		err := rows.Scan(
 			&user.ID,
@@ -287,8 +271,10 @@ func (r *UserRepository) GetAll() ([]*models.User, error) {
 			&user.Role,
 			&user.SessionLimit,
 			&user.IsActive,
+			&defaultRegion,
 			&createdAtUnix,
 			&updatedAtUnix,
+			&tokensInvalidatedUnix,
 		)
 		
 		if err != nil {
@@ -296,10 +282,15 @@ func (r *UserRepository) GetAll() ([]*models.User, error) {
 		}
 		
 		user.CreatedAt = time.Unix(createdAtUnix, 0)
+		user.DefaultRegion = defaultRegion.String
 		if updatedAtUnix.Valid {
 			updatedTime := time.Unix(updatedAtUnix.Int64, 0)
 			user.UpdatedAt = &updatedTime
 		}
+		if tokensInvalidatedUnix.Valid {
+			invalidatedAt := time.UnixMicro(tokensInvalidatedUnix.Int64)
+			user.TokensInvalidatedAt = &invalidatedAt
+		}
 		
 		users = append(users, user)
 	}
@@ -318,4 +309,19 @@ func (r *UserRepository) CountByRole(role string) (int, error) {
 	}
 	
 	return count, nil
-}
\ No newline at end of file
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullableInt(value *int) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,15 @@
+package repository
+
+import "errors"
+
+// ErrVersionConflict is returned by conditional updates when the row was
+// changed since the version the caller expected
+var ErrVersionConflict = errors.New("version conflict")
+
+// Rows that support optimistic concurrency use their updated_at (unix
+// seconds, falling back to created_at) as version. Updates advance it by at
+// least one, so two changes within the same second still get distinct versions.
+const (
+	versionColumn = "COALESCE(updated_at, created_at)"
+	bumpVersion   = "updated_at = GREATEST(?, COALESCE(updated_at, created_at) + 1)"
+)
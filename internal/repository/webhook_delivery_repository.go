@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+const webhookDeliveryColumns = `d.id, d.session_id, d.event_type, d.payload, d.status, d.attempts, d.next_retry_at,
+	d.expires_at, d.last_error, d.created_at, d.delivered_at`
+
+// WebhookDeliveryRepository stores the webhook delivery queue
+type WebhookDeliveryRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookDeliveryRepository creates a new webhook delivery repository
+func NewWebhookDeliveryRepository(db *sql.DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{db: db}
+}
+
+// Enqueue queues a webhook payload for delivery as soon as possible: at once,
+// or once the session's pending deliveries are due if they are postponed, so
+// it does not go out ahead of them
+func (r *WebhookDeliveryRepository) Enqueue(sessionID, eventType string, payload []byte, expiresAt time.Time) error {
+	query := `
+		INSERT INTO webhook_deliveries (session_id, event_type, payload, status, attempts, next_retry_at, expires_at, created_at)
+		SELECT ?, ?, ?, ?, 0, COALESCE(MAX(CASE WHEN next_retry_at > ? THEN next_retry_at END), ?), ?, ?
+		FROM webhook_deliveries
+		WHERE session_id = ? AND status = ?
+	`
+
+	now := time.Now().Unix()
+	_, err := r.db.Exec(query, sessionID, eventType, string(payload), models.WebhookDeliveryPending, now, now, expiresAt.Unix(), now,
+		sessionID, models.WebhookDeliveryPending)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook delivery: %v", err)
+	}
+
+	return nil
+}
+
+// ClaimDue leases up to limit pending deliveries due at now until leaseUntil
+// and returns them, oldest first. Deliveries of sessions whose webhook is
+// suspended, or with a delivery leased to another worker, are left out. Each
+// delivery is claimed by comparing its lease, so when several instances poll
+// at once every delivery is claimed by only one.
+func (r *WebhookDeliveryRepository) ClaimDue(now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN session_metadata s ON s.id = d.session_id
+		WHERE d.status = ? AND d.next_retry_at <= ? AND s.webhook_suspended_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM webhook_deliveries l
+			WHERE l.session_id = d.session_id AND l.status = ? AND l.lease_until > ?
+		  )
+		ORDER BY d.id
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, models.WebhookDeliveryPending, now.Unix(), models.WebhookDeliveryPending, now.Unix(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due webhook deliveries: %v", err)
+	}
+	due, err := scanWebhookDeliveries(rows)
+	rows.Close()
+	if err != nil || len(due) == 0 {
+		return due, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	claimed := make([]*models.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		result, err := tx.Exec(`
+			UPDATE webhook_deliveries SET lease_until = ?
+			WHERE id = ? AND status = ? AND next_retry_at <= ? AND (lease_until IS NULL OR lease_until <= ?)`,
+			leaseUntil.Unix(), delivery.ID, models.WebhookDeliveryPending, now.Unix(), now.Unix(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim webhook delivery %d: %v", delivery.ID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			claimed = append(claimed, delivery)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return claimed, nil
+}
+
+// Unclaim gives back the pending deliveries of ids leased until leaseUntil,
+// so they can be claimed again at once
+func (r *WebhookDeliveryRepository) Unclaim(ids []int64, leaseUntil time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := []interface{}{models.WebhookDeliveryPending, leaseUntil.Unix()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := r.db.Exec(`UPDATE webhook_deliveries SET lease_until = NULL WHERE status = ? AND lease_until = ? AND id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to release webhook deliveries: %v", err)
+	}
+
+	return nil
+}
+
+// Get returns a delivery by ID
+func (r *WebhookDeliveryRepository) Get(id int64) (*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery %d: %v", id, err)
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, models.NewNotFoundError("webhook delivery %d not found", id)
+	}
+	return deliveries[0], nil
+}
+
+// List returns a page of deliveries, newest first, only those with status
+// and of sessionID unless they are empty
+func (r *WebhookDeliveryRepository) List(status, sessionID string, offset, limit int) ([]*models.WebhookDelivery, int, error) {
+	where := "WHERE 1 = 1"
+	var args []interface{}
+	if status != "" {
+		where += " AND d.status = ?"
+		args = append(args, status)
+	}
+	if sessionID != "" {
+		where += " AND d.session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries d "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %v", err)
+	}
+
+	rows, err := r.db.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries d "+where+" ORDER BY d.id DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// MarkDelivered records that a delivery succeeded on its attempts-th attempt
+func (r *WebhookDeliveryRepository) MarkDelivered(id int64, attempts int) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, last_error = NULL, delivered_at = ? WHERE id = ?`
+
+	_, err := r.db.Exec(query, models.WebhookDeliveryDelivered, attempts, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivery %d delivered: %v", id, err)
+	}
+
+	return nil
+}
+
+// MarkRetry records a failed attempt of a delivery that is retried at nextRetryAt
+func (r *WebhookDeliveryRepository) MarkRetry(id int64, attempts int, nextRetryAt time.Time, lastError string) error {
+	query := `UPDATE webhook_deliveries SET attempts = ?, next_retry_at = ?, last_error = ?, lease_until = NULL WHERE id = ?`
+
+	_, err := r.db.Exec(query, attempts, nextRetryAt.Unix(), lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery %d: %v", id, err)
+	}
+
+	return nil
+}
+
+// MarkFailed gives up on a delivery after attempts attempts
+func (r *WebhookDeliveryRepository) MarkFailed(id int64, attempts int, lastError string) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, last_error = ? WHERE id = ?`
+
+	_, err := r.db.Exec(query, models.WebhookDeliveryFailed, attempts, lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivery %d failed: %v", id, err)
+	}
+
+	return nil
+}
+
+// PostponeSession moves the pending deliveries of a session due before until
+// to until, so they stay behind one that is being retried, and gives them back
+// to be claimed then
+func (r *WebhookDeliveryRepository) PostponeSession(sessionID string, until time.Time) error {
+	query := `UPDATE webhook_deliveries SET next_retry_at = ?, lease_until = NULL WHERE session_id = ? AND status = ? AND next_retry_at < ?`
+
+	_, err := r.db.Exec(query, until.Unix(), sessionID, models.WebhookDeliveryPending, until.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to postpone webhook deliveries of session %s: %v", sessionID, err)
+	}
+
+	return nil
+}
+
+// Retry queues a failed delivery again as if it were new, to be sent at once
+// and given up on at expiresAt. Its last error is kept until it is sent.
+func (r *WebhookDeliveryRepository) Retry(id int64, expiresAt time.Time) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = 0, next_retry_at = ?, expires_at = ?, lease_until = NULL WHERE id = ? AND status = ?`
+
+	result, err := r.db.Exec(query, models.WebhookDeliveryPending, time.Now().Unix(), expiresAt.Unix(), id, models.WebhookDeliveryFailed)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery %d: %v", id, err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		delivery, err := r.Get(id)
+		if err != nil {
+			return err
+		}
+		return models.NewBadRequestError("webhook delivery %d is %s, only failed deliveries can be retried", id, delivery.Status)
+	}
+	return nil
+}
+
+// ReleaseSession makes the pending deliveries of a session due at once and
+// gives up on them at expiresAt. It returns how many there are.
+func (r *WebhookDeliveryRepository) ReleaseSession(sessionID string, expiresAt time.Time) (int64, error) {
+	query := `UPDATE webhook_deliveries SET next_retry_at = ?, expires_at = ?, lease_until = NULL WHERE session_id = ? AND status = ?`
+
+	result, err := r.db.Exec(query, time.Now().Unix(), expiresAt.Unix(), sessionID, models.WebhookDeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release webhook deliveries of session %s: %v", sessionID, err)
+	}
+
+	return result.RowsAffected()
+}
+
+// DiscardSession gives up on the pending deliveries of a session with reason.
+// It returns how many there were.
+func (r *WebhookDeliveryRepository) DiscardSession(sessionID, reason string) (int64, error) {
+	query := `UPDATE webhook_deliveries SET status = ?, last_error = ? WHERE session_id = ? AND status = ?`
+
+	result, err := r.db.Exec(query, models.WebhookDeliveryFailed, reason, sessionID, models.WebhookDeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to discard webhook deliveries of session %s: %v", sessionID, err)
+	}
+
+	return result.RowsAffected()
+}
+
+// PruneDelivered deletes the deliveries delivered before before and returns how many
+func (r *WebhookDeliveryRepository) PruneDelivered(before time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ?`
+
+	result, err := r.db.Exec(query, models.WebhookDeliveryDelivered, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook deliveries: %v", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		var payload string
+		var lastError sql.NullString
+		var nextRetryAt, expiresAt, createdAt int64
+		var deliveredAt sql.NullInt64
+		err := rows.Scan(&delivery.ID, &delivery.SessionID, &delivery.EventType, &payload, &delivery.Status,
+			&delivery.Attempts, &nextRetryAt, &expiresAt, &lastError, &createdAt, &deliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+
+		delivery.Payload = json.RawMessage(payload)
+		delivery.NextRetryAt = time.Unix(nextRetryAt, 0)
+		delivery.ExpiresAt = time.Unix(expiresAt, 0)
+		delivery.LastError = lastError.String
+		delivery.CreatedAt = time.Unix(createdAt, 0)
+		if deliveredAt.Valid {
+			at := time.Unix(deliveredAt.Int64, 0)
+			delivery.DeliveredAt = &at
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
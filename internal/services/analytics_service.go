@@ -34,7 +34,7 @@ type AnalyticsData struct {
 }
 
 // GetAnalytics retrieves comprehensive analytics data
-func (s *AnalyticsService) GetAnalytics(userId int64, isAdmin bool, timeRange string) (*AnalyticsData, error) {
+func (s *AnalyticsService) GetAnalytics(userId int64, isAdmin bool, timeRange string, includeSandbox bool) (*AnalyticsData, error) {
 	s.log.Info("Fetching analytics for user %d (admin: %v) with time range: %s", userId, isAdmin, timeRange)
 	
 	// If not admin, use the userId for filtering
@@ -44,14 +44,14 @@ func (s *AnalyticsService) GetAnalytics(userId int64, isAdmin bool, timeRange st
 	}
 	
 	// Get message statistics
-	messageStats, err := s.analyticsRepo.GetMessageStats(filterUserId, timeRange)
+	messageStats, err := s.analyticsRepo.GetMessageStats(filterUserId, timeRange, includeSandbox)
 	if err != nil {
 		s.log.Error("Failed to get message stats: %v", err)
 		return nil, err
 	}
 	
 	// Get session statistics
-	sessionStats, err := s.analyticsRepo.GetSessionStats(filterUserId)
+	sessionStats, err := s.analyticsRepo.GetSessionStats(filterUserId, includeSandbox)
 	if err != nil {
 		s.log.Error("Failed to get session stats: %v", err)
 		return nil, err
@@ -81,21 +81,21 @@ func (s *AnalyticsService) GetAnalytics(userId int64, isAdmin bool, timeRange st
 	}
 	
 	// Get message time series
-	messageTrend, err := s.analyticsRepo.GetMessageTimeSeries(filterUserId, timeRange, interval)
+	messageTrend, err := s.analyticsRepo.GetMessageTimeSeries(filterUserId, timeRange, interval, includeSandbox)
 	if err != nil {
 		s.log.Error("Failed to get message trend: %v", err)
 		messageTrend = []repository.TimeSeriesData{} // Return empty array instead of failing
 	}
 	
 	// Get top contacts
-	topContacts, err := s.analyticsRepo.GetTopContacts(filterUserId, 10)
+	topContacts, err := s.analyticsRepo.GetTopContacts(filterUserId, 10, includeSandbox)
 	if err != nil {
 		s.log.Error("Failed to get top contacts: %v", err)
 		topContacts = []map[string]interface{}{} // Return empty array instead of failing
 	}
 	
 	// Get session activity
-	sessionActivity, err := s.analyticsRepo.GetSessionActivity(filterUserId, 10)
+	sessionActivity, err := s.analyticsRepo.GetSessionActivity(filterUserId, 10, includeSandbox)
 	if err != nil {
 		s.log.Error("Failed to get session activity: %v", err)
 		sessionActivity = []map[string]interface{}{} // Return empty array instead of failing
@@ -115,23 +115,23 @@ func (s *AnalyticsService) GetAnalytics(userId int64, isAdmin bool, timeRange st
 }
 
 // GetMessageStats retrieves only message statistics
-func (s *AnalyticsService) GetMessageStats(userId int64, isAdmin bool, timeRange string) (*repository.MessageStats, error) {
+func (s *AnalyticsService) GetMessageStats(userId int64, isAdmin bool, timeRange string, includeSandbox bool) (*repository.MessageStats, error) {
 	filterUserId := userId
 	if isAdmin {
 		filterUserId = 0
 	}
 	
-	return s.analyticsRepo.GetMessageStats(filterUserId, timeRange)
+	return s.analyticsRepo.GetMessageStats(filterUserId, timeRange, includeSandbox)
 }
 
 // GetSessionStats retrieves only session statistics
-func (s *AnalyticsService) GetSessionStats(userId int64, isAdmin bool) (*repository.SessionStats, error) {
+func (s *AnalyticsService) GetSessionStats(userId int64, isAdmin bool, includeSandbox bool) (*repository.SessionStats, error) {
 	filterUserId := userId
 	if isAdmin {
 		filterUserId = 0
 	}
 
-	return s.analyticsRepo.GetSessionStats(filterUserId)
+	return s.analyticsRepo.GetSessionStats(filterUserId, includeSandbox)
 }
 
 // updateSessionConnectionStatus updates the connection status of sessions based on actual WhatsApp service data
@@ -146,7 +146,7 @@ func (s *AnalyticsService) updateSessionConnectionStatus(sessionActivity []map[s
 	// Create a map for quick lookup
 	sessionMap := make(map[string]bool)
 	for _, session := range sessions {
-		sessionMap[session.ID] = session.Connected
+		sessionMap[session.ID] = session.IsConnected()
 	}
 
 	// Update the connection status in session activity
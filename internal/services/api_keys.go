@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/utils"
+)
+
+// defaultAPIKeyName names the full-access key managed by the single-key
+// endpoints under /api/auth/api-key
+const defaultAPIKeyName = "default"
+
+// apiKeyTouchInterval is how often last_used_at of a key in steady use is
+// written, rather than on every request
+const apiKeyTouchInterval = time.Minute
+
+// maxAPIKeyNameLength matches the api_keys.name column
+const maxAPIKeyNameLength = 100
+
+// CreateAPIKey creates a named key for a user, limited to the requested
+// scopes, sessions and rate. The key itself is only returned here.
+func (s *UserService) CreateAPIKey(userID int, req *models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, models.NewBadRequestError("name is required")
+	}
+	if len(name) > maxAPIKeyNameLength {
+		return nil, models.NewBadRequestError("name must be at most %d characters", maxAPIKeyNameLength)
+	}
+	if req.RateLimit < 0 {
+		return nil, models.NewBadRequestError("rate_limit must be 0 (unlimited) or more")
+	}
+
+	scopes, err := normalizeAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []string
+	for _, sessionID := range req.Sessions {
+		sessionID = strings.TrimSpace(sessionID)
+		if sessionID != "" && !containsString(sessions, sessionID) {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	if len(req.Sessions) > 0 && len(sessions) == 0 {
+		return nil, models.NewBadRequestError("sessions must name at least one session, or be left out to allow all")
+	}
+
+	existing, err := s.apiKeyRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range existing {
+		if strings.EqualFold(key.Name, name) {
+			return nil, models.NewBadRequestError("an API key named %s already exists", key.Name)
+		}
+	}
+
+	return s.createAPIKey(userID, name, scopes, sessions, req.RateLimit)
+}
+
+// createAPIKey generates and stores a key
+func (s *UserService) createAPIKey(userID int, name string, scopes, sessions []string, rateLimit int) (*models.CreatedAPIKey, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return nil, models.NewNotFoundError("user %d not found", userID)
+	}
+
+	apiKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Hint:      utils.APIKeyHint(apiKey),
+		Scopes:    scopes,
+		Sessions:  sessions,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now(),
+		KeyHash:   utils.HashAPIKey(apiKey),
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created API key %s (%s) for user %s (ID: %d) with scopes %s",
+		key.Name, key.Hint, user.Username, userID, strings.Join(scopes, ","))
+	return &models.CreatedAPIKey{APIKey: key, Key: apiKey}, nil
+}
+
+// normalizeAPIKeyScopes validates requested scopes, defaulting to full access
+func normalizeAPIKeyScopes(requested []string) ([]string, error) {
+	var scopes []string
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if !models.IsAPIKeyScope(scope) {
+			return nil, models.NewBadRequestError("unknown scope %q, expected one of %s", scope, strings.Join(models.APIKeyScopes, ", "))
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 || containsString(scopes, models.ScopeAll) {
+		return []string{models.ScopeAll}, nil
+	}
+	return scopes, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ListAPIKeys returns the keys of a user without the keys themselves
+func (s *UserService) ListAPIKeys(userID int) ([]*models.APIKey, error) {
+	return s.apiKeyRepo.GetByUserID(userID)
+}
+
+// DeleteAPIKey revokes one key of a user
+func (s *UserService) DeleteAPIKey(userID int, keyID int64) error {
+	deleted, err := s.apiKeyRepo.Delete(userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return models.NewNotFoundError("API key %d not found", keyID)
+	}
+
+	s.logger.Info("Revoked API key %d of user %d", keyID, userID)
+	return nil
+}
+
+// GenerateAPIKey replaces the default full-access key of a user
+func (s *UserService) GenerateAPIKey(userID int) (*models.APIKeyResponse, error) {
+	if err := s.apiKeyRepo.DeleteByName(userID, defaultAPIKeyName); err != nil {
+		return nil, err
+	}
+	created, err := s.createAPIKey(userID, defaultAPIKeyName, []string{models.ScopeAll}, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyResponse{
+		Success: true,
+		Message: "API key generated successfully",
+		APIKey:  created.Key,
+	}, nil
+}
+
+// RevokeAPIKey revokes every key of a user
+func (s *UserService) RevokeAPIKey(userID int) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	revoked, err := s.apiKeyRepo.DeleteByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API keys: %v", err)
+	}
+
+	s.logger.Info("Revoked %d API key(s) of user %s (ID: %d)", revoked, user.Username, userID)
+	return nil
+}
+
+// GetAPIKeyInfo summarizes the keys of a user (without the keys themselves)
+func (s *UserService) GetAPIKeyInfo(userID int) (*models.APIKeyInfo, error) {
+	keys, err := s.apiKeyRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.APIKeyInfo{HasKey: len(keys) > 0, Keys: len(keys)}
+	for _, key := range keys {
+		if key.CreatedAt.After(info.CreatedAt) {
+			info.CreatedAt = key.CreatedAt
+		}
+		if key.LastUsedAt != nil && (info.LastUsed == nil || key.LastUsedAt.After(*info.LastUsed)) {
+			info.LastUsed = key.LastUsedAt
+		}
+	}
+	return info, nil
+}
+
+// AuthenticateAPIKey returns the user and the key an API key belongs to
+func (s *UserService) AuthenticateAPIKey(apiKey string) (*models.User, *models.APIKey, error) {
+	if apiKey == "" {
+		return nil, nil, fmt.Errorf("API key is required")
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(utils.HashAPIKey(apiKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to authenticate API key: %v", err)
+	}
+	if key == nil {
+		return nil, nil, fmt.Errorf("invalid API key")
+	}
+
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to authenticate API key: %v", err)
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("invalid API key")
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("account is disabled")
+	}
+
+	if now := time.Now(); key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		go func() {
+			if err := s.apiKeyRepo.TouchLastUsed(key.ID, now); err != nil {
+				s.logger.Warn("%v", err)
+			}
+		}()
+	}
+
+	return user, key, nil
+}
+
+// AllowAPIKeyRequest counts a request made with a key against its rate
+// limit, returning how long to wait when the limit is used up
+func (s *UserService) AllowAPIKeyRequest(key *models.APIKey) (bool, time.Duration) {
+	return s.keyRequests.Allow(strconv.FormatInt(key.ID, 10), key.RateLimit)
+}
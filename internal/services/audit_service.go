@@ -0,0 +1,50 @@
+package services
+
+import (
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// AuditService keeps the record of sensitive actions, separate from the
+// application logs
+type AuditService struct {
+	repo   *repository.AuditLogRepository
+	logger *logger.Logger
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repo *repository.AuditLogRepository, log *logger.Logger) *AuditService {
+	return &AuditService{repo: repo, logger: log}
+}
+
+// Record stores an entry. The action it records has already happened, so a
+// failure to store it is logged rather than returned.
+func (s *AuditService) Record(entry *models.AuditLog) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if err := s.repo.Create(entry); err != nil {
+		s.logger.Error("Failed to record audit log entry %s %s/%s: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+// List returns a page of the entries matching filter
+func (s *AuditService) List(filter models.AuditLogFilter, page, pageSize int) (*models.AuditLogPage, error) {
+	filter.Limit = pageSize
+	filter.Offset = (page - 1) * pageSize
+
+	logs, total, err := s.repo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuditLogPage{
+		Logs:       logs,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SetAutoReplyService enables auto-reply rules on incoming messages
+func (s *WhatsAppService) SetAutoReplyService(autoReplies *AutoReplyService) {
+	s.autoReplies = autoReplies
+}
+
+// SetAutoRepliesEnabled turns the auto-reply rules of a session on or off
+func (s *WhatsAppService) SetAutoRepliesEnabled(sessionID string, enabled bool) error {
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	if exists {
+		session.AutoRepliesEnabled = enabled
+	}
+	s.mu.Unlock()
+	if !exists {
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+
+	return s.sessionRepo.UpdateAutoRepliesEnabled(sessionID, enabled)
+}
+
+// autoReply answers an incoming message. A session with auto-reply rules
+// enabled answers by its rules, and its auto reply text only answers the
+// messages its rules leave alone, so a message never gets both. Group
+// messages and status updates are not answered.
+func (s *WhatsAppService) autoReply(session *models.Session, evt *events.Message) {
+	if evt.Info.IsGroup || evt.Info.Chat.Server == types.BroadcastServer || evt.Info.Chat.Server == types.NewsletterServer {
+		return
+	}
+
+	s.mu.RLock()
+	rulesEnabled := session.AutoRepliesEnabled
+	s.mu.RUnlock()
+
+	// Reactions, edits and other protocol messages do not trigger rules
+	messageType := loggedMessageType(evt.Message)
+	if rulesEnabled && s.autoReplies != nil && messageType != "" && messageType != "reaction" {
+		contact := senderPhone(evt.Info.MessageSource)
+		if contact == "" {
+			contact = evt.Info.Sender.ToNonAD().String()
+		}
+		handled, err := s.autoReplies.ProcessIncomingMessage(session.ID, contact, evt.Info.PushName, messageText(evt.Message), messageType)
+		if err != nil {
+			s.logger.Error("Failed to process auto-reply rules for session %s: %v", session.ID, err)
+		}
+		if handled {
+			return
+		}
+	}
+
+	s.sendAutoReply(session, evt)
+}
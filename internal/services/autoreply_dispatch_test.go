@@ -0,0 +1,152 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// Incoming messages of a sandbox session, which stands in for WhatsApp, are
+// answered by the session's auto-reply rules or, when no rule handles them,
+// by its auto reply text, never both
+func TestAutoReplyRules(t *testing.T) {
+	db := newTestDatabase(t)
+	service, sessionRepo, userID := newTestWhatsAppServiceOn(t, db)
+	autoReplyRepo := repository.NewAutoReplyRepository(db.DB())
+	autoReplies := NewAutoReplyService(autoReplyRepo, service, *newTestLogger())
+	service.SetAutoReplyService(autoReplies)
+
+	fallback := "Thanks, we'll get back to you"
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true, AutoReplyText: &fallback}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	pricing := &models.AutoReply{SessionID: session.ID, Name: "Pricing", Trigger: "keyword", Keywords: []string{"price", "cost"},
+		Response: "See our price list", IsActive: true, Priority: 1, MaxReplies: 2}
+	urgent := &models.AutoReply{SessionID: session.ID, Name: "Urgent", Trigger: "keyword", Keywords: []string{"urgent"},
+		Response: "We're on it", IsActive: true, Priority: 5}
+	for _, rule := range []*models.AutoReply{pricing, urgent} {
+		if err := autoReplyRepo.CreateAutoReply(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	replies := receipts(session)
+	receive := func(from, text, group string) {
+		t.Helper()
+		if _, err := service.InjectSandboxMessage(session.ID, &models.SandboxIncomingRequest{From: from, Text: text, Group: group}); err != nil {
+			t.Fatalf("failed to inject message: %v", err)
+		}
+	}
+	expectNoReply := func() {
+		t.Helper()
+		select {
+		case receipt := <-replies:
+			t.Errorf("unexpected reply to %s", receipt.Chat)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+	expectReply := func(to string) {
+		t.Helper()
+		select {
+		case receipt := <-replies:
+			if receipt.Chat.User != to {
+				t.Errorf("reply sent to %s, want %s", receipt.Chat, to)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reply to %s", to)
+		}
+		// A second answer to the same message would follow right away
+		expectNoReply()
+	}
+	ruleReplies := func() map[int]int {
+		t.Helper()
+		logs, err := autoReplyRepo.GetAutoReplyLogsBySession(session.ID, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make(map[int]int)
+		for _, log := range logs {
+			if !log.Success {
+				t.Errorf("rule %d failed to reply to %s: %s", log.AutoReplyID, log.ContactPhone, log.ErrorMsg)
+			}
+			counts[log.AutoReplyID]++
+		}
+		return counts
+	}
+
+	const ani, budi, citra = "628111111111", "628222222222", "628333333333"
+
+	// Keywords match regardless of case, up to the rule's daily limit per contact
+	receive(ani, "What's the PRICE?", "")
+	expectReply(ani)
+	receive(ani, "and the cost of shipping", "")
+	expectReply(ani)
+	receive(ani, "price again", "")
+	expectNoReply()
+	if counts := ruleReplies(); counts[pricing.ID] != 2 {
+		t.Errorf("pricing rule replied %d times, want its limit of 2", counts[pricing.ID])
+	}
+
+	// The limit is per contact
+	receive(budi, "price?", "")
+	expectReply(budi)
+
+	// Messages no rule matches get the auto reply text
+	receive(ani, "hello there", "")
+	expectReply(ani)
+
+	// The rule with the highest priority answers
+	receive(citra, "urgent: what does it cost?", "")
+	expectReply(citra)
+	if counts := ruleReplies(); counts[pricing.ID] != 3 || counts[urgent.ID] != 1 {
+		t.Errorf("rules replied %v, want pricing 3 and urgent 1", counts)
+	}
+
+	// Group messages are not answered
+	receive(citra, "price", "120363012345678901@g.us")
+	expectNoReply()
+
+	// With rules disabled only the auto reply text answers
+	if err := autoReplies.DisableAutoRepliesForSession(session.ID); err != nil {
+		t.Fatalf("failed to disable rules: %v", err)
+	}
+	stored, err := sessionRepo.GetByID(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AutoRepliesEnabled {
+		t.Error("disabled rules are stored as enabled")
+	}
+	receive(citra, "price", "")
+	expectReply(citra)
+	if counts := ruleReplies(); counts[pricing.ID] != 3 || counts[urgent.ID] != 1 {
+		t.Errorf("rules replied %v while disabled", counts)
+	}
+
+	if err := autoReplies.EnableAutoRepliesForSession(session.ID); err != nil {
+		t.Fatalf("failed to enable rules: %v", err)
+	}
+	receive(citra, "PRICE", "")
+	expectReply(citra)
+	logs, err := autoReplyRepo.GetAutoReplyLogsBySession(session.ID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pricedCitra := 0
+	for _, log := range logs {
+		if log.AutoReplyID == pricing.ID && strings.Contains(log.ContactPhone, citra) && log.Response == pricing.Response {
+			pricedCitra++
+		}
+	}
+	if len(logs) != 5 || pricedCitra != 1 {
+		t.Errorf("%d rule replies with %d pricing replies to %s, want 5 with 1", len(logs), pricedCitra, citra)
+	}
+}
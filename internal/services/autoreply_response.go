@@ -0,0 +1,110 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// An auto-reply response may contain variables filled in for each reply:
+// {{sender_name}}, {{sender_phone}}, {{time}} and, for the regex trigger, the
+// capture groups of the matching pattern as {{1}}, {{2}} and so on, or by
+// name for named groups. Variables that cannot be filled in, such as the name
+// of a sender without a WhatsApp profile name, are left out of the reply and
+// recorded in its log.
+
+// autoReplyVariable matches a variable of a response
+var autoReplyVariable = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// regexMatch returns the capture groups of the first of the rule's patterns
+// that matches text, and false if none does
+func regexMatch(rule *models.AutoReply, text string) (map[string]string, bool) {
+	for _, pattern := range rule.Keywords {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		captures := make(map[string]string, len(match))
+		for i, name := range re.SubexpNames() {
+			if i == 0 {
+				continue
+			}
+			captures[strconv.Itoa(i)] = match[i]
+			if name != "" {
+				captures[name] = match[i]
+			}
+		}
+		return captures, true
+	}
+	return nil, false
+}
+
+// responseVariables returns the values of the variables of a reply by rule to
+// a message from contactPhone, sent by senderName, at now
+func responseVariables(rule *models.AutoReply, contactPhone, senderName, messageText string, now time.Time) map[string]string {
+	vars := map[string]string{
+		"sender_phone": contactPhone,
+		"time":         now.In(rule.Location()).Format("15:04"),
+	}
+	if senderName != "" {
+		vars["sender_name"] = senderName
+	}
+	if rule.Trigger == "regex" {
+		captures, _ := regexMatch(rule, messageText)
+		for name, value := range captures {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// renderResponse fills in the variables of a response, returning the text and
+// the variables it had no value for, which are left out
+func renderResponse(response string, vars map[string]string) (string, []string) {
+	var missing []string
+	text := autoReplyVariable.ReplaceAllStringFunc(response, func(placeholder string) string {
+		name := autoReplyVariable.FindStringSubmatch(placeholder)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return ""
+	})
+	return text, missing
+}
+
+// sendResponse sends a rendered reply of a rule: its media with the text as
+// caption if it has media, the text otherwise. Media is downloaded like
+// SendFileFromURL's, so repeated replies reuse the session's earlier upload.
+func (s *AutoReplyService) sendResponse(rule *models.AutoReply, sessionID, contactPhone, text string) error {
+	var opts models.SendOptions
+	if rule.SendOptions != nil {
+		opts = *rule.SendOptions
+	}
+
+	if rule.MediaURL != "" {
+		_, err := s.whatsappSvc.SendFileFromURL(sessionID, &models.SendFileURLRequest{
+			To:          contactPhone,
+			URL:         rule.MediaURL,
+			Caption:     strings.TrimSpace(text),
+			Type:        rule.MediaType,
+			SendOptions: opts,
+		})
+		return err
+	}
+
+	_, err := s.whatsappSvc.SendMessage(sessionID, &models.SendMessageRequest{
+		To:          contactPhone,
+		Message:     text,
+		SendOptions: opts,
+	})
+	return err
+}
@@ -39,39 +39,43 @@ func NewAutoReplyService(autoReplyRepo *repository.AutoReplyRepository, whatsapp
 	return service
 }
 
-// ProcessIncomingMessage processes incoming messages for auto-reply triggers
-func (s *AutoReplyService) ProcessIncomingMessage(sessionID, contactPhone, messageText, messageType string) error {
+// ProcessIncomingMessage processes incoming messages for auto-reply triggers.
+// handled reports whether a rule dealt with the message, by replying or
+// because the contact already got the rule's replies for the day, in which
+// case the session's auto reply text must not answer it too.
+// senderName is the sender's WhatsApp profile name, if known.
+func (s *AutoReplyService) ProcessIncomingMessage(sessionID, contactPhone, senderName, messageText, messageType string) (handled bool, err error) {
 	// Get active auto-reply rules for this session
 	rules, err := s.autoReplyRepo.GetActiveAutoRepliesBySession(sessionID)
 	if err != nil {
 		s.log.Error("Failed to get auto-reply rules for session %s: %v", sessionID, err)
-		return err
+		return false, err
 	}
 	
 	if len(rules) == 0 {
-		return nil // No rules to process
-	}
-	
-	// Check daily reply limit for this contact
-	if !s.canReplyToContact(sessionID, contactPhone) {
-		s.log.Debug("Daily reply limit reached for contact %s in session %s", contactPhone, sessionID)
-		return nil
+		return false, nil // No rules to process
 	}
 	
 	// Find matching rules (sorted by priority)
 	matchingRule := s.findMatchingRule(rules, messageText, messageType, contactPhone)
 	if matchingRule == nil {
-		return nil // No matching rule
+		return false, nil // No matching rule
 	}
 	
 	// Check time-based conditions
 	if !s.isWithinTimeWindow(matchingRule) {
 		s.log.Debug("Auto-reply rule %d outside time window", matchingRule.ID)
-		return nil
+		return false, nil
+	}
+	
+	// Check daily reply limit for this contact
+	if !s.canReplyToContact(sessionID, contactPhone, matchingRule.MaxReplies) {
+		s.log.Debug("Daily reply limit reached for contact %s in session %s", contactPhone, sessionID)
+		return true, nil
 	}
 	
 	// Process the auto-reply
-	return s.processAutoReply(matchingRule, sessionID, contactPhone, messageText)
+	return true, s.processAutoReply(matchingRule, sessionID, contactPhone, senderName, messageText)
 }
 
 // findMatchingRule finds the highest priority matching rule
@@ -113,9 +117,13 @@ func (s *AutoReplyService) doesRuleMatch(rule models.AutoReply, messageText, mes
 		}
 		return false
 		
+	case "regex":
+		_, matched := regexMatch(&rule, messageText)
+		return matched
+		
 	case "new_contact":
-		// Check if this is first time contact (simplified - you might want to check database)
-		return !s.hasContactBeenSeenBefore(rule.SessionID, contactPhone)
+		// Requires new contact detection to be enabled on the session
+		return s.whatsappSvc.IsFirstContact(rule.SessionID, contactPhone)
 		
 	case "time_based":
 		// This trigger is handled separately in time window check
@@ -127,7 +135,7 @@ func (s *AutoReplyService) doesRuleMatch(rule models.AutoReply, messageText, mes
 }
 
 // processAutoReply executes the auto-reply
-func (s *AutoReplyService) processAutoReply(rule *models.AutoReply, sessionID, contactPhone, originalMessage string) error {
+func (s *AutoReplyService) processAutoReply(rule *models.AutoReply, sessionID, contactPhone, senderName, originalMessage string) error {
 	// Add delay if specified
 	delay := s.calculateReplyDelay(rule)
 	if delay > 0 {
@@ -135,25 +143,30 @@ func (s *AutoReplyService) processAutoReply(rule *models.AutoReply, sessionID, c
 		time.Sleep(time.Duration(delay) * time.Second)
 	}
 	
-	// Prepare message request
-	messageReq := &models.SendMessageRequest{
-		To:      contactPhone,
-		Message: rule.Response,
+	// Fill in the response's variables
+	vars := responseVariables(rule, contactPhone, senderName, originalMessage, time.Now())
+	response, missing := renderResponse(rule.Response, vars)
+	if len(missing) > 0 {
+		s.log.Warn("Auto-reply rule %d has no value for variables %s", rule.ID, strings.Join(missing, ", "))
 	}
 	
 	// Send the auto-reply
-	_, err := s.whatsappSvc.SendMessage(sessionID, messageReq)
+	err := s.sendResponse(rule, sessionID, contactPhone, response)
 	success := err == nil
 	
 	// Log the auto-reply attempt
 	logEntry := models.AutoReplyLog{
-		AutoReplyID:  rule.ID,
-		SessionID:    sessionID,
-		ContactPhone: contactPhone,
-		TriggerMsg:   originalMessage,
-		Response:     rule.Response,
-		Success:      success,
-		CreatedAt:    time.Now(),
+		AutoReplyID:   rule.ID,
+		SessionID:     sessionID,
+		ContactPhone:  contactPhone,
+		TriggerMsg:    originalMessage,
+		Response:      response,
+		Success:       success,
+		MediaIncluded: rule.MediaURL != "",
+		CreatedAt:     time.Now(),
+	}
+	for _, name := range missing {
+		logEntry.SubstitutionErrors = append(logEntry.SubstitutionErrors, fmt.Sprintf("no value for {{%s}}", name))
 	}
 	
 	if !success {
@@ -194,19 +207,12 @@ func (s *AutoReplyService) calculateReplyDelay(rule *models.AutoReply) int {
 
 // isWithinTimeWindow checks if current time is within rule's time window
 func (s *AutoReplyService) isWithinTimeWindow(rule *models.AutoReply) bool {
-	if rule.TimeStart == "" || rule.TimeEnd == "" {
-		return true // No time restriction
-	}
-	
-	now := time.Now()
-	currentTime := now.Format("15:04")
-	
-	// Simple time comparison (assumes same day)
-	return currentTime >= rule.TimeStart && currentTime <= rule.TimeEnd
+	return rule.InTimeWindow(time.Now())
 }
 
-// canReplyToContact checks if we can send another reply to this contact today
-func (s *AutoReplyService) canReplyToContact(sessionID, contactPhone string) bool {
+// canReplyToContact checks if we can send another reply to this contact
+// today, allowing maxReplies a day or the default limit if it is 0
+func (s *AutoReplyService) canReplyToContact(sessionID, contactPhone string, maxReplies int) bool {
 	s.trackerMutex.RLock()
 	defer s.trackerMutex.RUnlock()
 	
@@ -220,9 +226,11 @@ func (s *AutoReplyService) canReplyToContact(sessionID, contactPhone string) boo
 		return true
 	}
 	
-	// For now, use a default daily limit (could be made configurable per rule)
 	const defaultDailyLimit = 5
-	return count < defaultDailyLimit
+	if maxReplies <= 0 {
+		maxReplies = defaultDailyLimit
+	}
+	return count < maxReplies
 }
 
 // trackReply increments the reply count for a contact
@@ -237,22 +245,6 @@ func (s *AutoReplyService) trackReply(sessionID, contactPhone string) {
 	s.replyTracker[sessionID][contactPhone]++
 }
 
-// hasContactBeenSeenBefore checks if we've interacted with this contact before
-func (s *AutoReplyService) hasContactBeenSeenBefore(sessionID, contactPhone string) bool {
-	// This is a simplified implementation
-	// In a real system, you'd check the database for previous interactions
-	s.trackerMutex.RLock()
-	defer s.trackerMutex.RUnlock()
-	
-	sessionTracker, exists := s.replyTracker[sessionID]
-	if !exists {
-		return false
-	}
-	
-	_, exists = sessionTracker[contactPhone]
-	return exists
-}
-
 // dailyResetRoutine resets reply counters daily at midnight
 func (s *AutoReplyService) dailyResetRoutine() {
 	for {
@@ -291,7 +283,8 @@ func (s *AutoReplyService) TestAutoReply(req models.AutoReplyTestRequest) (*mode
 	// Check if rule would match
 	if s.doesRuleMatch(*rule, req.TestMessage, "text", testPhone) {
 		response.WouldTrigger = true
-		response.Response = rule.Response
+		vars := responseVariables(rule, testPhone, "", req.TestMessage, time.Now())
+		response.Response, _ = renderResponse(rule.Response, vars)
 		response.Delay = s.calculateReplyDelay(rule)
 		response.Reason = fmt.Sprintf("Matched trigger: %s", rule.Trigger)
 		
@@ -362,14 +355,18 @@ func (s *AutoReplyService) GetAutoReplyStats(sessionID string) (*models.AutoRepl
 
 // EnableAutoRepliesForSession enables auto-reply processing for a session
 func (s *AutoReplyService) EnableAutoRepliesForSession(sessionID string) error {
-	// This would integrate with your WhatsApp service to register message handlers
+	if err := s.whatsappSvc.SetAutoRepliesEnabled(sessionID, true); err != nil {
+		return err
+	}
 	s.log.Info("Auto-reply processing enabled for session %s", sessionID)
 	return nil
 }
 
 // DisableAutoRepliesForSession disables auto-reply processing for a session
 func (s *AutoReplyService) DisableAutoRepliesForSession(sessionID string) error {
-	// This would integrate with your WhatsApp service to unregister message handlers
+	if err := s.whatsappSvc.SetAutoRepliesEnabled(sessionID, false); err != nil {
+		return err
+	}
 	s.log.Info("Auto-reply processing disabled for session %s", sessionID)
 	return nil
 }
@@ -431,7 +428,7 @@ func (s *AutoReplyService) CreateAutoReplyFromTemplate(sessionID, templateType s
 		}
 		
 	default:
-		return nil, fmt.Errorf("unknown template type: %s", templateType)
+		return nil, models.NewBadRequestError("unknown template type %q, use welcome, away, business_hours or support", templateType)
 	}
 	
 	err := s.autoReplyRepo.CreateAutoReply(autoReply)
@@ -463,13 +460,17 @@ func (s *AutoReplyService) ValidateAutoReplyRule(rule *models.AutoReply) error {
 			return fmt.Errorf("keywords are required for keyword trigger")
 		}
 		
-	case "all", "new_contact", "time_based":
+	case "regex", "all", "new_contact", "time_based":
 		// These are valid trigger types
 		
 	default:
 		return fmt.Errorf("invalid trigger type: %s", rule.Trigger)
 	}
 	
+	if err := models.ValidateAutoReplyContent(rule.Trigger, rule.Keywords, rule.MediaType); err != nil {
+		return err
+	}
+	
 	// Validate time format if specified
 	if rule.TimeStart != "" {
 		if !s.isValidTimeFormat(rule.TimeStart) {
@@ -483,6 +484,10 @@ func (s *AutoReplyService) ValidateAutoReplyRule(rule *models.AutoReply) error {
 		}
 	}
 	
+	if err := models.ValidateTimeWindow(rule.TimeStart, rule.TimeEnd, rule.TimeZone); err != nil {
+		return err
+	}
+	
 	// Validate delay values
 	if rule.DelayMin < 0 || rule.DelayMax < 0 {
 		return fmt.Errorf("delay values cannot be negative")
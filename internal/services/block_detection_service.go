@@ -0,0 +1,67 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// blockDetectionInterval is how often undelivered messages are re-classified
+const blockDetectionInterval = time.Hour
+
+// BlockDetectionService periodically flags sent messages that were never
+// delivered to recipients who used to receive messages, since WhatsApp does
+// not tell the sender when a recipient has blocked them.
+type BlockDetectionService struct {
+	messageRepo *repository.MessageRepository
+	after       time.Duration
+	log         *logger.Logger
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewBlockDetectionService creates a service that marks messages still undelivered after the given duration
+func NewBlockDetectionService(messageRepo *repository.MessageRepository, after time.Duration, log *logger.Logger) *BlockDetectionService {
+	return &BlockDetectionService{
+		messageRepo: messageRepo,
+		after:       after,
+		log:         log,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start runs the classification every hour in the background until Stop is called
+func (s *BlockDetectionService) Start() {
+	go func() {
+		ticker := time.NewTicker(blockDetectionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Classify()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background classification
+func (s *BlockDetectionService) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Classify marks undelivered messages older than the configured duration as probably blocked
+func (s *BlockDetectionService) Classify() {
+	marked, err := s.messageRepo.MarkProbablyBlocked(time.Now().Add(-s.after))
+	if err != nil {
+		s.log.Error("Block detection failed: %v", err)
+		return
+	}
+	if marked > 0 {
+		s.log.Info("Block detection marked %d undelivered message(s) as probably blocked", marked)
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// Linked devices cannot send to a broadcast list themselves: whatsmeow only
+// supports the status broadcast. Messages to a list are therefore sent to each
+// of its recipients under one message ID, which is what a broadcast looks like
+// to recipients, and their receipts name the list.
+
+// broadcastSendTTL is how long receipts are matched to the list a message
+// was sent to
+const broadcastSendTTL = 7 * 24 * time.Hour
+
+// broadcastListPatches are the app state collections broadcast lists may be
+// synced in
+var broadcastListPatches = []appstate.WAPatchName{
+	appstate.WAPatchRegular, appstate.WAPatchRegularHigh, appstate.WAPatchRegularLow,
+}
+
+// SetBroadcastListRepository enables the local mirror of the phone's broadcast lists
+func (s *WhatsAppService) SetBroadcastListRepository(lists *repository.BroadcastListRepository) {
+	s.broadcastLists = lists
+}
+
+// handleBroadcastListAction mirrors a broadcast list created, changed or
+// deleted on the phone. whatsmeow has no event of its own for lists, so they
+// are read from the raw app state.
+func (s *WhatsAppService) handleBroadcastListAction(session *models.Session, evt *events.AppState) {
+	action := evt.GetBusinessBroadcastListAction()
+	if s.broadcastLists == nil || action == nil || len(evt.Index) < 2 {
+		return
+	}
+
+	listJID := broadcastListJID(evt.Index[1])
+	var err error
+	if action.GetDeleted() {
+		err = s.broadcastLists.DeleteList(session.ID, listJID)
+	} else {
+		recipients := make([]string, 0, len(action.GetParticipants()))
+		for _, participant := range action.GetParticipants() {
+			recipient := participant.GetPnJID()
+			if recipient == "" {
+				recipient = participant.GetLidJID()
+			}
+			if recipient != "" {
+				recipients = append(recipients, recipient)
+			}
+		}
+		err = s.broadcastLists.UpsertList(session.ID, &models.BroadcastList{
+			JID:        listJID,
+			Name:       action.GetListName(),
+			Recipients: recipients,
+		})
+	}
+	if err != nil {
+		s.logger.Error("Failed to mirror broadcast list %s for session %s: %v", listJID, session.ID, err)
+	}
+}
+
+// GetBroadcastLists returns the session's broadcast lists. With refresh the
+// lists are re-read from the phone's app state first.
+func (s *WhatsAppService) GetBroadcastLists(sessionID string, refresh bool) ([]*models.BroadcastList, error) {
+	if s.broadcastLists == nil {
+		return nil, models.NewServiceUnavailableError("broadcast list storage is not configured")
+	}
+
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return nil, models.NewNotFoundError("session not found")
+	}
+
+	if refresh && !session.Sandbox {
+		session, err := s.loggedInSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		session.Client.EmitAppStateEventsOnFullSync = true
+		for _, patch := range broadcastListPatches {
+			if err := session.Client.FetchAppState(context.Background(), patch, true, false); err != nil {
+				return nil, fmt.Errorf("failed to sync broadcast lists: %v", err)
+			}
+		}
+	}
+
+	return s.broadcastLists.GetLists(sessionID)
+}
+
+// sendToBroadcastList sends a message to each recipient of a mirrored
+// broadcast list. It succeeds when at least one recipient was sent the message.
+func (s *WhatsAppService) sendToBroadcastList(session *models.Session, list types.JID, msg *waProto.Message) (whatsmeow.SendResponse, error) {
+	if s.broadcastLists == nil {
+		return whatsmeow.SendResponse{}, models.NewServiceUnavailableError("broadcast list storage is not configured")
+	}
+	mirrored, err := s.broadcastLists.GetList(session.ID, list.String())
+	if err != nil {
+		return whatsmeow.SendResponse{}, err
+	}
+	if mirrored == nil {
+		return whatsmeow.SendResponse{}, models.NewNotFoundError("broadcast list %s not found, refresh the lists with GET /broadcast-lists?refresh=true", list)
+	}
+	if len(mirrored.Recipients) == 0 {
+		return whatsmeow.SendResponse{}, models.NewBadRequestError("broadcast list %s has no recipients", list)
+	}
+
+	var (
+		resp    whatsmeow.SendResponse
+		lastErr error
+		sent    int
+	)
+	id := session.Client.GenerateMessageID()
+	for _, recipient := range mirrored.Recipients {
+		jid, err := types.ParseJID(recipient)
+		if err != nil {
+			lastErr = fmt.Errorf("invalid recipient %s: %v", recipient, err)
+			continue
+		}
+		// whatsmeow may add to the message while sending it
+		recipientResp, err := s.sendMessageWithID(session, jid, proto.Clone(msg).(*waProto.Message), id)
+		if err != nil {
+			s.logger.Warn("Session %s failed to send message %s to %s of broadcast list %s: %v", session.ID, id, jid, list, err)
+			lastErr = err
+			continue
+		}
+		resp = recipientResp
+		sent++
+	}
+	if sent == 0 {
+		return resp, lastErr
+	}
+
+	s.broadcastSends.record(session.ID, id, list)
+	s.logger.Info("Session %s sent message %s to %d of %d recipients of broadcast list %s",
+		session.ID, id, sent, len(mirrored.Recipients), list)
+	return resp, nil
+}
+
+// broadcastListJID turns the list ID of an app state index into the list's JID
+func broadcastListJID(id string) string {
+	if strings.HasSuffix(id, "@"+types.BroadcastServer) {
+		return id
+	}
+	return types.NewJID(id, types.BroadcastServer).String()
+}
+
+// broadcastSend is a message sent to a broadcast list
+type broadcastSend struct {
+	list   types.JID
+	sentAt time.Time
+}
+
+// broadcastSendTracker remembers which messages were sent to broadcast lists,
+// so receipts from their recipients can name the list
+type broadcastSendTracker struct {
+	mu    sync.Mutex
+	sends map[string]broadcastSend
+}
+
+func broadcastSendKey(sessionID string, messageID types.MessageID) string {
+	return sessionID + "\x00" + messageID
+}
+
+// record remembers a message sent to a list, forgetting expired ones
+func (t *broadcastSendTracker) record(sessionID string, messageID types.MessageID, list types.JID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.sends == nil {
+		t.sends = make(map[string]broadcastSend)
+	}
+	for key, send := range t.sends {
+		if now.Sub(send.sentAt) > broadcastSendTTL {
+			delete(t.sends, key)
+		}
+	}
+	t.sends[broadcastSendKey(sessionID, messageID)] = broadcastSend{list: list, sentAt: now}
+}
+
+// list returns the broadcast list any of the messages was sent to, if any
+func (t *broadcastSendTracker) list(sessionID string, messageIDs []types.MessageID) (types.JID, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, messageID := range messageIDs {
+		if send, ok := t.sends[broadcastSendKey(sessionID, messageID)]; ok && time.Since(send.sentAt) <= broadcastSendTTL {
+			return send.list, true
+		}
+	}
+	return types.EmptyJID, false
+}
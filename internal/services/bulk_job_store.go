@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// Bulk messaging jobs are kept in the campaigns table, one campaign per job,
+// with a campaign message per recipient. Messages are rendered when the job is
+// created, so a job reloaded after a restart sends what it would have sent.
+
+// renderContents renders the message to each contact of a job
+func (s *BulkMessagingService) renderContents(job *BulkMessageJob) {
+	job.contents = make([]string, len(job.Contacts))
+	for i, contact := range job.Contacts {
+		job.contents[i], _ = s.generateMessageContent(job.Template, contact, job.Variables)
+	}
+}
+
+// storeJob renders the messages of a new job, unless they are given, and
+// stores the job with them
+func (s *BulkMessagingService) storeJob(job *BulkMessageJob) error {
+	if job.contents == nil {
+		s.renderContents(job)
+	}
+	if s.campaignRepo == nil {
+		return nil
+	}
+
+	messages := make([]repository.BulkJobMessage, len(job.Contacts))
+	for i, contact := range job.Contacts {
+		messages[i] = repository.BulkJobMessage{
+			Position:  i,
+			ContactID: contact.ID,
+			Phone:     contact.Phone,
+			Name:      contact.Name,
+			Content:   job.contents[i],
+		}
+	}
+
+	record := job.record()
+	if err := s.campaignRepo.CreateBulkJob(record, messages); err != nil {
+		return err
+	}
+	job.CampaignID = &record.CampaignID
+	job.stored = true
+	return nil
+}
+
+// record returns the stored form of a job. The caller holds jobsMutex unless
+// the job is not shared yet.
+func (job *BulkMessageJob) record() *repository.BulkJob {
+	record := &repository.BulkJob{
+		UserID:            job.UserID,
+		JobID:             job.ID,
+		RetryOf:           job.RetryOf,
+		SessionID:         job.SessionID,
+		SessionIDs:        job.SessionIDs,
+		SessionWeights:    job.SessionWeights,
+		Message:           job.Template.Content,
+		DelayBetween:      job.DelayBetween,
+		RandomDelay:       job.RandomDelay,
+		Variables:         job.Variables,
+		SendOptions:       job.SendOptions,
+		Throttle:          job.Throttle,
+		InvalidRecipients: job.InvalidRecipients,
+		Status:            job.Status,
+		PausedReason:      job.PausedReason,
+		NextIndex:         job.NextIndex,
+		Total:             job.Progress.Total,
+		Sent:              job.Progress.Sent,
+		Failed:            job.Progress.Failed,
+		CreatedAt:         job.CreatedAt,
+		StartedAt:         job.StartedAt,
+		CompletedAt:       job.CompletedAt,
+	}
+	if job.CampaignID != nil {
+		record.CampaignID = *job.CampaignID
+	}
+	return record
+}
+
+// saveJob stores the status and progress of a stored job
+func (s *BulkMessagingService) saveJob(job *BulkMessageJob) {
+	if !job.stored {
+		return
+	}
+
+	s.jobsMutex.RLock()
+	record := job.record()
+	s.jobsMutex.RUnlock()
+
+	if err := s.campaignRepo.UpdateBulkJob(record); err != nil {
+		s.log.Error("Failed to store progress of bulk messaging job %s: %v", job.ID, err)
+	}
+}
+
+// recordFailedMessage stores why the message to the contact at index failed
+// to send from sessionID
+func (s *BulkMessagingService) recordFailedMessage(job *BulkMessageJob, index int, sessionID string, sendErr error) {
+	if !job.stored {
+		return
+	}
+	if err := s.campaignRepo.UpdateBulkJobMessage(*job.CampaignID, index, sessionID, models.BulkResultFailed, "", sendErr.Error(), nil); err != nil {
+		s.log.Error("Failed to store failed message %d of job %s: %v", index, job.ID, err)
+	}
+}
+
+// RestoreJobs reloads the stored jobs that had not finished when the server
+// stopped. They come back paused, since their session may not be connected
+// yet and an operator should decide whether they still go out; ResumeJob
+// continues them with their next contact.
+func (s *BulkMessagingService) RestoreJobs() error {
+	if s.campaignRepo == nil {
+		return nil
+	}
+
+	incomplete, err := s.campaignRepo.GetIncompleteBulkJobs()
+	if err != nil {
+		return err
+	}
+	for _, record := range incomplete {
+		job, err := s.loadJob(record)
+		if err != nil {
+			return err
+		}
+
+		job.ctx, job.cancel = context.WithCancel(s.ctx)
+		job.wake = make(chan struct{}, 1)
+		job.paused = true
+		job.Status = "paused"
+		job.PausedReason = PausedReasonRestart
+
+		s.jobsMutex.Lock()
+		s.jobs[job.ID] = job
+		s.jobsMutex.Unlock()
+		s.saveJob(job)
+
+		s.runJob(job)
+
+		s.log.Info("Restored bulk messaging job %s paused at contact %d/%d", job.ID, job.NextIndex+1, len(job.Contacts))
+	}
+	return nil
+}
+
+// loadJob rebuilds a stored job with its contacts and rendered messages.
+// Contacts deleted since the job was stored are left empty and skipped.
+func (s *BulkMessagingService) loadJob(record *repository.BulkJob) (*BulkMessageJob, error) {
+	messages, err := s.campaignRepo.GetBulkJobMessages(record.CampaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	job := jobFromRecord(record)
+	job.Contacts = make([]models.Contact, record.Total)
+	job.contents = make([]string, record.Total)
+	for _, message := range messages {
+		if message.Position < 0 || message.Position >= record.Total {
+			continue
+		}
+		job.Contacts[message.Position] = models.Contact{
+			ID:    message.ContactID,
+			Name:  message.Name,
+			Phone: message.Phone,
+		}
+		job.contents[message.Position] = message.Content
+		if message.MessageID != "" {
+			job.messageIDs[message.Position] = message.MessageID
+		}
+	}
+	return job, nil
+}
+
+// jobFromRecord returns a stored job without its contacts
+func jobFromRecord(record *repository.BulkJob) *BulkMessageJob {
+	campaignID := record.CampaignID
+	return &BulkMessageJob{
+		ID:                record.JobID,
+		RetryOf:           record.RetryOf,
+		CampaignID:        &campaignID,
+		UserID:            record.UserID,
+		SessionID:         record.SessionID,
+		SessionIDs:        record.SessionIDs,
+		SessionWeights:    record.SessionWeights,
+		Template:          &models.MessageTemplate{Content: record.Message, Type: "text"},
+		DelayBetween:      record.DelayBetween,
+		RandomDelay:       record.RandomDelay,
+		Variables:         record.Variables,
+		SendOptions:       record.SendOptions,
+		Throttle:          record.Throttle.WithDefaults(),
+		Status:            record.Status,
+		InvalidRecipients: record.InvalidRecipients,
+		PausedReason:      record.PausedReason,
+		NextIndex:         record.NextIndex,
+		Progress: BulkMessageProgress{
+			Total:     record.Total,
+			Sent:      record.Sent,
+			Failed:    record.Failed,
+			Remaining: record.Total - record.Sent - record.Failed,
+		},
+		CreatedAt:   record.CreatedAt,
+		StartedAt:   record.StartedAt,
+		CompletedAt: record.CompletedAt,
+		messageIDs:  make(map[int]string),
+		stored:      true,
+	}
+}
+
+// storedJob returns the stored form of a job, live or finished, if userID
+// started it or is 0
+func (s *BulkMessagingService) storedJob(jobID string, userID int) (*repository.BulkJob, error) {
+	if s.campaignRepo == nil {
+		return nil, models.NewServiceUnavailableError("bulk job results are not available")
+	}
+
+	s.jobsMutex.RLock()
+	job, live := s.jobs[jobID]
+	var record *repository.BulkJob
+	if live && job.stored {
+		record = job.record()
+	}
+	s.jobsMutex.RUnlock()
+
+	if !live {
+		return s.campaignRepo.GetBulkJob(jobID, userID)
+	}
+	if !job.ownedBy(userID) {
+		return nil, models.NewNotFoundError("job %s not found", jobID)
+	}
+	if record == nil {
+		return nil, models.NewBadRequestError("results of job %s are not stored", jobID)
+	}
+	return record, nil
+}
+
+// GetJobResults returns a page of the per-recipient outcomes of a job userID
+// started, or of any job if userID is 0, only those with status unless it is
+// empty
+func (s *BulkMessagingService) GetJobResults(jobID, status string, page, limit, userID int) (*models.BulkMessageResultList, error) {
+	switch status {
+	case "", models.BulkResultPending, models.BulkResultSent, models.BulkResultFailed:
+	default:
+		return nil, models.NewBadRequestError("status must be %s, %s or %s",
+			models.BulkResultPending, models.BulkResultSent, models.BulkResultFailed)
+	}
+
+	record, err := s.storedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	messages, total, err := s.campaignRepo.GetBulkJobResults(record.CampaignID, status, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.BulkMessageResultList{
+		JobID:   jobID,
+		Results: make([]models.BulkMessageResult, 0, len(messages)),
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+		Pages:   (total + limit - 1) / limit,
+	}
+	for _, message := range messages {
+		list.Results = append(list.Results, models.BulkMessageResult{
+			Index:     message.Position,
+			Phone:     message.Phone,
+			ContactID: message.ContactID,
+			Name:      message.Name,
+			Status:    message.Status,
+			SessionID: message.SessionID,
+			MessageID: message.MessageID,
+			Error:     message.ErrorMsg,
+			SentAt:    message.SentAt,
+		})
+	}
+	return list, nil
+}
+
+// RetryFailedJob starts a job sending a finished job's message again to the
+// recipients it failed to reach, with the same sessions and options. The
+// messages are sent as they were rendered for the first job, and the new job
+// belongs to the same user. Only the user who started the job, or an admin
+// passing 0 as userID, may retry it.
+func (s *BulkMessagingService) RetryFailedJob(jobID string, userID int) (*BulkMessageJob, error) {
+	record, err := s.storedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch record.Status {
+	case "completed", "cancelled", "failed":
+	default:
+		return nil, models.NewBadRequestError("job %s is %s, only finished jobs can be retried", jobID, record.Status)
+	}
+
+	failed, _, err := s.campaignRepo.GetBulkJobResults(record.CampaignID, models.BulkResultFailed, 0, record.Total)
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return nil, models.NewBadRequestError("job %s has no failed recipients", jobID)
+	}
+
+	contacts := make([]models.Contact, len(failed))
+	contents := make([]string, len(failed))
+	for i, message := range failed {
+		contacts[i] = models.Contact{ID: message.ContactID, Name: message.Name, Phone: message.Phone}
+		contents[i] = message.Content
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	job := &BulkMessageJob{
+		ID:             s.generateJobID(),
+		RetryOf:        jobID,
+		UserID:         record.UserID,
+		SessionID:      record.SessionID,
+		SessionIDs:     record.SessionIDs,
+		SessionWeights: record.SessionWeights,
+		Template:       &models.MessageTemplate{Content: record.Message, Type: "text"},
+		Contacts:       contacts,
+		DelayBetween:   record.DelayBetween,
+		RandomDelay:    record.RandomDelay,
+		Variables:      record.Variables,
+		SendOptions:    record.SendOptions,
+		Throttle:       record.Throttle.WithDefaults(),
+		Status:         "pending",
+		Progress: BulkMessageProgress{
+			Total:     len(contacts),
+			Remaining: len(contacts),
+		},
+		CreatedAt:  time.Now(),
+		messageIDs: make(map[int]string),
+		contents:   contents,
+		wake:       make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if err := s.storeJob(job); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s.jobsMutex.Lock()
+	s.jobs[job.ID] = job
+	s.jobsMutex.Unlock()
+
+	s.runJob(job)
+
+	s.log.Info("Started bulk messaging job %s retrying %d failed recipients of job %s", job.ID, len(contacts), jobID)
+	return job, nil
+}
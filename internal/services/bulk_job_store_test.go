@@ -0,0 +1,200 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// newTestBulkService returns a bulk messaging service storing its jobs in the
+// campaigns table, with a connected sandbox session of its user
+func newTestBulkService(t *testing.T) (*BulkMessagingService, *repository.CampaignRepository, string, int) {
+	t.Helper()
+	db := newTestDatabase(t)
+	service, _, userID := newTestWhatsAppServiceOn(t, db)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect session: %v", err)
+	}
+
+	campaignRepo := repository.NewCampaignRepository(db.DB())
+	bulk := NewBulkMessagingService(service, service.messageRepo, campaignRepo, *newTestLogger())
+	t.Cleanup(func() { bulk.Shutdown(t.Context()) })
+	return bulk, campaignRepo, session.ID, userID
+}
+
+// newTestBulkJob starts a bulk job of the user on a sandbox session and waits
+// for it to finish
+func newTestBulkJob(t *testing.T) (*BulkMessagingService, *repository.CampaignRepository, *BulkMessageJob, int) {
+	t.Helper()
+	bulk, campaignRepo, sessionID, userID := newTestBulkService(t)
+	contacts := []models.Contact{{Phone: "628111111111", Name: "Ani"}}
+	job, err := bulk.StartBulkMessage(models.BulkMessageRequest{SessionID: sessionID, Message: "Hi {{name}}"}, nil, contacts, nil, userID)
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	waitUntil(t, 5*time.Second, "the job to finish", func() bool {
+		status, _, _, _ := jobSnapshot(bulk, job)
+		return status == "completed"
+	})
+	return bulk, campaignRepo, job, userID
+}
+
+// Jobs record the user who started them, and only that user and admins see
+// their results or retry them; others get 404 as for an unknown job
+func TestBulkJobResultsAndRetriesOfOwnerOnly(t *testing.T) {
+	bulk, campaignRepo, job, owner := newTestBulkJob(t)
+	other := owner + 1
+
+	if job.UserID != owner {
+		t.Errorf("job user = %d, want %d", job.UserID, owner)
+	}
+	if stored, err := campaignRepo.GetBulkJob(job.ID, owner); err != nil || stored.UserID != owner {
+		t.Fatalf("stored job of its owner = %+v, %v; want user %d", stored, err, owner)
+	}
+	if _, err := campaignRepo.GetBulkJob(job.ID, other); !isNotFound(err) {
+		t.Errorf("stored job of another user = %v, want not found", err)
+	}
+
+	// The job as live in this process, then as stored after a restart
+	restarted := NewBulkMessagingService(bulk.whatsappService, bulk.messageRepo, campaignRepo, *newTestLogger())
+	t.Cleanup(func() { restarted.Shutdown(t.Context()) })
+	for name, service := range map[string]*BulkMessagingService{"live": bulk, "stored": restarted} {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				name   string
+				userID int
+				found  bool
+			}{
+				{"owner", owner, true},
+				{"admin", 0, true},
+				{"other user", other, false},
+			}
+			for _, tt := range tests {
+				results, err := service.GetJobResults(job.ID, "", 1, 20, tt.userID)
+				if tt.found && (err != nil || results.Total != 1) {
+					t.Errorf("results for %s = %+v, %v; want 1 result", tt.name, results, err)
+				}
+				if !tt.found && !isNotFound(err) {
+					t.Errorf("results for %s = %v, want not found", tt.name, err)
+				}
+
+				// Nothing failed, so the job cannot be retried by those who may see it
+				_, err = service.RetryFailedJob(job.ID, tt.userID)
+				if _, badRequest := err.(models.BadRequestError); tt.found && !badRequest {
+					t.Errorf("retry for %s = %v, want a bad request", tt.name, err)
+				}
+				if !tt.found && !isNotFound(err) {
+					t.Errorf("retry for %s = %v, want not found", tt.name, err)
+				}
+			}
+		})
+	}
+}
+
+// Users list and get only the jobs they started, while admins see every job
+func TestBulkJobsListedToOwnerOnly(t *testing.T) {
+	bulk, campaignRepo, job, owner := newTestBulkJob(t)
+	other := owner + 1
+
+	restarted := NewBulkMessagingService(bulk.whatsappService, bulk.messageRepo, campaignRepo, *newTestLogger())
+	t.Cleanup(func() { restarted.Shutdown(t.Context()) })
+	for name, service := range map[string]*BulkMessagingService{"live": bulk, "stored": restarted} {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				name   string
+				userID int
+				found  bool
+			}{
+				{"owner", owner, true},
+				{"admin", 0, true},
+				{"other user", other, false},
+			}
+			for _, tt := range tests {
+				jobs, err := service.GetJobs(tt.userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.found && (len(jobs) != 1 || jobs[0].ID != job.ID) {
+					t.Errorf("jobs of %s = %d jobs, want the job", tt.name, len(jobs))
+				}
+				if !tt.found && len(jobs) != 0 {
+					t.Errorf("jobs of %s = %d jobs, want none", tt.name, len(jobs))
+				}
+
+				got, err := service.GetJob(job.ID, tt.userID)
+				if tt.found && (err != nil || got.ID != job.ID) {
+					t.Errorf("job for %s = %v, want the job", tt.name, err)
+				}
+				if !tt.found && !isNotFound(err) {
+					t.Errorf("job for %s = %v, want not found", tt.name, err)
+				}
+			}
+		})
+	}
+}
+
+// Only the user who started a job and admins may pause, resume or cancel it;
+// others get 404 and the job goes on as it was
+func TestBulkJobChangedByOwnerOnly(t *testing.T) {
+	bulk, _, sessionID, owner := newTestBulkService(t)
+	other := owner + 1
+	contacts := []models.Contact{{Phone: "628111111111", Name: "Ani"}, {Phone: "628222222222", Name: "Budi"}}
+	job, err := bulk.StartBulkMessage(models.BulkMessageRequest{SessionID: sessionID, Message: "Hi {{name}}", DelayBetween: 60}, nil, contacts, nil, owner)
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	waitUntil(t, 5*time.Second, "the job to run", func() bool {
+		status, _, _, _ := jobSnapshot(bulk, job)
+		return status == "running"
+	})
+
+	steps := []struct {
+		name   string
+		change func(string, int) error
+		userID int
+		want   string // Status after the step, unchanged when it is refused
+		found  bool
+	}{
+		{"other user pauses", bulk.PauseJob, other, "running", false},
+		{"owner pauses", bulk.PauseJob, owner, "paused", true},
+		{"other user resumes", bulk.ResumeJob, other, "paused", false},
+		{"admin resumes", bulk.ResumeJob, 0, "running", true},
+		{"other user cancels", bulk.CancelJob, other, "running", false},
+		{"owner cancels", bulk.CancelJob, owner, "cancelled", true},
+	}
+	for _, step := range steps {
+		err := step.change(job.ID, step.userID)
+		if step.found && err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !step.found && !isNotFound(err) {
+			t.Errorf("%s = %v, want not found", step.name, err)
+		}
+		if status, _, _, _ := jobSnapshot(bulk, job); status != step.want {
+			t.Errorf("status after %s = %s, want %s", step.name, status, step.want)
+		}
+	}
+
+	// Once it is no longer loaded, the finished job is only reported as such
+	// to its owner
+	bulk.jobsMutex.Lock()
+	delete(bulk.jobs, job.ID)
+	bulk.jobsMutex.Unlock()
+	if err := bulk.CancelJob(job.ID, other); !isNotFound(err) {
+		t.Errorf("other user cancelling the stored job = %v, want not found", err)
+	}
+	if _, badRequest := bulk.CancelJob(job.ID, owner).(models.BadRequestError); !badRequest {
+		t.Error("owner cancelling the stored job is not a bad request")
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(models.NotFoundError)
+	return ok
+}
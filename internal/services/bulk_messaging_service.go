@@ -2,69 +2,157 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
 	"whatsapp-multi-session/pkg/logger"
 )
 
 type BulkMessageJob struct {
 	ID           string                 `json:"id"`
+	RetryOf      string                 `json:"retry_of,omitempty"` // Job whose failed recipients this job retries
 	CampaignID   *int                   `json:"campaign_id,omitempty"`
-	SessionID    string                 `json:"session_id"`
+	UserID       int                    `json:"user_id,omitempty"` // User who started the job, 0 when an admin started it for any user
+	SessionID    string                 `json:"session_id"` // First of SessionIDs for a rotating job
+	SessionIDs   []string               `json:"session_ids,omitempty"` // Sessions a rotating job sends from in turn
+	SessionWeights map[string]int       `json:"session_weights,omitempty"` // Relative share of messages per session of SessionIDs
 	Template     *models.MessageTemplate `json:"template"`
-	Contacts     []models.Contact       `json:"contacts"`
+	Contacts     []models.Contact       `json:"contacts,omitempty"` // Left out when listing stored jobs
 	DelayBetween int                    `json:"delay_between"` // seconds
 	RandomDelay  bool                   `json:"random_delay"`
 	Variables    map[string]string      `json:"variables,omitempty"`
-	Status       string                 `json:"status"` // "pending", "running", "paused", "completed", "failed"
+	SendOptions  *models.SendOptions    `json:"send_options,omitempty"`
+	Throttle     *models.BulkThrottle   `json:"throttle"` // With the defaults filled in
+	Status       string                 `json:"status"` // "pending", "running", "paused", JobStatusThrottled, JobStatusQuietHours, "completed", "cancelled", "failed"
 	Progress     BulkMessageProgress    `json:"progress"`
 	CreatedAt    time.Time              `json:"created_at"`
 	StartedAt    *time.Time             `json:"started_at,omitempty"`
 	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
+	ProbablyBlockedContactIDs []int     `json:"probably_blocked_contact_ids,omitempty"`
+	ProbablyBlockedPhones []string      `json:"probably_blocked_phones,omitempty"`
+	InvalidRecipients []models.InvalidRecipient `json:"invalid_recipients,omitempty"` // Inline recipients skipped before the job started
+	PausedReason string                 `json:"paused_reason,omitempty"` // One of the PausedReason constants
+	ResumeAt     *time.Time             `json:"resume_at,omitempty"` // When a ban, break, rate limit or quiet hours end
+	NextIndex    int                    `json:"next_index"` // Index in Contacts of the next contact to message
+	messageIDs   map[int]string         // index in Contacts -> sent message ID
+	contents     []string               // Message to each contact, rendered when the job is created
+	stored       bool                   // Kept in the campaigns table by campaignRepo
+	paused       bool                   // Set by PauseJob until ResumeJob
+	wake         chan struct{}          // Signaled by PauseJob and ResumeJob
+	breakUntil   time.Time              // End of the break after every Throttle.BreakEvery messages
+	rotation     map[string]int         // Current weight of each rotating session, see pickSession
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
 
+// Reasons a bulk messaging job is paused
+const (
+	PausedReasonSessionBanned = "session_banned"       // Waiting out a WhatsApp ban, resumes by itself
+	PausedReasonRequested     = "requested"            // Paused through PauseJob until ResumeJob
+	PausedReasonRestart       = "server_restart"       // Reloaded after a restart, waits for ResumeJob
+	PausedReasonNoSession     = "no_session_available" // None of a rotating job's sessions can send, resumes by itself
+)
+
+// Statuses of a job held back by its throttle, which sends again by itself at ResumeAt
+const (
+	JobStatusThrottled  = "throttled"            // Taking a break or at the session's rate limit
+	JobStatusQuietHours = "sleeping_quiet_hours" // Within the quiet hours
+)
+
 type BulkMessageProgress struct {
 	Total     int `json:"total"`
 	Sent      int `json:"sent"`
 	Failed    int `json:"failed"`
 	Remaining int `json:"remaining"`
+	// ProbablyBlocked counts sent messages later classified as probably blocked
+	ProbablyBlocked int `json:"probably_blocked"`
 }
 
+// banPollInterval is how often a job paused by a session ban checks whether the ban has lifted
+const banPollInterval = 30 * time.Second
+
 type BulkMessagingService struct {
 	whatsappService *WhatsAppService
+	messageRepo     *repository.MessageRepository
+	campaignRepo    *repository.CampaignRepository
 	jobs            map[string]*BulkMessageJob
 	jobsMutex       sync.RWMutex
+	sends           map[string][]time.Time // Bulk messages sent in the last hour, by session
+	sendsMutex      sync.Mutex
 	log             logger.Logger
+
+	// Job contexts derive from ctx, which Shutdown cancels
+	ctx             context.Context
+	stop            context.CancelFunc
+	stopping        bool           // Set by Shutdown, guarded by jobsMutex
+	running         sync.WaitGroup // Jobs being processed
+	active          int            // Number of jobs being processed, guarded by jobsMutex
 }
 
-func NewBulkMessagingService(whatsappService *WhatsAppService, log logger.Logger) *BulkMessagingService {
+func NewBulkMessagingService(whatsappService *WhatsAppService, messageRepo *repository.MessageRepository, campaignRepo *repository.CampaignRepository, log logger.Logger) *BulkMessagingService {
+	ctx, stop := context.WithCancel(context.Background())
 	return &BulkMessagingService{
 		whatsappService: whatsappService,
+		messageRepo:     messageRepo,
+		campaignRepo:    campaignRepo,
 		jobs:            make(map[string]*BulkMessageJob),
+		sends:           make(map[string][]time.Time),
 		log:             log,
+		ctx:             ctx,
+		stop:            stop,
 	}
 }
 
-// StartBulkMessage creates and starts a new bulk messaging job
-func (s *BulkMessagingService) StartBulkMessage(req models.BulkMessageRequest, template *models.MessageTemplate, contacts []models.Contact) (*BulkMessageJob, error) {
+// StartBulkMessage creates and starts a new bulk messaging job sending from
+// sessions of owner, or of any user if owner is 0. Without a template,
+// req.Message is sent instead; contacts may be stored contacts or transient
+// ones made from inline recipients by BulkRecipientContacts, whose invalid
+// recipients are reported with the job.
+func (s *BulkMessagingService) StartBulkMessage(req models.BulkMessageRequest, template *models.MessageTemplate, contacts []models.Contact, invalid []models.InvalidRecipient, owner int) (*BulkMessageJob, error) {
+	sessionIDs, err := s.jobSessions(req, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.SendOptions.Validate(); err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+	if err := req.Throttle.Validate(); err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+	if template == nil {
+		if strings.TrimSpace(req.Message) == "" {
+			return nil, models.NewBadRequestError("message or template_id is required")
+		}
+		template = &models.MessageTemplate{Content: req.Message, Type: "text"}
+	}
+	if len(contacts) == 0 {
+		return nil, models.NewBadRequestError("no recipients to message")
+	}
+
 	jobID := s.generateJobID()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(s.ctx)
 	
 	job := &BulkMessageJob{
 		ID:           jobID,
-		SessionID:    req.SessionID,
+		UserID:       owner,
+		SessionID:    sessionIDs[0],
+		SessionIDs:   req.SessionIDs,
+		SessionWeights: req.SessionWeights,
 		Template:     template,
 		Contacts:     contacts,
 		DelayBetween: req.DelayBetween,
 		RandomDelay:  req.RandomDelay,
 		Variables:    req.Variables,
+		SendOptions:  req.SendOptions,
+		Throttle:     req.Throttle.WithDefaults(),
+		InvalidRecipients: invalid,
 		Status:       "pending",
 		Progress: BulkMessageProgress{
 			Total:     len(contacts),
@@ -73,36 +161,44 @@ func (s *BulkMessagingService) StartBulkMessage(req models.BulkMessageRequest, t
 			Remaining: len(contacts),
 		},
 		CreatedAt: time.Now(),
+		messageIDs: make(map[int]string),
+		wake:      make(chan struct{}, 1),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	if err := s.storeJob(job); err != nil {
+		cancel()
+		return nil, err
+	}
 	
 	s.jobsMutex.Lock()
 	s.jobs[jobID] = job
 	s.jobsMutex.Unlock()
 	
 	// Start processing in background
-	go s.processJob(job)
+	s.runJob(job)
 	
-	s.log.Info("Started bulk messaging job %s for session %s with %d contacts", jobID, req.SessionID, len(contacts))
+	s.log.Info("Started bulk messaging job %s for session %s with %d contacts", jobID, strings.Join(sessionIDs, ", "), len(contacts))
 	
 	return job, nil
 }
 
-// StartCampaignMessages creates and starts bulk messaging for a campaign
+// StartCampaignMessages creates and starts bulk messaging for a stored campaign
 func (s *BulkMessagingService) StartCampaignMessages(campaign *models.Campaign, template *models.MessageTemplate, contacts []models.Contact) (*BulkMessageJob, error) {
 	jobID := s.generateJobID()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(s.ctx)
 	
 	job := &BulkMessageJob{
 		ID:           jobID,
 		CampaignID:   &campaign.ID,
+		UserID:       campaign.UserID,
 		SessionID:    campaign.SessionID,
 		Template:     template,
 		Contacts:     contacts,
 		DelayBetween: campaign.DelayBetween,
 		RandomDelay:  campaign.RandomDelay,
 		Variables:    campaign.Variables,
+		Throttle:     campaign.Throttle.WithDefaults(),
 		Status:       "pending",
 		Progress: BulkMessageProgress{
 			Total:     len(contacts),
@@ -111,48 +207,91 @@ func (s *BulkMessagingService) StartCampaignMessages(campaign *models.Campaign,
 			Remaining: len(contacts),
 		},
 		CreatedAt: time.Now(),
+		messageIDs: make(map[int]string),
+		wake:      make(chan struct{}, 1),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	// The job is stored in the campaign's row
+	if err := s.storeJob(job); err != nil {
+		cancel()
+		return nil, err
+	}
 	
 	s.jobsMutex.Lock()
 	s.jobs[jobID] = job
 	s.jobsMutex.Unlock()
 	
 	// Start processing in background
-	go s.processJob(job)
+	s.runJob(job)
 	
 	s.log.Info("Started campaign bulk messaging job %s for campaign %d with %d contacts", jobID, campaign.ID, len(contacts))
 	
 	return job, nil
 }
 
-// processJob processes messages for a bulk messaging job
+// processJob messages the job's contacts from NextIndex on. A pause takes
+// effect after the message being sent and a resume continues with the next
+// contact, so the loop can be suspended any number of times.
 func (s *BulkMessagingService) processJob(job *BulkMessageJob) {
 	defer func() {
 		if r := recover(); r != nil {
 			s.log.Error("Bulk messaging job %s panicked: %v", job.ID, r)
-			job.Status = "failed"
+			s.setJobStatus(job, "failed")
 		}
 	}()
 	
-	job.Status = "running"
-	now := time.Now()
-	job.StartedAt = &now
+	s.jobsMutex.Lock()
+	if !job.paused {
+		// Jobs reloaded after a restart wait for ResumeJob
+		job.Status = "running"
+		job.start()
+	}
+	s.jobsMutex.Unlock()
+	s.saveJob(job)
 	
 	s.log.Info("Processing bulk messaging job %s", job.ID)
 	
-	for i, contact := range job.Contacts {
-		select {
-		case <-job.ctx.Done():
-			s.log.Info("Bulk messaging job %s was cancelled", job.ID)
-			job.Status = "cancelled"
+	for {
+		if !s.waitWhilePaused(job) {
+			s.jobStopped(job)
 			return
-		default:
+		}
+		
+		s.jobsMutex.RLock()
+		i := job.NextIndex
+		s.jobsMutex.RUnlock()
+		if i >= len(job.Contacts) {
+			break
+		}
+		contact := job.Contacts[i]
+		
+		// Pick the session, waiting out a ban of the session or, when
+		// rotating, until one of the sessions can send
+		sessionID, ok := s.waitForSession(job)
+		if !ok {
+			s.jobStopped(job)
+			return
+		}
+		if s.pauseRequested(job) {
+			// Paused while waiting; the contact is sent after resuming
+			continue
+		}
+		
+		// Hold back while the throttle does not allow another message
+		if !s.waitForThrottle(job, sessionID) {
+			s.jobStopped(job)
+			return
+		}
+		if s.pauseRequested(job) {
+			continue
 		}
 		
 		// Process individual message
-		success := s.processMessage(job, contact, i)
+		success, retry := s.processMessage(job, contact, i, sessionID)
+		if retry {
+			continue
+		}
 		
 		// Update progress
 		s.jobsMutex.Lock()
@@ -162,58 +301,310 @@ func (s *BulkMessagingService) processJob(job *BulkMessageJob) {
 			job.Progress.Failed++
 		}
 		job.Progress.Remaining = job.Progress.Total - job.Progress.Sent - job.Progress.Failed
+		job.NextIndex = i + 1
 		s.jobsMutex.Unlock()
+		s.saveJob(job)
+		
+		if every := job.Throttle.BreakEvery; every > 0 && (i+1)%every == 0 && i < len(job.Contacts)-1 {
+			job.breakUntil = time.Now().Add(time.Duration(job.Throttle.BreakSeconds) * time.Second)
+		}
 		
 		// Add delay between messages (except for last message)
 		if i < len(job.Contacts)-1 {
 			delay := s.calculateDelay(job)
 			s.log.Debug("Waiting %d seconds before next message", delay)
 			
-			select {
-			case <-job.ctx.Done():
-				job.Status = "cancelled"
-				return
-			case <-time.After(time.Duration(delay) * time.Second):
-				// Continue to next message
+			timer := time.NewTimer(time.Duration(delay) * time.Second)
+			for waiting := true; waiting; {
+				select {
+				case <-job.ctx.Done():
+					timer.Stop()
+					s.jobStopped(job)
+					return
+				case <-job.wake:
+					// When paused, the next contact waits for the resume instead of the delay
+					waiting = !s.pauseRequested(job)
+				case <-timer.C:
+					waiting = false
+				}
 			}
+			timer.Stop()
 		}
 	}
 	
 	// Mark job as completed
+	s.jobsMutex.Lock()
 	job.Status = "completed"
-	now = time.Now()
+	now := time.Now()
 	job.CompletedAt = &now
+	s.jobsMutex.Unlock()
+	s.saveJob(job)
 	
 	s.log.Info("Bulk messaging job %s completed. Sent: %d, Failed: %d", 
 		job.ID, job.Progress.Sent, job.Progress.Failed)
 }
 
-// processMessage sends a single message
-func (s *BulkMessagingService) processMessage(job *BulkMessageJob, contact models.Contact, index int) bool {
-	// Generate personalized message content
-	content, err := s.generateMessageContent(job.Template, contact, job.Variables)
-	if err != nil {
-		s.log.Error("Failed to generate message content for contact %d in job %s: %v", contact.ID, job.ID, err)
-		return false
+// runJob processes the job in the background. Jobs started while shutting
+// down are not processed; they are stored and restored after the restart.
+func (s *BulkMessagingService) runJob(job *BulkMessageJob) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if s.stopping {
+		return
+	}
+
+	s.running.Add(1)
+	s.active++
+	go func() {
+		defer func() {
+			s.jobsMutex.Lock()
+			s.active--
+			s.jobsMutex.Unlock()
+			s.running.Done()
+		}()
+		s.processJob(job)
+	}()
+}
+
+// jobStopped records that the job's context was cancelled: by CancelJob, or
+// by Shutdown, which keeps the job's status and stores its progress so
+// RestoreJobs picks it up after the restart
+func (s *BulkMessagingService) jobStopped(job *BulkMessageJob) {
+	s.jobsMutex.RLock()
+	stopping := s.stopping
+	next := job.NextIndex
+	s.jobsMutex.RUnlock()
+
+	if stopping {
+		s.saveJob(job)
+		s.log.Info("Bulk messaging job %s stopped for shutdown before contact %d/%d", job.ID, next+1, len(job.Contacts))
+		return
 	}
+	s.log.Info("Bulk messaging job %s was cancelled", job.ID)
+	s.setJobStatus(job, "cancelled")
+}
+
+// Shutdown stops the jobs being processed once the message each is sending
+// is done and stores their progress. It waits until ctx is done and returns
+// how many jobs stopped in time and how many were abandoned mid-send.
+func (s *BulkMessagingService) Shutdown(ctx context.Context) (drained, abandoned int) {
+	s.jobsMutex.Lock()
+	s.stopping = true
+	started := s.active
+	s.jobsMutex.Unlock()
+	s.stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	s.jobsMutex.RLock()
+	abandoned = s.active
+	s.jobsMutex.RUnlock()
+	return started - abandoned, abandoned
+}
+
+// waitWhilePaused blocks while the job is paused by PauseJob, until
+// ResumeJob is called. It returns false if the job was cancelled.
+func (s *BulkMessagingService) waitWhilePaused(job *BulkMessageJob) bool {
+	for {
+		if !s.pauseRequested(job) {
+			return true
+		}
+		
+		select {
+		case <-job.ctx.Done():
+			return false
+		case <-job.wake:
+		}
+	}
+}
+
+// pauseRequested reports whether PauseJob was called and not yet resumed
+func (s *BulkMessagingService) pauseRequested(job *BulkMessageJob) bool {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+	return job.paused
+}
+
+// setJobStatus sets the status of a job
+func (s *BulkMessagingService) setJobStatus(job *BulkMessageJob, status string) {
+	s.jobsMutex.Lock()
+	job.Status = status
+	s.jobsMutex.Unlock()
+	s.saveJob(job)
+}
+
+// waitWhileBanned pauses the job while its session is temporarily banned and
+// resumes it once the ban lifts. It returns early when the job is paused by
+// PauseJob meanwhile, and false if the job was cancelled.
+func (s *BulkMessagingService) waitWhileBanned(job *BulkMessageJob) bool {
+	for {
+		until, banned := s.whatsappService.SessionBannedUntil(job.SessionID)
+		
+		s.jobsMutex.Lock()
+		if job.paused {
+			// The pause outlasts the ban; waitWhilePaused takes over
+			s.jobsMutex.Unlock()
+			return true
+		}
+		if !banned {
+			lifted := job.PausedReason == PausedReasonSessionBanned
+			if lifted {
+				job.Status = "running"
+				job.PausedReason = ""
+				job.ResumeAt = nil
+				s.log.Info("Resumed bulk messaging job %s after session %s ban lifted", job.ID, job.SessionID)
+			}
+			s.jobsMutex.Unlock()
+			if lifted {
+				s.saveJob(job)
+			}
+			return true
+		}
+		newlyBanned := job.PausedReason != PausedReasonSessionBanned
+		if newlyBanned {
+			s.log.Warn("Paused bulk messaging job %s: session %s is banned until %s", job.ID, job.SessionID, models.FormatTimestamp(until))
+		}
+		job.Status = "paused"
+		job.PausedReason = PausedReasonSessionBanned
+		job.ResumeAt = &until
+		s.jobsMutex.Unlock()
+		if newlyBanned {
+			s.saveJob(job)
+		}
+		
+		select {
+		case <-job.ctx.Done():
+			return false
+		case <-job.wake:
+		case <-time.After(banPollInterval):
+		}
+	}
+}
+
+// processMessage sends a single message from sessionID. retry is true when
+// the send was rejected because the session is banned, or the session of a
+// rotating job dropped, so the contact should be sent again.
+func (s *BulkMessagingService) processMessage(job *BulkMessageJob, contact models.Contact, index int, sessionID string) (sent bool, retry bool) {
+	if contact.Phone == "" {
+		// The contact was deleted while the job was stored
+		s.log.Warn("Skipping deleted contact %d/%d in job %s", index+1, len(job.Contacts), job.ID)
+		return false, false
+	}
+	content := job.contents[index]
 	
 	// Create message request
 	messageReq := &models.SendMessageRequest{
 		To:      contact.Phone,
 		Message: content,
 	}
+	if job.SendOptions != nil {
+		messageReq.SendOptions = *job.SendOptions
+	}
 	
 	// Send message
-	_, err = s.whatsappService.SendMessage(job.SessionID, messageReq)
+	messageID, err := s.whatsappService.SendMessage(sessionID, messageReq)
 	if err != nil {
+		var bannedErr models.SessionBannedError
+		if errors.As(err, &bannedErr) {
+			return false, true
+		}
+		if job.rotating() && !s.sessionAvailable(sessionID) {
+			s.log.Warn("Session %s of job %s dropped, sending to %s from another session", sessionID, job.ID, contact.Phone)
+			return false, true
+		}
 		s.log.Error("Failed to send message to %s in job %s: %v", contact.Phone, job.ID, err)
-		return false
+		s.recordFailedMessage(job, index, sessionID, err)
+		return false, false
+	}
+	s.recordSend(sessionID, time.Now())
+	
+	s.recordSentMessage(job, contact, index, sessionID, messageID, content)
+	
+	s.log.Debug("Sent message %d/%d to %s (%s) from session %s in job %s", 
+		index+1, len(job.Contacts), contact.Phone, contact.Name, sessionID, job.ID)
+	
+	return true, false
+}
+
+// recordSentMessage logs a message sent from sessionID so delivery receipts
+// and block detection can update it, and remembers it for the job's results
+func (s *BulkMessagingService) recordSentMessage(job *BulkMessageJob, contact models.Contact, index int, sessionID, messageID, content string) {
+	s.jobsMutex.Lock()
+	job.messageIDs[index] = messageID
+	s.jobsMutex.Unlock()
+	
+	now := time.Now()
+	if job.stored {
+		if err := s.campaignRepo.UpdateBulkJobMessage(*job.CampaignID, index, sessionID, models.BulkResultSent, messageID, "", &now); err != nil {
+			s.log.Error("Failed to store sent message %s of job %s: %v", messageID, job.ID, err)
+		}
+	}
+	
+	if s.messageRepo == nil {
+		return
+	}
+	
+	err := s.messageRepo.LogMessage(&repository.Message{
+		SessionID:    sessionID,
+		MessageID:    messageID,
+		RecipientJID: ChatJID(contact.Phone),
+		MessageType:  "text",
+		Content:      content,
+		Direction:    "sent",
+		Status:       "sent",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		s.log.Error("Failed to log bulk message %s in job %s: %v", messageID, job.ID, err)
+	}
+}
+
+// refreshDeliveryResults updates the job's probably-blocked results from the stored message statuses
+func (s *BulkMessagingService) refreshDeliveryResults(job *BulkMessageJob) {
+	if s.messageRepo == nil || len(job.messageIDs) == 0 {
+		return
 	}
 	
-	s.log.Debug("Sent message %d/%d to %s (%s) in job %s", 
-		index+1, len(job.Contacts), contact.Phone, contact.Name, job.ID)
+	ids := make([]string, 0, len(job.messageIDs))
+	for _, id := range job.messageIDs {
+		ids = append(ids, id)
+	}
 	
-	return true
+	statuses, err := s.messageRepo.GetMessageStatuses(ids)
+	if err != nil {
+		s.log.Error("Failed to refresh delivery results for job %s: %v", job.ID, err)
+		return
+	}
+	
+	// Stored contacts are reported by ID, inline recipients by number
+	blocked := []int{}
+	var blockedPhones []string
+	for index, messageID := range job.messageIDs {
+		if statuses[messageID] != repository.StatusProbablyBlocked {
+			continue
+		}
+		if contact := job.Contacts[index]; contact.ID != 0 {
+			blocked = append(blocked, contact.ID)
+		} else {
+			blockedPhones = append(blockedPhones, contact.Phone)
+		}
+	}
+	sort.Ints(blocked)
+	sort.Strings(blockedPhones)
+	
+	job.ProbablyBlockedContactIDs = blocked
+	job.ProbablyBlockedPhones = blockedPhones
+	job.Progress.ProbablyBlocked = len(blocked) + len(blockedPhones)
 }
 
 // generateMessageContent creates personalized message content
@@ -289,71 +680,218 @@ func (s *BulkMessagingService) calculateDelay(job *BulkMessageJob) int {
 	return finalDelay
 }
 
-// GetJob returns a job by ID
-func (s *BulkMessagingService) GetJob(jobID string) (*BulkMessageJob, error) {
-	s.jobsMutex.RLock()
-	defer s.jobsMutex.RUnlock()
-	
+// GetJob returns a job userID started, or any job if userID is 0, by ID,
+// loading finished jobs from the database
+func (s *BulkMessagingService) GetJob(jobID string, userID int) (*BulkMessageJob, error) {
+	s.jobsMutex.Lock()
 	job, exists := s.jobs[jobID]
-	if !exists {
-		return nil, fmt.Errorf("job not found")
+	if exists && job.ownedBy(userID) {
+		s.refreshDeliveryResults(job)
+	}
+	s.jobsMutex.Unlock()
+	if exists {
+		if !job.ownedBy(userID) {
+			return nil, models.NewNotFoundError("job %s not found", jobID)
+		}
+		return job, nil
+	}
+	
+	if s.campaignRepo == nil {
+		return nil, models.NewNotFoundError("job %s not found", jobID)
+	}
+	stored, err := s.campaignRepo.GetBulkJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	job, err = s.loadJob(stored)
+	if err != nil {
+		return nil, err
 	}
+	s.refreshDeliveryResults(job)
 	
 	return job, nil
 }
 
-// GetJobs returns all jobs
-func (s *BulkMessagingService) GetJobs() []*BulkMessageJob {
-	s.jobsMutex.RLock()
-	defer s.jobsMutex.RUnlock()
+// GetJobs returns the jobs userID started, or all jobs if userID is 0, newest
+// first: the jobs of this process with their live state, and the finished jobs
+// stored in the database without contacts
+func (s *BulkMessagingService) GetJobs(userID int) ([]*BulkMessageJob, error) {
+	var stored []*repository.BulkJob
+	if s.campaignRepo != nil {
+		var err error
+		if stored, err = s.campaignRepo.GetBulkJobs(userID); err != nil {
+			return nil, err
+		}
+	}
 	
-	jobs := make([]*BulkMessageJob, 0, len(s.jobs))
+	s.jobsMutex.RLock()
+	jobs := make([]*BulkMessageJob, 0, len(s.jobs)+len(stored))
 	for _, job := range s.jobs {
-		jobs = append(jobs, job)
+		if job.ownedBy(userID) {
+			jobs = append(jobs, job)
+		}
 	}
+	for _, record := range stored {
+		if _, live := s.jobs[record.JobID]; !live {
+			jobs = append(jobs, jobFromRecord(record))
+		}
+	}
+	s.jobsMutex.RUnlock()
 	
-	return jobs
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// PauseJob pauses a running job after the message being sent. A job paused
+// by a session ban can be paused too, so it stays paused when the ban lifts.
+// Only the user who started the job, or an admin passing 0 as userID, may
+// pause it.
+func (s *BulkMessagingService) PauseJob(jobID string, userID int) error {
+	return s.changeJob(jobID, userID, s.pauseJob)
 }
 
-// PauseJob pauses a running job
-func (s *BulkMessagingService) PauseJob(jobID string) error {
+func (s *BulkMessagingService) pauseJob(jobID string, userID int) (*BulkMessageJob, error) {
 	s.jobsMutex.Lock()
 	defer s.jobsMutex.Unlock()
 	
-	job, exists := s.jobs[jobID]
-	if !exists {
-		return fmt.Errorf("job not found")
+	job, err := s.ownedJob(jobID, userID)
+	if err != nil {
+		return nil, err
 	}
 	
-	if job.Status != "running" {
-		return fmt.Errorf("job is not running")
+	switch {
+	case job.paused:
+		return nil, models.NewBadRequestError("job %s is already paused", jobID)
+	case job.Status == "pending":
+		return nil, models.NewBadRequestError("job %s has not started yet", jobID)
+	case job.Status != "running" && job.Status != "paused" && job.Status != JobStatusThrottled && job.Status != JobStatusQuietHours:
+		return nil, models.NewBadRequestError("job %s is %s and cannot be paused", jobID, job.Status)
 	}
 	
-	job.cancel()
+	job.paused = true
 	job.Status = "paused"
+	job.PausedReason = PausedReasonRequested
+	job.ResumeAt = nil
+	job.signal()
 	
-	s.log.Info("Paused bulk messaging job %s", jobID)
-	return nil
+	s.log.Info("Paused bulk messaging job %s at contact %d/%d", jobID, job.NextIndex+1, len(job.Contacts))
+	return job, nil
 }
 
-// CancelJob cancels a job
-func (s *BulkMessagingService) CancelJob(jobID string) error {
+// ResumeJob continues a job paused by PauseJob with its next contact. If the
+// session is still banned, or none of a rotating job's sessions can send, the
+// job goes on waiting. Only the user who started the job, or an admin passing
+// 0 as userID, may resume it.
+func (s *BulkMessagingService) ResumeJob(jobID string, userID int) error {
+	return s.changeJob(jobID, userID, s.resumeJob)
+}
+
+func (s *BulkMessagingService) resumeJob(jobID string, userID int) (*BulkMessageJob, error) {
 	s.jobsMutex.Lock()
 	defer s.jobsMutex.Unlock()
 	
-	job, exists := s.jobs[jobID]
-	if !exists {
-		return fmt.Errorf("job not found")
+	job, err := s.ownedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	if !job.paused {
+		if job.PausedReason == PausedReasonSessionBanned && job.ResumeAt != nil {
+			return nil, models.NewBadRequestError("job %s is waiting for the session ban to lift and resumes by itself at %s",
+				jobID, models.FormatTimestamp(*job.ResumeAt))
+		}
+		if job.PausedReason == PausedReasonNoSession {
+			return nil, models.NewBadRequestError("job %s is waiting for one of its sessions to reconnect and resumes by itself", jobID)
+		}
+		return nil, models.NewBadRequestError("job %s is %s, only paused jobs can be resumed", jobID, job.Status)
+	}
+	
+	job.paused = false
+	job.Status = "running"
+	job.PausedReason = ""
+	job.start()
+	job.signal()
+	
+	s.log.Info("Resumed bulk messaging job %s at contact %d/%d", jobID, job.NextIndex+1, len(job.Contacts))
+	return job, nil
+}
+
+// start records when a job first runs
+func (job *BulkMessageJob) start() {
+	if job.StartedAt == nil {
+		now := time.Now()
+		job.StartedAt = &now
+	}
+}
+
+// ownedBy reports whether the user may see and change the job, which admins,
+// passing 0, may for every job
+func (job *BulkMessageJob) ownedBy(userID int) bool {
+	return userID == 0 || job.UserID == userID
+}
+
+// signal wakes the job loop if it is waiting, without blocking
+func (job *BulkMessageJob) signal() {
+	select {
+	case job.wake <- struct{}{}:
+	default:
+	}
+}
+
+// CancelJob cancels a pending, running or paused job userID started, or any
+// such job if userID is 0
+func (s *BulkMessagingService) CancelJob(jobID string, userID int) error {
+	return s.changeJob(jobID, userID, s.cancelJob)
+}
+
+func (s *BulkMessagingService) cancelJob(jobID string, userID int) (*BulkMessageJob, error) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	
+	job, err := s.ownedJob(jobID, userID)
+	if err != nil {
+		return nil, err
 	}
 	
-	if job.Status == "completed" || job.Status == "cancelled" {
-		return fmt.Errorf("job is already %s", job.Status)
+	if job.Status == "completed" || job.Status == "cancelled" || job.Status == "failed" {
+		return nil, models.NewBadRequestError("job %s is already %s", jobID, job.Status)
 	}
 	
 	job.cancel()
+	job.paused = false
 	job.Status = "cancelled"
+	job.PausedReason = ""
+	job.ResumeAt = nil
 	
 	s.log.Info("Cancelled bulk messaging job %s", jobID)
+	return job, nil
+}
+
+// ownedJob returns a job of this process that userID started, or any job if
+// userID is 0. Jobs of other users are not found. The caller holds jobsMutex.
+func (s *BulkMessagingService) ownedJob(jobID string, userID int) (*BulkMessageJob, error) {
+	job, exists := s.jobs[jobID]
+	if !exists || !job.ownedBy(userID) {
+		return nil, models.NewNotFoundError("job %s not found", jobID)
+	}
+	return job, nil
+}
+
+// changeJob applies a state change to a job of this process that userID may
+// change and stores the result. Stored jobs that are not loaded have finished,
+// so they are reported as such rather than as unknown.
+func (s *BulkMessagingService) changeJob(jobID string, userID int, change func(string, int) (*BulkMessageJob, error)) error {
+	job, err := change(jobID, userID)
+	if _, ok := err.(models.NotFoundError); ok && s.campaignRepo != nil {
+		if stored, storedErr := s.campaignRepo.GetBulkJob(jobID, userID); storedErr == nil {
+			return models.NewBadRequestError("job %s is already %s", jobID, stored.Status)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	
+	s.saveJob(job)
 	return nil
 }
 
@@ -468,6 +1006,7 @@ type JobSummary struct {
 	ID          string              `json:"id"`
 	CampaignID  *int                `json:"campaign_id,omitempty"`
 	SessionID   string              `json:"session_id"`
+	SessionIDs  []string            `json:"session_ids,omitempty"`
 	Status      string              `json:"status"`
 	Progress    BulkMessageProgress `json:"progress"`
 	CreatedAt   time.Time           `json:"created_at"`
@@ -475,9 +1014,10 @@ type JobSummary struct {
 	CompletedAt *time.Time          `json:"completed_at,omitempty"`
 }
 
-// GetJobSummary returns a simplified job summary
-func (s *BulkMessagingService) GetJobSummary(jobID string) (*JobSummary, error) {
-	job, err := s.GetJob(jobID)
+// GetJobSummary returns a simplified job summary of a job userID started, or
+// of any job if userID is 0
+func (s *BulkMessagingService) GetJobSummary(jobID string, userID int) (*JobSummary, error) {
+	job, err := s.GetJob(jobID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -486,6 +1026,7 @@ func (s *BulkMessagingService) GetJobSummary(jobID string) (*JobSummary, error)
 		ID:          job.ID,
 		CampaignID:  job.CampaignID,
 		SessionID:   job.SessionID,
+		SessionIDs:  job.SessionIDs,
 		Status:      job.Status,
 		Progress:    job.Progress,
 		CreatedAt:   job.CreatedAt,
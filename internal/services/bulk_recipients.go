@@ -0,0 +1,97 @@
+package services
+
+import (
+	"strings"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// BulkRecipientContacts wraps inline bulk recipients into transient contacts,
+// which have no ID and are not stored. Numbers are normalized to E.164,
+// reading national numbers in region; numbers that cannot be parsed are
+// returned as invalid, and repeated numbers are only messaged once. Recipients
+// without a name are named by their number.
+func BulkRecipientContacts(recipients []models.BulkRecipient, region string) ([]models.Contact, []models.InvalidRecipient) {
+	contacts := make([]models.Contact, 0, len(recipients))
+	invalid := []models.InvalidRecipient{}
+	seen := make(map[string]int, len(recipients)) // E.164 -> index in contacts
+
+	for i, recipient := range recipients {
+		number, err := phone.Parse(recipient.Phone, region)
+		if err != nil {
+			invalid = append(invalid, models.InvalidRecipient{Index: i, Phone: recipient.Phone, Reason: err.Error()})
+			continue
+		}
+
+		name := strings.TrimSpace(recipient.Name)
+		if index, exists := seen[number.E164]; exists {
+			// A repeat may still name a number given bare before
+			if contact := &contacts[index]; name != "" && contact.Name == contact.Phone {
+				contact.Name = name
+			}
+			continue
+		}
+		seen[number.E164] = len(contacts)
+
+		if name == "" {
+			name = number.E164
+		}
+		contacts = append(contacts, models.Contact{Name: name, Phone: number.E164, IsActive: true})
+	}
+	return contacts, invalid
+}
+
+// LeaveOutUnregistered drops the contacts of a bulk job that are not on
+// WhatsApp, checked from the first session the job would send from, and
+// reports them with the invalid recipients. Inline recipients are reported
+// by their position in req.Recipients, read in region as by
+// BulkRecipientContacts; stored contacts by their position among contacts.
+// Contacts whose number cannot be read are kept, to fail when messaged.
+func (s *BulkMessagingService) LeaveOutUnregistered(req models.BulkMessageRequest, contacts []models.Contact, invalid []models.InvalidRecipient, region string, owner int) ([]models.Contact, []models.InvalidRecipient, error) {
+	sessionIDs, err := s.jobSessions(req, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := s.whatsappService.loggedInSession(sessionIDs[0])
+	if err != nil {
+		return nil, nil, models.NewServiceUnavailableError("cannot check numbers from session %s: %v", sessionIDs[0], err)
+	}
+
+	phones := make([]string, len(contacts))
+	checked := make([]string, 0, len(contacts))
+	for i, contact := range contacts {
+		if number, err := phone.Parse(contact.Phone, phone.DefaultRegion()); err == nil {
+			phones[i] = number.E164
+			checked = append(checked, number.E164)
+		}
+	}
+	registered, err := s.whatsappService.registeredNumbers(session, checked)
+	if err != nil {
+		return nil, nil, models.NewServiceUnavailableError("%v", err)
+	}
+
+	kept := make([]models.Contact, 0, len(contacts))
+	leftOut := make(map[string]bool)
+	for i, contact := range contacts {
+		if _, ok := registered[phones[i]]; ok || phones[i] == "" {
+			kept = append(kept, contact)
+			continue
+		}
+		if len(req.Recipients) == 0 {
+			invalid = append(invalid, models.InvalidRecipient{Index: i, Phone: contact.Phone, ContactID: contact.ID, Reason: models.InvalidReasonNotOnWhatsApp})
+		}
+		leftOut[phones[i]] = true
+	}
+
+	// Inline recipients are reported once, at the first mention of the number
+	for i, recipient := range req.Recipients {
+		number, err := phone.Parse(recipient.Phone, region)
+		if err != nil || !leftOut[number.E164] {
+			continue
+		}
+		delete(leftOut, number.E164)
+		invalid = append(invalid, models.InvalidRecipient{Index: i, Phone: recipient.Phone, Reason: models.InvalidReasonNotOnWhatsApp})
+	}
+	return kept, invalid, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// A bulk messaging job given session_ids rotates between those sessions to
+// spread its volume: each message goes from the next available session by
+// smooth weighted round-robin, so with equal weights the sessions take turns.
+
+// sessionPollInterval is how often a rotating job without an available
+// session checks whether one has come back
+const sessionPollInterval = 30 * time.Second
+
+// jobSessions validates the sessions a bulk request sends from and returns
+// them: the sessions of session_ids to rotate between, or session_id. Every
+// session must belong to owner unless owner is 0, and sessions to rotate
+// between must be logged in.
+func (s *BulkMessagingService) jobSessions(req models.BulkMessageRequest, owner int) ([]string, error) {
+	if len(req.SessionIDs) == 0 {
+		switch {
+		case req.SessionID == "":
+			return nil, models.NewBadRequestError("session_id or session_ids is required")
+		case len(req.SessionWeights) > 0:
+			return nil, models.NewBadRequestError("session_weights require session_ids")
+		}
+		if err := s.checkSessionOwner(req.SessionID, owner); err != nil {
+			return nil, err
+		}
+		return []string{req.SessionID}, nil
+	}
+	if req.SessionID != "" {
+		return nil, models.NewBadRequestError("session_id and session_ids cannot be combined")
+	}
+
+	listed := make(map[string]bool, len(req.SessionIDs))
+	for _, sessionID := range req.SessionIDs {
+		switch {
+		case sessionID == "":
+			return nil, models.NewBadRequestError("session_ids cannot contain an empty session ID")
+		case listed[sessionID]:
+			return nil, models.NewBadRequestError("session %s is listed twice in session_ids", sessionID)
+		}
+		listed[sessionID] = true
+
+		if err := s.checkSessionOwner(sessionID, owner); err != nil {
+			return nil, err
+		}
+		if _, err := s.whatsappService.loggedInSession(sessionID); err != nil {
+			return nil, models.NewBadRequestError("session %s cannot send: %v", sessionID, err)
+		}
+	}
+	for sessionID, weight := range req.SessionWeights {
+		if !listed[sessionID] {
+			return nil, models.NewBadRequestError("session_weights names session %s, which is not in session_ids", sessionID)
+		}
+		if weight <= 0 {
+			return nil, models.NewBadRequestError("session_weights must be positive")
+		}
+	}
+	return req.SessionIDs, nil
+}
+
+// checkSessionOwner verifies that a session belongs to owner, unless owner is 0
+func (s *BulkMessagingService) checkSessionOwner(sessionID string, owner int) error {
+	if owner == 0 {
+		return nil
+	}
+	owned, err := s.whatsappService.IsSessionOwnedByUser(sessionID, owner)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return models.NewForbiddenError("session %s does not belong to you", sessionID)
+	}
+	return nil
+}
+
+// rotating reports whether the job rotates between sessions
+func (job *BulkMessageJob) rotating() bool {
+	return len(job.SessionIDs) > 0
+}
+
+// sessionWeight returns the relative share of messages of a rotating session
+func (job *BulkMessageJob) sessionWeight(sessionID string) int {
+	if weight := job.SessionWeights[sessionID]; weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// waitForSession returns the session to send the job's next message from. A
+// job with one session waits out any ban of it. A rotating job skips sessions
+// that are disconnected, logged out or banned; while none is left it is
+// paused, and resumes by itself once one is back. It returns early when the
+// job is paused by PauseJob, and false if the job was cancelled.
+func (s *BulkMessagingService) waitForSession(job *BulkMessageJob) (string, bool) {
+	if !job.rotating() {
+		return job.SessionID, s.waitWhileBanned(job)
+	}
+
+	for {
+		sessionID := s.pickSession(job, time.Now())
+
+		s.jobsMutex.Lock()
+		if job.paused {
+			s.jobsMutex.Unlock()
+			return sessionID, true
+		}
+		if sessionID != "" {
+			recovered := job.PausedReason == PausedReasonNoSession
+			if recovered {
+				job.Status = "running"
+				job.PausedReason = ""
+				s.log.Info("Resumed bulk messaging job %s: session %s is available again", job.ID, sessionID)
+			}
+			s.jobsMutex.Unlock()
+			if recovered {
+				s.saveJob(job)
+			}
+			return sessionID, true
+		}
+		lost := job.PausedReason != PausedReasonNoSession
+		if lost {
+			s.log.Warn("Paused bulk messaging job %s: none of its sessions can send", job.ID)
+		}
+		job.Status = "paused"
+		job.PausedReason = PausedReasonNoSession
+		job.ResumeAt = nil
+		s.jobsMutex.Unlock()
+		if lost {
+			s.saveJob(job)
+		}
+
+		select {
+		case <-job.ctx.Done():
+			return "", false
+		case <-job.wake:
+		case <-time.After(sessionPollInterval):
+		}
+	}
+}
+
+// pickSession returns the next session of a rotating job among those that can
+// send at now, or "" if none can. Sessions under their rate limits come first,
+// so one session at its limit does not hold the job back while another could
+// send.
+func (s *BulkMessagingService) pickSession(job *BulkMessageJob, now time.Time) string {
+	var available, ready []string
+	for _, sessionID := range job.SessionIDs {
+		if !s.sessionAvailable(sessionID) {
+			continue
+		}
+		available = append(available, sessionID)
+		if s.rateLimitedUntil(sessionID, job.Throttle, now).IsZero() {
+			ready = append(ready, sessionID)
+		}
+	}
+	candidates := ready
+	if len(candidates) == 0 {
+		candidates = available
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	// Smooth weighted round-robin: every candidate gains its weight, the one
+	// ahead is picked and falls back by the total
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if job.rotation == nil {
+		job.rotation = make(map[string]int)
+	}
+	total := 0
+	picked := ""
+	for _, sessionID := range candidates {
+		weight := job.sessionWeight(sessionID)
+		job.rotation[sessionID] += weight
+		total += weight
+		if picked == "" || job.rotation[sessionID] > job.rotation[picked] {
+			picked = sessionID
+		}
+	}
+	job.rotation[picked] -= total
+	return picked
+}
+
+// sessionAvailable reports whether a session is logged in and not banned
+func (s *BulkMessagingService) sessionAvailable(sessionID string) bool {
+	if _, err := s.whatsappService.loggedInSession(sessionID); err != nil {
+		return false
+	}
+	_, banned := s.whatsappService.SessionBannedUntil(sessionID)
+	return !banned
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// waitForThrottle holds a job back while its throttle does not allow another
+// message from sessionID: within quiet hours, during a break, or while the
+// session is at its per-minute or per-hour limit. The job's status tells
+// which, with ResumeAt set to when it sends again. It returns early when the
+// job is paused by PauseJob, and false if the job was cancelled.
+func (s *BulkMessagingService) waitForThrottle(job *BulkMessageJob, sessionID string) bool {
+	for {
+		status, until := s.throttledUntil(job, sessionID, time.Now())
+
+		s.jobsMutex.Lock()
+		if job.paused {
+			s.jobsMutex.Unlock()
+			return true
+		}
+		if until.IsZero() {
+			held := job.Status == JobStatusThrottled || job.Status == JobStatusQuietHours
+			if held {
+				job.Status = "running"
+				job.ResumeAt = nil
+			}
+			s.jobsMutex.Unlock()
+			if held {
+				s.saveJob(job)
+			}
+			return true
+		}
+		changed := job.Status != status
+		job.Status = status
+		job.ResumeAt = &until
+		s.jobsMutex.Unlock()
+		if changed {
+			s.log.Info("Bulk messaging job %s is %s until %s", job.ID, status, models.FormatTimestamp(until))
+			s.saveJob(job)
+		}
+
+		timer := time.NewTimer(time.Until(until))
+		select {
+		case <-job.ctx.Done():
+			timer.Stop()
+			return false
+		case <-job.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// throttledUntil returns the status of a job held back by its throttle from
+// sending from sessionID at now and until when, or the zero time if it may send
+func (s *BulkMessagingService) throttledUntil(job *BulkMessageJob, sessionID string, now time.Time) (string, time.Time) {
+	throttle := job.Throttle
+	if throttle.QuietHours != nil {
+		if until := throttle.QuietHours.Until(now); !until.IsZero() {
+			return JobStatusQuietHours, until
+		}
+	}
+	if job.breakUntil.After(now) {
+		return JobStatusThrottled, job.breakUntil
+	}
+	if until := s.rateLimitedUntil(sessionID, throttle, now); !until.IsZero() {
+		return JobStatusThrottled, until
+	}
+	return "", time.Time{}
+}
+
+// rateLimitedUntil returns until when a session is at the per-minute or
+// per-hour limit of throttle at now, or the zero time if it is not
+func (s *BulkMessagingService) rateLimitedUntil(sessionID string, throttle *models.BulkThrottle, now time.Time) time.Time {
+	s.sendsMutex.Lock()
+	defer s.sendsMutex.Unlock()
+	sends := s.recentSends(sessionID, now)
+
+	// The next message may go once the oldest of the last max sends leaves the window
+	var until time.Time
+	if max := throttle.MaxPerHour; max > 0 && len(sends) >= max {
+		until = sends[len(sends)-max].Add(time.Hour)
+	}
+	if max := throttle.MaxPerMinute; max > 0 && len(sends) >= max {
+		if next := sends[len(sends)-max].Add(time.Minute); next.After(now) && next.After(until) {
+			until = next
+		}
+	}
+	return until
+}
+
+// recordSend counts a bulk message of a session against its rate limits
+func (s *BulkMessagingService) recordSend(sessionID string, at time.Time) {
+	s.sendsMutex.Lock()
+	defer s.sendsMutex.Unlock()
+	s.sends[sessionID] = append(s.recentSends(sessionID, at), at)
+}
+
+// recentSends returns the bulk messages of a session sent within the hour
+// before now, oldest first, forgetting older ones. The caller holds sendsMutex.
+func (s *BulkMessagingService) recentSends(sessionID string, now time.Time) []time.Time {
+	sends := s.sends[sessionID]
+	cutoff := now.Add(-time.Hour)
+	first := 0
+	for first < len(sends) && !sends[first].After(cutoff) {
+		first++
+	}
+	if first == len(sends) {
+		delete(s.sends, sessionID)
+		return nil
+	}
+	sends = sends[first:]
+	s.sends[sessionID] = sends
+	return sends
+}
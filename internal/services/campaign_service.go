@@ -0,0 +1,244 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+)
+
+// campaignPollInterval is how often the scheduler looks for due campaigns
+const campaignPollInterval = 30 * time.Second
+
+// CampaignService schedules campaigns and starts their bulk messaging job
+// once they are due. Times are stored as unix timestamps and returned in UTC.
+type CampaignService struct {
+	campaignRepo *repository.CampaignRepository
+	contactRepo  *repository.ContactRepository
+	bulkService  *BulkMessagingService
+	log          *logger.Logger
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+// NewCampaignService creates a campaign scheduler
+func NewCampaignService(campaignRepo *repository.CampaignRepository, contactRepo *repository.ContactRepository, bulkService *BulkMessagingService, log *logger.Logger) *CampaignService {
+	return &CampaignService{
+		campaignRepo: campaignRepo,
+		contactRepo:  contactRepo,
+		bulkService:  bulkService,
+		log:          log,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start starts due campaigns in the background until Stop is called
+func (s *CampaignService) Start() {
+	go func() {
+		ticker := time.NewTicker(campaignPollInterval)
+		defer ticker.Stop()
+		for {
+			s.StartDueCampaigns()
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler
+func (s *CampaignService) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// CreateCampaign schedules a campaign messaging contacts of owner
+func (s *CampaignService) CreateCampaign(req models.CreateCampaignRequest, owner int) (*models.Campaign, error) {
+	switch {
+	case strings.TrimSpace(req.Name) == "":
+		return nil, models.NewBadRequestError("name is required")
+	case req.SessionID == "":
+		return nil, models.NewBadRequestError("session_id is required")
+	case strings.TrimSpace(req.Message) == "":
+		return nil, models.NewBadRequestError("message is required")
+	case len(req.ContactIDs) == 0 && req.GroupID == nil:
+		return nil, models.NewBadRequestError("contact_ids or group_id is required")
+	case req.DelayBetween < 0:
+		return nil, models.NewBadRequestError("delay_between cannot be negative")
+	}
+	if err := checkScheduledAt(req.ScheduledAt); err != nil {
+		return nil, err
+	}
+	if err := req.Throttle.Validate(); err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+	if err := s.checkCampaignSession(req.SessionID, owner); err != nil {
+		return nil, err
+	}
+
+	scheduledAt := req.ScheduledAt.UTC()
+	campaign := &models.Campaign{
+		UserID:       owner,
+		Name:         req.Name,
+		Description:  req.Description,
+		Message:      req.Message,
+		GroupID:      req.GroupID,
+		ContactIDs:   req.ContactIDs,
+		SessionID:    req.SessionID,
+		Status:       models.CampaignStatusScheduled,
+		DelayBetween: req.DelayBetween,
+		RandomDelay:  req.RandomDelay,
+		ScheduledAt:  &scheduledAt,
+		Variables:    req.Variables,
+		Throttle:     req.Throttle,
+	}
+	if err := s.campaignRepo.CreateCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("Scheduled campaign %d for session %s at %s", campaign.ID, campaign.SessionID, models.FormatTimestamp(scheduledAt))
+	return campaign, nil
+}
+
+// UpdateCampaign reschedules, edits or cancels a campaign that has not started
+// yet; unless userID is 0 the campaign must belong to userID. Its recipients
+// and session cannot be changed.
+func (s *CampaignService) UpdateCampaign(id, userID int, req models.UpdateCampaignRequest) (*models.Campaign, error) {
+	if req.TemplateID != 0 || req.GroupID != nil || req.ContactIDs != nil || req.SessionID != "" {
+		return nil, models.NewBadRequestError("the recipients and session of a campaign cannot be changed, create a new campaign instead")
+	}
+
+	campaign, err := s.campaignRepo.GetCampaign(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.CampaignStatusScheduled {
+		return nil, models.NewBadRequestError("campaign %d is %s and can no longer be changed", id, campaign.Status)
+	}
+
+	switch req.Status {
+	case "", models.CampaignStatusScheduled:
+	case models.CampaignStatusCancelled:
+		campaign.Status = models.CampaignStatusCancelled
+	default:
+		return nil, models.NewBadRequestError("status can only be set to %s", models.CampaignStatusCancelled)
+	}
+	if req.Name != "" {
+		campaign.Name = req.Name
+	}
+	if req.Description != "" {
+		campaign.Description = req.Description
+	}
+	if req.Message != "" {
+		campaign.Message = req.Message
+	}
+	if req.DelayBetween != nil {
+		if *req.DelayBetween < 0 {
+			return nil, models.NewBadRequestError("delay_between cannot be negative")
+		}
+		campaign.DelayBetween = *req.DelayBetween
+	}
+	if req.RandomDelay != nil {
+		campaign.RandomDelay = *req.RandomDelay
+	}
+	if req.Variables != nil {
+		campaign.Variables = req.Variables
+	}
+	if req.ScheduledAt != nil {
+		if err := checkScheduledAt(req.ScheduledAt); err != nil {
+			return nil, err
+		}
+		scheduledAt := req.ScheduledAt.UTC()
+		campaign.ScheduledAt = &scheduledAt
+	}
+
+	if err := s.campaignRepo.UpdateScheduledCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	if campaign.Status == models.CampaignStatusCancelled {
+		s.log.Info("Cancelled campaign %d", id)
+	} else {
+		s.log.Info("Updated campaign %d, scheduled at %s", id, models.FormatTimestamp(*campaign.ScheduledAt))
+	}
+	return s.campaignRepo.GetCampaign(id, userID)
+}
+
+// checkScheduledAt verifies that a campaign is scheduled in the future
+func checkScheduledAt(scheduledAt *time.Time) error {
+	if scheduledAt == nil || scheduledAt.IsZero() {
+		return models.NewBadRequestError("scheduled_at is required, as an RFC 3339 time such as 2024-06-01T09:00:00+07:00")
+	}
+	if !scheduledAt.After(time.Now()) {
+		return models.NewBadRequestError("scheduled_at must be in the future")
+	}
+	return nil
+}
+
+// StartDueCampaigns starts the bulk messaging job of every campaign whose
+// scheduled time has passed and returns how many were started
+func (s *CampaignService) StartDueCampaigns() int {
+	campaigns, err := s.campaignRepo.ClaimDueCampaigns(time.Now())
+	if err != nil {
+		s.log.Error("Failed to claim due campaigns: %v", err)
+	}
+
+	started := 0
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		if err := s.startCampaign(campaign); err != nil {
+			s.log.Error("Failed to start campaign %d: %v", campaign.ID, err)
+			if err := s.campaignRepo.SetCampaignStatus(campaign.ID, models.CampaignStatusFailed); err != nil {
+				s.log.Error("Failed to mark campaign %d as failed: %v", campaign.ID, err)
+			}
+			continue
+		}
+		started++
+	}
+	return started
+}
+
+// checkCampaignSession verifies that a campaign of owner sends from one of
+// owner's sessions. Admins may send from any session.
+func (s *CampaignService) checkCampaignSession(sessionID string, owner int) error {
+	admin, err := s.bulkService.whatsappService.isAdmin(owner)
+	if err != nil || admin {
+		return err
+	}
+	return s.bulkService.checkSessionOwner(sessionID, owner)
+}
+
+// startCampaign starts the bulk messaging job of a claimed campaign. The
+// session is checked again, as it may have been reassigned since scheduling.
+func (s *CampaignService) startCampaign(campaign *models.Campaign) error {
+	if err := s.checkCampaignSession(campaign.SessionID, campaign.UserID); err != nil {
+		return err
+	}
+
+	var contacts []models.Contact
+	var err error
+	if len(campaign.ContactIDs) > 0 {
+		contacts, err = s.contactRepo.GetContactsByIDs(campaign.ContactIDs, campaign.UserID)
+	} else if campaign.GroupID != nil {
+		contacts, err = s.contactRepo.GetContactsByGroupID(*campaign.GroupID, campaign.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(contacts) == 0 {
+		return models.NewBadRequestError("campaign %d has no contacts left to message", campaign.ID)
+	}
+
+	template := &models.MessageTemplate{Content: campaign.Message, Type: "text"}
+	job, err := s.bulkService.StartCampaignMessages(campaign, template, contacts)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Started campaign %d as bulk messaging job %s", campaign.ID, job.ID)
+	return nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// Catalog page sizes
+const (
+	DefaultCatalogLimit = 20
+	MaxCatalogLimit     = 100
+)
+
+// catalogSearchPages bounds how many full pages SendProduct reads looking for
+// a product, so huge catalogs cannot stall a send
+const catalogSearchPages = 10
+
+// catalogImageSize is the edge in pixels of the product images WhatsApp is
+// asked to scale for catalog and order listings
+const catalogImageSize = "100"
+
+// whatsmeow has no catalog API, so catalogs and orders are fetched with the
+// same queries WhatsApp Business clients send
+
+// GetCatalog returns a page of the session's own product catalog. cursor is
+// the next value of the previous page.
+func (s *WhatsAppService) GetCatalog(sessionID string, limit int, cursor string) (*models.Catalog, error) {
+	if limit == 0 {
+		limit = DefaultCatalogLimit
+	}
+	if limit < 1 || limit > MaxCatalogLimit {
+		return nil, models.NewBadRequestError("limit must be between 1 and %d", MaxCatalogLimit)
+	}
+
+	session, err := s.businessSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetchCatalog(session, limit, cursor)
+}
+
+// SendProduct sends a product of the session's own catalog, with the
+// product's first image
+func (s *WhatsAppService) SendProduct(sessionID string, req *models.SendProductRequest) (string, error) {
+	productID := strings.TrimSpace(req.ProductID)
+	if productID == "" {
+		return "", models.NewBadRequestError("product_id is required")
+	}
+
+	session, err := s.businessSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
+	jid, err := parseRecipientJID(req.To)
+	if err != nil {
+		return "", models.NewBadRequestError("%v", err)
+	}
+
+	product, err := s.findProduct(session, productID)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := &waProto.ProductMessage_ProductSnapshot{
+		ProductID:       proto.String(product.ID),
+		Title:           proto.String(product.Name),
+		Description:     proto.String(product.Description),
+		CurrencyCode:    proto.String(product.Currency),
+		PriceAmount1000: proto.Int64(product.Price1000),
+		RetailerID:      proto.String(product.RetailerID),
+		URL:             proto.String(product.URL),
+	}
+	if product.ImageURL != "" {
+		image, err := s.productImage(session, product.ImageURL)
+		if err != nil {
+			return "", err
+		}
+		snapshot.ProductImage = image
+		snapshot.ProductImageCount = proto.Uint32(1)
+	}
+
+	msg := &waProto.Message{
+		ProductMessage: &waProto.ProductMessage{
+			Product:          snapshot,
+			BusinessOwnerJID: proto.String(session.Client.Store.GetJID().ToNonAD().String()),
+		},
+	}
+	if req.Body != "" {
+		msg.ProductMessage.Body = proto.String(req.Body)
+	}
+	if req.Footer != "" {
+		msg.ProductMessage.Footer = proto.String(req.Footer)
+	}
+
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to send product", err)
+	}
+
+	s.logger.Info("Product %s sent to %s from session %s", product.ID, jid, sessionID)
+	return resp.ID, nil
+}
+
+// businessSession returns the logged in session, failing with a BadRequestError
+// unless its account is a WhatsApp Business account
+func (s *WhatsAppService) businessSession(sessionID string) (*models.Session, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Sandbox {
+		return nil, models.NewBadRequestError("catalogs are not available to sandbox sessions")
+	}
+	store := session.Client.Store
+	if store.BusinessName == "" && !strings.HasPrefix(store.Platform, "smb") {
+		return nil, models.NewBadRequestError("session %s is not a WhatsApp Business account", sessionID)
+	}
+	return session, nil
+}
+
+// fetchCatalog reads a page of the session's own catalog
+func (s *WhatsAppService) fetchCatalog(session *models.Session, limit int, cursor string) (*models.Catalog, error) {
+	query := []waBinary.Node{
+		{Tag: "limit", Content: []byte(strconv.Itoa(limit))},
+		{Tag: "width", Content: []byte(catalogImageSize)},
+		{Tag: "height", Content: []byte(catalogImageSize)},
+	}
+	if cursor != "" {
+		query = append(query, waBinary.Node{Tag: "after", Content: []byte(cursor)})
+	}
+
+	resp, err := session.Client.DangerousInternals().SendIQ(context.Background(), whatsmeow.DangerousInfoQuery{
+		Namespace: "w:biz:catalog",
+		Type:      "get",
+		To:        types.ServerJID,
+		Content: []waBinary.Node{{
+			Tag: "product_catalog",
+			Attrs: waBinary.Attrs{
+				"jid":               session.Client.Store.GetJID().ToNonAD(),
+				"allow_shop_source": "true",
+			},
+			Content: query,
+		}},
+	})
+	if err != nil {
+		return nil, catalogFailure("failed to get catalog", err)
+	}
+
+	catalog := &models.Catalog{Products: []*models.Product{}}
+	node, ok := resp.GetOptionalChildByTag("product_catalog")
+	if !ok {
+		return catalog, nil
+	}
+	for _, child := range node.GetChildrenByTag("product") {
+		catalog.Products = append(catalog.Products, catalogProduct(child))
+	}
+	catalog.Next = childText(node, "paging", "after")
+	return catalog, nil
+}
+
+// findProduct looks a product up in the session's own catalog
+func (s *WhatsAppService) findProduct(session *models.Session, productID string) (*models.Product, error) {
+	cursor := ""
+	for page := 0; page < catalogSearchPages; page++ {
+		catalog, err := s.fetchCatalog(session, MaxCatalogLimit, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range catalog.Products {
+			if product.ID == productID {
+				return product, nil
+			}
+		}
+		if catalog.Next == "" {
+			break
+		}
+		cursor = catalog.Next
+	}
+	return nil, models.NewNotFoundError("product %s not found in the catalog", productID)
+}
+
+// productImage uploads a catalog image for a product message
+func (s *WhatsAppService) productImage(session *models.Session, imageURL string) (*waProto.ImageMessage, error) {
+	data, contentType, _, err := s.downloadFile(imageURL, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download product image: %v", err)
+	}
+	uploaded, err := s.upload(session, data, whatsmeow.MediaImage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload product image: %v", err)
+	}
+	return mediaMessage(uploadedMedia{
+		uploaded:    uploaded,
+		contentType: contentType,
+		mediaType:   "image",
+	}, "").GetImageMessage(), nil
+}
+
+// decodeOrder describes a cart order. The items are fetched from WhatsApp,
+// which only answers the seller; when that fails the order is sent without them.
+func (s *WhatsAppService) decodeOrder(session *models.Session, evt *events.Message) *models.WebhookOrder {
+	msg := evt.Message.GetOrderMessage()
+	order := &models.WebhookOrder{
+		OrderID:   msg.GetOrderID(),
+		Title:     msg.GetOrderTitle(),
+		Message:   msg.GetMessage(),
+		Seller:    msg.GetSellerJID(),
+		ItemCount: int(msg.GetItemCount()),
+		Total1000: msg.GetTotalAmount1000(),
+		Currency:  msg.GetTotalCurrencyCode(),
+		Items:     []*models.WebhookOrderItem{},
+	}
+	if msg.Status != nil {
+		order.Status = strings.ToLower(msg.GetStatus().String())
+	}
+	if session.Sandbox || msg.GetOrderID() == "" || msg.GetToken() == "" {
+		return order
+	}
+
+	resp, err := session.Client.DangerousInternals().SendIQ(context.Background(), whatsmeow.DangerousInfoQuery{
+		Namespace: "fb:thrift_iq",
+		Type:      "get",
+		To:        types.ServerJID,
+		SMaxID:    "5",
+		Content: []waBinary.Node{{
+			Tag:   "order",
+			Attrs: waBinary.Attrs{"op": "get", "id": msg.GetOrderID()},
+			Content: []waBinary.Node{
+				{Tag: "image_dimensions", Content: []waBinary.Node{
+					{Tag: "width", Content: []byte(catalogImageSize)},
+					{Tag: "height", Content: []byte(catalogImageSize)},
+				}},
+				{Tag: "token", Content: []byte(msg.GetToken())},
+			},
+		}},
+	})
+	if err != nil {
+		s.logger.Warn("Failed to get details of order %s in session %s: %v", order.OrderID, session.ID, err)
+		return order
+	}
+
+	node, ok := resp.GetOptionalChildByTag("order")
+	if !ok {
+		return order
+	}
+	for _, child := range node.GetChildrenByTag("product") {
+		price, _ := strconv.ParseInt(childText(child, "price"), 10, 64)
+		quantity, _ := strconv.Atoi(childText(child, "quantity"))
+		order.Items = append(order.Items, &models.WebhookOrderItem{
+			ProductID: childText(child, "id"),
+			Name:      childText(child, "name"),
+			ImageURL:  childText(child, "image", "url"),
+			Price1000: price,
+			Currency:  childText(child, "currency"),
+			Quantity:  quantity,
+		})
+	}
+	if order.Total1000 == 0 {
+		order.Total1000, _ = strconv.ParseInt(childText(node, "price", "total"), 10, 64)
+		order.Currency = childText(node, "price", "currency")
+	}
+	return order
+}
+
+// catalogProduct converts a product node of a catalog response
+func catalogProduct(node waBinary.Node) *models.Product {
+	price, _ := strconv.ParseInt(childText(node, "price"), 10, 64)
+	product := &models.Product{
+		ID:          childText(node, "id"),
+		RetailerID:  childText(node, "retailer_id"),
+		Name:        childText(node, "name"),
+		Description: childText(node, "description"),
+		URL:         childText(node, "url"),
+		ImageURL:    childText(node, "media", "image", "original_image_url"),
+		Price1000:   price,
+		Currency:    childText(node, "currency"),
+		Hidden:      node.AttrGetter().OptionalBool("is_hidden"),
+		Status:      childText(node, "status_info", "status"),
+	}
+	if product.ImageURL == "" {
+		product.ImageURL = childText(node, "media", "image", "request_image_url")
+	}
+	return product
+}
+
+// childText returns the text content of the descendant at the path of tags
+func childText(node waBinary.Node, tags ...string) string {
+	child, ok := node.GetOptionalChildByTag(tags...)
+	if !ok {
+		return ""
+	}
+	content, _ := child.Content.([]byte)
+	return string(content)
+}
+
+// catalogFailure explains catalog requests WhatsApp does not accept
+func catalogFailure(action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return models.NewUnauthorizedError("session is not authenticated")
+	case errors.Is(err, whatsmeow.ErrIQNotFound):
+		return models.NewNotFoundError("%s: the account has no catalog", action)
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAuthorized):
+		return models.NewForbiddenError("%s: %v", action, err)
+	case errors.Is(err, whatsmeow.ErrIQBadRequest), errors.Is(err, whatsmeow.ErrIQNotAcceptable):
+		return models.NewBadRequestError("%s: %v", action, err)
+	}
+	return models.NewServiceUnavailableError("%s: %v", action, err)
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/metrics"
+)
+
+// Connections WhatsApp drops are reconnected here rather than by whatsmeow, so
+// attempts are visible and bounded. Each reconnect waits an exponentially
+// growing, jittered delay; once the configured number of reconnects has failed
+// the session is left disconnected and marked as needing attention until it is
+// reconnected with POST /api/sessions/{sessionId}/reconnect. Connections closed
+// by a logout, a ban or another client taking over are not reconnected.
+
+const (
+	defaultReconnectMaxAttempts = 10
+	defaultReconnectBaseDelay   = 2 * time.Second
+	defaultReconnectMaxDelay    = 5 * time.Minute
+
+	// disconnectReasonWindow is how long a reason recorded from a stream error
+	// or connect failure explains the disconnect that follows it
+	disconnectReasonWindow = 10 * time.Second
+)
+
+// reconnectPolicy bounds how the supervisor reconnects dropped connections
+type reconnectPolicy struct {
+	maxAttempts int // 0 never gives up
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// ConfigureReconnect sets how dropped connections are reconnected: delays
+// double from baseDelay up to maxDelay, and after maxAttempts failed
+// reconnects (0 for never) the session needs attention
+func (s *WhatsAppService) ConfigureReconnect(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	if baseDelay <= 0 {
+		baseDelay = defaultReconnectBaseDelay
+	}
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+
+	s.mu.Lock()
+	s.reconnect = reconnectPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay, maxDelay: maxDelay}
+	s.mu.Unlock()
+}
+
+// reconnectDelay returns how long to wait before the reconnect following
+// attempts earlier ones: the doubled delay with up to half of it taken off at random
+func (p reconnectPolicy) reconnectDelay(attempts int) time.Duration {
+	delay := p.baseDelay
+	for i := 0; i < attempts && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// recordDisconnectReason records why the connection of a session closed or is
+// about to close
+func recordDisconnectReason(session *models.Session, reason string) {
+	session.UpdateState(func(state *models.SessionState) {
+		state.LastDisconnectReason = reason
+		state.LastDisconnectedAt = time.Now()
+	})
+}
+
+// recentDisconnectReason returns the reason recorded for the disconnect that
+// just happened, or fallback if none was recorded shortly before
+func recentDisconnectReason(session *models.Session, fallback string) string {
+	state := session.State()
+	if state.LastDisconnectReason != "" && time.Since(state.LastDisconnectedAt) < disconnectReasonWindow {
+		return state.LastDisconnectReason
+	}
+	return fallback
+}
+
+// scheduleReconnect records that the connection of a session closed for
+// reason and reconnects it after the backoff delay, or marks it as needing
+// attention once the reconnects are used up
+func (s *WhatsAppService) scheduleReconnect(session *models.Session, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, exists := s.sessions[session.ID]; !exists || current != session {
+		return
+	}
+	canReconnect := s.ctx.Err() == nil && session.Enabled && !session.Sandbox && session.BannedUntil == nil &&
+		session.Client != nil && session.Client.Store.ID != nil
+
+	var delay time.Duration
+	var gaveUp bool
+	state := session.UpdateState(func(state *models.SessionState) {
+		state.LastDisconnectReason = reason
+		state.LastDisconnectedAt = time.Now()
+		state.NextReconnectAt = time.Time{}
+		if !canReconnect {
+			return
+		}
+		if s.reconnect.maxAttempts > 0 && state.ReconnectAttempts >= s.reconnect.maxAttempts {
+			state.NeedsAttention = true
+			gaveUp = true
+			return
+		}
+		delay = s.reconnect.reconnectDelay(state.ReconnectAttempts)
+		state.ReconnectAttempts++
+		state.NextReconnectAt = time.Now().Add(delay)
+	})
+	s.stopReconnect(session.ID)
+	if !canReconnect {
+		return
+	}
+
+	if gaveUp {
+		s.logger.Error("Session %s disconnected (%s) and %d reconnect(s) failed, it needs attention", session.ID, reason, state.ReconnectAttempts)
+		go s.notifyOperator("session_needs_attention", session, map[string]interface{}{
+			"phone":                  session.ActualPhone,
+			"reconnect_attempts":     state.ReconnectAttempts,
+			"last_disconnect_reason": reason,
+		})
+		return
+	}
+
+	s.logger.Warn("Session %s disconnected (%s), reconnecting in %s (attempt %d)", session.ID, reason, delay.Round(time.Millisecond), state.ReconnectAttempts+1)
+	s.reconnectTimers[session.ID] = time.AfterFunc(delay, func() {
+		s.reconnectSession(session)
+	})
+}
+
+// handleKeepAliveTimeout drops and reconnects a connection whose keepalives
+// have failed for longer than whatsmeow tolerates
+func (s *WhatsAppService) handleKeepAliveTimeout(session *models.Session, evt *events.KeepAliveTimeout) {
+	if time.Since(evt.LastSuccess) <= whatsmeow.KeepAliveMaxFailTime {
+		s.logger.Warn("Session %s keepalive failed (%d in a row)", session.ID, evt.ErrorCount)
+		return
+	}
+
+	// An expected disconnect reports no event, so the reconnect is scheduled here
+	session.Client.Disconnect()
+	session.SetConnected(false, false)
+	reason := fmt.Sprintf("keepalive failed %d times since %s", evt.ErrorCount, models.FormatTimestamp(evt.LastSuccess))
+	s.scheduleReconnect(session, reason)
+	s.publishSessionStatus(session, models.SessionStatusDisconnected, reason)
+}
+
+// stopReconnect cancels the scheduled reconnect of a session. The caller holds s.mu.
+func (s *WhatsAppService) stopReconnect(sessionID string) {
+	if timer, exists := s.reconnectTimers[sessionID]; exists {
+		timer.Stop()
+		delete(s.reconnectTimers, sessionID)
+	}
+}
+
+// cancelReconnect cancels the scheduled reconnect of a session and leaves it
+// disconnected without needing attention. The caller holds s.mu.
+func (s *WhatsAppService) cancelReconnect(session *models.Session) {
+	s.stopReconnect(session.ID)
+	session.UpdateState(func(state *models.SessionState) {
+		state.ReconnectAttempts = 0
+		state.NextReconnectAt = time.Time{}
+		state.NeedsAttention = false
+	})
+}
+
+// reconnectSession opens the connection of a session again, scheduling the
+// next reconnect if that fails. A connection that opens but is closed again
+// before it is up reports a disconnect, which schedules the next one too.
+func (s *WhatsAppService) reconnectSession(session *models.Session) {
+	s.mu.Lock()
+	if current, exists := s.sessions[session.ID]; !exists || current != session || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.reconnectTimers, session.ID)
+	s.mu.Unlock()
+
+	state := session.UpdateState(func(state *models.SessionState) {
+		state.NextReconnectAt = time.Time{}
+	})
+	if state.Connected || state.Connecting {
+		return
+	}
+
+	s.logger.Info("Reconnecting session %s", session.ID)
+	metrics.Reconnects.Inc(session.ID)
+	err := session.Client.Connect()
+	if err == nil || err == whatsmeow.ErrAlreadyConnected {
+		return
+	}
+
+	s.mu.Lock()
+	recordProxyFailure(session)
+	s.mu.Unlock()
+	s.scheduleReconnect(session, fmt.Sprintf("reconnect failed: %v", err))
+}
+
+// GetSessionHealth returns the connection health of a session
+func (s *WhatsAppService) GetSessionHealth(sessionID string) (*models.SessionHealth, error) {
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	maxAttempts := s.reconnect.maxAttempts
+	s.mu.RUnlock()
+	if !exists {
+		return nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+
+	state := session.State()
+	health := &models.SessionHealth{
+		SessionID:            session.ID,
+		Status:               state.ConnectionStatus(),
+		Connected:            state.Connected,
+		LoggedIn:             state.LoggedIn,
+		ReconnectAttempts:    state.ReconnectAttempts,
+		MaxReconnectAttempts: maxAttempts,
+		LastDisconnectReason: state.LastDisconnectReason,
+	}
+	if session.Sandbox {
+		health.ClientConnected = state.Connected
+		health.ClientLoggedIn = state.LoggedIn
+	} else if session.Client != nil {
+		health.ClientConnected = session.Client.IsConnected()
+		health.ClientLoggedIn = session.Client.IsLoggedIn()
+	}
+	if !state.NextReconnectAt.IsZero() {
+		health.NextReconnectAt = models.FormatTimestamp(state.NextReconnectAt)
+	}
+	if !state.LastDisconnectedAt.IsZero() {
+		health.LastDisconnectedAt = models.FormatTimestamp(state.LastDisconnectedAt)
+	}
+	if !state.LastConnectedAt.IsZero() {
+		health.LastConnectedAt = models.FormatTimestamp(state.LastConnectedAt)
+	}
+	return health, nil
+}
+
+// ForceReconnect drops the connection of a session, if open, and reconnects
+// it at once with a fresh set of reconnect attempts
+func (s *WhatsAppService) ForceReconnect(sessionID string) (*models.SessionHealth, error) {
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+	if !session.Enabled {
+		s.mu.Unlock()
+		return nil, models.NewBadRequestError("session %s is disabled and cannot be connected", sessionID)
+	}
+	if session.BannedUntil != nil {
+		until := *session.BannedUntil
+		reason := session.BanReason
+		s.mu.Unlock()
+		return nil, models.NewSessionBannedError(sessionID, until, reason)
+	}
+	if !session.Sandbox && (session.Client == nil || session.Client.Store.ID == nil) {
+		s.mu.Unlock()
+		return nil, models.NewBadRequestError("session %s is not logged in, log in with a QR code or pairing code first", sessionID)
+	}
+	s.cancelReconnect(session)
+	s.mu.Unlock()
+
+	s.logger.Info("Reconnect of session %s requested", sessionID)
+	if session.Sandbox {
+		if !session.IsConnected() {
+			s.sandboxLogin(session)
+		}
+	} else {
+		recordDisconnectReason(session, "reconnect requested")
+		session.Client.Disconnect()
+		if previous := session.SetDisconnected(); previous.Connected {
+			s.publishSessionStatus(session, models.SessionStatusDisconnected, "reconnect requested")
+		}
+		go s.reconnectSession(session)
+	}
+
+	return s.GetSessionHealth(sessionID)
+}
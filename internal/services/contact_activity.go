@@ -0,0 +1,275 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+const (
+	// contactActivityFlushInterval is how often accumulated message traffic is written
+	contactActivityFlushInterval = 10 * time.Second
+
+	// contactActivityMaxPending flushes early once this many numbers are waiting
+	contactActivityMaxPending = 1000
+
+	// contactIDCacheTTL is how long a number's contact, or the absence of
+	// one, is remembered before it is looked up again
+	contactIDCacheTTL = 10 * time.Minute
+)
+
+// pendingActivity is the traffic of one user with one number since the last flush
+type pendingActivity struct {
+	last     time.Time
+	inbound  int
+	outbound int
+}
+
+// cachedContactID is a resolved number; id 0 means the user has no contact with the number
+type cachedContactID struct {
+	id      int
+	expires time.Time
+}
+
+// ContactActivityService maintains last_contact and the inbound and outbound
+// message counts of CRM contacts. Messages are only counted in memory on the
+// message path; numbers are resolved to the contacts of the session's owner
+// and written in batches in the background.
+type ContactActivityService struct {
+	contactRepo *repository.ContactRepository
+	log         *logger.Logger
+
+	mu      sync.Mutex
+	pending map[models.ContactPhoneKey]*pendingActivity
+	ids     map[models.ContactPhoneKey]cachedContactID
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	flushing sync.Mutex
+}
+
+// NewContactActivityService creates a service writing contact activity to contactRepo
+func NewContactActivityService(contactRepo *repository.ContactRepository, log *logger.Logger) *ContactActivityService {
+	return &ContactActivityService{
+		contactRepo: contactRepo,
+		log:         log,
+		pending:     make(map[models.ContactPhoneKey]*pendingActivity),
+		ids:         make(map[models.ContactPhoneKey]cachedContactID),
+		flushNow:    make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start writes accumulated activity in the background until Stop is called
+func (s *ContactActivityService) Start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(contactActivityFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Flush()
+			case <-s.flushNow:
+				s.Flush()
+			case <-s.stop:
+				s.Flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop writes what is still pending and stops the background writer
+func (s *ContactActivityService) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+// RecordInbound counts a message a session of userID received from the
+// number at the given time
+func (s *ContactActivityService) RecordInbound(userID int, e164 string, at time.Time) {
+	s.record(userID, e164, at, true)
+}
+
+// RecordOutbound counts a message a session of userID sent to the number at
+// the given time
+func (s *ContactActivityService) RecordOutbound(userID int, e164 string, at time.Time) {
+	s.record(userID, e164, at, false)
+}
+
+func (s *ContactActivityService) record(userID int, e164 string, at time.Time, inbound bool) {
+	if s == nil || e164 == "" {
+		return
+	}
+
+	key := models.ContactPhoneKey{UserID: userID, Phone: e164}
+	s.mu.Lock()
+	activity, exists := s.pending[key]
+	if !exists {
+		activity = &pendingActivity{}
+		s.pending[key] = activity
+	}
+	if at.After(activity.last) {
+		activity.last = at
+	}
+	if inbound {
+		activity.inbound++
+	} else {
+		activity.outbound++
+	}
+	full := len(s.pending) >= contactActivityMaxPending
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RememberContact records that a contact of userID was just created for the
+// number, so activity counted before the next lookup is not lost to a cached
+// miss
+func (s *ContactActivityService) RememberContact(userID int, e164 string, contactID int) {
+	if s == nil || e164 == "" {
+		return
+	}
+	s.mu.Lock()
+	s.ids[models.ContactPhoneKey{UserID: userID, Phone: e164}] = cachedContactID{id: contactID, expires: time.Now().Add(contactIDCacheTTL)}
+	s.mu.Unlock()
+}
+
+// Flush resolves the pending numbers to contacts and writes their activity
+func (s *ContactActivityService) Flush() {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[models.ContactPhoneKey]*pendingActivity)
+	now := time.Now()
+	var unresolved []models.ContactPhoneKey
+	for key := range pending {
+		if cached, ok := s.ids[key]; !ok || now.After(cached.expires) {
+			unresolved = append(unresolved, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	if len(unresolved) > 0 {
+		numbers := make([]string, 0, len(unresolved))
+		for _, key := range unresolved {
+			numbers = append(numbers, key.Phone)
+		}
+		found, err := s.contactRepo.GetContactIDsByPhones(numbers)
+		if err != nil {
+			s.log.Warn("Failed to resolve contacts for activity of %d number(s), retrying later: %v", len(pending), err)
+			s.requeue(pending)
+			return
+		}
+		s.mu.Lock()
+		for _, key := range unresolved {
+			// Misses are cached too, so unknown numbers cost one lookup per TTL
+			s.ids[key] = cachedContactID{id: found[key], expires: now.Add(contactIDCacheTTL)}
+		}
+		s.pruneCache(now)
+		s.mu.Unlock()
+	}
+
+	batch := make([]models.ContactActivity, 0, len(pending))
+	s.mu.Lock()
+	for key, activity := range pending {
+		if id := s.ids[key].id; id != 0 {
+			batch = append(batch, models.ContactActivity{
+				ContactID:   id,
+				LastContact: activity.last,
+				Inbound:     activity.inbound,
+				Outbound:    activity.outbound,
+			})
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.contactRepo.RecordActivityBatch(batch); err != nil {
+		s.log.Warn("Failed to record activity of %d contact(s), retrying later: %v", len(batch), err)
+		s.requeue(pending)
+		return
+	}
+	if len(batch) > 0 {
+		s.log.Debug("Recorded message activity of %d contact(s)", len(batch))
+	}
+}
+
+// requeue merges activity that could not be written back into the pending set
+func (s *ContactActivityService) requeue(failed map[models.ContactPhoneKey]*pendingActivity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, activity := range failed {
+		current, exists := s.pending[key]
+		if !exists {
+			s.pending[key] = activity
+			continue
+		}
+		if activity.last.After(current.last) {
+			current.last = activity.last
+		}
+		current.inbound += activity.inbound
+		current.outbound += activity.outbound
+	}
+}
+
+// pruneCache drops expired lookups; the caller holds s.mu
+func (s *ContactActivityService) pruneCache(now time.Time) {
+	for key, cached := range s.ids {
+		if now.After(cached.expires) {
+			delete(s.ids, key)
+		}
+	}
+}
+
+// SetContactActivity makes the session message paths maintain contact activity
+func (s *WhatsAppService) SetContactActivity(activity *ContactActivityService) {
+	s.activity = activity
+}
+
+// recordMessageActivity counts a one-to-one message for the contact it was
+// exchanged with. Messages the account sent from the phone or another linked
+// device arrive here as well and count as outbound.
+func (s *WhatsAppService) recordMessageActivity(session *models.Session, evt *events.Message) {
+	if s.activity == nil || evt.Info.IsGroup || evt.Message.GetProtocolMessage() != nil {
+		return
+	}
+	chat := evt.Info.Chat
+	if chat.Server != types.DefaultUserServer && chat.Server != types.HiddenUserServer {
+		return
+	}
+
+	if !evt.Info.IsFromMe {
+		s.activity.RecordInbound(session.UserID, senderPhone(evt.Info.MessageSource), evt.Info.Timestamp)
+		return
+	}
+	number := phone.FromJID(chat.ToNonAD().String())
+	if number == "" {
+		number = phone.FromJID(evt.Info.RecipientAlt.ToNonAD().String())
+	}
+	s.activity.RecordOutbound(session.UserID, number, evt.Info.Timestamp)
+}
@@ -110,6 +110,12 @@ func (s *ContactDetectionService) detectFieldMapping(headers []string) map[int]s
 			continue
 		}
 
+		// Columns written by the contact export
+		if header == "tags" || header == "notes" {
+			mapping[i] = header
+			continue
+		}
+
 		// If no specific pattern matches, try to infer from content in first few rows
 		mapping[i] = "unknown"
 	}
@@ -162,6 +168,14 @@ func (s *ContactDetectionService) detectContactFromRecord(record []string, field
 		case "position":
 			contact.Position = value
 			confidenceScore += 5.0
+		case "tags":
+			for _, tag := range strings.Split(value, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					contact.Tags = append(contact.Tags, tag)
+				}
+			}
+		case "notes":
+			contact.Notes = value
 		case "unknown":
 			// Try to auto-detect what this field might be
 			if phone := s.extractPhone(value); phone != "" {
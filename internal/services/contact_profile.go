@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetContactAvatar returns the profile picture of a contact or group, full
+// size or as a thumbnail with preview. Pictures that are not set or hidden by
+// the contact's privacy settings are reported as not found.
+func (s *WhatsAppService) GetContactAvatar(sessionID, contact string, preview bool) (*models.ContactAvatar, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	jid, err := parseRecipientJID(contact)
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid contact: %v", err)
+	}
+
+	params := &whatsmeow.GetProfilePictureParams{Preview: preview}
+	info, err := session.Client.GetProfilePictureInfo(context.Background(), jid, params)
+	switch {
+	case errors.Is(err, whatsmeow.ErrProfilePictureNotSet), errors.Is(err, whatsmeow.ErrProfilePictureUnauthorized), err == nil && info == nil:
+		return nil, models.NewNotFoundError("profile picture of %s is not available", jid)
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return nil, models.NewUnauthorizedError("session is not authenticated")
+	case err != nil:
+		return nil, models.NewServiceUnavailableError("failed to get profile picture: %v", err)
+	}
+
+	return &models.ContactAvatar{
+		JID:        jid.String(),
+		PictureID:  info.ID,
+		PictureURL: info.URL,
+		Type:       info.Type,
+	}, nil
+}
+
+// DownloadContactAvatar returns the image of a contact's or group's profile
+// picture and its content type
+func (s *WhatsAppService) DownloadContactAvatar(sessionID, contact string, preview bool) ([]byte, string, error) {
+	avatar, err := s.GetContactAvatar(sessionID, contact, preview)
+	if err != nil {
+		return nil, "", err
+	}
+	image, contentType, _, err := s.downloadFile(avatar.PictureURL, false)
+	if err != nil {
+		return nil, "", models.NewServiceUnavailableError("failed to download profile picture of %s: %v", avatar.JID, err)
+	}
+	return image, contentType, nil
+}
+
+// GetBusinessProfile returns the public profile of a WhatsApp business
+// account. Accounts that are not businesses are reported as not found.
+func (s *WhatsAppService) GetBusinessProfile(sessionID, contact string) (*models.BusinessProfile, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	jid, err := parseRecipientJID(contact)
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid contact: %v", err)
+	}
+	if jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer {
+		return nil, models.NewBadRequestError("%s is not a user", jid)
+	}
+
+	business, err := session.Client.GetBusinessProfile(context.Background(), jid)
+	if err != nil {
+		// Other accounts come back without a profile, or one without a JID
+		var missing *whatsmeow.ElementMissingError
+		if errors.As(err, &missing) || errors.Is(err, whatsmeow.ErrIQNotFound) || err.Error() == "missing jid in business profile" {
+			return nil, models.NewNotFoundError("%s is not a WhatsApp business account", jid)
+		}
+		if errors.Is(err, whatsmeow.ErrNotLoggedIn) {
+			return nil, models.NewUnauthorizedError("session is not authenticated")
+		}
+		return nil, models.NewServiceUnavailableError("failed to get business profile: %v", err)
+	}
+
+	profile := &models.BusinessProfile{
+		JID:           jid.String(),
+		Email:         business.Email,
+		Address:       business.Address,
+		Categories:    make([]models.BusinessCategory, 0, len(business.Categories)),
+		HoursTimeZone: business.BusinessHoursTimeZone,
+		Hours:         make([]models.BusinessHours, 0, len(business.BusinessHours)),
+		Options:       business.ProfileOptions,
+	}
+	for _, category := range business.Categories {
+		profile.Categories = append(profile.Categories, models.BusinessCategory{ID: category.ID, Name: category.Name})
+	}
+	for _, hours := range business.BusinessHours {
+		profile.Hours = append(profile.Hours, models.BusinessHours{
+			DayOfWeek: hours.DayOfWeek,
+			Mode:      hours.Mode,
+			OpenTime:  hours.OpenTime,
+			CloseTime: hours.CloseTime,
+		})
+	}
+
+	// The verified name and about text come with the user info
+	if info, err := session.Client.GetUserInfo(context.Background(), []types.JID{jid}); err != nil {
+		s.logger.Warn("Failed to get user info of %s for session %s: %v", jid, sessionID, err)
+	} else if user, ok := info[jid]; ok {
+		profile.About = user.Status
+		if user.VerifiedName != nil && user.VerifiedName.Details != nil {
+			profile.VerifiedName = user.VerifiedName.Details.GetVerifiedName()
+		}
+	}
+	return profile, nil
+}
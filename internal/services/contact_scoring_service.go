@@ -0,0 +1,176 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+const (
+	// scoringBatchSize bounds how many contacts are updated per transaction so
+	// the nightly job never holds row locks on the contacts table for long.
+	scoringBatchSize = 500
+
+	// campaignReplyWindow is how long after a campaign message an inbound
+	// message still counts as a reply to that campaign.
+	campaignReplyWindow = 72 * time.Hour
+
+	// recentActivityWindow is the window used for the inbound reply frequency.
+	recentActivityWindow = 90 * 24 * time.Hour
+)
+
+// ContactScoringService recomputes contact engagement scores from the message history
+type ContactScoringService struct {
+	contactRepo *repository.ContactRepository
+	hour        int
+	log         *logger.Logger
+	stop        chan struct{}
+	stopOnce    sync.Once
+	running     sync.Mutex
+}
+
+// NewContactScoringService creates a scoring service that runs nightly at the given local hour
+func NewContactScoringService(contactRepo *repository.ContactRepository, hour int, log *logger.Logger) *ContactScoringService {
+	return &ContactScoringService{
+		contactRepo: contactRepo,
+		hour:        hour,
+		log:         log,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start runs the scoring job once a day in the background until Stop is called
+func (s *ContactScoringService) Start() {
+	go func() {
+		for {
+			wait := time.Until(s.nextRun(time.Now()))
+			select {
+			case <-time.After(wait):
+				if _, err := s.ScoreAll(); err != nil {
+					s.log.Error("Contact scoring failed: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the nightly job
+func (s *ContactScoringService) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// nextRun returns the next time the job is due after now
+func (s *ContactScoringService) nextRun(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// ScoreAll recomputes the engagement data of every contact and returns how many were updated
+func (s *ContactScoringService) ScoreAll() (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	started := time.Now()
+	activity, err := s.contactRepo.GetInboundActivity(started.Add(-recentActivityWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	afterID := 0
+	for {
+		contacts, err := s.contactRepo.GetContactPhonesAfter(afterID, scoringBatchSize, 0)
+		if err != nil {
+			return updated, err
+		}
+		if len(contacts) == 0 {
+			break
+		}
+		afterID = contacts[len(contacts)-1].ID
+
+		senders := make(map[int]string, len(contacts))
+		for _, contact := range contacts {
+			if digits := phoneDigits(contact.Phone); digits != "" {
+				senders[contact.ID] = digits
+			}
+		}
+
+		replied, err := s.contactRepo.GetCampaignRepliedContacts(senders, campaignReplyWindow)
+		if err != nil {
+			return updated, err
+		}
+
+		batch := make([]models.ContactEngagement, 0, len(contacts))
+		for _, contact := range contacts {
+			engagement := models.ContactEngagement{
+				ContactID:         contact.ID,
+				RepliedToCampaign: replied[contact.ID],
+			}
+			var inbound models.InboundActivity
+			if digits, ok := senders[contact.ID]; ok {
+				inbound = activity[digits]
+			}
+			if !inbound.LastAt.IsZero() {
+				lastAt := inbound.LastAt
+				engagement.LastInboundAt = &lastAt
+			}
+			engagement.Score = engagementScore(started, inbound, engagement.RepliedToCampaign)
+			batch = append(batch, engagement)
+		}
+
+		if err := s.contactRepo.UpdateEngagementBatch(batch); err != nil {
+			return updated, err
+		}
+		updated += len(batch)
+	}
+
+	s.log.Info("Contact scoring updated %d contacts in %s", updated, time.Since(started).Round(time.Millisecond))
+	return updated, nil
+}
+
+// engagementScore combines recency, inbound reply frequency and campaign replies into a 0-100 score
+func engagementScore(now time.Time, activity models.InboundActivity, repliedToCampaign bool) int {
+	score := 0
+
+	if !activity.LastAt.IsZero() {
+		switch age := now.Sub(activity.LastAt); {
+		case age <= 7*24*time.Hour:
+			score += 40
+		case age <= 30*24*time.Hour:
+			score += 25
+		case age <= 90*24*time.Hour:
+			score += 10
+		}
+	}
+
+	recent := activity.RecentCount
+	if recent > 20 {
+		recent = 20
+	}
+	score += recent * 2
+
+	if repliedToCampaign {
+		score += 20
+	}
+
+	return score
+}
+
+// phoneDigits returns the E.164 digits of a stored contact phone, as used in WhatsApp JIDs
+func phoneDigits(raw string) string {
+	number, err := phone.Parse(raw, phone.DefaultRegion())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(number.E164, "+")
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// contactSyncBatchSize is how many WhatsApp contacts are looked up and
+// written per query when syncing
+const contactSyncBatchSize = 500
+
+// contactSyncTag is the tag given to CRM contacts synced from a session
+func contactSyncTag(sessionID string) string {
+	return "synced:" + sessionID
+}
+
+// SyncContacts copies the contacts in a session's WhatsApp contact store into
+// the CRM contacts of the session's owner. Numbers without a contact are created, tagged
+// synced:<sessionID>. Existing contacts get the tag, and a name when theirs
+// is only the number, and are otherwise skipped. With dryRun nothing is
+// written and the result reports what a sync would do.
+func (s *WhatsAppService) SyncContacts(sessionID string, dryRun bool) (*models.ContactImportResult, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if s.contacts == nil {
+		return nil, models.NewServiceUnavailableError("contacts are not available")
+	}
+
+	stored, err := session.Client.Store.Contacts.GetAllContacts(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %v", err)
+	}
+
+	// One entry per number, ordered so repeated syncs batch the same way
+	names := make(map[string]string, len(stored))
+	for jid, contact := range stored {
+		if jid.Server != types.DefaultUserServer {
+			continue // LIDs, groups and broadcast lists have no number
+		}
+		e164 := phone.FromJID(jid.String())
+		if e164 == "" {
+			continue
+		}
+		names[e164] = syncedContactName(contact)
+	}
+	numbers := make([]string, 0, len(names))
+	for e164 := range names {
+		numbers = append(numbers, e164)
+	}
+	sort.Strings(numbers)
+
+	tag := contactSyncTag(sessionID)
+	result := &models.ContactImportResult{Total: len(numbers), DryRun: dryRun, Errors: []string{}}
+	for start := 0; start < len(numbers); start += contactSyncBatchSize {
+		end := start + contactSyncBatchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		batch := numbers[start:end]
+
+		existing, err := s.contacts.GetContactsByPhones(batch, session.UserID)
+		if err != nil {
+			result.Failed += len(batch)
+			result.Errors = append(result.Errors, fmt.Sprintf("Contacts %d-%d: %v", start+1, end, err))
+			continue
+		}
+
+		var created, updated []models.Contact
+		for _, e164 := range batch {
+			name := names[e164]
+			contact, found := existing[e164]
+			if !found {
+				if name == "" {
+					name = e164
+				}
+				created = append(created, models.Contact{UserID: session.UserID, Name: name, Phone: e164, Tags: []string{tag}, IsActive: true})
+				continue
+			}
+
+			changed := false
+			if !hasTag(contact.Tags, tag) {
+				contact.Tags = append(contact.Tags, tag)
+				changed = true
+			}
+			if name != "" && (contact.Name == "" || contact.Name == contact.Phone || contact.Name == e164) {
+				contact.Name = name
+				changed = true
+			}
+			if changed {
+				updated = append(updated, *contact)
+			} else {
+				result.Duplicates++
+			}
+		}
+
+		if !dryRun {
+			if err := s.contacts.CreateContactsBatch(created); err != nil {
+				result.Failed += len(created)
+				result.Errors = append(result.Errors, fmt.Sprintf("Contacts %d-%d: %v", start+1, end, err))
+				created = nil
+			}
+			if err := s.contacts.UpdateNameAndTagsBatch(updated); err != nil {
+				result.Failed += len(updated)
+				result.Errors = append(result.Errors, fmt.Sprintf("Contacts %d-%d: %v", start+1, end, err))
+				updated = nil
+			}
+		}
+		result.Success += len(created)
+		result.Updated += len(updated)
+	}
+
+	if !dryRun {
+		s.logger.Info("Session %s synced %d contact(s): %d created, %d updated, %d skipped, %d failed",
+			sessionID, result.Total, result.Success, result.Updated, result.Duplicates, result.Failed)
+	}
+	return result, nil
+}
+
+// syncedContactName is the name a WhatsApp contact is synced under, preferring
+// the name saved on the phone
+func syncedContactName(contact types.ContactInfo) string {
+	for _, name := range []string{contact.FullName, contact.FirstName, contact.BusinessName, contact.PushName} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// hasTag reports whether tags contains tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
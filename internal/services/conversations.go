@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// GetConversations lists the chats of a session: its contacts and joined
+// groups, plus every chat with logged messages. The last message and unread
+// count of each chat come from the message log. When query.Label is set only
+// chats carrying that label (by name, case-insensitive) are returned.
+func (s *WhatsAppService) GetConversations(sessionID string, query *models.ConversationQuery) (*models.ConversationList, error) {
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, models.ErrSessionNotFound
+	}
+
+	if !session.IsConnected() || !session.IsLoggedIn() {
+		return nil, models.ErrSessionNotAuthenticated
+	}
+
+	switch query.Sort {
+	case "":
+		query.Sort = models.ConversationSortLastActivity
+	case models.ConversationSortLastActivity, models.ConversationSortName:
+	default:
+		return nil, models.NewBadRequestError("sort must be %s or %s", models.ConversationSortLastActivity, models.ConversationSortName)
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	// Get all contacts from the store
+	contacts, err := session.Client.Store.Contacts.GetAllContacts(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %v", err)
+	}
+
+	// Get joined groups
+	groups, err := session.Client.GetJoinedGroups(context.Background())
+	if err != nil {
+		s.logger.Warn("Failed to get groups: %v", err)
+		// Continue without groups
+		groups = []*types.GroupInfo{}
+	}
+
+	conversations := make([]*models.Conversation, 0, len(contacts)+len(groups))
+	byJID := make(map[string]*models.Conversation, len(contacts)+len(groups))
+	add := func(conversation *models.Conversation) {
+		conversations = append(conversations, conversation)
+		byJID[conversation.JID] = conversation
+	}
+
+	// Add individual chats
+	for jid, contact := range contacts {
+		if jid.Server != types.DefaultUserServer {
+			continue // Skip non-user contacts
+		}
+		add(&models.Conversation{
+			JID:  jid.String(),
+			Name: s.getContactName(contact),
+		})
+	}
+
+	// Add group chats
+	for _, group := range groups {
+		add(&models.Conversation{
+			JID:     group.JID.String(),
+			Name:    group.Name,
+			IsGroup: true,
+		})
+	}
+
+	if s.messageRepo != nil {
+		summaries, err := s.messageRepo.GetChatSummaries(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range summaries {
+			conversation, known := byJID[summary.ChatJID]
+			if !known {
+				jid, err := types.ParseJID(summary.ChatJID)
+				if err != nil || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer && jid.Server != types.GroupServer) {
+					continue // Broadcast lists and logs predating JID normalization
+				}
+				conversation = &models.Conversation{
+					JID:     summary.ChatJID,
+					Name:    contactName(contacts, jid),
+					IsGroup: jid.Server == types.GroupServer,
+				}
+				add(conversation)
+			}
+			applyChatSummary(conversation, summary, contacts)
+		}
+	}
+
+	conversations, err = s.applyChatLabels(sessionID, conversations, query.Label)
+	if err != nil {
+		return nil, err
+	}
+	sortConversations(conversations, query.Sort)
+
+	list := &models.ConversationList{
+		Conversations: conversations,
+		Total:         len(conversations),
+		Page:          query.Page,
+		Limit:         query.Limit,
+	}
+	if query.Limit > 0 {
+		start := (query.Page - 1) * query.Limit
+		if start > len(conversations) {
+			start = len(conversations)
+		}
+		end := start + query.Limit
+		if end > len(conversations) {
+			end = len(conversations)
+		}
+		list.Conversations = conversations[start:end]
+	}
+	list.Count = len(list.Conversations)
+	return list, nil
+}
+
+// applyChatSummary fills in a conversation's last message and unread count
+func applyChatSummary(conversation *models.Conversation, summary *repository.ChatSummary, contacts map[types.JID]types.ContactInfo) {
+	last := summary.LastMessage
+	lastTime := last.CreatedAt
+	conversation.LastMessageID = last.MessageID
+	conversation.LastMessageText = last.Content
+	conversation.LastMessageType = last.MessageType
+	conversation.LastMessageTime = &lastTime
+	conversation.LastMessageFromMe = last.Direction == "sent"
+	conversation.UnreadCount = summary.UnreadCount
+
+	if conversation.IsGroup && !conversation.LastMessageFromMe && last.SenderJID != "" {
+		conversation.LastMessageSender = last.SenderJID
+		if sender, err := types.ParseJID(last.SenderJID); err == nil {
+			conversation.LastMessageSenderName = contactName(contacts, sender)
+		}
+	}
+}
+
+// contactName is the name of a chat partner from the contact store, or the
+// phone number for unknown numbers
+func contactName(contacts map[types.JID]types.ContactInfo, jid types.JID) string {
+	contact := contacts[jid.ToNonAD()]
+	switch {
+	case contact.FullName != "":
+		return contact.FullName
+	case contact.BusinessName != "":
+		return contact.BusinessName
+	case contact.PushName != "":
+		return contact.PushName
+	case jid.Server == types.DefaultUserServer:
+		return "+" + jid.User
+	}
+	return ""
+}
+
+// sortConversations orders conversations by their last message, newest first,
+// or by name; ties and chats without messages are ordered by name
+func sortConversations(conversations []*models.Conversation, order string) {
+	sort.SliceStable(conversations, func(i, j int) bool {
+		a, b := conversations[i], conversations[j]
+		if order == models.ConversationSortLastActivity {
+			switch {
+			case a.LastMessageTime != nil && b.LastMessageTime == nil:
+				return true
+			case a.LastMessageTime == nil && b.LastMessageTime != nil:
+				return false
+			case a.LastMessageTime != nil && !a.LastMessageTime.Equal(*b.LastMessageTime):
+				return a.LastMessageTime.After(*b.LastMessageTime)
+			}
+		}
+		if nameA, nameB := strings.ToLower(a.Name), strings.ToLower(b.Name); nameA != nameB {
+			return nameA < nameB
+		}
+		return a.JID < b.JID
+	})
+}
+
+// recordChatRead records that a chat was read up to its latest logged
+// message, so the messages received before no longer count as unread
+func (s *WhatsAppService) recordChatRead(session *models.Session, chat types.JID) {
+	if s.messageRepo == nil {
+		return
+	}
+
+	// Chats are logged under the phone number JID when it is known
+	chat = chat.ToNonAD()
+	if chat.Server == types.HiddenUserServer {
+		if pn, err := session.Client.Store.LIDs.GetPNForLID(context.Background(), chat); err == nil && !pn.IsEmpty() {
+			chat = pn.ToNonAD()
+		}
+	}
+
+	if err := s.messageRepo.MarkChatRead(session.ID, chat.String()); err != nil {
+		s.logger.Warn("Failed to record chat %s of session %s as read: %v", chat, session.ID, err)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// Defaults for media downloaded from URLs, see SetDownloadLimits
+const (
+	defaultMaxDownloadSize = 64 << 20
+	defaultDownloadTimeout = 2 * time.Minute
+)
+
+// downloadUserAgent identifies the server to the hosts it downloads from
+const downloadUserAgent = "WhatsApp-Multi-Session/1.0"
+
+// SetDownloadLimits bounds media downloaded from URLs: larger bodies are
+// rejected with a PayloadTooLargeError, and a download is aborted after timeout
+func (s *WhatsAppService) SetDownloadLimits(maxSize int64, timeout time.Duration) {
+	if maxSize > 0 {
+		s.maxDownloadSize = maxSize
+	}
+	if timeout > 0 {
+		s.downloadTimeout = timeout
+	}
+}
+
+// downloadFile downloads a file from url, reading at most the maximum
+// download size into memory. With keepLocal a copy is kept in storage.
+func (s *WhatsAppService) downloadFile(url string, keepLocal bool) ([]byte, string, string, error) {
+	maxSize, timeout := s.maxDownloadSize, s.downloadTimeout
+	if maxSize <= 0 {
+		maxSize = defaultMaxDownloadSize
+	}
+	if timeout <= 0 {
+		timeout = defaultDownloadTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", "", models.NewBadRequestError("invalid download URL: %v", err)
+	}
+	if err := s.guard.checkURL(ctx, req.URL); err != nil {
+		return nil, "", "", models.NewBadRequestError("download URL not allowed: %v", err)
+	}
+	req.Header.Set("User-Agent", downloadUserAgent)
+
+	// The shared client's timeout would cut large downloads short; the
+	// context bounds this one instead
+	client := s.guard.client(s.httpClients.Default())
+	client.Timeout = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", "", models.NewBadRequestError("download of %s timed out after %s", url, timeout)
+		}
+		return nil, "", "", models.NewBadRequestError("failed to download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", models.NewBadRequestError("download failed with status: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, "", "", models.NewPayloadTooLargeError("file is %d bytes, downloads are limited to %d bytes", resp.ContentLength, maxSize)
+	}
+
+	// Read one byte more than allowed to tell a body at the limit from a larger one
+	fileData, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", "", models.NewBadRequestError("download of %s timed out after %s", url, timeout)
+		}
+		return nil, "", "", models.NewBadRequestError("failed to read file data: %v", err)
+	}
+	if int64(len(fileData)) > maxSize {
+		return nil, "", "", models.NewPayloadTooLargeError("file exceeds the download limit of %d bytes", maxSize)
+	}
+
+	// Get content type
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(fileData)
+	}
+
+	// Extract filename from URL or Content-Disposition
+	filename := s.extractFilename(url, resp.Header.Get("Content-Disposition"))
+
+	if keepLocal {
+		key := storage.JoinKey(storage.PrefixDownloads, filepath.Base(filename))
+		if err := s.storage.Put(context.Background(), key, fileData, contentType); err != nil {
+			s.logger.Error("Failed to store downloaded file: %v", err)
+			// Continue even if storing the copy fails
+		} else {
+			s.logger.Info("File stored: %s (%s)", key, s.storage.Driver())
+		}
+	}
+
+	return fileData, contentType, filename, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// EditMessage replaces the text of one of the session's sent text messages.
+// Only logged messages can be edited, since WhatsApp only accepts edits within
+// models.EditWindow of sending and the log is where the send time is known.
+// The logged content is updated to the new text.
+func (s *WhatsAppService) EditMessage(sessionID, chatJID, messageID, newText string) (*models.EditMessageResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return nil, models.NewBadRequestError("message ID is required")
+	}
+	if strings.TrimSpace(newText) == "" {
+		return nil, models.NewBadRequestError("text is required")
+	}
+	if s.messageRepo == nil {
+		return nil, models.NewServiceUnavailableError("message log is not available")
+	}
+
+	logged, err := s.messageRepo.GetMessageByID(sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if logged == nil {
+		return nil, models.NewNotFoundError("message %s not found", messageID)
+	}
+	if logged.Direction != "sent" {
+		return nil, models.NewBadRequestError("message %s was received, only the session's own messages can be edited", messageID)
+	}
+	if logged.MessageType != "text" {
+		return nil, models.NewBadRequestError("message %s is a %s message, only text messages can be edited", messageID, logged.MessageType)
+	}
+	switch logged.Status {
+	case "failed", "revoked", "deleted":
+		return nil, models.NewBadRequestError("message %s is %s and can no longer be edited", messageID, logged.Status)
+	}
+	if time.Since(logged.CreatedAt) > models.EditWindow {
+		return nil, models.NewEditWindowExpiredError(messageID, logged.CreatedAt)
+	}
+
+	chat, err := revokeChat(chatJID, logged)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := session.Client.BuildEdit(chat, messageID, &waProto.Message{Conversation: proto.String(newText)})
+	sent, err := s.sendMessage(session, chat, edit)
+	if err != nil {
+		return nil, s.sendFailure(session, "failed to edit message", err)
+	}
+
+	editedAt := time.Now()
+	if err := s.messageRepo.UpdateEditedMessage(sessionID, messageID, newText, editedAt); err != nil {
+		s.logger.Error("Failed to record edit of message %s for session %s: %v", messageID, sessionID, err)
+	}
+
+	s.logger.Info("Session %s edited message %s in %s", sessionID, messageID, chat)
+	return &models.EditMessageResponse{
+		MessageID:     messageID,
+		Chat:          chat.String(),
+		Text:          newText,
+		EditMessageID: sent.ID,
+		EditedAt:      models.FormatTimestamp(editedAt),
+	}, nil
+}
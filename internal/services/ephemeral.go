@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SetChatEphemeral sets the disappearing message timer of a direct chat or a
+// group. Messages sent to the chat afterwards should carry the same timer as
+// their ephemeral_expiration to disappear on the recipient's side.
+func (s *WhatsAppService) SetChatEphemeral(sessionID, chat, timer string) (int, error) {
+	seconds, ok := models.EphemeralTimers[timer]
+	if !ok {
+		return 0, models.NewBadRequestError("timer must be off, 24h, 7d or 90d")
+	}
+
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return 0, err
+	}
+
+	jid, err := parseRecipientJID(chat)
+	if err != nil {
+		return 0, models.NewBadRequestError("%v", err)
+	}
+	switch jid.Server {
+	case types.DefaultUserServer, types.HiddenUserServer, types.GroupServer:
+	default:
+		return 0, models.NewBadRequestError("disappearing messages can only be set for direct chats and groups, not %s", jid)
+	}
+
+	if !session.Sandbox {
+		if err := session.Client.SetDisappearingTimer(context.Background(), jid, time.Duration(seconds)*time.Second, time.Now()); err != nil {
+			if errors.Is(err, whatsmeow.ErrInvalidDisappearingTimer) {
+				return 0, models.NewBadRequestError("WhatsApp rejected the %s timer", timer)
+			}
+			if jid.Server == types.GroupServer {
+				return 0, groupFailure("failed to set disappearing messages", err)
+			}
+			return 0, s.sendFailure(session, "failed to set disappearing messages", err)
+		}
+	}
+
+	s.logger.Info("Session %s set disappearing messages in %s to %s", sessionID, jid, timer)
+	return seconds, nil
+}
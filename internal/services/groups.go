@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// CreateGroup creates a group with the session's account as its owner. The
+// response reports, per participant, whether WhatsApp added them.
+func (s *WhatsAppService) CreateGroup(sessionID, name string, participants []string) (*models.GroupResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, models.NewBadRequestError("name is required")
+	}
+	if length := utf8.RuneCountInString(name); length > models.GroupNameMaxLength {
+		return nil, models.NewBadRequestError("name is %d characters, WhatsApp allows at most %d", length, models.GroupNameMaxLength)
+	}
+	jids, err := parseParticipantJIDs(participants, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := session.Client.CreateGroup(context.Background(), whatsmeow.ReqCreateGroup{Name: name, Participants: jids})
+	if err != nil {
+		return nil, groupFailure("failed to create group", err)
+	}
+
+	s.logger.Info("Session %s created group %s with %d participant(s)", sessionID, info.JID, len(jids))
+	resp := &models.GroupResponse{
+		JID:          info.JID.String(),
+		Name:         info.Name,
+		Created:      models.FormatTimestamp(info.GroupCreated),
+		Participants: make([]*models.GroupParticipantResult, 0, len(info.Participants)),
+	}
+	if !info.OwnerJID.IsEmpty() {
+		resp.Owner = info.OwnerJID.String()
+	}
+	own := session.Client.Store.ID
+	for _, participant := range info.Participants {
+		// The owner is listed as well, but was not asked for
+		if own != nil && participant.JID.User == own.User {
+			continue
+		}
+		resp.Participants = append(resp.Participants, groupParticipantResult(participant))
+	}
+	return resp, nil
+}
+
+// AddGroupParticipants adds participants to a group the session administers
+func (s *WhatsAppService) AddGroupParticipants(sessionID, groupJID string, participants []string) ([]*models.GroupParticipantResult, error) {
+	return s.updateGroupParticipants(sessionID, groupJID, participants, whatsmeow.ParticipantChangeAdd)
+}
+
+// RemoveGroupParticipants removes participants from a group the session administers
+func (s *WhatsAppService) RemoveGroupParticipants(sessionID, groupJID string, participants []string) ([]*models.GroupParticipantResult, error) {
+	return s.updateGroupParticipants(sessionID, groupJID, participants, whatsmeow.ParticipantChangeRemove)
+}
+
+// PromoteParticipants makes participants of a group admins
+func (s *WhatsAppService) PromoteParticipants(sessionID, groupJID string, participants []string) ([]*models.GroupParticipantResult, error) {
+	return s.updateGroupParticipants(sessionID, groupJID, participants, whatsmeow.ParticipantChangePromote)
+}
+
+// DemoteParticipants revokes the admin rights of participants of a group
+func (s *WhatsAppService) DemoteParticipants(sessionID, groupJID string, participants []string) ([]*models.GroupParticipantResult, error) {
+	return s.updateGroupParticipants(sessionID, groupJID, participants, whatsmeow.ParticipantChangeDemote)
+}
+
+func (s *WhatsAppService) updateGroupParticipants(sessionID, groupJID string, participants []string, action whatsmeow.ParticipantChange) ([]*models.GroupParticipantResult, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+
+	group, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+	jids, err := parseParticipantJIDs(participants, false)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := session.Client.UpdateGroupParticipants(context.Background(), group, jids, action)
+	if err != nil {
+		return nil, groupFailure(fmt.Sprintf("failed to %s participants", action), err)
+	}
+
+	results := make([]*models.GroupParticipantResult, 0, len(changed))
+	for _, participant := range changed {
+		results = append(results, groupParticipantResult(participant))
+	}
+	s.logger.Info("Session %s requested %s of %d participant(s) in %s", sessionID, action, len(jids), group)
+	return results, nil
+}
+
+// LeaveGroup makes the session's account leave a group
+func (s *WhatsAppService) LeaveGroup(sessionID, groupJID string) error {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	group, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+
+	if err := session.Client.LeaveGroup(context.Background(), group); err != nil {
+		return groupFailure("failed to leave group", err)
+	}
+
+	s.logger.Info("Session %s left group %s", sessionID, group)
+	return nil
+}
+
+// SetGroupName changes the name (subject) of a group the session administers
+func (s *WhatsAppService) SetGroupName(sessionID, groupJID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.NewBadRequestError("name is required")
+	}
+	if length := utf8.RuneCountInString(name); length > models.GroupNameMaxLength {
+		return models.NewBadRequestError("name is %d characters, WhatsApp allows at most %d", length, models.GroupNameMaxLength)
+	}
+
+	session, group, err := s.administeredGroup(sessionID, groupJID)
+	if err != nil {
+		return err
+	}
+	if err := session.Client.SetGroupName(context.Background(), group, name); err != nil {
+		return groupFailure("failed to set group name", err)
+	}
+
+	s.logger.Info("Session %s renamed group %s", sessionID, group)
+	return nil
+}
+
+// SetGroupTopic changes the description of a group the session administers
+func (s *WhatsAppService) SetGroupTopic(sessionID, groupJID, topic string) error {
+	topic = strings.TrimSpace(topic)
+	if length := utf8.RuneCountInString(topic); length > models.GroupTopicMaxLength {
+		return models.NewBadRequestError("topic is %d characters, WhatsApp allows at most %d", length, models.GroupTopicMaxLength)
+	}
+
+	session, group, err := s.administeredGroup(sessionID, groupJID)
+	if err != nil {
+		return err
+	}
+	if err := session.Client.SetGroupTopic(context.Background(), group, "", "", topic); err != nil {
+		return groupFailure("failed to set group topic", err)
+	}
+
+	s.logger.Info("Session %s changed the topic of group %s", sessionID, group)
+	return nil
+}
+
+// SetGroupPhoto changes the picture of a group the session administers and
+// returns the new picture's ID
+func (s *WhatsAppService) SetGroupPhoto(sessionID, groupJID string, jpeg []byte) (string, error) {
+	if len(jpeg) == 0 {
+		return "", models.NewBadRequestError("image is required")
+	}
+	if len(jpeg) < 3 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 || jpeg[2] != 0xFF {
+		return "", models.NewBadRequestError("image must be a JPEG")
+	}
+
+	session, group, err := s.administeredGroup(sessionID, groupJID)
+	if err != nil {
+		return "", err
+	}
+	pictureID, err := session.Client.SetGroupPhoto(context.Background(), group, jpeg)
+	if err != nil {
+		return "", groupFailure("failed to set group photo", err)
+	}
+
+	s.logger.Info("Session %s changed the photo of group %s", sessionID, group)
+	return pictureID, nil
+}
+
+// GetGroupInviteLink returns the invite link of a group the session
+// administers. With reset the current link is revoked and a new one created.
+func (s *WhatsAppService) GetGroupInviteLink(sessionID, groupJID string, reset bool) (string, error) {
+	session, group, err := s.administeredGroup(sessionID, groupJID)
+	if err != nil {
+		return "", err
+	}
+	link, err := session.Client.GetGroupInviteLink(context.Background(), group, reset)
+	if err != nil {
+		return "", groupFailure("failed to get group invite link", err)
+	}
+
+	if reset {
+		s.logger.Info("Session %s reset the invite link of group %s", sessionID, group)
+	}
+	return link, nil
+}
+
+// PreviewGroupInvite returns the group behind an invite link without joining it
+func (s *WhatsAppService) PreviewGroupInvite(sessionID, link string) (*models.GroupInvitePreview, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	code, err := parseInviteCode(link)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := session.Client.GetGroupInfoFromLink(context.Background(), code)
+	if err != nil {
+		return nil, inviteFailure("failed to look up invite link", err)
+	}
+
+	preview := &models.GroupInvitePreview{
+		JID:              info.JID.String(),
+		Name:             info.Name,
+		Topic:            info.Topic,
+		Size:             info.ParticipantCount,
+		ApprovalRequired: info.IsJoinApprovalRequired,
+	}
+	if preview.Size == 0 {
+		preview.Size = len(info.Participants)
+	}
+	if !info.GroupCreated.IsZero() {
+		preview.Created = models.FormatTimestamp(info.GroupCreated)
+	}
+	return preview, nil
+}
+
+// JoinGroup joins the group behind an invite link. Groups requiring approval
+// only receive a request to join.
+func (s *WhatsAppService) JoinGroup(sessionID, link string) (*models.JoinGroupResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+	code, err := parseInviteCode(link)
+	if err != nil {
+		return nil, err
+	}
+
+	// Looked up first for the name, and to reject dead links with a clear error
+	info, err := session.Client.GetGroupInfoFromLink(context.Background(), code)
+	if err != nil {
+		return nil, inviteFailure("failed to look up invite link", err)
+	}
+	group, err := session.Client.JoinGroupWithLink(context.Background(), code)
+	if err != nil {
+		return nil, inviteFailure("failed to join group", err)
+	}
+
+	s.logger.Info("Session %s joined group %s", sessionID, group)
+	return &models.JoinGroupResponse{
+		JID:             group.String(),
+		Name:            info.Name,
+		PendingApproval: info.IsJoinApprovalRequired,
+	}, nil
+}
+
+// parseInviteCode extracts the invite code from a group invite link or code
+func parseInviteCode(link string) (string, error) {
+	code := strings.TrimSpace(link)
+	if i := strings.Index(code, "chat.whatsapp.com/"); i >= 0 {
+		code = code[i+len("chat.whatsapp.com/"):]
+		if end := strings.IndexAny(code, "/?#"); end >= 0 {
+			code = code[:end]
+		}
+	}
+	if code == "" {
+		return "", models.NewBadRequestError("link is required")
+	}
+	for _, r := range code {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "", models.NewBadRequestError("%q is not a group invite link or code", link)
+		}
+	}
+	return code, nil
+}
+
+// inviteFailure explains invite links WhatsApp does not accept
+func inviteFailure(action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrInviteLinkRevoked):
+		return models.NewBadRequestError("%s: the invite link was revoked or has expired", action)
+	case errors.Is(err, whatsmeow.ErrInviteLinkInvalid), errors.Is(err, whatsmeow.ErrIQNotFound):
+		return models.NewBadRequestError("%s: the invite link is not valid", action)
+	case errors.Is(err, whatsmeow.ErrIQResourceLimit):
+		return models.NewBadRequestError("%s: the group is full", action)
+	}
+	return groupFailure(action, err)
+}
+
+// administeredGroup returns the logged in session and the parsed group,
+// failing with a ForbiddenError unless the session's account is an admin of it
+func (s *WhatsAppService) administeredGroup(sessionID, groupJID string) (*models.Session, types.JID, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, types.EmptyJID, err
+	}
+	group, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, types.EmptyJID, err
+	}
+
+	info, err := session.Client.GetGroupInfo(context.Background(), group)
+	if err != nil {
+		return nil, types.EmptyJID, groupFailure("failed to get group info", err)
+	}
+
+	own, ownLID := session.Client.Store.ID, session.Client.Store.LID
+	for _, participant := range info.Participants {
+		isSelf := (own != nil && participant.JID.User == own.User) ||
+			(!ownLID.IsEmpty() && participant.JID.User == ownLID.User) ||
+			(own != nil && participant.PhoneNumber.User == own.User)
+		if !isSelf {
+			continue
+		}
+		if participant.IsAdmin || participant.IsSuperAdmin {
+			return session, group, nil
+		}
+		break
+	}
+	return nil, types.EmptyJID, models.NewForbiddenError("session %s is not an admin of group %s", sessionID, group)
+}
+
+// parseGroupJID parses a group JID or bare group ID
+func parseGroupJID(groupJID string) (types.JID, error) {
+	jid, err := parseRecipientJID(groupJID)
+	if err != nil {
+		return types.EmptyJID, models.NewBadRequestError("invalid group: %v", err)
+	}
+	if jid.Server != types.GroupServer {
+		return types.EmptyJID, models.NewBadRequestError("%s is not a group JID", groupJID)
+	}
+	return jid, nil
+}
+
+// parseParticipantJIDs parses the phone numbers or user JIDs of group
+// participants. Creating a group may list none.
+func parseParticipantJIDs(participants []string, allowEmpty bool) ([]types.JID, error) {
+	if len(participants) == 0 && !allowEmpty {
+		return nil, models.NewBadRequestError("participants is required")
+	}
+
+	jids := make([]types.JID, 0, len(participants))
+	seen := make(map[types.JID]bool, len(participants))
+	for _, participant := range participants {
+		jid, err := parseRecipientJID(participant)
+		if err != nil {
+			return nil, models.NewBadRequestError("invalid participant %q: %v", participant, err)
+		}
+		if jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer {
+			return nil, models.NewBadRequestError("participant %q must be a phone number or user JID", participant)
+		}
+		if !seen[jid] {
+			seen[jid] = true
+			jids = append(jids, jid)
+		}
+	}
+	return jids, nil
+}
+
+// groupParticipantResult translates WhatsApp's answer for one participant
+func groupParticipantResult(participant types.GroupParticipant) *models.GroupParticipantResult {
+	result := &models.GroupParticipantResult{
+		JID:       participant.JID.String(),
+		Status:    models.GroupParticipantOK,
+		ErrorCode: participant.Error,
+	}
+	if !participant.PhoneNumber.IsEmpty() {
+		result.Phone = phone.FromJID(participant.PhoneNumber.String())
+	} else if participant.JID.Server == types.DefaultUserServer {
+		result.Phone = phone.FromJID(participant.JID.String())
+	}
+
+	switch participant.Error {
+	case 0, 200:
+		result.ErrorCode = 0
+	case 401:
+		result.Status = models.GroupParticipantNotAuthorized
+	case 403:
+		result.Status = models.GroupParticipantInviteRequired
+		if participant.AddRequest != nil {
+			result.InviteCode = participant.AddRequest.Code
+			result.InviteExpiration = models.FormatTimestamp(participant.AddRequest.Expiration)
+		}
+	case 404:
+		result.Status = models.GroupParticipantNotFound
+	case 408:
+		result.Status = models.GroupParticipantRecentlyLeft
+	case 409:
+		result.Status = models.GroupParticipantAlreadyMember
+	default:
+		result.Status = models.GroupParticipantFailed
+	}
+	return result
+}
+
+// groupFailure turns WhatsApp's answers to group requests into API errors
+func groupFailure(action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return models.NewUnauthorizedError("session is not authenticated")
+	case errors.Is(err, whatsmeow.ErrGroupNotFound), errors.Is(err, whatsmeow.ErrIQNotFound):
+		return models.NewNotFoundError("%s: group not found", action)
+	case errors.Is(err, whatsmeow.ErrNotInGroup):
+		return models.NewBadRequestError("%s: the session is not a participant of the group", action)
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAuthorized):
+		return models.NewForbiddenError("%s: the session is not an admin of the group", action)
+	case errors.Is(err, whatsmeow.ErrInvalidImageFormat):
+		return models.NewBadRequestError("%s: WhatsApp rejected the image, use a square JPEG", action)
+	case errors.Is(err, whatsmeow.ErrIQBadRequest), errors.Is(err, whatsmeow.ErrIQNotAcceptable):
+		return models.NewBadRequestError("%s: %v", action, err)
+	}
+	return models.NewServiceUnavailableError("%s: %v", action, err)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/httpclient"
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// newTestDatabase returns a migrated SQLite database that is removed when
+// the test ends
+func newTestDatabase(t *testing.T) *repository.Database {
+	t.Helper()
+	db, err := repository.NewDatabase(repository.DatabaseConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitTables(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// newTestLogger returns a logger that discards everything below errors
+func newTestLogger() *logger.Logger {
+	return logger.New(false, "error", "text")
+}
+
+// newTestWhatsAppService returns a WhatsApp service on a fresh database with
+// one user to own sessions, and the session repository behind it. Webhook
+// hosts resolve through testHosts.
+func newTestWhatsAppService(t *testing.T) (*WhatsAppService, *repository.SessionRepository, int) {
+	t.Helper()
+	return newTestWhatsAppServiceOn(t, newTestDatabase(t))
+}
+
+// newTestWhatsAppServiceOn is newTestWhatsAppService on the given database
+func newTestWhatsAppServiceOn(t *testing.T, db *repository.Database) (*WhatsAppService, *repository.SessionRepository, int) {
+	t.Helper()
+	user := &models.User{Username: "alice", Password: "x", Role: models.RoleUser, IsActive: true, CreatedAt: time.Now()}
+	if err := repository.NewUserRepository(db.DB()).Create(user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	mediaStorage, err := storage.New(storage.Config{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	sessionRepo := repository.NewSessionRepository(db.DB())
+	service, err := NewWhatsAppService(filepath.Join(t.TempDir(), "whatsapp.db"), sessionRepo,
+		repository.NewMessageRepository(db.DB()), mediaStorage, httpclient.NewPool(&http.Client{}), newTestLogger())
+	if err != nil {
+		t.Fatalf("failed to create WhatsApp service: %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+	service.guard.lookup = fakeLookup(testHosts)
+
+	return service, sessionRepo, user.ID
+}
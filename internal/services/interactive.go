@@ -0,0 +1,35 @@
+package services
+
+import (
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// interactiveReply describes the list row or button a message selects and
+// returns its message type, list_reply or button_reply. Quick-reply buttons of
+// template messages are button replies too. Other messages return nil.
+func interactiveReply(msg *waProto.Message) (string, *models.WebhookInteractiveReply) {
+	switch {
+	case msg.GetListResponseMessage() != nil:
+		list := msg.GetListResponseMessage()
+		return "list_reply", &models.WebhookInteractiveReply{
+			ID:          list.GetSingleSelectReply().GetSelectedRowID(),
+			Title:       list.GetTitle(),
+			Description: list.GetDescription(),
+		}
+	case msg.GetButtonsResponseMessage() != nil:
+		buttons := msg.GetButtonsResponseMessage()
+		return "button_reply", &models.WebhookInteractiveReply{
+			ID:    buttons.GetSelectedButtonID(),
+			Title: buttons.GetSelectedDisplayText(),
+		}
+	case msg.GetTemplateButtonReplyMessage() != nil:
+		template := msg.GetTemplateButtonReplyMessage()
+		return "button_reply", &models.WebhookInteractiveReply{
+			ID:    template.GetSelectedID(),
+			Title: template.GetSelectedDisplayText(),
+		}
+	}
+	return "", nil
+}
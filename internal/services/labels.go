@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// SetLabelRepository enables the local mirror of WhatsApp Business chat labels
+func (s *WhatsAppService) SetLabelRepository(labels *repository.LabelRepository) {
+	s.labels = labels
+}
+
+// handleLabelEdit mirrors a label created, renamed or deleted on any device
+func (s *WhatsAppService) handleLabelEdit(session *models.Session, evt *events.LabelEdit) {
+	if s.labels == nil || evt.Action == nil {
+		return
+	}
+
+	var err error
+	if evt.Action.GetDeleted() {
+		err = s.labels.DeleteLabel(session.ID, evt.LabelID)
+	} else {
+		err = s.labels.UpsertLabel(session.ID, &models.ChatLabel{
+			ID:    evt.LabelID,
+			Name:  evt.Action.GetName(),
+			Color: int(evt.Action.GetColor()),
+		})
+	}
+	if err != nil {
+		s.logger.Error("Failed to mirror label %s for session %s: %v", evt.LabelID, session.ID, err)
+	}
+}
+
+// handleLabelAssociation mirrors a chat being labeled or unlabeled on any device
+func (s *WhatsAppService) handleLabelAssociation(session *models.Session, evt *events.LabelAssociationChat) {
+	if s.labels == nil || evt.Action == nil {
+		return
+	}
+
+	chatJID := evt.JID.ToNonAD().String()
+	if err := s.labels.SetChatLabel(session.ID, chatJID, evt.LabelID, evt.Action.GetLabeled()); err != nil {
+		s.logger.Error("Failed to mirror label %s on %s for session %s: %v", evt.LabelID, chatJID, session.ID, err)
+	}
+}
+
+// GetLabels returns the session's chat labels. business reports whether the
+// account is a WhatsApp Business account; other accounts have no labels.
+// With refresh the labels are re-read from the phone's app state first.
+func (s *WhatsAppService) GetLabels(sessionID string, refresh bool) (labels []*models.ChatLabel, business bool, err error) {
+	if s.labels == nil {
+		return nil, false, models.NewServiceUnavailableError("label storage is not configured")
+	}
+
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return nil, false, models.NewNotFoundError("session not found")
+	}
+
+	if refresh {
+		if session, err = s.loggedInSession(sessionID); err != nil {
+			return nil, false, err
+		}
+		session.Client.EmitAppStateEventsOnFullSync = true
+		if err := session.Client.FetchAppState(context.Background(), appstate.WAPatchRegular, true, false); err != nil {
+			return nil, false, fmt.Errorf("failed to sync labels: %v", err)
+		}
+	}
+
+	labels, err = s.labels.GetLabels(sessionID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	business = len(labels) > 0
+	if session.Client != nil && session.Client.Store.BusinessName != "" {
+		business = true
+	}
+	return labels, business, nil
+}
+
+// GetChatLabels returns the labels assigned to a chat
+func (s *WhatsAppService) GetChatLabels(sessionID, chat string) ([]*models.ChatLabel, error) {
+	if s.labels == nil {
+		return nil, models.NewServiceUnavailableError("label storage is not configured")
+	}
+
+	if _, exists := s.GetSession(sessionID); !exists {
+		return nil, models.NewNotFoundError("session not found")
+	}
+
+	jid, err := parseRecipientJID(chat)
+	if err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+
+	return s.labels.GetChatLabels(sessionID, jid.String())
+}
+
+// SetChatLabel assigns a label to a chat, or removes it, on the phone and in the mirror
+func (s *WhatsAppService) SetChatLabel(sessionID, chat string, req *models.ChatLabelRequest, labeled bool) (*models.ChatLabel, error) {
+	if s.labels == nil {
+		return nil, models.NewServiceUnavailableError("label storage is not configured")
+	}
+
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := parseRecipientJID(chat)
+	if err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+
+	label, err := s.findLabel(sessionID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := appstate.BuildLabelChat(jid, label.ID, labeled)
+	if err := session.Client.SendAppState(context.Background(), patch); err != nil {
+		return nil, fmt.Errorf("failed to update label on WhatsApp: %v", err)
+	}
+
+	if err := s.labels.SetChatLabel(sessionID, jid.String(), label.ID, labeled); err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
+
+// findLabel resolves a label by ID or, case-insensitively, by name
+func (s *WhatsAppService) findLabel(sessionID string, req *models.ChatLabelRequest) (*models.ChatLabel, error) {
+	if req.LabelID == "" && req.Label == "" {
+		return nil, models.NewBadRequestError("label_id or label is required")
+	}
+
+	labels, err := s.labels.GetLabels(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, label := range labels {
+		if (req.LabelID != "" && label.ID == req.LabelID) ||
+			(req.LabelID == "" && strings.EqualFold(label.Name, req.Label)) {
+			return label, nil
+		}
+	}
+
+	if req.LabelID != "" {
+		return nil, models.NewNotFoundError("label %s not found", req.LabelID)
+	}
+	return nil, models.NewNotFoundError("label %q not found", req.Label)
+}
+
+// applyChatLabels attaches label names to conversations and, when label is
+// set, keeps only the conversations carrying it
+func (s *WhatsAppService) applyChatLabels(sessionID string, conversations []*models.Conversation, label string) ([]*models.Conversation, error) {
+	if s.labels == nil {
+		if label != "" {
+			return nil, models.NewServiceUnavailableError("label storage is not configured")
+		}
+		return conversations, nil
+	}
+
+	assignments, err := s.labels.GetAssignments(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := conversations[:0]
+	for _, conversation := range conversations {
+		conversation.Labels = assignments[conversation.JID]
+		if label == "" || hasLabel(conversation.Labels, label) {
+			filtered = append(filtered, conversation)
+		}
+	}
+	return filtered, nil
+}
+
+func hasLabel(labels []string, name string) bool {
+	for _, label := range labels {
+		if strings.EqualFold(label, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// MarkRead sends read receipts for messages received in a chat. Without
+// message IDs, every message received in the chat since it was last marked
+// read is marked. Type seen additionally marks the whole chat read in the
+// account's app state, which clears the unread badge on the phone even for
+// messages the service never saw.
+func (s *WhatsAppService) MarkRead(sessionID string, req *models.MarkReadRequest) (*models.MarkReadResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	receiptType := types.ReceiptTypeRead
+	switch req.Type {
+	case "", models.MarkReadTypeRead, models.MarkReadTypeSeen:
+	case models.MarkReadTypePlayed:
+		receiptType = types.ReceiptTypePlayed
+	default:
+		return nil, models.NewBadRequestError("type must be %s, %s or %s", models.MarkReadTypeRead, models.MarkReadTypePlayed, models.MarkReadTypeSeen)
+	}
+
+	chat, err := parseRecipientJID(req.Chat)
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid chat: %v", err)
+	}
+
+	var messages []unreadMessage
+	if len(req.MessageIDs) > 0 {
+		var sender types.JID
+		if req.Sender != "" {
+			if sender, err = parseRecipientJID(req.Sender); err != nil {
+				return nil, models.NewBadRequestError("invalid sender: %v", err)
+			}
+		} else if chat.Server == types.GroupServer {
+			return nil, models.NewBadRequestError("sender is required to mark messages of a group read")
+		} else {
+			sender = chat
+		}
+		for _, id := range req.MessageIDs {
+			if id = strings.TrimSpace(id); id != "" {
+				messages = append(messages, unreadMessage{id: id, sender: sender})
+			}
+		}
+		if len(messages) == 0 {
+			return nil, models.NewBadRequestError("message_ids must not be empty strings")
+		}
+	} else {
+		messages = s.unread.take(session.ID, chat)
+	}
+
+	// Receipts can only list messages of one sender
+	bySender := make(map[types.JID][]types.MessageID)
+	var order []types.JID
+	for _, message := range messages {
+		if _, seen := bySender[message.sender]; !seen {
+			order = append(order, message.sender)
+		}
+		bySender[message.sender] = append(bySender[message.sender], message.id)
+	}
+
+	resp := &models.MarkReadResponse{Chat: chat.String(), MessageIDs: []string{}}
+	now := time.Now()
+	for _, sender := range order {
+		ids := bySender[sender]
+		if !session.Sandbox {
+			if err := session.Client.MarkRead(context.Background(), ids, now, chat, sender, receiptType); err != nil {
+				if len(resp.MessageIDs) == 0 {
+					return nil, models.NewServiceUnavailableError("failed to mark messages read: %v", err)
+				}
+				s.logger.Warn("Failed to mark %d message(s) in %s read for session %s: %v", len(ids), chat, sessionID, err)
+				continue
+			}
+		}
+		if len(req.MessageIDs) > 0 {
+			s.unread.remove(session.ID, chat, ids)
+		}
+		resp.MessageIDs = append(resp.MessageIDs, ids...)
+	}
+
+	if req.Type == models.MarkReadTypeSeen {
+		if !session.Sandbox {
+			if err := session.Client.SendAppState(context.Background(), appstate.BuildMarkChatAsRead(chat, true, now, nil)); err != nil {
+				return nil, models.NewServiceUnavailableError("failed to mark chat read: %v", err)
+			}
+		}
+		resp.ChatMarked = true
+	}
+	if len(req.MessageIDs) == 0 || resp.ChatMarked {
+		s.recordChatRead(session, chat)
+	}
+
+	s.logger.Info("Marked %d message(s) in %s read for session %s", len(resp.MessageIDs), chat, sessionID)
+	return resp, nil
+}
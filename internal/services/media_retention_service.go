@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// mediaRetentionInterval is how often received media is swept
+const mediaRetentionInterval = time.Hour
+
+// MediaRetentionService periodically deletes received media older than the
+// retention, so stored files do not accumulate forever.
+type MediaRetentionService struct {
+	storage   storage.Storage
+	retention time.Duration
+	log       *logger.Logger
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewMediaRetentionService creates a service that deletes received media older than retention (0 keeps it)
+func NewMediaRetentionService(mediaStorage storage.Storage, retention time.Duration, log *logger.Logger) *MediaRetentionService {
+	return &MediaRetentionService{
+		storage:   mediaStorage,
+		retention: retention,
+		log:       log,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start sweeps received media now and every hour in the background until Stop
+// is called. Without a retention it does nothing.
+func (s *MediaRetentionService) Start() {
+	if s.retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(mediaRetentionInterval)
+		defer ticker.Stop()
+
+		s.Sweep()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background sweep
+func (s *MediaRetentionService) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Sweep deletes received media stored longer ago than the retention
+func (s *MediaRetentionService) Sweep() {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-s.retention)
+
+	objects, err := s.storage.List(ctx, storage.PrefixReceivedMedia+"/")
+	if err != nil {
+		s.log.Error("Media retention sweep failed to list media files: %v", err)
+		return
+	}
+
+	deleted := 0
+	for _, object := range objects {
+		if !object.ModTime.Before(cutoff) {
+			continue
+		}
+		if err := s.storage.Delete(ctx, object.Key); err != nil {
+			s.log.Error("Failed to delete expired media file %s: %v", object.Key, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		s.log.Info("Media retention sweep deleted %d media file(s) older than %s", deleted, s.retention)
+	}
+}
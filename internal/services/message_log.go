@@ -0,0 +1,121 @@
+package services
+
+import (
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// logIncomingMessage stores a message from the session's chats in the message
+// log, so the message history has both sides of each conversation. Messages
+// the account sent from the phone or another linked device are logged as
+// sent and edits update the logged text. Other protocol messages, statuses and
+// unsupported types are not logged. Media is stored with the message when the
+// session persists it.
+func (s *WhatsAppService) logIncomingMessage(session *models.Session, evt *events.Message) {
+	if s.messageRepo == nil || evt.Message == nil || evt.Info.Chat == types.StatusBroadcastJID {
+		return
+	}
+	if protocol := evt.Message.GetProtocolMessage(); protocol.GetType() == waProto.ProtocolMessage_MESSAGE_EDIT {
+		// Edits from the chat partner or the account's phone replace the logged text
+		if text := messageText(protocol.GetEditedMessage()); text != "" {
+			editedAt := evt.Info.Timestamp
+			if ms := protocol.GetTimestampMS(); ms > 0 {
+				editedAt = time.UnixMilli(ms)
+			}
+			if err := s.messageRepo.UpdateEditedMessage(session.ID, protocol.GetKey().GetID(), text, editedAt); err != nil {
+				s.logger.Debug("Failed to log edit of message %s of session %s: %v", protocol.GetKey().GetID(), session.ID, err)
+			}
+		}
+		return
+	}
+	messageType := loggedMessageType(evt.Message)
+	if messageType == "" {
+		return
+	}
+
+	direction, status := "received", "received"
+	if evt.Info.IsFromMe {
+		direction, status = "sent", "sent"
+	}
+	content := messageText(evt.Message)
+	if reaction := evt.Message.GetReactionMessage(); reaction != nil {
+		content = reaction.GetText()
+	}
+
+	message := &repository.Message{
+		SessionID:    session.ID,
+		MessageID:    evt.Info.ID,
+		SenderJID:    evt.Info.Sender.ToNonAD().String(),
+		RecipientJID: loggedChatJID(evt.Info),
+		MessageType:  messageType,
+		Content:      content,
+		Direction:    direction,
+		Status:       status,
+		CreatedAt:    evt.Info.Timestamp,
+		UpdatedAt:    time.Now(),
+	}
+	if err := s.messageRepo.LogMessage(message); err != nil {
+		// Redelivered messages hit the unique message ID
+		s.logger.Debug("Failed to log message %s of session %s: %v", evt.Info.ID, session.ID, err)
+		return
+	}
+	s.persistIncomingMedia(session, evt)
+}
+
+// loggedChatJID is the chat a message is logged under. One-to-one chats
+// addressed by LID are logged under the phone number JID when it is known, the
+// form sent messages are logged with.
+func loggedChatJID(info types.MessageInfo) string {
+	chat := info.Chat.ToNonAD()
+	if chat.Server == types.HiddenUserServer {
+		alt := info.SenderAlt
+		if info.IsFromMe {
+			alt = info.RecipientAlt
+		}
+		if alt.Server == types.DefaultUserServer {
+			return alt.ToNonAD().String()
+		}
+	}
+	return chat.String()
+}
+
+// loggedMessageType names a message the way webhooks do, or returns "" for
+// messages that are not logged
+func loggedMessageType(msg *waProto.Message) string {
+	switch {
+	case msg.GetConversation() != "", msg.GetExtendedTextMessage() != nil:
+		return "text"
+	case msg.GetImageMessage() != nil:
+		return "image"
+	case msg.GetDocumentMessage() != nil:
+		return "document"
+	case msg.GetAudioMessage() != nil:
+		return "audio"
+	case msg.GetVideoMessage() != nil:
+		return "video"
+	case msg.GetStickerMessage() != nil:
+		return "sticker"
+	case msg.GetLocationMessage() != nil:
+		return "location"
+	case msg.GetContactMessage() != nil:
+		return "contact"
+	case msg.GetPollCreationMessage() != nil, msg.GetPollCreationMessageV3() != nil:
+		return "poll"
+	case msg.GetReactionMessage() != nil:
+		return "reaction"
+	case msg.GetProductMessage() != nil:
+		return "product"
+	case msg.GetOrderMessage() != nil:
+		return "order"
+	}
+	if messageType, reply := interactiveReply(msg); reply != nil {
+		return messageType
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"io"
+	"path"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/storage"
+)
+
+// Received media is downloaded once per message, by whichever of the message
+// log and the webhook needs it first. Sessions that persist media record the
+// file on the logged message, so it can be fetched with GET
+// /api/sessions/{sessionId}/media/{messageId} until the retention sweep
+// deletes it. Other sessions only keep it while the webhook's URL is valid.
+
+// mediaURLTTL is how long the URL of received media sent to webhooks is valid
+const mediaURLTTL = time.Hour
+
+// mediaDownloadShareTime is how long a finished download is kept for the
+// other consumer of the same message
+const mediaDownloadShareTime = time.Minute
+
+// mediaDownload is the download of a received message's media
+type mediaDownload struct {
+	done     chan struct{}
+	fileName string
+	err      error
+}
+
+// hasDownloadableMedia reports whether downloadIncomingMedia can store the media of msg
+func hasDownloadableMedia(msg *waProto.Message) bool {
+	return msg.GetImageMessage() != nil || msg.GetDocumentMessage() != nil ||
+		msg.GetVideoMessage() != nil || msg.GetAudioMessage() != nil
+}
+
+// isViewOnce reports whether a received message is view-once media.
+// whatsmeow unwraps the view-once envelope, leaving the flag on the event or
+// on the media message itself.
+func isViewOnce(evt *events.Message) bool {
+	return evt.IsViewOnce || evt.Message.GetImageMessage().GetViewOnce() ||
+		evt.Message.GetVideoMessage().GetViewOnce() || evt.Message.GetAudioMessage().GetViewOnce()
+}
+
+// incomingMedia returns the file name of a received message's media in
+// storage, downloading it unless another caller already did. Media of a
+// session that does not persist it is deleted once its URL has expired.
+func (s *WhatsAppService) incomingMedia(session *models.Session, evt *events.Message) (string, error) {
+	id := session.ID + "/" + evt.Info.ID
+
+	s.mediaDownloadsMu.Lock()
+	if s.mediaDownloads == nil {
+		s.mediaDownloads = make(map[string]*mediaDownload)
+	}
+	download, started := s.mediaDownloads[id]
+	if !started {
+		download = &mediaDownload{done: make(chan struct{})}
+		s.mediaDownloads[id] = download
+	}
+	s.mediaDownloadsMu.Unlock()
+
+	if started {
+		<-download.done
+		return download.fileName, download.err
+	}
+
+	download.fileName, download.err = s.downloadIncomingMedia(session, evt)
+	close(download.done)
+	time.AfterFunc(mediaDownloadShareTime, func() {
+		s.mediaDownloadsMu.Lock()
+		delete(s.mediaDownloads, id)
+		s.mediaDownloadsMu.Unlock()
+	})
+
+	if download.err == nil && !session.PersistMedia {
+		key := storage.JoinKey(storage.PrefixReceivedMedia, download.fileName)
+		time.AfterFunc(mediaURLTTL, func() {
+			if err := s.storage.Delete(context.Background(), key); err != nil {
+				s.logger.Warn("Failed to delete media file %s: %v", key, err)
+			}
+		})
+	}
+	return download.fileName, download.err
+}
+
+// persistIncomingMedia stores the media of a logged message and records it
+// on the message, when the session persists media
+func (s *WhatsAppService) persistIncomingMedia(session *models.Session, evt *events.Message) {
+	if !session.PersistMedia || !hasDownloadableMedia(evt.Message) {
+		return
+	}
+	if isViewOnce(evt) && !session.AllowViewOnceCapture {
+		return
+	}
+
+	fileName, err := s.incomingMedia(session, evt)
+	if err != nil {
+		s.logger.Warn("Failed to store media of message %s of session %s: %v", evt.Info.ID, session.ID, err)
+		return
+	}
+	key := storage.JoinKey(storage.PrefixReceivedMedia, fileName)
+	if err := s.messageRepo.SetMediaKey(session.ID, evt.Info.ID, key); err != nil {
+		s.logger.Error("%v", err)
+	}
+}
+
+// OpenMessageMedia opens the stored media of a session's message and returns
+// it with its file name. The caller closes the reader.
+func (s *WhatsAppService) OpenMessageMedia(ctx context.Context, sessionID, messageID string) (io.ReadCloser, *storage.Object, string, error) {
+	if s.messageRepo == nil {
+		return nil, nil, "", models.NewServiceUnavailableError("message history is not available")
+	}
+
+	key, found, err := s.messageRepo.GetMediaKey(sessionID, messageID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !found {
+		return nil, nil, "", models.NewNotFoundError("message %s not found", messageID)
+	}
+	if key == "" {
+		return nil, nil, "", models.NewNotFoundError("message %s has no stored media", messageID)
+	}
+
+	reader, object, err := s.storage.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		return nil, nil, "", models.NewNotFoundError("media of message %s is no longer stored", messageID)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return reader, object, path.Base(key), nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"whatsapp-multi-session/pkg/metrics"
+)
+
+// CollectMetrics sets the session gauges; it runs on every metrics scrape
+func (s *WhatsAppService) CollectMetrics() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var connected, loggedIn int
+	for _, session := range s.sessions {
+		state := session.State()
+		if state.Connected {
+			connected++
+		}
+		if state.LoggedIn {
+			loggedIn++
+		}
+	}
+	metrics.Sessions.Set(float64(len(s.sessions)))
+	metrics.SessionsConnected.Set(float64(connected))
+	metrics.SessionsLoggedIn.Set(float64(loggedIn))
+}
+
+// CollectMetrics sets the bulk job gauges from the jobs of this process; it
+// runs on every metrics scrape. Progress is reported for unfinished jobs only,
+// so finished jobs do not pile up series.
+func (s *BulkMessagingService) CollectMetrics() {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+
+	metrics.BulkJobs.Reset()
+	metrics.BulkJobMessages.Reset()
+	counts := make(map[string]int)
+	for _, job := range s.jobs {
+		counts[job.Status]++
+		switch job.Status {
+		case "completed", "cancelled", "failed":
+			continue
+		}
+		metrics.BulkJobMessages.Set(float64(job.Progress.Sent), job.ID, "sent")
+		metrics.BulkJobMessages.Set(float64(job.Progress.Failed), job.ID, "failed")
+		metrics.BulkJobMessages.Set(float64(job.Progress.Remaining), job.ID, "remaining")
+	}
+	for status, count := range counts {
+		metrics.BulkJobs.Set(float64(count), status)
+	}
+}
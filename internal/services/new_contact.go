@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// inboundLeadTag is the tag given to CRM contacts created for new contacts
+const inboundLeadTag = "inbound-lead"
+
+// SetContactSeenRepository enables first-contact detection. contacts is used
+// to create CRM contacts for new numbers on sessions that ask for it.
+func (s *WhatsAppService) SetContactSeenRepository(contactSeen *repository.ContactSeenRepository, contacts *repository.ContactRepository) {
+	s.contactSeen = contactSeen
+	s.contacts = contacts
+}
+
+// ConfigureNewContactDetection enables or disables first-contact detection
+// for a session. When it is first enabled, every number the session already
+// knows (device contacts and message history) is recorded as seen, so only
+// numbers that have never written before are reported. It returns how many
+// numbers were recorded that way.
+func (s *WhatsAppService) ConfigureNewContactDetection(sessionID string, enabled, createContact bool) (int, error) {
+	if s.contactSeen == nil {
+		return 0, models.NewServiceUnavailableError("new contact detection is not available")
+	}
+
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	var since *time.Time
+	if exists {
+		since = session.NewContactSince
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return 0, models.NewNotFoundError("session %s not found", sessionID)
+	}
+
+	backfilled := 0
+	if !enabled {
+		since = nil
+	} else if since == nil {
+		var err error
+		if backfilled, err = s.backfillKnownContacts(session); err != nil {
+			return 0, err
+		}
+		now := time.Now()
+		since = &now
+	}
+
+	if err := s.sessionRepo.UpdateNewContactDetection(sessionID, since, createContact); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	session.NewContactSince = since
+	session.NewContactCreateContact = createContact
+	s.mu.Unlock()
+
+	return backfilled, nil
+}
+
+// backfillKnownContacts records the session's existing contacts as seen
+func (s *WhatsAppService) backfillKnownContacts(session *models.Session) (int, error) {
+	known := make(map[string]bool)
+
+	if session.Client != nil && session.Client.Store.ID != nil {
+		contacts, err := session.Client.Store.Contacts.GetAllContacts(context.Background())
+		if err != nil {
+			s.logger.Warn("Failed to read device contacts of session %s for backfill: %v", session.ID, err)
+		}
+		for jid := range contacts {
+			if key := contactKey(jid); key != "" {
+				known[key] = true
+			}
+		}
+	}
+
+	if s.messageRepo != nil {
+		jids, err := s.messageRepo.GetCorrespondents(session.ID)
+		if err != nil {
+			return 0, err
+		}
+		for _, raw := range jids {
+			if jid, err := types.ParseJID(raw); err == nil {
+				if key := contactKey(jid); key != "" {
+					known[key] = true
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(known))
+	for key := range known {
+		keys = append(keys, key)
+	}
+
+	recorded, err := s.contactSeen.Backfill(session.ID, keys)
+	if err != nil {
+		return recorded, err
+	}
+	s.logger.Info("Recorded %d existing contact(s) as seen for session %s", recorded, session.ID)
+	return recorded, nil
+}
+
+// detectFirstContact records an incoming message and reports whether it is
+// the first one ever received from the sender. Messages sent before detection
+// was enabled, such as those delivered while offline, are never reported.
+func (s *WhatsAppService) detectFirstContact(session *models.Session, evt *events.Message) bool {
+	s.mu.RLock()
+	since := session.NewContactSince
+	s.mu.RUnlock()
+
+	oneToOne := evt.Info.Chat.Server == types.DefaultUserServer || evt.Info.Chat.Server == types.HiddenUserServer
+	if s.contactSeen == nil || since == nil || evt.Info.IsFromMe || !oneToOne {
+		return false
+	}
+
+	key := senderPhone(evt.Info.MessageSource)
+	if key == "" {
+		key = contactKey(evt.Info.Sender)
+	}
+	if key == "" {
+		return false
+	}
+
+	if evt.Info.Timestamp.Before(*since) {
+		if _, err := s.contactSeen.Backfill(session.ID, []string{key}); err != nil {
+			s.logger.Debug("Failed to record contact %s for session %s: %v", key, session.ID, err)
+		}
+		return false
+	}
+
+	first, err := s.contactSeen.RecordInbound(session.ID, key, evt.Info.ID, time.Now())
+	if err != nil {
+		s.logger.Warn("Failed to record contact %s for session %s: %v", key, session.ID, err)
+		return false
+	}
+	return first
+}
+
+// handleNewContact creates the CRM contact if the session asks for it and
+// sends the new_contact webhook event
+func (s *WhatsAppService) handleNewContact(session *models.Session, evt *events.Message) {
+	firstSeenAt := time.Now()
+	fromPhone := senderPhone(evt.Info.MessageSource)
+	s.logger.Info("Session %s received a message from new contact %s", session.ID, evt.Info.Sender.ToNonAD())
+
+	s.mu.RLock()
+	createContact := session.NewContactCreateContact
+	s.mu.RUnlock()
+
+	contactID := 0
+	if createContact && s.contacts != nil && fromPhone != "" {
+		contactID = s.createInboundLead(session, fromPhone, evt.Info.PushName)
+	}
+
+	if !session.Enabled || session.WebhookURL == "" || !session.WebhookEventEnabled("new_contact", false) {
+		return
+	}
+
+	s.deliverWebhook(session, "new_contact", &models.WebhookNewContact{
+		Type:        "new_contact",
+		SessionID:   session.ID,
+		From:        evt.Info.Sender.ToNonAD().String(),
+		FromDevice:  evt.Info.Sender.String(),
+		FromName:    evt.Info.PushName,
+		FromPhone:   fromPhone,
+		MessageID:   evt.Info.ID,
+		FirstSeenAt: models.FormatTimestamp(firstSeenAt),
+		ContactID:   contactID,
+	})
+}
+
+// createInboundLead creates a CRM contact of the session's owner for a new
+// number unless the owner has one, returning its ID or 0 if none was created
+func (s *WhatsAppService) createInboundLead(session *models.Session, e164, pushName string) int {
+	existing, err := s.contacts.GetContactByPhone(e164, session.UserID)
+	if err != nil {
+		s.logger.Warn("Failed to look up contact %s: %v", e164, err)
+		return 0
+	}
+	if existing != nil {
+		return 0
+	}
+
+	name := strings.TrimSpace(pushName)
+	if name == "" {
+		name = e164
+	}
+	contact := &models.Contact{
+		UserID:   session.UserID,
+		Name:     name,
+		Phone:    e164,
+		Tags:     []string{inboundLeadTag},
+		IsActive: true,
+	}
+	if err := s.contacts.CreateContact(contact); err != nil {
+		s.logger.Warn("Failed to create contact for new number %s: %v", e164, err)
+		return 0
+	}
+	// The message that led here is counted by the activity service once it resolves the number
+	s.activity.RememberContact(session.UserID, e164, contact.ID)
+	return contact.ID
+}
+
+// IsFirstContact reports whether the session has received exactly one message
+// from the contact, given as a phone number or JID, and did not know it
+// before. It is always false while detection is disabled for the session.
+func (s *WhatsAppService) IsFirstContact(sessionID, contact string) bool {
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	enabled := exists && session.NewContactSince != nil
+	s.mu.RUnlock()
+	if !enabled || s.contactSeen == nil {
+		return false
+	}
+
+	var key string
+	if strings.Contains(contact, "@") {
+		if jid, err := types.ParseJID(contact); err == nil {
+			key = contactKey(jid)
+		}
+	} else if number, err := phone.Parse(contact, ""); err == nil {
+		key = number.E164
+	}
+	if key == "" {
+		return false
+	}
+
+	first, err := s.contactSeen.IsFirstMessage(sessionID, key)
+	if err != nil {
+		s.logger.Warn("Failed to check contact %s for session %s: %v", key, sessionID, err)
+		return false
+	}
+	return first
+}
+
+// contactKey identifies a contact by E.164 number, or by JID when the JID
+// carries no number (LIDs)
+func contactKey(jid types.JID) string {
+	jid = jid.ToNonAD()
+	if jid.Server == types.GroupServer || jid.Server == types.BroadcastServer || jid.User == "" {
+		return ""
+	}
+	if number := phone.FromJID(jid.String()); number != "" {
+		return number
+	}
+	return jid.String()
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/metrics"
+)
+
+// GetNewsletters lists the channels the session's account follows or runs
+func (s *WhatsAppService) GetNewsletters(sessionID string) ([]*models.Newsletter, error) {
+	session, err := s.newsletterSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed, err := session.Client.GetSubscribedNewsletters(context.Background())
+	if err != nil {
+		return nil, newsletterFailure("failed to get newsletters", err)
+	}
+
+	newsletters := make([]*models.Newsletter, 0, len(subscribed))
+	for _, info := range subscribed {
+		newsletters = append(newsletters, newsletterInfo(info))
+	}
+	return newsletters, nil
+}
+
+// FollowNewsletter follows the channel behind an invite link, invite code or
+// channel JID and returns its description
+func (s *WhatsAppService) FollowNewsletter(sessionID, newsletter string) (*models.Newsletter, error) {
+	session, err := s.newsletterSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+
+	// Looked up first to resolve invite links, and to reject unknown channels with a clear error
+	info, err := s.lookUpNewsletter(session, newsletter)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Client.FollowNewsletter(context.Background(), info.ID); err != nil {
+		return nil, newsletterFailure("failed to follow newsletter", err)
+	}
+
+	s.logger.Info("Session %s followed newsletter %s", sessionID, info.ID)
+	followed := newsletterInfo(info)
+	followed.Role = string(types.NewsletterRoleSubscriber)
+	return followed, nil
+}
+
+// UnfollowNewsletter stops following the channel behind an invite link,
+// invite code or channel JID and returns the channel's JID
+func (s *WhatsAppService) UnfollowNewsletter(sessionID, newsletter string) (string, error) {
+	session, err := s.newsletterSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	jid, err := parseNewsletterJID(newsletter)
+	if err != nil {
+		info, lookupErr := s.lookUpNewsletter(session, newsletter)
+		if lookupErr != nil {
+			return "", lookupErr
+		}
+		jid = info.ID
+	}
+	if err := session.Client.UnfollowNewsletter(context.Background(), jid); err != nil {
+		return "", newsletterFailure("failed to unfollow newsletter", err)
+	}
+
+	s.logger.Info("Session %s unfollowed newsletter %s", sessionID, jid)
+	return jid.String(), nil
+}
+
+// SendNewsletterMessage posts a text or a media file to a channel the
+// session's account owns or administers. Channel media is uploaded
+// unencrypted, so it cannot go through the regular send endpoints.
+func (s *WhatsAppService) SendNewsletterMessage(sessionID, newsletterJID string, req *models.SendNewsletterRequest) (string, error) {
+	if req.Message == "" && req.File == "" {
+		return "", models.NewBadRequestError("message or file is required")
+	}
+	if req.Message != "" && req.File != "" {
+		return "", models.NewBadRequestError("message and file cannot be sent together, use caption for the file's text")
+	}
+	switch req.Type {
+	case "", "image", "video", "audio", "document":
+	default:
+		return "", models.NewBadRequestError("invalid type %q: expected image, video, audio or document", req.Type)
+	}
+
+	session, jid, err := s.administeredNewsletter(sessionID, newsletterJID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
+	if req.Message != "" {
+		return s.sendNewsletterMessage(session, jid, &waProto.Message{
+			Conversation: proto.String(req.Message),
+		}, "")
+	}
+
+	fileData, err := base64.StdEncoding.DecodeString(req.File)
+	if err != nil {
+		return "", models.NewBadRequestError("invalid base64 file data: %v", err)
+	}
+	contentType := http.DetectContentType(fileData)
+	mediaType := s.getMediaType(contentType, req.Type)
+
+	uploaded, err := session.Client.UploadNewsletter(context.Background(), fileData, whatsmeowMediaType(mediaType))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %v", mediaName(mediaType), err)
+	}
+	msg := mediaMessage(uploadedMedia{
+		uploaded:    uploaded,
+		contentType: contentType,
+		filename:    req.FileName,
+		mediaType:   mediaType,
+	}, req.Caption)
+	return s.sendNewsletterMessage(session, jid, msg, uploaded.Handle)
+}
+
+// sendNewsletterMessage sends a message to a channel. Media messages carry
+// the handle WhatsApp returned for their upload.
+func (s *WhatsAppService) sendNewsletterMessage(session *models.Session, jid types.JID, msg *waProto.Message, mediaHandle string) (string, error) {
+	resp, err := session.Client.SendMessage(context.Background(), jid, msg, whatsmeow.SendRequestExtra{MediaHandle: mediaHandle})
+	if err != nil {
+		metrics.MessagesFailed.Inc(session.ID)
+		return "", s.sendFailure(session, "failed to send newsletter message", err)
+	}
+	metrics.MessagesSent.Inc(session.ID)
+
+	s.logger.Info("Session %s posted message %s to newsletter %s", session.ID, resp.ID, jid)
+	return resp.ID, nil
+}
+
+// newsletterSession returns the logged in session. Sandbox sessions have no
+// WhatsApp account to follow channels with.
+func (s *WhatsAppService) newsletterSession(sessionID string) (*models.Session, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Sandbox {
+		return nil, models.NewBadRequestError("newsletters are not available to sandbox sessions")
+	}
+	return session, nil
+}
+
+// administeredNewsletter returns the logged in session and the parsed channel,
+// failing with a ForbiddenError unless the session's account owns or
+// administers it
+func (s *WhatsAppService) administeredNewsletter(sessionID, newsletterJID string) (*models.Session, types.JID, error) {
+	session, err := s.newsletterSession(sessionID)
+	if err != nil {
+		return nil, types.EmptyJID, err
+	}
+	jid, err := parseNewsletterJID(newsletterJID)
+	if err != nil {
+		return nil, types.EmptyJID, err
+	}
+
+	info, err := session.Client.GetNewsletterInfo(context.Background(), jid)
+	if err != nil {
+		return nil, types.EmptyJID, newsletterFailure("failed to get newsletter info", err)
+	}
+	if info == nil {
+		return nil, types.EmptyJID, models.NewNotFoundError("newsletter %s not found", jid)
+	}
+	if info.ViewerMeta == nil ||
+		(info.ViewerMeta.Role != types.NewsletterRoleOwner && info.ViewerMeta.Role != types.NewsletterRoleAdmin) {
+		return nil, types.EmptyJID, models.NewForbiddenError("the session is not an owner or admin of newsletter %s", jid)
+	}
+	return session, jid, nil
+}
+
+// lookUpNewsletter fetches a channel by its JID, invite link or invite code
+func (s *WhatsAppService) lookUpNewsletter(session *models.Session, newsletter string) (*types.NewsletterMetadata, error) {
+	var (
+		info *types.NewsletterMetadata
+		err  error
+	)
+	if jid, jidErr := parseNewsletterJID(newsletter); jidErr == nil {
+		info, err = session.Client.GetNewsletterInfo(context.Background(), jid)
+	} else {
+		code, codeErr := parseNewsletterInviteCode(newsletter)
+		if codeErr != nil {
+			return nil, codeErr
+		}
+		info, err = session.Client.GetNewsletterInfoWithInvite(context.Background(), code)
+	}
+	if err != nil {
+		return nil, newsletterFailure("failed to look up newsletter", err)
+	}
+	if info == nil {
+		return nil, models.NewNotFoundError("newsletter %s not found", newsletter)
+	}
+	return info, nil
+}
+
+// parseNewsletterJID parses a channel JID (…@newsletter)
+func parseNewsletterJID(newsletterJID string) (types.JID, error) {
+	newsletterJID = strings.TrimSpace(newsletterJID)
+	if newsletterJID == "" {
+		return types.EmptyJID, models.NewBadRequestError("newsletter is required")
+	}
+	if !strings.HasSuffix(newsletterJID, "@"+types.NewsletterServer) {
+		return types.EmptyJID, models.NewBadRequestError("%s is not a newsletter JID", newsletterJID)
+	}
+	jid, err := parseRecipientJID(newsletterJID)
+	if err != nil {
+		return types.EmptyJID, models.NewBadRequestError("invalid newsletter: %v", err)
+	}
+	return jid, nil
+}
+
+// parseNewsletterInviteCode extracts the invite code from a channel invite
+// link or code
+func parseNewsletterInviteCode(link string) (string, error) {
+	code := strings.TrimSpace(link)
+	if i := strings.Index(code, "whatsapp.com/channel/"); i >= 0 {
+		code = code[i+len("whatsapp.com/channel/"):]
+		if end := strings.IndexAny(code, "/?#"); end >= 0 {
+			code = code[:end]
+		}
+	}
+	if code == "" {
+		return "", models.NewBadRequestError("newsletter is required")
+	}
+	for _, r := range code {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "", models.NewBadRequestError("%q is not a newsletter invite link, invite code or JID", link)
+		}
+	}
+	return code, nil
+}
+
+// newsletterInfo converts whatsmeow's channel metadata
+func newsletterInfo(info *types.NewsletterMetadata) *models.Newsletter {
+	meta := info.ThreadMeta
+	newsletter := &models.Newsletter{
+		JID:         info.ID.String(),
+		Name:        meta.Name.Text,
+		Description: meta.Description.Text,
+		InviteCode:  meta.InviteCode,
+		Subscribers: meta.SubscriberCount,
+		Verified:    meta.VerificationState == types.NewsletterVerificationStateVerified,
+		State:       string(info.State.Type),
+		Created:     models.FormatTimestamp(meta.CreationTime.Time),
+	}
+	if meta.InviteCode != "" {
+		newsletter.InviteLink = whatsmeow.NewsletterLinkPrefix + meta.InviteCode
+	}
+	if info.ViewerMeta != nil {
+		newsletter.Role = string(info.ViewerMeta.Role)
+		newsletter.Muted = info.ViewerMeta.Mute == types.NewsletterMuteOn
+	}
+	return newsletter
+}
+
+// newsletterFailure explains channel requests WhatsApp does not accept
+func newsletterFailure(action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return models.NewUnauthorizedError("session is not authenticated")
+	case errors.Is(err, whatsmeow.ErrIQNotFound):
+		return models.NewNotFoundError("%s: newsletter not found", action)
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAuthorized):
+		return models.NewForbiddenError("%s: %v", action, err)
+	case errors.Is(err, whatsmeow.ErrIQBadRequest), errors.Is(err, whatsmeow.ErrIQNotAcceptable):
+		return models.NewBadRequestError("%s: %v", action, err)
+	}
+	return models.NewServiceUnavailableError("%s: %v", action, err)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// Numbers are looked up on WhatsApp in batches of numberCheckBatchSize, with
+// a pause between batches so large lists do not hammer WhatsApp's servers
+const (
+	numberCheckBatchSize  = 50
+	numberCheckBatchDelay = 500 * time.Millisecond
+)
+
+// CheckNumbers checks which of the numbers are on WhatsApp. Numbers are read
+// in the region given unless they carry a country code; an invalid number
+// is reported in its result instead of failing the whole check.
+func (s *WhatsAppService) CheckNumbers(sessionID string, numbers []string, region string) ([]models.NumberCheckResult, error) {
+	if len(numbers) == 0 {
+		return nil, models.NewBadRequestError("numbers is required")
+	}
+	if len(numbers) > models.MaxNumberChecks {
+		return nil, models.NewBadRequestError("at most %d numbers can be checked at once", models.MaxNumberChecks)
+	}
+
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.NumberCheckResult, len(numbers))
+	phones := make([]string, 0, len(numbers))
+	for i, raw := range numbers {
+		results[i].Number = raw
+		number, err := phone.Parse(raw, region)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Phone = number.E164
+		phones = append(phones, number.E164)
+	}
+
+	registered, err := s.registeredNumbers(session, phones)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if jid, ok := registered[results[i].Phone]; ok {
+			results[i].Exists = true
+			results[i].JID = jid.String()
+		}
+	}
+	return results, nil
+}
+
+// registeredNumbers looks up E.164 numbers on WhatsApp and returns the
+// canonical JID of each registered one
+func (s *WhatsAppService) registeredNumbers(session *models.Session, phones []string) (map[string]types.JID, error) {
+	unique := make([]string, 0, len(phones))
+	seen := make(map[string]bool, len(phones))
+	for _, number := range phones {
+		if !seen[number] {
+			seen[number] = true
+			unique = append(unique, number)
+		}
+	}
+
+	registered := make(map[string]types.JID)
+	for start := 0; start < len(unique); start += numberCheckBatchSize {
+		if start > 0 {
+			select {
+			case <-s.ctx.Done():
+				return nil, models.NewServiceUnavailableError("server is shutting down")
+			case <-time.After(numberCheckBatchDelay):
+			}
+		}
+		end := start + numberCheckBatchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		resp, err := session.Client.IsOnWhatsApp(context.Background(), unique[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to check numbers: %v", err)
+		}
+		for _, info := range resp {
+			if !info.IsIn {
+				continue
+			}
+			// WhatsApp echoes the query, normally with its leading +
+			query := info.Query
+			if !strings.HasPrefix(query, "+") {
+				query = "+" + query
+			}
+			registered[query] = info.JID
+		}
+	}
+	return registered, nil
+}
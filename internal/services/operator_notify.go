@@ -0,0 +1,56 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SetOperatorNotifyURL sets the URL that receives JSON notices about events
+// needing operator attention, such as suspended webhooks and banned sessions
+func (s *WhatsAppService) SetOperatorNotifyURL(notifyURL string) {
+	s.notifyURL = notifyURL
+}
+
+// notifyOperator posts an event about a session to OPERATOR_NOTIFY_URL
+func (s *WhatsAppService) notifyOperator(event string, session *models.Session, fields map[string]interface{}) {
+	if s.notifyURL == "" {
+		return
+	}
+
+	notice := map[string]interface{}{
+		"event":        event,
+		"session_id":   session.ID,
+		"session_name": session.Name,
+	}
+	for key, value := range fields {
+		notice[key] = value
+	}
+
+	data, err := json.Marshal(notice)
+	if err != nil {
+		s.logger.Error("Failed to encode operator notification: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", s.notifyURL, bytes.NewReader(data))
+	if err != nil {
+		s.logger.Error("Failed to create operator notification: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WhatsApp-Multi-Session/1.0")
+
+	resp, err := s.httpClients.Default().Do(req)
+	if err != nil {
+		s.logger.Error("Failed to send %s operator notification for session %s: %v", event, session.ID, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.logger.Error("Operator notification %s for session %s returned status %d", event, session.ID, resp.StatusCode)
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/httpclient"
+)
+
+// maxOutboundRedirects bounds the redirects followed by webhook deliveries
+// and URL downloads
+const maxOutboundRedirects = 5
+
+// blockedNetworks are internal ranges the net.IP predicates used by
+// isPrivateIP do not cover
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network, reaches the host itself on Linux
+	"100.64.0.0/10", // carrier-grade NAT, also used for cloud metadata (100.100.100.200)
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"64:ff9b::/96",  // NAT64, embeds arbitrary IPv4 addresses
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// outboundGuard keeps the requests the server makes on behalf of users,
+// webhook deliveries and URL downloads, from reaching the server itself, its
+// internal network or cloud metadata services. The target host is resolved
+// before each request and every redirect to refuse it early, and the guarded
+// transport checks the address actually dialled, so a host answering
+// differently the second time gets nowhere. Proxies chosen by users are
+// checked the same way; only the operator's OUTBOUND_HTTP_PROXY may sit on
+// the internal network. With allowPrivate set all targets are allowed.
+type outboundGuard struct {
+	allowPrivate bool
+
+	// lookup resolves host names, net.DefaultResolver when nil
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	// transports holds the guarded copy of each base transport, keyed by
+	// guardedTransportKey, so connections are still pooled
+	transports sync.Map
+}
+
+// guardedTransportKey identifies a guarded transport: the base it copies and
+// whether the base's proxies are trusted
+type guardedTransportKey struct {
+	base       *http.Transport
+	trustProxy bool
+}
+
+// SetAllowPrivateWebhooks lets webhooks and URL downloads target loopback,
+// link-local and private addresses, for deployments posting to services on
+// their own network
+func (s *WhatsAppService) SetAllowPrivateWebhooks(allow bool) {
+	s.guard.allowPrivate = allow
+}
+
+// checkURL verifies that target is an absolute http(s) URL whose host only
+// resolves to public addresses
+func (g *outboundGuard) checkURL(ctx context.Context, target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q, use http or https", target.Scheme)
+	}
+	host := target.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL must include a host")
+	}
+	return g.checkHost(ctx, host)
+}
+
+// checkProxyURL verifies that a proxy chosen by a user is a valid proxy URL
+// whose host only resolves to public addresses
+func (g *outboundGuard) checkProxyURL(ctx context.Context, proxyURL string) error {
+	if err := httpclient.ValidateProxyURL(proxyURL); err != nil {
+		return err
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	return g.checkHost(ctx, parsed.Hostname())
+}
+
+// checkHost verifies that host only resolves to public addresses
+func (g *outboundGuard) checkHost(ctx context.Context, host string) error {
+	if g.allowPrivate {
+		return nil
+	}
+
+	var addrs []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		addrs = []net.IPAddr{{IP: ip}}
+	} else {
+		lookup := g.lookup
+		if lookup == nil {
+			lookup = net.DefaultResolver.LookupIPAddr
+		}
+		var err error
+		addrs, err = lookup(ctx, host)
+		if err != nil {
+			return fmt.Errorf("cannot resolve host %s: %v", host, err)
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("host %s has no addresses", host)
+		}
+	}
+
+	// A host with any internal address is refused, as the dialer may pick it
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return fmt.Errorf("host %s resolves to private address %s", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// checkRedirect is an http.Client CheckRedirect applying checkURL to every
+// redirect target
+func (g *outboundGuard) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxOutboundRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxOutboundRedirects)
+	}
+	if err := g.checkURL(req.Context(), req.URL); err != nil {
+		return fmt.Errorf("redirect to %s refused: %v", req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// client returns a copy of base that checks redirects with the guard and
+// dials through the guarded transport. The proxies of base, the operator's
+// OUTBOUND_HTTP_PROXY, are trusted.
+func (g *outboundGuard) client(base *http.Client) *http.Client {
+	return g.guardedClient(base, true)
+}
+
+// userProxyClient is client for a base sending through a proxy a user chose,
+// such as a session's webhook proxy. Connections to the proxy are guarded
+// like any other.
+func (g *outboundGuard) userProxyClient(base *http.Client) *http.Client {
+	return g.guardedClient(base, false)
+}
+
+func (g *outboundGuard) guardedClient(base *http.Client, trustProxy bool) *http.Client {
+	client := *base
+	client.CheckRedirect = g.checkRedirect
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if t, ok := transport.(*http.Transport); ok {
+		client.Transport = g.transport(t, trustProxy)
+	}
+	return &client
+}
+
+// transport returns a copy of base whose connections are refused when the
+// dialled address is private. With trustProxy, connections to base's
+// proxies are left alone; the proxy resolves the target itself.
+func (g *outboundGuard) transport(base *http.Transport, trustProxy bool) *http.Transport {
+	key := guardedTransportKey{base: base, trustProxy: trustProxy}
+	if cached, ok := g.transports.Load(key); ok {
+		return cached.(*http.Transport)
+	}
+
+	var proxies sync.Map // addresses of the trusted proxies requests went through
+	transport := base.Clone()
+	if proxy := base.Proxy; proxy != nil && trustProxy {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			proxyURL, err := proxy(req)
+			if proxyURL != nil {
+				proxies.Store(proxyAddr(proxyURL), true)
+			}
+			return proxyURL, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	dialProxy := base.DialContext
+	if dialProxy == nil {
+		dialProxy = dialer.DialContext
+	}
+	guarded := &net.Dialer{Timeout: dialer.Timeout, KeepAlive: dialer.KeepAlive, Control: g.controlDial}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, ok := proxies.Load(addr); ok {
+			return dialProxy(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+
+	actual, _ := g.transports.LoadOrStore(key, transport)
+	return actual.(*http.Transport)
+}
+
+// controlDial is a net.Dialer Control hook refusing connections to private
+// addresses; address is the resolved IP the dialer is about to connect to
+func (g *outboundGuard) controlDial(network, address string, _ syscall.RawConn) error {
+	if g.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("cannot check address %s", address)
+	}
+	if isPrivateIP(ip) {
+		return fmt.Errorf("connection to private address %s refused", ip)
+	}
+	return nil
+}
+
+// proxyAddr returns the host:port the transport dials for a proxy URL
+func proxyAddr(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// validateWebhookURL checks a webhook URL before it is saved; an empty URL
+// disables webhooks and is always valid
+func (s *WhatsAppService) validateWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return models.NewBadRequestError("invalid webhook URL: %v", err)
+	}
+	if err := s.guard.checkURL(context.Background(), parsed); err != nil {
+		return models.NewBadRequestError("webhook URL not allowed: %v", err)
+	}
+	return nil
+}
+
+// validateWebhookProxyURL checks a session's webhook proxy before it is
+// saved; an empty URL sends webhooks through the shared outbound client
+func (s *WhatsAppService) validateWebhookProxyURL(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	if err := s.guard.checkProxyURL(context.Background(), proxyURL); err != nil {
+		return models.NewBadRequestError("webhook proxy not allowed: %v", err)
+	}
+	return nil
+}
+
+// isPrivateIP reports whether ip belongs to the server's own or an internal
+// network rather than the public internet. Link-local covers the
+// 169.254.169.254 metadata address, and private IPv6 covers fd00:ec2::254.
+func isPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
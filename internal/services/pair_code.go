@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// pairCodeLifetime bounds how long a pairing code can be used. WhatsApp does
+// not document the exact expiry, but it closes the login connection once the
+// QR codes run out, about 160 seconds after connecting.
+const pairCodeLifetime = 160 * time.Second
+
+// pairClientDisplayName is shown on the phone while linking. WhatsApp only
+// accepts common "Browser (OS)" combinations.
+const pairClientDisplayName = "Chrome (Linux)"
+
+// pairCodeHub fans pairing codes out to the WebSocket connections of a session
+type pairCodeHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *models.PairCodeResponse]struct{}
+}
+
+func (h *pairCodeHub) subscribe(sessionID string) (<-chan *models.PairCodeResponse, func()) {
+	ch := make(chan *models.PairCodeResponse, 1)
+
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[string]map[chan *models.PairCodeResponse]struct{})
+	}
+	if h.subscribers[sessionID] == nil {
+		h.subscribers[sessionID] = make(map[chan *models.PairCodeResponse]struct{})
+	}
+	h.subscribers[sessionID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[sessionID], ch)
+		if len(h.subscribers[sessionID]) == 0 {
+			delete(h.subscribers, sessionID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *pairCodeHub) publish(sessionID string, code *models.PairCodeResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[sessionID] {
+		// A newer code replaces one the subscriber has not picked up yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- code
+	}
+}
+
+// SubscribePairCodes returns a channel receiving every pairing code generated
+// for the session, and a function to stop receiving them
+func (s *WhatsAppService) SubscribePairCodes(sessionID string) (<-chan *models.PairCodeResponse, func()) {
+	return s.pairCodes.subscribe(sessionID)
+}
+
+// PairPhone requests a pairing code that links the session to the WhatsApp
+// account of phoneNumber without scanning a QR code. The session must be
+// connected for login (see LoginSession) but not logged in.
+func (s *WhatsAppService) PairPhone(sessionID, phoneNumber string) (*models.PairCodeResponse, error) {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+	if !session.Enabled {
+		return nil, models.NewBadRequestError("session %s is disabled and cannot be logged in", sessionID)
+	}
+	if session.Sandbox {
+		return nil, models.NewBadRequestError("session %s is a sandbox session and logs in when connected, without pairing", sessionID)
+	}
+	if session.IsLoggedIn() || session.Client.IsLoggedIn() {
+		return nil, models.NewBadRequestError("session %s is already logged in", sessionID)
+	}
+	if !session.Client.IsConnected() {
+		return nil, models.NewBadRequestError("session %s is not connected, start the login before requesting a pairing code", sessionID)
+	}
+
+	number, err := phone.Parse(phoneNumber, phone.DefaultRegion())
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid phone number %q: %v", phoneNumber, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	code, err := session.Client.PairPhone(ctx, strings.TrimPrefix(number.E164, "+"), true, whatsmeow.PairClientChrome, pairClientDisplayName)
+	if err != nil {
+		switch {
+		case errors.Is(err, whatsmeow.ErrPhoneNumberTooShort), errors.Is(err, whatsmeow.ErrPhoneNumberIsNotInternational):
+			return nil, models.NewBadRequestError("invalid phone number %q: %v", phoneNumber, err)
+		case errors.Is(err, whatsmeow.ErrNotConnected):
+			return nil, models.NewBadRequestError("session %s is not connected, start the login before requesting a pairing code", sessionID)
+		case errors.Is(err, whatsmeow.ErrIQBadRequest):
+			return nil, models.NewBadRequestError("WhatsApp rejected the pairing request for %s: %v", number.E164, err)
+		}
+		return nil, models.NewServiceUnavailableError("failed to request pairing code: %v", err)
+	}
+
+	resp := &models.PairCodeResponse{
+		Code:      code,
+		Phone:     number.E164,
+		ExpiresAt: models.FormatTimestamp(time.Now().Add(pairCodeLifetime)),
+	}
+	s.pairCodes.publish(sessionID, resp)
+
+	s.logger.Info("Pairing code requested for session %s (%s)", sessionID, number.E164)
+	return resp, nil
+}
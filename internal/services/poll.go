@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// SendPoll sends a poll and returns its message ID. The question and options
+// of req are trimmed to what was sent.
+func (s *WhatsAppService) SendPoll(sessionID string, req *models.SendPollRequest) (string, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
+	question, options, err := validatePoll(req)
+	if err != nil {
+		return "", err
+	}
+	// Callers log the poll as sent, which must match the hashed option names
+	req.Question, req.Options = question, options
+
+	jid, err := parseRecipientJID(req.To)
+	if err != nil {
+		return "", models.NewBadRequestError("%v", err)
+	}
+
+	msg := session.Client.BuildPollCreation(question, options, req.SelectableCount)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to send poll", err)
+	}
+
+	s.logger.Info("Poll sent to %s from session %s", jid, sessionID)
+	return resp.ID, nil
+}
+
+// validatePoll checks a poll request and returns the trimmed question and options
+func validatePoll(req *models.SendPollRequest) (string, []string, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return "", nil, models.NewBadRequestError("question is required")
+	}
+
+	if len(req.Options) < models.PollMinOptions || len(req.Options) > models.PollMaxOptions {
+		return "", nil, models.NewBadRequestError("a poll needs %d to %d options, got %d", models.PollMinOptions, models.PollMaxOptions, len(req.Options))
+	}
+	options := make([]string, len(req.Options))
+	seen := make(map[string]bool, len(req.Options))
+	for i, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return "", nil, models.NewBadRequestError("option %d is empty", i+1)
+		}
+		// Votes identify options by a hash of their name, so names must be unique
+		if seen[option] {
+			return "", nil, models.NewBadRequestError("duplicate option %q", option)
+		}
+		seen[option] = true
+		options[i] = option
+	}
+
+	if req.SelectableCount < 0 || req.SelectableCount > len(options) {
+		return "", nil, models.NewBadRequestError("selectable_count must be between 0 and the number of options (%d)", len(options))
+	}
+	return question, options, nil
+}
+
+// decodePollVote decrypts a poll vote and names the selected options when the
+// poll was sent through this API and logged
+func (s *WhatsAppService) decodePollVote(session *models.Session, evt *events.Message) *models.WebhookPollVote {
+	pollID := evt.Message.GetPollUpdateMessage().GetPollCreationMessageKey().GetID()
+	vote := &models.WebhookPollVote{
+		PollID:               pollID,
+		SelectedOptions:      []string{},
+		SelectedOptionHashes: []string{},
+	}
+
+	decrypted, err := session.Client.DecryptPollVote(context.Background(), evt)
+	if err != nil {
+		s.logger.Warn("Failed to decrypt vote on poll %s in session %s: %v", pollID, session.ID, err)
+		return vote
+	}
+
+	names := s.pollOptionsByHash(session.ID, pollID)
+	for _, hash := range decrypted.GetSelectedOptions() {
+		key := hex.EncodeToString(hash)
+		vote.SelectedOptionHashes = append(vote.SelectedOptionHashes, key)
+		if name, ok := names[key]; ok {
+			vote.SelectedOptions = append(vote.SelectedOptions, name)
+		}
+	}
+	return vote
+}
+
+// pollOptionsByHash maps the option hashes of a logged poll to option names
+func (s *WhatsAppService) pollOptionsByHash(sessionID, pollID string) map[string]string {
+	names := make(map[string]string)
+	if s.messageRepo == nil || pollID == "" {
+		return names
+	}
+
+	msg, err := s.messageRepo.GetMessageByID(sessionID, pollID)
+	if err != nil {
+		s.logger.Debug("Failed to look up poll %s: %v", pollID, err)
+		return names
+	}
+	if msg == nil || msg.MessageType != "poll" {
+		return names
+	}
+
+	var poll models.PollContent
+	if err := json.Unmarshal([]byte(msg.Content), &poll); err != nil {
+		return names
+	}
+	for _, option := range poll.Options {
+		hash := sha256.Sum256([]byte(option))
+		names[hex.EncodeToString(hash[:])] = option
+	}
+	return names
+}
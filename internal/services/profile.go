@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// GetProfile returns the push name, about text and profile picture of the
+// session's own account. An account without a picture, or one WhatsApp does
+// not reveal, has no picture URL.
+func (s *WhatsAppService) GetProfile(sessionID string) (*models.Profile, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	own := session.Client.Store.ID
+	if own == nil {
+		return nil, models.NewUnauthorizedError("session is not authenticated")
+	}
+	jid := own.ToNonAD()
+
+	profile := &models.Profile{
+		JID:      jid.String(),
+		PushName: session.Client.Store.PushName,
+	}
+
+	info, err := session.Client.GetUserInfo(context.Background(), []types.JID{jid})
+	if err != nil {
+		return nil, profileFailure("failed to get profile", err)
+	}
+	profile.About = info[jid].Status
+
+	picture, err := session.Client.GetProfilePictureInfo(context.Background(), jid, nil)
+	switch {
+	case errors.Is(err, whatsmeow.ErrProfilePictureNotSet), errors.Is(err, whatsmeow.ErrProfilePictureUnauthorized):
+	case err != nil:
+		return nil, profileFailure("failed to get profile picture", err)
+	case picture != nil:
+		profile.PictureID = picture.ID
+		profile.PictureURL = picture.URL
+	}
+	return profile, nil
+}
+
+// SetProfileName changes the push name of the session's account, which
+// contacts without the number saved see. The name is kept in the device store,
+// so presence updates use it instead of a generated one.
+func (s *WhatsAppService) SetProfileName(sessionID, name string) error {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.NewBadRequestError("name is required")
+	}
+	if length := utf8.RuneCountInString(name); length > models.ProfileNameMaxLength {
+		return models.NewBadRequestError("name is %d characters, WhatsApp allows at most %d", length, models.ProfileNameMaxLength)
+	}
+
+	if err := session.Client.SendAppState(context.Background(), appstate.BuildSettingPushName(name)); err != nil {
+		return profileFailure("failed to set profile name", err)
+	}
+	session.Client.Store.PushName = name
+	if err := session.Client.Store.Save(context.Background()); err != nil {
+		s.logger.Warn("Failed to save push name of session %s: %v", sessionID, err)
+	}
+
+	s.logger.Info("Session %s changed its profile name", sessionID)
+	return nil
+}
+
+// SetProfileAbout changes the about text of the session's account
+func (s *WhatsAppService) SetProfileAbout(sessionID, about string) error {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	about = strings.TrimSpace(about)
+	if length := utf8.RuneCountInString(about); length > models.ProfileAboutMaxLength {
+		return models.NewBadRequestError("about is %d characters, WhatsApp allows at most %d", length, models.ProfileAboutMaxLength)
+	}
+
+	if err := session.Client.SetStatusMessage(context.Background(), about); err != nil {
+		return profileFailure("failed to set profile about", err)
+	}
+
+	s.logger.Info("Session %s changed its profile about text", sessionID)
+	return nil
+}
+
+// SetProfilePicture changes the profile picture of the session's account and
+// returns the new picture's ID
+func (s *WhatsAppService) SetProfilePicture(sessionID string, jpeg []byte) (string, error) {
+	if len(jpeg) == 0 {
+		return "", models.NewBadRequestError("image is required")
+	}
+	if len(jpeg) < 3 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 || jpeg[2] != 0xFF {
+		return "", models.NewBadRequestError("image must be a JPEG")
+	}
+
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	// Without a target the picture is the account's own
+	pictureID, err := session.Client.SetGroupPhoto(context.Background(), types.EmptyJID, jpeg)
+	if err != nil {
+		return "", profileFailure("failed to set profile picture", err)
+	}
+
+	s.logger.Info("Session %s changed its profile picture", sessionID)
+	return pictureID, nil
+}
+
+// profileFailure maps a failed profile request to a typed error
+func profileFailure(action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return models.NewUnauthorizedError("session is not authenticated")
+	case errors.Is(err, whatsmeow.ErrInvalidImageFormat):
+		return models.NewBadRequestError("%s: WhatsApp rejected the image, use a square JPEG", action)
+	case errors.Is(err, whatsmeow.ErrIQBadRequest), errors.Is(err, whatsmeow.ErrIQNotAcceptable):
+		return models.NewBadRequestError("%s: %v", action, err)
+	}
+	return models.NewServiceUnavailableError("%s: %v", action, err)
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// whatsmeow hands out one QR channel per connection, so a session's QR login
+// is owned by the service: one goroutine reads the channel, keeps the latest
+// code and fans every step out to the session's WebSocket connections. GET
+// /qr, POST /login and any number of browser tabs share the same login instead
+// of each opening a channel and invalidating the others.
+
+// qrNotReadyRetry is how soon a client asking for a code that is not there
+// yet should ask again
+const qrNotReadyRetry = 2 * time.Second
+
+// qrSubscriberBuffer is how many QR events a subscriber may fall behind
+// before the oldest are dropped
+const qrSubscriberBuffer = 8
+
+// qrLogin is the QR login in progress of a session
+type qrLogin struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	latest *models.QREvent // The code being shown, nil until the first arrives
+}
+
+// qrHub holds the QR logins in progress and the subscribers to their events
+type qrHub struct {
+	mu          sync.Mutex
+	logins      map[string]*qrLogin
+	subscribers map[string]map[chan *models.QREvent]struct{}
+}
+
+// SubscribeQR returns a channel receiving the QR events of the session's
+// logins, starting with the code being shown if there is one, and a function
+// to stop receiving them
+func (s *WhatsAppService) SubscribeQR(sessionID string) (<-chan *models.QREvent, func()) {
+	h := &s.qrLogins
+	ch := make(chan *models.QREvent, qrSubscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[string]map[chan *models.QREvent]struct{})
+	}
+	if h.subscribers[sessionID] == nil {
+		h.subscribers[sessionID] = make(map[chan *models.QREvent]struct{})
+	}
+	h.subscribers[sessionID][ch] = struct{}{}
+	if login := h.logins[sessionID]; login != nil && login.latest != nil {
+		ch <- login.latest
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[sessionID], ch)
+			if len(h.subscribers[sessionID]) == 0 {
+				delete(h.subscribers, sessionID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// HasQRSubscribers reports whether any WebSocket connection follows the
+// session's QR login
+func (s *WhatsAppService) HasQRSubscribers(sessionID string) bool {
+	s.qrLogins.mu.Lock()
+	defer s.qrLogins.mu.Unlock()
+	return len(s.qrLogins.subscribers[sessionID]) > 0
+}
+
+// StartQRLogin starts a QR login for the session unless one is in progress.
+// The codes are read with GetQRCode or SubscribeQR.
+func (s *WhatsAppService) StartQRLogin(sessionID string) error {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+	if !session.Enabled {
+		return models.NewBadRequestError("session %s is disabled and cannot be logged in", sessionID)
+	}
+	if session.Sandbox {
+		return models.NewBadRequestError("session %s is a sandbox session and logs in when connected, without a QR code", sessionID)
+	}
+	if session.IsLoggedIn() || session.Client.Store.ID != nil {
+		return models.NewBadRequestError("session %s is already logged in", sessionID)
+	}
+
+	h := &s.qrLogins
+	h.mu.Lock()
+	if h.logins[sessionID] != nil {
+		h.mu.Unlock()
+		return nil
+	}
+
+	// The QR channel must be opened before connecting, so a connection left
+	// over from an earlier login is dropped first
+	if session.Client.IsConnected() {
+		s.logger.Info("Disconnecting stale connection of session %s before QR login", sessionID)
+		session.Client.Disconnect()
+		session.SetDisconnected()
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	qrChan, err := session.Client.GetQRChannel(ctx)
+	if err != nil {
+		h.mu.Unlock()
+		cancel()
+		return models.NewBadRequestError("failed to start QR login for session %s: %v", sessionID, err)
+	}
+	login := &qrLogin{ctx: ctx, cancel: cancel}
+	if h.logins == nil {
+		h.logins = make(map[string]*qrLogin)
+	}
+	h.logins[sessionID] = login
+	h.mu.Unlock()
+
+	go s.runQRLogin(session, login, qrChan)
+
+	if err := s.ConnectSession(sessionID); err != nil {
+		s.stopQRLogin(sessionID)
+		return err
+	}
+
+	s.logger.Info("QR login started for session %s", sessionID)
+	return nil
+}
+
+// runQRLogin reads the QR channel of a login until it closes or the login is
+// stopped, keeping the latest code and publishing every step
+func (s *WhatsAppService) runQRLogin(session *models.Session, login *qrLogin, qrChan <-chan whatsmeow.QRChannelItem) {
+	defer func() {
+		s.qrLogins.mu.Lock()
+		if s.qrLogins.logins[session.ID] == login {
+			delete(s.qrLogins.logins, session.ID)
+		}
+		s.qrLogins.mu.Unlock()
+		login.cancel()
+	}()
+
+	for {
+		var item whatsmeow.QRChannelItem
+		select {
+		case <-login.ctx.Done():
+			// Stopped before WhatsApp sent codes, so the channel never closes
+			return
+		case next, ok := <-qrChan:
+			if !ok {
+				return
+			}
+			item = next
+		}
+
+		var event *models.QREvent
+		switch item.Event {
+		case whatsmeow.QRChannelEventCode:
+			event = &models.QREvent{
+				Event:     models.QREventCode,
+				Code:      item.Code,
+				Timeout:   item.Timeout,
+				ExpiresAt: time.Now().Add(item.Timeout),
+			}
+		case whatsmeow.QRChannelSuccess.Event:
+			s.logger.Info("QR code scanned for session %s", session.ID)
+			event = &models.QREvent{Event: models.QREventSuccess}
+		case whatsmeow.QRChannelTimeout.Event:
+			s.logger.Info("QR codes of session %s ran out without being scanned", session.ID)
+			event = &models.QREvent{Event: models.QREventTimeout}
+			s.publishSessionStatus(session, models.SessionStatusQRTimeout, "")
+		case whatsmeow.QRChannelEventError:
+			s.logger.Error("QR login of session %s failed: %v", session.ID, item.Error)
+			event = &models.QREvent{Event: models.QREventError, Error: item.Error.Error()}
+		default:
+			// err-client-outdated and the like
+			s.logger.Error("QR login of session %s failed: %s", session.ID, item.Event)
+			event = &models.QREvent{Event: models.QREventError, Error: item.Event}
+		}
+		s.publishQREvent(session.ID, login, event)
+	}
+}
+
+// publishQREvent records a step of a login and sends it to the subscribers
+func (s *WhatsAppService) publishQREvent(sessionID string, login *qrLogin, event *models.QREvent) {
+	h := &s.qrLogins
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch event.Event {
+	case models.QREventCode:
+		login.latest = event
+	case models.QREventSuccess, models.QREventTimeout:
+		login.latest = nil
+	}
+	for ch := range h.subscribers[sessionID] {
+		// A subscriber that stopped reading loses its oldest events
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+// stopQRLogin ends the QR login of a session, if one is in progress
+func (s *WhatsAppService) stopQRLogin(sessionID string) {
+	s.qrLogins.mu.Lock()
+	login := s.qrLogins.logins[sessionID]
+	delete(s.qrLogins.logins, sessionID)
+	s.qrLogins.mu.Unlock()
+
+	if login != nil {
+		login.cancel()
+	}
+}
+
+// GetQRCode returns the QR code being shown for the session's login, starting
+// a login if none is in progress. Until WhatsApp has sent the first code it
+// returns a NotReadyError.
+func (s *WhatsAppService) GetQRCode(sessionID string) (*models.QRResponse, error) {
+	if err := s.StartQRLogin(sessionID); err != nil {
+		return nil, err
+	}
+
+	s.qrLogins.mu.Lock()
+	var latest *models.QREvent
+	if login := s.qrLogins.logins[sessionID]; login != nil {
+		latest = login.latest
+	}
+	s.qrLogins.mu.Unlock()
+
+	if latest == nil {
+		return nil, models.NewNotReadyError(qrNotReadyRetry, "QR code of session %s is not ready yet", sessionID)
+	}
+	return &models.QRResponse{
+		QRCode:    latest.Code,
+		ExpiresAt: models.FormatTimestamp(latest.ExpiresAt),
+	}, nil
+}
+
+// ActiveQRCode returns the QR code being shown for the session's login
+// without starting one. A session that is logged in or shows no code gives a
+// NotFoundError.
+func (s *WhatsAppService) ActiveQRCode(sessionID string) (*models.QRResponse, error) {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+	if session.IsLoggedIn() {
+		return nil, models.NewNotFoundError("session %s is already logged in", sessionID)
+	}
+
+	s.qrLogins.mu.Lock()
+	var latest *models.QREvent
+	if login := s.qrLogins.logins[sessionID]; login != nil {
+		latest = login.latest
+	}
+	s.qrLogins.mu.Unlock()
+
+	if latest == nil {
+		return nil, models.NewNotFoundError("session %s has no active QR code, start a login first", sessionID)
+	}
+	return &models.QRResponse{
+		QRCode:    latest.Code,
+		ExpiresAt: models.FormatTimestamp(latest.ExpiresAt),
+	}, nil
+}
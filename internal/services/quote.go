@@ -0,0 +1,56 @@
+package services
+
+import (
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// quotedMessage resolves the sender of a message quoted in chat and, when a
+// text message was logged, its text for the quote preview. sender may be
+// messageSenderSelf for the session's own messages; when empty the sender is
+// taken from the message log or, in one-to-one chats, is the chat itself.
+func (s *WhatsAppService) quotedMessage(session *models.Session, chat types.JID, messageID, sender string) (types.JID, *waProto.Message, error) {
+	var logged *repository.Message
+	if s.messageRepo != nil {
+		message, err := s.messageRepo.GetMessageByID(session.ID, messageID)
+		if err != nil {
+			s.logger.Warn("Failed to look up quoted message %s of session %s: %v", messageID, session.ID, err)
+		}
+		logged = message
+	}
+
+	quoted := &waProto.Message{}
+	if logged != nil && logged.MessageType == "text" && logged.Content != "" {
+		quoted.Conversation = proto.String(logged.Content)
+	}
+
+	own := session.Client.Store.ID
+	switch {
+	case sender == messageSenderSelf:
+		if own == nil {
+			return types.JID{}, nil, models.NewServiceUnavailableError("session is not logged in")
+		}
+		return own.ToNonAD(), quoted, nil
+	case sender != "":
+		jid, err := parseRecipientJID(sender)
+		if err != nil {
+			return types.JID{}, nil, models.NewBadRequestError("invalid quoted_sender: %v", err)
+		}
+		return jid, quoted, nil
+	case logged != nil && logged.Direction == "sent" && own != nil:
+		return own.ToNonAD(), quoted, nil
+	case logged != nil && logged.SenderJID != "":
+		if jid, err := types.ParseJID(logged.SenderJID); err == nil {
+			return jid.ToNonAD(), quoted, nil
+		}
+	}
+
+	if chat.Server == types.GroupServer || chat.Server == types.BroadcastServer {
+		return types.JID{}, nil, models.NewBadRequestError("quoted_sender is required to quote a message in a group that was not logged")
+	}
+	return chat, quoted, nil
+}
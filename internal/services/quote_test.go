@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// A reply's quote names the quoted message's sender, not the recipient
+func TestQuotedMessage(t *testing.T) {
+	service, _, userID := newTestWhatsAppService(t)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	own := session.Client.Store.ID.ToNonAD().String()
+
+	for _, message := range []*repository.Message{
+		{MessageID: "SENT1", SenderJID: own, RecipientJID: "628123456789@s.whatsapp.net", MessageType: "text", Content: "our offer", Direction: "sent"},
+		{MessageID: "RECEIVED1", SenderJID: "628123456789:7@s.whatsapp.net", RecipientJID: "628123456789@s.whatsapp.net", MessageType: "text", Content: "how much?", Direction: "received"},
+		{MessageID: "GROUP1", SenderJID: "628987654321@s.whatsapp.net", RecipientJID: "120363012345678901@g.us", MessageType: "image", Direction: "received"},
+	} {
+		message.SessionID = session.ID
+		message.Status = "sent"
+		message.CreatedAt = time.Now()
+		if err := service.messageRepo.LogMessage(message); err != nil {
+			t.Fatalf("failed to log message: %v", err)
+		}
+	}
+
+	direct := types.NewJID("628123456789", types.DefaultUserServer)
+	group := types.NewJID("120363012345678901", types.GroupServer)
+	tests := []struct {
+		name      string
+		chat      types.JID
+		messageID string
+		sender    string
+		want      string
+		text      string
+	}{
+		{"own message by me", direct, "UNLOGGED", messageSenderSelf, own, ""},
+		{"explicit sender", group, "UNLOGGED", "628555555555", "628555555555@s.whatsapp.net", ""},
+		{"explicit sender overrides the log", direct, "RECEIVED1", "628555555555", "628555555555@s.whatsapp.net", "how much?"},
+		{"logged sent message", direct, "SENT1", "", own, "our offer"},
+		{"logged received message", direct, "RECEIVED1", "", "628123456789@s.whatsapp.net", "how much?"},
+		{"logged group message without text", group, "GROUP1", "", "628987654321@s.whatsapp.net", ""},
+		{"unlogged one-to-one message", direct, "UNLOGGED", "", "628123456789@s.whatsapp.net", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			participant, quoted, err := service.quotedMessage(session, tt.chat, tt.messageID, tt.sender)
+			if err != nil {
+				t.Fatalf("quotedMessage() = %v", err)
+			}
+			if participant.String() != tt.want {
+				t.Errorf("participant = %s, want %s", participant, tt.want)
+			}
+			if quoted.GetConversation() != tt.text {
+				t.Errorf("quoted text = %q, want %q", quoted.GetConversation(), tt.text)
+			}
+		})
+	}
+
+	var badRequest models.BadRequestError
+	if _, _, err := service.quotedMessage(session, group, "UNLOGGED", ""); !errors.As(err, &badRequest) {
+		t.Errorf("unlogged group message without sender = %v, want a bad request", err)
+	}
+	if _, _, err := service.quotedMessage(session, direct, "UNLOGGED", "not a number"); !errors.As(err, &badRequest) {
+		t.Errorf("invalid sender = %v, want a bad request", err)
+	}
+}
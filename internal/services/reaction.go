@@ -0,0 +1,111 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// messageSenderSelf names the session's own account as the sender of a
+// message reacted to or quoted
+const messageSenderSelf = "me"
+
+// SendReaction reacts to a message with an emoji and returns the reaction's
+// message ID. An empty emoji removes the session's reaction to the message.
+func (s *WhatsAppService) SendReaction(sessionID, chatJID, messageID, senderJID, emoji string) (string, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return "", models.NewBadRequestError("message_id is required")
+	}
+	if emoji != "" && !isSingleGrapheme(emoji) {
+		return "", models.NewBadRequestError("emoji must be a single emoji, got %q", emoji)
+	}
+
+	chat, err := parseRecipientJID(chatJID)
+	if err != nil {
+		return "", models.NewBadRequestError("invalid chat: %v", err)
+	}
+
+	// An empty sender makes whatsmeow build a key for one of our own messages
+	var sender types.JID
+	switch senderJID {
+	case messageSenderSelf:
+	case "":
+		if chat.Server == types.GroupServer || chat.Server == types.BroadcastServer {
+			return "", models.NewBadRequestError("sender is required to react to a message in a group")
+		}
+		sender = chat
+	default:
+		if sender, err = parseRecipientJID(senderJID); err != nil {
+			return "", models.NewBadRequestError("invalid sender: %v", err)
+		}
+	}
+
+	msg := session.Client.BuildReaction(chat, sender, messageID, emoji)
+	resp, err := s.sendMessage(session, chat, msg)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to send reaction", err)
+	}
+
+	if emoji == "" {
+		s.logger.Info("Reaction to %s in %s removed from session %s", messageID, chat, sessionID)
+	} else {
+		s.logger.Info("Reacted %s to %s in %s from session %s", emoji, messageID, chat, sessionID)
+	}
+	return resp.ID, nil
+}
+
+// isSingleGrapheme reports whether s is one user-perceived character. It
+// covers what emoji are made of: a base character with variation selectors,
+// skin tones, keycaps, combining marks and tags, possibly joined to further
+// characters with zero width joiners, or a pair of regional indicators (a flag).
+func isSingleGrapheme(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return false
+	}
+	if len(runes) == 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+		return true
+	}
+
+	i := 1
+	for i < len(runes) {
+		switch r := runes[i]; {
+		case isGraphemeExtender(r):
+			i++
+		case r == '\u200d' && i+1 < len(runes):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isGraphemeExtender reports whether r attaches to the preceding character
+func isGraphemeExtender(r rune) bool {
+	switch {
+	case r == '\ufe0e', r == '\ufe0f': // Variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // Skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Tags, used by subdivision flags
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// restoreReadyTimeout is how long readiness waits for restored sessions to
+// finish connecting. A connection hanging longer no longer holds back traffic
+// for the other sessions.
+const restoreReadyTimeout = time.Minute
+
+// restoreProgress tracks the restore of stored sessions at startup: loading
+// them, then the first connection attempt of each enabled one
+type restoreProgress struct {
+	mu       sync.Mutex
+	done     bool
+	loadedAt time.Time
+	pending  int // Auto-connects still running
+}
+
+func (p *restoreProgress) add() {
+	p.mu.Lock()
+	p.pending++
+	p.mu.Unlock()
+}
+
+func (p *restoreProgress) connectDone() {
+	p.mu.Lock()
+	p.pending--
+	p.mu.Unlock()
+}
+
+func (p *restoreProgress) loaded() {
+	p.mu.Lock()
+	p.done = true
+	p.loadedAt = time.Now()
+	p.mu.Unlock()
+}
+
+// Ready reports whether stored sessions have been restored and have made
+// their first connection attempt, with the reason when they have not
+func (s *WhatsAppService) Ready() (bool, string) {
+	p := &s.restore
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case !p.done:
+		return false, "restoring sessions"
+	case p.pending > 0 && time.Since(p.loadedAt) < restoreReadyTimeout:
+		return false, fmt.Sprintf("connecting %d restored session(s)", p.pending)
+	}
+	return true, ""
+}
+
+// SessionCounts counts the sessions of this instance by state
+func (s *WhatsAppService) SessionCounts() models.SessionCounts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := models.SessionCounts{Total: len(s.sessions)}
+	for _, session := range s.sessions {
+		state := session.State()
+		if session.Enabled {
+			counts.Enabled++
+		}
+		if state.Connected {
+			counts.Connected++
+		}
+		if state.LoggedIn {
+			counts.LoggedIn++
+		}
+		if state.NeedsAttention {
+			counts.NeedsAttention++
+		}
+		if session.Client == nil || session.Client.Store.ID == nil {
+			counts.NeedsAuth++
+		}
+	}
+	return counts
+}
+
+// CheckStoreWritable checks that the whatsmeow SQLite store and its
+// directory, where SQLite keeps its journal, can be written
+func (s *WhatsAppService) CheckStoreWritable() error {
+	file, err := os.OpenFile(s.storePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("store %s is not writable: %v", s.storePath, err)
+	}
+	file.Close()
+
+	probe, err := os.CreateTemp(filepath.Dir(s.storePath), ".health-*")
+	if err != nil {
+		return fmt.Errorf("store directory %s is not writable: %v", filepath.Dir(s.storePath), err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Group IDs are either a creator's number and creation time joined by a dash
+// (older groups) or a long number starting with 120363 (newer groups)
+var (
+	legacyGroupIDPattern = regexp.MustCompile(`^\d{8,15}-\d{9,11}$`)
+	digitsPattern        = regexp.MustCompile(`^\d+$`)
+)
+
+// groupIDMinDigits is the shortest numeric group ID; phone numbers have at
+// most 15 digits, so longer numbers are group IDs
+const groupIDMinDigits = 16
+
+// parseRecipientJID resolves a send recipient. It accepts
+//   - a phone number, with optional +, spaces or dashes
+//   - a user JID (number@s.whatsapp.net or a hidden user @lid)
+//   - a group JID (…@g.us) or a bare numeric group ID as listed by GET /groups
+//   - a broadcast list JID (…@broadcast)
+//   - a channel JID (…@newsletter)
+//
+// Device suffixes are dropped from the result.
+func parseRecipientJID(to string) (types.JID, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return types.JID{}, fmt.Errorf("recipient is required")
+	}
+
+	if strings.Contains(to, "@") {
+		jid, err := types.ParseJID(to)
+		if err != nil {
+			return types.JID{}, fmt.Errorf("invalid JID: %v", err)
+		}
+		if jid.User == "" {
+			return types.JID{}, fmt.Errorf("invalid JID %q: missing user", to)
+		}
+		switch jid.Server {
+		case types.DefaultUserServer, types.HiddenUserServer, types.GroupServer, types.NewsletterServer:
+		case types.BroadcastServer:
+			if jid == types.StatusBroadcastJID {
+				return types.JID{}, fmt.Errorf("statuses are posted with the status endpoint, not sent to %s", to)
+			}
+		default:
+			return types.JID{}, fmt.Errorf("unsupported recipient server %q in %s", jid.Server, to)
+		}
+		return jid.ToNonAD(), nil
+	}
+
+	if legacyGroupIDPattern.MatchString(to) {
+		return types.NewJID(to, types.GroupServer), nil
+	}
+
+	number := strings.NewReplacer("+", "", " ", "", "-", "").Replace(to)
+	if !digitsPattern.MatchString(number) {
+		return types.JID{}, fmt.Errorf("invalid recipient %q: expected a phone number, group ID or JID", to)
+	}
+	if len(number) >= groupIDMinDigits && !strings.HasPrefix(to, "+") {
+		return types.NewJID(number, types.GroupServer), nil
+	}
+	if len(number) < 8 || len(number) > 15 {
+		return types.JID{}, fmt.Errorf("invalid phone number length. Should be 8-15 digits")
+	}
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
+
+// ChatJID returns the JID a recipient resolves to, or the recipient unchanged
+// when it does not parse. Sent messages are logged under it so they share a
+// chat with the messages received from the same number.
+func ChatJID(to string) string {
+	jid, err := parseRecipientJID(to)
+	if err != nil {
+		return to
+	}
+	return jid.String()
+}
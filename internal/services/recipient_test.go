@@ -0,0 +1,81 @@
+package services
+
+import "testing"
+
+func TestParseRecipientJID(t *testing.T) {
+	tests := []struct {
+		name string
+		to   string
+		want string // Empty when the recipient is rejected
+	}{
+		// Phone numbers
+		{"phone number", "628123456789", "628123456789@s.whatsapp.net"},
+		{"formatted phone number", "+62 812-3456-789", "628123456789@s.whatsapp.net"},
+		{"surrounding spaces", "  628123456789  ", "628123456789@s.whatsapp.net"},
+		{"shortest phone number", "12345678", "12345678@s.whatsapp.net"},
+		{"longest phone number", "123456789012345", "123456789012345@s.whatsapp.net"},
+
+		// User JIDs
+		{"user JID", "628123456789@s.whatsapp.net", "628123456789@s.whatsapp.net"},
+		{"user JID with device", "628123456789:12@s.whatsapp.net", "628123456789@s.whatsapp.net"},
+		{"hidden user JID", "123456789012345@lid", "123456789012345@lid"},
+
+		// Groups
+		{"group JID", "120363012345678901@g.us", "120363012345678901@g.us"},
+		{"bare numeric group ID", "120363012345678901", "120363012345678901@g.us"},
+		{"legacy group JID", "6281234567890-1612345678@g.us", "6281234567890-1612345678@g.us"},
+		{"bare legacy group ID", "6281234567890-1612345678", "6281234567890-1612345678@g.us"},
+
+		// Broadcast lists and channels
+		{"broadcast list", "1612345678@broadcast", "1612345678@broadcast"},
+		{"channel", "120363123456789012@newsletter", "120363123456789012@newsletter"},
+
+		// Invalid input
+		{"empty", "", ""},
+		{"only spaces", "   ", ""},
+		{"letters", "alice", ""},
+		{"letters in a number", "62812abc456", ""},
+		{"too short", "1234567", ""},
+		{"too long with a plus", "+1234567890123456", ""},
+		{"JID without a user", "@s.whatsapp.net", ""},
+		{"unsupported server", "628123456789@example.com", ""},
+		{"status broadcast", "status@broadcast", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jid, err := parseRecipientJID(tt.to)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("parseRecipientJID(%q) = %s, want an error", tt.to, jid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRecipientJID(%q) = %v", tt.to, err)
+			}
+			if jid.String() != tt.want {
+				t.Errorf("parseRecipientJID(%q) = %s, want %s", tt.to, jid, tt.want)
+			}
+		})
+	}
+}
+
+// Sent messages are logged under the chat JID; recipients that do not parse
+// are logged as given
+func TestChatJID(t *testing.T) {
+	tests := []struct {
+		to   string
+		want string
+	}{
+		{"+62 812-3456-789", "628123456789@s.whatsapp.net"},
+		{"628123456789:3@s.whatsapp.net", "628123456789@s.whatsapp.net"},
+		{"120363012345678901", "120363012345678901@g.us"},
+		{"not a number", "not a number"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ChatJID(tt.to); got != tt.want {
+			t.Errorf("ChatJID(%q) = %q, want %q", tt.to, got, tt.want)
+		}
+	}
+}
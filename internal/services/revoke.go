@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waSyncAction"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// RevokeMessage deletes a message. For everyone, one of the session's own
+// messages is revoked for every participant, which WhatsApp only accepts
+// within models.RevokeWindow of sending. For me, any message is removed from
+// the session's own phone and linked devices only. The logged message is
+// marked revoked or deleted.
+func (s *WhatsAppService) RevokeMessage(sessionID, messageID string, req *models.DeleteMessageRequest) (*models.DeleteMessageResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return nil, models.NewBadRequestError("message ID is required")
+	}
+	mode := req.For
+	if mode == "" {
+		mode = models.DeleteForEveryone
+	}
+	if mode != models.DeleteForEveryone && mode != models.DeleteForMe {
+		return nil, models.NewBadRequestError("for must be %s or %s", models.DeleteForEveryone, models.DeleteForMe)
+	}
+
+	var logged *repository.Message
+	if s.messageRepo != nil {
+		if logged, err = s.messageRepo.GetMessageByID(sessionID, messageID); err != nil {
+			s.logger.Warn("Failed to look up message %s of session %s: %v", messageID, sessionID, err)
+		}
+	}
+
+	chat, err := revokeChat(req.Chat, logged)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.DeleteMessageResponse{MessageID: messageID, Chat: chat.String(), For: mode}
+	status := "deleted"
+	if mode == models.DeleteForEveryone {
+		if err := s.checkNotBanned(session); err != nil {
+			return nil, err
+		}
+		if logged != nil {
+			if logged.Direction != "sent" {
+				return nil, models.NewBadRequestError("message %s was received, only the session's own messages can be deleted for everyone", messageID)
+			}
+			if age := time.Since(logged.CreatedAt); age > models.RevokeWindow {
+				return nil, models.NewBadRequestError("message %s was sent %s ago, WhatsApp only deletes messages for everyone within %s", messageID, age.Round(time.Minute), models.RevokeWindow)
+			}
+		}
+
+		sent, err := s.sendMessage(session, chat, session.Client.BuildRevoke(chat, types.EmptyJID, messageID))
+		if err != nil {
+			return nil, s.sendFailure(session, "failed to delete message for everyone", err)
+		}
+		resp.RevokeMessageID = sent.ID
+		status = "revoked"
+	} else if !session.Sandbox {
+		if err := session.Client.SendAppState(context.Background(), buildDeleteForMe(chat, messageID, logged)); err != nil {
+			return nil, models.NewServiceUnavailableError("failed to delete message: %v", err)
+		}
+	}
+
+	if logged != nil {
+		if err := s.messageRepo.MarkMessageDeleted(sessionID, messageID, status); err != nil {
+			s.logger.Error("Failed to record deletion of message %s for session %s: %v", messageID, sessionID, err)
+		}
+	}
+
+	s.logger.Info("Session %s deleted message %s in %s for %s", sessionID, messageID, chat, mode)
+	return resp, nil
+}
+
+// revokeChat resolves the chat of a message to delete, falling back to the
+// chat it was logged in
+func revokeChat(chat string, logged *repository.Message) (types.JID, error) {
+	if chat == "" && logged != nil {
+		chat = logged.RecipientJID
+		if logged.Direction == "received" {
+			chat = logged.SenderJID
+		}
+	}
+	if chat == "" {
+		return types.EmptyJID, models.NewBadRequestError("chat is required for messages that were not logged")
+	}
+	jid, err := parseRecipientJID(chat)
+	if err != nil {
+		return types.EmptyJID, models.NewBadRequestError("invalid chat: %v", err)
+	}
+	return jid, nil
+}
+
+// buildDeleteForMe builds the app state patch removing a message from the
+// account's own devices. Messages that were not logged are assumed to be the
+// session's own.
+func buildDeleteForMe(chat types.JID, messageID string, logged *repository.Message) appstate.PatchInfo {
+	fromMe, participant := "1", "0"
+	timestamp := time.Now()
+	if logged != nil {
+		timestamp = logged.CreatedAt
+		if logged.Direction == "received" {
+			fromMe = "0"
+			if chat.Server == types.GroupServer {
+				if sender, err := types.ParseJID(logged.SenderJID); err == nil {
+					participant = sender.ToNonAD().String()
+				}
+			}
+		}
+	}
+
+	return appstate.PatchInfo{
+		Type: appstate.WAPatchRegularHigh,
+		Mutations: []appstate.MutationInfo{{
+			Index:   []string{appstate.IndexDeleteMessageForMe, chat.String(), messageID, fromMe, participant},
+			Version: 3,
+			Value: &waSyncAction.SyncActionValue{
+				DeleteMessageForMeAction: &waSyncAction.DeleteMessageForMeAction{
+					DeleteMedia:      proto.Bool(true),
+					MessageTimestamp: proto.Int64(timestamp.Unix()),
+				},
+			},
+		}},
+	}
+}
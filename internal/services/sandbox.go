@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/metrics"
+)
+
+// Sandbox sessions let the API be exercised without a WhatsApp account. They
+// keep a whatsmeow client whose device is never saved or connected; instead
+// the service simulates the connection at its boundary:
+//
+//   - connecting logs in at once as a fake number (sandboxJID)
+//   - sends and uploads succeed locally, and each sent message is confirmed by
+//     a delivered receipt after the sandbox receipt delay
+//   - InjectSandboxMessage feeds synthetic incoming messages to the client's
+//     event handlers, so webhooks, auto-replies and contact tracking run as
+//     for real traffic
+//
+// Operations that need WhatsApp's servers, such as number checks or group
+// queries, fail as they would on a disconnected client.
+
+// sandboxCountryCode prefixes fake sandbox numbers; +999 is not assigned to
+// any country, so a sandbox number never belongs to a real account
+const sandboxCountryCode = "999"
+
+// sandboxMediaHost is used in the URLs of simulated uploads
+const sandboxMediaHost = "https://sandbox.invalid"
+
+// SetSandboxMode makes every new session a sandbox session when enabled, and
+// sets how long sandbox sends wait before their delivered receipt
+func (s *WhatsAppService) SetSandboxMode(enabled bool, receiptDelay time.Duration) {
+	s.sandboxMode = enabled
+	if receiptDelay < 0 {
+		receiptDelay = 0
+	}
+	s.sandboxReceiptDelay = receiptDelay
+}
+
+// sandboxJID is the fake account a sandbox session logs in as, derived from
+// the session ID so it stays the same across restarts
+func sandboxJID(sessionID string) types.JID {
+	hash := fnv.New32a()
+	hash.Write([]byte(sessionID))
+	return types.NewJID(fmt.Sprintf("%s%09d", sandboxCountryCode, hash.Sum32()%1000000000), types.DefaultUserServer)
+}
+
+// newSandboxDevice creates an in-memory device for a sandbox session. It is
+// never saved, so nothing about the sandbox ends up in the WhatsApp store.
+func (s *WhatsAppService) newSandboxDevice(sessionID string) *store.Device {
+	device := s.store.NewDevice()
+	jid := sandboxJID(sessionID)
+	device.ID = &jid
+	device.PushName = "Sandbox " + sessionID
+	return device
+}
+
+// sandboxLogin marks a sandbox session connected and logged in as its fake
+// number; the caller holds s.mu
+func (s *WhatsAppService) sandboxLogin(session *models.Session) {
+	session.SetConnected(true, true)
+	session.ActualPhone = session.Client.Store.ID.User + "@" + types.DefaultUserServer
+	s.logger.Info("Sandbox session %s logged in as %s", session.ID, session.ActualPhone)
+	go s.publishSessionStatus(session, models.SessionStatusConnected, "")
+}
+
+// sandboxLogout marks a sandbox session disconnected and logged out
+func (s *WhatsAppService) sandboxLogout(session *models.Session) {
+	session.SetDisconnected()
+	s.logger.Info("Sandbox session %s logged out", session.ID)
+	s.publishSessionStatus(session, models.SessionStatusLoggedOut, "logged out by request")
+}
+
+// sendMessage sends a message through the session's client. Sandbox sessions
+// only pretend to send and confirm delivery after the sandbox receipt delay.
+// Messages to a broadcast list go to each of its recipients.
+func (s *WhatsAppService) sendMessage(session *models.Session, jid types.JID, msg *waProto.Message) (whatsmeow.SendResponse, error) {
+	if jid.Server == types.BroadcastServer && jid != types.StatusBroadcastJID {
+		return s.sendToBroadcastList(session, jid, msg)
+	}
+	return s.sendMessageWithID(session, jid, msg, "")
+}
+
+// sendMessageWithID sends a message under the given ID, or a new one when id is empty
+func (s *WhatsAppService) sendMessageWithID(session *models.Session, jid types.JID, msg *waProto.Message, id types.MessageID) (whatsmeow.SendResponse, error) {
+	// A message clears the typing indicator, so one still pending must not bring it back
+	s.typing.stop(session.ID, jid)
+
+	if !session.Sandbox {
+		resp, err := session.Client.SendMessage(context.Background(), jid, msg, whatsmeow.SendRequestExtra{ID: id})
+		if err != nil {
+			metrics.MessagesFailed.Inc(session.ID)
+		} else {
+			metrics.MessagesSent.Inc(session.ID)
+		}
+		return resp, err
+	}
+
+	if id == "" {
+		id = session.Client.GenerateMessageID()
+	}
+	resp := whatsmeow.SendResponse{
+		ID:        id,
+		Timestamp: time.Now(),
+		Sender:    *session.Client.Store.ID,
+	}
+	time.AfterFunc(s.sandboxReceiptDelay, func() {
+		session.Client.DangerousInternals().DispatchEvent(&events.Receipt{
+			MessageSource: types.MessageSource{
+				Chat:   jid,
+				Sender: jid,
+			},
+			MessageIDs: []types.MessageID{resp.ID},
+			Timestamp:  time.Now(),
+			Type:       types.ReceiptTypeDelivered,
+		})
+	})
+	s.logger.Debug("Sandbox session %s sent message %s to %s", session.ID, resp.ID, jid)
+	metrics.MessagesSent.Inc(session.ID)
+	return resp, nil
+}
+
+// upload uploads media through the session's client. Sandbox uploads only
+// compute the hashes a real upload would return.
+func (s *WhatsAppService) upload(session *models.Session, data []byte, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	if !session.Sandbox {
+		return session.Client.Upload(context.Background(), data, mediaType)
+	}
+
+	mediaKey := make([]byte, 32)
+	if _, err := rand.Read(mediaKey); err != nil {
+		return whatsmeow.UploadResponse{}, err
+	}
+	fileSHA256 := sha256.Sum256(data)
+	directPath := "/sandbox/" + hex.EncodeToString(fileSHA256[:])
+	return whatsmeow.UploadResponse{
+		URL:           sandboxMediaHost + directPath,
+		DirectPath:    directPath,
+		MediaKey:      mediaKey,
+		FileEncSHA256: fileSHA256[:],
+		FileSHA256:    fileSHA256[:],
+		FileLength:    uint64(len(data)),
+	}, nil
+}
+
+// InjectSandboxMessage delivers a synthetic incoming text message to a
+// sandbox session, running it through the same handlers as a message
+// received from WhatsApp
+func (s *WhatsAppService) InjectSandboxMessage(sessionID string, req *models.SandboxIncomingRequest) (*models.SandboxIncomingResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Sandbox {
+		return nil, models.NewBadRequestError("session %s is not a sandbox session", sessionID)
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		return nil, models.NewBadRequestError("text is required")
+	}
+	sender, err := parseRecipientJID(req.From)
+	if err != nil {
+		return nil, models.NewBadRequestError("invalid from: %v", err)
+	}
+	if sender.Server != types.DefaultUserServer && sender.Server != types.HiddenUserServer {
+		return nil, models.NewBadRequestError("from must be a phone number or user JID, use group for group messages")
+	}
+
+	chat := sender
+	if req.Group != "" {
+		chat, err = types.ParseJID(req.Group)
+		if err != nil || chat.Server != types.GroupServer {
+			return nil, models.NewBadRequestError("invalid group JID %q", req.Group)
+		}
+	}
+
+	messageID := req.MessageID
+	if messageID == "" {
+		messageID = session.Client.GenerateMessageID()
+	}
+
+	evt := &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:    chat,
+				Sender:  sender,
+				IsGroup: chat.Server == types.GroupServer,
+			},
+			ID:        messageID,
+			Type:      "text",
+			PushName:  req.PushName,
+			Timestamp: time.Now(),
+		},
+		Message: &waProto.Message{Conversation: proto.String(req.Text)},
+	}
+	session.Client.DangerousInternals().DispatchEvent(evt)
+
+	s.logger.Info("Sandbox session %s received injected message %s from %s", sessionID, messageID, sender)
+	return &models.SandboxIncomingResponse{
+		MessageID: messageID,
+		From:      sender.String(),
+		Chat:      chat.String(),
+		Timestamp: models.FormatTimestamp(evt.Info.Timestamp),
+	}, nil
+}
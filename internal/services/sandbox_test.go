@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// receipts collects the receipts dispatched to a session's client
+func receipts(session *models.Session) <-chan *events.Receipt {
+	ch := make(chan *events.Receipt, 16)
+	session.Client.AddEventHandler(func(evt interface{}) {
+		if receipt, ok := evt.(*events.Receipt); ok {
+			select {
+			case ch <- receipt:
+			default:
+			}
+		}
+	})
+	return ch
+}
+
+func TestSandboxJID(t *testing.T) {
+	jid := sandboxJID("shop")
+	if jid != sandboxJID("shop") {
+		t.Error("sandbox JID changes for the same session")
+	}
+	if jid == sandboxJID("support") {
+		t.Error("two sessions share a sandbox JID")
+	}
+	if !strings.HasPrefix(jid.User, sandboxCountryCode) || len(jid.User) != len(sandboxCountryCode)+9 || jid.Server != types.DefaultUserServer {
+		t.Errorf("sandbox JID = %s, want a 12 digit +%s number", jid, sandboxCountryCode)
+	}
+}
+
+func TestSandboxSessionLifecycle(t *testing.T) {
+	service, sessionRepo, userID := newTestWhatsAppService(t)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Sandbox", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	stored, err := sessionRepo.GetByID(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Sandbox {
+		t.Error("stored session is not marked as sandbox")
+	}
+
+	var badRequest models.BadRequestError
+	if _, err := service.GetQRCode(session.ID); !errors.As(err, &badRequest) {
+		t.Errorf("QR code of a sandbox session = %v, want a bad request", err)
+	}
+
+	// Connecting logs in at once as the fake number
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if state := session.State(); !state.Connected || !state.LoggedIn {
+		t.Fatalf("state after connecting = %+v, want logged in", state)
+	}
+	if want := sandboxJID(session.ID).String(); session.ActualPhone != want {
+		t.Errorf("logged in as %q, want %q", session.ActualPhone, want)
+	}
+
+	if err := service.LogoutSession(session.ID); err != nil {
+		t.Fatalf("failed to log out: %v", err)
+	}
+	if state := session.State(); state.Connected || state.LoggedIn {
+		t.Errorf("state after logging out = %+v, want logged out", state)
+	}
+	if err := service.LoginSession(session.ID); err != nil {
+		t.Fatalf("failed to log in again: %v", err)
+	}
+	if !session.IsLoggedIn() {
+		t.Error("session is not logged in after logging in again")
+	}
+}
+
+// Sandbox sessions do not count against the session limit
+func TestSandboxSessionsSkipSessionLimit(t *testing.T) {
+	service, _, userID := newTestWhatsAppService(t)
+
+	for i := 0; i <= models.DefaultSessionLimit; i++ {
+		if _, err := service.CreateSession(&models.CreateSessionRequest{Name: "Sandbox", Sandbox: true}, userID, models.RoleUser); err != nil {
+			t.Fatalf("sandbox session %d rejected: %v", i+1, err)
+		}
+	}
+	for i := 0; i < models.DefaultSessionLimit; i++ {
+		if _, err := service.CreateSession(&models.CreateSessionRequest{Name: "Real"}, userID, models.RoleUser); err != nil {
+			t.Fatalf("session %d rejected next to sandbox sessions: %v", i+1, err)
+		}
+	}
+	var limitErr models.SessionLimitError
+	if _, err := service.CreateSession(&models.CreateSessionRequest{Name: "Real"}, userID, models.RoleUser); !errors.As(err, &limitErr) {
+		t.Errorf("session above the limit = %v, want a session limit error", err)
+	}
+}
+
+// In sandbox mode every new session is a sandbox session, whose sends are
+// confirmed by a delivered receipt after the receipt delay
+func TestSandboxModeSends(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	service, _, userID := newTestWhatsAppService(t)
+	service.SetSandboxMode(true, delay)
+
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop"}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if !session.Sandbox {
+		t.Fatal("session created in sandbox mode is not a sandbox session")
+	}
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	delivered := receipts(session)
+
+	sentAt := time.Now()
+	messageID, err := service.SendMessage(session.ID, &models.SendMessageRequest{To: "628123456789", Message: "hello"})
+	if err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	select {
+	case receipt := <-delivered:
+		if len(receipt.MessageIDs) != 1 || receipt.MessageIDs[0] != messageID || receipt.Type != types.ReceiptTypeDelivered {
+			t.Errorf("receipt = %+v, want delivered for %s", receipt, messageID)
+		}
+		if receipt.Chat.String() != "628123456789@s.whatsapp.net" {
+			t.Errorf("receipt from %s, want the recipient", receipt.Chat)
+		}
+		if elapsed := time.Since(sentAt); elapsed < delay {
+			t.Errorf("receipt after %v, want the %v receipt delay", elapsed, delay)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivered receipt for the sandbox send")
+	}
+
+	uploaded, err := service.upload(session, []byte("media"), whatsmeow.MediaImage)
+	if err != nil {
+		t.Fatalf("sandbox upload failed: %v", err)
+	}
+	if uploaded.FileLength != 5 || len(uploaded.MediaKey) != 32 || !strings.HasPrefix(uploaded.URL, sandboxMediaHost+"/sandbox/") {
+		t.Errorf("sandbox upload = %+v", uploaded)
+	}
+}
+
+// Injected messages run through the webhook and auto-reply pipeline
+func TestInjectSandboxMessage(t *testing.T) {
+	webhooks := make(chan models.WebhookMessage, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg models.WebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err == nil && msg.ID != "" {
+			webhooks <- msg
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	service, _, userID := newTestWhatsAppService(t)
+	service.SetAllowPrivateWebhooks(true)
+	autoReply := "We'll get back to you"
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true, WebhookURL: server.URL, AutoReplyText: &autoReply}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	replies := receipts(session)
+
+	tests := []struct {
+		name string
+		req  models.SandboxIncomingRequest
+		want models.WebhookMessage
+	}{
+		{
+			name: "direct message",
+			req:  models.SandboxIncomingRequest{From: "+62 812-3456-789", Text: "  hello  ", PushName: "Ani"},
+			want: models.WebhookMessage{From: "628123456789@s.whatsapp.net", FromName: "Ani", Chat: "628123456789@s.whatsapp.net", Message: "hello"},
+		},
+		{
+			name: "group message without a push name",
+			req:  models.SandboxIncomingRequest{From: "628987654321@s.whatsapp.net", Text: "hi all", Group: "120363012345678901@g.us", MessageID: "GROUPMSG1"},
+			want: models.WebhookMessage{From: "628987654321@s.whatsapp.net", FromName: "628987654321", Chat: "120363012345678901@g.us", Message: "hi all", IsGroup: true, GroupID: "120363012345678901@g.us"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := service.InjectSandboxMessage(session.ID, &tt.req)
+			if err != nil {
+				t.Fatalf("inject failed: %v", err)
+			}
+			if resp.From != tt.want.From || resp.Chat != tt.want.Chat || resp.MessageID == "" {
+				t.Errorf("response = %+v, want from %s in %s", resp, tt.want.From, tt.want.Chat)
+			}
+			if tt.req.MessageID != "" && resp.MessageID != tt.req.MessageID {
+				t.Errorf("message ID = %q, want the given %q", resp.MessageID, tt.req.MessageID)
+			}
+
+			select {
+			case msg := <-webhooks:
+				if msg.ID != resp.MessageID || msg.SessionID != session.ID || msg.To != session.ActualPhone {
+					t.Errorf("webhook for message %s of session %s to %s, want %s of %s to %s",
+						msg.ID, msg.SessionID, msg.To, resp.MessageID, session.ID, session.ActualPhone)
+				}
+				if msg.From != tt.want.From || msg.FromName != tt.want.FromName || msg.Chat != tt.want.Chat || msg.Message != tt.want.Message ||
+					msg.IsGroup != tt.want.IsGroup || msg.GroupID != tt.want.GroupID {
+					t.Errorf("webhook = %+v, want %+v", msg, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no webhook for the injected message")
+			}
+		})
+	}
+
+	// Only the direct message is answered, with a delivered auto-reply
+	select {
+	case receipt := <-replies:
+		if receipt.Chat.String() != "628123456789@s.whatsapp.net" {
+			t.Errorf("auto-reply sent to %s, want the direct message's sender", receipt.Chat)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no auto-reply to the injected message")
+	}
+}
+
+func TestInjectSandboxMessageRejects(t *testing.T) {
+	service, _, userID := newTestWhatsAppService(t)
+	sandbox, err := service.CreateSession(&models.CreateSessionRequest{Name: "Sandbox", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	offline, err := service.CreateSession(&models.CreateSessionRequest{Name: "Offline", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := service.ConnectSession(sandbox.ID); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		req       models.SandboxIncomingRequest
+	}{
+		{"unknown session", "missing", models.SandboxIncomingRequest{From: "628123456789", Text: "hi"}},
+		{"not logged in", offline.ID, models.SandboxIncomingRequest{From: "628123456789", Text: "hi"}},
+		{"empty text", sandbox.ID, models.SandboxIncomingRequest{From: "628123456789", Text: "   "}},
+		{"invalid sender", sandbox.ID, models.SandboxIncomingRequest{From: "not a number", Text: "hi"}},
+		{"group as sender", sandbox.ID, models.SandboxIncomingRequest{From: "120363012345678901@g.us", Text: "hi"}},
+		{"invalid group", sandbox.ID, models.SandboxIncomingRequest{From: "628123456789", Text: "hi", Group: "628123456789@s.whatsapp.net"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp, err := service.InjectSandboxMessage(tt.sessionID, &tt.req); err == nil {
+				t.Errorf("inject = %+v, want an error", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/phone"
+)
+
+// Typing is simulated for about as long as a person would need to type the
+// message, within these bounds
+const (
+	typingPerCharacter = 50 * time.Millisecond
+	minTypingDuration  = time.Second
+	maxTypingDuration  = 5 * time.Second
+)
+
+// maxUnreadPerChat bounds how many received messages are remembered per chat
+// for marking read after a reply
+const maxUnreadPerChat = 50
+
+// maxPreviewPageSize is how much of a linked page is read to build a preview
+const maxPreviewPageSize = 512 * 1024
+
+var (
+	linkPattern     = regexp.MustCompile(`https?://[^\s<>"]+`)
+	titlePattern    = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrPattern = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// unreadMessage is a received message not yet marked read by the service
+type unreadMessage struct {
+	id     types.MessageID
+	sender types.JID
+}
+
+// unreadTracker remembers received messages per chat so they can be marked
+// read once the session replies
+type unreadTracker struct {
+	mu    sync.Mutex
+	chats map[string][]unreadMessage
+}
+
+func unreadKey(sessionID string, chat types.JID) string {
+	return sessionID + "\x00" + chat.ToNonAD().String()
+}
+
+// track remembers an incoming message
+func (t *unreadTracker) track(sessionID string, evt *events.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.chats == nil {
+		t.chats = make(map[string][]unreadMessage)
+	}
+	key := unreadKey(sessionID, evt.Info.Chat)
+	unread := append(t.chats[key], unreadMessage{id: evt.Info.ID, sender: evt.Info.Sender})
+	if len(unread) > maxUnreadPerChat {
+		unread = unread[len(unread)-maxUnreadPerChat:]
+	}
+	t.chats[key] = unread
+}
+
+// take returns and forgets the chat's unread messages
+func (t *unreadTracker) take(sessionID string, chat types.JID) []unreadMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := unreadKey(sessionID, chat)
+	unread := t.chats[key]
+	delete(t.chats, key)
+	return unread
+}
+
+// remove forgets the given messages of a chat once they were marked read
+func (t *unreadTracker) remove(sessionID string, chat types.JID, ids []types.MessageID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := unreadKey(sessionID, chat)
+	marked := make(map[types.MessageID]bool, len(ids))
+	for _, id := range ids {
+		marked[id] = true
+	}
+	unread := t.chats[key][:0]
+	for _, message := range t.chats[key] {
+		if !marked[message.id] {
+			unread = append(unread, message)
+		}
+	}
+	if len(unread) == 0 {
+		delete(t.chats, key)
+	} else {
+		t.chats[key] = unread
+	}
+}
+
+// forget drops everything remembered for a session
+func (t *unreadTracker) forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prefix := sessionID + "\x00"
+	for key := range t.chats {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.chats, key)
+		}
+	}
+}
+
+// UpdateSendDefaults replaces the session's default send options; nil clears them
+func (s *WhatsAppService) UpdateSendDefaults(sessionID string, defaults *models.SendOptions) error {
+	if err := defaults.Validate(); err != nil {
+		return models.NewBadRequestError("%v", err)
+	}
+
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.mu.Unlock()
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+	session.SendDefaults = defaults
+	s.mu.Unlock()
+
+	return s.sessionRepo.UpdateSendDefaults(sessionID, defaults)
+}
+
+// sendWithOptions sends a message, applying the request's send options over
+// the session's send defaults
+func (s *WhatsAppService) sendWithOptions(session *models.Session, jid types.JID, msg *waProto.Message, opts *models.SendOptions) (whatsmeow.SendResponse, error) {
+	if err := opts.Validate(); err != nil {
+		return whatsmeow.SendResponse{}, models.NewBadRequestError("%v", err)
+	}
+
+	s.mu.RLock()
+	effective := models.ResolveSendOptions(opts, session.SendDefaults)
+	s.mu.RUnlock()
+
+	if effective.LinkPreview {
+		s.addLinkPreview(msg)
+	}
+	if effective.EphemeralExpiration > 0 {
+		setEphemeralExpiration(msg, effective.EphemeralExpiration)
+	}
+	if effective.SimulateTyping && !session.Sandbox {
+		s.simulateTyping(session, jid, msg)
+	}
+
+	resp, err := s.sendMessage(session, jid, msg)
+	if err != nil {
+		return resp, err
+	}
+	s.activity.RecordOutbound(session.UserID, phone.FromJID(jid.ToNonAD().String()), resp.Timestamp)
+
+	if effective.MarkRead && !session.Sandbox {
+		s.markChatRead(session, jid)
+	}
+	return resp, nil
+}
+
+// simulateTyping shows the typing indicator for a duration matching the message length
+func (s *WhatsAppService) simulateTyping(session *models.Session, jid types.JID, msg *waProto.Message) {
+	ctx := context.Background()
+	media := types.ChatPresenceMediaText
+	if msg.GetAudioMessage() != nil {
+		media = types.ChatPresenceMediaAudio
+	}
+
+	if err := session.Client.SendChatPresence(ctx, jid, types.ChatPresenceComposing, media); err != nil {
+		s.logger.Debug("Failed to send typing indicator for session %s: %v", session.ID, err)
+		return
+	}
+
+	duration := time.Duration(len(messageText(msg))) * typingPerCharacter
+	if duration < minTypingDuration {
+		duration = minTypingDuration
+	} else if duration > maxTypingDuration {
+		duration = maxTypingDuration
+	}
+	time.Sleep(duration)
+
+	if err := session.Client.SendChatPresence(ctx, jid, types.ChatPresencePaused, media); err != nil {
+		s.logger.Debug("Failed to clear typing indicator for session %s: %v", session.ID, err)
+	}
+}
+
+// markChatRead marks the messages received in a chat as read
+func (s *WhatsAppService) markChatRead(session *models.Session, chat types.JID) {
+	bySender := make(map[types.JID][]types.MessageID)
+	for _, message := range s.unread.take(session.ID, chat) {
+		bySender[message.sender] = append(bySender[message.sender], message.id)
+	}
+
+	for sender, ids := range bySender {
+		if err := session.Client.MarkRead(context.Background(), ids, time.Now(), chat, sender); err != nil {
+			s.logger.Warn("Failed to mark %d message(s) in %s read for session %s: %v", len(ids), chat, session.ID, err)
+		}
+	}
+	s.recordChatRead(session, chat)
+}
+
+// addLinkPreview turns a text message containing a link into one carrying a
+// preview of the first link. The page title and description are included
+// when the page can be fetched.
+func (s *WhatsAppService) addLinkPreview(msg *waProto.Message) {
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waProto.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+	text := msg.GetExtendedTextMessage()
+	if text == nil {
+		return
+	}
+
+	link := linkPattern.FindString(text.GetText())
+	if link == "" {
+		return
+	}
+	text.MatchedText = proto.String(link)
+	text.PreviewType = waProto.ExtendedTextMessage_NONE.Enum()
+
+	title, description, err := s.fetchLinkPreview(link)
+	if err != nil {
+		s.logger.Debug("Failed to fetch link preview for %s: %v", link, err)
+		return
+	}
+	if title != "" {
+		text.Title = proto.String(title)
+	}
+	if description != "" {
+		text.Description = proto.String(description)
+	}
+}
+
+// fetchLinkPreview reads the title and description of a web page
+func (s *WhatsAppService) fetchLinkPreview(link string) (title, description string, err error) {
+	resp, err := s.httpClients.Default().Get(link)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return "", "", fmt.Errorf("not an HTML page: %s", contentType)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPreviewPageSize))
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, tag := range metaTagPattern.FindAllString(string(page), -1) {
+		var key, content string
+		for _, attr := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(attr[2], `"'`))
+			if strings.EqualFold(attr[1], "content") {
+				content = value
+			} else {
+				key = strings.ToLower(value)
+			}
+		}
+		switch key {
+		case "og:title":
+			title = content
+		case "og:description":
+			description = content
+		case "description":
+			if description == "" {
+				description = content
+			}
+		}
+	}
+	if title == "" {
+		if match := titlePattern.FindStringSubmatch(string(page)); match != nil {
+			title = html.UnescapeString(match[1])
+		}
+	}
+
+	return strings.TrimSpace(title), strings.TrimSpace(description), nil
+}
+
+// setEphemeralExpiration makes a message disappear after the given number of seconds
+func setEphemeralExpiration(msg *waProto.Message, seconds int) {
+	if inner := msg.GetViewOnceMessage().GetMessage(); inner != nil {
+		setEphemeralExpiration(inner, seconds)
+		return
+	}
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waProto.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+
+	var contextInfo **waProto.ContextInfo
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		contextInfo = &msg.ExtendedTextMessage.ContextInfo
+	case msg.ImageMessage != nil:
+		contextInfo = &msg.ImageMessage.ContextInfo
+	case msg.VideoMessage != nil:
+		contextInfo = &msg.VideoMessage.ContextInfo
+	case msg.AudioMessage != nil:
+		contextInfo = &msg.AudioMessage.ContextInfo
+	case msg.DocumentMessage != nil:
+		contextInfo = &msg.DocumentMessage.ContextInfo
+	case msg.LocationMessage != nil:
+		contextInfo = &msg.LocationMessage.ContextInfo
+	default:
+		return
+	}
+
+	if *contextInfo == nil {
+		*contextInfo = &waProto.ContextInfo{}
+	}
+	(*contextInfo).Expiration = proto.Uint32(uint32(seconds))
+}
+
+// messageText returns the text or caption of a message
+func messageText(msg *waProto.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	case msg.GetProductMessage() != nil:
+		return msg.GetProductMessage().GetBody()
+	case msg.GetOrderMessage() != nil:
+		return msg.GetOrderMessage().GetMessage()
+	case msg.GetButtonsMessage() != nil:
+		return msg.GetButtonsMessage().GetContentText()
+	case msg.GetListMessage() != nil:
+		return msg.GetListMessage().GetDescription()
+	}
+	// The selected title, so keyword rules can react to taps
+	if _, reply := interactiveReply(msg); reply != nil {
+		return reply.Title
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// defaultBanDuration is assumed when WhatsApp does not say when a temporary ban expires
+const defaultBanDuration = 24 * time.Hour
+
+// handleTemporaryBan records a temporary ban reported by WhatsApp, rejects
+// sends until it expires and notifies the operator
+func (s *WhatsAppService) handleTemporaryBan(session *models.Session, evt *events.TemporaryBan) {
+	expire := evt.Expire
+	if expire <= 0 {
+		expire = defaultBanDuration
+	}
+	until := time.Now().Add(expire)
+	reason := evt.Code.String()
+
+	s.mu.Lock()
+	session.BannedUntil = &until
+	session.BanReason = reason
+	session.SetConnected(false, false)
+	s.mu.Unlock()
+
+	if err := s.sessionRepo.UpdateBan(session.ID, &until, reason); err != nil {
+		s.logger.Error("Failed to persist ban for session %s: %v", session.ID, err)
+	}
+
+	s.logger.Error("Session %s TEMPORARILY BANNED by WhatsApp until %s: %s", session.ID, models.FormatTimestamp(until), reason)
+	s.scheduleBanLift(session, until)
+	s.publishSessionStatus(session, models.SessionStatusDisconnected, "temporarily banned: "+reason)
+
+	go s.notifyOperator("session_banned", session, map[string]interface{}{
+		"phone":        session.ActualPhone,
+		"ban_reason":   reason,
+		"banned_until": models.FormatTimestamp(until),
+	})
+}
+
+// scheduleBanLift clears the ban and reconnects the session once it expires
+func (s *WhatsAppService) scheduleBanLift(session *models.Session, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if timer, exists := s.banTimers[session.ID]; exists {
+		timer.Stop()
+	}
+	s.banTimers[session.ID] = time.AfterFunc(time.Until(until), func() {
+		s.liftBan(session)
+	})
+}
+
+// liftBan clears an expired ban and reconnects the session
+func (s *WhatsAppService) liftBan(session *models.Session) {
+	s.mu.Lock()
+	if session.BannedUntil == nil || time.Now().Before(*session.BannedUntil) {
+		// Lifted already, or extended by a newer ban
+		s.mu.Unlock()
+		return
+	}
+	session.BannedUntil = nil
+	session.BanReason = ""
+	delete(s.banTimers, session.ID)
+	canReconnect := session.Enabled && session.Client != nil && session.Client.Store.ID != nil
+	s.mu.Unlock()
+
+	if err := s.sessionRepo.UpdateBan(session.ID, nil, ""); err != nil {
+		s.logger.Error("Failed to clear ban for session %s: %v", session.ID, err)
+	}
+
+	s.logger.Info("Temporary ban of session %s has expired", session.ID)
+	go s.notifyOperator("session_ban_lifted", session, nil)
+
+	if canReconnect {
+		if err := s.ConnectSession(session.ID); err != nil {
+			s.logger.Warn("Failed to reconnect session %s after ban: %v", session.ID, err)
+		}
+	}
+}
+
+// SessionBannedUntil reports whether a session is temporarily banned and until when
+func (s *WhatsAppService) SessionBannedUntil(sessionID string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists || session.BannedUntil == nil {
+		return time.Time{}, false
+	}
+	return *session.BannedUntil, true
+}
+
+// checkNotBanned rejects sends from a session during a temporary ban
+func (s *WhatsAppService) checkNotBanned(session *models.Session) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session.BannedUntil != nil && time.Now().Before(*session.BannedUntil) {
+		return models.NewSessionBannedError(session.ID, *session.BannedUntil, session.BanReason)
+	}
+	return nil
+}
+
+// sendFailure converts a send error into the error returned to callers,
+// surfacing WhatsApp rate limiting as a typed error. The API's own errors,
+// such as an unknown broadcast list, pass through.
+func (s *WhatsAppService) sendFailure(session *models.Session, action string, err error) error {
+	switch err.(type) {
+	case models.BadRequestError, models.NotFoundError, models.ServiceUnavailableError:
+		return err
+	}
+	if isRateLimitError(err) {
+		s.logger.Warn("WhatsApp rate-limited session %s: %v", session.ID, err)
+		return models.NewRateLimitedError("WhatsApp rate limit reached for session %s, retry later", session.ID)
+	}
+	return fmt.Errorf("%s: %v", action, err)
+}
+
+// isRateLimitError reports whether WhatsApp rejected a request with a rate limit
+func isRateLimitError(err error) bool {
+	if errors.Is(err, whatsmeow.ErrIQRateOverLimit) {
+		return true
+	}
+	return errors.Is(err, whatsmeow.ErrServerReturnedError) && strings.HasSuffix(err.Error(), " 429")
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// waitUntil polls cond until it holds, failing the test after timeout
+func waitUntil(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// jobSnapshot reads the fields of a running job under the service's lock
+func jobSnapshot(bulk *BulkMessagingService, job *BulkMessageJob) (status, reason string, resumeAt *time.Time, progress BulkMessageProgress) {
+	bulk.jobsMutex.RLock()
+	defer bulk.jobsMutex.RUnlock()
+	return job.Status, job.PausedReason, job.ResumeAt, job.Progress
+}
+
+// A temporary ban reported while a bulk job runs pauses the job, rejects
+// sends until it expires, then reconnects the session and resumes the job
+func TestTemporaryBanPausesAndResumesBulkJob(t *testing.T) {
+	service, sessionRepo, userID := newTestWhatsAppService(t)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := service.ConnectSession(session.ID); err != nil {
+		t.Fatalf("failed to connect session: %v", err)
+	}
+
+	bulk := NewBulkMessagingService(service, nil, nil, *newTestLogger())
+	t.Cleanup(func() { bulk.Shutdown(t.Context()) })
+	contacts := []models.Contact{
+		{Phone: "628111111111", Name: "Ani"},
+		{Phone: "628222222222", Name: "Budi"},
+		{Phone: "628333333333", Name: "Citra"},
+	}
+	job, err := bulk.StartBulkMessage(models.BulkMessageRequest{SessionID: session.ID, Message: "Hi {{name}}"}, nil, contacts, nil, userID)
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	waitUntil(t, 5*time.Second, "the first message", func() bool {
+		_, _, _, progress := jobSnapshot(bulk, job)
+		return progress.Sent == 1
+	})
+
+	// Banned during the delay before the second message
+	const banFor = 1500 * time.Millisecond
+	dispatch(session, &events.TemporaryBan{Code: events.TempBanSentToTooManyPeople, Expire: banFor})
+
+	until, banned := service.SessionBannedUntil(session.ID)
+	if !banned {
+		t.Fatal("session is not banned after the ban event")
+	}
+	if state := session.State(); state.Connected || state.LastDisconnectReason == "" {
+		t.Errorf("state = %+v, want disconnected with the ban as reason", state)
+	}
+	stored, err := sessionRepo.GetByID(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.BannedUntil == nil || stored.BannedUntil.Unix() != until.Unix() || stored.BanReason == "" {
+		t.Errorf("stored ban = %v, %q; want until %v with a reason", stored.BannedUntil, stored.BanReason, until)
+	}
+	var bannedErr models.SessionBannedError
+	if _, err := service.SendMessage(session.ID, &models.SendMessageRequest{To: "628444444444", Message: "hi"}); !errors.As(err, &bannedErr) {
+		t.Errorf("send during the ban = %v, want a session banned error", err)
+	}
+
+	waitUntil(t, 5*time.Second, "the job to pause", func() bool {
+		status, _, _, _ := jobSnapshot(bulk, job)
+		return status == "paused"
+	})
+	status, reason, resumeAt, progress := jobSnapshot(bulk, job)
+	if reason != PausedReasonSessionBanned || resumeAt == nil || !resumeAt.Equal(until) {
+		t.Errorf("job paused with reason %q until %v, want %q until %v", reason, resumeAt, PausedReasonSessionBanned, until)
+	}
+	if progress.Sent != 1 || progress.Failed != 0 {
+		t.Errorf("progress while banned = %+v, want one sent and none failed", progress)
+	}
+
+	waitUntil(t, banFor+5*time.Second, "the ban to lift", func() bool {
+		_, banned := service.SessionBannedUntil(session.ID)
+		return !banned && session.IsConnected()
+	})
+	stored, err = sessionRepo.GetByID(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.BannedUntil != nil || stored.BanReason != "" {
+		t.Errorf("stored ban = %v, %q after it lifted, want it cleared", stored.BannedUntil, stored.BanReason)
+	}
+
+	// Stands in for the job's next poll of the ban
+	job.signal()
+	waitUntil(t, 10*time.Second, "the job to complete", func() bool {
+		status, _, _, _ := jobSnapshot(bulk, job)
+		return status == "completed"
+	})
+	status, reason, resumeAt, progress = jobSnapshot(bulk, job)
+	if reason != "" || resumeAt != nil {
+		t.Errorf("job %s with reason %q until %v, want the pause cleared", status, reason, resumeAt)
+	}
+	if progress.Sent != len(contacts) || progress.Failed != 0 || progress.Remaining != 0 {
+		t.Errorf("progress = %+v, want every message sent", progress)
+	}
+}
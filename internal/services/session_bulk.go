@@ -0,0 +1,311 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// sessionBulkWorkers bounds how many sessions a bulk action works on at once
+const sessionBulkWorkers = 8
+
+// sessionBulkConnectTimeout is how long a bulk connect waits for each
+// session to log in
+const sessionBulkConnectTimeout = 30 * time.Second
+
+// sessionBulkJobTTL is how long finished bulk jobs can still be polled
+const sessionBulkJobTTL = time.Hour
+
+// StartSessionBulk applies an action to many sessions. Users other than
+// admins may only target their own sessions; sessions that cannot be targeted
+// fail in the results instead of failing the request. Connects run in the
+// background and the job is returned at once; other actions return it done.
+func (s *WhatsAppService) StartSessionBulk(userID int, admin bool, req *models.SessionBulkRequest) (*models.SessionBulkJob, error) {
+	switch req.Action {
+	case models.SessionBulkConnect, models.SessionBulkDisconnect, models.SessionBulkEnable,
+		models.SessionBulkDisable, models.SessionBulkDelete:
+	default:
+		return nil, models.NewBadRequestError("action must be %s, %s, %s, %s or %s", models.SessionBulkConnect,
+			models.SessionBulkDisconnect, models.SessionBulkEnable, models.SessionBulkDisable, models.SessionBulkDelete)
+	}
+	if (len(req.SessionIDs) == 0) == (req.Tag == "") {
+		return nil, models.NewBadRequestError("either session_ids or tag is required")
+	}
+	if len(req.SessionIDs) > models.MaxSessionBulkTargets {
+		return nil, models.NewBadRequestError("at most %d sessions can be named at once", models.MaxSessionBulkTargets)
+	}
+
+	targets, rejected, err := s.sessionBulkTargets(userID, admin, req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := s.sessionBulk.start(userID, req.Action, len(targets)+len(rejected))
+	for _, result := range rejected {
+		s.sessionBulk.finish(entry, result.SessionID, errors.New(result.Error))
+	}
+
+	s.logger.Info("User %d started bulk %s %s of %d sessions", userID, req.Action, entry.job.ID, len(targets))
+	if req.Action == models.SessionBulkConnect {
+		go s.runSessionBulk(entry, req, targets)
+		return s.sessionBulk.snapshot(entry), nil
+	}
+	s.runSessionBulk(entry, req, targets)
+	return s.sessionBulk.snapshot(entry), nil
+}
+
+// GetSessionBulkJob returns the progress of a bulk action, which only its
+// starter and admins may see
+func (s *WhatsAppService) GetSessionBulkJob(jobID string, userID int, admin bool) (*models.SessionBulkJob, error) {
+	job, owner, ok := s.sessionBulk.get(jobID)
+	if !ok || (!admin && owner != userID) {
+		return nil, models.NewNotFoundError("bulk job %s not found", jobID)
+	}
+	return job, nil
+}
+
+// sessionBulkTargets resolves the sessions of a bulk action, along with
+// failed results for named sessions the user may not target
+func (s *WhatsAppService) sessionBulkTargets(userID int, admin bool, req *models.SessionBulkRequest) ([]string, []*models.SessionBulkResult, error) {
+	if req.Tag != "" {
+		sessions := s.GetAllSessions()
+		if !admin {
+			var err error
+			if sessions, err = s.GetSessionsByUserID(userID); err != nil {
+				return nil, nil, err
+			}
+		}
+		var targets []string
+		for _, session := range sessions {
+			if hasTags(session, []string{req.Tag}) {
+				targets = append(targets, session.ID)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, nil, models.NewNotFoundError("no sessions are tagged %q", req.Tag)
+		}
+		return targets, nil, nil
+	}
+
+	var targets []string
+	var rejected []*models.SessionBulkResult
+	seen := make(map[string]bool, len(req.SessionIDs))
+	for _, sessionID := range req.SessionIDs {
+		sessionID = strings.TrimSpace(sessionID)
+		if sessionID == "" || seen[sessionID] {
+			continue
+		}
+		seen[sessionID] = true
+
+		if _, exists := s.GetSession(sessionID); !exists {
+			rejected = append(rejected, &models.SessionBulkResult{SessionID: sessionID, Error: "session not found"})
+			continue
+		}
+		if !admin {
+			owned, err := s.IsSessionOwnedByUser(sessionID, userID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !owned {
+				rejected = append(rejected, &models.SessionBulkResult{SessionID: sessionID, Error: "access denied: session not owned by user"})
+				continue
+			}
+		}
+		targets = append(targets, sessionID)
+	}
+	return targets, rejected, nil
+}
+
+// runSessionBulk applies the action to the sessions with a bounded pool of workers
+func (s *WhatsAppService) runSessionBulk(entry *sessionBulkJob, req *models.SessionBulkRequest, sessionIDs []string) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(sessionBulkWorkers, len(sessionIDs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sessionID := range queue {
+				err := s.sessionBulkAction(req, sessionID)
+				if err != nil {
+					s.logger.Warn("Bulk %s %s failed for session %s: %v", req.Action, entry.job.ID, sessionID, err)
+				}
+				s.sessionBulk.finish(entry, sessionID, err)
+			}
+		}()
+	}
+	for _, sessionID := range sessionIDs {
+		queue <- sessionID
+	}
+	close(queue)
+	wg.Wait()
+
+	job := s.sessionBulk.complete(entry)
+	s.logger.Info("Bulk %s %s completed: %d succeeded, %d failed", req.Action, job.ID, job.Succeeded, job.Failed)
+}
+
+// sessionBulkAction applies a bulk action to one session
+func (s *WhatsAppService) sessionBulkAction(req *models.SessionBulkRequest, sessionID string) error {
+	switch req.Action {
+	case models.SessionBulkConnect:
+		return s.connectAndWait(sessionID)
+	case models.SessionBulkDisconnect:
+		return s.DisconnectSession(sessionID)
+	case models.SessionBulkEnable:
+		return s.UpdateSessionEnabled(sessionID, true)
+	case models.SessionBulkDisable:
+		return s.UpdateSessionEnabled(sessionID, false)
+	case models.SessionBulkDelete:
+		return s.DeleteSession(sessionID, req.PurgeData)
+	}
+	return models.NewBadRequestError("unknown action %s", req.Action)
+}
+
+// connectAndWait connects a session and waits until it is logged in. Sessions
+// already connected succeed; sessions without a linked device fail, as only a
+// QR scan or pairing code can log them in.
+func (s *WhatsAppService) connectAndWait(sessionID string) error {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+	switch session.AuthState() {
+	case models.AuthStateConnected:
+		return nil
+	case models.AuthStateNeedsAuth:
+		return models.NewBadRequestError("session %s has no linked device; log in with a QR code or pairing code", sessionID)
+	}
+
+	if err := s.ConnectSession(sessionID); err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(sessionBulkConnectTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return fmt.Errorf("server is shutting down")
+		case <-timeout.C:
+			return fmt.Errorf("session %s did not connect within %s", sessionID, sessionBulkConnectTimeout)
+		case <-ticker.C:
+			state := session.State()
+			if state.Connected && state.LoggedIn {
+				return nil
+			}
+			if !state.Connecting {
+				reason := state.LastDisconnectReason
+				if reason == "" {
+					reason = "connection failed"
+				}
+				return fmt.Errorf("session %s did not connect: %s", sessionID, reason)
+			}
+		}
+	}
+}
+
+// sessionBulkJob is a bulk action tracked by sessionBulkJobs
+type sessionBulkJob struct {
+	userID      int
+	job         models.SessionBulkJob
+	completedAt time.Time
+}
+
+// sessionBulkJobs keeps bulk actions for polling until sessionBulkJobTTL
+// after they complete
+type sessionBulkJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*sessionBulkJob
+}
+
+// start tracks a new bulk action, forgetting expired ones
+func (t *sessionBulkJobs) start(userID int, action string, total int) *sessionBulkJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.jobs == nil {
+		t.jobs = make(map[string]*sessionBulkJob)
+	}
+	for id, entry := range t.jobs {
+		if !entry.completedAt.IsZero() && now.Sub(entry.completedAt) > sessionBulkJobTTL {
+			delete(t.jobs, id)
+		}
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	entry := &sessionBulkJob{
+		userID: userID,
+		job: models.SessionBulkJob{
+			ID:        "sessions_" + hex.EncodeToString(id),
+			Action:    action,
+			Status:    models.SessionBulkRunning,
+			Total:     total,
+			Results:   []*models.SessionBulkResult{},
+			CreatedAt: models.FormatTimestamp(now),
+		},
+	}
+	t.jobs[entry.job.ID] = entry
+	return entry
+}
+
+// finish records the outcome of the action for one session
+func (t *sessionBulkJobs) finish(entry *sessionBulkJob, sessionID string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := &models.SessionBulkResult{SessionID: sessionID, OK: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+		entry.job.Failed++
+	} else {
+		entry.job.Succeeded++
+	}
+	entry.job.Done++
+	entry.job.Results = append(entry.job.Results, result)
+}
+
+// complete marks the action done and returns its final state
+func (t *sessionBulkJobs) complete(entry *sessionBulkJob) *models.SessionBulkJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry.completedAt = time.Now()
+	entry.job.Status = models.SessionBulkCompleted
+	entry.job.CompletedAt = models.FormatTimestamp(entry.completedAt)
+	return entry.copy()
+}
+
+// snapshot returns the current state of a bulk action
+func (t *sessionBulkJobs) snapshot(entry *sessionBulkJob) *models.SessionBulkJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return entry.copy()
+}
+
+// get returns the current state of a bulk action and the user who started it
+func (t *sessionBulkJobs) get(jobID string) (*models.SessionBulkJob, int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.jobs[jobID]
+	if !ok || (!entry.completedAt.IsZero() && time.Since(entry.completedAt) > sessionBulkJobTTL) {
+		return nil, 0, false
+	}
+	return entry.copy(), entry.userID, true
+}
+
+// copy returns the job with its own results slice; callers hold the lock
+func (entry *sessionBulkJob) copy() *models.SessionBulkJob {
+	job := entry.job
+	job.Results = append([]*models.SessionBulkResult{}, entry.job.Results...)
+	return &job
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// deviceUnlinkTimeout bounds the logout and device store cleanup of a deleted
+// session
+const deviceUnlinkTimeout = 10 * time.Second
+
+// unlinkDevice logs the device of a deleted session out, so the phone drops it
+// from its linked devices, and deletes its identity keys, prekeys and app
+// state from the device store. The logout is best effort: a device that cannot
+// reach WhatsApp is still wiped locally and disappears from the phone once
+// WhatsApp expires it.
+func (s *WhatsAppService) unlinkDevice(session *models.Session) {
+	if session.Sandbox || session.Client == nil {
+		// Sandbox devices are never saved to the device store
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deviceUnlinkTimeout)
+	defer cancel()
+
+	client := session.Client
+	if client.Store.ID != nil && client.IsConnected() && client.IsLoggedIn() {
+		// Logout deletes the device from the store itself
+		err := client.Logout(ctx)
+		if err == nil {
+			s.logger.Info("Device of session %s logged out", session.ID)
+			return
+		}
+		s.logger.Warn("Failed to log out device of session %s, wiping it locally: %v", session.ID, err)
+	}
+
+	client.Disconnect()
+	if client.Store.ID == nil {
+		// Never paired, so nothing was saved
+		return
+	}
+	if err := client.Store.Delete(ctx); err != nil {
+		s.logger.Error("Failed to delete device of session %s from the device store: %v", session.ID, err)
+		return
+	}
+	s.logger.Info("Device of session %s deleted from the device store", session.ID)
+}
+
+// purgeSessionData deletes the stored media and logged messages of a deleted
+// session
+func (s *WhatsAppService) purgeSessionData(sessionID string) {
+	if s.messageRepo == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	keys, err := s.messageRepo.GetMediaKeys(sessionID)
+	if err != nil {
+		s.logger.Error("Failed to list media of session %s: %v", sessionID, err)
+	}
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			s.logger.Warn("Failed to delete media file %s of session %s: %v", key, sessionID, err)
+		}
+	}
+
+	deleted, err := s.messageRepo.DeleteBySession(sessionID)
+	if err != nil {
+		s.logger.Error("%v", err)
+		return
+	}
+	s.logger.Info("Purged %d message(s) and %d media file(s) of session %s", deleted, len(keys), sessionID)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// storeRows counts the rows of every whatsmeow table in the service's device
+// store
+func storeRows(t *testing.T, service *WhatsAppService) map[string]int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+service.storePath+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'whatsmeow_%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if len(tables) == 0 {
+		t.Fatal("device store has no whatsmeow tables")
+	}
+
+	counts := make(map[string]int, len(tables))
+	for _, table := range tables {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		counts[table] = count
+	}
+	return counts
+}
+
+// pairDevice saves the session's device to the device store as pairing does,
+// with the keys, sessions and contacts a paired device collects
+func pairDevice(t *testing.T, session *models.Session, jid types.JID) {
+	t.Helper()
+	ctx := context.Background()
+	device := session.Client.Store
+	device.ID = &jid
+	device.Account = &waAdv.ADVSignedDeviceIdentity{Details: []byte("details"), AccountSignature: make([]byte, 64),
+		AccountSignatureKey: make([]byte, 32), DeviceSignature: make([]byte, 64)}
+	if err := device.Save(ctx); err != nil {
+		t.Fatalf("failed to save device: %v", err)
+	}
+	if _, err := device.PreKeys.GetOrGenPreKeys(ctx, 10); err != nil {
+		t.Fatalf("failed to generate prekeys: %v", err)
+	}
+	if err := device.Identities.PutIdentity(ctx, "628123456789.0:0", [32]byte{1}); err != nil {
+		t.Fatalf("failed to save identity: %v", err)
+	}
+	if err := device.Sessions.PutSession(ctx, "628123456789.0:0", []byte("session")); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if err := device.AppStateKeys.PutAppStateSyncKey(ctx, []byte("key"), store.AppStateSyncKey{Data: []byte("data"), Fingerprint: []byte("fingerprint"), Timestamp: time.Now().Unix()}); err != nil {
+		t.Fatalf("failed to save app state key: %v", err)
+	}
+	if _, _, err := device.Contacts.PutPushName(ctx, types.NewJID("628123456789", types.DefaultUserServer), "Ani"); err != nil {
+		t.Fatalf("failed to save push name: %v", err)
+	}
+}
+
+// Deleting sessions removes their devices from the device store, so creating
+// and deleting many sessions leaves it as it was
+func TestDeleteSessionWipesDeviceStore(t *testing.T) {
+	service, sessionRepo, userID := newTestWhatsAppService(t)
+	before := storeRows(t, service)
+
+	for i := 0; i < 25; i++ {
+		session, err := service.CreateSession(&models.CreateSessionRequest{Name: fmt.Sprintf("Shop %d", i)}, userID, models.RoleUser)
+		if err != nil {
+			t.Fatalf("failed to create session %d: %v", i, err)
+		}
+		pairDevice(t, session, types.NewADJID(fmt.Sprintf("62812%07d", i), 0, 1))
+		if i == 0 {
+			paired := storeRows(t, service)
+			for _, table := range []string{"whatsmeow_device", "whatsmeow_pre_keys", "whatsmeow_identity_keys", "whatsmeow_sessions"} {
+				if paired[table] <= before[table] {
+					t.Fatalf("pairing added no rows to %s", table)
+				}
+			}
+		}
+
+		// Never connected, so the device is wiped locally without a logout
+		if err := service.DeleteSession(session.ID, false); err != nil {
+			t.Fatalf("failed to delete session %d: %v", i, err)
+		}
+		if stored, err := sessionRepo.GetByID(session.ID); err != nil || stored != nil {
+			t.Errorf("session %d after deleting = %v, %v; want none stored", i, stored, err)
+		}
+	}
+
+	after := storeRows(t, service)
+	for table, count := range after {
+		if count != before[table] {
+			t.Errorf("%s has %d rows after deleting every session, want %d", table, count, before[table])
+		}
+	}
+	devices, err := service.store.GetAllDevices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("%d devices left in the store", len(devices))
+	}
+}
+
+// purge_data deletes a session's stored media along with its logged messages,
+// and only that session's. The messages of a deleted session go with it
+// through the foreign key either way.
+func TestDeleteSessionPurgesData(t *testing.T) {
+	service, _, userID := newTestWhatsAppService(t)
+	ctx := context.Background()
+
+	logWithMedia := func(session *models.Session, messageID string) string {
+		t.Helper()
+		message := &repository.Message{SessionID: session.ID, MessageID: messageID, SenderJID: "628123456789@s.whatsapp.net",
+			RecipientJID: session.ActualPhone, MessageType: "image", Direction: "received", Status: "received", CreatedAt: time.Now()}
+		if err := service.messageRepo.LogMessage(message); err != nil {
+			t.Fatalf("failed to log message: %v", err)
+		}
+		key := "media/" + session.ID + "/" + messageID + ".jpg"
+		if err := service.storage.Put(ctx, key, []byte("image"), "image/jpeg"); err != nil {
+			t.Fatalf("failed to store media: %v", err)
+		}
+		if err := service.messageRepo.SetMediaKey(session.ID, messageID, key); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	var sessions []*models.Session
+	for _, name := range []string{"Purged", "Kept", "Other"} {
+		session, err := service.CreateSession(&models.CreateSessionRequest{Name: name, Sandbox: true}, userID, models.RoleUser)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	purged, kept, other := sessions[0], sessions[1], sessions[2]
+	purgedKeys := []string{logWithMedia(purged, "MSG1"), logWithMedia(purged, "MSG2")}
+	keptKey := logWithMedia(kept, "MSG3")
+	otherKey := logWithMedia(other, "MSG4")
+
+	if err := service.DeleteSession(purged.ID, true); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if err := service.DeleteSession(kept.ID, false); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		messageID string
+		keys      []string
+		logged    bool
+		stored    bool
+	}{
+		{"purged session", purged.ID, "MSG1", purgedKeys, false, false},
+		{"deleted without purging", kept.ID, "MSG3", []string{keptKey}, false, true},
+		{"other session", other.ID, "MSG4", []string{otherKey}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := service.messageRepo.GetMessageByID(tt.sessionID, tt.messageID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged := message != nil; logged != tt.logged {
+				t.Errorf("message logged = %v, want %v", logged, tt.logged)
+			}
+			for _, key := range tt.keys {
+				_, err := service.storage.Stat(ctx, key)
+				if stored := err == nil; stored != tt.stored {
+					t.Errorf("media %s stored = %v, want %v", key, stored, tt.stored)
+				}
+			}
+		})
+	}
+	if keys, err := service.messageRepo.GetMediaKeys(purged.ID); err != nil || len(keys) != 0 {
+		t.Errorf("media keys of the purged session = %v, %v; want none", keys, err)
+	}
+}
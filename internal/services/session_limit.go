@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+// SetUserRepository enables per-user session limits; without it every
+// non-admin user gets DefaultSessionLimit
+func (s *WhatsAppService) SetUserRepository(users *repository.UserRepository) {
+	s.users = users
+}
+
+// resolveSessionOwner returns the user a new session is created for: the
+// caller, or the user an admin creates it on behalf of
+func (s *WhatsAppService) resolveSessionOwner(req *models.CreateSessionRequest, userID int, userRole string) (*models.User, error) {
+	ownerID := userID
+	if req.UserID != 0 && req.UserID != userID {
+		if userRole != models.RoleAdmin {
+			return nil, models.NewForbiddenError("only admins can create sessions for other users")
+		}
+		ownerID = req.UserID
+	}
+
+	if s.users == nil {
+		owner := &models.User{ID: ownerID, Role: models.RoleUser, SessionLimit: models.DefaultSessionLimit}
+		if ownerID == userID {
+			owner.Role = userRole
+		}
+		return owner, nil
+	}
+
+	owner, err := s.users.GetByID(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %v", ownerID, err)
+	}
+	if owner == nil {
+		return nil, models.NewBadRequestError("user %d not found", ownerID)
+	}
+	return owner, nil
+}
+
+// isAdmin reports whether userID is an admin; without a user repository no
+// one is
+func (s *WhatsAppService) isAdmin(userID int) (bool, error) {
+	if s.users == nil {
+		return false, nil
+	}
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user %d: %v", userID, err)
+	}
+	return user != nil && user.Role == models.RoleAdmin, nil
+}
+
+// checkSessionLimit fails with a SessionLimitError when the owner already has
+// as many sessions as their limit allows. Admins and a limit of 0 (or the -1
+// the dashboard used to set) are unlimited. The caller holds s.mu so that
+// concurrent creates cannot both pass.
+func (s *WhatsAppService) checkSessionLimit(owner *models.User) error {
+	if owner.Role == models.RoleAdmin || owner.SessionLimit <= 0 {
+		return nil
+	}
+
+	count, err := s.sessionRepo.CountByUserID(owner.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check session count: %v", err)
+	}
+	if count >= owner.SessionLimit {
+		return models.NewSessionLimitError(count, owner.SessionLimit)
+	}
+	return nil
+}
+
+// GetSessionOwners returns the owner of every session, or only of the sessions
+// of userID when it is not 0
+func (s *WhatsAppService) GetSessionOwners(userID int) ([]*models.SessionOwner, error) {
+	return s.sessionRepo.GetOwners(userID)
+}
+
+// ReassignSession moves a session to another user, who needs room for it
+// under their session limit, and returns the session and its new owner
+func (s *WhatsAppService) ReassignSession(sessionID string, userID int) (*models.Session, *models.User, error) {
+	if s.users == nil {
+		return nil, nil, models.NewServiceUnavailableError("user accounts are not available")
+	}
+	owner, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user %d: %v", userID, err)
+	}
+	if owner == nil {
+		return nil, nil, models.NewBadRequestError("user %d not found", userID)
+	}
+	if !owner.IsActive {
+		return nil, nil, models.NewBadRequestError("user %s is deactivated", owner.Username)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+	previous := session.UserID
+	if previous == userID {
+		return nil, nil, models.NewBadRequestError("session %s already belongs to %s", sessionID, owner.Username)
+	}
+	if !session.Sandbox {
+		if err := s.checkSessionLimit(owner); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := s.sessionRepo.UpdateUserID(sessionID, userID); err != nil {
+		return nil, nil, err
+	}
+	session.UserID = userID
+
+	s.logger.Info("Session %s reassigned from user %d to user %d (%s)", sessionID, previous, userID, owner.Username)
+	return session, owner, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"golang.org/x/net/proxy"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// proxyVerifyURL answers with the caller's public IP address as plain text
+const proxyVerifyURL = "https://api.ipify.org"
+
+// proxyWhatsAppURL is the WhatsApp Web server sessions connect to
+const proxyWhatsAppURL = "https://web.whatsapp.com"
+
+// proxyResolveTimeout bounds the DNS lookup of a proxy host
+const proxyResolveTimeout = 5 * time.Second
+
+// ValidateProxy checks a proxy configuration and that its host resolves
+func (s *WhatsAppService) ValidateProxy(ctx context.Context, config *models.ProxyConfig) error {
+	if err := config.Validate(); err != nil {
+		return models.NewBadRequestError("invalid proxy: %v", err)
+	}
+	if config == nil || !config.Enabled || net.ParseIP(config.Host) != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proxyResolveTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, config.Host); err != nil {
+		return models.NewBadRequestError("proxy host %s does not resolve: %v", config.Host, err)
+	}
+	return nil
+}
+
+// VerifyProxy fetches the public IP through the proxy, measuring how long it took.
+// The deadline of ctx bounds the test.
+func (s *WhatsAppService) VerifyProxy(ctx context.Context, config *models.ProxyConfig) (*models.ProxyVerification, error) {
+	transport, err := proxyTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proxyVerifyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IP lookup through proxy returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return nil, err
+	}
+	exitIP := strings.TrimSpace(string(body))
+	if net.ParseIP(exitIP) == nil {
+		return nil, fmt.Errorf("IP lookup through proxy returned %q", exitIP)
+	}
+
+	return &models.ProxyVerification{LatencyMS: latency.Milliseconds(), ExitIP: exitIP}, nil
+}
+
+// TestProxyWhatsApp opens an HTTPS connection to the WhatsApp Web server
+// through the proxy and returns how long it took to get an answer. Any HTTP
+// response means the proxy reaches WhatsApp. The deadline of ctx bounds the test.
+func (s *WhatsAppService) TestProxyWhatsApp(ctx context.Context, config *models.ProxyConfig) (time.Duration, error) {
+	transport, err := proxyTransport(config)
+	if err != nil {
+		return 0, err
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, proxyWhatsAppURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
+
+// proxyTransport returns an HTTP transport whose connections go through the proxy
+func proxyTransport(config *models.ProxyConfig) (*http.Transport, error) {
+	transport := &http.Transport{}
+	proxyURL := config.URL()
+	if config.Type == models.ProxyTypeSOCKS5 {
+		dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+		}
+		transport.DialContext = contextDialer.DialContext
+	} else {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+// UpdateSessionProxy stores a session's proxy and applies it to the live
+// client. A connected session is reconnected so the change, including
+// disabling the proxy, takes effect immediately; reconnecting reports whether
+// that happened. The configuration must have been validated first.
+func (s *WhatsAppService) UpdateSessionProxy(sessionID string, config *models.ProxyConfig) (reconnecting bool, err error) {
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mu.RUnlock()
+	if !exists {
+		return false, models.NewNotFoundError("session %s not found", sessionID)
+	}
+
+	if err := s.sessionRepo.UpdateProxyConfig(sessionID, config); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	session.ProxyConfig = config
+	s.mu.Unlock()
+
+	return s.reapplyProxy(session)
+}
+
+// reapplyProxy applies the session's current proxy to its client, which only
+// takes effect on the next connection, so a connected session is
+// disconnected and connected again. reconnecting reports whether it was.
+func (s *WhatsAppService) reapplyProxy(session *models.Session) (reconnecting bool, err error) {
+	s.mu.Lock()
+	config := session.ProxyConfig
+	client := session.Client
+	previous := session.SetDisconnected()
+	connected := previous.Connected || previous.Connecting
+	session.ProxyStatus = initialProxyStatus(config)
+	s.mu.Unlock()
+
+	if client == nil {
+		return false, nil
+	}
+
+	if connected {
+		client.Disconnect()
+	}
+	if err := applyProxy(client, config); err != nil {
+		s.mu.Lock()
+		session.ProxyStatus = models.ProxyStatusFailed
+		s.mu.Unlock()
+		return false, models.NewBadRequestError("failed to apply proxy: %v", err)
+	}
+	if !connected {
+		return false, nil
+	}
+
+	s.logger.Info("Reconnecting session %s to apply proxy change", session.ID)
+	if err := s.ConnectSession(session.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// applyProxy routes the client's WhatsApp and media connections through the
+// proxy, or directly when it is nil or disabled. The client must be disconnected.
+func applyProxy(client *whatsmeow.Client, config *models.ProxyConfig) error {
+	if config == nil || !config.Enabled {
+		return client.SetProxyAddress("")
+	}
+	return client.SetProxyAddress(config.URL().String())
+}
+
+// initialProxyStatus is the proxy status of a client that has not connected
+// since config was applied to it
+func initialProxyStatus(config *models.ProxyConfig) string {
+	if config == nil || !config.Enabled {
+		return models.ProxyStatusDirect
+	}
+	return models.ProxyStatusPending
+}
+
+// recordProxyConnected records that the session connected, through its proxy
+// if it has one. The caller holds s.mu.
+func recordProxyConnected(session *models.Session) {
+	if session.ProxyConfig != nil && session.ProxyConfig.Enabled {
+		session.ProxyStatus = models.ProxyStatusActive
+	}
+}
+
+// recordProxyFailure records that the session failed to connect through its
+// proxy, if it has one. The caller holds s.mu.
+func recordProxyFailure(session *models.Session) {
+	if session.ProxyConfig != nil && session.ProxyConfig.Enabled {
+		session.ProxyStatus = models.ProxyStatusFailed
+	}
+}
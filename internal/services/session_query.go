@@ -0,0 +1,156 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// UpdateSessionTags replaces the tags of a session and returns them as stored.
+// Tags are trimmed and compared case-insensitively; duplicates are dropped.
+func (s *WhatsAppService) UpdateSessionTags(sessionID string, tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, models.NewBadRequestError("tags must not be empty")
+		}
+		if len(tag) > models.MaxSessionTagLength {
+			return nil, models.NewBadRequestError("tag %q is longer than %d characters", tag, models.MaxSessionTagLength)
+		}
+		if key := strings.ToLower(tag); !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > models.MaxSessionTags {
+		return nil, models.NewBadRequestError("a session can have at most %d tags", models.MaxSessionTags)
+	}
+
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, models.NewNotFoundError("session %s not found", sessionID)
+	}
+	session.Tags = normalized
+	s.mu.Unlock()
+
+	if err := s.sessionRepo.UpdateTags(sessionID, normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// QuerySessions filters and orders sessions for the session list and returns
+// the requested page along with the number of sessions on every page
+func QuerySessions(sessions []*models.Session, query *models.SessionQuery) ([]*models.Session, int, error) {
+	switch query.Sort {
+	case "":
+		query.Sort = models.SessionSortPosition
+	case models.SessionSortPosition, models.SessionSortName, models.SessionSortCreatedAt, models.SessionSortLastConnected:
+	default:
+		return nil, 0, models.NewBadRequestError("sort must be %s, %s, %s or %s",
+			models.SessionSortPosition, models.SessionSortName, models.SessionSortCreatedAt, models.SessionSortLastConnected)
+	}
+	switch query.State {
+	case "", models.AuthStateConnected, models.AuthStateLoggedIn, models.AuthStateNeedsAuth:
+	default:
+		return nil, 0, models.NewBadRequestError("state must be %s, %s or %s",
+			models.AuthStateConnected, models.AuthStateLoggedIn, models.AuthStateNeedsAuth)
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	search := strings.ToLower(strings.TrimSpace(query.Search))
+	matched := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if query.Enabled != nil && session.Enabled != *query.Enabled {
+			continue
+		}
+		if query.State != "" && session.AuthState() != query.State {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(session.Name), search) {
+			continue
+		}
+		if !hasTags(session, query.Tags) {
+			continue
+		}
+		matched = append(matched, session)
+	}
+	sortSessions(matched, query.Sort)
+
+	total := len(matched)
+	if query.Limit > 0 {
+		start := (query.Page - 1) * query.Limit
+		if start > total {
+			start = total
+		}
+		end := start + query.Limit
+		if end > total {
+			end = total
+		}
+		matched = matched[start:end]
+	}
+	return matched, total, nil
+}
+
+// hasTags reports whether the session has every one of the tags
+func hasTags(session *models.Session, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, own := range session.Tags {
+			if strings.EqualFold(own, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSessions orders sessions for the session list; ties are ordered by ID
+// so pages stay stable
+func sortSessions(sessions []*models.Session, order string) {
+	lastConnected := make(map[string]int64, len(sessions))
+	if order == models.SessionSortLastConnected {
+		for _, session := range sessions {
+			if at := session.State().LastConnectedAt; !at.IsZero() {
+				lastConnected[session.ID] = at.UnixNano()
+			}
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		switch order {
+		case models.SessionSortName:
+			if an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name); an != bn {
+				return an < bn
+			}
+		case models.SessionSortCreatedAt:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+		case models.SessionSortLastConnected:
+			if lastConnected[a.ID] != lastConnected[b.ID] {
+				return lastConnected[a.ID] > lastConnected[b.ID]
+			}
+		default:
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+		}
+		return a.ID < b.ID
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// Lifecycle changes of a session (connected, disconnected, logged in or out,
+// stream errors and expired QR codes) are published to the WebSocket
+// connections attached to it, so dashboards need not poll the session, and
+// sent to its webhook when its webhook_events list session_status.
+
+// sessionStatusBuffer is how many events a subscriber may fall behind before
+// the oldest are dropped
+const sessionStatusBuffer = 16
+
+// sessionStatusHub fans session status events out to the WebSocket
+// connections of a session
+type sessionStatusHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *models.SessionStatusEvent]struct{}
+}
+
+func (h *sessionStatusHub) subscribe(sessionID string) (<-chan *models.SessionStatusEvent, func()) {
+	ch := make(chan *models.SessionStatusEvent, sessionStatusBuffer)
+
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[string]map[chan *models.SessionStatusEvent]struct{})
+	}
+	if h.subscribers[sessionID] == nil {
+		h.subscribers[sessionID] = make(map[chan *models.SessionStatusEvent]struct{})
+	}
+	h.subscribers[sessionID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[sessionID], ch)
+			if len(h.subscribers[sessionID]) == 0 {
+				delete(h.subscribers, sessionID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *sessionStatusHub) publish(sessionID string, event *models.SessionStatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[sessionID] {
+		// A subscriber that stopped reading loses its oldest events, never
+		// blocking the event handler publishing
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+// SubscribeSessionStatus returns a channel receiving the status events of the
+// session, and a function to stop receiving them
+func (s *WhatsAppService) SubscribeSessionStatus(sessionID string) (<-chan *models.SessionStatusEvent, func()) {
+	return s.statuses.subscribe(sessionID)
+}
+
+// publishSessionStatus reports a lifecycle change of a session, with the
+// session's connection state after it, to its subscribers and webhook
+func (s *WhatsAppService) publishSessionStatus(session *models.Session, status, reason string) {
+	state := session.State()
+	event := &models.SessionStatusEvent{
+		Type:      "session_status",
+		Status:    status,
+		SessionID: session.ID,
+		Reason:    reason,
+		Connected: state.Connected,
+		LoggedIn:  state.LoggedIn,
+		Timestamp: models.FormatTimestamp(time.Now()),
+	}
+	s.statuses.publish(session.ID, event)
+
+	if session.WebhookURL != "" && session.WebhookEventEnabled("session_status", false) {
+		s.goBackground(func() {
+			s.deliverWebhook(session, "session_status", event)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// On shutdown the clients are disconnected first, so no new events arrive.
+// Webhook sends and message logging already started then get until the
+// shutdown deadline to finish, the webhook queue stops after the delivery it
+// is sending, and whatever still runs at the deadline is cancelled. Undelivered
+// webhooks stay queued and are sent after the restart.
+
+// backgroundTasks tracks the event handling work Shutdown drains
+type backgroundTasks struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	running int
+	closed  bool
+
+	// ctx is cancelled when Shutdown gives up on the tasks still running
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// goBackground runs fn in a goroutine that Shutdown waits for. Once the
+// service is shutting down fn runs in the caller's goroutine instead.
+func (s *WhatsAppService) goBackground(fn func()) {
+	s.tasks.mu.Lock()
+	if s.tasks.closed {
+		s.tasks.mu.Unlock()
+		fn()
+		return
+	}
+	s.tasks.wg.Add(1)
+	s.tasks.running++
+	s.tasks.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.tasks.mu.Lock()
+			s.tasks.running--
+			s.tasks.mu.Unlock()
+			s.tasks.wg.Done()
+		}()
+		fn()
+	}()
+}
+
+// runningTasks returns how many background tasks are running
+func (s *WhatsAppService) runningTasks() int {
+	s.tasks.mu.Lock()
+	defer s.tasks.mu.Unlock()
+	return s.tasks.running
+}
+
+// Shutdown disconnects all sessions, stops connection attempts, reconnects
+// and ban timers, and waits until ctx is done for the background tasks and the
+// webhook queue. It returns how many tasks finished and how many were
+// cancelled at the deadline.
+func (s *WhatsAppService) Shutdown(ctx context.Context) (drained, abandoned int) {
+	// Connection attempts and delayed auto-connects give up
+	s.cancel()
+
+	s.mu.Lock()
+	for sessionID, timer := range s.banTimers {
+		timer.Stop()
+		delete(s.banTimers, sessionID)
+	}
+	for sessionID := range s.reconnectTimers {
+		s.stopReconnect(sessionID)
+	}
+	s.mu.Unlock()
+	s.Close()
+
+	s.tasks.mu.Lock()
+	s.tasks.closed = true
+	started := s.tasks.running
+	s.tasks.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.tasks.wg.Wait()
+		s.StopWebhookQueue()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("Shutdown deadline reached, cancelling %d background task(s) and the webhook queue", s.runningTasks())
+	}
+	abandoned = s.runningTasks()
+	s.tasks.cancel()
+
+	return started - abandoned, abandoned
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// statusLifetime is how long WhatsApp shows a status
+const statusLifetime = 24 * time.Hour
+
+// defaultStatusBackground is used for text statuses without a background color
+const defaultStatusBackground = 0xFF128C7E
+
+// PostStatus posts a text, image or video status to the session's status
+// audience, as configured in the WhatsApp privacy settings
+func (s *WhatsAppService) PostStatus(sessionID string, req *models.PostStatusRequest) (*models.StatusResponse, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return nil, err
+	}
+
+	var msg *waProto.Message
+	switch req.Type {
+	case "text":
+		msg, err = buildTextStatus(req)
+	case "image", "video":
+		msg, err = s.buildMediaStatus(session, req)
+	default:
+		err = models.NewBadRequestError("type must be text, image or video")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.sendMessage(session, types.StatusBroadcastJID, msg)
+	if err != nil {
+		return nil, s.statusFailure(session, "failed to post status", err)
+	}
+
+	s.logger.Info("Session %s posted %s status %s", sessionID, req.Type, resp.ID)
+	return &models.StatusResponse{
+		MessageID: resp.ID,
+		Type:      req.Type,
+		PostedAt:  models.FormatTimestamp(resp.Timestamp),
+		ExpiresAt: models.FormatTimestamp(resp.Timestamp.Add(statusLifetime)),
+	}, nil
+}
+
+// RevokeStatus deletes one of the session's statuses for everyone who can see it
+func (s *WhatsAppService) RevokeStatus(sessionID, messageID string) error {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if messageID == "" {
+		return models.NewBadRequestError("message ID is required")
+	}
+
+	if _, err := session.Client.RevokeMessage(context.Background(), types.StatusBroadcastJID, messageID); err != nil {
+		return s.statusFailure(session, "failed to delete status", err)
+	}
+
+	s.logger.Info("Session %s deleted status %s", sessionID, messageID)
+	return nil
+}
+
+// statusFailure explains errors caused by the account not being allowed to
+// post statuses, and otherwise behaves like sendFailure
+func (s *WhatsAppService) statusFailure(session *models.Session, action string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return models.NewUnauthorizedError("session is not authenticated")
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAcceptable):
+		return models.NewBadRequestError("this WhatsApp account is not permitted to post status updates: %v", err)
+	}
+	return s.sendFailure(session, action, err)
+}
+
+// buildTextStatus builds a text status with its background color, text color and font
+func buildTextStatus(req *models.PostStatusRequest) (*waProto.Message, error) {
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, models.NewBadRequestError("text is required for a text status")
+	}
+	if length := len([]rune(text)); length > models.StatusMaxTextLength {
+		return nil, models.NewBadRequestError("text is %d characters, a status allows at most %d", length, models.StatusMaxTextLength)
+	}
+
+	background := uint32(defaultStatusBackground)
+	if req.BackgroundColor != "" {
+		color, err := parseARGB(req.BackgroundColor)
+		if err != nil {
+			return nil, models.NewBadRequestError("invalid background_color: %v", err)
+		}
+		background = color
+	}
+
+	status := &waProto.ExtendedTextMessage{
+		Text:           proto.String(text),
+		BackgroundArgb: proto.Uint32(background),
+	}
+	if req.TextColor != "" {
+		color, err := parseARGB(req.TextColor)
+		if err != nil {
+			return nil, models.NewBadRequestError("invalid text_color: %v", err)
+		}
+		status.TextArgb = proto.Uint32(color)
+	}
+	if req.Font != "" {
+		font, ok := waProto.ExtendedTextMessage_FontType_value[strings.ToUpper(req.Font)]
+		if !ok {
+			return nil, models.NewBadRequestError("unknown font %q", req.Font)
+		}
+		status.Font = waProto.ExtendedTextMessage_FontType(font).Enum()
+	}
+
+	return &waProto.Message{ExtendedTextMessage: status}, nil
+}
+
+// parseARGB parses #RRGGBB (fully opaque) or #AARRGGBB
+func parseARGB(value string) (uint32, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return 0, errors.New("expected #RRGGBB or #AARRGGBB")
+	}
+	color, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, errors.New("expected #RRGGBB or #AARRGGBB")
+	}
+	if len(hex) == 6 {
+		color |= 0xFF000000
+	}
+	return uint32(color), nil
+}
+
+// buildMediaStatus checks the media against the status limits, uploads it
+// and builds the image or video status
+func (s *WhatsAppService) buildMediaStatus(session *models.Session, req *models.PostStatusRequest) (*waProto.Message, error) {
+	var data []byte
+	var contentType string
+	switch {
+	case req.Media != "":
+		decoded, err := base64.StdEncoding.DecodeString(req.Media)
+		if err != nil {
+			return nil, models.NewBadRequestError("invalid base64 media data: %v", err)
+		}
+		data = decoded
+		contentType = http.DetectContentType(data)
+	case req.MediaURL != "":
+		downloaded, _, _, err := s.downloadFile(req.MediaURL, false)
+		if err != nil {
+			return nil, err
+		}
+		data = downloaded
+		contentType = http.DetectContentType(data)
+	default:
+		return nil, models.NewBadRequestError("media or media_url is required for an %s status", req.Type)
+	}
+
+	var seconds uint32
+	if req.Type == "image" {
+		if contentType != "image/jpeg" && contentType != "image/png" {
+			return nil, models.NewBadRequestError("image status must be JPEG or PNG, got %s", contentType)
+		}
+		if len(data) > models.StatusMaxImageBytes {
+			return nil, models.NewBadRequestError("image is %d bytes, a status allows at most %d", len(data), models.StatusMaxImageBytes)
+		}
+	} else {
+		if contentType != "video/mp4" {
+			return nil, models.NewBadRequestError("video status must be MP4, got %s", contentType)
+		}
+		if len(data) > models.StatusMaxVideoBytes {
+			return nil, models.NewBadRequestError("video is %d bytes, a status allows at most %d", len(data), models.StatusMaxVideoBytes)
+		}
+		duration, ok := mp4Duration(data)
+		if !ok {
+			return nil, models.NewBadRequestError("could not read the video duration")
+		}
+		if duration > models.StatusMaxVideoSeconds*time.Second {
+			return nil, models.NewBadRequestError("video is %s long, a status allows at most %ds", duration.Round(time.Second), models.StatusMaxVideoSeconds)
+		}
+		seconds = uint32(duration.Round(time.Second) / time.Second)
+	}
+
+	uploaded, err := s.upload(session, data, whatsmeowMediaType(req.Type))
+	if err != nil {
+		return nil, s.sendFailure(session, "failed to upload "+req.Type, err)
+	}
+
+	var caption *string
+	if req.Caption != "" {
+		caption = proto.String(req.Caption)
+	}
+
+	if req.Type == "image" {
+		return &waProto.Message{
+			ImageMessage: &waProto.ImageMessage{
+				URL:           proto.String(uploaded.URL),
+				Mimetype:      proto.String(contentType),
+				Caption:       caption,
+				FileSHA256:    uploaded.FileSHA256,
+				FileLength:    proto.Uint64(uploaded.FileLength),
+				MediaKey:      uploaded.MediaKey,
+				FileEncSHA256: uploaded.FileEncSHA256,
+				DirectPath:    proto.String(uploaded.DirectPath),
+			},
+		}, nil
+	}
+	return &waProto.Message{
+		VideoMessage: &waProto.VideoMessage{
+			URL:           proto.String(uploaded.URL),
+			Mimetype:      proto.String(contentType),
+			Caption:       caption,
+			Seconds:       proto.Uint32(seconds),
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			DirectPath:    proto.String(uploaded.DirectPath),
+		},
+	}, nil
+}
+
+// mp4Duration reads the duration from the movie header (moov/mvhd) of an MP4 file
+func mp4Duration(data []byte) (time.Duration, bool) {
+	moov, ok := mp4Box(data, "moov")
+	if !ok {
+		return 0, false
+	}
+	mvhd, ok := mp4Box(moov, "mvhd")
+	if !ok || len(mvhd) < 20 {
+		return 0, false
+	}
+
+	var timescale, duration uint64
+	if mvhd[0] == 1 {
+		// Version 1: 64-bit creation and modification times and duration
+		if len(mvhd) < 32 {
+			return 0, false
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if timescale == 0 {
+		return 0, false
+	}
+	return time.Duration(duration * uint64(time.Second) / timescale), true
+}
+
+// mp4Box returns the payload of the first box of the given type at the top level of data
+func mp4Box(data []byte, boxType string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, false
+		}
+		if string(data[4:8]) == boxType {
+			return data[header:size], true
+		}
+		data = data[size:]
+	}
+	return nil, false
+}
+
+// handleStatusReceipt reports views of the session's own statuses
+func (s *WhatsAppService) handleStatusReceipt(session *models.Session, evt *events.Receipt) {
+	var event string
+	switch evt.Type {
+	case types.ReceiptTypeRead:
+		event = "viewed"
+	case types.ReceiptTypePlayed:
+		event = "played"
+	default:
+		return
+	}
+	if evt.IsFromMe || session.WebhookURL == "" || !session.WebhookEventEnabled("status_update", false) {
+		return
+	}
+
+	s.deliverWebhook(session, "status_update", &models.WebhookStatusUpdate{
+		Type:      "status_update",
+		Event:     event,
+		SessionID: session.ID,
+		StatusIDs: evt.MessageIDs,
+		From:      evt.Sender.ToNonAD().String(),
+		FromPhone: senderPhone(evt.MessageSource),
+		Timestamp: models.FormatTimestamp(evt.Timestamp),
+	})
+}
+
+// handleStatusReaction reports reactions to the session's own statuses
+func (s *WhatsAppService) handleStatusReaction(session *models.Session, evt *events.Message) {
+	reaction := evt.Message.GetReactionMessage()
+	if reaction == nil || evt.Info.IsFromMe || session.WebhookURL == "" || !session.WebhookEventEnabled("status_update", false) {
+		return
+	}
+	key := reaction.GetKey()
+	if key.GetRemoteJID() != types.StatusBroadcastJID.String() || !key.GetFromMe() {
+		return
+	}
+
+	s.deliverWebhook(session, "status_update", &models.WebhookStatusUpdate{
+		Type:      "status_update",
+		Event:     "reaction",
+		SessionID: session.ID,
+		StatusIDs: []string{key.GetID()},
+		From:      evt.Info.Sender.ToNonAD().String(),
+		FromName:  evt.Info.PushName,
+		FromPhone: senderPhone(evt.Info.MessageSource),
+		Reaction:  reaction.GetText(),
+		Timestamp: models.FormatTimestamp(evt.Info.Timestamp),
+	})
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/utils"
+)
+
+// Token lifetimes used until ConfigureTokens is called
+const (
+	defaultAccessTokenTTL  = 24 * time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ConfigureTokens sets the lifetime of access tokens and enables refresh
+// tokens; without it logins only return a 24h access token.
+//
+// It also sets jwt.TimePrecision to microseconds, so access tokens carry
+// their issue time at the precision tokens_invalidated_at is stored with and
+// checkTokenHolder tells apart the tokens issued in the same second as an
+// invalidation. The setting is global to the jwt package: every token the
+// process signs from then on has sub-second times. Without it, tokens issued
+// in the second of an invalidation are all rejected.
+func (s *UserService) ConfigureTokens(refreshTokens *repository.RefreshTokenRepository, accessTTL, refreshTTL time.Duration) {
+	jwt.TimePrecision = time.Microsecond
+	s.refreshRepo = refreshTokens
+	if accessTTL > 0 {
+		s.accessTokenTTL = accessTTL
+	}
+	if refreshTTL > 0 {
+		s.refreshTokenTTL = refreshTTL
+	}
+}
+
+// issueTokens signs an access token for a user and, when refresh tokens are
+// enabled, stores a new refresh token
+func (s *UserService) issueTokens(user *models.User) (*models.LoginResponse, error) {
+	now := time.Now()
+	token, err := s.generateJWT(user, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	resp := &models.LoginResponse{
+		Success:   true,
+		Token:     token,
+		ExpiresAt: models.FormatTimestamp(now.Add(s.accessTokenTTL)),
+		User:      user,
+	}
+	if s.refreshRepo == nil {
+		return resp, nil
+	}
+
+	if err := s.refreshRepo.DeleteExpired(user.ID, now); err != nil {
+		s.logger.Warn("%v", err)
+	}
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTokenTTL),
+	}
+	if err := s.refreshRepo.Create(stored); err != nil {
+		return nil, err
+	}
+
+	resp.RefreshToken = refreshToken
+	resp.RefreshExpiresAt = models.FormatTimestamp(stored.ExpiresAt)
+	return resp, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. A refresh token that was already used revokes every refresh token of
+// its user, as it has most likely been stolen.
+func (s *UserService) Refresh(refreshToken string) (*models.LoginResponse, error) {
+	if s.refreshRepo == nil {
+		return nil, models.NewServiceUnavailableError("refresh tokens are not enabled")
+	}
+	if !strings.HasPrefix(refreshToken, "wamr_") {
+		return nil, models.NewUnauthorizedError("invalid refresh token")
+	}
+
+	stored, err := s.refreshRepo.GetByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, models.NewUnauthorizedError("invalid refresh token")
+	}
+
+	now := time.Now()
+	if stored.UsedAt != nil {
+		s.logger.Warn("Used refresh token %d of user %d was presented again, revoking all of the user's refresh tokens", stored.ID, stored.UserID)
+		if err := s.refreshRepo.RevokeByUserID(stored.UserID, now); err != nil {
+			s.logger.Error("%v", err)
+		}
+		return nil, models.NewUnauthorizedError("refresh token has already been used")
+	}
+	if stored.RevokedAt != nil {
+		return nil, models.NewUnauthorizedError("refresh token has been revoked")
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, models.NewUnauthorizedError("refresh token has expired")
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	// Invalidating a user's tokens revokes their refresh tokens, so only the
+	// account itself is left to check
+	if err := checkAccount(user); err != nil {
+		return nil, err
+	}
+
+	used, err := s.refreshRepo.MarkUsed(stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, models.NewUnauthorizedError("refresh token has already been used")
+	}
+
+	return s.issueTokens(user)
+}
+
+// Logout revokes a refresh token, or with all every access and refresh token
+// of its user. Unknown tokens are ignored so that logging out twice succeeds.
+func (s *UserService) Logout(req *models.LogoutRequest) error {
+	if s.refreshRepo == nil || req.RefreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.refreshRepo.GetByHash(utils.HashToken(req.RefreshToken))
+	if err != nil || stored == nil {
+		return err
+	}
+
+	if req.All {
+		return s.InvalidateTokens(stored.UserID)
+	}
+	_, err = s.refreshRepo.Revoke(stored.ID, time.Now())
+	return err
+}
+
+// InvalidateTokens revokes every access and refresh token issued to a user
+// so far
+func (s *UserService) InvalidateTokens(userID int) error {
+	now := time.Now()
+	if err := s.userRepo.InvalidateTokens(userID, now); err != nil {
+		return err
+	}
+	if s.refreshRepo != nil {
+		if err := s.refreshRepo.RevokeByUserID(userID, now); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Info("Invalidated all tokens of user %d", userID)
+	return nil
+}
+
+// CheckAccessToken fails when the user an access token was issued to at
+// issuedAt no longer exists, is disabled or has had their tokens invalidated
+func (s *UserService) CheckAccessToken(userID int, issuedAt time.Time) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %v", err)
+	}
+	return checkTokenHolder(user, issuedAt)
+}
+
+// checkTokenHolder checks that an access token issued at issuedAt to user is
+// still good. Both times have microsecond precision once ConfigureTokens has
+// run, so tokens issued just before an invalidation fail while the ones issued
+// right after it pass.
+func checkTokenHolder(user *models.User, issuedAt time.Time) error {
+	if err := checkAccount(user); err != nil {
+		return err
+	}
+	if user.TokensInvalidatedAt != nil && issuedAt.Before(*user.TokensInvalidatedAt) {
+		return models.NewUnauthorizedError("token has been revoked")
+	}
+	return nil
+}
+
+// checkAccount checks that user still exists and is enabled
+func checkAccount(user *models.User) error {
+	if user == nil {
+		return models.NewUnauthorizedError("user no longer exists")
+	}
+	if !user.IsActive {
+		return models.NewUnauthorizedError("account is disabled")
+	}
+	return nil
+}
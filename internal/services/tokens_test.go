@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+)
+
+func TestCheckTokenHolder(t *testing.T) {
+	// Mid-second, so tokens on either side share its whole second
+	invalidatedAt := time.Unix(1700000000, 500000000)
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		issuedAt time.Time
+		ok       bool
+	}{
+		{name: "never invalidated", user: &models.User{IsActive: true}, issuedAt: invalidatedAt, ok: true},
+		{name: "deleted user", issuedAt: invalidatedAt.Add(time.Minute)},
+		{name: "disabled user", user: &models.User{}, issuedAt: invalidatedAt.Add(time.Minute)},
+		{name: "issued a minute before", user: &models.User{IsActive: true, TokensInvalidatedAt: &invalidatedAt}, issuedAt: invalidatedAt.Add(-time.Minute)},
+		{name: "issued just before in the same second", user: &models.User{IsActive: true, TokensInvalidatedAt: &invalidatedAt}, issuedAt: invalidatedAt.Add(-time.Microsecond)},
+		{name: "issued at the invalidation", user: &models.User{IsActive: true, TokensInvalidatedAt: &invalidatedAt}, issuedAt: invalidatedAt, ok: true},
+		{name: "issued just after in the same second", user: &models.User{IsActive: true, TokensInvalidatedAt: &invalidatedAt}, issuedAt: invalidatedAt.Add(time.Microsecond), ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTokenHolder(tt.user, tt.issuedAt)
+			if tt.ok && err != nil {
+				t.Errorf("checkTokenHolder() = %v, want accepted", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("checkTokenHolder() accepted the token, want rejected")
+			}
+		})
+	}
+}
+
+// newTestUserService returns a user service with refresh tokens and one
+// user, alice, whose password is "old-password"
+func newTestUserService(t *testing.T) (*UserService, *models.User) {
+	t.Helper()
+	db := newTestDatabase(t)
+	userService := NewUserService(repository.NewUserRepository(db.DB()), repository.NewAPIKeyRepository(db.DB()), "test-secret", newTestLogger())
+	userService.ConfigureTokens(repository.NewRefreshTokenRepository(db.DB()), time.Hour, 24*time.Hour)
+
+	user, err := userService.CreateUser(&models.CreateUserRequest{Username: "alice", Password: "old-password", Role: models.RoleUser})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return userService, user
+}
+
+// issuedAt parses an access token and returns its issue time
+func issuedAt(t *testing.T, token string) time.Time {
+	t.Helper()
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	}); err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	return claims.IssuedAt.Time
+}
+
+// Logging in, changing the password and using the new tokens all happen
+// within the same second here, as they do for a client
+func TestChangePasswordRevokesTokensOfTheSameSecond(t *testing.T) {
+	userService, user := newTestUserService(t)
+
+	before, err := userService.Login(&models.LoginRequest{Username: "alice", Password: "old-password"})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	after, err := userService.ChangePassword(user.ID, &models.ChangePasswordRequest{OldPassword: "old-password", NewPassword: "new-password"})
+	if err != nil {
+		t.Fatalf("password change failed: %v", err)
+	}
+
+	if err := userService.CheckAccessToken(user.ID, issuedAt(t, before.Token)); err == nil {
+		t.Error("access token issued before the password change was accepted")
+	}
+	if err := userService.CheckAccessToken(user.ID, issuedAt(t, after.Token)); err != nil {
+		t.Errorf("access token issued by the password change was rejected: %v", err)
+	}
+
+	if _, err := userService.Refresh(before.RefreshToken); err == nil {
+		t.Error("refresh token issued before the password change was accepted")
+	}
+	if _, err := userService.Refresh(after.RefreshToken); err != nil {
+		t.Errorf("refresh token issued by the password change was rejected: %v", err)
+	}
+}
+
+func TestInvalidationTimeKeepsMicroseconds(t *testing.T) {
+	userService, user := newTestUserService(t)
+
+	at := time.Unix(1700000000, 123456789)
+	if err := userService.userRepo.InvalidateTokens(user.ID, at); err != nil {
+		t.Fatalf("failed to invalidate tokens: %v", err)
+	}
+	stored, err := userService.userRepo.GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if want := at.Truncate(time.Microsecond); stored.TokensInvalidatedAt == nil || !stored.TokensInvalidatedAt.Equal(want) {
+		t.Errorf("tokens_invalidated_at = %v, want %v", stored.TokensInvalidatedAt, want)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// typingPresenceDelay gives WhatsApp time to process the online presence
+// before the typing indicator follows it
+const typingPresenceDelay = 300 * time.Millisecond
+
+// maxTypingIndicatorDuration bounds the duration of a typing indicator that
+// stops by itself
+const maxTypingIndicatorDuration = 5 * time.Minute
+
+// typingIndicator is the pending work of one typing request: showing the
+// indicator and, with a duration, clearing it again
+type typingIndicator struct {
+	cancel context.CancelFunc
+}
+
+// typingTracker remembers the typing indicator of each chat so a later
+// request, or a message sent to the chat, can cancel it
+type typingTracker struct {
+	mu    sync.Mutex
+	chats map[string]*typingIndicator
+}
+
+// start cancels the chat's pending indicator and registers a new one
+func (t *typingTracker) start(parent context.Context, sessionID string, chat types.JID) (context.Context, *typingIndicator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.chats == nil {
+		t.chats = make(map[string]*typingIndicator)
+	}
+	key := unreadKey(sessionID, chat)
+	if pending, ok := t.chats[key]; ok {
+		pending.cancel()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	indicator := &typingIndicator{cancel: cancel}
+	t.chats[key] = indicator
+	return ctx, indicator
+}
+
+// finish forgets an indicator whose work is over, unless a newer one replaced it
+func (t *typingTracker) finish(sessionID string, chat types.JID, indicator *typingIndicator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	indicator.cancel()
+	key := unreadKey(sessionID, chat)
+	if t.chats[key] == indicator {
+		delete(t.chats, key)
+	}
+}
+
+// stop cancels the chat's pending indicator, if any
+func (t *typingTracker) stop(sessionID string, chat types.JID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := unreadKey(sessionID, chat)
+	if pending, ok := t.chats[key]; ok {
+		pending.cancel()
+		delete(t.chats, key)
+	}
+}
+
+// forget cancels every pending indicator of a session
+func (t *typingTracker) forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prefix := sessionID + "\x00"
+	for key, pending := range t.chats {
+		if strings.HasPrefix(key, prefix) {
+			pending.cancel()
+			delete(t.chats, key)
+		}
+	}
+}
+
+// showTyping shows the typing indicator once the online presence has
+// settled, and clears it again after duration unless that is zero. It runs
+// in the background; a message sent to the chat or a new typing request
+// cancels it.
+func (s *WhatsAppService) showTyping(session *models.Session, jid types.JID, duration time.Duration) {
+	ctx, indicator := s.typing.start(s.ctx, session.ID, jid)
+
+	go func() {
+		defer s.typing.finish(session.ID, jid, indicator)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(typingPresenceDelay):
+		}
+		if err := session.Client.SendChatPresence(context.Background(), jid, types.ChatPresenceComposing, types.ChatPresenceMediaText); err != nil {
+			s.logger.Error("Failed to send typing indicator to %s from session %s: %v", jid, session.ID, err)
+			return
+		}
+		s.logger.Info("Sent typing indicator to %s from session %s (push name: %s)",
+			jid.String(), session.ID, session.Client.Store.PushName)
+
+		if duration == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(duration):
+		}
+		if err := session.Client.SendChatPresence(context.Background(), jid, types.ChatPresencePaused, types.ChatPresenceMediaText); err != nil {
+			s.logger.Warn("Failed to stop typing indicator to %s from session %s: %v", jid, session.ID, err)
+			return
+		}
+		s.logger.Info("Typing indicator to %s from session %s stopped after %s", jid, session.ID, duration)
+	}()
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// MaxUploadCacheTTL keeps cached uploads well inside WhatsApp's media expiry,
+// after which the CDN no longer serves the encrypted file
+const MaxUploadCacheTTL = 7 * 24 * time.Hour
+
+// uploadedMedia is a file uploaded to WhatsApp's media servers, ready to be
+// referenced from a message
+type uploadedMedia struct {
+	uploaded    whatsmeow.UploadResponse
+	contentType string
+	filename    string
+	mediaType   string
+	sha256      string
+	expiresAt   time.Time
+}
+
+// urlUpload links a downloaded URL to the content it served
+type urlUpload struct {
+	sha256    string
+	mediaType string
+	expiresAt time.Time
+}
+
+// uploadCache remembers media uploads per session so sending the same URL or
+// the same content again skips the download and the upload. Uploads are keyed
+// by (session, content hash, media type) because the media key, and with it
+// the encrypted file, differs per media type.
+type uploadCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	byURL   map[string]urlUpload
+	uploads map[string]*uploadedMedia
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// UploadCacheStats reports the effectiveness of the media upload cache
+type UploadCacheStats struct {
+	Enabled    bool    `json:"enabled"`
+	TTLSeconds int64   `json:"ttl_seconds"`
+	Entries    int     `json:"entries"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	HitRatio   float64 `json:"hit_ratio"`
+}
+
+func newUploadCache(ttl time.Duration) *uploadCache {
+	return &uploadCache{
+		ttl:     ttl,
+		byURL:   make(map[string]urlUpload),
+		uploads: make(map[string]*uploadedMedia),
+	}
+}
+
+func uploadURLKey(sessionID, url string) string {
+	return sessionID + "\x00" + url
+}
+
+func uploadContentKey(sessionID, sha256, mediaType string) string {
+	return sessionID + "\x00" + sha256 + "\x00" + mediaType
+}
+
+// lookupURL returns the upload made for a URL, if it is still fresh.
+// An empty mediaType accepts whatever type the URL was sent as.
+func (c *uploadCache) lookupURL(sessionID, url, mediaType string) (uploadedMedia, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	link, ok := c.byURL[uploadURLKey(sessionID, url)]
+	if ok && now.Before(link.expiresAt) && (mediaType == "" || mediaType == link.mediaType) {
+		if media, ok := c.uploads[uploadContentKey(sessionID, link.sha256, link.mediaType)]; ok && now.Before(media.expiresAt) {
+			c.hits.Add(1)
+			return *media, true
+		}
+	}
+
+	c.misses.Add(1)
+	return uploadedMedia{}, false
+}
+
+// lookupContent returns an earlier upload of identical content
+func (c *uploadCache) lookupContent(sessionID, sha256, mediaType string) (uploadedMedia, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	media, ok := c.uploads[uploadContentKey(sessionID, sha256, mediaType)]
+	if !ok || !time.Now().Before(media.expiresAt) {
+		c.misses.Add(1)
+		return uploadedMedia{}, false
+	}
+
+	c.hits.Add(1)
+	return *media, true
+}
+
+// store records a fresh upload and, when url is set, links the URL to it
+func (c *uploadCache) store(sessionID, url string, media uploadedMedia) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.pruneLocked(now)
+
+	media.expiresAt = now.Add(c.ttl)
+	c.uploads[uploadContentKey(sessionID, media.sha256, media.mediaType)] = &media
+	if url != "" {
+		c.byURL[uploadURLKey(sessionID, url)] = urlUpload{
+			sha256:    media.sha256,
+			mediaType: media.mediaType,
+			expiresAt: media.expiresAt,
+		}
+	}
+}
+
+// linkURL points a URL at an existing upload of the content it served
+func (c *uploadCache) linkURL(sessionID, url string, media uploadedMedia) {
+	if url == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.byURL[uploadURLKey(sessionID, url)] = urlUpload{
+		sha256:    media.sha256,
+		mediaType: media.mediaType,
+		expiresAt: media.expiresAt,
+	}
+}
+
+// invalidate drops the upload of the given content and the URL that pointed at it
+func (c *uploadCache) invalidate(sessionID, url, sha256, mediaType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sha256 != "" {
+		delete(c.uploads, uploadContentKey(sessionID, sha256, mediaType))
+	}
+	if url != "" {
+		delete(c.byURL, uploadURLKey(sessionID, url))
+	}
+}
+
+// pruneLocked removes expired entries. Callers must hold c.mu.
+func (c *uploadCache) pruneLocked(now time.Time) {
+	for key, link := range c.byURL {
+		if !now.Before(link.expiresAt) {
+			delete(c.byURL, key)
+		}
+	}
+	for key, media := range c.uploads {
+		if !now.Before(media.expiresAt) {
+			delete(c.uploads, key)
+		}
+	}
+}
+
+func (c *uploadCache) stats() UploadCacheStats {
+	c.mu.Lock()
+	entries := len(c.uploads)
+	c.mu.Unlock()
+
+	stats := UploadCacheStats{
+		Enabled:    true,
+		TTLSeconds: int64(c.ttl / time.Second),
+		Entries:    entries,
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// SetUploadCacheTTL enables reuse of media uploads for identical URLs and
+// content for the given duration (0 disables the cache)
+func (s *WhatsAppService) SetUploadCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		s.uploads = nil
+		return
+	}
+	if ttl > MaxUploadCacheTTL {
+		ttl = MaxUploadCacheTTL
+	}
+	s.uploads = newUploadCache(ttl)
+}
+
+// UploadCacheStats returns hit and miss counts of the media upload cache
+func (s *WhatsAppService) UploadCacheStats() UploadCacheStats {
+	if s.uploads == nil {
+		return UploadCacheStats{}
+	}
+	return s.uploads.stats()
+}
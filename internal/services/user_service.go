@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,27 +10,40 @@ import (
 
 	"whatsapp-multi-session/internal/models"
 	"whatsapp-multi-session/internal/repository"
-	"whatsapp-multi-session/internal/utils"
 	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/phone"
+	"whatsapp-multi-session/pkg/ratelimiter"
 )
 
 // UserService handles user-related business logic
 type UserService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
-	logger    *logger.Logger
+	userRepo    *repository.UserRepository
+	apiKeyRepo  *repository.APIKeyRepository
+	keyRequests *ratelimiter.RequestLimiter
+	refreshRepo *repository.RefreshTokenRepository
+	jwtSecret   string
+	logger      *logger.Logger
+
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
 }
 
 // NewUserService creates a new user service
 func NewUserService(
 	userRepo *repository.UserRepository,
+	apiKeyRepo *repository.APIKeyRepository,
 	jwtSecret string,
 	log *logger.Logger,
 ) *UserService {
 	return &UserService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
-		logger:    log,
+		userRepo:    userRepo,
+		apiKeyRepo:  apiKeyRepo,
+		keyRequests: ratelimiter.NewRequestLimiter(time.Minute),
+		jwtSecret:   jwtSecret,
+		logger:      log,
+
+		accessTokenTTL:  defaultAccessTokenTTL,
+		refreshTokenTTL: defaultRefreshTokenTTL,
 	}
 }
 
@@ -54,19 +68,14 @@ func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := s.generateJWT(user)
+	// Issue access and refresh tokens
+	resp, err := s.issueTokens(user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %v", err)
+		return nil, err
 	}
 
 	s.logger.Info("User %s logged in successfully", user.Username)
-
-	return &models.LoginResponse{
-		Success: true,
-		Token:   token,
-		User:    user,
-	}, nil
+	return resp, nil
 }
 
 // Register registers a new user account
@@ -92,7 +101,7 @@ func (s *UserService) Register(req *models.RegisterRequest) (*models.RegisterRes
 		Username:     req.Username,
 		Password:     string(hashedPassword),
 		Role:         models.RoleUser, // Default role
-		SessionLimit: 5,               // Default session limit
+		SessionLimit: models.DefaultSessionLimit,
 		IsActive:     true,
 		CreatedAt:    time.Now(),
 	}
@@ -131,14 +140,25 @@ func (s *UserService) CreateUser(req *models.CreateUserRequest) (*models.User, e
 		return nil, fmt.Errorf("failed to hash password: %v", err)
 	}
 
+	defaultRegion := strings.ToUpper(strings.TrimSpace(req.DefaultRegion))
+	if defaultRegion != "" && !phone.IsSupportedRegion(defaultRegion) {
+		return nil, fmt.Errorf("unsupported default region: %s", req.DefaultRegion)
+	}
+
+	sessionLimit := models.DefaultSessionLimit
+	if req.SessionLimit != nil {
+		sessionLimit = *req.SessionLimit
+	}
+
 	// Create user
 	user := &models.User{
-		Username:     req.Username,
-		Password:     string(hashedPassword),
-		Role:         req.Role,
-		SessionLimit: req.SessionLimit,
-		IsActive:     true,
-		CreatedAt:    time.Now(),
+		Username:      req.Username,
+		Password:      string(hashedPassword),
+		Role:          req.Role,
+		SessionLimit:  sessionLimit,
+		IsActive:      true,
+		DefaultRegion: defaultRegion,
+		CreatedAt:     time.Now(),
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -161,6 +181,8 @@ func (s *UserService) UpdateUser(id int, req *models.UpdateUserRequest) (*models
 		return nil, fmt.Errorf("user not found")
 	}
 
+	previousRole, wasActive := user.Role, user.IsActive
+
 	// Update fields
 	if req.Username != "" && req.Username != user.Username {
 		// Check if new username already exists
@@ -186,54 +208,104 @@ func (s *UserService) UpdateUser(id int, req *models.UpdateUserRequest) (*models
 		user.Role = req.Role
 	}
 
-	if req.SessionLimit > 0 {
-		user.SessionLimit = req.SessionLimit
+	if req.SessionLimit != nil {
+		user.SessionLimit = *req.SessionLimit
 	}
 
 	if req.IsActive != nil {
 		user.IsActive = *req.IsActive
 	}
 
+	if req.DefaultRegion != nil {
+		defaultRegion := strings.ToUpper(strings.TrimSpace(*req.DefaultRegion))
+		if defaultRegion != "" && !phone.IsSupportedRegion(defaultRegion) {
+			return nil, fmt.Errorf("unsupported default region: %s", *req.DefaultRegion)
+		}
+		user.DefaultRegion = defaultRegion
+	}
+
 	// Save changes
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, fmt.Errorf("failed to update user: %v", err)
 	}
 
+	// Tokens carry the role, and must not outlive a password reset or a
+	// disabled account
+	if req.Password != "" || user.Role != previousRole || (wasActive && !user.IsActive) {
+		if err := s.InvalidateTokens(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	s.logger.Info("User %s updated successfully", user.Username)
 	return user, nil
 }
 
-// ChangePassword changes a user's password
-func (s *UserService) ChangePassword(userID int, req *models.ChangePasswordRequest) error {
+// ChangePassword changes a user's password, revoking their other tokens, and
+// returns new tokens for the caller
+func (s *UserService) ChangePassword(userID int, req *models.ChangePasswordRequest) (*models.LoginResponse, error) {
 	// Get user
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %v", err)
+		return nil, fmt.Errorf("failed to get user: %v", err)
 	}
 
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user not found")
 	}
 
 	// Verify old password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
-		return fmt.Errorf("invalid old password")
+		return nil, fmt.Errorf("invalid old password")
 	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return fmt.Errorf("failed to hash new password: %v", err)
+		return nil, fmt.Errorf("failed to hash new password: %v", err)
 	}
 
 	// Update password
 	user.Password = string(hashedPassword)
 	if err := s.userRepo.Update(user); err != nil {
-		return fmt.Errorf("failed to update password: %v", err)
+		return nil, fmt.Errorf("failed to update password: %v", err)
+	}
+
+	if err := s.InvalidateTokens(user.ID); err != nil {
+		return nil, err
 	}
 
 	s.logger.Info("Password changed for user %s", user.Username)
-	return nil
+	return s.issueTokens(user)
+}
+
+// ResetPassword sets a new password without the old one, for administrators
+func (s *UserService) ResetPassword(username, newPassword string) (*models.User, error) {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash new password: %v", err)
+	}
+
+	user.Password = string(hashedPassword)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update password: %v", err)
+	}
+
+	if err := s.InvalidateTokens(user.ID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Password reset for user %s", user.Username)
+	return user, nil
 }
 
 // GetUser returns a user by ID
@@ -325,11 +397,12 @@ func (s *UserService) EnsureDefaultAdmin(username, password string) error {
 	}
 
 	// Create default admin
+	sessionLimit := 10
 	req := &models.CreateUserRequest{
 		Username:     username,
 		Password:     password,
 		Role:         models.RoleAdmin,
-		SessionLimit: 10,
+		SessionLimit: &sessionLimit,
 	}
 
 	_, err = s.CreateUser(req)
@@ -346,19 +419,21 @@ type Claims struct {
 	Username string `json:"username"`
 	UserID   int    `json:"user_id"`
 	Role     string `json:"role"`
+	Region   string `json:"region,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// generateJWT generates a JWT token for a user
-func (s *UserService) generateJWT(user *models.User) (string, error) {
+// generateJWT generates an access token for a user
+func (s *UserService) generateJWT(user *models.User, issuedAt time.Time) (string, error) {
 	claims := &Claims{
 		Username: user.Username,
 		UserID:   user.ID,
 		Role:     user.Role,
+		Region:   user.DefaultRegion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   fmt.Sprintf("%d", user.ID),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(s.accessTokenTTL)),
 			Issuer:    "whatsapp-multi-session",
 		},
 	}
@@ -366,99 +441,3 @@ func (s *UserService) generateJWT(user *models.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.jwtSecret))
 }
-
-// GenerateAPIKey generates a new API key for a user
-func (s *UserService) GenerateAPIKey(userID int) (*models.APIKeyResponse, error) {
-	// Check if user exists
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %v", err)
-	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found")
-	}
-
-	// Generate new API key
-	apiKey, err := s.generateAPIKey()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate API key: %v", err)
-	}
-
-	// Update user with new API key
-	if err := s.userRepo.UpdateAPIKey(userID, apiKey); err != nil {
-		return nil, fmt.Errorf("failed to save API key: %v", err)
-	}
-
-	s.logger.Info("Generated new API key for user %s (ID: %d)", user.Username, userID)
-
-	return &models.APIKeyResponse{
-		Success: true,
-		Message: "API key generated successfully",
-		APIKey:  apiKey,
-	}, nil
-}
-
-// RevokeAPIKey revokes a user's API key
-func (s *UserService) RevokeAPIKey(userID int) error {
-	// Check if user exists
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil {
-		return fmt.Errorf("failed to get user: %v", err)
-	}
-	if user == nil {
-		return fmt.Errorf("user not found")
-	}
-
-	// Remove API key
-	if err := s.userRepo.RemoveAPIKey(userID); err != nil {
-		return fmt.Errorf("failed to revoke API key: %v", err)
-	}
-
-	s.logger.Info("Revoked API key for user %s (ID: %d)", user.Username, userID)
-	return nil
-}
-
-// GetAPIKeyInfo returns information about a user's API key (without the key itself)
-func (s *UserService) GetAPIKeyInfo(userID int) (*models.APIKeyInfo, error) {
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %v", err)
-	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found")
-	}
-
-	return &models.APIKeyInfo{
-		HasKey:    user.APIKey != "",
-		CreatedAt: user.CreatedAt,
-		LastUsed:  user.UpdatedAt,
-	}, nil
-}
-
-// AuthenticateAPIKey authenticates a user by API key
-func (s *UserService) AuthenticateAPIKey(apiKey string) (*models.User, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("API key is required")
-	}
-
-	user, err := s.userRepo.GetByAPIKey(apiKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to authenticate API key: %v", err)
-	}
-
-	if user == nil {
-		return nil, fmt.Errorf("invalid API key")
-	}
-
-	if !user.IsActive {
-		return nil, fmt.Errorf("account is disabled")
-	}
-
-	return user, nil
-}
-
-// generateAPIKey generates a secure API key
-func (s *UserService) generateAPIKey() (string, error) {
-	// Import the utils package
-	return utils.GenerateAPIKey()
-}
\ No newline at end of file
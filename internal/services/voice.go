@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/pkg/audio"
+)
+
+// voiceConversionTimeout bounds each ffmpeg run while preparing a voice note
+const voiceConversionTimeout = time.Minute
+
+// SetFFmpeg enables converting voice notes to OGG/Opus and drawing their
+// waveforms; without it only OGG/Opus audio can be sent as a voice note
+func (s *WhatsAppService) SetFFmpeg(ffmpeg *audio.FFmpeg) {
+	s.ffmpeg = ffmpeg
+}
+
+// voiceNote is audio ready to be sent as a voice note
+type voiceNote struct {
+	data     []byte
+	seconds  uint32
+	waveform []byte
+}
+
+// SendVoice sends audio as a voice note, which WhatsApp plays inline with a
+// waveform instead of offering it as a file
+func (s *WhatsAppService) SendVoice(sessionID string, req *models.SendVoiceRequest) (string, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
+	jid, err := parseRecipientJID(req.To)
+	if err != nil {
+		return "", models.NewBadRequestError("%v", err)
+	}
+
+	var data []byte
+	switch {
+	case req.Audio != "":
+		if data, err = base64.StdEncoding.DecodeString(req.Audio); err != nil {
+			return "", models.NewBadRequestError("invalid base64 audio data: %v", err)
+		}
+	case req.URL != "":
+		if data, _, _, err = s.downloadFile(req.URL, false); err != nil {
+			return "", err
+		}
+	default:
+		return "", models.NewBadRequestError("audio or url is required")
+	}
+
+	voice, err := s.prepareVoiceNote(data)
+	if err != nil {
+		return "", err
+	}
+
+	uploaded, err := s.upload(session, voice.data, whatsmeow.MediaAudio)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to upload voice note", err)
+	}
+
+	msg := &waProto.Message{
+		AudioMessage: &waProto.AudioMessage{
+			URL:           proto.String(uploaded.URL),
+			Mimetype:      proto.String(audio.VoiceMimetype),
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			DirectPath:    proto.String(uploaded.DirectPath),
+			PTT:           proto.Bool(true),
+			Seconds:       proto.Uint32(voice.seconds),
+			Waveform:      voice.waveform,
+		},
+	}
+
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to send voice note", err)
+	}
+
+	s.logger.Info("Voice note of %ds sent to %s from session %s", voice.seconds, jid, sessionID)
+	return resp.ID, nil
+}
+
+// prepareVoiceNote converts audio to OGG/Opus if needed and measures it. The
+// waveform is only drawn when ffmpeg is available; WhatsApp shows a flat one
+// otherwise.
+func (s *WhatsAppService) prepareVoiceNote(data []byte) (*voiceNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), voiceConversionTimeout)
+	defer cancel()
+
+	if !audio.IsOggOpus(data) {
+		if s.ffmpeg == nil {
+			return nil, models.NewBadRequestError("voice notes must be OGG/Opus audio; install ffmpeg to have other formats converted")
+		}
+		converted, err := s.ffmpeg.ToOggOpus(ctx, data)
+		if err != nil {
+			return nil, models.NewBadRequestError("failed to convert audio to OGG/Opus: %v", err)
+		}
+		data = converted
+	}
+
+	duration, err := audio.OggOpusDuration(data)
+	if err != nil {
+		return nil, models.NewBadRequestError("failed to read audio duration: %v", err)
+	}
+	voice := &voiceNote{data: data, seconds: uint32(math.Ceil(duration.Seconds()))}
+
+	if s.ffmpeg != nil {
+		if voice.waveform, err = s.ffmpeg.Waveform(ctx, data); err != nil {
+			s.logger.Warn("Failed to compute voice note waveform: %v", err)
+		}
+	}
+	return voice, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// webhookCircuit suspends a session's webhook once it has failed continuously
+// for suspendAfter, so a dead target no longer ties up the delivery queue.
+// Deliveries are held in the queue while the webhook is suspended.
+type webhookCircuit struct {
+	suspendAfter time.Duration
+
+	mu           sync.Mutex
+	failingSince map[string]time.Time
+}
+
+// ConfigureWebhookSuspension enables suspending webhooks that have failed
+// continuously for suspendAfter (0 never suspends). Suspensions are reported
+// through the operator notification URL.
+func (s *WhatsAppService) ConfigureWebhookSuspension(suspendAfter time.Duration) {
+	s.webhooks = &webhookCircuit{
+		suspendAfter: suspendAfter,
+		failingSince: make(map[string]time.Time),
+	}
+}
+
+// isWebhookSuspended reports whether webhook delivery is suspended for the session
+func (s *WhatsAppService) isWebhookSuspended(session *models.Session) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return session.WebhookSuspendedAt != nil
+}
+
+// recordWebhookSuccess resets the session's failure streak
+func (s *WhatsAppService) recordWebhookSuccess(session *models.Session) {
+	if s.webhooks == nil {
+		return
+	}
+
+	s.webhooks.mu.Lock()
+	delete(s.webhooks.failingSince, session.ID)
+	s.webhooks.mu.Unlock()
+}
+
+// recordWebhookFailure extends the session's failure streak and suspends the
+// webhook once the streak is longer than the configured duration
+func (s *WhatsAppService) recordWebhookFailure(session *models.Session) {
+	if s.webhooks == nil || s.webhooks.suspendAfter <= 0 {
+		return
+	}
+
+	now := time.Now()
+	s.webhooks.mu.Lock()
+	since, failing := s.webhooks.failingSince[session.ID]
+	if !failing {
+		since = now
+		s.webhooks.failingSince[session.ID] = now
+	}
+	s.webhooks.mu.Unlock()
+
+	if now.Sub(since) < s.webhooks.suspendAfter {
+		return
+	}
+
+	s.mu.Lock()
+	if session.WebhookSuspendedAt != nil {
+		s.mu.Unlock()
+		return
+	}
+	session.WebhookSuspendedAt = &now
+	s.mu.Unlock()
+
+	if err := s.sessionRepo.UpdateWebhookSuspended(session.ID, &now); err != nil {
+		s.logger.Error("Failed to persist webhook suspension for session %s: %v", session.ID, err)
+	}
+
+	s.logger.WithContext("webhook_audit", session.ID, nil).Warn(
+		"Webhook suspended for session %s: %s has failed continuously since %s",
+		session.ID, session.WebhookURL, models.FormatTimestamp(since))
+
+	go s.notifyOperator("webhook_suspended", session, map[string]interface{}{
+		"webhook_url":   session.WebhookURL,
+		"failing_since": models.FormatTimestamp(since),
+		"suspended_at":  models.FormatTimestamp(now),
+	})
+}
+
+// ResumeWebhook re-enables a suspended webhook. When replay is true the
+// deliveries held while it was suspended are sent by the delivery queue,
+// oldest first; otherwise they are failed and only sent again when retried.
+func (s *WhatsAppService) ResumeWebhook(sessionID string, userID int, replay bool) error {
+	s.mu.RLock()
+	session, exists := s.sessions[sessionID]
+	var webhookURL string
+	var wasSuspended bool
+	if exists {
+		webhookURL = session.WebhookURL
+		wasSuspended = session.WebhookSuspendedAt != nil
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+	if webhookURL == "" {
+		return models.NewBadRequestError("session %s has no webhook URL", sessionID)
+	}
+	if replay && s.webhookQueue == nil {
+		return models.NewServiceUnavailableError("webhook delivery queue is not configured")
+	}
+
+	actor := int64(userID)
+	audit := s.logger.WithContext("webhook_audit", sessionID, &actor)
+
+	// Held deliveries are released or failed before the suspension is lifted,
+	// so the queue does not send them with their old expiry
+	if s.webhookQueue != nil {
+		if replay {
+			released, err := s.webhookQueue.deliveries.ReleaseSession(sessionID, time.Now().Add(s.webhookQueue.maxAge))
+			if err != nil {
+				return err
+			}
+			audit.Info("Webhook replay for session %s by user %d: %d held delivery(ies) queued", sessionID, userID, released)
+		} else if wasSuspended {
+			discarded, err := s.webhookQueue.deliveries.DiscardSession(sessionID, "discarded when the webhook was resumed without replay")
+			if err != nil {
+				return err
+			}
+			if discarded > 0 {
+				audit.Info("Webhook for session %s resumed without replay: %d held delivery(ies) failed", sessionID, discarded)
+			}
+		}
+	}
+
+	if wasSuspended {
+		if err := s.sessionRepo.UpdateWebhookSuspended(sessionID, nil); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		session.WebhookSuspendedAt = nil
+		s.mu.Unlock()
+	}
+
+	if s.webhooks != nil {
+		s.webhooks.mu.Lock()
+		delete(s.webhooks.failingSince, sessionID)
+		s.webhooks.mu.Unlock()
+	}
+
+	if wasSuspended {
+		audit.Info("Webhook resumed for session %s by user %d (replay: %v)", sessionID, userID, replay)
+	}
+	if s.webhookQueue != nil {
+		s.webhookQueue.notify()
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// webhookMessageType returns the message_type a message is sent to the
+// webhook as, which is also the event sessions filter it by. It is known
+// before any media is downloaded, so filtered messages cost nothing.
+func webhookMessageType(msg *waProto.Message) string {
+	switch {
+	case msg.GetPollUpdateMessage() != nil:
+		return "poll_vote"
+	case msg.GetReactionMessage() != nil:
+		return "reaction"
+	}
+	switch messageType := loggedMessageType(msg); messageType {
+	case "text", "image", "document", "audio", "video", "order", "list_reply", "button_reply":
+		return messageType
+	}
+	return "unknown"
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/pkg/httpclient"
+)
+
+// Webhook events are queued in the webhook_deliveries table and sent by a
+// background worker, so an endpoint that is down for a while delays events
+// instead of losing them. A failed delivery is retried with exponential
+// backoff until it is older than the configured maximum age, then it is failed
+// and kept until an operator retries it. A session's deliveries go out one at
+// a time in order; while one is being retried the ones behind it wait, and so
+// do new ones. Workers lease the deliveries they send, so instances sharing a
+// database do not send them twice.
+
+const (
+	// webhookQueuePollInterval is how often the worker looks for due deliveries
+	// when it is not woken by a new one
+	webhookQueuePollInterval = 5 * time.Second
+	// webhookQueueBatchSize is how many due deliveries are loaded per query
+	webhookQueueBatchSize = 100
+	// webhookQueueWorkers is how many sessions are sent to at the same time
+	webhookQueueWorkers = 8
+	// webhookQueueLease is how long a worker holds the deliveries it loads.
+	// It stops sending a batch while a request may still fit in the lease.
+	webhookQueueLease = 5 * time.Minute
+	// webhookRetryBaseDelay and webhookRetryMaxDelay bound the backoff between attempts
+	webhookRetryBaseDelay = 10 * time.Second
+	webhookRetryMaxDelay  = 30 * time.Minute
+	// webhookPruneInterval is how often delivered deliveries are pruned
+	webhookPruneInterval = time.Hour
+)
+
+// webhookQueue is the worker state of the webhook delivery queue
+type webhookQueue struct {
+	deliveries *repository.WebhookDeliveryRepository
+	maxAge     time.Duration
+	retention  time.Duration
+
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	started  bool
+	stopOnce sync.Once
+}
+
+// ConfigureWebhookQueue sends webhook events through the delivery queue.
+// Deliveries are given up on maxAge after the event, and delivered ones are
+// pruned after retention (0 keeps them). StartWebhookQueue starts sending.
+func (s *WhatsAppService) ConfigureWebhookQueue(deliveries *repository.WebhookDeliveryRepository, maxAge, retention time.Duration) {
+	s.webhookQueue = &webhookQueue{
+		deliveries: deliveries,
+		maxAge:     maxAge,
+		retention:  retention,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// StartWebhookQueue starts the worker sending queued webhook deliveries
+func (s *WhatsAppService) StartWebhookQueue() {
+	if s.webhookQueue == nil || s.webhookQueue.started {
+		return
+	}
+	s.webhookQueue.started = true
+	go s.runWebhookQueue()
+}
+
+// StopWebhookQueue stops the worker once the deliveries it is sending are done
+func (s *WhatsAppService) StopWebhookQueue() {
+	q := s.webhookQueue
+	if q == nil {
+		return
+	}
+	q.stopOnce.Do(func() {
+		close(q.stop)
+		if q.started {
+			<-q.done
+		}
+	})
+}
+
+// notify wakes the worker to look for due deliveries
+func (q *webhookQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stopped reports whether the worker is asked to stop
+func (q *webhookQueue) stopped() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliverWebhook queues a webhook event for delivery. Without a queue, or if
+// the event cannot be queued, it is sent once and dropped if that fails.
+func (s *WhatsAppService) deliverWebhook(session *models.Session, eventType string, payload any) {
+	if q := s.webhookQueue; q != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			s.logger.Error("Failed to encode %s webhook event for session %s: %v", eventType, session.ID, err)
+			return
+		}
+		err = q.deliveries.Enqueue(session.ID, eventType, data, time.Now().Add(q.maxAge))
+		if err == nil {
+			q.notify()
+			return
+		}
+		s.logger.Error("Failed to queue %s webhook event for session %s, sending it once: %v", eventType, session.ID, err)
+	}
+
+	if s.isWebhookSuspended(session) {
+		s.logger.Debug("Webhook for session %s is suspended, dropping %s event", session.ID, eventType)
+		return
+	}
+	if err := s.sendWebhookHTTP(session, payload); err != nil {
+		s.logger.Error("Webhook %s failed for session %s: %v", eventType, session.ID, err)
+		s.recordWebhookFailure(session)
+		return
+	}
+	s.recordWebhookSuccess(session)
+}
+
+// runWebhookQueue sends due deliveries whenever it is woken or polls, and
+// prunes delivered ones, until the queue is stopped
+func (s *WhatsAppService) runWebhookQueue() {
+	q := s.webhookQueue
+	defer close(q.done)
+
+	poll := time.NewTicker(webhookQueuePollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(webhookPruneInterval)
+	defer prune.Stop()
+
+	s.pruneWebhookDeliveries()
+	for {
+		s.drainWebhookQueue()
+
+		select {
+		case <-q.stop:
+			return
+		case <-prune.C:
+			s.pruneWebhookDeliveries()
+		case <-q.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// drainWebhookQueue sends the due deliveries a batch at a time until none is
+// left, sending to several sessions at once
+func (s *WhatsAppService) drainWebhookQueue() {
+	q := s.webhookQueue
+	for !q.stopped() {
+		now := time.Now()
+		leaseUntil := now.Add(webhookQueueLease)
+		due, err := q.deliveries.ClaimDue(now, leaseUntil, webhookQueueBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to load due webhook deliveries: %v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		var sessionIDs []string
+		bySession := make(map[string][]*models.WebhookDelivery)
+		for _, delivery := range due {
+			if _, ok := bySession[delivery.SessionID]; !ok {
+				sessionIDs = append(sessionIDs, delivery.SessionID)
+			}
+			bySession[delivery.SessionID] = append(bySession[delivery.SessionID], delivery)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		stored := true
+		workers := make(chan struct{}, webhookQueueWorkers)
+		for _, sessionID := range sessionIDs {
+			workers <- struct{}{}
+			wg.Add(1)
+			go func(sessionID string, deliveries []*models.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				if !s.sendWebhookDeliveries(sessionID, deliveries, leaseUntil) {
+					mu.Lock()
+					stored = false
+					mu.Unlock()
+				}
+			}(sessionID, bySession[sessionID])
+		}
+		wg.Wait()
+
+		// A delivery whose outcome could not be stored is still due; wait for
+		// the next poll rather than sending it again at once
+		if !stored || len(due) < webhookQueueBatchSize {
+			return
+		}
+	}
+}
+
+// sendWebhookDeliveries sends the due deliveries of a session, leased until
+// leaseUntil, in order, stopping at the first that fails. It returns false if
+// an outcome could not be stored.
+func (s *WhatsAppService) sendWebhookDeliveries(sessionID string, deliveries []*models.WebhookDelivery, leaseUntil time.Time) bool {
+	q := s.webhookQueue
+
+	// Deliveries of a deleted session are deleted with it, so one that is not
+	// loaded may still come back; its deliveries wait for it
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		if err := q.deliveries.PostponeSession(sessionID, time.Now().Add(webhookRetryMaxDelay)); err != nil {
+			s.logger.Error("%v", err)
+			return false
+		}
+		return true
+	}
+	if session.WebhookURL == "" {
+		if _, err := q.deliveries.DiscardSession(sessionID, "session has no webhook URL"); err != nil {
+			s.logger.Error("%v", err)
+			return false
+		}
+		return true
+	}
+
+	for i, delivery := range deliveries {
+		// Deliveries still due when the webhook is suspended are held until
+		// it is resumed. Those left are given back to be claimed again.
+		if q.stopped() || s.isWebhookSuspended(session) || time.Until(leaseUntil) < httpclient.DefaultTimeout {
+			ids := make([]int64, 0, len(deliveries)-i)
+			for _, rest := range deliveries[i:] {
+				ids = append(ids, rest.ID)
+			}
+			if err := q.deliveries.Unclaim(ids, leaseUntil); err != nil {
+				s.logger.Error("%v", err)
+				return false
+			}
+			return true
+		}
+
+		attempts := delivery.Attempts + 1
+		err := s.sendWebhookHTTP(session, delivery.Payload)
+		if err == nil {
+			s.recordWebhookSuccess(session)
+			s.logger.Debug("Webhook %s delivery %d sent for session %s", delivery.EventType, delivery.ID, sessionID)
+			if err := q.deliveries.MarkDelivered(delivery.ID, attempts); err != nil {
+				s.logger.Error("%v", err)
+				return false
+			}
+			continue
+		}
+
+		s.recordWebhookFailure(session)
+		now := time.Now()
+		if !now.Before(delivery.ExpiresAt) {
+			s.logger.Error("Webhook %s delivery %d for session %s failed after %d attempt(s), giving up: %v",
+				delivery.EventType, delivery.ID, sessionID, attempts, err)
+			if err := q.deliveries.MarkFailed(delivery.ID, attempts, err.Error()); err != nil {
+				s.logger.Error("%v", err)
+				return false
+			}
+			continue
+		}
+
+		next := now.Add(webhookRetryDelay(attempts))
+		s.logger.Warn("Webhook %s delivery %d for session %s failed (attempt %d), retrying at %s: %v",
+			delivery.EventType, delivery.ID, sessionID, attempts, models.FormatTimestamp(next), err)
+		if err := q.deliveries.MarkRetry(delivery.ID, attempts, next, err.Error()); err != nil {
+			s.logger.Error("%v", err)
+			return false
+		}
+		if err := q.deliveries.PostponeSession(sessionID, next); err != nil {
+			s.logger.Error("%v", err)
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+// webhookRetryDelay returns how long to wait before retrying a delivery that
+// has failed attempts times, doubling from webhookRetryBaseDelay
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// pruneWebhookDeliveries deletes deliveries delivered longer ago than the retention
+func (s *WhatsAppService) pruneWebhookDeliveries() {
+	q := s.webhookQueue
+	if q.retention <= 0 {
+		return
+	}
+
+	pruned, err := q.deliveries.PruneDelivered(time.Now().Add(-q.retention))
+	if err != nil {
+		s.logger.Error("%v", err)
+		return
+	}
+	if pruned > 0 {
+		s.logger.Info("Pruned %d delivered webhook delivery(ies)", pruned)
+	}
+}
+
+// ListWebhookDeliveries returns a page of queued webhook deliveries, newest
+// first, only those with status and of sessionID unless they are empty
+func (s *WhatsAppService) ListWebhookDeliveries(status, sessionID string, page, limit int) (*models.WebhookDeliveryList, error) {
+	if s.webhookQueue == nil {
+		return nil, models.NewServiceUnavailableError("webhook delivery queue is not configured")
+	}
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return nil, models.NewBadRequestError("status must be %s, %s or %s",
+			models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed)
+	}
+
+	deliveries, total, err := s.webhookQueue.deliveries.List(status, sessionID, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WebhookDeliveryList{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		Pages:      (total + limit - 1) / limit,
+	}, nil
+}
+
+// RetryWebhookDelivery queues a failed delivery again to be sent at once,
+// retried with backoff like a new one. A session whose webhook is suspended
+// holds it until the webhook is resumed.
+func (s *WhatsAppService) RetryWebhookDelivery(id int64) (*models.WebhookDelivery, error) {
+	q := s.webhookQueue
+	if q == nil {
+		return nil, models.NewServiceUnavailableError("webhook delivery queue is not configured")
+	}
+
+	if err := q.deliveries.Retry(id, time.Now().Add(q.maxAge)); err != nil {
+		return nil, err
+	}
+	q.notify()
+
+	return q.deliveries.Get(id)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+// Webhook secrets must be long enough not to be guessed and fit their column
+const (
+	minWebhookSecretLength = 16
+	maxWebhookSecretLength = 255
+)
+
+// Headers carrying the signature of a webhook request
+const (
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// validateWebhookSecret checks a new webhook secret; empty disables signing
+func validateWebhookSecret(secret string) error {
+	if secret == "" {
+		return nil
+	}
+	if len(secret) < minWebhookSecretLength || len(secret) > maxWebhookSecretLength {
+		return models.NewBadRequestError("webhook_secret must be %d to %d characters", minWebhookSecretLength, maxWebhookSecretLength)
+	}
+	return nil
+}
+
+// signWebhookRequest sets the signature headers of a webhook request. The
+// signature is the hex HMAC-SHA256 of the timestamp, a dot and the body, so a
+// captured request cannot be replayed with a fresh timestamp.
+func signWebhookRequest(req *http.Request, secret string, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
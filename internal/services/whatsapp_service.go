@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math/big"
 	"mime"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mau.fi/whatsmeow"
@@ -29,7 +31,13 @@ import (
 
 	"whatsapp-multi-session/internal/models"
 	"whatsapp-multi-session/internal/repository"
+	"whatsapp-multi-session/internal/utils"
+	"whatsapp-multi-session/pkg/audio"
+	"whatsapp-multi-session/pkg/httpclient"
 	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/metrics"
+	"whatsapp-multi-session/pkg/phone"
+	"whatsapp-multi-session/pkg/storage"
 
 	// Import SQLite driver for whatsmeow store (library requirement)
 	_ "github.com/mattn/go-sqlite3"
@@ -39,13 +47,69 @@ import (
 type WhatsAppService struct {
 	sessions      map[string]*models.Session
 	store         *sqlstore.Container
+	storePath     string
+	restore       restoreProgress
 	sessionRepo   *repository.SessionRepository
 	messageRepo   *repository.MessageRepository
+	storage       storage.Storage
+	httpClients   *httpclient.Pool
+	publicBaseURL string
+	requestBase   atomic.Value // base URL of the latest API request, see PublicURL
+	webhooks      *webhookCircuit
+	webhookQueue  *webhookQueue
+	notifyURL     string
+	banTimers     map[string]*time.Timer
+	reconnect     reconnectPolicy
+	reconnectTimers map[string]*time.Timer
+	uploads       *uploadCache
+	mediaDownloadsMu sync.Mutex
+	mediaDownloads map[string]*mediaDownload
+	labels        *repository.LabelRepository
+	broadcastLists *repository.BroadcastListRepository
+	broadcastSends broadcastSendTracker
+	sessionBulk   sessionBulkJobs
+	users         *repository.UserRepository
+	contactSeen   *repository.ContactSeenRepository
+	contacts      *repository.ContactRepository
+	activity      *ContactActivityService
+	unread        unreadTracker
+	typing        typingTracker
+	pairCodes     pairCodeHub
+	statuses      sessionStatusHub
+	qrLogins      qrHub
+	sandboxMode   bool
+	sandboxReceiptDelay time.Duration
+	ffmpeg        *audio.FFmpeg
+	maxDownloadSize int64
+	downloadTimeout time.Duration
+	guard         outboundGuard
+	// pairingClient returns the client the post-pairing restart drives,
+	// the session's own when nil
+	pairingClient func(session *models.Session) connectionClient
+	autoReplies   *AutoReplyService
+	tasks         backgroundTasks
 	logger        *logger.Logger
 	mu            sync.RWMutex
+
+	// ctx is cancelled by Shutdown, ending connection attempts and other session lifecycle goroutines
+	ctx           context.Context
+	cancel        context.CancelFunc
 	eventHandlers map[string]func(*events.Message)
 }
 
+// connectionClient is what the post-pairing restart needs from a WhatsApp
+// client; *whatsmeow.Client implements it
+type connectionClient interface {
+	Connect() error
+	Disconnect()
+	IsConnected() bool
+	IsLoggedIn() bool
+}
+
+// postPairReconnectWindow is how long after events.PairSuccess a disconnect is
+// treated as WhatsApp's post-pairing restart (stream error 515) rather than a failure
+const postPairReconnectWindow = 30 * time.Second
+
 // UserAgentData contains browser and OS information for randomization
 type UserAgentData struct {
 	Browser   string
@@ -191,6 +255,8 @@ func NewWhatsAppService(
 	dbPath string,
 	sessionRepo *repository.SessionRepository,
 	messageRepo *repository.MessageRepository,
+	mediaStorage storage.Storage,
+	httpClients *httpclient.Pool,
 	log *logger.Logger,
 ) (*WhatsAppService, error) {
 	// Ensure directory exists for WhatsApp database
@@ -227,11 +293,23 @@ func NewWhatsAppService(
 	service := &WhatsAppService{
 		sessions:      make(map[string]*models.Session),
 		store:         container,
+		storePath:     dbPath,
 		sessionRepo:   sessionRepo,
 		messageRepo:   messageRepo,
+		storage:       mediaStorage,
+		httpClients:   httpClients,
 		logger:        log,
 		eventHandlers: make(map[string]func(*events.Message)),
+		banTimers:     make(map[string]*time.Timer),
+		reconnect: reconnectPolicy{
+			maxAttempts: defaultReconnectMaxAttempts,
+			baseDelay:   defaultReconnectBaseDelay,
+			maxDelay:    defaultReconnectMaxDelay,
+		},
+		reconnectTimers: make(map[string]*time.Timer),
 	}
+	service.ctx, service.cancel = context.WithCancel(context.Background())
+	service.tasks.ctx, service.tasks.cancel = context.WithCancel(context.Background())
 
 	// Load existing sessions
 	if err := service.loadExistingSessions(); err != nil {
@@ -243,6 +321,19 @@ func NewWhatsAppService(
 
 // CreateSession creates a new WhatsApp session
 func (s *WhatsAppService) CreateSession(req *models.CreateSessionRequest, userID int, userRole string) (*models.Session, error) {
+	// Resolved before locking, the lookup may take a while
+	if err := s.validateWebhookURL(req.WebhookURL); err != nil {
+		return nil, err
+	}
+	if err := s.validateWebhookProxyURL(req.WebhookProxyURL); err != nil {
+		return nil, err
+	}
+	owner, err := s.resolveSessionOwner(req, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	userID = owner.ID
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -259,35 +350,40 @@ func (s *WhatsAppService) CreateSession(req *models.CreateSessionRequest, userID
 		return nil, fmt.Errorf("session %s already exists", sessionID)
 	}
 
-	// Check session limit (only for non-admin users)
-	if userRole != "admin" {
-		// Get current session count for this user
-		currentSessionCount, err := s.sessionRepo.CountByUserID(userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check session count: %v", err)
-		}
-
-		// Default limit (this should come from user's record in the future)
-		sessionLimit := 5
+	if err := req.ProxyConfig.Validate(); err != nil {
+		return nil, models.NewBadRequestError("invalid proxy: %v", err)
+	}
+	if err := validateWebhookSecret(req.WebhookSecret); err != nil {
+		return nil, err
+	}
 
-		if currentSessionCount >= sessionLimit {
-			return nil, fmt.Errorf("session limit reached. You can create maximum %d sessions", sessionLimit)
+	// Check the owner's session limit (sandbox sessions are free)
+	if !req.Sandbox && !s.sandboxMode {
+		if err := s.checkSessionLimit(owner); err != nil {
+			return nil, err
 		}
 	}
 
+	sandbox := req.Sandbox || s.sandboxMode
+
 	// Create device store with error handling
-	deviceStore := s.store.NewDevice()
-	if deviceStore == nil {
-		return nil, fmt.Errorf("failed to create device store for session %s", sessionID)
-	}
+	var deviceStore *store.Device
+	if sandbox {
+		deviceStore = s.newSandboxDevice(sessionID)
+	} else {
+		deviceStore = s.store.NewDevice()
+		if deviceStore == nil {
+			return nil, fmt.Errorf("failed to create device store for session %s", sessionID)
+		}
 
-	// Set random device properties for this session
-	s.setRandomDeviceProps(deviceStore)
-	
-	// Pre-save the device to avoid foreign key constraints during pairing
-	if err := deviceStore.Save(context.Background()); err != nil {
-		s.logger.Warn("Failed to pre-save device for session %s: %v", sessionID, err)
-		// Continue anyway as this might not be critical
+		// Set random device properties for this session
+		s.setRandomDeviceProps(deviceStore)
+
+		// Pre-save the device to avoid foreign key constraints during pairing
+		if err := deviceStore.Save(context.Background()); err != nil {
+			s.logger.Warn("Failed to pre-save device for session %s: %v", sessionID, err)
+			// Continue anyway as this might not be critical
+		}
 	}
 
 	// Create WhatsApp client with error handling
@@ -299,31 +395,49 @@ func (s *WhatsAppService) CreateSession(req *models.CreateSessionRequest, userID
 		return nil, fmt.Errorf("failed to create WhatsApp client for session %s", sessionID)
 	}
 
-	// Enable auto-reconnect like in original
-	client.EnableAutoReconnect = true
+	// Dropped connections are reconnected by the supervisor (see scheduleReconnect)
+	client.EnableAutoReconnect = false
 	client.AutoTrustIdentity = true
+	// Handle the post-pairing 515 restart ourselves (see reconnectAfterPairing)
+	client.DisableLoginAutoReconnect = true
+	// Ask the primary phone to resend messages we fail to decrypt
+	client.AutomaticMessageRerequestFromPhone = true
+	// Emit app state events during full syncs so chat labels get mirrored
+	client.EmitAppStateEventsOnFullSync = true
 
-	// Create session - default enabled to true unless specified otherwise
-	enabled := true
-	if req.Enabled {
-		enabled = req.Enabled
+	// Route the connection through the session's proxy before it is ever opened
+	if err := applyProxy(client, req.ProxyConfig); err != nil {
+		return nil, models.NewBadRequestError("failed to apply proxy: %v", err)
 	}
 
+	// Create session - default enabled to true unless specified otherwise
+	enabled := req.Enabled == nil || *req.Enabled
+	receiveReceipts := req.ReceiveReceipts == nil || *req.ReceiveReceipts
+	persistMedia := req.PersistMedia == nil || *req.PersistMedia
+
 	session := &models.Session{
 		ID:            sessionID,
 		Phone:         phoneForDisplay,
 		Name:          req.Name,
 		Position:      req.Position,
 		WebhookURL:    req.WebhookURL,
-		AutoReplyText: req.AutoReplyText,
 		ProxyConfig:   req.ProxyConfig,
+		ProxyStatus:   initialProxyStatus(req.ProxyConfig),
 		Enabled:       enabled,
 		UserID:        userID,
+		WebhookProxyURL: req.WebhookProxyURL,
+		WebhookLegacyFormat: req.WebhookLegacyFormat,
+		WebhookSecret: req.WebhookSecret,
+		ReceiveReceipts: receiveReceipts,
+		ReceiveStatus: req.ReceiveStatus,
+		AllowViewOnceCapture: req.AllowViewOnceCapture,
+		PersistMedia:  persistMedia,
+		AutoRepliesEnabled: true,
+		Sandbox:       sandbox,
+		CreatedAt:     time.Now(),
 		Client:        client,
-		Connected:     false,
-		LoggedIn:      false,
-		Connecting:    false,
 	}
+	session.SetAutoReply(req.AutoReplyText)
 
 	// Set up event handlers
 	s.setupEventHandlers(session)
@@ -342,7 +456,16 @@ func (s *WhatsAppService) CreateSession(req *models.CreateSessionRequest, userID
 		ProxyConfig:   req.ProxyConfig,
 		Enabled:       enabled,
 		UserID:        userID,
-		CreatedAt:     time.Now(),
+		WebhookProxyURL: req.WebhookProxyURL,
+		WebhookLegacyFormat: req.WebhookLegacyFormat,
+		WebhookSecret: req.WebhookSecret,
+		ReceiveReceipts: receiveReceipts,
+		ReceiveStatus: req.ReceiveStatus,
+		AllowViewOnceCapture: req.AllowViewOnceCapture,
+		PersistMedia:  persistMedia,
+		AutoRepliesEnabled: true,
+		Sandbox:       sandbox,
+		CreatedAt:     session.CreatedAt,
 	}
 
 	if err := s.sessionRepo.Create(metadata); err != nil {
@@ -350,10 +473,48 @@ func (s *WhatsAppService) CreateSession(req *models.CreateSessionRequest, userID
 		return nil, fmt.Errorf("failed to save session metadata: %v", err)
 	}
 
+	if sandbox {
+		s.logger.Info("Created new sandbox session: %s", sessionID)
+		return session, nil
+	}
 	s.logger.Info("Created new session: %s", sessionID)
 	return session, nil
 }
 
+// sessionMetadata builds the persisted form of an in-memory session
+func sessionMetadata(session *models.Session) *models.SessionMetadata {
+	return &models.SessionMetadata{
+		ID:              session.ID,
+		Phone:           session.Phone,
+		ActualPhone:     session.ActualPhone,
+		Name:            session.Name,
+		Position:        session.Position,
+		WebhookURL:      session.WebhookURL,
+		AutoReplyText:   session.AutoReply(),
+		ProxyConfig:     session.ProxyConfig,
+		Enabled:         session.Enabled,
+		UserID:          session.UserID,
+		WebhookProxyURL: session.WebhookProxyURL,
+		WebhookLegacyFormat: session.WebhookLegacyFormat,
+		WebhookSecret:   session.WebhookSecret,
+		ReceiveReceipts: session.ReceiveReceipts,
+		ReceiveStatus:   session.ReceiveStatus,
+		AllowViewOnceCapture: session.AllowViewOnceCapture,
+		PersistMedia:    session.PersistMedia,
+		WebhookEvents:   session.WebhookEvents,
+		WebhookSuspendedAt: session.WebhookSuspendedAt,
+		BannedUntil:     session.BannedUntil,
+		BanReason:       session.BanReason,
+		SendDefaults:    session.SendDefaults,
+		NewContactSince: session.NewContactSince,
+		NewContactCreateContact: session.NewContactCreateContact,
+		AutoRepliesEnabled: session.AutoRepliesEnabled,
+		Tags:            session.Tags,
+		Sandbox:         session.Sandbox,
+		CreatedAt:       session.CreatedAt,
+	}
+}
+
 // GetSession returns a session by ID
 func (s *WhatsAppService) GetSession(sessionID string) (*models.Session, bool) {
 	s.mu.RLock()
@@ -440,32 +601,30 @@ func (s *WhatsAppService) ConnectSession(sessionID string) error {
 		return models.NewBadRequestError("session %s is disabled and cannot be connected", sessionID)
 	}
 
-	if session.Connecting {
+	// Checking and starting the attempt at once keeps concurrent connects from both starting one
+	previous, started := session.StartConnecting()
+	if previous.Connecting {
 		return models.NewBadRequestError("session %s is already connecting", sessionID)
 	}
-
-	if session.Connected {
+	if !started {
 		return models.NewBadRequestError("session %s is already connected", sessionID)
 	}
 
-	session.Connecting = true
+	if session.Sandbox {
+		s.sandboxLogin(session)
+		return nil
+	}
 
 	go func() {
-		defer func() {
-			// Only clear connecting flag if we didn't successfully connect
-			if !session.Connected {
-				s.mu.Lock()
-				session.Connecting = false
-				s.mu.Unlock()
-			}
-		}()
+		// Whatever happens, the attempt is over when this returns
+		defer session.SetConnecting(false)
 
 		s.logger.Info("Attempting to connect session %s...", sessionID)
 
 		if err := session.Client.Connect(); err != nil {
 			s.logger.Error("Failed to connect session %s: %v", sessionID, err)
 			s.mu.Lock()
-			session.Connecting = false
+			recordProxyFailure(session)
 			s.mu.Unlock()
 			return
 		}
@@ -473,32 +632,27 @@ func (s *WhatsAppService) ConnectSession(sessionID string) error {
 		s.logger.Info("Connect() called for session %s, waiting for connection event...", sessionID)
 
 		// Wait up to 30 seconds for the connection to be established
-		timeout := time.After(30 * time.Second)
+		timeout := time.NewTimer(30 * time.Second)
+		defer timeout.Stop()
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-timeout:
+			case <-s.ctx.Done():
+				return
+			case <-timeout.C:
 				s.logger.Warn("Session %s connection timed out after 30 seconds", sessionID)
-				s.mu.Lock()
-				session.Connecting = false
-				s.mu.Unlock()
 				return
 			case <-ticker.C:
-				s.mu.Lock()
-				isConnected := session.Client.IsConnected() && session.Connected
-				s.mu.Unlock()
-
-				if isConnected {
+				clientConnected := session.Client.IsConnected()
+				connected := session.IsConnected()
+				if clientConnected && connected {
 					s.logger.Info("Session %s connection established successfully", sessionID)
-					s.mu.Lock()
-					session.Connecting = false
-					s.mu.Unlock()
 					return
 				}
 				s.logger.Debug("Session %s still connecting... (IsConnected: %v, Connected: %v)",
-					sessionID, session.Client.IsConnected(), session.Connected)
+					sessionID, clientConnected, connected)
 			}
 		}
 	}()
@@ -516,31 +670,46 @@ func (s *WhatsAppService) DisconnectSession(sessionID string) error {
 		return models.NewNotFoundError("session %s not found", sessionID)
 	}
 
+	s.cancelReconnect(session)
 	session.Client.Disconnect()
-	session.Connected = false
-	session.LoggedIn = false
+	session.SetConnected(false, false)
+	recordDisconnectReason(session, "disconnected by request")
 
 	s.logger.Info("Session %s disconnected", sessionID)
+	go s.publishSessionStatus(session, models.SessionStatusDisconnected, "disconnected by request")
 	return nil
 }
 
-// DeleteSession removes a session completely
-func (s *WhatsAppService) DeleteSession(sessionID string) error {
+// DeleteSession removes a session completely, unlinking its device from the
+// phone and wiping it from the device store. With purgeData the session's
+// logged messages and stored media are deleted as well.
+func (s *WhatsAppService) DeleteSession(sessionID string, purgeData bool) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	session, exists := s.sessions[sessionID]
 	if !exists {
+		s.mu.Unlock()
 		return models.NewNotFoundError("session %s not found", sessionID)
 	}
 
-	// Disconnect if connected
-	if session.Connected {
-		session.Client.Disconnect()
-	}
-
 	// Remove from memory
 	delete(s.sessions, sessionID)
+	s.stopQRLogin(sessionID)
+	if timer, exists := s.banTimers[sessionID]; exists {
+		timer.Stop()
+		delete(s.banTimers, sessionID)
+	}
+	s.stopReconnect(sessionID)
+	s.unread.forget(sessionID)
+	s.typing.forget(sessionID)
+	s.mu.Unlock()
+
+	// Unlinked from the phone and wiped from the device store outside the
+	// lock, the logout is a round trip to WhatsApp
+	s.unlinkDevice(session)
+
+	if purgeData {
+		s.purgeSessionData(sessionID)
+	}
 
 	// Remove from database
 	if err := s.sessionRepo.Delete(sessionID); err != nil {
@@ -563,33 +732,16 @@ func (s *WhatsAppService) LoginSession(sessionID string) error {
 		return models.NewBadRequestError("session %s is disabled and cannot be logged in", sessionID)
 	}
 
-	if session.LoggedIn {
+	if session.IsLoggedIn() {
 		return models.NewBadRequestError("session %s is already logged in", sessionID)
 	}
 
-	// Connect if not connected
-	if !session.Connected {
-		if err := s.ConnectSession(sessionID); err != nil {
-			return fmt.Errorf("failed to connect session for login: %v", err)
-		}
-
-		// Wait a bit for connection to establish
-		time.Sleep(2 * time.Second)
+	// Sandbox sessions log in as soon as they connect
+	if session.Sandbox {
+		return s.ConnectSession(sessionID)
 	}
 
-	// Start QR code generation for login
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	qrChan, err := session.Client.GetQRChannel(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get QR channel: %v", err)
-	}
-
-	session.QRChan = qrChan
-	s.logger.Info("QR code channel created for session %s", sessionID)
-
-	return nil
+	return s.StartQRLogin(sessionID)
 }
 
 // LogoutSession logs out a session
@@ -599,10 +751,15 @@ func (s *WhatsAppService) LogoutSession(sessionID string) error {
 		return models.NewNotFoundError("session %s not found", sessionID)
 	}
 
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return models.NewBadRequestError("session %s is not logged in", sessionID)
 	}
 
+	if session.Sandbox {
+		s.sandboxLogout(session)
+		return nil
+	}
+
 	// Perform logout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -613,119 +770,128 @@ func (s *WhatsAppService) LogoutSession(sessionID string) error {
 	}
 
 	// Update session state
-	s.mu.Lock()
-	session.LoggedIn = false
-	s.mu.Unlock()
+	session.SetLoggedIn(false)
+	recordDisconnectReason(session, "logged out by request")
 
 	s.logger.Info("Session %s logged out successfully", sessionID)
+	s.publishSessionStatus(session, models.SessionStatusLoggedOut, "logged out by request")
 	return nil
 }
 
-// GetQRCode returns QR code for session login
-func (s *WhatsAppService) GetQRCode(sessionID string) (string, error) {
-	session, exists := s.GetSession(sessionID)
-	if !exists {
-		return "", models.NewNotFoundError("session %s not found", sessionID)
-	}
-
-	if session.LoggedIn {
-		return "", models.NewBadRequestError("session %s is already logged in", sessionID)
-	}
-
-	var qrChan <-chan whatsmeow.QRChannelItem
-	var err error
-
-	// Use existing QR channel if available (from LoginSession), otherwise create new one
-	if session.QRChan != nil {
-		s.logger.Info("Using existing QR channel for session %s", sessionID)
-		qrChan = session.QRChan
-	} else {
-		s.logger.Info("Creating new QR channel for session %s", sessionID)
-		// Start QR code generation
-		qrChan, err = session.Client.GetQRChannel(context.Background())
-		if err != nil {
-			return "", fmt.Errorf("failed to get QR channel: %v", err)
+// UpdateSession updates session metadata and returns the new version. With
+// req.ExpectedVersion set, a session changed since that version is left
+// untouched and a ConflictError is returned.
+func (s *WhatsAppService) UpdateSession(sessionID string, req *models.UpdateSessionRequest) (int64, error) {
+	if req.WebhookURL != nil {
+		if err := s.validateWebhookURL(*req.WebhookURL); err != nil {
+			return 0, err
 		}
-		session.QRChan = qrChan
 	}
-
-	// Wait for QR code with shorter timeout for existing channel, longer for new one
-	timeout := 10 * time.Second
-	if session.QRChan == nil {
-		timeout = 30 * time.Second
-	}
-
-	select {
-	case qr, ok := <-qrChan:
-		if !ok {
-			// Channel closed, return error
-			return "", fmt.Errorf("QR channel closed")
-		}
-		if qr.Event == "code" {
-			s.logger.Info("QR code received for session %s", sessionID)
-			return qr.Code, nil
+	if req.WebhookProxyURL != nil {
+		if err := s.validateWebhookProxyURL(*req.WebhookProxyURL); err != nil {
+			return 0, err
 		}
-		// QR expired or other event
-		if qr.Event == "timeout" {
-			return "", fmt.Errorf("QR code expired")
-		}
-		return "", fmt.Errorf("unexpected QR event: %s", qr.Event)
-	case <-time.After(timeout):
-		return "", fmt.Errorf("timeout waiting for QR code")
 	}
-}
 
-// UpdateSession updates session metadata
-func (s *WhatsAppService) UpdateSession(sessionID string, req *models.UpdateSessionRequest) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	session, exists := s.sessions[sessionID]
 	if !exists {
-		return models.NewNotFoundError("session %s not found", sessionID)
+		return 0, models.NewNotFoundError("session %s not found", sessionID)
 	}
 
-	// Get existing metadata from database to get user_id
-	existingMetadata, err := s.sessionRepo.GetByID(sessionID)
-	if err != nil {
-		return fmt.Errorf("failed to get existing session metadata: %v", err)
+	if req.WebhookSecret != nil {
+		if err := validateWebhookSecret(*req.WebhookSecret); err != nil {
+			return 0, err
+		}
 	}
-	if existingMetadata == nil {
-		return models.NewNotFoundError("session metadata not found in database for session %s", sessionID)
+	if err := req.ProxyConfig.Validate(); err != nil {
+		return 0, models.NewBadRequestError("invalid proxy: %v", err)
 	}
 
-	// Update in-memory session
+	// Build the new settings first; the in-memory session only changes once they are saved
+	metadata := sessionMetadata(session)
 	if req.Name != "" {
-		session.Name = req.Name
+		metadata.Name = req.Name
 	}
-	if req.WebhookURL != "" {
-		session.WebhookURL = req.WebhookURL
+	if req.WebhookURL != nil {
+		metadata.WebhookURL = *req.WebhookURL
+	}
+	if req.Position != 0 {
+		metadata.Position = req.Position
 	}
 	if req.AutoReplyText != nil {
-		session.AutoReplyText = req.AutoReplyText
+		metadata.AutoReplyText = req.AutoReplyText
 	}
 	if req.ProxyConfig != nil {
-		session.ProxyConfig = req.ProxyConfig
+		metadata.ProxyConfig = req.ProxyConfig
 	}
 	if req.Enabled != nil {
-		session.Enabled = *req.Enabled
+		metadata.Enabled = *req.Enabled
+	}
+	if req.WebhookProxyURL != nil {
+		metadata.WebhookProxyURL = *req.WebhookProxyURL
+	}
+	if req.WebhookLegacyFormat != nil {
+		metadata.WebhookLegacyFormat = *req.WebhookLegacyFormat
+	}
+	if req.WebhookSecret != nil {
+		metadata.WebhookSecret = *req.WebhookSecret
+	}
+	if req.ReceiveReceipts != nil {
+		metadata.ReceiveReceipts = *req.ReceiveReceipts
+	}
+	if req.ReceiveStatus != nil {
+		metadata.ReceiveStatus = *req.ReceiveStatus
+	}
+	if req.AllowViewOnceCapture != nil {
+		metadata.AllowViewOnceCapture = *req.AllowViewOnceCapture
+	}
+	if req.PersistMedia != nil {
+		metadata.PersistMedia = *req.PersistMedia
 	}
 
-	// Update in database with correct user_id
-	metadata := &models.SessionMetadata{
-		ID:            sessionID,
-		Phone:         session.Phone,
-		ActualPhone:   session.ActualPhone,
-		Name:          session.Name,
-		Position:      req.Position,
-		WebhookURL:    session.WebhookURL,
-		AutoReplyText: session.AutoReplyText,
-		ProxyConfig:   session.ProxyConfig,
-		Enabled:       session.Enabled,
-		UserID:        existingMetadata.UserID, // Use the existing user_id from database
+	version, err := s.sessionRepo.UpdateSettings(metadata, req.ExpectedVersion)
+	if err == repository.ErrVersionConflict {
+		return version, models.NewConflictError(version, "session %s was modified since version %d; current version is %d", sessionID, *req.ExpectedVersion, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	proxyChanged := !metadata.ProxyConfig.Equal(session.ProxyConfig)
+
+	session.Name = metadata.Name
+	session.WebhookURL = metadata.WebhookURL
+	session.Position = metadata.Position
+	session.SetAutoReply(metadata.AutoReplyText)
+	session.ProxyConfig = metadata.ProxyConfig
+	session.Enabled = metadata.Enabled
+	session.WebhookProxyURL = metadata.WebhookProxyURL
+	session.WebhookLegacyFormat = metadata.WebhookLegacyFormat
+	session.WebhookSecret = metadata.WebhookSecret
+	session.ReceiveReceipts = metadata.ReceiveReceipts
+	session.ReceiveStatus = metadata.ReceiveStatus
+	session.AllowViewOnceCapture = metadata.AllowViewOnceCapture
+	session.PersistMedia = metadata.PersistMedia
+
+	// Reconnecting waits for the session lock, so it happens once this returns
+	if proxyChanged {
+		go func() {
+			if _, err := s.reapplyProxy(session); err != nil {
+				s.logger.Error("Failed to apply proxy change of session %s: %v", sessionID, err)
+			}
+		}()
 	}
 
-	return s.sessionRepo.Update(metadata)
+	return version, nil
+}
+
+// GetSessionVersion returns the version of a session's settings, which
+// changes with every update and is checked by conditional updates
+func (s *WhatsAppService) GetSessionVersion(sessionID string) (int64, error) {
+	return s.sessionRepo.GetVersion(sessionID)
 }
 
 // UpdateSessionAutoReply updates only the auto-reply text for a session
@@ -739,14 +905,24 @@ func (s *WhatsAppService) UpdateSessionAutoReply(sessionID string, autoReplyText
 	}
 
 	// Update in-memory session
-	session.AutoReplyText = autoReplyText
+	session.SetAutoReply(autoReplyText)
 
 	// Update only the auto-reply text in database using the dedicated method
 	return s.sessionRepo.UpdateAutoReplyText(sessionID, autoReplyText)
 }
 
-// UpdateSessionWebhook updates only the webhook URL for a session
-func (s *WhatsAppService) UpdateSessionWebhook(sessionID string, webhookURL string) error {
+// UpdateSessionWebhook updates only the webhook URL for a session and, unless
+// webhookSecret is nil, the secret its requests are signed with
+func (s *WhatsAppService) UpdateSessionWebhook(sessionID string, webhookURL string, webhookSecret *string, webhookEvents *[]string) error {
+	if err := s.validateWebhookURL(webhookURL); err != nil {
+		return err
+	}
+	if webhookEvents != nil {
+		if err := models.ValidateWebhookEvents(*webhookEvents); err != nil {
+			return err
+		}
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -755,14 +931,28 @@ func (s *WhatsAppService) UpdateSessionWebhook(sessionID string, webhookURL stri
 		return models.NewNotFoundError("session %s not found", sessionID)
 	}
 
-	// Update in-memory session
-	session.WebhookURL = webhookURL
+	secret := session.WebhookSecret
+	if webhookSecret != nil {
+		if err := validateWebhookSecret(*webhookSecret); err != nil {
+			return err
+		}
+		secret = *webhookSecret
+	}
+	events := session.WebhookEvents
+	if webhookEvents != nil {
+		events = *webhookEvents
+	}
 
 	// Update in database
-	if err := s.sessionRepo.UpdateSessionWebhook(sessionID, webhookURL); err != nil {
+	if err := s.sessionRepo.UpdateSessionWebhook(sessionID, webhookURL, secret, events); err != nil {
 		return fmt.Errorf("failed to update webhook URL in database: %v", err)
 	}
 
+	// Update in-memory session
+	session.WebhookURL = webhookURL
+	session.WebhookSecret = secret
+	session.WebhookEvents = events
+
 	s.logger.Info("Updated webhook URL for session %s: %s", sessionID, webhookURL)
 	return nil
 }
@@ -781,6 +971,9 @@ func (s *WhatsAppService) UpdateSessionEnabled(sessionID string, enabled bool) e
 
 	// Update in-memory session
 	session.Enabled = enabled
+	if !enabled {
+		s.cancelReconnect(session)
+	}
 	s.mu.Unlock()
 
 	// Update only the enabled status in database using the dedicated method
@@ -793,24 +986,32 @@ func (s *WhatsAppService) UpdateSessionEnabled(sessionID string, enabled bool) e
 		s.logger.Info("Session %s has been enabled, attempting to auto-connect", sessionID)
 
 		// Check if session has stored credentials and is not already connected
-		if !session.Connected && session.Client != nil {
+		if !session.IsConnected() && session.Client != nil {
 			go func() {
 				// Wait a moment to ensure everything is settled
-				time.Sleep(1 * time.Second)
+				select {
+				case <-s.ctx.Done():
+					return
+				case <-time.After(1 * time.Second):
+				}
 
 				s.logger.Info("Auto-connecting newly enabled session %s", sessionID)
 				if err := session.Client.Connect(); err != nil {
 					s.logger.Error("Failed to auto-connect enabled session %s: %v", sessionID, err)
+					s.mu.Lock()
+					recordProxyFailure(session)
+					s.mu.Unlock()
+					s.scheduleReconnect(session, fmt.Sprintf("connect failed: %v", err))
 				} else {
 					s.logger.Info("Successfully connected enabled session %s", sessionID)
 				}
 			}()
-		} else if session.Connected {
+		} else if session.IsConnected() {
 			s.logger.Info("Session %s is already connected", sessionID)
 		} else {
 			s.logger.Info("Session %s needs re-authentication (no stored credentials)", sessionID)
 		}
-	} else if !wasDisabled && !enabled && session.Connected {
+	} else if !wasDisabled && !enabled && session.IsConnected() {
 		// If session was enabled and is now disabled, disconnect it
 		s.logger.Info("Session %s has been disabled, disconnecting", sessionID)
 		go func() {
@@ -867,13 +1068,20 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 		switch v := evt.(type) {
 		case *events.Connected:
 			s.mu.Lock()
+			client := s.clientForPairing(session)
 			// Verify the client is actually connected before marking as connected
-			if session.Client.IsConnected() {
-				session.Connected = true
-				session.LoggedIn = session.Client.IsLoggedIn()
+			if client.IsConnected() {
+				session.SetConnected(true, client.IsLoggedIn())
+				s.stopReconnect(session.ID)
+				recordProxyConnected(session)
+				pairingDone := session.PairingFinalizing && session.IsLoggedIn()
+				if pairingDone {
+					session.PairingFinalizing = false
+					s.logger.Info("Session %s finished post-pairing registration", session.ID)
+				}
 
 				// Update actual phone number if logged in (like original)
-				if session.Client.IsLoggedIn() && session.Client.Store.ID != nil {
+				if client.IsLoggedIn() && session.Client.Store.ID != nil {
 					session.ActualPhone = session.Client.Store.ID.User + "@s.whatsapp.net"
 					s.logger.Info("Session %s actual phone: %s", session.ID, session.ActualPhone)
 
@@ -892,31 +1100,26 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 						}
 					}()
 
-					// Save updated metadata
-					go func() {
-						metadata := &models.SessionMetadata{
-							ID:            session.ID,
-							Phone:         session.Phone,
-							ActualPhone:   session.ActualPhone,
-							Name:          session.Name,
-							Position:      session.Position,
-							WebhookURL:    session.WebhookURL,
-							AutoReplyText: session.AutoReplyText,
-							ProxyConfig:   session.ProxyConfig,
-							Enabled:       session.Enabled,
-							UserID:        session.UserID,
-						}
-						if err := s.sessionRepo.Update(metadata); err != nil {
+					// Save the actual phone only: writing back the whole in-memory
+					// session would undo settings changed since it was loaded
+					go func(actualPhone string) {
+						if err := s.sessionRepo.UpdateActualPhone(session.ID, actualPhone); err != nil {
 							s.logger.Error("Failed to update session metadata: %v", err)
 						}
-					}()
+					}(session.ActualPhone)
 				}
 				s.mu.Unlock()
 				s.logger.Info("Session %s connected", session.ID)
+				s.publishSessionStatus(session, models.SessionStatusConnected, "")
+				if pairingDone {
+					s.publishSessionStatus(session, models.SessionStatusLoggedIn, "")
+				}
 			} else {
 				// Client reported Connected event but isn't actually connected
-				session.Connected = false
 				s.mu.Unlock()
+				session.UpdateState(func(state *models.SessionState) {
+					state.Connected = false
+				})
 
 				// TROUBLESHOOTING: This means whatsmeow fired Connected event but socket isn't connected
 				// Possible causes:
@@ -931,19 +1134,49 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 				s.logger.Error("  → Try recreating the session (delete and create new)")
 			}
 
-		case *events.Disconnected:
+		case *events.PairSuccess:
 			s.mu.Lock()
-			session.Connected = false
-			session.LoggedIn = false
+			session.PairedAt = time.Now()
+			session.PairingFinalizing = true
+			session.PairReconnected = false
 			s.mu.Unlock()
 
+			s.logger.Info("Session %s paired with %s, waiting for WhatsApp to finalize registration", session.ID, v.ID.String())
+
+		case *events.ManualLoginReconnect:
+			// WhatsApp sent stream error 515 after pairing and expects an immediate reconnect
+			s.logger.Info("Session %s received post-pairing restart request", session.ID)
+			s.reconnectAfterPairing(session)
+
+		case *events.Disconnected:
+			if s.isPostPairDisconnect(session) {
+				session.UpdateState(func(state *models.SessionState) {
+					state.Connected = false
+				})
+
+				s.logger.Info("Session %s disconnected right after pairing, reconnecting to finish registration", session.ID)
+				s.reconnectAfterPairing(session)
+				break
+			}
+
+			session.SetConnected(false, false)
+
+			reason := recentDisconnectReason(session, "connection closed")
 			s.logger.Info("Session %s disconnected", session.ID)
+			s.scheduleReconnect(session, reason)
+			s.publishSessionStatus(session, models.SessionStatusDisconnected, reason)
 
 		case *events.StreamError:
-			s.mu.Lock()
-			session.Connected = false
-			session.LoggedIn = false
-			s.mu.Unlock()
+			if s.isPostPairDisconnect(session) {
+				s.logger.Info("Session %s got stream error %s right after pairing, reconnecting to finish registration", session.ID, v.Code)
+				s.reconnectAfterPairing(session)
+				break
+			}
+
+			session.SetConnected(false, false)
+			// The disconnect that follows reconnects with this reason
+			recordDisconnectReason(session, fmt.Sprintf("stream error %s", v.Code))
+			s.publishSessionStatus(session, models.SessionStatusStreamError, fmt.Sprintf("stream error %s", v.Code))
 
 			// TROUBLESHOOTING: WhatsApp closed connection due to protocol error
 			// Possible causes:
@@ -957,28 +1190,19 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 			s.logger.Error("  → If issue persists, delete and recreate the session")
 
 		case *events.LoggedOut:
+			session.SetLoggedIn(false)
+			recordDisconnectReason(session, fmt.Sprintf("logged out: %s", v.Reason))
 			s.mu.Lock()
-			session.LoggedIn = false
 			session.ActualPhone = ""
+			s.cancelReconnect(session)
 			s.mu.Unlock()
 
 			s.logger.Info("Session %s logged out", session.ID)
+			s.publishSessionStatus(session, models.SessionStatusLoggedOut, v.Reason.String())
 
 			// Update database to clear actual phone
 			go func() {
-				metadata := &models.SessionMetadata{
-					ID:            session.ID,
-					Phone:         session.Phone,
-					ActualPhone:   "",
-					Name:          session.Name,
-					Position:      session.Position,
-					WebhookURL:    session.WebhookURL,
-					AutoReplyText: session.AutoReplyText,
-					ProxyConfig:   session.ProxyConfig,
-					Enabled:       session.Enabled,
-					UserID:        session.UserID,
-				}
-				if err := s.sessionRepo.Update(metadata); err != nil {
+				if err := s.sessionRepo.UpdateActualPhone(session.ID, ""); err != nil {
 					s.logger.Error("Failed to update session metadata after logout: %v", err)
 				}
 			}()
@@ -989,16 +1213,30 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 				handler(v)
 			}
 
+			if !v.Info.IsFromMe {
+				s.unread.track(session.ID, v)
+				metrics.MessagesReceived.Inc(session.ID)
+			}
+			s.recordMessageActivity(session, v)
+			s.goBackground(func() { s.logIncomingMessage(session, v) })
+
+			// Recorded before replying so auto-reply rules see the first contact
+			firstContact := s.detectFirstContact(session, v)
+			if firstContact {
+				go s.handleNewContact(session, v)
+			}
+
 			// Only process auto-reply and webhook if session is enabled
 			if session.Enabled {
-				// Send auto reply if configured and this is an incoming message
-				if session.AutoReplyText != nil && *session.AutoReplyText != "" && !v.Info.IsFromMe {
-					go s.sendAutoReply(session, v)
+				// Answer incoming messages by the auto-reply rules or auto reply text
+				if !v.Info.IsFromMe {
+					go s.autoReply(session, v)
 				}
 
 				// Send webhook if configured
 				if session.WebhookURL != "" {
-					go s.sendWebhook(session, v)
+					s.goBackground(func() { s.sendWebhook(session, v, firstContact) })
+					go s.handleStatusReaction(session, v)
 				}
 			} else {
 				// Log that the session is disabled and won't process messages
@@ -1007,6 +1245,49 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 				}
 			}
 
+		case *events.MarkChatAsRead:
+			if v.Action.GetRead() {
+				s.recordChatRead(session, v.JID)
+			}
+
+		case *events.LabelEdit:
+			s.handleLabelEdit(session, v)
+
+		case *events.LabelAssociationChat:
+			s.handleLabelAssociation(session, v)
+
+		case *events.AppState:
+			s.handleBroadcastListAction(session, v)
+
+		case *events.StreamReplaced:
+			session.SetConnected(false, false)
+			recordDisconnectReason(session, "replaced by another connection of the same device")
+			s.logger.Warn("Session %s was replaced by another connection and will not reconnect", session.ID)
+			s.publishSessionStatus(session, models.SessionStatusDisconnected, "replaced by another connection of the same device")
+
+		case *events.ClientOutdated:
+			session.SetConnected(false, false)
+			recordDisconnectReason(session, "client outdated")
+			session.UpdateState(func(state *models.SessionState) {
+				state.NeedsAttention = true
+			})
+			s.logger.Error("Session %s was rejected as outdated by WhatsApp, update whatsmeow: go get go.mau.fi/whatsmeow@latest", session.ID)
+			s.publishSessionStatus(session, models.SessionStatusDisconnected, "client outdated")
+
+		case *events.ConnectFailure:
+			// The disconnect that follows reconnects with this reason
+			recordDisconnectReason(session, fmt.Sprintf("connect failure: %s", v.Reason))
+
+		case *events.KeepAliveTimeout:
+			s.handleKeepAliveTimeout(session, v)
+
+		case *events.TemporaryBan:
+			recordDisconnectReason(session, fmt.Sprintf("temporarily banned: %s", v.Code))
+			s.handleTemporaryBan(session, v)
+
+		case *events.UndecryptableMessage:
+			s.handleUndecryptableMessage(session, v)
+
 		case *events.Receipt:
 			// Handle read/delivery receipts (seen events)
 			if !session.Enabled {
@@ -1031,22 +1312,117 @@ func (s *WhatsAppService) setupEventHandlers(session *models.Session) {
 			// Update message status in database if applicable
 			if status != "" && s.messageRepo != nil {
 				for _, msgID := range v.MessageIDs {
-					if err := s.messageRepo.UpdateMessageStatus(msgID, status, ""); err != nil {
+					if updated, err := s.messageRepo.UpdateMessageReceiptStatus(session.ID, msgID, status); err != nil {
 						s.logger.Debug("Failed to update message status for %s: %v", msgID, err)
-					} else {
+					} else if updated {
 						s.logger.Debug("Updated message %s status to %s", msgID, status)
 					}
 				}
 			}
 
-			// Send receipt webhook if configured
+			// Messages read on the phone or another linked device
+			if v.IsFromMe && (v.Type == types.ReceiptTypeRead || v.Type == types.ReceiptTypeReadSelf) {
+				s.recordChatRead(session, v.Chat)
+			}
+
+			// Send receipt webhook if configured, unless the session opted out
 			if session.WebhookURL != "" {
-				go s.sendReceiptWebhook(session, v, status)
+				if session.ReceiveReceipts {
+					s.goBackground(func() { s.sendReceiptWebhook(session, v, status) })
+				}
+				if v.Chat == types.StatusBroadcastJID {
+					go s.handleStatusReceipt(session, v)
+				}
 			}
 		}
 	})
 }
 
+// isPostPairDisconnect reports whether a disconnect is the server-initiated
+// restart WhatsApp performs right after a successful pairing
+func (s *WhatsAppService) isPostPairDisconnect(session *models.Session) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !session.PairingFinalizing || session.PairedAt.IsZero() {
+		return false
+	}
+	if session.Client.Store.ID == nil {
+		return false
+	}
+	return time.Since(session.PairedAt) < postPairReconnectWindow
+}
+
+// reconnectAfterPairing reconnects a freshly paired session once so WhatsApp can
+// finish registering the device. Subsequent calls are no-ops until the next pairing.
+func (s *WhatsAppService) reconnectAfterPairing(session *models.Session) {
+	s.mu.Lock()
+	if session.PairReconnected {
+		s.mu.Unlock()
+		return
+	}
+	session.PairReconnected = true
+	s.mu.Unlock()
+
+	client := s.clientForPairing(session)
+	go func() {
+		client.Disconnect()
+
+		if err := client.Connect(); err != nil && err != whatsmeow.ErrAlreadyConnected {
+			s.logger.Error("Failed to reconnect session %s after pairing: %v", session.ID, err)
+			s.mu.Lock()
+			session.PairingFinalizing = false
+			recordProxyFailure(session)
+			s.mu.Unlock()
+			return
+		}
+
+		s.logger.Info("Session %s reconnected after pairing", session.ID)
+	}()
+}
+
+// clientForPairing returns the client the post-pairing restart drives
+func (s *WhatsAppService) clientForPairing(session *models.Session) connectionClient {
+	if s.pairingClient != nil {
+		return s.pairingClient(session)
+	}
+	return session.Client
+}
+
+// WaitForPairingComplete blocks until a freshly paired session has reconnected
+// and is logged in, or the timeout expires
+func (s *WhatsAppService) WaitForPairingComplete(sessionID string, timeout time.Duration) error {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return models.NewNotFoundError("session %s not found", sessionID)
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline:
+			return fmt.Errorf("timed out waiting for session %s to finish pairing", sessionID)
+		case <-ticker.C:
+			s.mu.RLock()
+			finalizing := session.PairingFinalizing
+			state := session.State()
+			ready := state.Connected && state.LoggedIn
+			reconnected := session.PairReconnected
+			s.mu.RUnlock()
+
+			if !finalizing && ready {
+				return nil
+			}
+			if !finalizing && reconnected && !ready {
+				return fmt.Errorf("session %s failed to reconnect after pairing", sessionID)
+			}
+		}
+	}
+}
+
 // SetMessageHandler sets a message handler for a session
 func (s *WhatsAppService) SetMessageHandler(sessionID string, handler func(*events.Message)) {
 	s.mu.Lock()
@@ -1078,8 +1454,9 @@ func (s *WhatsAppService) loadExistingSessions() error {
 
 		// Find existing device in store (like original)
 		var deviceStore *store.Device
-		devices, err := s.store.GetAllDevices(context.Background())
-		if err != nil {
+		if metadata.Sandbox {
+			deviceStore = s.newSandboxDevice(metadata.ID)
+		} else if devices, err := s.store.GetAllDevices(context.Background()); err != nil {
 			s.logger.Error("Error getting devices: %v", err)
 		} else {
 			// Normalize session ID by removing @s.whatsapp.net suffix if present
@@ -1142,9 +1519,22 @@ func (s *WhatsAppService) loadExistingSessions() error {
 		clientLog := waLog.Stdout("Client:"+metadata.ID, "INFO", true)
 		client := whatsmeow.NewClient(deviceStore, clientLog)
 
-		// Enable auto-reconnect like in original
-		client.EnableAutoReconnect = true
+		// Dropped connections are reconnected by the supervisor (see scheduleReconnect)
+		client.EnableAutoReconnect = false
 		client.AutoTrustIdentity = true
+		// Handle the post-pairing 515 restart ourselves (see reconnectAfterPairing)
+		client.DisableLoginAutoReconnect = true
+		// Ask the primary phone to resend messages we fail to decrypt
+		client.AutomaticMessageRerequestFromPhone = true
+		// Emit app state events during full syncs so chat labels get mirrored
+		client.EmitAppStateEventsOnFullSync = true
+
+		// Route the connection through the session's proxy before it is ever opened
+		proxyStatus := initialProxyStatus(metadata.ProxyConfig)
+		if err := applyProxy(client, metadata.ProxyConfig); err != nil {
+			s.logger.Error("Failed to apply proxy of session %s: %v", metadata.ID, err)
+			proxyStatus = models.ProxyStatusFailed
+		}
 
 		// Create session
 		session := &models.Session{
@@ -1154,15 +1544,31 @@ func (s *WhatsAppService) loadExistingSessions() error {
 			Name:          metadata.Name,
 			Position:      metadata.Position,
 			WebhookURL:    metadata.WebhookURL,
-			AutoReplyText: metadata.AutoReplyText,
 			ProxyConfig:   metadata.ProxyConfig,
+			ProxyStatus:   proxyStatus,
 			Enabled:       metadata.Enabled,
 			UserID:        metadata.UserID,
+			WebhookProxyURL: metadata.WebhookProxyURL,
+			WebhookLegacyFormat: metadata.WebhookLegacyFormat,
+			WebhookSecret: metadata.WebhookSecret,
+			ReceiveReceipts: metadata.ReceiveReceipts,
+			ReceiveStatus: metadata.ReceiveStatus,
+			AllowViewOnceCapture: metadata.AllowViewOnceCapture,
+			PersistMedia:  metadata.PersistMedia,
+			WebhookEvents: metadata.WebhookEvents,
+			WebhookSuspendedAt: metadata.WebhookSuspendedAt,
+			BannedUntil:   metadata.BannedUntil,
+			BanReason:     metadata.BanReason,
+			SendDefaults:  metadata.SendDefaults,
+			NewContactSince: metadata.NewContactSince,
+			NewContactCreateContact: metadata.NewContactCreateContact,
+			AutoRepliesEnabled: metadata.AutoRepliesEnabled,
+			Tags:          metadata.Tags,
+			Sandbox:       metadata.Sandbox,
+			CreatedAt:     metadata.CreatedAt,
 			Client:        client,
-			Connected:     false,
-			LoggedIn:      false,
-			Connecting:    false,
 		}
+		session.SetAutoReply(metadata.AutoReplyText)
 
 		// Set up event handlers
 		s.setupEventHandlers(session)
@@ -1170,19 +1576,50 @@ func (s *WhatsAppService) loadExistingSessions() error {
 		// Store in memory
 		s.sessions[metadata.ID] = session
 
+		if metadata.Sandbox {
+			if metadata.Enabled {
+				s.sandboxLogin(session)
+			}
+			continue
+		}
+
+		// A banned session reconnects when its ban lifts
+		if metadata.BannedUntil != nil {
+			s.logger.Warn("Session %s is temporarily banned until %s, delaying auto-connect", metadata.ID, models.FormatTimestamp(*metadata.BannedUntil))
+			s.scheduleBanLift(session, *metadata.BannedUntil)
+			continue
+		}
+
+		// A session whose proxy could not be applied must not connect directly
+		if proxyStatus == models.ProxyStatusFailed {
+			s.logger.Warn("Session %s is not auto-connected until its proxy is fixed", metadata.ID)
+			continue
+		}
+
 		// Try to connect if device has stored credentials and session is enabled
 		if deviceStore != nil && deviceStore.ID != nil {
 			if metadata.Enabled {
-				go func(sessionID string, client *whatsmeow.Client) {
+				s.restore.add()
+				go func(session *models.Session, client *whatsmeow.Client) {
+					defer s.restore.connectDone()
+
 					// Wait a bit before connecting to ensure everything is initialized
-					time.Sleep(2 * time.Second)
+					select {
+					case <-s.ctx.Done():
+						return
+					case <-time.After(2 * time.Second):
+					}
 
-					s.logger.Info("Auto-connecting restored session %s with JID %s", sessionID, deviceStore.ID.String())
+					s.logger.Info("Auto-connecting restored session %s with JID %s", session.ID, deviceStore.ID.String())
 					err := client.Connect()
 					if err != nil {
-						s.logger.Error("Failed to auto-connect session %s: %v", sessionID, err)
+						s.logger.Error("Failed to auto-connect session %s: %v", session.ID, err)
+						s.mu.Lock()
+						recordProxyFailure(session)
+						s.mu.Unlock()
+						s.scheduleReconnect(session, fmt.Sprintf("connect failed: %v", err))
 					}
-				}(metadata.ID, client)
+				}(session, client)
 			} else {
 				s.logger.Info("Session %s is disabled, skipping auto-connect", metadata.ID)
 			}
@@ -1192,6 +1629,7 @@ func (s *WhatsAppService) loadExistingSessions() error {
 	}
 
 	s.logger.Info("Restored %d sessions", len(s.sessions))
+	s.restore.loaded()
 	return nil
 }
 
@@ -1222,52 +1660,34 @@ func (s *WhatsAppService) SendMessage(sessionID string, req *models.SendMessageR
 		return "", models.NewNotFoundError("session not found")
 	}
 
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
 	// Check if session is connected
-	if !session.Connected {
+	if !session.IsConnected() {
 		return "", models.NewServiceUnavailableError("session is not connected. Please connect the session first")
 	}
 
 	// Check if session is logged in
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return "", models.NewUnauthorizedError("session is not authenticated. Please scan QR code to login")
 	}
 
-	// Format recipient JID like original implementation
-	recipientJID := req.To
-
-	// Check if it's a phone number (contains only digits and +)
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		// Add @s.whatsapp.net if not present
-		recipientJID = phoneNumber + "@s.whatsapp.net"
-	}
-
-	s.logger.Debug("Formatted recipient JID: %s", recipientJID)
-
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Send message
 	msg := &waProto.Message{
 		Conversation: proto.String(req.Message),
 	}
 
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return "", fmt.Errorf("failed to send message: %v", err)
+		return "", s.sendFailure(session, "failed to send message", err)
 	}
 
 	return resp.ID, nil
@@ -1280,13 +1700,17 @@ func (s *WhatsAppService) ForwardMessage(sessionID string, req *models.ForwardMe
 		return "", models.NewNotFoundError("session not found")
 	}
 
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
 	// Check if session is connected
-	if !session.Connected {
+	if !session.IsConnected() {
 		return "", models.NewServiceUnavailableError("session is not connected. Please connect the session first")
 	}
 
 	// Check if session is logged in
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return "", models.NewUnauthorizedError("session is not authenticated. Please scan QR code to login")
 	}
 
@@ -1295,38 +1719,19 @@ func (s *WhatsAppService) ForwardMessage(sessionID string, req *models.ForwardMe
 		return "", fmt.Errorf("message text is required for forwarding")
 	}
 
-	// Format recipient JID
-	recipientJID := req.To
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-		
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-		
-		recipientJID = phoneNumber + "@s.whatsapp.net"
-	}
-
-	s.logger.Debug("Forwarding message %s to JID: %s", req.MessageID, recipientJID)
-
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Create forward message with context info
 	msg := &waProto.Message{
 		ExtendedTextMessage: &waProto.ExtendedTextMessage{
 			Text: proto.String(req.Text),
 			ContextInfo: &waProto.ContextInfo{
 				StanzaID:       proto.String(req.MessageID),
-				Participant:    proto.String(recipientJID),
+				Participant:    proto.String(jid.String()),
 				IsForwarded:    proto.Bool(true),
 				ForwardingScore: proto.Uint32(1),
 			},
@@ -1334,12 +1739,12 @@ func (s *WhatsAppService) ForwardMessage(sessionID string, req *models.ForwardMe
 	}
 
 	// Send the forward message
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return "", fmt.Errorf("failed to forward message: %v", err)
+		return "", s.sendFailure(session, "failed to forward message", err)
 	}
 
-	s.logger.Info("Message forwarded to %s from session %s", recipientJID, sessionID)
+	s.logger.Info("Message forwarded to %s from session %s", jid, sessionID)
 	return resp.ID, nil
 }
 
@@ -1350,60 +1755,51 @@ func (s *WhatsAppService) ReplyMessage(sessionID string, req *models.ReplyMessag
 		return "", models.NewNotFoundError("session not found")
 	}
 
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
 	// Check if session is connected
-	if !session.Connected {
+	if !session.IsConnected() {
 		return "", models.NewServiceUnavailableError("session is not connected. Please connect the session first")
 	}
 
 	// Check if session is logged in
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return "", models.NewUnauthorizedError("session is not authenticated. Please scan QR code to login")
 	}
 
-	// Format recipient JID
-	recipientJID := req.To
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-		
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-		
-		recipientJID = phoneNumber + "@s.whatsapp.net"
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
+	if err != nil {
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	s.logger.Debug("Replying to message %s with JID: %s", req.QuotedMessageID, recipientJID)
-
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// The quote names the quoted message's sender, who is not necessarily the recipient
+	participant, quoted, err := s.quotedMessage(session, jid, req.QuotedMessageID, req.QuotedSender)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", err
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Create reply message with context info
 	msg := &waProto.Message{
 		ExtendedTextMessage: &waProto.ExtendedTextMessage{
 			Text: proto.String(req.Message),
 			ContextInfo: &waProto.ContextInfo{
 				StanzaID:      proto.String(req.QuotedMessageID),
-				Participant:   proto.String(recipientJID),
-				QuotedMessage: &waProto.Message{},
+				Participant:   proto.String(participant.String()),
+				QuotedMessage: quoted,
 			},
 		},
 	}
 
 	// Send the reply message
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return "", fmt.Errorf("failed to send reply message: %v", err)
+		return "", s.sendFailure(session, "failed to send reply message", err)
 	}
 
-	s.logger.Info("Reply sent to %s from session %s", recipientJID, sessionID)
+	s.logger.Info("Reply sent to %s from session %s", jid, sessionID)
 	return resp.ID, nil
 }
 
@@ -1414,44 +1810,26 @@ func (s *WhatsAppService) SendLocation(sessionID string, req *models.SendLocatio
 		return "", models.NewNotFoundError("session not found")
 	}
 
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
+	}
+
 	// Check if session is connected
-	if !session.Connected {
+	if !session.IsConnected() {
 		return "", models.NewServiceUnavailableError("session is not connected. Please connect the session first")
 	}
 
 	// Check if session is logged in
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return "", models.NewUnauthorizedError("session is not authenticated. Please scan QR code to login")
 	}
 
-	// Format recipient JID like original implementation
-	recipientJID := req.To
-
-	// Check if it's a phone number (contains only digits and +)
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		// Add @s.whatsapp.net if not present
-		recipientJID = phoneNumber + "@s.whatsapp.net"
-	}
-
-	s.logger.Debug("Formatted recipient JID for location: %s", recipientJID)
-
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Create location message
 	msg := &waProto.Message{
 		LocationMessage: &waProto.LocationMessage{
@@ -1461,12 +1839,12 @@ func (s *WhatsAppService) SendLocation(sessionID string, req *models.SendLocatio
 	}
 
 	// Send location message
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return "", fmt.Errorf("failed to send location: %v", err)
+		return "", s.sendFailure(session, "failed to send location", err)
 	}
 
-	s.logger.Info("Location sent to %s from session %s", recipientJID, sessionID)
+	s.logger.Info("Location sent to %s from session %s", jid, sessionID)
 	return resp.ID, nil
 }
 
@@ -1477,40 +1855,24 @@ func (s *WhatsAppService) SendAttachment(sessionID string, req *models.SendFileR
 		return "", models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
-		return "", models.NewServiceUnavailableError("session is not connected")
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
 	}
 
-	if !session.LoggedIn {
-		return "", models.NewUnauthorizedError("session is not authenticated")
+	if !session.IsConnected() {
+		return "", models.NewServiceUnavailableError("session is not connected")
 	}
 
-	// Format recipient JID like original implementation
-	recipientJID := req.To
-
-	// Check if it's a phone number (contains only digits and +)
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		// Add @s.whatsapp.net if not present
-		recipientJID = phoneNumber + "@s.whatsapp.net"
+	if !session.IsLoggedIn() {
+		return "", models.NewUnauthorizedError("session is not authenticated")
 	}
 
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Decode base64 file
 	fileData, err := base64.StdEncoding.DecodeString(req.File)
 	if err != nil {
@@ -1518,7 +1880,7 @@ func (s *WhatsAppService) SendAttachment(sessionID string, req *models.SendFileR
 	}
 
 	// Upload file
-	uploaded, err := session.Client.Upload(context.Background(), fileData, whatsmeow.MediaDocument)
+	uploaded, err := s.upload(session, fileData, whatsmeow.MediaDocument)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file: %v", err)
 	}
@@ -1542,9 +1904,9 @@ func (s *WhatsAppService) SendAttachment(sessionID string, req *models.SendFileR
 		msg.DocumentMessage.Caption = proto.String(req.Caption)
 	}
 
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return "", fmt.Errorf("failed to send attachment: %v", err)
+		return "", s.sendFailure(session, "failed to send attachment", err)
 	}
 
 	return resp.ID, nil
@@ -1557,40 +1919,39 @@ func (s *WhatsAppService) SendFileFromURL(sessionID string, req *models.SendFile
 		return "", models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
-		return "", models.NewServiceUnavailableError("session is not connected")
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
 	}
 
-	if !session.LoggedIn {
-		return "", models.NewUnauthorizedError("session is not authenticated")
+	if !session.IsConnected() {
+		return "", models.NewServiceUnavailableError("session is not connected")
 	}
 
-	// Format recipient JID
-	recipientJID := req.To
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		recipientJID = phoneNumber + "@s.whatsapp.net"
+	if !session.IsLoggedIn() {
+		return "", models.NewUnauthorizedError("session is not authenticated")
 	}
 
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
+	// Reuse a recent upload of the same URL, skipping download and upload
+	if s.uploads != nil && !req.NoCache {
+		if media, ok := s.uploads.lookupURL(sessionID, req.URL, req.Type); ok {
+			s.logger.Debug("Reusing upload of %s for session %s", req.URL, sessionID)
+			if req.FileName != "" {
+				media.filename = req.FileName
+			}
+			return s.sendUploadedMedia(session, jid, media, req.Caption, &req.SendOptions)
+		}
+	}
 
 	// Download file from URL
-	fileData, contentType, filename, err := s.downloadFile(req.URL)
+	fileData, contentType, filename, err := s.downloadFile(req.URL, req.KeepLocal)
 	if err != nil {
-		return "", fmt.Errorf("failed to download file: %v", err)
+		return "", err
 	}
 
 	// Use provided filename or extract from URL
@@ -1602,7 +1963,7 @@ func (s *WhatsAppService) SendFileFromURL(sessionID string, req *models.SendFile
 	mediaType := s.getMediaType(contentType, req.Type)
 
 	// Send based on media type
-	return s.sendMediaByType(session, jid, fileData, contentType, filename, req.Caption, mediaType)
+	return s.sendMediaByType(session, jid, fileData, contentType, filename, req.Caption, mediaType, req.URL, req.NoCache, &req.SendOptions)
 }
 
 // SendImage sends an image (enhanced version)
@@ -1612,36 +1973,24 @@ func (s *WhatsAppService) SendImage(sessionID string, req *models.SendImageReque
 		return "", models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
-		return "", models.NewServiceUnavailableError("session is not connected")
+	if err := s.checkNotBanned(session); err != nil {
+		return "", err
 	}
 
-	if !session.LoggedIn {
-		return "", models.NewUnauthorizedError("session is not authenticated")
+	if !session.IsConnected() {
+		return "", models.NewServiceUnavailableError("session is not connected")
 	}
 
-	// Format recipient JID
-	recipientJID := req.To
-	if !strings.Contains(req.To, "@") {
-		phoneNumber := strings.ReplaceAll(req.To, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return "", fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		recipientJID = phoneNumber + "@s.whatsapp.net"
+	if !session.IsLoggedIn() {
+		return "", models.NewUnauthorizedError("session is not authenticated")
 	}
 
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(req.To)
 	if err != nil {
-		return "", fmt.Errorf("invalid recipient JID: %v", err)
+		return "", models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for message sending
-	jid = jid.ToNonAD()
-
 	// Decode base64 image
 	imageData, err := base64.StdEncoding.DecodeString(req.Image)
 	if err != nil {
@@ -1649,7 +1998,7 @@ func (s *WhatsAppService) SendImage(sessionID string, req *models.SendImageReque
 	}
 
 	// Upload image
-	uploaded, err := session.Client.Upload(context.Background(), imageData, whatsmeow.MediaImage)
+	uploaded, err := s.upload(session, imageData, whatsmeow.MediaImage)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload image: %v", err)
 	}
@@ -1671,58 +2020,18 @@ func (s *WhatsAppService) SendImage(sessionID string, req *models.SendImageReque
 		msg.ImageMessage.Caption = proto.String(req.Caption)
 	}
 
-	resp, err := session.Client.SendMessage(context.Background(), jid, msg)
-	if err != nil {
-		return "", fmt.Errorf("failed to send image: %v", err)
-	}
-
-	return resp.ID, nil
-}
-
-// downloadFile downloads a file from URL and saves it locally
-func (s *WhatsAppService) downloadFile(url string) ([]byte, string, string, error) {
-	// Create downloads directory if it doesn't exist
-	downloadsDir := "./downloads"
-	if err := os.MkdirAll(downloadsDir, 0755); err != nil {
-		return nil, "", "", fmt.Errorf("failed to create downloads directory: %v", err)
-	}
-
-	// Download file
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to download file: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", "", fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	// View-once images can be opened once by the recipient
+	if req.ViewOnce {
+		msg.ImageMessage.ViewOnce = proto.Bool(true)
+		msg = &waProto.Message{ViewOnceMessage: &waProto.FutureProofMessage{Message: msg}}
 	}
 
-	// Read file data
-	fileData, err := io.ReadAll(resp.Body)
+	resp, err := s.sendWithOptions(session, jid, msg, &req.SendOptions)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to read file data: %v", err)
+		return "", s.sendFailure(session, "failed to send image", err)
 	}
 
-	// Get content type
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = http.DetectContentType(fileData)
-	}
-
-	// Extract filename from URL or Content-Disposition
-	filename := s.extractFilename(url, resp.Header.Get("Content-Disposition"))
-
-	// Save file locally
-	localPath := filepath.Join(downloadsDir, filename)
-	if err := os.WriteFile(localPath, fileData, 0644); err != nil {
-		s.logger.Error("Failed to save file locally: %v", err)
-		// Continue even if local save fails
-	} else {
-		s.logger.Info("File saved locally: %s", localPath)
-	}
-
-	return fileData, contentType, filename, nil
+	return resp.ID, nil
 }
 
 // extractFilename extracts filename from URL or Content-Disposition header
@@ -1772,21 +2081,89 @@ func (s *WhatsAppService) getMediaType(contentType, requestedType string) string
 	}
 }
 
-// sendMediaByType sends media based on the determined type
-func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID, fileData []byte, contentType, filename, caption, mediaType string) (string, error) {
-	ctx := context.Background()
+// sendMediaByType uploads media and sends it based on the determined type.
+// Identical content already uploaded by the session is reused unless noCache
+// is set; sourceURL, when known, is remembered so the next send of the same
+// URL skips the download too.
+func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID, fileData []byte, contentType, filename, caption, mediaType, sourceURL string, noCache bool, opts *models.SendOptions) (string, error) {
+	sum := sha256.Sum256(fileData)
+	contentHash := hex.EncodeToString(sum[:])
+
+	if s.uploads != nil && !noCache {
+		if media, ok := s.uploads.lookupContent(session.ID, contentHash, mediaType); ok {
+			s.uploads.linkURL(session.ID, sourceURL, media)
+			media.contentType = contentType
+			media.filename = filename
+			return s.sendUploadedMedia(session, jid, media, caption, opts)
+		}
+	}
+
+	uploaded, err := s.upload(session, fileData, whatsmeowMediaType(mediaType))
+	if err != nil {
+		if s.uploads != nil {
+			s.uploads.invalidate(session.ID, sourceURL, contentHash, mediaType)
+		}
+		return "", fmt.Errorf("failed to upload %s: %v", mediaName(mediaType), err)
+	}
+
+	media := uploadedMedia{
+		uploaded:    uploaded,
+		contentType: contentType,
+		filename:    filename,
+		mediaType:   mediaType,
+		sha256:      contentHash,
+	}
+	if s.uploads != nil {
+		s.uploads.store(session.ID, sourceURL, media)
+	}
 
+	return s.sendUploadedMedia(session, jid, media, caption, opts)
+}
+
+// whatsmeowMediaType maps a media type name to the whatsmeow upload type
+func whatsmeowMediaType(mediaType string) whatsmeow.MediaType {
 	switch mediaType {
 	case "image":
-		uploaded, err := session.Client.Upload(ctx, fileData, whatsmeow.MediaImage)
-		if err != nil {
-			return "", fmt.Errorf("failed to upload image: %v", err)
-		}
+		return whatsmeow.MediaImage
+	case "video":
+		return whatsmeow.MediaVideo
+	case "audio":
+		return whatsmeow.MediaAudio
+	default:
+		return whatsmeow.MediaDocument
+	}
+}
 
-		msg := &waProto.Message{
+// mediaName returns the media type used in error messages
+func mediaName(mediaType string) string {
+	switch mediaType {
+	case "image", "video", "audio":
+		return mediaType
+	default:
+		return "document"
+	}
+}
+
+// sendUploadedMedia sends a message referencing already uploaded media
+func (s *WhatsAppService) sendUploadedMedia(session *models.Session, jid types.JID, media uploadedMedia, caption string, opts *models.SendOptions) (string, error) {
+	resp, err := s.sendWithOptions(session, jid, mediaMessage(media, caption), opts)
+	if err != nil {
+		return "", s.sendFailure(session, "failed to send "+mediaName(media.mediaType), err)
+	}
+	return resp.ID, nil
+}
+
+// mediaMessage builds the message referencing uploaded media
+func mediaMessage(media uploadedMedia, caption string) *waProto.Message {
+	uploaded := media.uploaded
+	var msg *waProto.Message
+
+	switch media.mediaType {
+	case "image":
+		msg = &waProto.Message{
 			ImageMessage: &waProto.ImageMessage{
 				URL:           proto.String(uploaded.URL),
-				Mimetype:      proto.String(contentType),
+				Mimetype:      proto.String(media.contentType),
 				FileSHA256:    uploaded.FileSHA256,
 				FileLength:    proto.Uint64(uploaded.FileLength),
 				MediaKey:      uploaded.MediaKey,
@@ -1799,22 +2176,11 @@ func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID
 			msg.ImageMessage.Caption = proto.String(caption)
 		}
 
-		resp, err := session.Client.SendMessage(ctx, jid, msg)
-		if err != nil {
-			return "", fmt.Errorf("failed to send image: %v", err)
-		}
-		return resp.ID, nil
-
 	case "video":
-		uploaded, err := session.Client.Upload(ctx, fileData, whatsmeow.MediaVideo)
-		if err != nil {
-			return "", fmt.Errorf("failed to upload video: %v", err)
-		}
-
-		msg := &waProto.Message{
+		msg = &waProto.Message{
 			VideoMessage: &waProto.VideoMessage{
 				URL:           proto.String(uploaded.URL),
-				Mimetype:      proto.String(contentType),
+				Mimetype:      proto.String(media.contentType),
 				FileSHA256:    uploaded.FileSHA256,
 				FileLength:    proto.Uint64(uploaded.FileLength),
 				MediaKey:      uploaded.MediaKey,
@@ -1827,22 +2193,11 @@ func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID
 			msg.VideoMessage.Caption = proto.String(caption)
 		}
 
-		resp, err := session.Client.SendMessage(ctx, jid, msg)
-		if err != nil {
-			return "", fmt.Errorf("failed to send video: %v", err)
-		}
-		return resp.ID, nil
-
 	case "audio":
-		uploaded, err := session.Client.Upload(ctx, fileData, whatsmeow.MediaAudio)
-		if err != nil {
-			return "", fmt.Errorf("failed to upload audio: %v", err)
-		}
-
-		msg := &waProto.Message{
+		msg = &waProto.Message{
 			AudioMessage: &waProto.AudioMessage{
 				URL:           proto.String(uploaded.URL),
-				Mimetype:      proto.String(contentType),
+				Mimetype:      proto.String(media.contentType),
 				FileSHA256:    uploaded.FileSHA256,
 				FileLength:    proto.Uint64(uploaded.FileLength),
 				MediaKey:      uploaded.MediaKey,
@@ -1851,27 +2206,16 @@ func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID
 			},
 		}
 
-		resp, err := session.Client.SendMessage(ctx, jid, msg)
-		if err != nil {
-			return "", fmt.Errorf("failed to send audio: %v", err)
-		}
-		return resp.ID, nil
-
 	default: // document
-		uploaded, err := session.Client.Upload(ctx, fileData, whatsmeow.MediaDocument)
-		if err != nil {
-			return "", fmt.Errorf("failed to upload document: %v", err)
-		}
-
-		msg := &waProto.Message{
+		msg = &waProto.Message{
 			DocumentMessage: &waProto.DocumentMessage{
 				URL:           proto.String(uploaded.URL),
-				Mimetype:      proto.String(contentType),
-				Title:         proto.String(filename),
+				Mimetype:      proto.String(media.contentType),
+				Title:         proto.String(media.filename),
 				FileSHA256:    uploaded.FileSHA256,
 				FileLength:    proto.Uint64(uploaded.FileLength),
 				MediaKey:      uploaded.MediaKey,
-				FileName:      proto.String(filename),
+				FileName:      proto.String(media.filename),
 				FileEncSHA256: uploaded.FileEncSHA256,
 				DirectPath:    proto.String(uploaded.DirectPath),
 			},
@@ -1880,13 +2224,8 @@ func (s *WhatsAppService) sendMediaByType(session *models.Session, jid types.JID
 		if caption != "" {
 			msg.DocumentMessage.Caption = proto.String(caption)
 		}
-
-		resp, err := session.Client.SendMessage(ctx, jid, msg)
-		if err != nil {
-			return "", fmt.Errorf("failed to send document: %v", err)
-		}
-		return resp.ID, nil
 	}
+	return msg
 }
 
 // CheckNumber checks if a number is registered on WhatsApp
@@ -1896,11 +2235,11 @@ func (s *WhatsAppService) CheckNumber(sessionID string, number string) (bool, st
 		return false, "", models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
+	if !session.IsConnected() {
 		return false, "", models.NewServiceUnavailableError("session is not connected")
 	}
 
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return false, "", models.NewUnauthorizedError("session is not authenticated")
 	}
 
@@ -1923,48 +2262,41 @@ func (s *WhatsAppService) CheckNumber(sessionID string, number string) (bool, st
 	return false, "", nil
 }
 
-// SendTyping sends typing indicator
-func (s *WhatsAppService) SendTyping(sessionID string, to string, typing bool) error {
+// SendTyping shows or clears the typing indicator in a chat. The indicator
+// itself follows in the background once the online presence has settled;
+// with a duration it is cleared again after that long.
+func (s *WhatsAppService) SendTyping(sessionID string, to string, typing bool, duration time.Duration) error {
 	session, exists := s.GetSession(sessionID)
 	if !exists {
 		return models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
+	if !session.IsConnected() {
 		return models.NewServiceUnavailableError("session is not connected")
 	}
 
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return models.NewUnauthorizedError("session is not authenticated")
 	}
 
-
-	// Format recipient JID (same logic as SendMessage)
-	recipientJID := to
-	if !strings.Contains(to, "@") {
-		// Clean phone number
-		phoneNumber := strings.ReplaceAll(to, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-
-		if len(phoneNumber) < 8 || len(phoneNumber) > 15 {
-			return fmt.Errorf("invalid phone number length. Should be 8-15 digits")
-		}
-
-		// Add @s.whatsapp.net if not present
-		recipientJID = phoneNumber + "@s.whatsapp.net"
+	if duration < 0 || duration > maxTypingIndicatorDuration {
+		return models.NewBadRequestError("duration must be between 0 and %d seconds", int(maxTypingIndicatorDuration.Seconds()))
 	}
 
-	s.logger.Debug("Formatted recipient JID for typing: %s", recipientJID)
-
-	// Parse recipient JID
-	jid, err := types.ParseJID(recipientJID)
+	// Accepts phone numbers, user JIDs, groups and broadcast lists
+	jid, err := parseRecipientJID(to)
 	if err != nil {
-		return fmt.Errorf("invalid recipient JID: %v", err)
+		return models.NewBadRequestError("%v", err)
 	}
 
-	// Convert to non-device JID for presence/typing indicators
-	jid = jid.ToNonAD()
+	if !typing {
+		s.typing.stop(sessionID, jid)
+		if err := session.Client.SendChatPresence(context.Background(), jid, types.ChatPresencePaused, types.ChatPresenceMediaText); err != nil {
+			return fmt.Errorf("failed to stop typing indicator: %v", err)
+		}
+		s.logger.Info("Stopped typing indicator to %s from session %s", jid.String(), sessionID)
+		return nil
+	}
 
 	// Ensure we have a push name (required for presence/typing to work properly)
 	if session.Client.Store.PushName == "" {
@@ -1987,29 +2319,7 @@ func (s *WhatsAppService) SendTyping(sessionID string, to string, typing bool) e
 		// Not critical, continue
 	}
 
-	// Longer delay to ensure presence is fully processed by WhatsApp
-	time.Sleep(300 * time.Millisecond)
-
-	// Send typing indicator using chat presence
-	var presenceType types.ChatPresence
-	if typing {
-		presenceType = types.ChatPresenceComposing
-	} else {
-		presenceType = types.ChatPresencePaused
-	}
-
-	err = session.Client.SendChatPresence(context.Background(), jid, presenceType, types.ChatPresenceMediaText)
-	if err != nil {
-		return fmt.Errorf("failed to send typing indicator: %v", err)
-	}
-
-	if typing {
-		s.logger.Info("Sent typing indicator to %s from session %s (push name: %s)", 
-			jid.String(), sessionID, session.Client.Store.PushName)
-	} else {
-		s.logger.Info("Stopped typing indicator to %s from session %s", jid.String(), sessionID)
-	}
-
+	s.showTyping(session, jid, duration)
 	return nil
 }
 
@@ -2020,11 +2330,11 @@ func (s *WhatsAppService) SetPresence(sessionID string, status string) error {
 		return models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
+	if !session.IsConnected() {
 		return models.NewServiceUnavailableError("session is not connected")
 	}
 
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return models.NewUnauthorizedError("session is not authenticated")
 	}
 
@@ -2054,11 +2364,11 @@ func (s *WhatsAppService) GetGroups(sessionID string) ([]map[string]interface{},
 		return nil, models.NewNotFoundError("session not found")
 	}
 
-	if !session.Connected {
+	if !session.IsConnected() {
 		return nil, models.NewServiceUnavailableError("session is not connected")
 	}
 
-	if !session.LoggedIn {
+	if !session.IsLoggedIn() {
 		return nil, models.NewUnauthorizedError("session is not authenticated")
 	}
 
@@ -2071,25 +2381,106 @@ func (s *WhatsAppService) GetGroups(sessionID string) ([]map[string]interface{},
 	result := make([]map[string]interface{}, 0, len(groups))
 	for _, groupInfo := range groups {
 		result = append(result, map[string]interface{}{
-			"jid":     groupInfo.JID.String(),
-			"name":    groupInfo.Name,
-			"topic":   groupInfo.Topic,
-			"owner":   groupInfo.OwnerJID.String(),
-			"created": groupInfo.GroupCreated.Unix(),
+			"jid":        groupInfo.JID.String(),
+			"name":       groupInfo.Name,
+			"topic":      groupInfo.Topic,
+			"owner":      groupInfo.OwnerJID.String(),
+			"created":    groupInfo.GroupCreated.Unix(),
+			"created_at": models.FormatTimestamp(groupInfo.GroupCreated),
 		})
 	}
 
 	return result, nil
 }
 
+// GetBlocklist returns the JIDs the session's account has blocked
+func (s *WhatsAppService) GetBlocklist(sessionID string) ([]string, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocklist, err := session.Client.GetBlocklist(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocklist: %v", err)
+	}
+
+	return blocklistJIDs(blocklist), nil
+}
+
+// UpdateBlocklist blocks or unblocks a phone number or JID and returns the updated blocklist
+func (s *WhatsAppService) UpdateBlocklist(sessionID, target string, block bool) ([]string, error) {
+	session, err := s.loggedInSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := parseRecipientJID(target)
+	if err != nil {
+		return nil, models.NewBadRequestError("%v", err)
+	}
+
+	action := events.BlocklistChangeActionUnblock
+	if block {
+		action = events.BlocklistChangeActionBlock
+	}
+
+	blocklist, err := session.Client.UpdateBlocklist(context.Background(), jid, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %v", action, jid, err)
+	}
+
+	s.logger.Info("Session %s: %sed %s", sessionID, action, jid)
+	return blocklistJIDs(blocklist), nil
+}
+
+// loggedInSession returns a session that is connected and authenticated
+func (s *WhatsAppService) loggedInSession(sessionID string) (*models.Session, error) {
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		return nil, models.NewNotFoundError("session not found")
+	}
+
+	if !session.IsConnected() {
+		return nil, models.NewServiceUnavailableError("session is not connected")
+	}
+
+	if !session.IsLoggedIn() {
+		return nil, models.NewUnauthorizedError("session is not authenticated")
+	}
+
+	return session, nil
+}
+
+// blocklistJIDs converts a whatsmeow blocklist into JID strings
+func blocklistJIDs(blocklist *types.Blocklist) []string {
+	jids := make([]string, 0, len(blocklist.JIDs))
+	for _, jid := range blocklist.JIDs {
+		jids = append(jids, jid.String())
+	}
+	return jids
+}
+
 // sendWebhook sends incoming message data to configured webhook URL
-func (s *WhatsAppService) sendWebhook(session *models.Session, evt *events.Message) {
+func (s *WhatsAppService) sendWebhook(session *models.Session, evt *events.Message, firstContact bool) {
+	receivedAt := time.Now()
+
 	// Don't send webhook if session is disabled
 	if !session.Enabled {
 		s.logger.Debug("Session %s is disabled, skipping webhook", session.ID)
 		return
 	}
 
+	// Nor for messages the session's webhook does not take
+	if !session.WebhookEventEnabled(webhookMessageType(evt.Message), evt.Info.IsGroup) {
+		return
+	}
+
+	// Contacts' status updates are numerous, so they are only sent on request
+	if evt.Info.Chat == types.StatusBroadcastJID && !session.ReceiveStatus {
+		return
+	}
+
 	// Get sender name from push name (most reliable method)
 	senderName := "Unknown"
 	if evt.Info.PushName != "" {
@@ -2102,19 +2493,14 @@ func (s *WhatsAppService) sendWebhook(session *models.Session, evt *events.Messa
 	// Create webhook message
 	webhookMsg := &models.WebhookMessage{
 		SessionID:   session.ID,
-		From:        evt.Info.Sender.String(),
 		FromName:    senderName,
-		To:          session.Client.Store.ID.String(),
-		Timestamp:   evt.Info.Timestamp,
 		ID:          evt.Info.ID,
-		IsGroup:     evt.Info.IsGroup,
 		MessageType: "text",
+		FirstContact: firstContact,
 	}
-
-	// Handle group messages
-	if evt.Info.IsGroup {
-		webhookMsg.GroupID = evt.Info.Chat.String()
-	}
+	setWebhookSource(webhookMsg, session, evt.Info.MessageSource)
+	webhookMsg.SetTimes(evt.Info.Timestamp, receivedAt, session.WebhookLegacyFormat)
+	webhookMsg.IsViewOnce = isViewOnce(evt)
 
 	// Extract message content based on type
 	if evt.Message.GetConversation() != "" {
@@ -2127,52 +2513,190 @@ func (s *WhatsAppService) sendWebhook(session *models.Session, evt *events.Messa
 		webhookMsg.Message = evt.Message.GetImageMessage().GetCaption()
 		webhookMsg.MessageType = "image"
 		// Download and save media file
-		if fileName, err := s.downloadIncomingMedia(session, evt); err == nil {
-			// Create temporary access URL (valid for 1 hour)
-			webhookMsg.MediaURL = fmt.Sprintf("/api/media/temp/%s?expires=%d", fileName, time.Now().Add(time.Hour).Unix())
+		if fileName, err := s.incomingMedia(session, evt); err == nil {
+			webhookMsg.MediaURL = s.mediaURL(fileName)
 		}
 	} else if evt.Message.GetDocumentMessage() != nil {
 		webhookMsg.Message = evt.Message.GetDocumentMessage().GetCaption()
 		webhookMsg.MessageType = "document"
 		// Download and save media file
-		if fileName, err := s.downloadIncomingMedia(session, evt); err == nil {
-			// Create temporary access URL (valid for 1 hour)
-			webhookMsg.MediaURL = fmt.Sprintf("/api/media/temp/%s?expires=%d", fileName, time.Now().Add(time.Hour).Unix())
+		if fileName, err := s.incomingMedia(session, evt); err == nil {
+			webhookMsg.MediaURL = s.mediaURL(fileName)
 		}
 	} else if evt.Message.GetAudioMessage() != nil {
 		webhookMsg.MessageType = "audio"
+		webhookMsg.IsPTT = evt.Message.GetAudioMessage().GetPTT()
+		webhookMsg.Duration = int(evt.Message.GetAudioMessage().GetSeconds())
 		// Download and save media file
-		if fileName, err := s.downloadIncomingMedia(session, evt); err == nil {
-			// Create temporary access URL (valid for 1 hour)
-			webhookMsg.MediaURL = fmt.Sprintf("/api/media/temp/%s?expires=%d", fileName, time.Now().Add(time.Hour).Unix())
+		if fileName, err := s.incomingMedia(session, evt); err == nil {
+			webhookMsg.MediaURL = s.mediaURL(fileName)
 		}
 	} else if evt.Message.GetVideoMessage() != nil {
 		webhookMsg.Message = evt.Message.GetVideoMessage().GetCaption()
 		webhookMsg.MessageType = "video"
 		// Download and save media file
-		if fileName, err := s.downloadIncomingMedia(session, evt); err == nil {
-			// Create temporary access URL (valid for 1 hour)
-			webhookMsg.MediaURL = fmt.Sprintf("/api/media/temp/%s?expires=%d", fileName, time.Now().Add(time.Hour).Unix())
+		if fileName, err := s.incomingMedia(session, evt); err == nil {
+			webhookMsg.MediaURL = s.mediaURL(fileName)
 		}
+	} else if evt.Message.GetPollUpdateMessage() != nil {
+		webhookMsg.MessageType = "poll_vote"
+		webhookMsg.PollVote = s.decodePollVote(session, evt)
+	} else if evt.Message.GetOrderMessage() != nil {
+		webhookMsg.Message = evt.Message.GetOrderMessage().GetMessage()
+		webhookMsg.MessageType = "order"
+		webhookMsg.Order = s.decodeOrder(session, evt)
+	} else if messageType, reply := interactiveReply(evt.Message); reply != nil {
+		webhookMsg.Message = reply.Title
+		webhookMsg.MessageType = messageType
+		webhookMsg.InteractiveReply = reply
+	} else if reaction := evt.Message.GetReactionMessage(); reaction != nil {
+		// Reactions to statuses are reported as status_update events instead
+		if reaction.GetKey().GetRemoteJID() == types.StatusBroadcastJID.String() {
+			return
+		}
+		webhookMsg.Message = reaction.GetText()
+		webhookMsg.MessageType = "reaction"
+		webhookMsg.TargetMessageID = reaction.GetKey().GetID()
 	} else {
 		webhookMsg.Message = "[Unsupported message type]"
 		webhookMsg.MessageType = "unknown"
 	}
 
-	// Send webhook with retries
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if err := s.sendWebhookHTTP(session.WebhookURL, webhookMsg); err != nil {
-			s.logger.Error("Webhook attempt %d failed for session %s: %v", attempt, session.ID, err)
-			if attempt < maxRetries {
-				// Exponential backoff
-				time.Sleep(time.Duration(attempt*attempt) * time.Second)
-			}
-		} else {
-			s.logger.Info("Webhook sent successfully for session %s", session.ID)
-			break
+	// Replies carry the message they quote, so integrations can match them to what was sent
+	if quote := messageContextInfo(evt.Message); quote.GetStanzaID() != "" {
+		webhookMsg.QuotedMessageID = quote.GetStanzaID()
+		webhookMsg.QuotedParticipant = quote.GetParticipant()
+		if quoted := quote.GetQuotedMessage(); quoted != nil {
+			webhookMsg.QuotedMessageText = messageText(quoted)
 		}
 	}
+
+	// Disappearing messages carry the chat's timer
+	webhookMsg.EphemeralExpiration = int(messageContextInfo(evt.Message).GetExpiration())
+
+	s.deliverWebhook(session, "message", webhookMsg)
+}
+
+// messageContextInfo returns the context info of a text or media message, or
+// nil when the message type carries none
+func messageContextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.GetListResponseMessage() != nil:
+		return msg.GetListResponseMessage().GetContextInfo()
+	case msg.GetButtonsResponseMessage() != nil:
+		return msg.GetButtonsResponseMessage().GetContextInfo()
+	case msg.GetTemplateButtonReplyMessage() != nil:
+		return msg.GetTemplateButtonReplyMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// handleUndecryptableMessage records a message that could not be decrypted and
+// notifies the webhook so downstream systems can ask the sender to resend it.
+// A rising count usually means the session's keys are out of sync and it needs re-pairing.
+func (s *WhatsAppService) handleUndecryptableMessage(session *models.Session, evt *events.UndecryptableMessage) {
+	s.mu.Lock()
+	session.UndecryptableCount++
+	count := session.UndecryptableCount
+	s.mu.Unlock()
+
+	s.logger.Warn("Session %s could not decrypt message %s from %s in chat %s (unavailable: %v, total: %d)",
+		session.ID, evt.Info.ID, evt.Info.Sender.String(), evt.Info.Chat.String(), evt.IsUnavailable, count)
+
+	if s.messageRepo != nil {
+		message := &repository.Message{
+			SessionID:    session.ID,
+			MessageID:    evt.Info.ID,
+			SenderJID:    evt.Info.Sender.String(),
+			RecipientJID: evt.Info.Chat.String(),
+			MessageType:  "undecryptable",
+			Direction:    "received",
+			Status:       "undecryptable",
+			CreatedAt:    evt.Info.Timestamp,
+			UpdatedAt:    time.Now(),
+		}
+		if err := s.messageRepo.LogMessage(message); err != nil {
+			s.logger.Debug("Failed to log undecryptable message %s: %v", evt.Info.ID, err)
+		}
+	}
+
+	if !session.Enabled || session.WebhookURL == "" {
+		return
+	}
+
+	// Messages WhatsApp marks as intentionally hidden are not worth a notice
+	if evt.DecryptFailMode == events.DecryptFailHide || !session.WebhookEventEnabled("undecryptable", evt.Info.IsGroup) {
+		return
+	}
+	if evt.Info.Chat == types.StatusBroadcastJID && !session.ReceiveStatus {
+		return
+	}
+
+	receivedAt := time.Now()
+	go func() {
+		webhookMsg := &models.WebhookMessage{
+			SessionID:   session.ID,
+			FromName:    evt.Info.PushName,
+			ID:          evt.Info.ID,
+			MessageType: "undecryptable",
+			Message:     "[Message could not be decrypted]",
+		}
+		setWebhookSource(webhookMsg, session, evt.Info.MessageSource)
+		webhookMsg.SetTimes(evt.Info.Timestamp, receivedAt, session.WebhookLegacyFormat)
+
+		s.deliverWebhook(session, "undecryptable", webhookMsg)
+	}()
+}
+
+// setWebhookSource fills the sender, recipient and chat of a webhook message.
+// The session may have logged out since the message arrived, leaving no
+// own JID to report.
+func setWebhookSource(msg *models.WebhookMessage, session *models.Session, source types.MessageSource) {
+	msg.From = source.Sender.ToNonAD().String()
+	msg.FromDevice = source.Sender.String()
+	msg.FromPhone = senderPhone(source)
+	msg.Chat = source.Chat.ToNonAD().String()
+	msg.IsGroup = source.IsGroup
+	if source.IsGroup {
+		msg.GroupID = source.Chat.String()
+	}
+	if source.Chat == types.StatusBroadcastJID {
+		msg.IsStatus = true
+	}
+	if source.Chat.Server == types.NewsletterServer {
+		msg.IsNewsletter = true
+		msg.NewsletterJID = source.Chat.String()
+	}
+	if session.Client == nil {
+		return
+	}
+	// Read once: a logout clears it concurrently
+	if own := session.Client.Store.ID; own != nil {
+		msg.To = own.ToNonAD().String()
+	}
+}
+
+// senderPhone returns the sender's number in E.164, using the alternate
+// address when the message was addressed by LID
+func senderPhone(source types.MessageSource) string {
+	if number := phone.FromJID(source.Sender.ToNonAD().String()); number != "" {
+		return number
+	}
+	return phone.FromJID(source.SenderAlt.ToNonAD().String())
 }
 
 // sendAutoReply sends an automatic reply to incoming messages
@@ -2184,7 +2708,8 @@ func (s *WhatsAppService) sendAutoReply(session *models.Session, evt *events.Mes
 	}
 
 	// Don't reply to group messages or if no auto reply text is set
-	if evt.Info.IsGroup || session.AutoReplyText == nil || *session.AutoReplyText == "" {
+	autoReplyText := session.AutoReply()
+	if evt.Info.IsGroup || autoReplyText == nil || *autoReplyText == "" {
 		return
 	}
 
@@ -2195,14 +2720,14 @@ func (s *WhatsAppService) sendAutoReply(session *models.Session, evt *events.Mes
 
 	// Create the reply message
 	replyMsg := &waProto.Message{
-		Conversation: proto.String(*session.AutoReplyText),
+		Conversation: proto.String(*autoReplyText),
 	}
 
 	// Convert sender JID to user JID (remove device part)
 	userJID := evt.Info.Sender.ToNonAD()
 
 	// Send the auto reply
-	_, err := session.Client.SendMessage(context.Background(), userJID, replyMsg)
+	_, err := s.sendWithOptions(session, userJID, replyMsg, nil)
 	if err != nil {
 		s.logger.Error("Failed to send auto reply for session %s: %v", session.ID, err)
 		return
@@ -2217,55 +2742,77 @@ func (s *WhatsAppService) sendReceiptWebhook(session *models.Session, evt *event
 		s.logger.Debug("Session %s is disabled, skipping receipt webhook", session.ID)
 		return
 	}
+	if !session.WebhookEventEnabled("receipt", evt.IsGroup) {
+		return
+	}
 
 	webhookReceipt := &models.WebhookReceipt{
+		EventType:     "receipt",
 		SessionID:     session.ID,
 		MessageIDs:    evt.MessageIDs,
 		Sender:        evt.Sender.String(),
 		Chat:          evt.Chat.String(),
 		MessageSender: evt.MessageSender.String(),
-		Timestamp:     evt.Timestamp,
 		Type:          string(evt.Type),
 		Status:        status,
 	}
-
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if err := s.sendWebhookHTTP(session.WebhookURL, webhookReceipt); err != nil {
-			s.logger.Error("Receipt webhook attempt %d failed for session %s: %v", attempt, session.ID, err)
-			if attempt < maxRetries {
-				time.Sleep(time.Duration(attempt*attempt) * time.Second)
-			}
-		} else {
-			s.logger.Info("Receipt webhook sent successfully for session %s", session.ID)
-			break
-		}
+	if list, ok := s.broadcastSends.list(session.ID, evt.MessageIDs); ok {
+		webhookReceipt.BroadcastList = list.String()
 	}
+	webhookReceipt.SetTimes(evt.Timestamp, time.Now(), session.WebhookLegacyFormat)
+
+	s.deliverWebhook(session, "receipt", webhookReceipt)
 }
 
 // sendWebhookHTTP sends the webhook message via HTTP POST
-func (s *WhatsAppService) sendWebhookHTTP(webhookURL string, msg any) error {
+func (s *WhatsAppService) sendWebhookHTTP(session *models.Session, msg any) (err error) {
 	// Marshal message to JSON
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook message: %v", err)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
+	// Create HTTP request, cancelled if shutdown gives up on it
+	req, err := http.NewRequestWithContext(s.tasks.ctx, "POST", session.WebhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create webhook request: %v", err)
 	}
+	if err := s.guard.checkURL(req.Context(), req.URL); err != nil {
+		return fmt.Errorf("webhook URL not allowed: %v", err)
+	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "WhatsApp-Multi-Session/1.0")
+	if session.WebhookSecret != "" {
+		signWebhookRequest(req, session.WebhookSecret, jsonData)
+	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// Use the session's webhook proxy when set, otherwise the shared outbound
+	// client. The session's proxy is checked again, as it may have been saved
+	// before the check existed or resolve differently now.
+	client, err := s.httpClients.ForProxy(session.WebhookProxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook proxy for session %s: %v", session.ID, err)
+	}
+	if session.WebhookProxyURL != "" {
+		if err := s.guard.checkProxyURL(req.Context(), session.WebhookProxyURL); err != nil {
+			return fmt.Errorf("webhook proxy of session %s not allowed: %v", session.ID, err)
+		}
+		client = s.guard.userProxyClient(client)
+	} else {
+		client = s.guard.client(client)
 	}
 
 	// Send request
+	start := time.Now()
+	defer func() {
+		metrics.WebhookDuration.Observe(time.Since(start).Seconds())
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		metrics.WebhookDeliveries.Inc(session.ID, result)
+	}()
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send webhook request: %v", err)
@@ -2280,18 +2827,17 @@ func (s *WhatsAppService) sendWebhookHTTP(webhookURL string, msg any) error {
 	return nil
 }
 
-// downloadIncomingMedia downloads media from incoming messages and saves locally
+// downloadIncomingMedia downloads media from incoming messages and writes it to storage
 func (s *WhatsAppService) downloadIncomingMedia(session *models.Session, evt *events.Message) (string, error) {
+	// Keeping view-once media defeats its purpose, so sessions have to opt in
+	if isViewOnce(evt) && !session.AllowViewOnceCapture {
+		return "", fmt.Errorf("view-once media of message %s is not captured", evt.Info.ID)
+	}
+
 	var mediaData []byte
-	var fileName string
+	var fileName, mimeType string
 	var err error
-	ctx := context.Background()
-
-	// Create media directory
-	mediaDir := "./media/received"
-	if err := os.MkdirAll(mediaDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create media directory: %v", err)
-	}
+	ctx := s.tasks.ctx
 
 	// Generate unique filename
 	timestamp := time.Now().Unix()
@@ -2305,6 +2851,7 @@ func (s *WhatsAppService) downloadIncomingMedia(session *models.Session, evt *ev
 			return "", fmt.Errorf("failed to download image: %v", err)
 		}
 		fileName = fmt.Sprintf("%s_%d_%s.jpg", sessionID, timestamp, messageID)
+		mimeType = img.GetMimetype()
 	} else if doc := evt.Message.GetDocumentMessage(); doc != nil {
 		mediaData, err = session.Client.Download(ctx, doc)
 		if err != nil {
@@ -2318,112 +2865,86 @@ func (s *WhatsAppService) downloadIncomingMedia(session *models.Session, evt *ev
 		} else {
 			fileName = fmt.Sprintf("%s_%d_%s.bin", sessionID, timestamp, messageID)
 		}
+		mimeType = doc.GetMimetype()
 	} else if video := evt.Message.GetVideoMessage(); video != nil {
 		mediaData, err = session.Client.Download(ctx, video)
 		if err != nil {
 			return "", fmt.Errorf("failed to download video: %v", err)
 		}
 		fileName = fmt.Sprintf("%s_%d_%s.mp4", sessionID, timestamp, messageID)
+		mimeType = video.GetMimetype()
 	} else if audio := evt.Message.GetAudioMessage(); audio != nil {
 		mediaData, err = session.Client.Download(ctx, audio)
 		if err != nil {
 			return "", fmt.Errorf("failed to download audio: %v", err)
 		}
 		fileName = fmt.Sprintf("%s_%d_%s.ogg", sessionID, timestamp, messageID)
+		mimeType = audio.GetMimetype()
 	} else {
 		return "", fmt.Errorf("unsupported media type")
 	}
 
-	// Save file locally
-	filePath := filepath.Join(mediaDir, fileName)
-	if err := os.WriteFile(filePath, mediaData, 0644); err != nil {
+	// Save file to storage with the type the sender gave, or a sniffed one
+	if mimeType == "" {
+		mimeType = http.DetectContentType(mediaData)
+	}
+	key := storage.JoinKey(storage.PrefixReceivedMedia, fileName)
+	if err := s.storage.Put(ctx, key, mediaData, mimeType); err != nil {
 		return "", fmt.Errorf("failed to save media file: %v", err)
 	}
 
-	s.logger.Info("Downloaded media file: %s", filePath)
+	s.logger.Info("Downloaded media file: %s (%s)", key, s.storage.Driver())
 	return fileName, nil
 }
 
-// Close closes all sessions and the service
-func (s *WhatsAppService) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, session := range s.sessions {
-		if session.Connected {
-			session.Client.Disconnect()
-		}
+// mediaURL returns a temporary (1 hour) URL for received media. When the storage
+// backend can sign URLs (S3) the client downloads directly from the bucket,
+// otherwise the file is proxied through the authenticated temp media endpoint.
+func (s *WhatsAppService) mediaURL(fileName string) string {
+	key := storage.JoinKey(storage.PrefixReceivedMedia, fileName)
+	if url, err := s.storage.PresignGet(key, mediaURLTTL); err == nil {
+		return url
+	} else if err != storage.ErrPresignNotSupported {
+		s.logger.Warn("Failed to presign media URL for %s: %v", key, err)
 	}
 
-	return nil
+	return s.PublicURL(fmt.Sprintf("/api/media/temp/%s?expires=%d", fileName, time.Now().Add(mediaURLTTL).Unix()))
 }
 
-// GetConversations retrieves all conversations/chats for a session
-func (s *WhatsAppService) GetConversations(sessionID string) ([]*models.Conversation, error) {
-	s.mu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.mu.RUnlock()
-
-	if !exists {
-		return nil, models.ErrSessionNotFound
-	}
-
-	if !session.Connected || !session.LoggedIn {
-		return nil, models.ErrSessionNotAuthenticated
-	}
+// SetPublicBaseURL sets the base used to turn API paths into absolute URLs
+func (s *WhatsAppService) SetPublicBaseURL(baseURL string) {
+	s.publicBaseURL = baseURL
+}
 
-	// Get all contacts from the store
-	contacts, err := session.Client.Store.Contacts.GetAllContacts(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get contacts: %v", err)
-	}
+// SetRequestBaseURL records the base URL of the latest API request, which
+// PublicURL falls back to when no public base URL is configured
+func (s *WhatsAppService) SetRequestBaseURL(baseURL string) {
+	s.requestBase.Store(baseURL)
+}
 
-	// Get joined groups
-	groups, err := session.Client.GetJoinedGroups(context.Background())
-	if err != nil {
-		s.logger.Warn("Failed to get groups: %v", err)
-		// Continue without groups
-		groups = []*types.GroupInfo{}
+// PublicURL converts an API path into an absolute URL using the configured
+// public base URL, or the base URL of the latest API request when unset.
+// Before any request the path is returned unchanged.
+func (s *WhatsAppService) PublicURL(path string) string {
+	baseURL := s.publicBaseURL
+	if baseURL == "" {
+		baseURL, _ = s.requestBase.Load().(string)
 	}
+	return utils.JoinURL(baseURL, path)
+}
 
-	// Create conversations list
-	conversations := make([]*models.Conversation, 0, len(contacts)+len(groups))
-
-	// Add individual chats
-	for jid, contact := range contacts {
-		if jid.Server != types.DefaultUserServer {
-			continue // Skip non-user contacts
-		}
+// Close closes all sessions and the service
+func (s *WhatsAppService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		conversation := &models.Conversation{
-			JID:     jid.String(),
-			Name:    s.getContactName(contact),
-			IsGroup: false,
-			// Note: WhatsApp doesn't provide unread count, last message, etc. via these APIs
-			// These would need to be tracked by listening to events or using history sync
-			UnreadCount: 0,
-			IsPinned:    false,
-			IsMuted:     false,
-			IsArchived:  false,
-		}
-		conversations = append(conversations, conversation)
-	}
-
-	// Add group chats
-	for _, group := range groups {
-		conversation := &models.Conversation{
-			JID:         group.JID.String(),
-			Name:        group.Name,
-			IsGroup:     true,
-			UnreadCount: 0,
-			IsPinned:    false,
-			IsMuted:     false,
-			IsArchived:  false,
+	for _, session := range s.sessions {
+		if session.IsConnected() {
+			session.Client.Disconnect()
 		}
-		conversations = append(conversations, conversation)
 	}
 
-	return conversations, nil
+	return nil
 }
 
 // getContactName returns the best available name for a contact
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-multi-session/internal/models"
+)
+
+func TestSetWebhookSource(t *testing.T) {
+	own := types.NewADJID("628111111111", 0, 5)
+	sender := types.NewADJID("628987654321", 0, 12)
+	group := types.NewJID("120363012345678901", types.GroupServer)
+
+	tests := []struct {
+		name   string
+		client *whatsmeow.Client
+		source types.MessageSource
+		want   models.WebhookMessage
+	}{
+		{
+			name:   "logged out while processing",
+			client: &whatsmeow.Client{Store: &store.Device{}},
+			source: types.MessageSource{Chat: sender.ToNonAD(), Sender: sender},
+			want: models.WebhookMessage{
+				From:       "628987654321@s.whatsapp.net",
+				FromDevice: "628987654321:12@s.whatsapp.net",
+				FromPhone:  "+628987654321",
+				Chat:       "628987654321@s.whatsapp.net",
+			},
+		},
+		{
+			name:   "without a client",
+			source: types.MessageSource{Chat: sender.ToNonAD(), Sender: sender},
+			want: models.WebhookMessage{
+				From:       "628987654321@s.whatsapp.net",
+				FromDevice: "628987654321:12@s.whatsapp.net",
+				FromPhone:  "+628987654321",
+				Chat:       "628987654321@s.whatsapp.net",
+			},
+		},
+		{
+			name:   "logged in",
+			client: &whatsmeow.Client{Store: &store.Device{ID: &own}},
+			source: types.MessageSource{Chat: sender.ToNonAD(), Sender: sender},
+			want: models.WebhookMessage{
+				From:       "628987654321@s.whatsapp.net",
+				FromDevice: "628987654321:12@s.whatsapp.net",
+				FromPhone:  "+628987654321",
+				To:         "628111111111@s.whatsapp.net",
+				Chat:       "628987654321@s.whatsapp.net",
+			},
+		},
+		{
+			name:   "group message",
+			client: &whatsmeow.Client{Store: &store.Device{ID: &own}},
+			source: types.MessageSource{Chat: group, Sender: sender, IsGroup: true},
+			want: models.WebhookMessage{
+				From:       "628987654321@s.whatsapp.net",
+				FromDevice: "628987654321:12@s.whatsapp.net",
+				FromPhone:  "+628987654321",
+				To:         "628111111111@s.whatsapp.net",
+				Chat:       "120363012345678901@g.us",
+				IsGroup:    true,
+				GroupID:    "120363012345678901@g.us",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg models.WebhookMessage
+			setWebhookSource(&msg, &models.Session{ID: "s1", Client: tt.client}, tt.source)
+			if msg != tt.want {
+				t.Errorf("webhook message = %+v, want %+v", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSessionEnabled(t *testing.T) {
+	service, sessionRepo, userID := newTestWhatsAppService(t)
+
+	tests := []struct {
+		body    string
+		enabled bool
+	}{
+		{`{"name":"default","sandbox":true}`, true},
+		{`{"name":"enabled","sandbox":true,"enabled":true}`, true},
+		{`{"name":"disabled","sandbox":true,"enabled":false}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			var req models.CreateSessionRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			session, err := service.CreateSession(&req, userID, models.RoleUser)
+			if err != nil {
+				t.Fatalf("failed to create session: %v", err)
+			}
+			if session.Enabled != tt.enabled {
+				t.Errorf("session enabled = %v, want %v", session.Enabled, tt.enabled)
+			}
+			stored, err := sessionRepo.GetByID(session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Enabled != tt.enabled {
+				t.Errorf("stored session enabled = %v, want %v", stored.Enabled, tt.enabled)
+			}
+		})
+	}
+}
+
+// Updates run in order on one session
+func TestUpdateSessionWebhook(t *testing.T) {
+	service, sessionRepo, userID := newTestWhatsAppService(t)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Shop", Sandbox: true, WebhookURL: "https://public.example/hook"}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		webhook string
+	}{
+		{"omitted keeps the webhook", `{"name":"Renamed"}`, "https://public.example/hook"},
+		{"new URL replaces it", `{"webhook_url":"https://public.example/other"}`, "https://public.example/other"},
+		{"empty string clears it", `{"webhook_url":""}`, ""},
+		{"omitted keeps it cleared", `{"name":"Again"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req models.UpdateSessionRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := service.UpdateSession(session.ID, &req); err != nil {
+				t.Fatalf("update failed: %v", err)
+			}
+			if got, _ := service.GetSession(session.ID); got.WebhookURL != tt.webhook {
+				t.Errorf("session webhook = %q, want %q", got.WebhookURL, tt.webhook)
+			}
+			stored, err := sessionRepo.GetByID(session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.WebhookURL != tt.webhook {
+				t.Errorf("stored webhook = %q, want %q", stored.WebhookURL, tt.webhook)
+			}
+		})
+	}
+
+	req := models.UpdateSessionRequest{}
+	if err := json.Unmarshal([]byte(`{"webhook_url":"http://private.example/hook"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.UpdateSession(session.ID, &req); err == nil {
+		t.Error("update to a private webhook URL was accepted")
+	}
+}
+
+// scriptedClient stands in for the connection of a session. Connect waits
+// for release, then fails with connectErr or connects and reports it through
+// the session's event handlers, as whatsmeow does.
+type scriptedClient struct {
+	session    *models.Session
+	connectErr error
+	release    chan struct{}
+	connects   atomic.Int32
+
+	mu        sync.Mutex
+	connected bool
+}
+
+func (c *scriptedClient) Connect() error {
+	c.connects.Add(1)
+	<-c.release
+	if c.connectErr != nil {
+		return c.connectErr
+	}
+	c.mu.Lock()
+	c.connected = true
+	c.mu.Unlock()
+	c.session.Client.DangerousInternals().DispatchEvent(&events.Connected{})
+	return nil
+}
+
+func (c *scriptedClient) Disconnect() {
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
+}
+
+func (c *scriptedClient) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *scriptedClient) IsLoggedIn() bool {
+	return c.IsConnected()
+}
+
+// newPairingSession returns a session that has just shown its QR code, with
+// its connection scripted
+func newPairingSession(t *testing.T, connectErr error) (*WhatsAppService, *models.Session, *scriptedClient) {
+	t.Helper()
+	service, _, userID := newTestWhatsAppService(t)
+	session, err := service.CreateSession(&models.CreateSessionRequest{Name: "Pairing", Sandbox: true}, userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	client := &scriptedClient{session: session, connectErr: connectErr, release: make(chan struct{})}
+	service.pairingClient = func(*models.Session) connectionClient { return client }
+	return service, session, client
+}
+
+// dispatch delivers events to the session's handlers as whatsmeow would
+func dispatch(session *models.Session, evts ...interface{}) {
+	for _, evt := range evts {
+		session.Client.DangerousInternals().DispatchEvent(evt)
+	}
+}
+
+// The sequence WhatsApp sends after a QR scan: pairing succeeds, then the
+// server restarts the stream with error 515 and closes the connection
+func TestPostPairingRestartReconnectsOnce(t *testing.T) {
+	service, session, client := newPairingSession(t, nil)
+
+	dispatch(session,
+		&events.PairSuccess{ID: *session.Client.Store.ID},
+		&events.StreamError{Code: "515"},
+		&events.Disconnected{},
+		&events.ManualLoginReconnect{},
+	)
+	close(client.release)
+
+	if err := service.WaitForPairingComplete(session.ID, 5*time.Second); err != nil {
+		t.Fatalf("pairing did not complete: %v", err)
+	}
+	if n := client.connects.Load(); n != 1 {
+		t.Errorf("reconnected %d times, want once", n)
+	}
+	if state := session.State(); !state.Connected || !state.LoggedIn || state.LastDisconnectReason != "" {
+		t.Errorf("state = %+v, want connected and logged in without a disconnect reason", state)
+	}
+	service.mu.RLock()
+	finalizing := session.PairingFinalizing
+	service.mu.RUnlock()
+	if finalizing {
+		t.Error("session still finalizing its pairing")
+	}
+}
+
+func TestPostPairingReconnectFailure(t *testing.T) {
+	service, session, client := newPairingSession(t, errors.New("connection refused"))
+
+	dispatch(session,
+		&events.PairSuccess{ID: *session.Client.Store.ID},
+		&events.ManualLoginReconnect{},
+	)
+	close(client.release)
+
+	if err := service.WaitForPairingComplete(session.ID, 5*time.Second); err == nil {
+		t.Fatal("pairing completed although the reconnect failed")
+	}
+	if n := client.connects.Load(); n != 1 {
+		t.Errorf("reconnected %d times, want once", n)
+	}
+}
+
+// Disconnects are only taken for the post-pairing restart within the window
+// after a pairing
+func TestDisconnectOutsidePairing(t *testing.T) {
+	tests := []struct {
+		name     string
+		pairedAt time.Duration // before the disconnect, or no pairing when 0
+	}{
+		{"without pairing", 0},
+		{"after the window", postPairReconnectWindow + time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, session, client := newPairingSession(t, nil)
+			close(client.release)
+			if err := service.ConnectSession(session.ID); err != nil {
+				t.Fatalf("failed to connect session: %v", err)
+			}
+
+			if tt.pairedAt != 0 {
+				dispatch(session, &events.PairSuccess{ID: *session.Client.Store.ID})
+				service.mu.Lock()
+				session.PairedAt = time.Now().Add(-tt.pairedAt)
+				service.mu.Unlock()
+			}
+			dispatch(session, &events.Disconnected{})
+
+			if n := client.connects.Load(); n != 0 {
+				t.Errorf("reconnected %d times, want none", n)
+			}
+			if state := session.State(); state.Connected || state.LoggedIn {
+				t.Errorf("state = %+v, want disconnected", state)
+			}
+		})
+	}
+}
@@ -30,4 +30,16 @@ func GenerateAPIKey() (string, error) {
 func ValidateAPIKeyFormat(apiKey string) bool {
 	// Should start with wams_ and be at least 47 characters total
 	return strings.HasPrefix(apiKey, "wams_") && len(apiKey) >= 47
-}
\ No newline at end of file
+}
+// HashAPIKey returns the hash under which an API key is stored
+func HashAPIKey(apiKey string) string {
+	return HashToken(apiKey)
+}
+
+// APIKeyHint identifies an API key without revealing it
+func APIKeyHint(apiKey string) string {
+	if len(apiKey) <= 9 {
+		return "wams_..."
+	}
+	return "wams_..." + apiKey[len(apiKey)-4:]
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NormalizeBaseURL validates a public base URL (scheme, host and optional path
+// prefix) and returns it without a trailing slash
+func NormalizeBaseURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("URL must start with http:// or https://")
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("URL must include a host")
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("URL must not contain a query string or fragment")
+	}
+
+	return strings.TrimRight(parsed.String(), "/"), nil
+}
+
+// JoinURL joins a base URL and a path with exactly one slash between them.
+// Paths that are already absolute URLs (e.g. pre-signed storage URLs) are returned unchanged.
+func JoinURL(base, path string) string {
+	if base == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// RequestBaseURL derives the public base URL from an incoming request. The
+// X-Forwarded-Proto and X-Forwarded-Host headers are only honoured when the
+// server runs behind a trusted reverse proxy.
+func RequestBaseURL(r *http.Request, trustProxyHeaders bool) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if trustProxyHeaders {
+		if proto := firstHeaderValue(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// firstHeaderValue returns the first entry of a comma-separated header value
+func firstHeaderValue(value string) string {
+	if idx := strings.Index(value, ","); idx != -1 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
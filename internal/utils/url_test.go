@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		tls     bool
+		headers map[string]string
+		trust   bool
+		want    string
+	}{
+		{name: "plain", host: "wa.example.com", want: "http://wa.example.com"},
+		{name: "tls", host: "wa.example.com:8443", tls: true, want: "https://wa.example.com:8443"},
+		{
+			name:    "forwarded headers ignored",
+			host:    "10.0.0.5:8080",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "wa.example.com"},
+			want:    "http://10.0.0.5:8080",
+		},
+		{
+			name:    "forwarded headers trusted",
+			host:    "10.0.0.5:8080",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "wa.example.com"},
+			trust:   true,
+			want:    "https://wa.example.com",
+		},
+		{
+			name:    "first of several proxies",
+			host:    "10.0.0.5:8080",
+			headers: map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "wa.example.com, lb.internal"},
+			trust:   true,
+			want:    "https://wa.example.com",
+		},
+		{
+			name:    "unknown scheme ignored",
+			host:    "wa.example.com",
+			headers: map[string]string{"X-Forwarded-Proto": "gopher"},
+			trust:   true,
+			want:    "http://wa.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/sessions", nil)
+			r.Host = tt.host
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			} else {
+				r.TLS = nil
+			}
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			if got := RequestBaseURL(r, tt.trust); got != tt.want {
+				t.Errorf("RequestBaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base, path, want string
+	}{
+		{"https://wa.example.com", "/api/media/temp/a.jpg", "https://wa.example.com/api/media/temp/a.jpg"},
+		{"https://wa.example.com/wa/", "/api/media/temp/a.jpg", "https://wa.example.com/wa/api/media/temp/a.jpg"},
+		{"", "/api/media/temp/a.jpg", "/api/media/temp/a.jpg"},
+		{"https://wa.example.com", "https://bucket.s3.example.com/a.jpg?sig=1", "https://bucket.s3.example.com/a.jpg?sig=1"},
+	}
+	for _, tt := range tests {
+		if got := JoinURL(tt.base, tt.path); got != tt.want {
+			t.Errorf("JoinURL(%q, %q) = %q, want %q", tt.base, tt.path, got, tt.want)
+		}
+	}
+}
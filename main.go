@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,21 +16,41 @@ import (
 	"whatsapp-multi-session/internal/middleware"
 	"whatsapp-multi-session/internal/repository"
 	"whatsapp-multi-session/internal/services"
+	"whatsapp-multi-session/pkg/audio"
+	"whatsapp-multi-session/pkg/httpclient"
 	"whatsapp-multi-session/pkg/logger"
+	"whatsapp-multi-session/pkg/metrics"
+	"whatsapp-multi-session/pkg/phone"
 	"whatsapp-multi-session/pkg/ratelimiter"
+	"whatsapp-multi-session/pkg/storage"
 )
 
+// Version is the application version, injected at build time:
+//
+//	go build -ldflags "-X main.Version=v1.2.3"
+var Version = "dev"
+
 func main() {
 	// Load configuration (will use environment variables if set, otherwise defaults)
 	cfg := config.Load()
 
 	// Initialize logger
-	log := logger.New(cfg.EnableLogging, cfg.LogLevel)
-	log.Info("Starting WhatsApp Multi-Session Manager")
+	log := logger.New(cfg.EnableLogging, cfg.LogLevel, cfg.LogFormat)
+	log.Info("Starting WhatsApp Multi-Session Manager %s", Version)
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	phone.SetDefaultRegion(cfg.DefaultPhoneRegion)
+	if cfg.PublicBaseURL == "" {
+		log.Warn("PUBLIC_BASE_URL is not set - webhook media URLs will use the address of the latest API request; set it to the server's public address")
+	}
 	log.Info("Configuration loaded - Port: %s, Log Level: %s, Database Logging: %t", cfg.Port, cfg.LogLevel, cfg.EnableDatabaseLog)
 
 	// Initialize database
 	dbConfig := repository.DatabaseConfig{
+		Type:     cfg.DatabaseType,
+		Path:     cfg.DatabasePath,
 		Host:     cfg.MySQLHost,
 		Port:     cfg.MySQLPort,
 		User:     cfg.MySQLUser,
@@ -43,6 +64,14 @@ func main() {
 	}
 	defer db.Close()
 
+	// Hold the instance lock so wamsctl refuses destructive operations while we run
+	instanceLock, err := db.AcquireInstanceLock(0)
+	if err != nil {
+		log.Warn("Could not take the instance lock (%v) - is another instance using this database?", err)
+	} else {
+		defer instanceLock.Release()
+	}
+
 	// Initialize database tables
 	if err := db.InitTables(); err != nil {
 		log.Fatalf("Failed to initialize database tables: %v", err)
@@ -50,6 +79,8 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db.DB())
+	apiKeyRepo := repository.NewAPIKeyRepository(db.DB())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db.DB())
 	sessionRepo := repository.NewSessionRepository(db.DB())
 	contactRepo := repository.NewContactRepository(db.DB())
 	contactGroupRepo := repository.NewContactGroupRepository(db.DB())
@@ -57,6 +88,12 @@ func main() {
 	autoReplyRepo := repository.NewAutoReplyRepository(db.DB())
 	analyticsRepo := repository.NewAnalyticsRepository(db.DB())
 	messageRepo := repository.NewMessageRepository(db.DB())
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(db.DB())
+	labelRepo := repository.NewLabelRepository(db.DB())
+	broadcastListRepo := repository.NewBroadcastListRepository(db.DB())
+	contactSeenRepo := repository.NewContactSeenRepository(db.DB())
+	campaignRepo := repository.NewCampaignRepository(db.DB())
+	auditLogRepo := repository.NewAuditLogRepository(db.DB())
 
 	var logRepo *repository.LogRepository
 	// Setup database logging only if enabled
@@ -69,41 +106,146 @@ func main() {
 		log.Info("Database logging disabled - logs will only appear in console")
 	}
 
+	// Initialize media/file storage
+	mediaStorage, err := storage.New(storage.Config{
+		Driver:           cfg.StorageDriver,
+		LocalPath:        cfg.StorageLocalPath,
+		S3Endpoint:       cfg.S3Endpoint,
+		S3Region:         cfg.S3Region,
+		S3Bucket:         cfg.S3Bucket,
+		S3AccessKey:      cfg.S3AccessKey,
+		S3SecretKey:      cfg.S3SecretKey,
+		S3Prefix:         cfg.S3Prefix,
+		S3ForcePathStyle: cfg.S3ForcePathStyle,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	log.Info("Media storage driver: %s", mediaStorage.Driver())
+
+	// Initialize the shared outbound HTTP client (webhooks, URL downloads)
+	outboundClient, err := httpclient.New(httpclient.Config{
+		ProxyURL: cfg.OutboundHTTPProxy,
+		NoProxy:  cfg.OutboundNoProxy,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize outbound HTTP client: %v", err)
+	}
+	if cfg.OutboundHTTPProxy != "" {
+		log.Info("Outbound HTTP requests use proxy (no_proxy: %q)", cfg.OutboundNoProxy)
+	}
+	httpClients := httpclient.NewPool(outboundClient)
+
 	// Initialize services
-	userService := services.NewUserService(userRepo, cfg.JWTSecret, log)
-	whatsappService, err := services.NewWhatsAppService(cfg.WhatsAppDBPath, sessionRepo, messageRepo, log)
+	userService := services.NewUserService(userRepo, apiKeyRepo, cfg.JWTSecret, log)
+	// Also switches the jwt package to microsecond issue times, see ConfigureTokens
+	userService.ConfigureTokens(refreshTokenRepo, cfg.JWTExpiration, cfg.RefreshTokenTTL)
+	whatsappService, err := services.NewWhatsAppService(cfg.WhatsAppDBPath, sessionRepo, messageRepo, mediaStorage, httpClients, log)
 	if err != nil {
 		log.Fatalf("Failed to initialize WhatsApp service: %v", err)
 	}
-	defer whatsappService.Close()
+	whatsappService.SetPublicBaseURL(cfg.PublicBaseURL)
+	whatsappService.SetOperatorNotifyURL(cfg.OperatorNotifyURL)
+	whatsappService.ConfigureWebhookSuspension(cfg.WebhookSuspendAfter)
+	whatsappService.ConfigureReconnect(cfg.ReconnectMaxAttempts, cfg.ReconnectBaseDelay, cfg.ReconnectMaxDelay)
+	whatsappService.ConfigureWebhookQueue(webhookDeliveryRepo, cfg.WebhookMaxAge, cfg.WebhookDeliveryRetention)
+	whatsappService.SetUploadCacheTTL(cfg.UploadCacheTTL)
+	whatsappService.SetDownloadLimits(cfg.MaxDownloadSize, cfg.DownloadTimeout)
+	whatsappService.SetAllowPrivateWebhooks(cfg.AllowPrivateWebhooks)
+	if cfg.AllowPrivateWebhooks {
+		log.Warn("ALLOW_PRIVATE_WEBHOOKS is set, webhooks and URL downloads may reach internal addresses")
+	}
+	whatsappService.SetSandboxMode(cfg.SandboxMode, cfg.SandboxReceiptDelay)
+	if ffmpeg, err := audio.NewFFmpeg(cfg.FFmpegPath); err != nil {
+		log.Warn("Voice notes must be sent as OGG/Opus and get no waveform: %v", err)
+	} else {
+		whatsappService.SetFFmpeg(ffmpeg)
+	}
+	whatsappService.SetLabelRepository(labelRepo)
+	whatsappService.SetBroadcastListRepository(broadcastListRepo)
+	whatsappService.SetUserRepository(userRepo)
+	whatsappService.SetContactSeenRepository(contactSeenRepo, contactRepo)
+	contactActivityService := services.NewContactActivityService(contactRepo, log)
+	contactActivityService.Start()
+	defer contactActivityService.Stop()
+	whatsappService.SetContactActivity(contactActivityService)
 
 	// Initialize CRM services
 	contactDetectionService := services.NewContactDetectionService(*log)
-	bulkMessagingService := services.NewBulkMessagingService(whatsappService, *log)
+	bulkMessagingService := services.NewBulkMessagingService(whatsappService, messageRepo, campaignRepo, *log)
+	if err := bulkMessagingService.RestoreJobs(); err != nil {
+		log.Error("Failed to restore bulk messaging jobs: %v", err)
+	}
+	campaignService := services.NewCampaignService(campaignRepo, contactRepo, bulkMessagingService, log)
+	campaignService.Start()
+	defer campaignService.Stop()
+	autoReplyService := services.NewAutoReplyService(autoReplyRepo, whatsappService, *log)
+	whatsappService.SetAutoReplyService(autoReplyService)
 	analyticsService := services.NewAnalyticsService(analyticsRepo, userRepo, whatsappService, log)
+	contactScoringService := services.NewContactScoringService(contactRepo, cfg.ContactScoringHour, log)
+	contactScoringService.Start()
+	defer contactScoringService.Stop()
+	blockDetectionService := services.NewBlockDetectionService(messageRepo, cfg.BlockSuspectAfter, log)
+	blockDetectionService.Start()
+	defer blockDetectionService.Stop()
+	whatsappService.StartWebhookQueue()
+	mediaRetentionService := services.NewMediaRetentionService(mediaStorage, cfg.MediaRetention, log)
+	mediaRetentionService.Start()
+	defer mediaRetentionService.Stop()
 
 	// Ensure default admin user exists
 	if err := userService.EnsureDefaultAdmin(cfg.AdminUsername, cfg.AdminPassword); err != nil {
 		log.Fatalf("Failed to ensure default admin: %v", err)
 	}
 
+	// Sensitive actions are recorded apart from the application logs
+	auditService := services.NewAuditService(auditLogRepo, log)
+
 	// Initialize rate limiter
 	rateLimiter := ratelimiter.NewLoginRateLimiter()
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userService, rateLimiter, log)
+	authHandler.SetAuditService(auditService)
 	sessionHandler := handlers.NewSessionHandler(whatsappService, messageRepo, cfg.JWTSecret, log, cfg.CORSAllowedOrigins)
+	sessionHandler.SetWebSocketLimits(cfg.WebSocketSoftLimit, cfg.WebSocketHardLimit)
+	sessionHandler.SetExportRedaction(cfg.ExportRedactContent)
+	sessionHandler.SetAutoReplyService(autoReplyService)
+	sessionHandler.SetUserService(userService)
+	sessionHandler.SetAuditService(auditService)
 	adminHandler := handlers.NewAdminHandler(userService, log)
-	mediaHandler := handlers.NewMediaHandler(log)
+	adminHandler.SetAuditService(auditService)
+	adminHandler.SetDatabase(db)
+	auditHandler := handlers.NewAuditHandler(auditService, log)
+	mediaHandler := handlers.NewMediaHandler(mediaStorage, log)
+	capabilitiesHandler := handlers.NewCapabilitiesHandler(cfg, Version, mediaStorage.Driver())
+	healthHandler := handlers.NewHealthHandler(outboundClient, cfg.OutboundHTTPProxy != "", cfg.OutboundProxyTestURL, log)
+	healthHandler.SetDatabase(db.DB())
+	healthHandler.SetWhatsAppService(whatsappService)
 
 	// Initialize CRM handlers
-	contactHandler := handlers.NewContactHandler(contactRepo, contactGroupRepo, contactDetectionService, log)
+	contactHandler := handlers.NewContactHandler(contactRepo, contactGroupRepo, userRepo, contactDetectionService, log)
 	contactGroupHandler := handlers.NewContactGroupHandler(contactGroupRepo, log)
 	//templateHandler := handlers.NewTemplateHandler(templateRepo, contactRepo, log)
-	bulkMessagingHandler := handlers.NewBulkMessagingHandler(bulkMessagingService, log)
-	autoReplyHandler := handlers.NewAutoReplyHandler(autoReplyRepo, log)
+	bulkMessagingHandler := handlers.NewBulkMessagingHandler(bulkMessagingService, contactRepo, userRepo, log)
+	bulkMessagingHandler.SetAuditService(auditService)
+	campaignHandler := handlers.NewCampaignHandler(campaignService, campaignRepo, contactRepo, contactGroupRepo, log)
+	autoReplyHandler := handlers.NewAutoReplyHandler(autoReplyRepo, autoReplyService, whatsappService, log)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, log)
 
+	// Session and bulk job gauges are read when /metrics is scraped
+	if cfg.EnableMetrics {
+		metrics.OnCollect(whatsappService.CollectMetrics)
+		metrics.OnCollect(bulkMessagingService.CollectMetrics)
+		if cfg.MetricsUsername == "" {
+			log.Warn("ENABLE_METRICS is set without METRICS_USERNAME, /metrics is served without authentication")
+		}
+	}
+
+	// Request logging and the admin latency snapshot
+	requestMetrics := middleware.NewRequestMetrics(log, cfg.SlowRequestThreshold)
+	perfHandler := handlers.NewPerfHandler(requestMetrics)
+
 	var logHandler *handlers.LogHandler
 	if cfg.EnableDatabaseLog && logRepo != nil {
 		logHandler = handlers.NewLogHandler(logRepo, log)
@@ -115,13 +257,20 @@ func main() {
 		sessionHandler,
 		adminHandler,
 		mediaHandler,
+		capabilitiesHandler,
+		healthHandler,
 		logHandler,
 		contactHandler,
 		contactGroupHandler,
 		nil, // templateHandler temporarily disabled
 		bulkMessagingHandler,
+		campaignHandler,
 		autoReplyHandler,
 		analyticsHandler,
+		perfHandler,
+		auditHandler,
+		requestMetrics,
+		whatsappService,
 		userService,
 		cfg,
 	)
@@ -153,10 +302,20 @@ func main() {
 
 	// Wait for shutdown signal
 	<-c
-	log.Info("Shutting down server...")
+	log.Info("Shutting down server (timeout %s)...", cfg.ShutdownTimeout)
+
+	// Requests, bulk jobs and the WhatsApp sessions' background work share the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	// Perform cleanup here if needed
-	log.Info("Server shutdown complete")
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warn("HTTP server did not shut down cleanly: %v", err)
+	}
+	jobsDrained, jobsAbandoned := bulkMessagingService.Shutdown(ctx)
+	tasksDrained, tasksAbandoned := whatsappService.Shutdown(ctx)
+
+	log.Info("Server shutdown complete: %d bulk job(s) saved and %d abandoned mid-send, %d webhook/message task(s) drained and %d abandoned",
+		jobsDrained, jobsAbandoned, tasksDrained, tasksAbandoned)
 }
 
 // setupRoutes configures all HTTP routes
@@ -165,17 +324,25 @@ func setupRoutes(
 	sessionHandler *handlers.SessionHandler,
 	adminHandler *handlers.AdminHandler,
 	mediaHandler *handlers.MediaHandler,
+	capabilitiesHandler *handlers.CapabilitiesHandler,
+	healthHandler *handlers.HealthHandler,
 	logHandler *handlers.LogHandler,
 	contactHandler *handlers.ContactHandler,
 	contactGroupHandler *handlers.ContactGroupHandler,
 	templateHandler interface{},
 	bulkMessagingHandler *handlers.BulkMessagingHandler,
+	campaignHandler *handlers.CampaignHandler,
 	autoReplyHandler *handlers.AutoReplyHandler,
 	analyticsHandler *handlers.AnalyticsHandler,
+	perfHandler *handlers.PerfHandler,
+	auditHandler *handlers.AuditHandler,
+	requestMetrics *middleware.RequestMetrics,
+	whatsappService *services.WhatsAppService,
 	userService *services.UserService,
 	cfg *config.Config,
 ) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(requestMetrics.Middleware)
 
 	// API routes (register these first)
 	api := router.PathPrefix("/api").Subrouter()
@@ -183,31 +350,51 @@ func setupRoutes(
 	// Auth routes (no authentication required)
 	auth := api.PathPrefix("/auth").Subrouter()
 	auth.HandleFunc("/login", authHandler.Login).Methods("POST")
+	auth.HandleFunc("/refresh", authHandler.Refresh).Methods("POST")
+	auth.HandleFunc("/logout", authHandler.Logout).Methods("POST")
 
 	// Authenticated auth routes (for password change and API key management)
 	authProtected := api.PathPrefix("/auth").Subrouter()
 	authProtected.Use(middleware.FlexibleAuthMiddleware(cfg.JWTSecret, userService))
+	authProtected.Use(middleware.RequireAPIKeyScopes)
 	authProtected.HandleFunc("/change-password", authHandler.ChangePassword).Methods("POST")
 	authProtected.HandleFunc("/api-key", authHandler.GenerateAPIKey).Methods("POST")
 	authProtected.HandleFunc("/api-key", authHandler.RevokeAPIKey).Methods("DELETE")
 	authProtected.HandleFunc("/api-key", authHandler.GetAPIKeyInfo).Methods("GET")
+	authProtected.HandleFunc("/api-keys", authHandler.ListAPIKeys).Methods("GET")
+	authProtected.HandleFunc("/api-keys", authHandler.CreateAPIKey).Methods("POST")
+	authProtected.HandleFunc("/api-keys/{id}", authHandler.DeleteAPIKey).Methods("DELETE")
+	authProtected.HandleFunc("/whoami", sessionHandler.Whoami).Methods("GET")
 
 	// Health check
-	api.HandleFunc("/health", healthHandler).Methods("GET")
+	api.HandleFunc("/health", healthHandler.Health).Methods("GET")
+	api.HandleFunc("/ready", healthHandler.Ready).Methods("GET")
 
 	// Media routes (authentication required for security)
 	media := api.PathPrefix("/media").Subrouter()
 	media.Use(middleware.FlexibleAuthMiddleware(cfg.JWTSecret, userService))
-	media.HandleFunc("/temp/{filename}", mediaHandler.ServeTempMedia).Methods("GET")
+	media.Use(middleware.RequireAPIKeyScopes)
+	media.HandleFunc("/temp/{filename}", mediaHandler.ServeTempMedia).Methods("GET", "HEAD")
 
 	// Protected routes (authentication required)
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.FlexibleAuthMiddleware(cfg.JWTSecret, userService))
+	protected.Use(middleware.RequireAPIKeyScopes)
+	if cfg.PublicBaseURL == "" {
+		// Without a configured address, webhook media URLs use the one
+		// clients reach the API on
+		protected.Use(middleware.RecordBaseURL(cfg.TrustProxyHeaders, whatsappService.SetRequestBaseURL))
+	}
+
+	// Server capabilities for client feature detection
+	protected.HandleFunc("/capabilities", capabilitiesHandler.GetCapabilities).Methods("GET")
 
 	// Session routes
 	sessions := protected.PathPrefix("/sessions").Subrouter()
 	sessions.HandleFunc("", sessionHandler.GetSessions).Methods("GET")
 	sessions.HandleFunc("", sessionHandler.CreateSession).Methods("POST")
+	sessions.HandleFunc("/bulk", sessionHandler.BulkSessions).Methods("POST")
+	sessions.HandleFunc("/bulk/{jobId}", sessionHandler.GetSessionBulkJob).Methods("GET")
 	sessions.HandleFunc("/{sessionId}", sessionHandler.GetSession).Methods("GET")
 	sessions.HandleFunc("/{sessionId}", sessionHandler.UpdateSession).Methods("PUT")
 	sessions.HandleFunc("/{sessionId}", sessionHandler.DeleteSession).Methods("DELETE")
@@ -215,36 +402,98 @@ func setupRoutes(
 	// Connection management
 	sessions.HandleFunc("/{sessionId}/connect", sessionHandler.ConnectSession).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/disconnect", sessionHandler.DisconnectSession).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/reconnect", sessionHandler.ReconnectSession).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/health", sessionHandler.GetSessionHealth).Methods("GET")
 	sessions.HandleFunc("/{sessionId}/login", sessionHandler.LoginSession).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/logout", sessionHandler.LogoutSession).Methods("POST")
 
 	// QR code and WebSocket
 	sessions.HandleFunc("/{sessionId}/qr", sessionHandler.GetQRCode).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/qr.png", sessionHandler.GetQRCodePNG).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/pair-code", sessionHandler.RequestPairCode).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/ws", sessionHandler.WebSocketHandler).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/sandbox/incoming", sessionHandler.InjectSandboxMessage).Methods("POST")
 
 	// Session metadata updates
 	sessions.HandleFunc("/{sessionId}/webhook", sessionHandler.UpdateSessionWebhook).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/webhook/resume", sessionHandler.ResumeWebhook).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/send-defaults", sessionHandler.UpdateSendDefaults).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/new-contact-detection", sessionHandler.UpdateNewContactDetection).Methods("PUT")
 	sessions.HandleFunc("/{sessionId}/name", sessionHandler.UpdateSessionName).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/tags", sessionHandler.UpdateSessionTags).Methods("PUT")
 	sessions.HandleFunc("/{sessionId}/auto-reply", sessionHandler.UpdateSessionAutoReply).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/auto-replies/enabled", sessionHandler.UpdateSessionAutoRepliesEnabled).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/auto-replies/stats", autoReplyHandler.GetSessionAutoReplyStats).Methods("GET")
 	sessions.HandleFunc("/{sessionId}/proxy", sessionHandler.UpdateSessionProxy).Methods("PUT")
 	sessions.HandleFunc("/{sessionId}/enabled", sessionHandler.UpdateSessionEnabled).Methods("PUT")
 
 	// Message routes
 	sessions.HandleFunc("/{sessionId}/send", sessionHandler.SendMessage).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/send-location", sessionHandler.SendLocation).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/send-poll", sessionHandler.SendPoll).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/send-product", sessionHandler.SendProduct).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/send-voice", sessionHandler.SendVoice).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/send-attachment", sessionHandler.SendAttachment).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/send-image", sessionHandler.SendImage).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/send-file-url", sessionHandler.SendFileFromURL).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/forward", sessionHandler.ForwardMessage).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/reply", sessionHandler.ReplyMessage).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/messages", sessionHandler.GetMessageHistory).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/messages/export", sessionHandler.ExportMessages).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/messages/{messageId}", sessionHandler.DeleteMessage).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/messages/{messageId}", sessionHandler.EditMessage).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/media/{messageId}", sessionHandler.GetMessageMedia).Methods("GET", "HEAD")
 	sessions.HandleFunc("/{sessionId}/check-number", sessionHandler.CheckNumber).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/check-numbers", sessionHandler.CheckNumbers).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/contacts/{jid}/avatar", sessionHandler.GetContactAvatar).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/contacts/{jid}/business-profile", sessionHandler.GetBusinessProfile).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/sync-contacts", sessionHandler.SyncContacts).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/react", sessionHandler.React).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/mark-read", sessionHandler.MarkRead).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/typing", sessionHandler.SendTyping).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/stop-typing", sessionHandler.StopTyping).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/set-online", sessionHandler.SetOnline).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/presence", sessionHandler.SetPresence).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/profile", sessionHandler.GetProfile).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/profile/name", sessionHandler.SetProfileName).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/profile/about", sessionHandler.SetProfileAbout).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/profile/picture", sessionHandler.SetProfilePicture).Methods("PUT")
 	sessions.HandleFunc("/{sessionId}/groups", sessionHandler.GetGroups).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/groups", sessionHandler.CreateGroup).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/groups/join", sessionHandler.JoinGroup).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/groups/join", sessionHandler.PreviewGroupInvite).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/participants", sessionHandler.AddGroupParticipants).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/participants", sessionHandler.RemoveGroupParticipants).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/admins", sessionHandler.PromoteGroupParticipants).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/admins", sessionHandler.DemoteGroupParticipants).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/leave", sessionHandler.LeaveGroup).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/name", sessionHandler.SetGroupName).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/topic", sessionHandler.SetGroupTopic).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/photo", sessionHandler.SetGroupPhoto).Methods("PUT")
+	sessions.HandleFunc("/{sessionId}/groups/{groupJid}/invite-link", sessionHandler.GetGroupInviteLink).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/broadcast-lists", sessionHandler.GetBroadcastLists).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/newsletters", sessionHandler.GetNewsletters).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/newsletters/follow", sessionHandler.FollowNewsletter).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/newsletters/unfollow", sessionHandler.UnfollowNewsletter).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/newsletters/{newsletterJid}/send", sessionHandler.SendNewsletterMessage).Methods("POST")
 	sessions.HandleFunc("/{sessionId}/conversations", sessionHandler.GetConversations).Methods("GET")
 
+	// Blocking
+	sessions.HandleFunc("/{sessionId}/status", sessionHandler.PostStatus).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/status/{messageId}", sessionHandler.RevokeStatus).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/blocklist", sessionHandler.GetBlocklist).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/blocklist", sessionHandler.BlockContact).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/blocklist/{jid}", sessionHandler.UnblockContact).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/blocked-suspects", sessionHandler.GetBlockedSuspects).Methods("GET")
+
+	// WhatsApp Business chat labels
+	sessions.HandleFunc("/{sessionId}/labels", sessionHandler.GetLabels).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/chats/{jid}/labels", sessionHandler.GetChatLabels).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/chats/{jid}/labels", sessionHandler.AddChatLabel).Methods("POST")
+	sessions.HandleFunc("/{sessionId}/chats/{jid}/labels/{labelId}", sessionHandler.RemoveChatLabel).Methods("DELETE")
+	sessions.HandleFunc("/{sessionId}/catalog", sessionHandler.GetCatalog).Methods("GET")
+	sessions.HandleFunc("/{sessionId}/chats/{jid}/ephemeral", sessionHandler.SetChatEphemeral).Methods("PUT")
+
 	// General send endpoint for compatibility with original API
 	protected.HandleFunc("/send", sessionHandler.SendMessageGeneral).Methods("POST")
 
@@ -258,6 +507,10 @@ func setupRoutes(
 	// Contact management
 	protected.HandleFunc("/contacts", contactHandler.GetContacts).Methods("GET")
 	protected.HandleFunc("/contacts", contactHandler.CreateContact).Methods("POST")
+	protected.HandleFunc("/contacts/export", contactHandler.ExportContacts).Methods("GET")
+	protected.HandleFunc("/contacts/deduplicate", contactHandler.DeduplicateContacts).Methods("POST")
+	protected.HandleFunc("/contacts/segment-settings", contactHandler.GetSegmentSettings).Methods("GET")
+	protected.HandleFunc("/contacts/segment-settings", contactHandler.UpdateSegmentSettings).Methods("PUT")
 	protected.HandleFunc("/contacts/{id}", contactHandler.UpdateContact).Methods("PUT")
 	protected.HandleFunc("/contacts/{id}", contactHandler.DeleteContact).Methods("DELETE")
 	protected.HandleFunc("/contacts/bulk", contactHandler.BulkActions).Methods("POST")
@@ -277,12 +530,25 @@ func setupRoutes(
 	protected.HandleFunc("/bulk-messages", bulkMessagingHandler.StartBulkMessaging).Methods("POST")
 	protected.HandleFunc("/bulk-messages/{jobId}", bulkMessagingHandler.GetBulkMessagingJob).Methods("GET")
 	protected.HandleFunc("/bulk-messages/{jobId}", bulkMessagingHandler.CancelBulkMessagingJob).Methods("DELETE")
+	protected.HandleFunc("/bulk-messages/{jobId}/results", bulkMessagingHandler.GetBulkMessagingJobResults).Methods("GET")
+	protected.HandleFunc("/bulk-messages/{jobId}/retry-failed", bulkMessagingHandler.RetryFailedBulkMessagingJob).Methods("POST")
+	protected.HandleFunc("/bulk-messages/{jobId}/pause", bulkMessagingHandler.PauseBulkMessagingJob).Methods("POST")
+	protected.HandleFunc("/bulk-messages/{jobId}/resume", bulkMessagingHandler.ResumeBulkMessagingJob).Methods("POST")
+
+	// Scheduled campaigns
+	protected.HandleFunc("/campaigns", campaignHandler.GetCampaigns).Methods("GET")
+	protected.HandleFunc("/campaigns", campaignHandler.CreateCampaign).Methods("POST")
+	protected.HandleFunc("/campaigns/{id}", campaignHandler.GetCampaign).Methods("GET")
+	protected.HandleFunc("/campaigns/{id}", campaignHandler.UpdateCampaign).Methods("PUT")
 
 	// Auto-reply management
 	protected.HandleFunc("/auto-replies", autoReplyHandler.GetAutoReplies).Methods("GET")
 	protected.HandleFunc("/auto-replies", autoReplyHandler.CreateAutoReply).Methods("POST")
 	protected.HandleFunc("/auto-replies/{id}", autoReplyHandler.UpdateAutoReply).Methods("PUT")
 	protected.HandleFunc("/auto-replies/{id}", autoReplyHandler.DeleteAutoReply).Methods("DELETE")
+	protected.HandleFunc("/auto-replies/{id}/logs", autoReplyHandler.GetAutoReplyLogs).Methods("GET")
+	protected.HandleFunc("/auto-replies/{id}/test", autoReplyHandler.TestAutoReply).Methods("POST")
+	protected.HandleFunc("/auto-replies/templates/{type}", autoReplyHandler.CreateAutoReplyFromTemplate).Methods("POST")
 
 	// Analytics routes
 	protected.HandleFunc("/analytics", analyticsHandler.GetAnalytics).Methods("GET")
@@ -297,10 +563,32 @@ func setupRoutes(
 	admin.HandleFunc("/users/{id}", adminHandler.GetUser).Methods("GET")
 	admin.HandleFunc("/users/{id}", adminHandler.UpdateUser).Methods("PUT")
 	admin.HandleFunc("/users/{id}", adminHandler.DeleteUser).Methods("DELETE")
+	admin.HandleFunc("/migrations", adminHandler.GetMigrations).Methods("GET")
 
 	// Admin API key management
 	admin.HandleFunc("/users/{userId}/api-key", authHandler.AdminGenerateAPIKey).Methods("POST")
 	admin.HandleFunc("/users/{userId}/api-key", authHandler.AdminRevokeAPIKey).Methods("DELETE")
+	admin.HandleFunc("/users/{userId}/messages/export", sessionHandler.AdminExportUserMessages).Methods("GET")
+
+	// Sessions across all users and their owners (admin only)
+	admin.HandleFunc("/sessions", sessionHandler.AdminGetSessions).Methods("GET")
+	admin.HandleFunc("/sessions/{sessionId}/owner", sessionHandler.ReassignSessionOwner).Methods("PUT")
+
+	// Media upload cache metrics (admin only)
+	admin.HandleFunc("/upload-cache", sessionHandler.GetUploadCacheStats).Methods("GET")
+
+	// API latency snapshot (admin only)
+	admin.HandleFunc("/perf", perfHandler.GetPerf).Methods("GET")
+
+	// Audit log of sensitive actions (admin only)
+	admin.HandleFunc("/audit-logs", auditHandler.GetAuditLogs).Methods("GET")
+
+	// Webhook delivery queue (admin only)
+	admin.HandleFunc("/webhook-deliveries", sessionHandler.GetWebhookDeliveries).Methods("GET")
+	admin.HandleFunc("/webhook-deliveries/{id}/retry", sessionHandler.RetryWebhookDelivery).Methods("POST")
+
+	// Open WebSocket connections per user and session (admin only)
+	admin.HandleFunc("/websockets", sessionHandler.GetWebSocketStats).Methods("GET")
 
 	// Log status endpoint (always available)
 	admin.HandleFunc("/logs/status", func(w http.ResponseWriter, r *http.Request) {
@@ -325,9 +613,15 @@ func setupRoutes(
 	// User registration (admin only)
 	auth_admin := api.PathPrefix("/auth").Subrouter()
 	auth_admin.Use(middleware.FlexibleAuthMiddleware(cfg.JWTSecret, userService))
+	auth_admin.Use(middleware.RequireAPIKeyScopes)
 	auth_admin.Use(middleware.RequireRole("admin"))
 	auth_admin.HandleFunc("/register", authHandler.Register).Methods("POST")
 
+	// Prometheus metrics
+	if cfg.EnableMetrics {
+		router.Handle("/metrics", metrics.Handler(cfg.MetricsUsername, cfg.MetricsPassword)).Methods("GET")
+	}
+
 	// Static files (frontend) - register last to avoid conflicts
 	if cfg.EnableFrontend {
 		router.PathPrefix("/").Handler(SPAHandler("./frontend/dist/"))
@@ -339,13 +633,6 @@ func setupRoutes(
 	return router
 }
 
-// healthHandler provides a simple health check endpoint
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"ok","service":"whatsapp-multi-session"}`)
-}
-
 // frontendDisabledHandler serves a message when the frontend is disabled
 func frontendDisabledHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
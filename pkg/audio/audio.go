@@ -0,0 +1,190 @@
+// Package audio prepares audio for WhatsApp voice notes: it recognizes and
+// measures OGG/Opus files, and converts other formats and computes waveforms
+// with ffmpeg when it is installed.
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// VoiceMimetype is the mimetype WhatsApp expects for voice notes
+const VoiceMimetype = "audio/ogg; codecs=opus"
+
+// WaveformSamples is the number of bars in a voice note waveform
+const WaveformSamples = 64
+
+// opusGranuleRate is the rate of Ogg granule positions in Opus streams,
+// regardless of the input sample rate
+const opusGranuleRate = 48000
+
+// ErrNotOggOpus is returned for data that is not an OGG/Opus stream
+var ErrNotOggOpus = errors.New("not an OGG/Opus stream")
+
+// IsOggOpus reports whether data starts an OGG stream carrying Opus audio
+func IsOggOpus(data []byte) bool {
+	if len(data) < 27 || !bytes.HasPrefix(data, []byte("OggS")) {
+		return false
+	}
+	body, _, ok := oggPage(data)
+	return ok && bytes.HasPrefix(body, []byte("OpusHead"))
+}
+
+// OggOpusDuration returns the playing time of an OGG/Opus stream, read from
+// the granule position of its last page
+func OggOpusDuration(data []byte) (time.Duration, error) {
+	if !IsOggOpus(data) {
+		return 0, ErrNotOggOpus
+	}
+	head, _, _ := oggPage(data)
+	if len(head) < 12 {
+		return 0, fmt.Errorf("truncated OpusHead")
+	}
+	preSkip := int64(binary.LittleEndian.Uint16(head[10:12]))
+	serial := binary.LittleEndian.Uint32(data[14:18])
+
+	var granule int64
+	for rest := data; len(rest) >= 27 && bytes.HasPrefix(rest, []byte("OggS")); {
+		_, next, ok := oggPage(rest)
+		if !ok {
+			break
+		}
+		if binary.LittleEndian.Uint32(rest[14:18]) == serial {
+			// Pages without a completed packet carry -1, which never wins
+			if position := int64(binary.LittleEndian.Uint64(rest[6:14])); position > granule {
+				granule = position
+			}
+		}
+		rest = next
+	}
+
+	samples := granule - preSkip
+	if samples < 0 {
+		samples = 0
+	}
+	return time.Duration(samples) * time.Second / opusGranuleRate, nil
+}
+
+// oggPage splits the first OGG page off data, returning its body and the data after it
+func oggPage(data []byte) (body, rest []byte, ok bool) {
+	if len(data) < 27 {
+		return nil, nil, false
+	}
+	segments := int(data[26])
+	headerLength := 27 + segments
+	if len(data) < headerLength {
+		return nil, nil, false
+	}
+	bodyLength := 0
+	for _, size := range data[27:headerLength] {
+		bodyLength += int(size)
+	}
+	if len(data) < headerLength+bodyLength {
+		return nil, nil, false
+	}
+	return data[headerLength : headerLength+bodyLength], data[headerLength+bodyLength:], true
+}
+
+// FFmpeg converts audio with an ffmpeg binary
+type FFmpeg struct {
+	path string
+}
+
+// NewFFmpeg finds the ffmpeg binary at path, or by that name in PATH
+func NewFFmpeg(path string) (*FFmpeg, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	return &FFmpeg{path: resolved}, nil
+}
+
+// ToOggOpus converts audio in any format ffmpeg reads to mono OGG/Opus, as
+// recorded by WhatsApp
+func (f *FFmpeg) ToOggOpus(ctx context.Context, data []byte) ([]byte, error) {
+	return f.run(ctx, data, "-vn", "-ac", "1", "-ar", "48000", "-c:a", "libopus", "-b:a", "32k", "-application", "voip", "-f", "ogg")
+}
+
+// Waveform computes the WaveformSamples bars WhatsApp draws for a voice note,
+// each the mean loudness of its slice of the audio scaled to 0-100
+func (f *FFmpeg) Waveform(ctx context.Context, data []byte) ([]byte, error) {
+	pcm, err := f.run(ctx, data, "-vn", "-ac", "1", "-ar", "8000", "-f", "s16le", "-c:a", "pcm_s16le")
+	if err != nil {
+		return nil, err
+	}
+	return waveform(pcm), nil
+}
+
+// waveform reduces signed 16-bit little endian PCM to WaveformSamples bars
+func waveform(pcm []byte) []byte {
+	samples := len(pcm) / 2
+	bars := make([]byte, WaveformSamples)
+	if samples == 0 {
+		return bars
+	}
+
+	levels := make([]float64, WaveformSamples)
+	var loudest float64
+	for bar := range levels {
+		start, end := bar*samples/WaveformSamples, (bar+1)*samples/WaveformSamples
+		if end <= start {
+			continue
+		}
+		var sum float64
+		for i := start; i < end; i++ {
+			sample := float64(int16(binary.LittleEndian.Uint16(pcm[2*i:])))
+			if sample < 0 {
+				sample = -sample
+			}
+			sum += sample
+		}
+		levels[bar] = sum / float64(end-start)
+		if levels[bar] > loudest {
+			loudest = levels[bar]
+		}
+	}
+	if loudest == 0 {
+		return bars
+	}
+	for bar, level := range levels {
+		bars[bar] = byte(level / loudest * 100)
+	}
+	return bars
+}
+
+// run feeds data to ffmpeg through a temporary file, since some containers
+// cannot be read from a pipe, and returns what it writes to stdout
+func (f *FFmpeg) run(ctx context.Context, data []byte, outputArgs ...string) ([]byte, error) {
+	input, err := os.CreateTemp("", "wams-audio-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(input.Name())
+	if _, err := input.Write(data); err != nil {
+		input.Close()
+		return nil, fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := input.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-i", input.Name()}, outputArgs...)
+	args = append(args, "pipe:1")
+	cmd := exec.CommandContext(ctx, f.path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := bytes.TrimSpace(stderr.Bytes()); len(message) > 0 {
+			return nil, fmt.Errorf("ffmpeg failed: %s", message)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+// Package httpclient builds the shared outbound HTTP client used for webhook
+// delivery and URL downloads, with optional corporate proxy support.
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// DefaultTimeout bounds a whole outbound request, including reading the body
+const DefaultTimeout = 30 * time.Second
+
+// Config configures the outbound client
+type Config struct {
+	ProxyURL string        // http(s):// or socks5:// proxy for all outbound requests, empty for direct
+	NoProxy  string        // comma-separated hosts/domains/CIDRs that bypass the proxy
+	Timeout  time.Duration // overall request timeout, DefaultTimeout when zero
+}
+
+// New creates a pooled HTTP client for the given configuration
+func New(cfg Config) (*http.Client, error) {
+	if cfg.ProxyURL != "" {
+		if err := ValidateProxyURL(cfg.ProxyURL); err != nil {
+			return nil, err
+		}
+	}
+
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  cfg.ProxyURL,
+		HTTPSProxy: cfg.ProxyURL,
+		NoProxy:    cfg.NoProxy,
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	transport := newTransport()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout(cfg.Timeout)}, nil
+}
+
+// ValidateProxyURL checks that a proxy URL is absolute and uses a supported scheme
+func ValidateProxyURL(proxyURL string) error {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("proxy URL must include a host")
+	}
+	return nil
+}
+
+// Pool hands out clients per proxy override so connections to the same proxy
+// are reused. An empty proxy URL returns the shared default client.
+type Pool struct {
+	defaultClient *http.Client
+	timeout       time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// NewPool creates a pool backed by the given default client
+func NewPool(defaultClient *http.Client) *Pool {
+	return &Pool{
+		defaultClient: defaultClient,
+		timeout:       defaultClient.Timeout,
+		clients:       make(map[string]*http.Client),
+	}
+}
+
+// Default returns the shared client
+func (p *Pool) Default() *http.Client {
+	return p.defaultClient
+}
+
+// ForProxy returns a client that sends every request through proxyURL
+func (p *Pool) ForProxy(proxyURL string) (*http.Client, error) {
+	if proxyURL == "" {
+		return p.defaultClient, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[proxyURL]; ok {
+		return client, nil
+	}
+
+	if err := ValidateProxyURL(proxyURL); err != nil {
+		return nil, err
+	}
+	parsed, _ := url.Parse(proxyURL)
+
+	transport := newTransport()
+	transport.Proxy = http.ProxyURL(parsed)
+	client := &http.Client{Transport: transport, Timeout: timeout(p.timeout)}
+	p.clients[proxyURL] = client
+
+	return client, nil
+}
+
+func newTransport() *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+func timeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultTimeout
+	}
+	return d
+}
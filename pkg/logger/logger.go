@@ -1,9 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
 // LogWriter interface for writing logs to different destinations
@@ -15,11 +19,13 @@ type LogWriter interface {
 type Logger struct {
 	enabled   bool
 	level     string
+	format    string
 	logger    *log.Logger
 	writers   []LogWriter
 	component string
 	sessionID string
 	userID    *int64
+	fields    map[string]any // Attached by WithFields; never modified once set
 }
 
 // LogLevel constants
@@ -30,12 +36,26 @@ const (
 	LevelError = "error"
 )
 
-// New creates a new logger instance
-func New(enabled bool, level string) *Logger {
+// Output formats
+const (
+	FormatText = "text" // [LEVEL] message key=value ...
+	FormatJSON = "json" // One JSON object per line
+)
+
+// New creates a new logger instance writing in format, FormatText or
+// FormatJSON. Any other format writes text.
+func New(enabled bool, level, format string) *Logger {
+	flags := log.LstdFlags
+	if format == FormatJSON {
+		flags = 0 // Entries carry their own timestamp
+	} else {
+		format = FormatText
+	}
 	return &Logger{
 		enabled: enabled,
 		level:   level,
-		logger:  log.New(os.Stdout, "", log.LstdFlags),
+		format:  format,
+		logger:  log.New(os.Stdout, "", flags),
 		writers: make([]LogWriter, 0),
 	}
 }
@@ -47,15 +67,52 @@ func (l *Logger) AddWriter(writer LogWriter) {
 
 // WithContext creates a new logger with context
 func (l *Logger) WithContext(component, sessionID string, userID *int64) *Logger {
-	return &Logger{
-		enabled:   l.enabled,
-		level:     l.level,
-		logger:    l.logger,
-		writers:   l.writers,
-		component: component,
-		sessionID: sessionID,
-		userID:    userID,
+	child := l.derive()
+	child.component = component
+	child.sessionID = sessionID
+	child.userID = userID
+	return child
+}
+
+// WithSession creates a new logger whose entries carry sessionID
+func (l *Logger) WithSession(sessionID string) *Logger {
+	child := l.derive()
+	child.sessionID = sessionID
+	return child
+}
+
+// WithFields creates a new logger whose entries carry fields besides those
+// already attached. They are written as discrete JSON fields, appended as
+// key=value in text, and stored in the metadata of database log entries.
+func (l *Logger) WithFields(fields map[string]any) *Logger {
+	child := l.derive()
+	child.fields = mergeFields(l.fields, fields)
+	return child
+}
+
+// derive copies the logger for a With* helper
+func (l *Logger) derive() *Logger {
+	child := *l
+	return &child
+}
+
+// mergeFields returns the union of base and extra, extra winning, without
+// modifying either
+func mergeFields(base, extra map[string]any) map[string]any {
+	if len(extra) == 0 {
+		return base
 	}
+	if len(base) == 0 {
+		return extra
+	}
+	merged := make(map[string]any, len(base)+len(extra))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
 }
 
 // writeToWriters writes log entry to all registered writers
@@ -94,76 +151,116 @@ func (l *Logger) shouldLog(level string) bool {
 	return msgLevel >= currentLevel
 }
 
+// logf formats and writes an entry to stdout and the writers
+func (l *Logger) logf(level string, metadata map[string]any, format string, v ...interface{}) {
+	if !l.shouldLog(level) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	fields := mergeFields(l.fields, metadata)
+	l.output(level, message, fields)
+	l.writeToWriters(level, message, fields)
+}
+
+// output writes an entry to stdout in the logger's format
+func (l *Logger) output(level, message string, fields map[string]any) {
+	if l.format != FormatJSON {
+		l.logger.Print("[" + strings.ToUpper(level) + "] " + message + l.textSuffix(fields))
+		return
+	}
+
+	entry := make(map[string]any, len(fields)+6)
+	for key, value := range fields {
+		entry[key] = value
+	}
+	// The standard fields win over attached fields of the same name
+	entry["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["message"] = message
+	if l.component != "" {
+		entry["component"] = l.component
+	}
+	if l.sessionID != "" {
+		entry["session_id"] = l.sessionID
+	}
+	if l.userID != nil {
+		entry["user_id"] = *l.userID
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// A field that cannot be encoded must not lose the message
+		line, _ = json.Marshal(map[string]any{
+			"timestamp": entry["timestamp"],
+			"level":     level,
+			"message":   message,
+			"log_error": fmt.Sprintf("failed to encode fields: %v", err),
+		})
+	}
+	l.logger.Print(string(line))
+}
+
+// textSuffix renders the session ID and fields of a text entry as key=value
+// pairs, sorted by key
+func (l *Logger) textSuffix(fields map[string]any) string {
+	if l.sessionID == "" && len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	if l.sessionID != "" {
+		fmt.Fprintf(&b, " session_id=%s", l.sessionID)
+	}
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, fields[key])
+	}
+	return b.String()
+}
+
 // Debug logs debug messages
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.shouldLog(LevelDebug) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[DEBUG] "+message)
-		l.writeToWriters(LevelDebug, message, nil)
-	}
+	l.logf(LevelDebug, nil, format, v...)
 }
 
 // DebugWithMetadata logs debug messages with metadata
 func (l *Logger) DebugWithMetadata(metadata map[string]any, format string, v ...interface{}) {
-	if l.shouldLog(LevelDebug) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[DEBUG] "+message)
-		l.writeToWriters(LevelDebug, message, metadata)
-	}
+	l.logf(LevelDebug, metadata, format, v...)
 }
 
 // Info logs info messages
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.shouldLog(LevelInfo) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[INFO] "+message)
-		l.writeToWriters(LevelInfo, message, nil)
-	}
+	l.logf(LevelInfo, nil, format, v...)
 }
 
 // InfoWithMetadata logs info messages with metadata
 func (l *Logger) InfoWithMetadata(metadata map[string]any, format string, v ...interface{}) {
-	if l.shouldLog(LevelInfo) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[INFO] "+message)
-		l.writeToWriters(LevelInfo, message, metadata)
-	}
+	l.logf(LevelInfo, metadata, format, v...)
 }
 
 // Warn logs warning messages
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.shouldLog(LevelWarn) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[WARN] "+message)
-		l.writeToWriters(LevelWarn, message, nil)
-	}
+	l.logf(LevelWarn, nil, format, v...)
 }
 
 // WarnWithMetadata logs warning messages with metadata
 func (l *Logger) WarnWithMetadata(metadata map[string]any, format string, v ...interface{}) {
-	if l.shouldLog(LevelWarn) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[WARN] "+message)
-		l.writeToWriters(LevelWarn, message, metadata)
-	}
+	l.logf(LevelWarn, metadata, format, v...)
 }
 
 // Error logs error messages
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.shouldLog(LevelError) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[ERROR] "+message)
-		l.writeToWriters(LevelError, message, nil)
-	}
+	l.logf(LevelError, nil, format, v...)
 }
 
 // ErrorWithMetadata logs error messages with metadata
 func (l *Logger) ErrorWithMetadata(metadata map[string]any, format string, v ...interface{}) {
-	if l.shouldLog(LevelError) {
-		message := fmt.Sprintf(format, v...)
-		l.logger.Printf("[ERROR] "+message)
-		l.writeToWriters(LevelError, message, metadata)
-	}
+	l.logf(LevelError, metadata, format, v...)
 }
 
 // Printf logs formatted messages (for compatibility)
@@ -174,23 +271,33 @@ func (l *Logger) Printf(format string, v ...interface{}) {
 // Println logs messages (for compatibility)
 func (l *Logger) Println(v ...interface{}) {
 	if l.shouldLog(LevelInfo) {
-		l.logger.Println("[INFO]", fmt.Sprint(v...))
+		l.output(LevelInfo, fmt.Sprint(v...), l.fields)
 	}
 }
 
 // Print logs messages without newline (for compatibility)
 func (l *Logger) Print(v ...interface{}) {
 	if l.shouldLog(LevelInfo) {
-		l.logger.Print("[INFO] ", fmt.Sprint(v...))
+		l.output(LevelInfo, fmt.Sprint(v...), l.fields)
 	}
 }
 
 // Fatal logs fatal messages and exits
 func (l *Logger) Fatal(v ...interface{}) {
-	l.logger.Fatal(v...)
+	l.fatal(fmt.Sprint(v...))
 }
 
 // Fatalf logs formatted fatal messages and exits
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.logger.Fatalf(format, v...)
-}
\ No newline at end of file
+	l.fatal(fmt.Sprintf(format, v...))
+}
+
+// fatal writes a fatal entry whether or not logging is enabled, then exits
+func (l *Logger) fatal(message string) {
+	if l.format == FormatJSON {
+		l.output("fatal", message, l.fields)
+	} else {
+		l.logger.Print(message)
+	}
+	os.Exit(1)
+}
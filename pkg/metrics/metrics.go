@@ -0,0 +1,33 @@
+package metrics
+
+// Messages
+var (
+	MessagesSent     = NewCounter("wams_messages_sent_total", "Messages sent to WhatsApp, by session.", "session_id")
+	MessagesFailed   = NewCounter("wams_messages_failed_total", "Messages WhatsApp did not accept, by session.", "session_id")
+	MessagesReceived = NewCounter("wams_messages_received_total", "Messages received from other chats, by session.", "session_id")
+)
+
+// Webhooks
+var (
+	WebhookDeliveries = NewCounter("wams_webhook_deliveries_total", "Webhook delivery attempts, by session and result (success or failure).", "session_id", "result")
+	WebhookDuration   = NewHistogram("wams_webhook_delivery_duration_seconds", "Time taken by webhook delivery attempts.", nil)
+)
+
+// Sessions, set when scraped
+var (
+	Sessions          = NewGauge("wams_sessions", "Sessions loaded on this instance.")
+	SessionsConnected = NewGauge("wams_sessions_connected", "Sessions connected to WhatsApp.")
+	SessionsLoggedIn  = NewGauge("wams_sessions_logged_in", "Sessions logged in to WhatsApp.")
+	Reconnects        = NewCounter("wams_session_reconnects_total", "Reconnect attempts after a session lost its connection, by session.", "session_id")
+)
+
+// Bulk jobs, set when scraped
+var (
+	BulkJobs        = NewGauge("wams_bulk_jobs", "Bulk messaging jobs, by status.", "status")
+	BulkJobMessages = NewGauge("wams_bulk_job_messages", "Messages of unfinished bulk jobs, by job and state (sent, failed or remaining).", "job_id", "state")
+)
+
+// HTTP
+var (
+	HTTPRequestDuration = NewHistogram("wams_http_request_duration_seconds", "API request durations, by method, route template and status code.", nil, "method", "route", "status")
+)
@@ -0,0 +1,293 @@
+// Package metrics keeps the server's Prometheus counters, gauges and
+// histograms and serves them in the Prometheus text exposition format.
+// Services update the metrics defined in metrics.go directly; only the
+// /metrics handler knows about HTTP.
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram bucket upper bounds in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a family of series sharing a name, help text and label names
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+	collectors []func()
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	registry = append(registry, m)
+	registryMu.Unlock()
+}
+
+// OnCollect registers fn to run before every scrape, to set gauges whose
+// values are cheaper to read on demand than to keep up to date
+func OnCollect(fn func()) {
+	registryMu.Lock()
+	collectors = append(collectors, fn)
+	registryMu.Unlock()
+}
+
+// family holds the series of a metric by their joined label values
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string][]string // Label values by key
+}
+
+func newFamily(name, help, kind string, labelNames []string) family {
+	return family{name: name, help: help, kind: kind, labelNames: labelNames, series: make(map[string][]string)}
+}
+
+// key returns the series key of labelValues, adding the series if new. The
+// caller holds f.mu.
+func (f *family) key(labelValues []string) string {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s takes %d label value(s), got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, exists := f.series[key]; !exists {
+		f.series[key] = append([]string(nil), labelValues...)
+	}
+	return key
+}
+
+// sortedKeys returns the series keys in a stable order. The caller holds f.mu.
+func (f *family) sortedKeys() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *family) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// labels formats label pairs, with extra pairs appended, e.g. {a="1",le="0.5"}
+func (f *family) labels(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, name := range f.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a value that only goes up, such as messages sent
+type Counter struct {
+	family
+	values map[string]float64
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labelNames), values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series of labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the series of labelValues
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.values[c.key(labelValues)] += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value that goes up and down, such as connected sessions
+type Gauge struct {
+	family
+	values map[string]float64
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labelNames), values: make(map[string]float64)}
+	register(g)
+	return g
+}
+
+// Set sets the series of labelValues to value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	g.values[g.key(labelValues)] = value
+	g.mu.Unlock()
+}
+
+// Reset removes every series, for gauges rebuilt on each collection
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	g.series = make(map[string][]string)
+	g.values = make(map[string]float64)
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels(g.series[key]), formatFloat(g.values[key]))
+	}
+}
+
+// Histogram counts observations, such as durations, into buckets
+type Histogram struct {
+	family
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// DefaultBuckets when nil, and label names
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{family: newFamily(name, help, "histogram", labelNames), buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+// Observe records value in the series of labelValues
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(labelValues)
+	v := h.values[key]
+	if v == nil {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+			break
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		values, v := h.series[key], h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(values, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(values), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(values), v.count)
+	}
+}
+
+// WriteTo runs the collectors and writes every metric in the text format
+func WriteTo(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	collect := append([]func(){}, collectors...)
+	registryMu.Unlock()
+
+	for _, fn := range collect {
+		fn()
+	}
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics. With a username set, requests must carry
+// matching basic auth credentials.
+func Handler(username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username != "" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buffered := bufio.NewWriter(w)
+		WriteTo(buffered)
+		buffered.Flush()
+	})
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string   { return helpEscaper.Replace(help) }
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
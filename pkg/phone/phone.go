@@ -0,0 +1,254 @@
+// Package phone normalizes phone numbers to E.164 and renders them in a
+// consistent, human-friendly format for every API surface (sessions,
+// webhooks, contacts and analytics).
+package phone
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidNumber is returned when the input cannot be turned into a phone number
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+// ErrNotPhoneNumber is returned for JIDs that do not carry a phone number (groups, LIDs, newsletters)
+var ErrNotPhoneNumber = errors.New("identifier is not a phone number")
+
+// FallbackRegion is used when no default region has been configured
+const FallbackRegion = "ID"
+
+// Number is a parsed phone number
+type Number struct {
+	E164          string // +6281234567890
+	CountryCode   string // 62
+	National      string // 81234567890 (national significant number)
+	Region        string // ID, empty when the country code is not in the region table
+	International string // +62 812-3456-7890
+	NationalFmt   string // 0812-3456-7890
+}
+
+// regionInfo describes how numbers of a region are dialled and grouped
+type regionInfo struct {
+	callingCode string
+	trunk       string // national trunk prefix ("0"), empty if the region has none
+	minLen      int    // shortest national significant number
+	maxLen      int    // longest national significant number
+	groups      []int  // leading digit groups; the remaining digits form the last group
+	separator   string
+	areaParens  bool // national format wraps the first group in parentheses (NANP)
+}
+
+var regions = map[string]regionInfo{
+	"ID": {callingCode: "62", trunk: "0", minLen: 9, maxLen: 12, groups: []int{3, 4}, separator: "-"},
+	"US": {callingCode: "1", minLen: 10, maxLen: 10, groups: []int{3, 3}, separator: "-", areaParens: true},
+	"CA": {callingCode: "1", minLen: 10, maxLen: 10, groups: []int{3, 3}, separator: "-", areaParens: true},
+	"GB": {callingCode: "44", trunk: "0", minLen: 10, maxLen: 10, groups: []int{4}, separator: " "},
+	"DE": {callingCode: "49", trunk: "0", minLen: 10, maxLen: 11, groups: []int{4}, separator: " "},
+	"FR": {callingCode: "33", trunk: "0", minLen: 9, maxLen: 9, groups: []int{1, 2, 2, 2}, separator: " "},
+	"NL": {callingCode: "31", trunk: "0", minLen: 9, maxLen: 9, groups: []int{1}, separator: " "},
+	"ES": {callingCode: "34", minLen: 9, maxLen: 9, groups: []int{3, 2, 2}, separator: " "},
+	"IT": {callingCode: "39", minLen: 9, maxLen: 10, groups: []int{3, 3}, separator: " "},
+	"IN": {callingCode: "91", trunk: "0", minLen: 10, maxLen: 10, groups: []int{5}, separator: " "},
+	"MY": {callingCode: "60", trunk: "0", minLen: 9, maxLen: 10, groups: []int{2, 3}, separator: "-"},
+	"SG": {callingCode: "65", minLen: 8, maxLen: 8, groups: []int{4}, separator: " "},
+	"AU": {callingCode: "61", trunk: "0", minLen: 9, maxLen: 9, groups: []int{3, 3}, separator: " "},
+}
+
+// regionByCallingCode maps a calling code to the region used for formatting.
+// Shared codes (+1) resolve to the first listed region.
+var regionByCallingCode = map[string]string{
+	"62": "ID", "1": "US", "44": "GB", "49": "DE", "33": "FR", "31": "NL",
+	"34": "ES", "39": "IT", "91": "IN", "60": "MY", "65": "SG", "61": "AU",
+}
+
+var (
+	defaultRegion   = FallbackRegion
+	defaultRegionMu sync.RWMutex
+)
+
+// SetDefaultRegion sets the server-wide region used when a user has none configured
+func SetDefaultRegion(region string) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if !IsSupportedRegion(region) {
+		return
+	}
+	defaultRegionMu.Lock()
+	defaultRegion = region
+	defaultRegionMu.Unlock()
+}
+
+// DefaultRegion returns the server-wide default region
+func DefaultRegion() string {
+	defaultRegionMu.RLock()
+	defer defaultRegionMu.RUnlock()
+	return defaultRegion
+}
+
+// IsSupportedRegion reports whether the ISO 3166-1 alpha-2 region code is known
+func IsSupportedRegion(region string) bool {
+	_, ok := regions[strings.ToUpper(region)]
+	return ok
+}
+
+// Parse normalizes raw input (digits, +E.164, 00-prefixed, national with trunk
+// prefix, or a WhatsApp JID) into a Number. Numbers without an international
+// prefix are interpreted in defaultRegion.
+func Parse(raw, defaultRegion string) (*Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrInvalidNumber
+	}
+
+	international := false
+	if at := strings.Index(raw, "@"); at != -1 {
+		server := raw[at+1:]
+		if server != "s.whatsapp.net" && server != "c.us" {
+			return nil, ErrNotPhoneNumber
+		}
+		raw = raw[:at]
+		if idx := strings.IndexAny(raw, ":."); idx != -1 {
+			raw = raw[:idx]
+		}
+		international = true
+	}
+	if strings.HasPrefix(raw, "+") {
+		international = true
+	}
+
+	digits := digitsOnly(raw)
+	if !international && strings.HasPrefix(digits, "00") {
+		digits = digits[2:]
+		international = true
+	}
+	if digits == "" {
+		return nil, ErrInvalidNumber
+	}
+
+	if !international {
+		region := strings.ToUpper(defaultRegion)
+		if region == "" {
+			region = DefaultRegion()
+		}
+		if info, ok := regions[region]; ok {
+			if info.trunk != "" && strings.HasPrefix(digits, info.trunk) {
+				digits = info.callingCode + strings.TrimPrefix(digits, info.trunk)
+			} else if looksNational(digits, info) {
+				digits = info.callingCode + digits
+			}
+		}
+	}
+
+	if len(digits) < 8 || len(digits) > 15 {
+		return nil, ErrInvalidNumber
+	}
+
+	return build(digits), nil
+}
+
+// Format returns the E.164 and display forms of raw, or empty strings if it
+// cannot be parsed. The display form is national when the number belongs to
+// the viewer's region and international otherwise.
+func Format(raw, viewerRegion string) (e164, display string) {
+	number, err := Parse(raw, viewerRegion)
+	if err != nil {
+		return "", ""
+	}
+	return number.E164, number.Display(viewerRegion)
+}
+
+// FromJID returns the E.164 form of a WhatsApp user JID, or an empty string
+// for JIDs that do not carry a phone number
+func FromJID(jid string) string {
+	if !strings.Contains(jid, "@") {
+		return ""
+	}
+	number, err := Parse(jid, "")
+	if err != nil {
+		return ""
+	}
+	return number.E164
+}
+
+// Display renders the number for a viewer in viewerRegion
+func (n *Number) Display(viewerRegion string) string {
+	if viewerRegion == "" {
+		viewerRegion = DefaultRegion()
+	}
+	if n.Region != "" && n.Region == strings.ToUpper(viewerRegion) {
+		return n.NationalFmt
+	}
+	return n.International
+}
+
+// looksNational reports whether digits should be read as a national number of
+// the region rather than an international number that omitted the "+". Regions
+// with a trunk prefix are always dialled with it nationally, so bare digits
+// there are treated as international (the usual WhatsApp convention).
+func looksNational(digits string, info regionInfo) bool {
+	if info.trunk != "" || len(digits) < info.minLen || len(digits) > info.maxLen {
+		return false
+	}
+	if strings.HasPrefix(digits, info.callingCode) {
+		rest := len(digits) - len(info.callingCode)
+		return rest < info.minLen || rest > info.maxLen
+	}
+	return true
+}
+
+func build(digits string) *Number {
+	number := &Number{E164: "+" + digits}
+
+	for size := 1; size <= 3 && size < len(digits); size++ {
+		if region, ok := regionByCallingCode[digits[:size]]; ok {
+			number.CountryCode = digits[:size]
+			number.National = digits[size:]
+			number.Region = region
+			break
+		}
+	}
+
+	if number.Region == "" {
+		number.International = number.E164
+		number.NationalFmt = number.E164
+		return number
+	}
+
+	info := regions[number.Region]
+	grouped := group(number.National, info.groups, info.separator)
+	number.International = "+" + number.CountryCode + " " + grouped
+
+	if info.areaParens && len(info.groups) > 0 && len(number.National) > info.groups[0] {
+		area := number.National[:info.groups[0]]
+		rest := group(number.National[info.groups[0]:], info.groups[1:], info.separator)
+		number.NationalFmt = "(" + area + ") " + rest
+	} else {
+		number.NationalFmt = info.trunk + grouped
+	}
+
+	return number
+}
+
+// group splits digits into the given leading group sizes plus a final remainder group
+func group(digits string, sizes []int, separator string) string {
+	parts := make([]string, 0, len(sizes)+1)
+	for _, size := range sizes {
+		if len(digits) <= size {
+			break
+		}
+		parts = append(parts, digits[:size])
+		digits = digits[size:]
+	}
+	parts = append(parts, digits)
+	return strings.Join(parts, separator)
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		raw           string
+		region        string
+		e164          string
+		numberRegion  string
+		international string
+		national      string
+	}{
+		// Indonesia
+		{"ID national with trunk", "081234567890", "ID", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID international", "+62 812-3456-7890", "US", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID digits without plus", "6281234567890", "ID", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID 00 prefix", "0062 812 3456 7890", "GB", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID JID", "6281234567890@s.whatsapp.net", "US", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID JID with device", "6281234567890:12@s.whatsapp.net", "", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"ID legacy c.us JID", "6281234567890@c.us", "", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+		{"default region", "081234567890", "", "+6281234567890", "ID", "+62 812-3456-7890", "0812-3456-7890"},
+
+		// United States
+		{"US national", "(415) 555-2671", "US", "+14155552671", "US", "+1 415-555-2671", "(415) 555-2671"},
+		{"US national with country code", "1 415 555 2671", "US", "+14155552671", "US", "+1 415-555-2671", "(415) 555-2671"},
+		{"US international", "+1 415.555.2671", "ID", "+14155552671", "US", "+1 415-555-2671", "(415) 555-2671"},
+		{"US digits without plus", "14155552671", "ID", "+14155552671", "US", "+1 415-555-2671", "(415) 555-2671"},
+
+		// Europe
+		{"GB national", "07911 123456", "GB", "+447911123456", "GB", "+44 7911 123456", "07911 123456"},
+		{"GB international", "+44 7911 123456", "ID", "+447911123456", "GB", "+44 7911 123456", "07911 123456"},
+		{"DE international", "+49 1512 3456789", "ID", "+4915123456789", "DE", "+49 1512 3456789", "01512 3456789"},
+		{"DE national", "01512 3456789", "DE", "+4915123456789", "DE", "+49 1512 3456789", "01512 3456789"},
+		{"FR national", "06 12 34 56 78", "FR", "+33612345678", "FR", "+33 6 12 34 56 78", "06 12 34 56 78"},
+		{"NL 00 prefix", "0031 6 12345678", "NL", "+31612345678", "NL", "+31 6 12345678", "06 12345678"},
+		{"ES national without trunk", "612 34 56 78", "ES", "+34612345678", "ES", "+34 612 34 56 78", "612 34 56 78"},
+		{"IT international", "+39 312 345 6789", "ID", "+393123456789", "IT", "+39 312 345 6789", "312 345 6789"},
+
+		// Calling code outside the region table
+		{"unknown calling code", "+972 50 123 4567", "ID", "+972501234567", "", "+972501234567", "+972501234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := Parse(tt.raw, tt.region)
+			if err != nil {
+				t.Fatalf("Parse(%q, %q) = %v", tt.raw, tt.region, err)
+			}
+			if number.E164 != tt.e164 || number.Region != tt.numberRegion {
+				t.Errorf("Parse(%q, %q) = %s in %q, want %s in %q", tt.raw, tt.region, number.E164, number.Region, tt.e164, tt.numberRegion)
+			}
+			if number.International != tt.international || number.NationalFmt != tt.national {
+				t.Errorf("Parse(%q, %q) formats as %q and %q, want %q and %q", tt.raw, tt.region, number.International, number.NationalFmt, tt.international, tt.national)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{"", ErrInvalidNumber},
+		{"   ", ErrInvalidNumber},
+		{"not a number", ErrInvalidNumber},
+		{"12345", ErrInvalidNumber},
+		{"+1234567890123456", ErrInvalidNumber},
+		{"120363012345678901@g.us", ErrNotPhoneNumber},
+		{"123456789012345@lid", ErrNotPhoneNumber},
+		{"123456789@newsletter", ErrNotPhoneNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if number, err := Parse(tt.raw, "ID"); !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) = %+v, %v; want %v", tt.raw, number, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		raw     string
+		viewer  string
+		e164    string
+		display string
+	}{
+		{"081234567890", "ID", "+6281234567890", "0812-3456-7890"},
+		{"+6281234567890", "US", "+6281234567890", "+62 812-3456-7890"},
+		{"+14155552671", "us", "+14155552671", "(415) 555-2671"},
+		{"+447911123456", "DE", "+447911123456", "+44 7911 123456"},
+		{"+33612345678", "FR", "+33612345678", "06 12 34 56 78"},
+		{"120363012345678901@g.us", "ID", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw+" "+tt.viewer, func(t *testing.T) {
+			e164, display := Format(tt.raw, tt.viewer)
+			if e164 != tt.e164 || display != tt.display {
+				t.Errorf("Format(%q, %q) = %q, %q; want %q, %q", tt.raw, tt.viewer, e164, display, tt.e164, tt.display)
+			}
+		})
+	}
+}
+
+func TestFromJID(t *testing.T) {
+	tests := map[string]string{
+		"6281234567890@s.whatsapp.net":    "+6281234567890",
+		"6281234567890:12@s.whatsapp.net": "+6281234567890",
+		"14155552671@s.whatsapp.net":      "+14155552671",
+		"120363012345678901@g.us":         "",
+		"123456789012345@lid":             "",
+		"6281234567890":                   "",
+		"":                                "",
+	}
+	for jid, want := range tests {
+		if got := FromJID(jid); got != want {
+			t.Errorf("FromJID(%q) = %q, want %q", jid, got, want)
+		}
+	}
+}
+
+func TestSetDefaultRegion(t *testing.T) {
+	t.Cleanup(func() { SetDefaultRegion(FallbackRegion) })
+
+	SetDefaultRegion(" us ")
+	if got := DefaultRegion(); got != "US" {
+		t.Fatalf("DefaultRegion() = %q, want US", got)
+	}
+	SetDefaultRegion("ZZ")
+	if got := DefaultRegion(); got != "US" {
+		t.Errorf("DefaultRegion() = %q after an unsupported region, want US kept", got)
+	}
+
+	number, err := Parse("(415) 555-2671", "")
+	if err != nil || number.E164 != "+14155552671" {
+		t.Errorf("Parse in the default region = %+v, %v; want +14155552671", number, err)
+	}
+}
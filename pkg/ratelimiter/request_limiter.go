@@ -0,0 +1,61 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// RequestLimiter caps how many requests each client may make per window,
+// counting them in fixed windows
+type RequestLimiter struct {
+	window  time.Duration
+	mu      sync.Mutex
+	windows map[string]*requestWindow
+}
+
+type requestWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRequestLimiter creates a limiter counting requests per window
+func NewRequestLimiter(window time.Duration) *RequestLimiter {
+	return &RequestLimiter{
+		window:  window,
+		windows: make(map[string]*requestWindow),
+	}
+}
+
+// Allow records a request of client and reports whether it stays within
+// limit requests per window; otherwise it returns how long until the next
+// window opens. A limit of 0 or less allows every request.
+func (l *RequestLimiter) Allow(client string, limit int) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, exists := l.windows[client]
+	if !exists || now.Sub(w.start) >= l.window {
+		l.prune(now)
+		w = &requestWindow{start: now}
+		l.windows[client] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// prune drops the windows that have ended; the caller holds l.mu
+func (l *RequestLimiter) prune(now time.Time) {
+	for client, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, client)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// metaSuffix is appended to a file's path for its metadata sidecar
+const metaSuffix = ".meta.json"
+
+// localMeta is the metadata stored next to each file so it does not have to
+// be sniffed or hashed again on every read
+type localMeta struct {
+	ContentType string `json:"content_type"`
+	SHA256      string `json:"sha256"`
+}
+
+// LocalStorage stores objects on the local filesystem below a root directory
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local filesystem storage rooted at path
+func NewLocalStorage(path string) (*LocalStorage, error) {
+	if path == "" {
+		path = "."
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	return &LocalStorage{root: path}, nil
+}
+
+// Driver returns the driver name
+func (s *LocalStorage) Driver() string {
+	return DriverLocal
+}
+
+// Path returns the filesystem path for a key
+func (s *LocalStorage) Path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Put writes data to the file for key, creating parent directories as needed
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	path := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(key))
+	}
+	return s.writeMeta(path, localMeta{ContentType: contentType, SHA256: sha256Hex(data)})
+}
+
+// Get opens the file for key. The returned reader is an *os.File.
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(s.Path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to open file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	object := s.objectFromInfo(key, info)
+	if err := s.loadMeta(object); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return file, object, nil
+}
+
+// Stat returns metadata for the file at key
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(s.Path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	object := s.objectFromInfo(key, info)
+	if err := s.loadMeta(object); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// Delete removes the file at key
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.Path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %v", err)
+	}
+	if err := os.Remove(s.Path(key) + metaSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file metadata: %v", err)
+	}
+
+	return nil
+}
+
+// List returns all files whose key starts with prefix
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]Object, error) {
+	if prefix != "" {
+		if err := validateKey(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []Object
+
+	// Only walk the directory containing the prefix, not the whole root
+	walkRoot := s.root
+	if dir := path.Dir(prefix + "x"); dir != "." {
+		walkRoot = s.Path(dir)
+	}
+
+	err := filepath.Walk(walkRoot, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(filePath, metaSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, *s.objectFromInfo(key, info))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+
+	return objects, nil
+}
+
+// PresignGet is not supported for local storage; media is proxied through the API instead
+func (s *LocalStorage) PresignGet(key string, expires time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// objectFromInfo builds object metadata from file info
+func (s *LocalStorage) objectFromInfo(key string, info os.FileInfo) *Object {
+	return &Object{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		ModTime:     info.ModTime(),
+	}
+}
+
+// loadMeta fills in the stored content type and hash for an object. Files
+// written before sidecars existed are hashed once and their sidecar created.
+func (s *LocalStorage) loadMeta(object *Object) error {
+	path := s.Path(object.Key)
+
+	var meta localMeta
+	if data, err := os.ReadFile(path + metaSuffix); err == nil && json.Unmarshal(data, &meta) == nil && meta.SHA256 != "" {
+		if meta.ContentType != "" {
+			object.ContentType = meta.ContentType
+		}
+		object.ETag = meta.SHA256
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to hash file: %v", err)
+	}
+
+	meta = localMeta{ContentType: object.ContentType, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	object.ETag = meta.SHA256
+
+	// Caching the hash is an optimisation; serving the file must not fail because of it
+	_ = s.writeMeta(path, meta)
+	return nil
+}
+
+// writeMeta stores the metadata sidecar for the file at path
+func (s *LocalStorage) writeMeta(path string, meta localMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode file metadata: %v", err)
+	}
+	if err := os.WriteFile(path+metaSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file metadata: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+)
+
+func sha256Of(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// Content type and hash are stored once and served from the sidecar
+func TestLocalStorageMetadata(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key         string
+		contentType string
+		want        string
+	}{
+		{"media/received/clip.bin", "video/mp4", "video/mp4"},
+		{"media/received/photo.png", "", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := s.Put(ctx, tt.key, []byte("content of "+tt.key), tt.contentType); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, object, err := s.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(reader)
+			if _, seekable := reader.(io.ReadSeeker); !seekable {
+				t.Error("local reader cannot seek")
+			}
+			reader.Close()
+			if string(data) != "content of "+tt.key {
+				t.Errorf("content = %q", data)
+			}
+
+			stat, err := s.Stat(ctx, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, got := range map[string]*Object{"Get": object, "Stat": stat} {
+				if got.ContentType != tt.want || got.ETag != sha256Of("content of "+tt.key) || got.Size != int64(len(data)) {
+					t.Errorf("%s = %+v, want type %s and the content hash", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// Files written before sidecars existed are hashed on first read
+func TestLocalStorageWithoutSidecar(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "media/received/old.png"
+	if err := s.Put(ctx, key, []byte("old image"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(s.Path(key) + metaSuffix); err != nil {
+		t.Fatal(err)
+	}
+
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.ETag != sha256Of("old image") || object.ContentType != "image/png" {
+		t.Errorf("Stat = %+v, want the hash and the type from the extension", object)
+	}
+	if _, err := os.Stat(s.Path(key) + metaSuffix); err != nil {
+		t.Errorf("sidecar was not written: %v", err)
+	}
+
+	objects, err := s.List(ctx, "media/received/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("List = %+v, want only %s without its sidecar", objects, key)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, key); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(s.Path(key) + metaSuffix); !os.IsNotExist(err) {
+		t.Errorf("sidecar left behind after Delete: %v", err)
+	}
+}
@@ -0,0 +1,495 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Storage stores objects in an S3-compatible bucket (AWS S3, MinIO, R2, ...)
+// using plain HTTP requests signed with AWS Signature Version 4.
+type S3Storage struct {
+	endpoint       *url.URL
+	region         string
+	bucket         string
+	accessKey      string
+	secretKey      string
+	prefix         string
+	forcePathStyle bool
+	client         *http.Client
+	now            func() time.Time // Signing time, replaced in tests
+}
+
+// NewS3Storage creates an S3-compatible storage backend
+func NewS3Storage(cfg Config) (*S3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+		return nil, fmt.Errorf("S3 access key and secret key are required")
+	}
+
+	region := cfg.S3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %v", err)
+	}
+
+	return &S3Storage{
+		endpoint:       parsed,
+		region:         region,
+		bucket:         cfg.S3Bucket,
+		accessKey:      cfg.S3AccessKey,
+		secretKey:      cfg.S3SecretKey,
+		prefix:         strings.Trim(cfg.S3Prefix, "/"),
+		forcePathStyle: cfg.S3ForcePathStyle,
+		client: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+		now: time.Now,
+	}, nil
+}
+
+// Driver returns the driver name
+func (s *S3Storage) Driver() string {
+	return DriverS3
+}
+
+// sha256MetaHeader carries the SHA-256 of an object's content as user metadata
+const sha256MetaHeader = "X-Amz-Meta-Sha256"
+
+// Put uploads data to the bucket
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, s.objectKey(key), nil, data)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set(sha256MetaHeader, sha256Hex(data))
+	s.sign(req, data)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return s.responseError("upload object", resp)
+	}
+
+	return nil
+}
+
+// Get downloads an object. The caller must close the returned reader.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, s.objectKey(key), nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.sign(req, nil)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, nil, s.responseError("get object", resp)
+	}
+
+	return resp.Body, s.objectFromHeader(key, resp.Header), nil
+}
+
+// Stat returns object metadata using a HEAD request
+func (s *S3Storage) Stat(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodHead, s.objectKey(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, nil)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat object: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.responseError("stat object", resp)
+	}
+
+	return s.objectFromHeader(key, resp.Header), nil
+}
+
+// Delete removes an object from the bucket
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodDelete, s.objectKey(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, nil)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s.responseError("delete object", resp)
+	}
+
+	return nil
+}
+
+// listBucketResult is the subset of the ListObjectsV2 response we use
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List returns all objects whose key starts with prefix
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	continuation := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.objectKey(prefix))
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.sign(req, nil)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := s.responseError("list objects", resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list response: %v", err)
+		}
+
+		for _, item := range result.Contents {
+			key := strings.TrimPrefix(strings.TrimPrefix(item.Key, s.prefix), "/")
+			objects = append(objects, Object{
+				Key:     key,
+				Size:    item.Size,
+				ModTime: item.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuation = result.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
+// PresignGet returns a query-signed URL that allows downloading the object
+// directly from the bucket until it expires (max 7 days per SigV4)
+func (s *S3Storage) PresignGet(key string, expires time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("presign expiry must be between 1s and 7 days")
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.credentialScope(now)
+
+	u := s.objectURL(s.objectKey(key))
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(query)
+
+	canonical, _ := canonicalRequest(http.MethodGet, u, map[string]string{"host": u.Host}, "UNSIGNED-PAYLOAD")
+	signature := s.signature(now, stringToSign(amzDate, scope, canonical))
+	u.RawQuery += "&X-Amz-Signature=" + signature
+
+	return u.String(), nil
+}
+
+// objectKey applies the configured path prefix to a key
+func (s *S3Storage) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return JoinKey(s.prefix, key)
+}
+
+// objectURL builds the URL for an object key (virtual-hosted or path style)
+func (s *S3Storage) objectURL(objectKey string) *url.URL {
+	u := *s.endpoint
+	escapedKey := escapePath(objectKey)
+
+	if s.forcePathStyle {
+		u.Path = "/" + s.bucket
+		if objectKey != "" {
+			u.Path += "/" + objectKey
+		}
+		u.RawPath = "/" + s.bucket
+		if objectKey != "" {
+			u.RawPath += "/" + escapedKey
+		}
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + objectKey
+		u.RawPath = "/" + escapedKey
+	}
+
+	return &u
+}
+
+// newRequest creates an unsigned request against the bucket
+func (s *S3Storage) newRequest(ctx context.Context, method, objectKey string, query url.Values, body []byte) (*http.Request, error) {
+	u := s.objectURL(objectKey)
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %v", err)
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+
+	return req, nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (s *S3Storage) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-meta-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+
+	canonical, signedHeaders := canonicalRequest(req.Method, req.URL, headers, payloadHash)
+	scope := s.credentialScope(now)
+	signature := s.signature(now, stringToSign(amzDate, scope, canonical))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// credentialScope returns the SigV4 credential scope for a date
+func (s *S3Storage) credentialScope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// canonicalRequest returns the SigV4 canonical request for a request to u
+// with the given headers, keyed by lower-case name, and the signed headers list
+func canonicalRequest(method string, u *url.URL, headers map[string]string, payloadHash string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n"), signedHeaders
+}
+
+// stringToSign returns the SigV4 string to sign for a canonical request
+func stringToSign(amzDate, scope, canonicalRequest string) string {
+	return strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+}
+
+// signature derives the signing key for the date and signs the string to sign
+func (s *S3Storage) signature(t time.Time, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// objectFromHeader builds object metadata from response headers
+func (s *S3Storage) objectFromHeader(key string, header http.Header) *Object {
+	obj := &Object{
+		Key:         key,
+		ContentType: header.Get("Content-Type"),
+	}
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		obj.Size = size
+	}
+	if modTime, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		obj.ModTime = modTime
+	}
+	// Prefer the content hash recorded at upload; fall back to the bucket's own ETag
+	if hash := header.Get(sha256MetaHeader); hash != "" {
+		obj.ETag = hash
+	} else {
+		obj.ETag = strings.Trim(header.Get("ETag"), `"`)
+	}
+	return obj
+}
+
+// responseError converts a non-success S3 response into an error
+func (s *S3Storage) responseError(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("failed to %s: S3 returned status %d: %s", action, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// canonicalQuery encodes query parameters sorted by key with RFC 3986 escaping
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range values[key] {
+			parts = append(parts, uriEscape(key)+"="+uriEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath escapes each segment of an object key, keeping "/" separators
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEscape escapes a string as required by SigV4 (RFC 3986 unreserved characters only)
+func uriEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Storage drivers
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// Well-known key prefixes
+const (
+	PrefixReceivedMedia = "media/received"
+	PrefixDownloads     = "downloads"
+	PrefixBackups       = "backups"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrPresignNotSupported is returned by backends that cannot hand out direct URLs
+var ErrPresignNotSupported = errors.New("presigned URLs are not supported by this storage driver")
+
+// Object describes a stored file
+type Object struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ModTime     time.Time `json:"mod_time"`
+
+	// ETag is a strong validator for the content (SHA-256 hex where the
+	// backend stores it), unquoted. Empty when the backend has none.
+	ETag string `json:"etag,omitempty"`
+}
+
+// Storage is implemented by every media/file backend (local disk, S3-compatible)
+type Storage interface {
+	// Driver returns the driver name ("local" or "s3")
+	Driver() string
+
+	// Put stores data under key, replacing any existing object
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Get opens an object for reading. For the local driver the returned
+	// reader also implements io.Seeker so it can be used with http.ServeContent.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+
+	// Stat returns object metadata without reading its content
+	Stat(ctx context.Context, key string) (*Object, error)
+
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns all objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// PresignGet returns a time-limited URL that can be used to download the
+	// object directly from the backend, or ErrPresignNotSupported.
+	PresignGet(key string, expires time.Duration) (string, error)
+}
+
+// Config holds storage configuration
+type Config struct {
+	Driver string
+
+	// Local driver
+	LocalPath string
+
+	// S3-compatible driver
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3Prefix         string
+	S3ForcePathStyle bool
+}
+
+// New creates a storage backend from configuration
+func New(cfg Config) (Storage, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", DriverLocal:
+		return NewLocalStorage(cfg.LocalPath)
+	case DriverS3:
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s (valid: local, s3)", cfg.Driver)
+	}
+}
+
+// JoinKey joins key segments with "/" and strips leading/duplicate slashes
+func JoinKey(parts ...string) string {
+	cleaned := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.Trim(part, "/")
+		if part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+	return strings.Join(cleaned, "/")
+}
+
+// validateKey rejects keys that could escape the storage root
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("storage key is required")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return fmt.Errorf("invalid storage key: %s", key)
+		}
+	}
+	return nil
+}